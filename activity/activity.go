@@ -37,6 +37,33 @@ type (
 
 	// RegisterOptions consists of options for registering an activity
 	RegisterOptions = internal.RegisterActivityOptions
+
+	// CancelReason describes why the context of a running activity was cancelled.
+	CancelReason = internal.ActivityCancelReason
+)
+
+const (
+	// CancelReasonNone means the activity context was not cancelled.
+	CancelReasonNone = internal.ActivityCancelReasonNone
+
+	// CancelReasonCancelRequested means the workflow requested cancellation of the activity,
+	// e.g. because the workflow was cancelled.
+	CancelReasonCancelRequested = internal.ActivityCancelReasonCancelRequested
+
+	// CancelReasonTimedOut means the activity timed out, either because its deadline was exceeded or
+	// because a heartbeat found the activity no longer exists on the server, e.g. after a heartbeat timeout.
+	CancelReasonTimedOut = internal.ActivityCancelReasonTimedOut
+
+	// CancelReasonWorkflowCompleted means a heartbeat found the workflow of the activity already completed.
+	CancelReasonWorkflowCompleted = internal.ActivityCancelReasonWorkflowCompleted
+
+	// CancelReasonDomainNotActive means a heartbeat found the domain is not active in this cluster,
+	// e.g. after a failover.
+	CancelReasonDomainNotActive = internal.ActivityCancelReasonDomainNotActive
+
+	// CancelReasonWorkerStopped means the worker is shutting down and cancelled the activity after
+	// WorkerStopTimeout elapsed.
+	CancelReasonWorkerStopped = internal.ActivityCancelReasonWorkerStopped
 )
 
 // ErrResultPending is returned from activity's implementation to indicate the activity is not completed when
@@ -86,11 +113,20 @@ func GetLogger(ctx context.Context) *zap.Logger {
 	return internal.GetActivityLogger(ctx)
 }
 
-// GetMetricsScope returns a metrics scope that can be used in activity
+// GetMetricsScope returns a metrics scope that can be used in activity.
+// Tags added with GetMetricsScope(ctx).Tagged, for example the customer tier, also tag the metrics the worker
+// emits for this activity task once the activity returns, like its execution latency and completion counters.
 func GetMetricsScope(ctx context.Context) tally.Scope {
 	return internal.GetActivityMetricsScope(ctx)
 }
 
+// GetCancelReason returns the reason the activity context was cancelled, or CancelReasonNone if it was not.
+// Activity cleanup logic can use it after ctx.Done() is closed to tell a cancellation requested by the workflow
+// apart from a timeout or the worker shutting down.
+func GetCancelReason(ctx context.Context) CancelReason {
+	return internal.GetActivityCancelReason(ctx)
+}
+
 // RecordHeartbeat sends heartbeat for the currently executing activity
 // If the activity is either cancelled (or) workflow/activity doesn't exist then we would cancel
 // the context with error context.Canceled.
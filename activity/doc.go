@@ -102,6 +102,7 @@ the Cadence managed service.
 
 When the activity times out due to a missed heartbeat, the last value of the details (progress in the above sample) is
 returned from the workflow.ExecuteActivity function as the details field of TimeoutError with TimeoutType_HEARTBEAT.
+They can be extracted with TimeoutError.LastHeartbeatDetails, which also returns them for the other timeout types.
 
 It is also possible to heartbeat an activity from an external source:
 
@@ -43,6 +43,10 @@ const (
 	// QueryTypeOpenSessions is the build in query type for Client.QueryWorkflow() call. Use this query type to get all open
 	// sessions in the workflow. The result will be a list of SessionInfo encoded in the encoded.Value.
 	QueryTypeOpenSessions string = internal.QueryTypeOpenSessions
+
+	// QueryTypeWorkerMetadata is the build in query type for Client.QueryWorkflow() call. Use this query type to get
+	// the metadata of the worker answering the query. The result will be a WorkerMetadata encoded in the EncodedValue.
+	QueryTypeWorkerMetadata string = internal.QueryTypeWorkerMetadata
 )
 
 type (
@@ -53,6 +57,7 @@ type (
 	FeatureFlags = internal.FeatureFlags
 
 	// StartWorkflowOptions configuration parameters for starting a workflow execution.
+	// It can be read from config files as JSON with durations like "30s", see ParseStartWorkflowOptionsJSON.
 	StartWorkflowOptions = internal.StartWorkflowOptions
 
 	// HistoryEventIterator is a iterator which can return history events
@@ -64,15 +69,93 @@ type (
 	// WorkflowIDReusePolicy defines workflow ID reuse behavior.
 	WorkflowIDReusePolicy = internal.WorkflowIDReusePolicy
 
+	// CountWorkflowWithOptionsRequest defines the request to CountWorkflowWithOptions
+	CountWorkflowWithOptionsRequest = internal.CountWorkflowWithOptionsRequest
+
+	// CountWorkflowWithOptionsResponse defines the response to CountWorkflowWithOptions
+	CountWorkflowWithOptionsResponse = internal.CountWorkflowWithOptionsResponse
+
+	// GetWorkflowHistoryWithOptionsRequest defines the request to GetWorkflowHistoryWithOptions
+	GetWorkflowHistoryWithOptionsRequest = internal.GetWorkflowHistoryWithOptionsRequest
+
+	// HistoryArchivalPolicy controls whether GetWorkflowHistoryWithOptions reads archived history.
+	HistoryArchivalPolicy = internal.HistoryArchivalPolicy
+
+	// WorkflowStatus represents the status of a workflow in a visibility query
+	WorkflowStatus = internal.WorkflowStatus
+
+	// ArchivedWorkflowQuery is a typed query of archived workflow executions.
+	ArchivedWorkflowQuery = internal.ArchivedWorkflowQuery
+
+	// ListArchivedWorkflowRequest is the request to NewArchivedWorkflowIterator
+	ListArchivedWorkflowRequest = internal.ListArchivedWorkflowRequest
+
+	// WorkflowExecutionIterator is the interface for iterating workflow executions returned by visibility
+	WorkflowExecutionIterator = internal.WorkflowExecutionIterator
+
 	// QueryWorkflowWithOptionsRequest defines the request to QueryWorkflowWithOptions
 	QueryWorkflowWithOptionsRequest = internal.QueryWorkflowWithOptionsRequest
 
 	// QueryWorkflowWithOptionsResponse defines the response to QueryWorkflowWithOptions
 	QueryWorkflowWithOptionsResponse = internal.QueryWorkflowWithOptionsResponse
 
+	// WorkerMetadata describes the runtime of a worker, returned by QueryWorkerMetadata
+	WorkerMetadata = internal.WorkerMetadata
+
+	// SignalRequest is a signal sent by SignalWorkflows
+	SignalRequest = internal.SignalRequest
+
 	// ParentClosePolicy defines the behavior performed on a child workflow when its parent is closed
 	ParentClosePolicy = internal.ParentClosePolicy
 
+	// ResetOptions configures Client.ResetWorkflowExecution.
+	ResetOptions = internal.ResetOptions
+
+	// ResetPoint is an auto-reset point of a workflow execution, returned by GetResetPoints.
+	ResetPoint = internal.ResetPoint
+
+	// ResetType selects the decision a workflow execution is reset to.
+	ResetType = internal.ResetType
+
+	// ResetReapplyType selects the events reapplied after a reset.
+	ResetReapplyType = internal.ResetReapplyType
+
+	// WorkflowIDGenerator generates a workflow ID when a workflow is started without one.
+	// It is configured through Options.WorkflowIDGenerator.
+	WorkflowIDGenerator = internal.WorkflowIDGenerator
+
+	// ScheduleClient manages cron workflows as schedules: create, describe, update, pause, resume and backfill.
+	ScheduleClient = internal.ScheduleClient
+
+	// ScheduleDescription describes a schedule returned by ScheduleClient.DescribeSchedule.
+	ScheduleDescription = internal.ScheduleDescription
+
+	// FailoverServiceOptions configures the workflow service client created by NewFailoverWorkflowService.
+	FailoverServiceOptions = internal.FailoverServiceOptions
+
+	// FailoverWorkflowService is a workflow service client spreading calls over several frontend hosts.
+	FailoverWorkflowService = internal.FailoverWorkflowService
+
+	// ConnectionOptions configures the connection established by Connect, see Options.ConnectionOptions.
+	ConnectionOptions = internal.ConnectionOptions
+
+	// DNSResolutionError is returned by Client.HealthCheck and Connect when the frontend host name can't be resolved.
+	DNSResolutionError = internal.DNSResolutionError
+
+	// ConnectError is returned by Client.HealthCheck and Connect when the frontend can't be reached.
+	ConnectError = internal.ConnectError
+
+	// AuthenticationError is returned by Client.HealthCheck and Connect when the frontend rejects the client credentials.
+	AuthenticationError = internal.AuthenticationError
+
+	// StartWorkflowDedupOptions configures the deduplication of the workflow starts of a client by their workflow ID
+	// and StartWorkflowOptions.RequestID, see Options.StartWorkflowDedup.
+	StartWorkflowDedupOptions = internal.StartWorkflowDedupOptions
+
+	// WorkflowAlreadyStartedError is returned by Client.StartWorkflow when the workflow ID is used by an existing
+	// execution. It wraps the WorkflowExecutionAlreadyStartedError returned by the server.
+	WorkflowAlreadyStartedError = internal.WorkflowAlreadyStartedError
+
 	// Client is the client for starting and getting information about a workflow executions as well as
 	// completing activities asynchronously.
 	Client interface {
@@ -85,7 +168,8 @@ type (
 		// The errors it can return:
 		//	- EntityNotExistsError, if domain does not exists
 		//	- BadRequestError
-		//	- WorkflowExecutionAlreadyStartedError
+		//	- WorkflowAlreadyStartedError, which wraps the WorkflowExecutionAlreadyStartedError and exposes the run ID
+		//	  and start time of the existing execution
 		//	- InternalServiceError
 		StartWorkflow(ctx context.Context, options StartWorkflowOptions, workflowFunc interface{}, args ...interface{}) (*workflow.Execution, error)
 
@@ -137,16 +221,25 @@ type (
 		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
 		// - signalName name to identify the signal.
 		// - arg is the data (or nil) to send with the signal, which can be read with the signal channel's Receive out-arg.
+		// - ctx can set the request ID of the signal, see WithRequestID and WithRequestIDOutput.
 		// The errors it can return:
 		//	- EntityNotExistsError
 		//	- InternalServiceError
 		//	- WorkflowExecutionAlreadyCompletedError
 		SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error
 
+		// SignalWorkflows sends the signals of the requests, signaling up to concurrency workflows in parallel. The
+		// signals to the same workflow ID are sent one at a time in the order of the requests. Once a signal to a
+		// workflow failed, the following signals to it are not sent to preserve the order, and fail too.
+		// Returns the error of each request at its index, nil if the signal was sent.
+		// - concurrency is the maximum number of workflows signaled in parallel, values below 1 signal one at a time.
+		SignalWorkflows(ctx context.Context, requests []SignalRequest, concurrency int) []error
+
 		// SignalWithStartWorkflow sends a signal to a running workflow.
 		// If the workflow is not running or not found, it starts the workflow and then sends the signal in transaction.
 		// - workflowID, signalName, signalArg are same as SignalWorkflow's parameters
-		// - options, workflow, workflowArgs are same as StartWorkflow's parameters
+		// - options, workflow, workflowArgs are same as StartWorkflow's parameters, options.RequestID is the request ID
+		//   of the signal and of the start
 		// The errors it can return:
 		//  - EntityNotExistsError, if domain does not exist
 		//  - BadRequestError
@@ -157,6 +250,7 @@ type (
 		// CancelWorkflow cancels a workflow in execution
 		// - workflow ID of the workflow.
 		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
+		// - ctx can set the request ID of the cancellation request, see WithRequestID and WithRequestIDOutput.
 		// The errors it can return:
 		//	- EntityNotExistsError
 		//	- BadRequestError
@@ -195,6 +289,16 @@ type (
 		//		}
 		GetWorkflowHistory(ctx context.Context, workflowID string, runID string, isLongPoll bool, filterType s.HistoryEventFilterType) HistoryEventIterator
 
+		// GetWorkflowHistoryWithOptions gets history events of a particular workflow like GetWorkflowHistory.
+		// See GetWorkflowHistoryWithOptionsRequest for more information. With the default HistoryArchivalPolicyAllow
+		// the iterator transparently reads the history from archival once it has been removed from the server's
+		// primary storage.
+		// The errors it can return:
+		//	- EntityNotExistsError
+		//	- BadRequestError
+		//	- InternalServiceError
+		GetWorkflowHistoryWithOptions(ctx context.Context, request *GetWorkflowHistoryWithOptionsRequest) HistoryEventIterator
+
 		// CompleteActivity reports activity completed.
 		// activity Execute method can return activity.ErrResultPending to
 		// indicate the activity is not completed when it's Execute method returns. In that case, this CompleteActivity() method
@@ -301,11 +405,28 @@ type (
 		//  - InternalServiceError
 		CountWorkflow(ctx context.Context, request *s.CountWorkflowExecutionsRequest) (*s.CountWorkflowExecutionsResponse, error)
 
+		// CountWorkflowWithOptions gets number of workflow executions based on query like CountWorkflow, optionally
+		// grouped by the values of a search attribute.
+		// See CountWorkflowWithOptionsRequest and CountWorkflowWithOptionsResponse for more information.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		CountWorkflowWithOptions(ctx context.Context, request *CountWorkflowWithOptionsRequest) (*CountWorkflowWithOptionsResponse, error)
+
 		// GetSearchAttributes returns valid search attributes keys and value types.
 		// The search attributes can be used in query of List/Scan/Count APIs. Adding new search attributes requires cadence server
 		// to update dynamic config ValidSearchAttributes.
 		GetSearchAttributes(ctx context.Context) (*s.GetSearchAttributesResponse, error)
 
+		// HealthCheck pings the cadence frontend once, without retries, to verify that it can be reached with the
+		// client configuration. It can be used to fail fast at startup and in readiness probes.
+		// The errors it can return:
+		//  - DNSResolutionError when the frontend host name can't be resolved
+		//  - ConnectError when the frontend can't be reached or doesn't answer before the ctx deadline
+		//  - AuthenticationError when the frontend rejects the client credentials
+		//  - other errors returned by the frontend
+		HealthCheck(ctx context.Context) error
+
 		// QueryWorkflow queries a given workflow's last execution and returns the query result synchronously. Parameter workflowID
 		// and queryType are required, other parameters are optional. The workflowID and runID (optional) identify the
 		// target workflow execution that this query will be send to. If runID is not specified (empty string), server will
@@ -326,6 +447,18 @@ type (
 		//  - QueryFailError
 		QueryWorkflow(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (encoded.Value, error)
 
+		// QueryWorkerMetadata queries a given workflow execution with QueryTypeWorkerMetadata and returns the metadata
+		// of the worker answering the query, e.g. to verify the version of the workers processing the workflow before a
+		// deploy. The query is answered by the worker caching the workflow or any worker polling its task list.
+		// - workflowID is required.
+		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		//  - QueryFailError
+		QueryWorkerMetadata(ctx context.Context, workflowID string, runID string) (*WorkerMetadata, error)
+
 		// QueryWorkflowWithOptions queries a given workflow execution and returns the query result synchronously.
 		// See QueryWorkflowWithOptionsRequest and QueryWorkflowWithOptionsResponse for more information.
 		// The errors it can return:
@@ -343,6 +476,15 @@ type (
 		//  - EntityNotExistError
 		ResetWorkflow(ctx context.Context, request *s.ResetWorkflowExecutionRequest) (*s.ResetWorkflowExecutionResponse, error)
 
+		// ResetWorkflowExecution resets a workflow execution to the reset point selected by options.ResetType
+		// and returns the new run. Unlike ResetWorkflow, the decision finish event to reset to is looked up from the
+		// history or auto-reset points of the workflow.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		ResetWorkflowExecution(ctx context.Context, options ResetOptions) (*workflow.Execution, error)
+
 		// DescribeWorkflowExecution returns information about the specified workflow execution.
 		// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
 		//
@@ -418,6 +560,58 @@ const (
 	WorkflowIDReusePolicyTerminateIfRunning = internal.WorkflowIDReusePolicyTerminateIfRunning
 )
 
+const (
+	// ResetTypeLastDecisionCompleted resets to the last completed decision of the run.
+	ResetTypeLastDecisionCompleted = internal.ResetTypeLastDecisionCompleted
+	// ResetTypeFirstDecisionCompleted resets to the first completed decision of the run.
+	ResetTypeFirstDecisionCompleted = internal.ResetTypeFirstDecisionCompleted
+	// ResetTypeEventID resets to ResetOptions.EventID.
+	ResetTypeEventID = internal.ResetTypeEventID
+	// ResetTypeBadBinary resets to the first decision completed by ResetOptions.BadBinaryChecksum, using the
+	// auto-reset points of the run. Everything the bad binary decided is dropped.
+	ResetTypeBadBinary = internal.ResetTypeBadBinary
+)
+
+const (
+	// ResetReapplyTypeSignal reapplies the signals received after the reset point to the new run.
+	ResetReapplyTypeSignal = internal.ResetReapplyTypeSignal
+	// ResetReapplyTypeNone reapplies nothing, signals received after the reset point are dropped.
+	ResetReapplyTypeNone = internal.ResetReapplyTypeNone
+)
+
+const (
+	// HistoryArchivalPolicyAllow reads the history from archival once it is removed from the server's primary storage.
+	HistoryArchivalPolicyAllow = internal.HistoryArchivalPolicyAllow
+	// HistoryArchivalPolicyForbid never reads archived history.
+	HistoryArchivalPolicyForbid = internal.HistoryArchivalPolicyForbid
+	// HistoryArchivalPolicyRequire only reads archived history.
+	HistoryArchivalPolicyRequire = internal.HistoryArchivalPolicyRequire
+)
+
+const (
+	// WorkflowStatusOpen is the WorkflowStatus for open workflows
+	WorkflowStatusOpen = internal.WorkflowStatusOpen
+	// WorkflowStatusClosed is the WorkflowStatus for closed workflows
+	WorkflowStatusClosed = internal.WorkflowStatusClosed
+	// WorkflowStatusALL is the WorkflowStatus for all workflows
+	WorkflowStatusALL = internal.WorkflowStatusALL
+)
+
+var (
+	// WorkflowStatusCompleted is the WorkflowStatus for completed workflow
+	WorkflowStatusCompleted = internal.WorkflowStatusCompleted
+	// WorkflowStatusFailed is the WorkflowStatus for failed workflows
+	WorkflowStatusFailed = internal.WorkflowStatusFailed
+	// WorkflowStatusCanceled is the WorkflowStatus for canceled workflows
+	WorkflowStatusCanceled = internal.WorkflowStatusCanceled
+	// WorkflowStatusTerminated is the WorkflowStatus for terminated workflows
+	WorkflowStatusTerminated = internal.WorkflowStatusTerminated
+	// WorkflowStatusContinuedAsNew is the WorkflowStatus for continuedAsNew workflows
+	WorkflowStatusContinuedAsNew = internal.WorkflowStatusContinuedAsNew
+	// WorkflowStatusTimedOut is the WorkflowStatus for timedout workflows
+	WorkflowStatusTimedOut = internal.WorkflowStatusTimedOut
+)
+
 const (
 	// ParentClosePolicyTerminate means terminating the child workflow
 	ParentClosePolicyTerminate = internal.ParentClosePolicyTerminate
@@ -432,17 +626,107 @@ func NewClient(service workflowserviceclient.Interface, domain string, options *
 	return internal.NewClient(service, domain, options)
 }
 
+// Connect creates an instance of a workflow client like NewClient. Unless Options.ConnectionOptions.LazyConnect is
+// set, it health checks the frontend before returning, so services fail fast at startup with one of
+// DNSResolutionError, ConnectError or AuthenticationError when the frontend can't be used.
+func Connect(ctx context.Context, service workflowserviceclient.Interface, domain string, options *Options) (Client, error) {
+	return internal.Connect(ctx, service, domain, options)
+}
+
 // NewDomainClient creates an instance of a domain client, to manage lifecycle of domains.
 func NewDomainClient(service workflowserviceclient.Interface, options *Options) DomainClient {
 	return internal.NewDomainClient(service, options)
 }
 
+// NewFailoverWorkflowService creates a workflow service client spreading calls over several frontend hosts, given
+// explicitly or as a DNS name, and failing over to the other hosts when one of them is unreachable.
+// The returned service can be passed to NewClient and worker.New.
+func NewFailoverWorkflowService(options FailoverServiceOptions) (FailoverWorkflowService, error) {
+	return internal.NewFailoverWorkflowService(options)
+}
+
+// GetWorkflowAlreadyStartedRunID returns the run ID of the execution that uses the workflow ID when
+// Client.StartWorkflow failed with a WorkflowExecutionAlreadyStartedError, so that the execution can be waited for
+// with Client.GetWorkflow. It returns false if err is not a WorkflowExecutionAlreadyStartedError.
+func GetWorkflowAlreadyStartedRunID(err error) (string, bool) {
+	return internal.GetWorkflowAlreadyStartedRunID(err)
+}
+
+// NewUUIDv7WorkflowIDGenerator returns a WorkflowIDGenerator that generates version 7 UUIDs, which sort by creation time.
+func NewUUIDv7WorkflowIDGenerator() WorkflowIDGenerator {
+	return internal.NewUUIDv7WorkflowIDGenerator()
+}
+
+// NewArgsHashWorkflowIDGenerator returns a WorkflowIDGenerator that generates prefix followed by the hex encoded
+// SHA-256 of the workflow type and encoded arguments, so identical starts get the same workflow ID.
+func NewArgsHashWorkflowIDGenerator(prefix string) WorkflowIDGenerator {
+	return internal.NewArgsHashWorkflowIDGenerator(prefix)
+}
+
+// WithRequestID returns a copy of ctx setting the request ID of the SignalWorkflow or CancelWorkflow call made with
+// it. The server deduplicates the signals and the cancellation requests of a workflow by their request ID, so that a
+// call made again with the same request ID, by a retry or by another system, has no effect. Optional: defaulted to a
+// uuid generated for the call.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return internal.WithRequestID(ctx, requestID)
+}
+
+// WithRequestIDOutput returns a copy of ctx storing in requestID the request ID of the SignalWorkflow or
+// CancelWorkflow call made with it before the call is sent: the one set by WithRequestID, or else the generated one.
+func WithRequestIDOutput(ctx context.Context, requestID *string) context.Context {
+	return internal.WithRequestIDOutput(ctx, requestID)
+}
+
+// ParseStartWorkflowOptionsJSON decodes options read as JSON, e.g. from a config file. The keys are the
+// lowerCamelCase names of the fields, durations are strings like "1m30s" and the WorkflowIDReusePolicy is named
+// without its prefix, e.g. "RejectDuplicate". Unknown keys and invalid values are rejected.
+func ParseStartWorkflowOptionsJSON(data []byte) (StartWorkflowOptions, error) {
+	return internal.ParseStartWorkflowOptionsJSON(data)
+}
+
+// MarshalStartWorkflowOptionsJSON encodes options in the JSON format read by ParseStartWorkflowOptionsJSON.
+func MarshalStartWorkflowOptionsJSON(options StartWorkflowOptions) ([]byte, error) {
+	return internal.MarshalStartWorkflowOptionsJSON(options)
+}
+
+// NewScheduleClient creates an instance of a schedule client, to manage cron workflows of a domain as schedules.
+func NewScheduleClient(service workflowserviceclient.Interface, domain string, options *Options) ScheduleClient {
+	return internal.NewScheduleClient(service, domain, options)
+}
+
 // make sure if new methods are added to internal.Client they are also added to public Client.
 var _ Client = internal.Client(nil)
 var _ internal.Client = Client(nil)
 var _ DomainClient = internal.DomainClient(nil)
 var _ internal.DomainClient = DomainClient(nil)
 
+// GetResetPoints returns the auto-reset points of a workflow execution described by Client.DescribeWorkflowExecution,
+// oldest first. Deploy tooling can use them to pick a safe reset target for ResetWorkflowExecution.
+func GetResetPoints(response *s.DescribeWorkflowExecutionResponse) []ResetPoint {
+	return internal.GetResetPoints(response)
+}
+
+// NewArchivedWorkflowIterator returns an iterator over the archived workflow executions matching request.Query,
+// fetching the pages lazily with c.ListArchivedWorkflow. For example:
+//
+//	iter := client.NewArchivedWorkflowIterator(ctx, c, &client.ListArchivedWorkflowRequest{
+//		Query: client.ArchivedWorkflowQuery{
+//			WorkflowType: "orderWorkflow",
+//			CloseStatus:  client.WorkflowStatusFailed,
+//			MinCloseTime: time.Now().Add(-30 * 24 * time.Hour),
+//		},
+//	})
+//	for iter.HasNext() {
+//		execution, err := iter.Next()
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func NewArchivedWorkflowIterator(ctx context.Context, c Client, request *ListArchivedWorkflowRequest) WorkflowExecutionIterator {
+	return internal.NewArchivedWorkflowIterator(ctx, c, request)
+}
+
 // NewValue creates a new encoded.Value which can be used to decode binary data returned by Cadence.  For example:
 // User had Activity.RecordHeartbeat(ctx, "my-heartbeat") and then got response from calling Client.DescribeWorkflowExecution.
 // The response contains binary field PendingActivityInfo.HeartbeatDetails,
@@ -0,0 +1,95 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package dsl interprets declarative workflow definitions on top of the workflow API, so that config driven
+// pipelines don't need their own interpreter. A definition is a tree of activity, sequence, parallel, choice and
+// retry statements that can be parsed from JSON or YAML:
+//
+//	variables:
+//	  order: "order-1"
+//	activityOptions:
+//	  taskList: "pipeline"
+//	  scheduleToStartTimeout: "1m"
+//	  startToCloseTimeout: "10m"
+//	root:
+//	  sequence:
+//	    elements:
+//	      - activity: {name: "ValidateOrder", arguments: ["order"], result: "region"}
+//	      - choice:
+//	          variable: "region"
+//	          cases:
+//	            - value: "eu"
+//	              body: {activity: {name: "ShipEU", arguments: ["order"]}}
+//	          default: {activity: {name: "Ship", arguments: ["order"]}}
+//
+// Execute can be registered as a workflow that takes the definition as its input:
+//
+//	w.RegisterWorkflowWithOptions(dsl.Execute, workflow.RegisterOptions{Name: "DSLWorkflow"})
+package dsl
+
+import (
+	"go.uber.org/cadence/internal"
+	"go.uber.org/cadence/workflow"
+)
+
+type (
+	// Workflow is a declarative workflow definition. Activities read their arguments from and store their
+	// results into string variables, initialized from Variables.
+	Workflow = internal.DSLWorkflow
+
+	// Statement is a node of a Workflow. Exactly one of its fields must be set.
+	Statement = internal.DSLStatement
+
+	// Activity executes an activity with the values of the arguments variables and stores its string result.
+	Activity = internal.DSLActivity
+
+	// ActivityOptions override the options of the activities, timeouts are parsed by time.ParseDuration.
+	ActivityOptions = internal.DSLActivityOptions
+
+	// Sequence executes its elements one after another, and stops at the first failure.
+	Sequence = internal.DSLSequence
+
+	// Parallel executes its branches concurrently. The first failure cancels the other branches.
+	Parallel = internal.DSLParallel
+
+	// Choice executes the body of the case matching the value of a variable.
+	Choice = internal.DSLChoice
+
+	// ChoiceCase is a case of a Choice.
+	ChoiceCase = internal.DSLChoiceCase
+
+	// Retry executes its body again with a backoff when it fails.
+	Retry = internal.DSLRetry
+)
+
+// ParseJSON parses and validates a JSON workflow definition.
+func ParseJSON(data []byte) (*Workflow, error) {
+	return internal.ParseDSLWorkflowJSON(data)
+}
+
+// ParseYAML parses and validates a YAML workflow definition.
+func ParseYAML(data []byte) (*Workflow, error) {
+	return internal.ParseDSLWorkflowYAML(data)
+}
+
+// Execute interprets the workflow definition w and returns the variables once it is completed.
+func Execute(ctx workflow.Context, w Workflow) (map[string]string, error) {
+	return internal.ExecuteDSLWorkflow(ctx, w)
+}
@@ -42,6 +42,11 @@ type (
 	// Cadence support using different DataConverters for different activity/childWorkflow in same workflow.
 	//   2. Activity/Workflow worker that run these activity/childWorkflow, through worker.Options.
 	DataConverter = internal.DataConverter
+
+	// StreamingDataConverter is a DataConverter that can also encode values directly into a writer. It avoids
+	// holding a second copy of large arguments in memory when they are written to a file or a network stream.
+	// The default data converter implements it.
+	StreamingDataConverter = internal.StreamingDataConverter
 )
 
 // GetDefaultDataConverter return default data converter used by Cadence worker
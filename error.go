@@ -21,6 +21,8 @@
 package cadence
 
 import (
+	"errors"
+
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal"
 	"go.uber.org/cadence/workflow"
@@ -49,44 +51,56 @@ func NewCanceledError(details ...interface{}) *CanceledError {
 	return internal.NewCanceledError(details...)
 }
 
-// IsCustomError return if the err is a CustomError
+// IsCustomError return if the err is a CustomError, or wraps one
 func IsCustomError(err error) bool {
-	_, ok := err.(*CustomError)
-	return ok
+	var target *CustomError
+	return errors.As(err, &target)
 }
 
-// IsWorkflowExecutionAlreadyStartedError return if the err is a WorkflowExecutionAlreadyStartedError
+// IsWorkflowExecutionAlreadyStartedError return if the err is a WorkflowExecutionAlreadyStartedError, or wraps one
 func IsWorkflowExecutionAlreadyStartedError(err error) bool {
-	_, ok := err.(*shared.WorkflowExecutionAlreadyStartedError)
-	return ok
+	var target *shared.WorkflowExecutionAlreadyStartedError
+	return errors.As(err, &target)
 }
 
-// IsCanceledError return if the err is a CanceledError
+// IsCanceledError return if the err is a CanceledError, or wraps one
 func IsCanceledError(err error) bool {
-	_, ok := err.(*CanceledError)
-	return ok
+	var target *CanceledError
+	return errors.As(err, &target)
 }
 
-// IsGenericError return if the err is a GenericError
+// IsGenericError return if the err is a GenericError, or wraps one
 func IsGenericError(err error) bool {
-	_, ok := err.(*workflow.GenericError)
-	return ok
+	var target *workflow.GenericError
+	return errors.As(err, &target)
 }
 
-// IsTimeoutError return if the err is a TimeoutError
+// IsTimeoutError return if the err is a TimeoutError, or wraps one
 func IsTimeoutError(err error) bool {
-	_, ok := err.(*workflow.TimeoutError)
-	return ok
+	var target *workflow.TimeoutError
+	return errors.As(err, &target)
 }
 
-// IsTerminatedError return if the err is a TerminatedError
+// IsTerminatedError return if the err is a TerminatedError, or wraps one
 func IsTerminatedError(err error) bool {
-	_, ok := err.(*workflow.TerminatedError)
-	return ok
+	var target *workflow.TerminatedError
+	return errors.As(err, &target)
 }
 
-// IsPanicError return if the err is a PanicError
+// IsPanicError return if the err is a PanicError, or wraps one
 func IsPanicError(err error) bool {
-	_, ok := err.(*workflow.PanicError)
-	return ok
+	var target *workflow.PanicError
+	return errors.As(err, &target)
+}
+
+// ErrorDetails extracts the strong typed details of err, or of the first error in its chain that has details, into
+// valuePtr. It supports CustomError, CanceledError, TimeoutError and PanicError. The details of a PanicError are a
+// workflow.PanicDetails holding the panic value, stack trace and location, for example:
+//
+//	var details workflow.PanicDetails
+//	err := cadence.ErrorDetails(activityErr, &details)
+//
+// It returns ErrNoData if err has no details.
+func ErrorDetails(err error, valuePtr ...interface{}) error {
+	return internal.ErrorDetails(err, valuePtr...)
 }
@@ -0,0 +1,53 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package errors contains helpers classifying the errors returned by the Cadence client and server, so that
+// application code does not need to type-assert the generated transport types. The helpers behave the same for the
+// Thrift and the gRPC transports and see through wrapped errors.
+package errors
+
+import (
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal"
+)
+
+// IsServiceBusy tells whether the server rejected the request because it is overloaded or the rate limit of the
+// domain is exceeded. The request can be retried after a delay.
+func IsServiceBusy(err error) bool {
+	return internal.IsServiceBusy(err)
+}
+
+// IsEntityNotExists tells whether the domain, workflow execution or other entity targeted by the request does not
+// exist.
+func IsEntityNotExists(err error) bool {
+	return internal.IsEntityNotExists(err)
+}
+
+// IsWorkflowAlreadyCompleted tells whether the request targeted a workflow execution that is already closed, e.g. a
+// signal sent to a completed workflow.
+func IsWorkflowAlreadyCompleted(err error) bool {
+	return internal.IsWorkflowAlreadyCompleted(err)
+}
+
+// IsTimeout tells whether err is a timeout error of one of timeoutTypes. Without timeoutTypes it matches the timeout
+// error of any timeout type, and also the deadline of the context or of the request being exceeded.
+func IsTimeout(err error, timeoutTypes ...shared.TimeoutType) bool {
+	return internal.IsTimeout(err, timeoutTypes...)
+}
@@ -39,8 +39,8 @@ import (
 	"go.uber.org/atomic"
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	m "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/historybuilder"
 	"go.uber.org/cadence/internal"
-	"go.uber.org/cadence/internal/common"
 	"go.uber.org/cadence/worker"
 	"go.uber.org/yarpc"
 	"go.uber.org/zap/zaptest"
@@ -94,30 +94,7 @@ func TestWorkersTestSuite(t *testing.T) {
 	suite.Run(t, new(CacheEvictionSuite))
 }
 
-func createTestEventWorkflowExecutionStarted(eventID int64, attr *m.WorkflowExecutionStartedEventAttributes) *m.HistoryEvent {
-	return &m.HistoryEvent{
-		EventId:                                 common.Int64Ptr(eventID),
-		EventType:                               common.EventTypePtr(m.EventTypeWorkflowExecutionStarted),
-		WorkflowExecutionStartedEventAttributes: attr,
-	}
-}
-
-func createTestEventDecisionTaskScheduled(eventID int64, attr *m.DecisionTaskScheduledEventAttributes) *m.HistoryEvent {
-	return &m.HistoryEvent{
-		EventId:                              common.Int64Ptr(eventID),
-		EventType:                            common.EventTypePtr(m.EventTypeDecisionTaskScheduled),
-		DecisionTaskScheduledEventAttributes: attr,
-	}
-}
-
 func (s *CacheEvictionSuite) TestResetStickyOnEviction() {
-	testEvents := []*m.HistoryEvent{
-		createTestEventWorkflowExecutionStarted(1, &m.WorkflowExecutionStartedEventAttributes{
-			TaskList: &m.TaskList{Name: common.StringPtr("tasklist")},
-		}),
-		createTestEventDecisionTaskScheduled(2, &m.DecisionTaskScheduledEventAttributes{}),
-	}
-
 	var taskCounter atomic.Int32 // lambda variable to keep count
 	// mock that manufactures unique decision tasks
 	mockPollForDecisionTask := func(
@@ -126,19 +103,16 @@ func (s *CacheEvictionSuite) TestResetStickyOnEviction() {
 		opts ...yarpc.CallOption,
 	) (success *m.PollForDecisionTaskResponse, err error) {
 		taskID := taskCounter.Inc()
-		workflowID := common.StringPtr("testID" + strconv.Itoa(int(taskID)))
-		runID := common.StringPtr("runID" + strconv.Itoa(int(taskID)))
-		// how we initialize the response here is the result of a series of trial and error
-		// the goal is we want to fabricate a response that looks real enough to our worker
-		// that it will actually go along with processing it instead of just tossing it out
-		// after polling it or giving an error
-		ret := &m.PollForDecisionTaskResponse{
-			TaskToken:              make([]byte, 5),
-			WorkflowExecution:      &m.WorkflowExecution{WorkflowId: workflowID, RunId: runID},
-			WorkflowType:           &m.WorkflowType{Name: common.StringPtr("go.uber.org/cadence/evictiontest.testReplayWorkflow")},
-			History:                &m.History{Events: testEvents},
-			PreviousStartedEventId: common.Int64Ptr(5)}
-		return ret, nil
+		// the first decision task of a new workflow, which the worker processes and puts in the cache
+		history := historybuilder.New(historybuilder.Options{
+			WorkflowType: "go.uber.org/cadence/evictiontest.testReplayWorkflow",
+			WorkflowID:   "testID" + strconv.Itoa(int(taskID)),
+			RunID:        "runID" + strconv.Itoa(int(taskID)),
+			TaskList:     "tasklist",
+		})
+		history.AddDecisionTaskScheduledEvent()
+		history.AddDecisionTaskStartedEvent()
+		return history.DecisionTask(), nil
 	}
 
 	resetStickyAPICalled := make(chan struct{})
@@ -0,0 +1,543 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package historybuilder builds workflow histories for tests: the histories replayed by a worker.WorkflowReplayer and
+// the decision tasks returned by a mocked PollForDecisionTask.
+//
+// The Builder allocates the IDs of the events and fills the attributes that refer to other events the way the
+// Cadence server does, e.g. the decision task started and completed events refer to their scheduled event, and the
+// events resulting from the decisions of the workflow refer to the decision task completed event:
+//
+//	b := historybuilder.New(historybuilder.Options{WorkflowType: "SampleWorkflow", TaskList: "tasklist"})
+//	b.AddDecisionTaskScheduledEvent()
+//	b.AddDecisionTaskStartedEvent()
+//	b.AddDecisionTaskCompletedEvent()
+//	scheduledID := b.AddActivityTaskScheduledEvent("0", "SampleActivity", nil)
+//	startedID := b.AddActivityTaskStartedEvent(scheduledID)
+//	b.AddActivityTaskCompletedEvent(scheduledID, startedID, nil)
+//	b.AddDecisionTaskScheduledEvent()
+//	b.AddDecisionTaskStartedEvent()
+//	task := b.DecisionTask()
+//
+// The builder does not check that the history is one the workflow code builds, replaying it does.
+package historybuilder
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+const (
+	defaultWorkflowID                   = "test-workflow-id"
+	defaultTaskList                     = "test-task-list"
+	defaultIdentity                     = "test-identity"
+	defaultExecutionStartToCloseTimeout = time.Hour
+	defaultTaskStartToCloseTimeout      = 10 * time.Second
+	defaultActivityTimeout              = time.Minute
+)
+
+type (
+	// Options of the history builder. Only the WorkflowType is required.
+	Options struct {
+		WorkflowType string
+		// Optional: defaults to "test-workflow-id".
+		WorkflowID string
+		// Optional: defaults to a random UUID.
+		RunID string
+		// Optional: defaults to "test-task-list". It is also the task list of the decision tasks and of the
+		// activities.
+		TaskList string
+		// Optional: the encoded input of the workflow.
+		Input []byte
+		// Optional: defaults to an hour.
+		ExecutionStartToCloseTimeout time.Duration
+		// Optional: defaults to 10 seconds.
+		TaskStartToCloseTimeout time.Duration
+		// Optional: the time of the workflow execution started event, defaults to the wall clock. The time of the
+		// later events is moved with AdvanceTime.
+		StartTime time.Time
+	}
+
+	// Builder builds the history of a workflow execution, starting with its workflow execution started event. The
+	// events are added in order with the Add methods, which return the ID of the added event.
+	// The Builder is not safe for concurrent use.
+	Builder struct {
+		options Options
+		now     time.Time
+		events  []*shared.HistoryEvent
+
+		decisionScheduledID int64
+		decisionStartedID   int64
+		decisionAttempt     int64
+		// previousStartedID is the started event ID of the last completed decision task.
+		previousStartedID int64
+		// decisionCompletedID is the ID of the last decision task completed event, which the events resulting from
+		// decisions refer to.
+		decisionCompletedID int64
+		timers              map[string]int64
+	}
+)
+
+// New returns a builder of the history of a workflow started with the options.
+func New(options Options) *Builder {
+	if len(options.WorkflowID) == 0 {
+		options.WorkflowID = defaultWorkflowID
+	}
+	if len(options.RunID) == 0 {
+		options.RunID = uuid.New()
+	}
+	if len(options.TaskList) == 0 {
+		options.TaskList = defaultTaskList
+	}
+	if options.ExecutionStartToCloseTimeout <= 0 {
+		options.ExecutionStartToCloseTimeout = defaultExecutionStartToCloseTimeout
+	}
+	if options.TaskStartToCloseTimeout <= 0 {
+		options.TaskStartToCloseTimeout = defaultTaskStartToCloseTimeout
+	}
+	if options.StartTime.IsZero() {
+		options.StartTime = time.Now()
+	}
+
+	b := &Builder{
+		options: options,
+		now:     options.StartTime,
+		timers:  make(map[string]int64),
+	}
+	event := b.newEvent(shared.EventTypeWorkflowExecutionStarted)
+	event.WorkflowExecutionStartedEventAttributes = &shared.WorkflowExecutionStartedEventAttributes{
+		WorkflowType:                        &shared.WorkflowType{Name: common.StringPtr(options.WorkflowType)},
+		TaskList:                            b.taskList(),
+		Input:                               options.Input,
+		ExecutionStartToCloseTimeoutSeconds: common.Int32Ptr(int32(options.ExecutionStartToCloseTimeout.Seconds())),
+		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(int32(options.TaskStartToCloseTimeout.Seconds())),
+		OriginalExecutionRunId:              common.StringPtr(options.RunID),
+		FirstExecutionRunId:                 common.StringPtr(options.RunID),
+		Identity:                            common.StringPtr(defaultIdentity),
+		Attempt:                             common.Int32Ptr(0),
+	}
+	b.addEvent(event)
+	return b
+}
+
+// AdvanceTime moves the time of the events added next.
+func (b *Builder) AdvanceTime(d time.Duration) {
+	b.now = b.now.Add(d)
+}
+
+// History returns a copy of the events added so far.
+func (b *Builder) History() *shared.History {
+	events := make([]*shared.HistoryEvent, len(b.events))
+	copy(events, b.events)
+	return &shared.History{Events: events}
+}
+
+// WorkflowExecution returns the execution of the workflow.
+func (b *Builder) WorkflowExecution() *shared.WorkflowExecution {
+	return &shared.WorkflowExecution{
+		WorkflowId: common.StringPtr(b.options.WorkflowID),
+		RunId:      common.StringPtr(b.options.RunID),
+	}
+}
+
+// DecisionTask returns the response of PollForDecisionTask for the started decision task, with the whole history
+// of the workflow. It panics if the last decision task is not started.
+func (b *Builder) DecisionTask() *shared.PollForDecisionTaskResponse {
+	task := b.decisionTask()
+	task.History = b.History()
+	return task
+}
+
+// StickyDecisionTask returns the response of PollForDecisionTask on the sticky task list of a worker for the started
+// decision task, the history only has the events since the previous decision task was started. It panics if the
+// last decision task is not started.
+func (b *Builder) StickyDecisionTask() *shared.PollForDecisionTaskResponse {
+	task := b.decisionTask()
+	var events []*shared.HistoryEvent
+	for _, event := range b.events {
+		if event.GetEventId() > b.previousStartedID {
+			events = append(events, event)
+		}
+	}
+	task.History = &shared.History{Events: events}
+	return task
+}
+
+func (b *Builder) decisionTask() *shared.PollForDecisionTaskResponse {
+	if b.decisionStartedID == 0 {
+		panic("historybuilder: no decision task is started")
+	}
+	scheduled := b.events[b.decisionScheduledID-1]
+	started := b.events[b.decisionStartedID-1]
+	return &shared.PollForDecisionTaskResponse{
+		TaskToken:                 []byte(fmt.Sprintf("%v/%v/%v", b.options.WorkflowID, b.options.RunID, b.decisionScheduledID)),
+		WorkflowExecution:         b.WorkflowExecution(),
+		WorkflowType:              &shared.WorkflowType{Name: common.StringPtr(b.options.WorkflowType)},
+		PreviousStartedEventId:    common.Int64Ptr(b.previousStartedID),
+		StartedEventId:            common.Int64Ptr(b.decisionStartedID),
+		Attempt:                   common.Int64Ptr(b.decisionAttempt),
+		WorkflowExecutionTaskList: b.taskList(),
+		ScheduledTimestamp:        scheduled.Timestamp,
+		StartedTimestamp:          started.Timestamp,
+		NextEventId:               common.Int64Ptr(b.nextEventID()),
+	}
+}
+
+// AddDecisionTaskScheduledEvent adds the event scheduling a decision task. The attempt of the decision task is
+// incremented if the previous one failed or timed out.
+func (b *Builder) AddDecisionTaskScheduledEvent() int64 {
+	event := b.newEvent(shared.EventTypeDecisionTaskScheduled)
+	event.DecisionTaskScheduledEventAttributes = &shared.DecisionTaskScheduledEventAttributes{
+		TaskList:                   b.taskList(),
+		StartToCloseTimeoutSeconds: common.Int32Ptr(int32(b.options.TaskStartToCloseTimeout.Seconds())),
+		Attempt:                    common.Int64Ptr(b.decisionAttempt),
+	}
+	b.decisionScheduledID = b.addEvent(event)
+	b.decisionStartedID = 0
+	return b.decisionScheduledID
+}
+
+// AddDecisionTaskStartedEvent adds the event starting the scheduled decision task. It panics if no decision task is
+// scheduled.
+func (b *Builder) AddDecisionTaskStartedEvent() int64 {
+	if b.decisionScheduledID == 0 || b.decisionStartedID != 0 {
+		panic("historybuilder: no decision task is scheduled")
+	}
+	event := b.newEvent(shared.EventTypeDecisionTaskStarted)
+	event.DecisionTaskStartedEventAttributes = &shared.DecisionTaskStartedEventAttributes{
+		ScheduledEventId: common.Int64Ptr(b.decisionScheduledID),
+		Identity:         common.StringPtr(defaultIdentity),
+		RequestId:        common.StringPtr(uuid.New()),
+	}
+	b.decisionStartedID = b.addEvent(event)
+	return b.decisionStartedID
+}
+
+// AddDecisionTaskCompletedEvent adds the event completing the started decision task. The events added next for the
+// decisions of the workflow refer to it. It panics if no decision task is started.
+func (b *Builder) AddDecisionTaskCompletedEvent() int64 {
+	scheduledID, startedID := b.closeDecisionTask()
+	event := b.newEvent(shared.EventTypeDecisionTaskCompleted)
+	event.DecisionTaskCompletedEventAttributes = &shared.DecisionTaskCompletedEventAttributes{
+		ScheduledEventId: common.Int64Ptr(scheduledID),
+		StartedEventId:   common.Int64Ptr(startedID),
+		Identity:         common.StringPtr(defaultIdentity),
+	}
+	b.decisionCompletedID = b.addEvent(event)
+	b.previousStartedID = startedID
+	b.decisionAttempt = 0
+	return b.decisionCompletedID
+}
+
+// AddDecisionTaskFailedEvent adds the event failing the started decision task. It panics if no decision task is
+// started.
+func (b *Builder) AddDecisionTaskFailedEvent(cause shared.DecisionTaskFailedCause, details []byte) int64 {
+	scheduledID, startedID := b.closeDecisionTask()
+	event := b.newEvent(shared.EventTypeDecisionTaskFailed)
+	event.DecisionTaskFailedEventAttributes = &shared.DecisionTaskFailedEventAttributes{
+		ScheduledEventId: common.Int64Ptr(scheduledID),
+		StartedEventId:   common.Int64Ptr(startedID),
+		Cause:            cause.Ptr(),
+		Details:          details,
+		Identity:         common.StringPtr(defaultIdentity),
+	}
+	b.decisionAttempt++
+	return b.addEvent(event)
+}
+
+// AddDecisionTaskTimedOutEvent adds the event timing out the started decision task. It panics if no decision task is
+// started.
+func (b *Builder) AddDecisionTaskTimedOutEvent() int64 {
+	scheduledID, startedID := b.closeDecisionTask()
+	event := b.newEvent(shared.EventTypeDecisionTaskTimedOut)
+	event.DecisionTaskTimedOutEventAttributes = &shared.DecisionTaskTimedOutEventAttributes{
+		ScheduledEventId: common.Int64Ptr(scheduledID),
+		StartedEventId:   common.Int64Ptr(startedID),
+		TimeoutType:      shared.TimeoutTypeStartToClose.Ptr(),
+	}
+	b.decisionAttempt++
+	return b.addEvent(event)
+}
+
+func (b *Builder) closeDecisionTask() (int64, int64) {
+	if b.decisionStartedID == 0 {
+		panic("historybuilder: no decision task is started")
+	}
+	scheduledID, startedID := b.decisionScheduledID, b.decisionStartedID
+	b.decisionScheduledID, b.decisionStartedID = 0, 0
+	return scheduledID, startedID
+}
+
+// AddActivityTaskScheduledEvent adds the event scheduling an activity on the task list of the workflow, with
+// timeouts of a minute.
+func (b *Builder) AddActivityTaskScheduledEvent(activityID, activityType string, input []byte) int64 {
+	timeout := common.Int32Ptr(int32(defaultActivityTimeout.Seconds()))
+	event := b.newEvent(shared.EventTypeActivityTaskScheduled)
+	event.ActivityTaskScheduledEventAttributes = &shared.ActivityTaskScheduledEventAttributes{
+		ActivityId:                    common.StringPtr(activityID),
+		ActivityType:                  &shared.ActivityType{Name: common.StringPtr(activityType)},
+		TaskList:                      b.taskList(),
+		Input:                         input,
+		ScheduleToCloseTimeoutSeconds: timeout,
+		ScheduleToStartTimeoutSeconds: timeout,
+		StartToCloseTimeoutSeconds:    timeout,
+		DecisionTaskCompletedEventId:  common.Int64Ptr(b.decisionCompletedID),
+	}
+	return b.addEvent(event)
+}
+
+// AddActivityTaskStartedEvent adds the event starting the activity scheduled by the event scheduledID.
+func (b *Builder) AddActivityTaskStartedEvent(scheduledID int64) int64 {
+	event := b.newEvent(shared.EventTypeActivityTaskStarted)
+	event.ActivityTaskStartedEventAttributes = &shared.ActivityTaskStartedEventAttributes{
+		ScheduledEventId: common.Int64Ptr(scheduledID),
+		Identity:         common.StringPtr(defaultIdentity),
+		RequestId:        common.StringPtr(uuid.New()),
+		Attempt:          common.Int32Ptr(0),
+	}
+	return b.addEvent(event)
+}
+
+// AddActivityTaskCompletedEvent adds the event completing the activity scheduled by the event scheduledID.
+func (b *Builder) AddActivityTaskCompletedEvent(scheduledID, startedID int64, result []byte) int64 {
+	event := b.newEvent(shared.EventTypeActivityTaskCompleted)
+	event.ActivityTaskCompletedEventAttributes = &shared.ActivityTaskCompletedEventAttributes{
+		Result:           result,
+		ScheduledEventId: common.Int64Ptr(scheduledID),
+		StartedEventId:   common.Int64Ptr(startedID),
+		Identity:         common.StringPtr(defaultIdentity),
+	}
+	return b.addEvent(event)
+}
+
+// AddActivityTaskFailedEvent adds the event failing the activity scheduled by the event scheduledID.
+func (b *Builder) AddActivityTaskFailedEvent(scheduledID, startedID int64, reason string, details []byte) int64 {
+	event := b.newEvent(shared.EventTypeActivityTaskFailed)
+	event.ActivityTaskFailedEventAttributes = &shared.ActivityTaskFailedEventAttributes{
+		Reason:           common.StringPtr(reason),
+		Details:          details,
+		ScheduledEventId: common.Int64Ptr(scheduledID),
+		StartedEventId:   common.Int64Ptr(startedID),
+		Identity:         common.StringPtr(defaultIdentity),
+	}
+	return b.addEvent(event)
+}
+
+// AddActivityTaskTimedOutEvent adds the event timing out the activity scheduled by the event scheduledID. The
+// startedID is 0 if the activity timed out before it was started.
+func (b *Builder) AddActivityTaskTimedOutEvent(scheduledID, startedID int64, timeoutType shared.TimeoutType) int64 {
+	event := b.newEvent(shared.EventTypeActivityTaskTimedOut)
+	event.ActivityTaskTimedOutEventAttributes = &shared.ActivityTaskTimedOutEventAttributes{
+		ScheduledEventId: common.Int64Ptr(scheduledID),
+		StartedEventId:   common.Int64Ptr(startedID),
+		TimeoutType:      timeoutType.Ptr(),
+	}
+	return b.addEvent(event)
+}
+
+// AddTimerStartedEvent adds the event starting a timer.
+func (b *Builder) AddTimerStartedEvent(timerID string, timeout time.Duration) int64 {
+	event := b.newEvent(shared.EventTypeTimerStarted)
+	event.TimerStartedEventAttributes = &shared.TimerStartedEventAttributes{
+		TimerId:                      common.StringPtr(timerID),
+		StartToFireTimeoutSeconds:    common.Int64Ptr(int64(timeout.Seconds())),
+		DecisionTaskCompletedEventId: common.Int64Ptr(b.decisionCompletedID),
+	}
+	eventID := b.addEvent(event)
+	b.timers[timerID] = eventID
+	return eventID
+}
+
+// AddTimerFiredEvent adds the event firing a timer started with AddTimerStartedEvent. It panics if the timer is not
+// started.
+func (b *Builder) AddTimerFiredEvent(timerID string) int64 {
+	startedID, ok := b.timers[timerID]
+	if !ok {
+		panic(fmt.Sprintf("historybuilder: timer %q is not started", timerID))
+	}
+	delete(b.timers, timerID)
+	event := b.newEvent(shared.EventTypeTimerFired)
+	event.TimerFiredEventAttributes = &shared.TimerFiredEventAttributes{
+		TimerId:        common.StringPtr(timerID),
+		StartedEventId: common.Int64Ptr(startedID),
+	}
+	return b.addEvent(event)
+}
+
+// AddMarkerRecordedEvent adds the event recording a marker, e.g. of a side effect or a version.
+func (b *Builder) AddMarkerRecordedEvent(markerName string, details []byte) int64 {
+	event := b.newEvent(shared.EventTypeMarkerRecorded)
+	event.MarkerRecordedEventAttributes = &shared.MarkerRecordedEventAttributes{
+		MarkerName:                   common.StringPtr(markerName),
+		Details:                      details,
+		DecisionTaskCompletedEventId: common.Int64Ptr(b.decisionCompletedID),
+	}
+	return b.addEvent(event)
+}
+
+// AddWorkflowExecutionSignaledEvent adds the event of a signal of the workflow.
+func (b *Builder) AddWorkflowExecutionSignaledEvent(signalName string, input []byte) int64 {
+	event := b.newEvent(shared.EventTypeWorkflowExecutionSignaled)
+	event.WorkflowExecutionSignaledEventAttributes = &shared.WorkflowExecutionSignaledEventAttributes{
+		SignalName: common.StringPtr(signalName),
+		Input:      input,
+		Identity:   common.StringPtr(defaultIdentity),
+	}
+	return b.addEvent(event)
+}
+
+// AddWorkflowExecutionCancelRequestedEvent adds the event requesting the cancellation of the workflow.
+func (b *Builder) AddWorkflowExecutionCancelRequestedEvent(cause string) int64 {
+	event := b.newEvent(shared.EventTypeWorkflowExecutionCancelRequested)
+	event.WorkflowExecutionCancelRequestedEventAttributes = &shared.WorkflowExecutionCancelRequestedEventAttributes{
+		Cause:    common.StringPtr(cause),
+		Identity: common.StringPtr(defaultIdentity),
+	}
+	return b.addEvent(event)
+}
+
+// AddStartChildWorkflowExecutionInitiatedEvent adds the event initiating a child workflow on the task list of the
+// workflow.
+func (b *Builder) AddStartChildWorkflowExecutionInitiatedEvent(
+	domain, workflowID, workflowType string,
+	input []byte,
+) int64 {
+	event := b.newEvent(shared.EventTypeStartChildWorkflowExecutionInitiated)
+	event.StartChildWorkflowExecutionInitiatedEventAttributes = &shared.StartChildWorkflowExecutionInitiatedEventAttributes{
+		Domain:                              common.StringPtr(domain),
+		WorkflowId:                          common.StringPtr(workflowID),
+		WorkflowType:                        &shared.WorkflowType{Name: common.StringPtr(workflowType)},
+		TaskList:                            b.taskList(),
+		Input:                               input,
+		ExecutionStartToCloseTimeoutSeconds: common.Int32Ptr(int32(b.options.ExecutionStartToCloseTimeout.Seconds())),
+		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(int32(b.options.TaskStartToCloseTimeout.Seconds())),
+		ParentClosePolicy:                   shared.ParentClosePolicyTerminate.Ptr(),
+		DecisionTaskCompletedEventId:        common.Int64Ptr(b.decisionCompletedID),
+		WorkflowIdReusePolicy:               shared.WorkflowIdReusePolicyAllowDuplicateFailedOnly.Ptr(),
+	}
+	return b.addEvent(event)
+}
+
+// AddChildWorkflowExecutionStartedEvent adds the event of the start of the child workflow initiated by the event
+// initiatedID, it returns the ID of the event and the run ID of the child workflow.
+func (b *Builder) AddChildWorkflowExecutionStartedEvent(initiatedID int64) (int64, string) {
+	initiated := b.childInitiatedAttributes(initiatedID)
+	runID := uuid.New()
+	event := b.newEvent(shared.EventTypeChildWorkflowExecutionStarted)
+	event.ChildWorkflowExecutionStartedEventAttributes = &shared.ChildWorkflowExecutionStartedEventAttributes{
+		Domain:            initiated.Domain,
+		InitiatedEventId:  common.Int64Ptr(initiatedID),
+		WorkflowExecution: &shared.WorkflowExecution{WorkflowId: initiated.WorkflowId, RunId: common.StringPtr(runID)},
+		WorkflowType:      initiated.WorkflowType,
+	}
+	return b.addEvent(event), runID
+}
+
+// AddChildWorkflowExecutionCompletedEvent adds the event of the completion of the child workflow initiated by the
+// event initiatedID and started by the event startedID.
+func (b *Builder) AddChildWorkflowExecutionCompletedEvent(initiatedID, startedID int64, result []byte) int64 {
+	initiated := b.childInitiatedAttributes(initiatedID)
+	started := b.events[startedID-1].ChildWorkflowExecutionStartedEventAttributes
+	event := b.newEvent(shared.EventTypeChildWorkflowExecutionCompleted)
+	event.ChildWorkflowExecutionCompletedEventAttributes = &shared.ChildWorkflowExecutionCompletedEventAttributes{
+		Result:            result,
+		Domain:            initiated.Domain,
+		WorkflowExecution: started.WorkflowExecution,
+		WorkflowType:      initiated.WorkflowType,
+		InitiatedEventId:  common.Int64Ptr(initiatedID),
+		StartedEventId:    common.Int64Ptr(startedID),
+	}
+	return b.addEvent(event)
+}
+
+func (b *Builder) childInitiatedAttributes(initiatedID int64) *shared.StartChildWorkflowExecutionInitiatedEventAttributes {
+	if initiatedID <= 0 || initiatedID > int64(len(b.events)) ||
+		b.events[initiatedID-1].StartChildWorkflowExecutionInitiatedEventAttributes == nil {
+		panic(fmt.Sprintf("historybuilder: event %v does not initiate a child workflow", initiatedID))
+	}
+	return b.events[initiatedID-1].StartChildWorkflowExecutionInitiatedEventAttributes
+}
+
+// AddWorkflowExecutionCompletedEvent adds the event completing the workflow.
+func (b *Builder) AddWorkflowExecutionCompletedEvent(result []byte) int64 {
+	event := b.newEvent(shared.EventTypeWorkflowExecutionCompleted)
+	event.WorkflowExecutionCompletedEventAttributes = &shared.WorkflowExecutionCompletedEventAttributes{
+		Result:                       result,
+		DecisionTaskCompletedEventId: common.Int64Ptr(b.decisionCompletedID),
+	}
+	return b.addEvent(event)
+}
+
+// AddWorkflowExecutionFailedEvent adds the event failing the workflow.
+func (b *Builder) AddWorkflowExecutionFailedEvent(reason string, details []byte) int64 {
+	event := b.newEvent(shared.EventTypeWorkflowExecutionFailed)
+	event.WorkflowExecutionFailedEventAttributes = &shared.WorkflowExecutionFailedEventAttributes{
+		Reason:                       common.StringPtr(reason),
+		Details:                      details,
+		DecisionTaskCompletedEventId: common.Int64Ptr(b.decisionCompletedID),
+	}
+	return b.addEvent(event)
+}
+
+// AddWorkflowExecutionCanceledEvent adds the event canceling the workflow.
+func (b *Builder) AddWorkflowExecutionCanceledEvent(details []byte) int64 {
+	event := b.newEvent(shared.EventTypeWorkflowExecutionCanceled)
+	event.WorkflowExecutionCanceledEventAttributes = &shared.WorkflowExecutionCanceledEventAttributes{
+		Details:                      details,
+		DecisionTaskCompletedEventId: common.Int64Ptr(b.decisionCompletedID),
+	}
+	return b.addEvent(event)
+}
+
+// AddEvent adds an event the builder has no method for, e.g. an upsert of search attributes. The ID, timestamp and
+// type of the event are set by the builder, its attributes are added as they are.
+func (b *Builder) AddEvent(eventType shared.EventType, event *shared.HistoryEvent) int64 {
+	newEvent := b.newEvent(eventType)
+	event.EventId = newEvent.EventId
+	event.Timestamp = newEvent.Timestamp
+	event.EventType = newEvent.EventType
+	return b.addEvent(event)
+}
+
+func (b *Builder) taskList() *shared.TaskList {
+	return &shared.TaskList{Name: common.StringPtr(b.options.TaskList), Kind: shared.TaskListKindNormal.Ptr()}
+}
+
+func (b *Builder) nextEventID() int64 {
+	return int64(len(b.events) + 1)
+}
+
+func (b *Builder) newEvent(eventType shared.EventType) *shared.HistoryEvent {
+	return &shared.HistoryEvent{
+		EventId:   common.Int64Ptr(b.nextEventID()),
+		Timestamp: common.Int64Ptr(b.now.UnixNano()),
+		EventType: eventType.Ptr(),
+	}
+}
+
+func (b *Builder) addEvent(event *shared.HistoryEvent) int64 {
+	b.events = append(b.events, event)
+	return event.GetEventId()
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package historybuilder_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/historybuilder"
+	"go.uber.org/cadence/worker"
+	"go.uber.org/cadence/workflow"
+)
+
+func sampleWorkflow(ctx workflow.Context) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+	})
+	if err := workflow.ExecuteActivity(ctx, "SampleActivity").Get(ctx, nil); err != nil {
+		return err
+	}
+	return workflow.Sleep(ctx, time.Hour)
+}
+
+func TestBuilder_Replay(t *testing.T) {
+	b := historybuilder.New(historybuilder.Options{WorkflowType: "sampleWorkflow"})
+	b.AddDecisionTaskScheduledEvent()
+	b.AddDecisionTaskStartedEvent()
+	b.AddDecisionTaskCompletedEvent()
+	scheduledID := b.AddActivityTaskScheduledEvent("0", "SampleActivity", nil)
+	startedID := b.AddActivityTaskStartedEvent(scheduledID)
+	b.AddActivityTaskCompletedEvent(scheduledID, startedID, nil)
+	b.AddDecisionTaskScheduledEvent()
+	b.AddDecisionTaskStartedEvent()
+	b.AddDecisionTaskCompletedEvent()
+	b.AddTimerStartedEvent("1", time.Hour)
+	b.AdvanceTime(time.Hour)
+	b.AddTimerFiredEvent("1")
+	b.AddDecisionTaskScheduledEvent()
+	b.AddDecisionTaskStartedEvent()
+	b.AddDecisionTaskCompletedEvent()
+	b.AddWorkflowExecutionCompletedEvent(nil)
+
+	replayer := worker.NewWorkflowReplayer()
+	replayer.RegisterWorkflowWithOptions(sampleWorkflow, workflow.RegisterOptions{Name: "sampleWorkflow"})
+	require.NoError(t, replayer.ReplayWorkflowHistory(zaptest.NewLogger(t), b.History()))
+}
+
+func TestBuilder_DecisionTask(t *testing.T) {
+	b := historybuilder.New(historybuilder.Options{
+		WorkflowType: "sampleWorkflow",
+		WorkflowID:   "workflow-id",
+		RunID:        "run-id",
+		TaskList:     "tasklist",
+	})
+	require.Panics(t, func() { b.DecisionTask() })
+
+	b.AddDecisionTaskScheduledEvent()
+	b.AddDecisionTaskStartedEvent()
+	b.AddDecisionTaskCompletedEvent()
+	scheduledID := b.AddActivityTaskScheduledEvent("0", "SampleActivity", nil)
+	require.Equal(t, int64(5), scheduledID)
+	b.AddActivityTaskTimedOutEvent(scheduledID, 0, shared.TimeoutTypeScheduleToStart)
+	b.AddDecisionTaskScheduledEvent()
+	b.AddDecisionTaskStartedEvent()
+	b.AddDecisionTaskFailedEvent(shared.DecisionTaskFailedCauseUnhandledDecision, nil)
+	b.AddDecisionTaskScheduledEvent()
+	startedID := b.AddDecisionTaskStartedEvent()
+
+	task := b.DecisionTask()
+	require.Equal(t, "workflow-id", task.WorkflowExecution.GetWorkflowId())
+	require.Equal(t, "run-id", task.WorkflowExecution.GetRunId())
+	require.Equal(t, "sampleWorkflow", task.WorkflowType.GetName())
+	require.Equal(t, int64(3), task.GetPreviousStartedEventId())
+	require.Equal(t, startedID, task.GetStartedEventId())
+	require.Equal(t, int64(1), task.GetAttempt())
+	require.Len(t, task.History.Events, int(startedID))
+	for i, event := range task.History.Events {
+		require.Equal(t, int64(i+1), event.GetEventId())
+	}
+	completed := task.History.Events[3].DecisionTaskCompletedEventAttributes
+	require.Equal(t, int64(2), completed.GetScheduledEventId())
+	require.Equal(t, int64(3), completed.GetStartedEventId())
+	require.Equal(t, int64(4), task.History.Events[4].ActivityTaskScheduledEventAttributes.GetDecisionTaskCompletedEventId())
+	require.Equal(t, "tasklist", task.History.Events[9].DecisionTaskScheduledEventAttributes.TaskList.GetName())
+	require.Equal(t, int64(1), task.History.Events[9].DecisionTaskScheduledEventAttributes.GetAttempt())
+
+	sticky := b.StickyDecisionTask()
+	require.Equal(t, int64(4), sticky.History.Events[0].GetEventId())
+	require.Equal(t, startedID, sticky.History.Events[len(sticky.History.Events)-1].GetEventId())
+
+	require.Panics(t, func() { b.AddTimerFiredEvent("unknown") })
+}
@@ -24,6 +24,7 @@ package internal
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -53,8 +54,12 @@ type (
 		StartedTimestamp   time.Time     // Time of activity start
 		Deadline           time.Time     // Time of activity timeout
 		Attempt            int32         // Attempt starts from 0, and increased by 1 for every retry if retry policy is specified.
+		HostID             string        // ID to pin activities to the executing worker, empty unless WorkerOptions.EnableHostPinnedActivities is set.
 	}
 
+	// ActivityCancelReason describes why the context of a running activity was cancelled.
+	ActivityCancelReason int
+
 	// RegisterActivityOptions consists of options for registering an activity
 	RegisterActivityOptions struct {
 		// When an activity is a function the name is an actual activity type name.
@@ -66,10 +71,21 @@ type (
 		// This option has no effect when explicit Name is provided.
 		EnableShortName               bool
 		DisableAlreadyRegisteredCheck bool
+		// Optional: Namespace of the activity, usually the service it belongs to. The activity type name is
+		// Namespace.Name, or Namespace.Method for each method of a structure, so that it doesn't depend on the Go
+		// package or type the activity is implemented in, and stays the same when the code is refactored or moved.
+		// EnableShortName is implied when Namespace is set.
+		Namespace string
 		// Automatically send heartbeats for this activity at an interval that is less than the HeartbeatTimeout.
 		// This option has no effect if the activity is executed with a HeartbeatTimeout of 0.
 		// Default: false
 		EnableAutoHeartbeat bool
+		// Marks the activity as idempotent, so that the result of a successful execution is cached by the worker
+		// and returned for later executions of the same activity type with the same input, e.g. retries or
+		// executions after a workflow reset, without running the activity again.
+		// This option has no effect unless WorkerOptions.ActivityResultCacheTTL is set.
+		// Default: false
+		Idempotent bool
 	}
 
 	// ActivityOptions stores all activity-specific parameters that will be stored inside of a context.
@@ -86,15 +102,16 @@ type (
 		ScheduleToCloseTimeout time.Duration
 
 		// ScheduleToStartTimeout - The queue timeout before the activity starts executed.
-		// Mandatory: No default.
+		// Mandatory unless ScheduleToCloseTimeout is set: The default value is ScheduleToCloseTimeout.
 		ScheduleToStartTimeout time.Duration
 
 		// StartToCloseTimeout - The timeout from the start of execution to end of it.
-		// Mandatory: No default.
+		// Mandatory unless ScheduleToCloseTimeout is set: The default value is ScheduleToCloseTimeout.
 		StartToCloseTimeout time.Duration
 
 		// HeartbeatTimeout - The periodic timeout while the activity is in execution. This is
 		// the max interval the server needs to hear at-least one ping from the activity.
+		// It cannot be longer than StartToCloseTimeout.
 		// Optional: Default zero, means no heart beating is needed.
 		HeartbeatTimeout time.Duration
 
@@ -117,6 +134,14 @@ type (
 		// Same apply to ScheduleToCloseTimeout. See more details about RetryPolicy on the doc for RetryPolicy.
 		// Optional: default is no retry
 		RetryPolicy *RetryPolicy
+
+		// NonRetriableErrors are errors which stop the retries of the activity, in addition to the
+		// RetryPolicy.NonRetriableErrorReasons. An error returned by the activity matches if it, or an error it wraps,
+		// has the same type as one of them, so changing the message of an error does not change whether it is retried.
+		// A *CustomError matches errors with the same reason, as the reason is part of its encoding.
+		// Only used together with RetryPolicy.
+		// Optional: default is none
+		NonRetriableErrors []error
 	}
 
 	// LocalActivityOptions stores local activity specific parameters that will be stored inside of a context.
@@ -190,6 +215,30 @@ func RegisterActivityWithOptions(activityFunc interface{}, opts RegisterActivity
 	registry.RegisterActivityWithOptions(activityFunc, opts)
 }
 
+const (
+	// ActivityCancelReasonNone means the activity context was not cancelled.
+	ActivityCancelReasonNone ActivityCancelReason = iota
+
+	// ActivityCancelReasonCancelRequested means the workflow requested cancellation of the activity,
+	// e.g. because the workflow was cancelled.
+	ActivityCancelReasonCancelRequested
+
+	// ActivityCancelReasonTimedOut means the activity timed out, either because its deadline was exceeded or
+	// because a heartbeat found the activity no longer exists on the server, e.g. after a heartbeat timeout.
+	ActivityCancelReasonTimedOut
+
+	// ActivityCancelReasonWorkflowCompleted means a heartbeat found the workflow of the activity already completed.
+	ActivityCancelReasonWorkflowCompleted
+
+	// ActivityCancelReasonDomainNotActive means a heartbeat found the domain is not active in this cluster,
+	// e.g. after a failover.
+	ActivityCancelReasonDomainNotActive
+
+	// ActivityCancelReasonWorkerStopped means the worker is shutting down and cancelled the activity after
+	// WorkerStopTimeout elapsed.
+	ActivityCancelReasonWorkerStopped
+)
+
 // GetActivityInfo returns information about currently executing activity.
 func GetActivityInfo(ctx context.Context) ActivityInfo {
 	env := getActivityEnv(ctx)
@@ -206,6 +255,7 @@ func GetActivityInfo(ctx context.Context) ActivityInfo {
 		Attempt:            env.attempt,
 		WorkflowType:       env.workflowType,
 		WorkflowDomain:     env.workflowDomain,
+		HostID:             env.hostID,
 	}
 }
 
@@ -235,10 +285,15 @@ func GetActivityLogger(ctx context.Context) *zap.Logger {
 	return env.logger
 }
 
-// GetActivityMetricsScope returns a metrics scope that can be used in activity
+// GetActivityMetricsScope returns a metrics scope that can be used in activity. The tags of the scopes returned
+// by its Tagged method also tag the metrics the worker emits for this activity task once the activity returns, but
+// not the other scopes of the activity.
 func GetActivityMetricsScope(ctx context.Context) tally.Scope {
 	env := getActivityEnv(ctx)
-	return env.metricsScope
+	if env.metricsTags == nil {
+		env.metricsTags = &activityMetricsTags{}
+	}
+	return &activityMetricsScope{Scope: env.metricsScope, metricsTags: env.metricsTags}
 }
 
 // GetWorkerStopChannel returns a read-only channel. The closure of this channel indicates the activity worker is stopping.
@@ -250,11 +305,53 @@ func GetWorkerStopChannel(ctx context.Context) <-chan struct{} {
 	return env.workerStopChannel
 }
 
+// GetActivityCancelReason returns the reason the activity context was cancelled, or ActivityCancelReasonNone if it
+// was not. Activity cleanup logic can use it after ctx.Done() is closed to tell a cancellation requested by the
+// workflow apart from a timeout or the worker shutting down.
+func GetActivityCancelReason(ctx context.Context) ActivityCancelReason {
+	env := getActivityEnv(ctx)
+	if invoker, ok := env.serviceInvoker.(*cadenceInvoker); ok {
+		if reason := invoker.getCancelReason(); reason != ActivityCancelReasonNone {
+			return reason
+		}
+	}
+
+	switch ctx.Err() {
+	case nil:
+		return ActivityCancelReasonNone
+	case context.DeadlineExceeded:
+		return ActivityCancelReasonTimedOut
+	}
+	select {
+	case <-env.workerStopChannel:
+		return ActivityCancelReasonWorkerStopped
+	default:
+		return ActivityCancelReasonNone
+	}
+}
+
+// String returns the name of the cancel reason.
+func (r ActivityCancelReason) String() string {
+	switch r {
+	case ActivityCancelReasonNone:
+		return "None"
+	case ActivityCancelReasonCancelRequested:
+		return "CancelRequested"
+	case ActivityCancelReasonTimedOut:
+		return "TimedOut"
+	case ActivityCancelReasonWorkflowCompleted:
+		return "WorkflowCompleted"
+	case ActivityCancelReasonDomainNotActive:
+		return "DomainNotActive"
+	case ActivityCancelReasonWorkerStopped:
+		return "WorkerStopped"
+	}
+	return fmt.Sprintf("ActivityCancelReason(%d)", int(r))
+}
+
 // RecordActivityHeartbeat sends heartbeat for the currently executing activity
 // If the activity is either cancelled (or) workflow/activity doesn't exist then we would cancel
-// the context with error context.Canceled.
-//  TODO: we don't have a way to distinguish between the two cases when context is cancelled because
-//  context doesn't support overriding value of ctx.Error.
+// the context with error context.Canceled. Use GetActivityCancelReason to distinguish between the cases.
 //  TODO: Implement automatic heartbeating with cancellation through ctx.
 // details - the details that you provided here can be seen in the workflow when it receives TimeoutError, you
 // can check error TimeoutType()/Details().
@@ -345,6 +442,7 @@ func WithActivityTask(
 			ID:    *task.WorkflowExecution.WorkflowId},
 		logger:             logger,
 		metricsScope:       scope,
+		metricsTags:        &activityMetricsTags{},
 		deadline:           deadline,
 		heartbeatTimeout:   heartbeatTimeout,
 		scheduledTimestamp: scheduled,
@@ -23,6 +23,7 @@ package internal
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -55,7 +56,7 @@ func (s *activityTestSuite) TearDownTest() {
 
 func (s *activityTestSuite) TestActivityHeartbeat() {
 	ctx, cancel := context.WithCancel(context.Background())
-	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{})
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, 0, make(chan struct{}), FeatureFlags{})
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{serviceInvoker: invoker})
 
 	s.service.EXPECT().RecordActivityTaskHeartbeat(gomock.Any(), gomock.Any(), callOptions()...).
@@ -66,7 +67,7 @@ func (s *activityTestSuite) TestActivityHeartbeat() {
 
 func (s *activityTestSuite) TestActivityHeartbeat_InternalError() {
 	ctx, cancel := context.WithCancel(context.Background())
-	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{})
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, 0, make(chan struct{}), FeatureFlags{})
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker,
 		logger:         getTestLogger(s.T())})
@@ -82,7 +83,7 @@ func (s *activityTestSuite) TestActivityHeartbeat_InternalError() {
 
 func (s *activityTestSuite) TestActivityHeartbeat_CancelRequested() {
 	ctx, cancel := context.WithCancel(context.Background())
-	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{})
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, 0, make(chan struct{}), FeatureFlags{})
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker,
 		logger:         getTestLogger(s.T())})
@@ -93,11 +94,12 @@ func (s *activityTestSuite) TestActivityHeartbeat_CancelRequested() {
 	RecordActivityHeartbeat(ctx, "testDetails")
 	<-ctx.Done()
 	require.Equal(s.T(), ctx.Err(), context.Canceled)
+	require.Equal(s.T(), ActivityCancelReasonCancelRequested, GetActivityCancelReason(ctx))
 }
 
 func (s *activityTestSuite) TestActivityHeartbeat_EntityNotExist() {
 	ctx, cancel := context.WithCancel(context.Background())
-	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, make(chan struct{}), FeatureFlags{})
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, 0, make(chan struct{}), FeatureFlags{})
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker,
 		logger:         getTestLogger(s.T())})
@@ -108,11 +110,32 @@ func (s *activityTestSuite) TestActivityHeartbeat_EntityNotExist() {
 	RecordActivityHeartbeat(ctx, "testDetails")
 	<-ctx.Done()
 	require.Equal(s.T(), ctx.Err(), context.Canceled)
+	require.Equal(s.T(), ActivityCancelReasonTimedOut, GetActivityCancelReason(ctx))
+}
+
+func (s *activityTestSuite) TestGetActivityCancelReason_NotHeartbeat() {
+	workerStopCh := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 1, 0, workerStopCh, FeatureFlags{})
+	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
+		serviceInvoker:    invoker,
+		workerStopChannel: workerStopCh,
+		logger:            getTestLogger(s.T())})
+	require.Equal(s.T(), ActivityCancelReasonNone, GetActivityCancelReason(ctx))
+
+	deadlineCtx, deadlineCancel := context.WithDeadline(ctx, time.Now())
+	defer deadlineCancel()
+	require.Equal(s.T(), ActivityCancelReasonTimedOut, GetActivityCancelReason(deadlineCtx))
+
+	close(workerStopCh)
+	require.Equal(s.T(), ActivityCancelReasonNone, GetActivityCancelReason(ctx))
+	cancel()
+	require.Equal(s.T(), ActivityCancelReasonWorkerStopped, GetActivityCancelReason(ctx))
 }
 
 func (s *activityTestSuite) TestActivityHeartbeat_SuppressContinousInvokes() {
 	ctx, cancel := context.WithCancel(context.Background())
-	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 2, make(chan struct{}), FeatureFlags{})
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 2, 0, make(chan struct{}), FeatureFlags{})
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker,
 		logger:         getTestLogger(s.T())})
@@ -127,7 +150,7 @@ func (s *activityTestSuite) TestActivityHeartbeat_SuppressContinousInvokes() {
 
 	// No HB timeout configured.
 	service2 := workflowservicetest.NewMockClient(s.mockCtrl)
-	invoker2 := newServiceInvoker([]byte("task-token"), "identity", service2, cancel, 0, make(chan struct{}), FeatureFlags{})
+	invoker2 := newServiceInvoker([]byte("task-token"), "identity", service2, cancel, 0, 0, make(chan struct{}), FeatureFlags{})
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker2,
 		logger:         getTestLogger(s.T())})
@@ -140,7 +163,7 @@ func (s *activityTestSuite) TestActivityHeartbeat_SuppressContinousInvokes() {
 	// simulate batch picks before expiry.
 	waitCh := make(chan struct{})
 	service3 := workflowservicetest.NewMockClient(s.mockCtrl)
-	invoker3 := newServiceInvoker([]byte("task-token"), "identity", service3, cancel, 2, make(chan struct{}), FeatureFlags{})
+	invoker3 := newServiceInvoker([]byte("task-token"), "identity", service3, cancel, 2, 0, make(chan struct{}), FeatureFlags{})
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker3,
 		logger:         getTestLogger(s.T())})
@@ -170,7 +193,7 @@ func (s *activityTestSuite) TestActivityHeartbeat_SuppressContinousInvokes() {
 	// simulate batch picks before expiry, with out any progress specified.
 	waitCh2 := make(chan struct{})
 	service4 := workflowservicetest.NewMockClient(s.mockCtrl)
-	invoker4 := newServiceInvoker([]byte("task-token"), "identity", service4, cancel, 2, make(chan struct{}), FeatureFlags{})
+	invoker4 := newServiceInvoker([]byte("task-token"), "identity", service4, cancel, 2, 0, make(chan struct{}), FeatureFlags{})
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
 		serviceInvoker: invoker4,
 		logger:         getTestLogger(s.T())})
@@ -191,10 +214,41 @@ func (s *activityTestSuite) TestActivityHeartbeat_SuppressContinousInvokes() {
 	invoker4.Close(false)
 }
 
+func (s *activityTestSuite) TestActivityHeartbeat_ThrottleInterval() {
+	ctx, cancel := context.WithCancel(context.Background())
+	hbThrottleInterval := getHeartbeatThrottleInterval(60, 0, 50*time.Millisecond)
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 60, hbThrottleInterval, make(chan struct{}), FeatureFlags{})
+	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{
+		serviceInvoker: invoker,
+		logger:         getTestLogger(s.T())})
+
+	waitCh := make(chan string, 2)
+	s.service.EXPECT().RecordActivityTaskHeartbeat(gomock.Any(), gomock.Any(), callOptions()...).
+		Return(&shared.RecordActivityTaskHeartbeatResponse{}, nil).
+		Do(func(ctx context.Context, request *shared.RecordActivityTaskHeartbeatRequest, opts ...yarpc.CallOption) {
+			var progress string
+			require.NoError(s.T(), newEncodedValues(request.Details, nil).Get(&progress))
+			waitCh <- progress
+		}).Times(2)
+
+	RecordActivityHeartbeat(ctx, "testDetails1")
+	RecordActivityHeartbeat(ctx, "testDetails2")
+	RecordActivityHeartbeat(ctx, "testDetails3")
+	s.Equal("testDetails1", <-waitCh)
+	// the batch ends at the capped interval instead of at 80% of the heartbeat timeout
+	select {
+	case progress := <-waitCh:
+		s.Equal("testDetails3", progress)
+	case <-time.After(5 * time.Second):
+		s.Fail("coalesced heartbeat was not sent")
+	}
+	invoker.Close(false)
+}
+
 func (s *activityTestSuite) TestActivityHeartbeat_WorkerStop() {
 	ctx, cancel := context.WithCancel(context.Background())
 	workerStopChannel := make(chan struct{})
-	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 5, workerStopChannel, FeatureFlags{})
+	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 5, 0, workerStopChannel, FeatureFlags{})
 	ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnvironment{serviceInvoker: invoker})
 
 	heartBeatDetail := "testDetails"
@@ -222,3 +276,24 @@ func (s *activityTestSuite) TestGetWorkerStopChannel() {
 	channel := GetWorkerStopChannel(ctx)
 	s.NotNil(channel)
 }
+
+func TestGetHeartbeatThrottleInterval(t *testing.T) {
+	for name, test := range map[string]struct {
+		heartbeatTimeoutInSec int32
+		defaultInterval       time.Duration
+		maxInterval           time.Duration
+		expected              time.Duration
+	}{
+		"heartbeat timeout":             {heartbeatTimeoutInSec: 10, expected: 8 * time.Second},
+		"no heartbeat timeout":          {expected: 8 * time.Minute},
+		"default interval":              {defaultInterval: time.Minute, expected: time.Minute},
+		"default interval with timeout": {heartbeatTimeoutInSec: 10, defaultInterval: time.Minute, expected: 8 * time.Second},
+		"capped heartbeat timeout":      {heartbeatTimeoutInSec: 100, maxInterval: time.Minute, expected: time.Minute},
+		"capped default interval":       {defaultInterval: time.Hour, maxInterval: time.Minute, expected: time.Minute},
+		"interval shorter than the max": {heartbeatTimeoutInSec: 10, maxInterval: time.Minute, expected: 8 * time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.expected, getHeartbeatThrottleInterval(test.heartbeatTimeoutInSec, test.defaultInterval, test.maxInterval))
+		})
+	}
+}
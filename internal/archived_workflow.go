@@ -0,0 +1,195 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+const (
+	keyWorkflowID = "WorkflowID"
+	keyRunID      = "RunID"
+
+	defaultArchivedWorkflowPageSize = 100
+)
+
+type (
+	// ArchivedWorkflowQuery is a typed query of archived workflow executions. Visibility archivers only support
+	// conjunctions of simple comparisons, so each field adds one "and" predicate to the query. Zero fields are not
+	// part of the query.
+	ArchivedWorkflowQuery struct {
+		// WorkflowID matches the archived executions of a workflow.
+		WorkflowID string
+		// RunID matches a single archived execution.
+		RunID string
+		// WorkflowType matches the archived executions of a workflow type.
+		WorkflowType string
+		// CloseStatus matches the archived executions with the close status, for example WorkflowStatusFailed.
+		// WorkflowStatusOpen can't be used as archived executions are closed, WorkflowStatusClosed and
+		// WorkflowStatusALL match all archived executions.
+		CloseStatus WorkflowStatus
+		// MinCloseTime and MaxCloseTime bound the close time range of the archived executions, both inclusive.
+		MinCloseTime time.Time
+		MaxCloseTime time.Time
+	}
+
+	// ListArchivedWorkflowRequest is the request to NewArchivedWorkflowIterator
+	ListArchivedWorkflowRequest struct {
+		// Query selects the archived workflow executions.
+		Query ArchivedWorkflowQuery
+		// PageSize is the maximum number of executions fetched per ListArchivedWorkflow call.
+		// Optional: defaulted to 100.
+		PageSize int32
+	}
+
+	// WorkflowExecutionIterator represents the interface for
+	// workflow execution iterator
+	WorkflowExecutionIterator interface {
+		// HasNext return whether this iterator has next value
+		HasNext() bool
+		// Next returns the next workflow execution and error
+		// The errors it can return:
+		//	- BadRequestError
+		//	- InternalServiceError
+		Next() (*s.WorkflowExecutionInfo, error)
+	}
+
+	// workflowExecutionIteratorImpl is the implementation of WorkflowExecutionIterator
+	workflowExecutionIteratorImpl struct {
+		// whether this iterator is initialized
+		initialized bool
+		// local cached executions and corresponding consuming index
+		nextIndex  int
+		executions []*s.WorkflowExecutionInfo
+		// token to get next page of executions
+		nexttoken []byte
+		// err when getting next page of executions
+		err error
+		// func which use a next token to get next page of executions
+		paginate func(nexttoken []byte) ([]*s.WorkflowExecutionInfo, []byte, error)
+	}
+)
+
+// Build returns the visibility archival query, or an error if the query is invalid.
+func (q ArchivedWorkflowQuery) Build() (string, error) {
+	if !q.MinCloseTime.IsZero() && !q.MaxCloseTime.IsZero() && q.MaxCloseTime.Before(q.MinCloseTime) {
+		return "", errors.New("invalid archived workflow query: MaxCloseTime is before MinCloseTime")
+	}
+
+	builder := &queryBuilderImpl{}
+	if q.WorkflowID != "" {
+		builder.appendPartialQuery(keyWorkflowID + " = " + quoteVisibilityQueryString(q.WorkflowID))
+	}
+	if q.RunID != "" {
+		builder.appendPartialQuery(keyRunID + " = " + quoteVisibilityQueryString(q.RunID))
+	}
+	if q.WorkflowType != "" {
+		builder.WorkflowTypes([]string{q.WorkflowType})
+	}
+	switch q.CloseStatus {
+	case "", WorkflowStatusClosed, WorkflowStatusALL:
+		// all archived executions are closed
+	case WorkflowStatusOpen:
+		return "", errors.New("invalid archived workflow query: archived workflows are never open")
+	default:
+		status, err := ToWorkflowStatus(string(q.CloseStatus))
+		if err != nil {
+			return "", fmt.Errorf("invalid archived workflow query: %v", err)
+		}
+		builder.WorkflowStatus([]WorkflowStatus{status})
+	}
+	maxCloseTime := q.MaxCloseTime
+	if maxCloseTime.IsZero() {
+		maxCloseTime = maxTimestamp
+	}
+	builder.CloseTime(q.MinCloseTime, maxCloseTime)
+
+	return builder.Build(), nil
+}
+
+// NewArchivedWorkflowIterator returns an iterator over the archived workflow executions matching request.Query.
+// The pages are fetched lazily with c.ListArchivedWorkflow, following the next page tokens. An invalid query is
+// returned as the error of the first Next call.
+func NewArchivedWorkflowIterator(ctx context.Context, c Client, request *ListArchivedWorkflowRequest) WorkflowExecutionIterator {
+	pageSize := request.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultArchivedWorkflowPageSize
+	}
+	query, queryErr := request.Query.Build()
+
+	paginate := func(nexttoken []byte) ([]*s.WorkflowExecutionInfo, []byte, error) {
+		if queryErr != nil {
+			return nil, nil, queryErr
+		}
+		response, err := c.ListArchivedWorkflow(ctx, &s.ListArchivedWorkflowExecutionsRequest{
+			PageSize:      common.Int32Ptr(pageSize),
+			NextPageToken: nexttoken,
+			Query:         common.StringPtr(query),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Executions, response.NextPageToken, nil
+	}
+
+	return &workflowExecutionIteratorImpl{
+		paginate: paginate,
+	}
+}
+
+func (iter *workflowExecutionIteratorImpl) HasNext() bool {
+	// skip empty pages, an archiver may return them while there are more executions to scan
+	for iter.nextIndex >= len(iter.executions) && iter.err == nil && (!iter.initialized || len(iter.nexttoken) != 0) {
+		iter.initialized = true
+		iter.nextIndex = 0
+		iter.executions, iter.nexttoken, iter.err = iter.paginate(iter.nexttoken)
+		if iter.err != nil {
+			iter.executions = nil
+			iter.nexttoken = nil
+		}
+	}
+
+	return iter.nextIndex < len(iter.executions) || iter.err != nil
+}
+
+func (iter *workflowExecutionIteratorImpl) Next() (*s.WorkflowExecutionInfo, error) {
+	if !iter.HasNext() {
+		panic("WorkflowExecutionIterator Next() called without checking HasNext()")
+	}
+
+	// we have cached executions
+	if iter.nextIndex < len(iter.executions) {
+		index := iter.nextIndex
+		iter.nextIndex++
+		return iter.executions[index], nil
+	}
+
+	// we have err, clear that iter.err and return err
+	err := iter.err
+	iter.err = nil
+	return nil, err
+}
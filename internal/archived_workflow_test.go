@@ -0,0 +1,162 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func TestArchivedWorkflowQuery(t *testing.T) {
+	maxCloseTime := time.Now()
+	minCloseTime := maxCloseTime.Add(-time.Hour)
+	testCases := []struct {
+		msg           string
+		query         ArchivedWorkflowQuery
+		expectedQuery string
+		expectErr     bool
+	}{
+		{
+			msg:           "empty query",
+			expectedQuery: "",
+		},
+		{
+			msg: "all fields",
+			query: ArchivedWorkflowQuery{
+				WorkflowID:   workflowID,
+				RunID:        runID,
+				WorkflowType: workflowType,
+				CloseStatus:  WorkflowStatusFailed,
+				MinCloseTime: minCloseTime,
+				MaxCloseTime: maxCloseTime,
+			},
+			expectedQuery: fmt.Sprintf(`(WorkflowID = "%v") and (RunID = "%v") and (WorkflowType = "%v") and (CloseStatus = "FAILED") and (CloseTime >= %v and CloseTime <= %v)`,
+				workflowID, runID, workflowType, minCloseTime.UnixNano(), maxCloseTime.UnixNano()),
+		},
+		{
+			msg:           "quoted IDs",
+			query:         ArchivedWorkflowQuery{WorkflowID: `id" or "1" = "1`, RunID: `run\`},
+			expectedQuery: `(WorkflowID = "id\" or \"1\" = \"1") and (RunID = "run\\")`,
+		},
+		{
+			msg:           "closed status and open time range",
+			query:         ArchivedWorkflowQuery{CloseStatus: WorkflowStatusClosed, MinCloseTime: minCloseTime},
+			expectedQuery: fmt.Sprintf(`(CloseTime >= %v)`, minCloseTime.UnixNano()),
+		},
+		{
+			msg:       "open status",
+			query:     ArchivedWorkflowQuery{CloseStatus: WorkflowStatusOpen},
+			expectErr: true,
+		},
+		{
+			msg:       "unknown status",
+			query:     ArchivedWorkflowQuery{CloseStatus: "unknown"},
+			expectErr: true,
+		},
+		{
+			msg:       "inverted time range",
+			query:     ArchivedWorkflowQuery{MinCloseTime: maxCloseTime, MaxCloseTime: minCloseTime},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.msg, func(t *testing.T) {
+			query, err := test.query.Build()
+			if test.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedQuery, query)
+		})
+	}
+}
+
+func TestArchivedWorkflowIterator(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	client := NewClient(service, domain, nil)
+
+	query := ArchivedWorkflowQuery{WorkflowType: workflowType}
+	queryString, err := query.Build()
+	require.NoError(t, err)
+	newRequest := func(token []byte) *shared.ListArchivedWorkflowExecutionsRequest {
+		return &shared.ListArchivedWorkflowExecutionsRequest{
+			Domain:        common.StringPtr(domain),
+			PageSize:      common.Int32Ptr(defaultArchivedWorkflowPageSize),
+			NextPageToken: token,
+			Query:         common.StringPtr(queryString),
+		}
+	}
+	execution := func(id string) *shared.WorkflowExecutionInfo {
+		return &shared.WorkflowExecutionInfo{Execution: &shared.WorkflowExecution{WorkflowId: common.StringPtr(id)}}
+	}
+
+	gomock.InOrder(
+		service.EXPECT().ListArchivedWorkflowExecutions(gomock.Any(), newRequest(nil), gomock.Any()).Return(&shared.ListArchivedWorkflowExecutionsResponse{
+			Executions:    []*shared.WorkflowExecutionInfo{execution("1"), execution("2")},
+			NextPageToken: []byte{1},
+		}, nil),
+		// empty pages are skipped
+		service.EXPECT().ListArchivedWorkflowExecutions(gomock.Any(), newRequest([]byte{1}), gomock.Any()).Return(&shared.ListArchivedWorkflowExecutionsResponse{
+			NextPageToken: []byte{2},
+		}, nil),
+		service.EXPECT().ListArchivedWorkflowExecutions(gomock.Any(), newRequest([]byte{2}), gomock.Any()).Return(&shared.ListArchivedWorkflowExecutionsResponse{
+			Executions: []*shared.WorkflowExecutionInfo{execution("3")},
+		}, nil),
+	)
+
+	var ids []string
+	iter := NewArchivedWorkflowIterator(context.Background(), client, &ListArchivedWorkflowRequest{Query: query})
+	for iter.HasNext() {
+		info, err := iter.Next()
+		require.NoError(t, err)
+		ids = append(ids, info.Execution.GetWorkflowId())
+	}
+	require.Equal(t, []string{"1", "2", "3"}, ids)
+
+	// errors are returned once and end the iteration
+	service.EXPECT().ListArchivedWorkflowExecutions(gomock.Any(), newRequest(nil), gomock.Any()).Return(nil, &shared.BadRequestError{})
+	iter = NewArchivedWorkflowIterator(context.Background(), client, &ListArchivedWorkflowRequest{Query: query})
+	require.True(t, iter.HasNext())
+	_, err = iter.Next()
+	var badRequestErr *shared.BadRequestError
+	require.True(t, errors.As(err, &badRequestErr))
+	require.False(t, iter.HasNext())
+
+	// invalid queries are not sent
+	iter = NewArchivedWorkflowIterator(context.Background(), client, &ListArchivedWorkflowRequest{Query: ArchivedWorkflowQuery{CloseStatus: WorkflowStatusOpen}})
+	require.True(t, iter.HasNext())
+	_, err = iter.Next()
+	require.Error(t, err)
+}
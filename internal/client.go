@@ -43,6 +43,10 @@ const (
 	// QueryTypeOpenSessions is the build in query type for Client.QueryWorkflow() call. Use this query type to get all open
 	// sessions in the workflow. The result will be a list of SessionInfo encoded in the EncodedValue.
 	QueryTypeOpenSessions string = "__open_sessions"
+
+	// QueryTypeWorkerMetadata is the build in query type for Client.QueryWorkflow() call. Use this query type to get the
+	// metadata of the worker answering the query. The result will be a WorkerMetadata encoded in the EncodedValue.
+	QueryTypeWorkerMetadata string = "__worker_metadata"
 )
 
 type (
@@ -58,7 +62,8 @@ type (
 		// The errors it can return:
 		//	- EntityNotExistsError, if domain does not exists
 		//	- BadRequestError
-		//	- WorkflowExecutionAlreadyStartedError
+		//	- WorkflowAlreadyStartedError, which wraps the WorkflowExecutionAlreadyStartedError and exposes the run ID
+		//	  and start time of the existing execution
 		//	- InternalServiceError
 		// The current timeout resolution implementation is in seconds and uses math.Ceil(d.Seconds()) as the duration. But is
 		// subjected to change in the future.
@@ -112,16 +117,25 @@ type (
 		// - workflow ID of the workflow.
 		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
 		// - signalName name to identify the signal.
+		// - ctx can set the request ID of the signal, see WithRequestID and WithRequestIDOutput.
 		// The errors it can return:
 		//	- EntityNotExistsError
 		//	- InternalServiceError
 		//	- WorkflowExecutionAlreadyCompletedError
 		SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error
 
+		// SignalWorkflows sends the signals of the requests, signaling up to concurrency workflows in parallel. The
+		// signals to the same workflow ID are sent one at a time in the order of the requests. Once a signal to a
+		// workflow failed, the following signals to it are not sent to preserve the order, and fail too.
+		// Returns the error of each request at its index, nil if the signal was sent.
+		// - concurrency is the maximum number of workflows signaled in parallel, values below 1 signal one at a time.
+		SignalWorkflows(ctx context.Context, requests []SignalRequest, concurrency int) []error
+
 		// SignalWithStartWorkflow sends a signal to a running workflow.
 		// If the workflow is not running or not found, it starts the workflow and then sends the signal in transaction.
 		// - workflowID, signalName, signalArg are same as SignalWorkflow's parameters
-		// - options, workflow, workflowArgs are same as StartWorkflow's parameters
+		// - options, workflow, workflowArgs are same as StartWorkflow's parameters, options.RequestID is the request ID
+		//   of the signal and of the start
 		// Note: options.WorkflowIDReusePolicy is default to WorkflowIDReusePolicyAllowDuplicate in this API;
 		// while in StartWorkflow/ExecuteWorkflow APIs it is default to WorkflowIdReusePolicyAllowDuplicateFailedOnly.
 		// The errors it can return:
@@ -134,6 +148,7 @@ type (
 		// CancelWorkflow cancels a workflow in execution
 		// - workflow ID of the workflow.
 		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
+		// - ctx can set the request ID of the cancellation request, see WithRequestID and WithRequestIDOutput.
 		// The errors it can return:
 		//	- EntityNotExistsError
 		//	- BadRequestError
@@ -172,6 +187,16 @@ type (
 		//		}
 		GetWorkflowHistory(ctx context.Context, workflowID string, runID string, isLongPoll bool, filterType s.HistoryEventFilterType) HistoryEventIterator
 
+		// GetWorkflowHistoryWithOptions gets history events of a particular workflow like GetWorkflowHistory.
+		// See GetWorkflowHistoryWithOptionsRequest for more information. With the default HistoryArchivalPolicyAllow
+		// the iterator transparently reads the history from archival once it has been removed from the server's
+		// primary storage.
+		// The errors it can return:
+		//	- EntityNotExistsError
+		//	- BadRequestError
+		//	- InternalServiceError
+		GetWorkflowHistoryWithOptions(ctx context.Context, request *GetWorkflowHistoryWithOptionsRequest) HistoryEventIterator
+
 		// CompleteActivity reports activity completed.
 		// activity Execute method can return acitivity.activity.ErrResultPending to
 		// indicate the activity is not completed when it's Execute method returns. In that case, this CompleteActivity() method
@@ -271,11 +296,28 @@ type (
 		//  - InternalServiceError
 		CountWorkflow(ctx context.Context, request *s.CountWorkflowExecutionsRequest) (*s.CountWorkflowExecutionsResponse, error)
 
+		// CountWorkflowWithOptions gets number of workflow executions based on query like CountWorkflow, optionally
+		// grouped by the values of a search attribute.
+		// See CountWorkflowWithOptionsRequest and CountWorkflowWithOptionsResponse for more information.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		CountWorkflowWithOptions(ctx context.Context, request *CountWorkflowWithOptionsRequest) (*CountWorkflowWithOptionsResponse, error)
+
 		// GetSearchAttributes returns valid search attributes keys and value types.
 		// The search attributes can be used in query of List/Scan/Count APIs. Adding new search attributes requires cadence server
 		// to update dynamic config ValidSearchAttributes.
 		GetSearchAttributes(ctx context.Context) (*s.GetSearchAttributesResponse, error)
 
+		// HealthCheck pings the cadence frontend once, without retries, to verify that it can be reached with the
+		// client configuration. It can be used to fail fast at startup and in readiness probes.
+		// The errors it can return:
+		//  - DNSResolutionError when the frontend host name can't be resolved
+		//  - ConnectError when the frontend can't be reached or doesn't answer before the ctx deadline
+		//  - AuthenticationError when the frontend rejects the client credentials
+		//  - other errors returned by the frontend
+		HealthCheck(ctx context.Context) error
+
 		// QueryWorkflow queries a given workflow execution and returns the query result synchronously. Parameter workflowID
 		// and queryType are required, other parameters are optional. The workflowID and runID (optional) identify the
 		// target workflow execution that this query will be send to. If runID is not specified (empty string), server will
@@ -296,6 +338,18 @@ type (
 		//  - QueryFailError
 		QueryWorkflow(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (Value, error)
 
+		// QueryWorkerMetadata queries a given workflow execution with QueryTypeWorkerMetadata and returns the metadata
+		// of the worker answering the query, e.g. to verify the version of the workers processing the workflow before a
+		// deploy. The query is answered by the worker caching the workflow or any worker polling its task list.
+		// - workflowID is required.
+		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		//  - QueryFailError
+		QueryWorkerMetadata(ctx context.Context, workflowID string, runID string) (*WorkerMetadata, error)
+
 		// QueryWorkflowWithOptions queries a given workflow execution and returns the query result synchronously.
 		// See QueryWorkflowWithOptionsRequest and QueryWorkflowWithOptionsResponse for more information.
 		// The errors it can return:
@@ -313,6 +367,15 @@ type (
 		//  - EntityNotExistError
 		ResetWorkflow(ctx context.Context, request *s.ResetWorkflowExecutionRequest) (*s.ResetWorkflowExecutionResponse, error)
 
+		// ResetWorkflowExecution resets a workflow execution to the reset point selected by options.ResetType
+		// and returns the new run. Unlike ResetWorkflow, the decision finish event to reset to is looked up from the
+		// history or auto-reset points of the workflow.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		ResetWorkflowExecution(ctx context.Context, options ResetOptions) (*WorkflowExecution, error)
+
 		// DescribeWorkflowExecution returns information about the specified workflow execution.
 		// The errors it can return:
 		//  - BadRequestError
@@ -348,6 +411,55 @@ type (
 		ContextPropagators []ContextPropagator
 		FeatureFlags       FeatureFlags
 		Authorization      auth.AuthorizationProvider
+		// WorkflowIDGenerator generates the workflow ID when StartWorkflowOptions.ID, or the workflowID passed to
+		// SignalWithStartWorkflow, is empty. Optional: defaulted to a random uuid.
+		WorkflowIDGenerator WorkflowIDGenerator
+		// ConnectionOptions configures the connection established by Connect. Optional: connects eagerly.
+		ConnectionOptions ConnectionOptions
+		// StartWorkflowDedup enables the deduplication of the StartWorkflow and ExecuteWorkflow calls of the client
+		// by their workflow ID and StartWorkflowOptions.RequestID. The calls are only deduplicated across retries when
+		// the caller sets the RequestID, the uuid it defaults to differs for every call.
+		// Optional: disabled by default.
+		StartWorkflowDedup *StartWorkflowDedupOptions
+	}
+
+	// StartWorkflowDedupOptions configures the in-process deduplication of the workflow starts of a client.
+	// A start with the same workflow ID and RequestID as a start which succeeded in the last TTL returns the
+	// remembered run without calling the server, and a WorkflowExecutionAlreadyStartedError caused by the run of
+	// a previous start with the same RequestID is returned as a success with the run ID of that run.
+	// Only a caller set StartWorkflowOptions.RequestID identifies the retries of a start, without it every call is
+	// a new start.
+	StartWorkflowDedupOptions struct {
+		// TTL - How long a started run is remembered.
+		// Mandatory: the deduplication is disabled if not positive.
+		TTL time.Duration
+
+		// CacheSize - The maximum number of started runs remembered.
+		// Optional: defaulted to 1000.
+		CacheSize int
+
+		// AttachToExistingRun - Also returns the existing run as a success for a WorkflowExecutionAlreadyStartedError
+		// caused by a start with a different RequestID, as long as that run is open. A closed run the
+		// WorkflowIDReusePolicy doesn't allow to reuse still fails the start. Unless the policy is
+		// WorkflowIDReusePolicyAllowDuplicate, which only rejects starts while a run is open, the existing run is
+		// described to tell whether it is open.
+		// Optional: defaulted to false, such a start returns the error.
+		AttachToExistingRun bool
+	}
+
+	// SignalRequest is a signal sent by SignalWorkflows.
+	SignalRequest struct {
+		// WorkflowID of the signaled workflow, required.
+		WorkflowID string
+		// RunID of the signaled workflow, the running execution of the workflow ID if empty.
+		RunID string
+		// SignalName identifies the signal, required.
+		SignalName string
+		// Arg is the input of the signal.
+		Arg interface{}
+		// RequestID deduplicates the signal like WithRequestID, a unique ID is generated if empty. The request ID
+		// set on the context of SignalWorkflows is not used.
+		RequestID string
 	}
 
 	// StartWorkflowOptions configuration parameters for starting a workflow execution.
@@ -358,6 +470,14 @@ type (
 		// Optional: defaulted to a uuid.
 		ID string
 
+		// RequestID - The idempotency key of the start request. The server does not start another run for a retried
+		// start with the same ID and RequestID while the first run is open, see also ClientOptions.StartWorkflowDedup.
+		// Set it to a key derived from the request, rather than leaving the default, for retries of a start to be
+		// deduplicated.
+		// SignalWithStartWorkflow also sends it as the request ID of the signal.
+		// Optional: defaulted to a uuid.
+		RequestID string
+
 		// TaskList - The decisions of the workflow are scheduled on this queue.
 		// This is also the default task list on which activities are scheduled. The workflow author can choose
 		// to override this using activity options.
@@ -437,10 +557,11 @@ type (
 		BackoffCoefficient float64
 
 		// Maximum backoff interval between retries. Exponential backoff leads to interval increase.
-		// This value is the cap of the interval. Default is 100x of initial interval.
+		// This value is the cap of the interval. Default is 100x of initial interval, must not be less than it.
 		MaximumInterval time.Duration
 
-		// Maximum time to retry. Either ExpirationInterval or MaximumAttempts is required.
+		// Maximum time to retry. Either ExpirationInterval or MaximumAttempts is required, except for the retry
+		// policy of a workflow, where it defaults to the ExecutionStartToCloseTimeout if neither is set.
 		// When exceeded the retries stop even if maximum retries is not reached yet.
 		ExpirationInterval time.Duration
 
@@ -450,6 +571,7 @@ type (
 		MaximumAttempts int32
 
 		// Non-Retriable errors. This is optional. Cadence server will stop retry if error reason matches this list.
+		// The reasons must not be empty.
 		// Error reason for custom error is specified when your activity/workflow return cadence.NewCustomError(reason).
 		// Error reason for panic error is "cadenceInternal:Panic".
 		// Error reason for any other error is "cadenceInternal:Generic".
@@ -486,6 +608,127 @@ type (
 		Update(ctx context.Context, request *s.UpdateDomainRequest) error
 	}
 
+	// ScheduleClient manages cron workflows as schedules. A schedule is identified by the workflow ID of its cron
+	// workflow. Cadence has no native schedule entity, so pausing terminates the running cron workflow and resuming or
+	// updating starts a new run with the input, headers, memo, search attributes and retry policy of the previous one.
+	ScheduleClient interface {
+		// CreateSchedule starts a cron workflow. options.ID is the schedule ID and options.CronSchedule is required.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - WorkflowExecutionAlreadyStartedError
+		//  - InternalServiceError
+		CreateSchedule(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (*WorkflowExecution, error)
+
+		// DescribeSchedule returns the cron schedule, state and next run time of a schedule.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - EntityNotExistError
+		//  - InternalServiceError
+		DescribeSchedule(ctx context.Context, scheduleID string) (*ScheduleDescription, error)
+
+		// UpdateSchedule replaces the cron schedule of a running schedule. The current run is terminated and a new
+		// run is started with the same arguments in one transaction. Updating a paused schedule returns an error.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - EntityNotExistError
+		//  - InternalServiceError
+		UpdateSchedule(ctx context.Context, scheduleID string, cronSchedule string) error
+
+		// PauseSchedule terminates the running cron workflow of a schedule so that no further runs are started.
+		// The errors it can return:
+		//  - EntityNotExistError
+		//  - InternalServiceError
+		PauseSchedule(ctx context.Context, scheduleID string, reason string) error
+
+		// ResumeSchedule restarts a paused schedule with the cron schedule and arguments it had when it was paused.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - EntityNotExistError
+		//  - InternalServiceError
+		ResumeSchedule(ctx context.Context, scheduleID string) error
+
+		// BackfillSchedule starts one non-cron run for every time the schedule would have fired in [start, end], with
+		// the fire times computed in UTC like the server does for cron workflows.
+		// Backfill runs use the workflow ID "<scheduleID>-backfill-<RFC3339 UTC fire time>", so backfilling the same
+		// range twice only restarts the fire times whose run failed. It returns the runs started by this call.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - EntityNotExistError
+		//  - InternalServiceError
+		BackfillSchedule(ctx context.Context, scheduleID string, start, end time.Time) ([]*WorkflowExecution, error)
+	}
+
+	// ScheduleDescription describes a schedule returned by ScheduleClient.DescribeSchedule.
+	ScheduleDescription struct {
+		ID           string
+		WorkflowType string
+		TaskList     string
+		CronSchedule string
+		// Paused is true when the latest run of the schedule was terminated by ScheduleClient.PauseSchedule.
+		Paused bool
+		// LatestRun is the current run of a running schedule, or the last run of a paused one.
+		LatestRun WorkflowExecution
+		// NextRunTime is the next time the schedule fires, in UTC. It is zero when the schedule is paused.
+		NextRunTime time.Time
+	}
+
+	// ResetOptions configures Client.ResetWorkflowExecution.
+	ResetOptions struct {
+		// WorkflowID of the execution to reset.
+		// Mandatory: No default.
+		WorkflowID string
+
+		// RunID of the execution to reset.
+		// Optional: defaulted to the current run of WorkflowID.
+		RunID string
+
+		// Reason is recorded in the history of the new run.
+		// Mandatory: No default.
+		Reason string
+
+		// ResetType selects the decision to reset to.
+		// Optional: defaulted to ResetTypeLastDecisionCompleted.
+		ResetType ResetType
+
+		// EventID is the ID of the DecisionTaskCompleted, DecisionTaskFailed or DecisionTaskTimedOut event to
+		// reset to. Mandatory with ResetTypeEventID, ignored otherwise.
+		EventID int64
+
+		// BadBinaryChecksum is the binary checksum of the worker deployment to roll back. Mandatory with
+		// ResetTypeBadBinary, ignored otherwise.
+		BadBinaryChecksum string
+
+		// ReapplyType selects the events of the reset run that are reapplied to the new run.
+		// Optional: defaulted to ResetReapplyTypeSignal.
+		ReapplyType ResetReapplyType
+	}
+
+	// ResetPoint is an auto-reset point of a workflow execution. The server records one for the first decision
+	// completed by every worker binary, identified by its binary checksum, so that a workflow can be reset to
+	// before the point a bad deployment started deciding for it.
+	ResetPoint struct {
+		// BinaryChecksum of the worker binary that completed the decision.
+		BinaryChecksum string
+		// RunID of the run the decision belongs to.
+		RunID string
+		// FirstDecisionCompletedID is the ID of the first DecisionTaskCompleted event of the binary. It is the
+		// event ID to reset to with ResetTypeEventID.
+		FirstDecisionCompletedID int64
+		// CreatedTime is when the reset point was recorded.
+		CreatedTime time.Time
+		// ExpiringTime is when the run the reset point belongs to is deleted, zero if the run is still open.
+		ExpiringTime time.Time
+		// Resettable is false when the workflow can't be reset to this point, for example because a decision
+		// after it started a child workflow.
+		Resettable bool
+	}
+
+	// ResetType selects the decision a workflow execution is reset to.
+	ResetType int
+
+	// ResetReapplyType selects the events reapplied after a reset.
+	ResetReapplyType int
+
 	// WorkflowIDReusePolicy defines workflow ID reuse behavior.
 	WorkflowIDReusePolicy int
 
@@ -493,6 +736,25 @@ type (
 	ParentClosePolicy int
 )
 
+const (
+	// ResetTypeLastDecisionCompleted resets to the last completed decision of the run.
+	ResetTypeLastDecisionCompleted ResetType = iota
+	// ResetTypeFirstDecisionCompleted resets to the first completed decision of the run.
+	ResetTypeFirstDecisionCompleted
+	// ResetTypeEventID resets to ResetOptions.EventID.
+	ResetTypeEventID
+	// ResetTypeBadBinary resets to the first decision completed by ResetOptions.BadBinaryChecksum, using the
+	// auto-reset points of the run. Everything the bad binary decided is dropped.
+	ResetTypeBadBinary
+)
+
+const (
+	// ResetReapplyTypeSignal reapplies the signals received after the reset point to the new run.
+	ResetReapplyTypeSignal ResetReapplyType = iota
+	// ResetReapplyTypeNone reapplies nothing, signals received after the reset point are dropped.
+	ResetReapplyTypeNone
+)
+
 const (
 	// ParentClosePolicyTerminate means terminating the child workflow
 	ParentClosePolicyTerminate ParentClosePolicy = iota
@@ -529,6 +791,26 @@ func getFeatureFlags(options *ClientOptions) FeatureFlags {
 	return FeatureFlags{}
 }
 
+const (
+	requestIDContextKey       contextKey = "requestID"
+	requestIDOutputContextKey contextKey = "requestIDOutput"
+)
+
+// WithRequestID returns a copy of ctx setting the request ID of the SignalWorkflow or CancelWorkflow call made with
+// it. The server deduplicates the signals and the cancellation requests of a workflow by their request ID, so that a
+// call made again with the same request ID, by a retry or by another system, has no effect. Optional: defaulted to a
+// uuid generated for the call, also used by the retries of the call by the client.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// WithRequestIDOutput returns a copy of ctx storing in requestID the request ID of the SignalWorkflow or
+// CancelWorkflow call made with it before the call is sent: the one set by WithRequestID, or else the generated one,
+// e.g. to make the call again after a failure.
+func WithRequestIDOutput(ctx context.Context, requestID *string) context.Context {
+	return context.WithValue(ctx, requestIDOutputContextKey, requestID)
+}
+
 // NewClient creates an instance of a workflow client
 func NewClient(service workflowserviceclient.Interface, domain string, options *ClientOptions) Client {
 	var identity string
@@ -562,20 +844,33 @@ func NewClient(service workflowserviceclient.Interface, domain string, options *
 	if options != nil && options.Authorization != nil {
 		service = auth.NewWorkflowServiceWrapper(service, options.Authorization)
 	}
+	var workflowIDGenerator WorkflowIDGenerator
+	var startWorkflowDedup *startWorkflowDedupCache
+	if options != nil {
+		workflowIDGenerator = options.WorkflowIDGenerator
+		startWorkflowDedup = newStartWorkflowDedupCache(options.StartWorkflowDedup)
+	}
 	service = metrics.NewWorkflowServiceWrapper(service, metricScope)
 	return &workflowClient{
-		workflowService:    service,
-		domain:             domain,
-		registry:           newRegistry(),
-		metricsScope:       metrics.NewTaggedScope(metricScope),
-		identity:           identity,
-		dataConverter:      dataConverter,
-		contextPropagators: contextPropagators,
-		tracer:             tracer,
-		featureFlags:       getFeatureFlags(options),
+		workflowService:     service,
+		domain:              domain,
+		registry:            newRegistry(),
+		metricsScope:        metrics.NewTaggedScope(metricScope),
+		identity:            identity,
+		dataConverter:       dataConverter,
+		contextPropagators:  contextPropagators,
+		tracer:              tracer,
+		featureFlags:        getFeatureFlags(options),
+		workflowIDGenerator: workflowIDGenerator,
+		startWorkflowDedup:  startWorkflowDedup,
 	}
 }
 
+// NewScheduleClient creates an instance of a schedule client, to manage cron workflows of a domain as schedules.
+func NewScheduleClient(service workflowserviceclient.Interface, domain string, options *ClientOptions) ScheduleClient {
+	return &scheduleClient{workflowClient: NewClient(service, domain, options).(*workflowClient)}
+}
+
 // NewDomainClient creates an instance of a domain client, to manager lifecycle of domains.
 func NewDomainClient(service workflowserviceclient.Interface, options *ClientOptions) DomainClient {
 	var identity string
@@ -45,10 +45,15 @@ type (
 
 // Throttle Sleep if there were failures since the last success call.
 func (c *ConcurrentRetrier) Throttle() {
-	c.throttleInternal()
+	c.throttleInternal(nil)
 }
 
-func (c *ConcurrentRetrier) throttleInternal() time.Duration {
+// ThrottleUntil sleeps like Throttle, but returns early once stopC is closed.
+func (c *ConcurrentRetrier) ThrottleUntil(stopC <-chan struct{}) {
+	c.throttleInternal(stopC)
+}
+
+func (c *ConcurrentRetrier) throttleInternal(stopC <-chan struct{}) time.Duration {
 	next := done
 
 	// Check if we have failure count.
@@ -59,7 +64,12 @@ func (c *ConcurrentRetrier) throttleInternal() time.Duration {
 	c.Unlock()
 
 	if next != done {
-		time.Sleep(next)
+		timer := time.NewTimer(next)
+		select {
+		case <-timer.C:
+		case <-stopC:
+			timer.Stop()
+		}
 	}
 
 	return next
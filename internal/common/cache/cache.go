@@ -25,27 +25,36 @@ import (
 )
 
 // A Cache is a generalized interface to a cache.  See cache.LRU for a specific
-// implementation (bounded cache with LRU eviction)
+// implementation (bounded cache with LRU eviction). Keys are compared with ==, e.g.
+// strings or structs of strings.
 type Cache interface {
 	// Exist checks if a given key exists in the cache
-	Exist(key string) bool
+	Exist(key interface{}) bool
 
 	// Get retrieves an element based on a key, returning nil if the element
 	// does not exist
-	Get(key string) interface{}
+	Get(key interface{}) interface{}
 
 	// Put adds an element to the cache, returning the previous element
-	Put(key string, value interface{}) interface{}
+	Put(key interface{}, value interface{}) interface{}
 
 	// PutIfNotExist puts a value associated with a given key if it does not exist
-	PutIfNotExist(key string, value interface{}) (interface{}, error)
+	PutIfNotExist(key interface{}, value interface{}) (interface{}, error)
 
 	// Delete deletes an element in the cache
-	Delete(key string)
+	Delete(key interface{})
 
 	// Release decrements the ref count of a pinned element. If the ref count
 	// drops to 0, the element can be evicted from the cache.
-	Release(key string)
+	Release(key interface{})
+
+	// UpdateSize records the approximate size in bytes of an element. If the cache
+	// has a MaxBytes budget, elements are evicted until the cache fits into it again.
+	UpdateSize(key interface{}, size int64)
+
+	// Protect exempts an element from eviction until it is deleted. It returns false
+	// if the element does not exist or the MaxProtected bound is reached.
+	Protect(key interface{}) bool
 
 	// Size returns the number of entries currently stored in the Cache
 	Size() int
@@ -57,17 +66,50 @@ type Options struct {
 	// are older than the TTL will not be returned
 	TTL time.Duration
 
+	// SlidingTTL renews the TTL of an entry every time it is accessed, so only
+	// entries that were not used for the TTL expire
+	SlidingTTL bool
+
+	// SweepInterval starts a background sweeper removing expired entries every
+	// interval, instead of only dropping them when they are looked up. The sweeper
+	// runs for the lifetime of the cache, so it is meant for long lived caches.
+	SweepInterval time.Duration
+
+	// Policy selects which entry is evicted when the cache is full
+	Policy Policy
+
 	// InitialCapacity controls the initial capacity of the cache
 	InitialCapacity int
 
 	// Pin prevents in-use objects from getting evicted
 	Pin bool
 
+	// MaxBytes bounds the total size of the elements reported by UpdateSize. When the
+	// budget is exceeded the largest elements are evicted first, and the least recently
+	// used one among elements of equal size. Zero means no budget.
+	MaxBytes int64
+
+	// MaxProtected bounds the number of elements exempted from eviction by Protect.
+	// At least two elements of a cache are always left evictable, so a full cache
+	// can still make room for new elements.
+	MaxProtected int
+
 	// RemovedFunc is an optional function called when an element
 	// is scheduled for deletion
 	RemovedFunc RemovedFunc
 }
 
+// Policy is the eviction policy of a cache that is full
+type Policy int
+
+const (
+	// PolicyLRU evicts the least recently used entry
+	PolicyLRU Policy = iota
+	// PolicyLFU evicts the least frequently used entry, and the least recently used one
+	// among entries used equally often
+	PolicyLFU
+)
+
 // RemovedFunc is a type for notifying applications when an item is
 // scheduled for removal from the Cache. If f is a function with the
 // appropriate signature and i is the interface{} scheduled for
@@ -36,11 +36,19 @@ var (
 type lru struct {
 	mut      sync.Mutex
 	byAccess *list.List
-	byKey    map[string]*list.Element
+	byKey    map[interface{}]*list.Element
 	maxSize  int
+	maxBytes int64
+	bytes    int64
 	ttl      time.Duration
+	sliding  bool
+	policy   Policy
 	pin      bool
 	rmFunc   RemovedFunc
+
+	// maxProtected and protected bound and count the elements exempted from eviction
+	maxProtected int
+	protected    int
 }
 
 // New creates a new cache with the given options
@@ -49,14 +57,23 @@ func New(maxSize int, opts *Options) Cache {
 		opts = &Options{}
 	}
 
-	return &lru{
+	c := &lru{
 		byAccess: list.New(),
-		byKey:    make(map[string]*list.Element, opts.InitialCapacity),
+		byKey:    make(map[interface{}]*list.Element, opts.InitialCapacity),
 		ttl:      opts.TTL,
+		sliding:  opts.SlidingTTL,
+		policy:   opts.Policy,
 		maxSize:  maxSize,
+		maxBytes: opts.MaxBytes,
 		pin:      opts.Pin,
 		rmFunc:   opts.RemovedFunc,
+
+		maxProtected: opts.MaxProtected,
 	}
+	if opts.TTL > 0 && opts.SweepInterval > 0 {
+		go c.sweep(opts.SweepInterval)
+	}
+	return c
 }
 
 // NewLRU creates a new LRU cache of the given size, setting initial capacity
@@ -74,7 +91,7 @@ func NewLRUWithInitialCapacity(initialCapacity, maxSize int) Cache {
 }
 
 // Exist checks if a given key exists in the cache
-func (c *lru) Exist(key string) bool {
+func (c *lru) Exist(key interface{}) bool {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 	_, ok := c.byKey[key]
@@ -82,7 +99,7 @@ func (c *lru) Exist(key string) bool {
 }
 
 // Get retrieves the value stored under the given key
-func (c *lru) Get(key string) interface{} {
+func (c *lru) Get(key interface{}) interface{} {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
@@ -97,22 +114,24 @@ func (c *lru) Get(key string) interface{} {
 		cacheEntry.refCount++
 	}
 
-	if cacheEntry.refCount == 0 && !cacheEntry.expiration.IsZero() && time.Now().After(cacheEntry.expiration) {
+	if cacheEntry.refCount == 0 && !cacheEntry.protected && !cacheEntry.expiration.IsZero() && time.Now().After(cacheEntry.expiration) {
 		// Entry has expired
 		if c.rmFunc != nil {
 			go c.rmFunc(cacheEntry.value)
 		}
 		c.byAccess.Remove(elt)
 		delete(c.byKey, cacheEntry.key)
+		c.bytes -= cacheEntry.size
 		return nil
 	}
 
+	c.touch(cacheEntry)
 	c.byAccess.MoveToFront(elt)
 	return cacheEntry.value
 }
 
 // Put puts a new value associated with a given key, returning the existing value (if present)
-func (c *lru) Put(key string, value interface{}) interface{} {
+func (c *lru) Put(key interface{}, value interface{}) interface{} {
 	if c.pin {
 		panic("Cannot use Put API in Pin mode. Use Delete and PutIfNotExist if necessary")
 	}
@@ -121,7 +140,7 @@ func (c *lru) Put(key string, value interface{}) interface{} {
 }
 
 // PutIfNotExist puts a value associated with a given key if it does not exist
-func (c *lru) PutIfNotExist(key string, value interface{}) (interface{}, error) {
+func (c *lru) PutIfNotExist(key interface{}, value interface{}) (interface{}, error) {
 	existing, err := c.putInternal(key, value, false)
 	if err != nil {
 		return nil, err
@@ -136,7 +155,7 @@ func (c *lru) PutIfNotExist(key string, value interface{}) (interface{}, error)
 }
 
 // Delete deletes a key, value pair associated with a key
-func (c *lru) Delete(key string) {
+func (c *lru) Delete(key interface{}) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
@@ -147,11 +166,37 @@ func (c *lru) Delete(key string) {
 			go c.rmFunc(entry.value)
 		}
 		delete(c.byKey, key)
+		c.bytes -= entry.size
+		if entry.protected {
+			c.protected--
+		}
+	}
+}
+
+// Protect exempts the element from eviction until it is deleted.
+func (c *lru) Protect(key interface{}) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	elt := c.byKey[key]
+	if elt == nil {
+		return false
+	}
+	entry := elt.Value.(*cacheEntry)
+	if entry.protected {
+		return true
+	}
+	// a full lru holds maxSize-1 elements and needs an evictable one besides the inserted element
+	if c.protected >= c.maxProtected || (c.maxSize > 0 && c.protected >= c.maxSize-2) {
+		return false
 	}
+	entry.protected = true
+	c.protected++
+	return true
 }
 
 // Release decrements the ref count of a pinned element.
-func (c *lru) Release(key string) {
+func (c *lru) Release(key interface{}) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
@@ -160,6 +205,47 @@ func (c *lru) Release(key string) {
 	cacheEntry.refCount--
 }
 
+// UpdateSize records the size of the element and evicts elements until the lru fits into its byte budget.
+// The element itself is never evicted by this call, so a single element larger than the budget stays cached.
+func (c *lru) UpdateSize(key interface{}, size int64) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	elt := c.byKey[key]
+	if elt == nil {
+		return
+	}
+	entry := elt.Value.(*cacheEntry)
+	c.bytes += size - entry.size
+	entry.size = size
+	if c.sliding && c.ttl != 0 {
+		entry.expiration = time.Now().Add(c.ttl)
+	}
+
+	for c.maxBytes > 0 && c.bytes > c.maxBytes {
+		// walk from the least recently used end so the oldest element wins ties on size
+		var victim *list.Element
+		for e := c.byAccess.Back(); e != nil; e = e.Prev() {
+			candidate := e.Value.(*cacheEntry)
+			if e == elt || candidate.refCount > 0 || candidate.protected || candidate.size == 0 {
+				continue
+			}
+			if victim == nil || candidate.size > victim.Value.(*cacheEntry).size {
+				victim = e
+			}
+		}
+		if victim == nil {
+			return
+		}
+		evicted := c.byAccess.Remove(victim).(*cacheEntry)
+		if c.rmFunc != nil {
+			go c.rmFunc(evicted.value)
+		}
+		delete(c.byKey, evicted.key)
+		c.bytes -= evicted.size
+	}
+}
+
 // Size returns the number of entries currently in the lru, useful if cache is not full
 func (c *lru) Size() int {
 	c.mut.Lock()
@@ -170,7 +256,7 @@ func (c *lru) Size() int {
 
 // Put puts a new value associated with a given key, returning the existing value (if present)
 // allowUpdate flag is used to control overwrite behavior if the value exists
-func (c *lru) putInternal(key string, value interface{}, allowUpdate bool) (interface{}, error) {
+func (c *lru) putInternal(key interface{}, value interface{}, allowUpdate bool) (interface{}, error) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
@@ -184,6 +270,7 @@ func (c *lru) putInternal(key string, value interface{}, allowUpdate bool) (inte
 		if c.ttl != 0 {
 			entry.expiration = time.Now().Add(c.ttl)
 		}
+		entry.hits++
 		c.byAccess.MoveToFront(elt)
 		if c.pin {
 			entry.refCount++
@@ -206,9 +293,9 @@ func (c *lru) putInternal(key string, value interface{}, allowUpdate bool) (inte
 
 	c.byKey[key] = c.byAccess.PushFront(entry)
 	if len(c.byKey) == c.maxSize {
-		oldest := c.byAccess.Back().Value.(*cacheEntry)
+		victim := c.victim()
 
-		if oldest.refCount > 0 {
+		if victim == nil {
 			// Cache is full with pinned elements
 			// revert the insert and return
 			c.byAccess.Remove(c.byAccess.Front())
@@ -216,19 +303,89 @@ func (c *lru) putInternal(key string, value interface{}, allowUpdate bool) (inte
 			return nil, ErrCacheFull
 		}
 
-		c.byAccess.Remove(c.byAccess.Back())
+		oldest := c.byAccess.Remove(victim).(*cacheEntry)
 		if c.rmFunc != nil {
 			go c.rmFunc(oldest.value)
 		}
 		delete(c.byKey, oldest.key)
+		c.bytes -= oldest.size
 	}
 
 	return nil, nil
 }
 
+// victim returns the element evicted from a full lru according to its policy, or nil if all elements are pinned.
+func (c *lru) victim() *list.Element {
+	if c.policy != PolicyLFU {
+		for e := c.byAccess.Back(); e != nil; e = e.Prev() {
+			candidate := e.Value.(*cacheEntry)
+			if candidate.protected {
+				continue
+			}
+			if candidate.refCount > 0 {
+				return nil
+			}
+			return e
+		}
+		return nil
+	}
+
+	// the newly inserted element at the front has no hits yet, it must not evict itself
+	var victim *list.Element
+	for e := c.byAccess.Back(); e != c.byAccess.Front(); e = e.Prev() {
+		candidate := e.Value.(*cacheEntry)
+		if candidate.refCount > 0 || candidate.protected {
+			continue
+		}
+		if victim == nil || candidate.hits < victim.Value.(*cacheEntry).hits {
+			victim = e
+		}
+	}
+	return victim
+}
+
+// touch records an access of the entry.
+func (c *lru) touch(entry *cacheEntry) {
+	entry.hits++
+	if c.sliding && c.ttl != 0 {
+		entry.expiration = time.Now().Add(c.ttl)
+	}
+}
+
+// sweep periodically removes the expired elements that are not pinned.
+func (c *lru) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.removeExpired(time.Now())
+	}
+}
+
+func (c *lru) removeExpired(now time.Time) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for e := c.byAccess.Back(); e != nil; {
+		prev := e.Prev()
+		entry := e.Value.(*cacheEntry)
+		if entry.refCount == 0 && !entry.protected && !entry.expiration.IsZero() && now.After(entry.expiration) {
+			c.byAccess.Remove(e)
+			if c.rmFunc != nil {
+				go c.rmFunc(entry.value)
+			}
+			delete(c.byKey, entry.key)
+			c.bytes -= entry.size
+		}
+		e = prev
+	}
+}
+
 type cacheEntry struct {
-	key        string
+	key        interface{}
 	expiration time.Time
 	value      interface{}
 	refCount   int
+	size       int64
+	hits       int64
+	protected  bool
 }
@@ -154,3 +154,152 @@ func TestRemovedFuncWithTTL(t *testing.T) {
 		t.Error("RemovedFunc did not send true on channel ch")
 	}
 }
+
+func TestLRUWithMaxBytes(t *testing.T) {
+	cache := New(10, &Options{
+		MaxBytes: 100,
+	})
+
+	cache.Put("A", "small")
+	cache.Put("B", "huge")
+	cache.Put("C", "small")
+	cache.Put("D", "small")
+	cache.UpdateSize("A", 10)
+	cache.UpdateSize("B", 60)
+	cache.UpdateSize("C", 10)
+	cache.UpdateSize("D", 10)
+	assert.Equal(t, 4, cache.Size())
+
+	// the largest execution is evicted instead of the small ones
+	cache.Put("E", "small")
+	cache.UpdateSize("E", 20)
+	assert.Equal(t, 4, cache.Size())
+	assert.Nil(t, cache.Get("B"))
+	assert.Equal(t, "small", cache.Get("A"))
+
+	// among elements of equal size the least recently used one is evicted, C is now the oldest
+	cache.Get("D")
+	cache.UpdateSize("E", 75)
+	assert.Nil(t, cache.Get("C"))
+	assert.Equal(t, "small", cache.Get("D"))
+	assert.Equal(t, 3, cache.Size())
+
+	// the updated element is never evicted by its own update
+	cache.UpdateSize("E", 200)
+	assert.Equal(t, 1, cache.Size())
+	assert.Equal(t, "small", cache.Get("E"))
+
+	cache.Delete("E")
+	cache.Put("F", "small")
+	cache.UpdateSize("F", 100)
+	assert.Equal(t, 1, cache.Size())
+}
+
+func TestLFU(t *testing.T) {
+	cache := New(4, &Options{
+		Policy: PolicyLFU,
+	})
+
+	cache.Put("A", "Foo")
+	cache.Put("B", "Bar")
+	cache.Put("C", "Cid")
+	cache.Get("A")
+	cache.Get("A")
+	cache.Get("C")
+
+	// B is used least often although A is the least recently inserted one
+	cache.Put("D", "Delt")
+	assert.Equal(t, 3, cache.Size())
+	assert.Nil(t, cache.Get("B"))
+	assert.Equal(t, "Foo", cache.Get("A"))
+
+	// C and D were used once, D is the least recently used one of them
+	cache.Get("C")
+	cache.Put("E", "Epsi")
+	assert.Nil(t, cache.Get("D"))
+	assert.Equal(t, "Cid", cache.Get("C"))
+	assert.Equal(t, "Epsi", cache.Get("E"))
+}
+
+func TestLRUWithSlidingTTLSweeper(t *testing.T) {
+	ch := make(chan interface{}, 2)
+	cache := New(5, &Options{
+		TTL:           time.Millisecond * 200,
+		SlidingTTL:    true,
+		SweepInterval: time.Millisecond * 20,
+		RemovedFunc: func(i interface{}) {
+			ch <- i
+		},
+	})
+
+	cache.Put("A", "foo")
+	cache.Put("B", "bar")
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond * 60)
+		assert.Equal(t, "foo", cache.Get("A"))
+	}
+
+	// B expired and was removed by the sweeper without being looked up, A was kept alive by the lookups
+	select {
+	case removed := <-ch:
+		assert.Equal(t, "bar", removed)
+	case <-time.After(time.Second):
+		t.Error("sweeper did not remove the expired entry")
+	}
+	assert.Equal(t, 1, cache.Size())
+	assert.False(t, cache.Exist("B"))
+}
+
+func TestLRUProtect(t *testing.T) {
+	cache := New(5, &Options{
+		MaxProtected: 2,
+		MaxBytes:     100,
+	})
+
+	cache.Put("A", "Foo")
+	cache.Put("B", "Bar")
+	cache.Put("C", "Cid")
+	assert.True(t, cache.Protect("A"))
+	assert.True(t, cache.Protect("A"))
+	assert.True(t, cache.Protect("B"))
+	assert.False(t, cache.Protect("C")) // MaxProtected reached
+	assert.False(t, cache.Protect("X"))
+
+	// A and B are the least recently used elements, but protected ones are skipped
+	cache.Put("D", "Delt")
+	cache.Put("E", "Epsi")
+	assert.Equal(t, 4, cache.Size())
+	assert.Nil(t, cache.Get("C"))
+	assert.Equal(t, "Foo", cache.Get("A"))
+	assert.Equal(t, "Bar", cache.Get("B"))
+
+	// protected elements are not evicted to meet the byte budget either
+	cache.UpdateSize("A", 60)
+	cache.UpdateSize("D", 30)
+	cache.UpdateSize("E", 30)
+	assert.Equal(t, 3, cache.Size())
+	assert.Nil(t, cache.Get("D"))
+	assert.Equal(t, "Foo", cache.Get("A"))
+
+	// deleting a protected element makes room for another one
+	cache.Delete("A")
+	assert.True(t, cache.Protect("E"))
+}
+
+func TestLRUProtectLeavesEvictableElements(t *testing.T) {
+	cache := New(4, &Options{
+		MaxProtected: 10,
+	})
+
+	cache.Put("A", "Foo")
+	cache.Put("B", "Bar")
+	cache.Put("C", "Cid")
+	assert.True(t, cache.Protect("A"))
+	assert.True(t, cache.Protect("B"))
+	assert.False(t, cache.Protect("C"))
+
+	_, err := cache.PutIfNotExist("D", "Delt")
+	assert.NoError(t, err)
+	assert.Nil(t, cache.Get("C"))
+	assert.Equal(t, "Delt", cache.Get("D"))
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally/v4"
+)
+
+type (
+	// Buffer holds the metrics recorded through the scopes returned by WrapBufferedScope until they are flushed or
+	// discarded. It is safe for concurrent use.
+	Buffer struct {
+		sync.Mutex
+		records []func()
+	}
+
+	bufferedScope struct {
+		buffer *Buffer
+		scope  tally.Scope
+		clock  Clock
+	}
+
+	bufferedCounter struct {
+		buffer  *Buffer
+		counter tally.Counter
+	}
+
+	bufferedGauge struct {
+		buffer *Buffer
+		gauge  tally.Gauge
+	}
+
+	bufferedTimer struct {
+		buffer *Buffer
+		timer  tally.Timer
+		clock  Clock
+	}
+
+	bufferedHistogram struct {
+		buffer    *Buffer
+		histogram tally.Histogram
+		clock     Clock
+	}
+
+	bufferedStopwatchRecorder struct {
+		recorder durationRecorder
+		clock    Clock
+	}
+)
+
+// NewBuffer creates an empty metrics Buffer
+func NewBuffer() *Buffer {
+	return &Buffer{}
+}
+
+// WrapBufferedScope wraps a scope like WrapScope: metrics are not recorded when isReplay is true. Metrics recorded
+// while not replaying are held in buffer until buffer.Flush is called.
+// This is designed to be used by workflowEnvironmentImpl so the metrics recorded by a decision task are only emitted
+// once the decision task is completed. When the decision task fails the buffer is discarded, the metrics are then
+// recorded again by the next attempt of the decision task, so each metric is emitted exactly once.
+func WrapBufferedScope(isReplay *bool, scope tally.Scope, clock Clock, buffer *Buffer) tally.Scope {
+	return WrapScope(isReplay, &bufferedScope{buffer, scope, clock}, clock)
+}
+
+// Flush records the buffered metrics to the wrapped scopes, in the order they were recorded.
+func (b *Buffer) Flush() {
+	b.Lock()
+	records := b.records
+	b.records = nil
+	b.Unlock()
+
+	for _, record := range records {
+		record()
+	}
+}
+
+// Discard drops the buffered metrics.
+func (b *Buffer) Discard() {
+	b.Lock()
+	defer b.Unlock()
+	b.records = nil
+}
+
+// Len returns the number of buffered metrics.
+func (b *Buffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+	return len(b.records)
+}
+
+func (b *Buffer) add(record func()) {
+	b.Lock()
+	defer b.Unlock()
+	b.records = append(b.records, record)
+}
+
+// Inc increments the counter by a delta.
+func (c *bufferedCounter) Inc(delta int64) {
+	c.buffer.add(func() { c.counter.Inc(delta) })
+}
+
+// Update sets the gauges absolute value.
+func (g *bufferedGauge) Update(value float64) {
+	g.buffer.add(func() { g.gauge.Update(value) })
+}
+
+// Record a specific duration.
+func (t *bufferedTimer) Record(value time.Duration) {
+	t.buffer.add(func() { t.timer.Record(value) })
+}
+
+// RecordDuration records a specific duration.
+func (t *bufferedTimer) RecordDuration(duration time.Duration) {
+	t.Record(duration)
+}
+
+// Start gives you back a specific point in time to report via Stop.
+func (t *bufferedTimer) Start() tally.Stopwatch {
+	return tally.NewStopwatch(t.clock.Now(), &bufferedStopwatchRecorder{t, t.clock})
+}
+
+// RecordValue records a specific value directly. Will use the configured value buckets for the histogram.
+func (h *bufferedHistogram) RecordValue(value float64) {
+	h.buffer.add(func() { h.histogram.RecordValue(value) })
+}
+
+// RecordDuration records a specific duration directly.
+// Will use the configured duration buckets for the histogram.
+func (h *bufferedHistogram) RecordDuration(value time.Duration) {
+	h.buffer.add(func() { h.histogram.RecordDuration(value) })
+}
+
+// Start gives you a specific point in time to then record a duration.
+// Will use the configured duration buckets for the histogram.
+func (h *bufferedHistogram) Start() tally.Stopwatch {
+	return tally.NewStopwatch(h.clock.Now(), &bufferedStopwatchRecorder{h, h.clock})
+}
+
+// RecordStopwatch is called when a stopwatch is stopped with Stop().
+func (r *bufferedStopwatchRecorder) RecordStopwatch(stopwatchStart time.Time) {
+	r.recorder.RecordDuration(r.clock.Now().Sub(stopwatchStart))
+}
+
+// Counter returns the Counter object corresponding to the name.
+func (s *bufferedScope) Counter(name string) tally.Counter {
+	return &bufferedCounter{s.buffer, s.scope.Counter(name)}
+}
+
+// Gauge returns the Gauge object corresponding to the name.
+func (s *bufferedScope) Gauge(name string) tally.Gauge {
+	return &bufferedGauge{s.buffer, s.scope.Gauge(name)}
+}
+
+// Timer returns the Timer object corresponding to the name.
+func (s *bufferedScope) Timer(name string) tally.Timer {
+	return &bufferedTimer{s.buffer, s.scope.Timer(name), s.clock}
+}
+
+// Histogram returns the Histogram object corresponding to the name.
+func (s *bufferedScope) Histogram(name string, buckets tally.Buckets) tally.Histogram {
+	return &bufferedHistogram{s.buffer, s.scope.Histogram(name, buckets), s.clock}
+}
+
+// Tagged returns a new child scope with the given tags and current tags.
+func (s *bufferedScope) Tagged(tags map[string]string) tally.Scope {
+	return &bufferedScope{s.buffer, s.scope.Tagged(tags), s.clock}
+}
+
+// SubScope returns a new child scope appending a further name prefix.
+func (s *bufferedScope) SubScope(name string) tally.Scope {
+	return &bufferedScope{s.buffer, s.scope.SubScope(name), s.clock}
+}
+
+// Capabilities returns a description of metrics reporting capabilities.
+func (s *bufferedScope) Capabilities() tally.Capabilities {
+	return s.scope.Capabilities()
+}
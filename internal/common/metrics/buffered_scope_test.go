@@ -0,0 +1,70 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally/v4"
+)
+
+func Test_BufferedScope(t *testing.T) {
+	t.Parallel()
+	isReplay := true
+	testScope := tally.NewTestScope("", nil)
+	buffer := NewBuffer()
+	scope := WrapBufferedScope(&isReplay, testScope, &realClock{}, buffer)
+	record := func() {
+		scope.Counter("counter").Inc(1)
+		scope.Tagged(map[string]string{"key": "value"}).Gauge("gauge").Update(2)
+		scope.SubScope("sub").Timer("timer").Record(time.Second)
+		scope.Timer("stopwatch").Start().Stop()
+		scope.Histogram("histogram", tally.DefaultBuckets).RecordValue(3)
+	}
+
+	// nothing is buffered while replaying
+	record()
+	require.Equal(t, 0, buffer.Len())
+
+	isReplay = false
+	record()
+	require.Equal(t, 5, buffer.Len())
+	snapshot := testScope.Snapshot()
+	require.Equal(t, int64(0), snapshot.Counters()["counter+"].Value())
+	require.Equal(t, 0, len(snapshot.Timers()["sub.timer+"].Values()))
+
+	// discarded metrics are never emitted
+	buffer.Discard()
+	require.Equal(t, 0, buffer.Len())
+
+	record()
+	buffer.Flush()
+	require.Equal(t, 0, buffer.Len())
+	buffer.Flush()
+	snapshot = testScope.Snapshot()
+	require.Equal(t, int64(1), snapshot.Counters()["counter+"].Value())
+	require.Equal(t, float64(2), snapshot.Gauges()["gauge+key=value"].Value())
+	require.Equal(t, []time.Duration{time.Second}, snapshot.Timers()["sub.timer+"].Values())
+	require.Equal(t, 1, len(snapshot.Timers()["stopwatch+"].Values()))
+	require.Equal(t, 1, len(snapshot.Histograms()))
+}
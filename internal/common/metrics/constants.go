@@ -43,6 +43,7 @@ const (
 	DecisionPollSucceedCounter         = CadenceMetricsPrefix + "decision-poll-succeed"
 	DecisionPollLatency                = CadenceMetricsPrefix + "decision-poll-latency" // measure succeed poll request latency
 	DecisionPollInvalidCounter         = CadenceMetricsPrefix + "decision-poll-invalid"
+	DecisionPollHedgedCounter          = CadenceMetricsPrefix + "decision-poll-hedged"
 	DecisionScheduledToStartLatency    = CadenceMetricsPrefix + "decision-scheduled-to-start-latency"
 	DecisionExecutionFailedCounter     = CadenceMetricsPrefix + "decision-execution-failed"
 	DecisionExecutionLatency           = CadenceMetricsPrefix + "decision-execution-latency"
@@ -51,6 +52,15 @@ const (
 	DecisionTaskPanicCounter           = CadenceMetricsPrefix + "decision-task-panic"
 	DecisionTaskCompletedCounter       = CadenceMetricsPrefix + "decision-task-completed"
 	DecisionTaskForceCompleted         = CadenceMetricsPrefix + "decision-task-force-completed"
+	DecisionTaskFailureThreshold       = CadenceMetricsPrefix + "decision-task-failure-threshold"
+	DecisionTaskFilteredCounter        = CadenceMetricsPrefix + "decision-task-filtered"
+	DecisionValidationFailed           = CadenceMetricsPrefix + "decision-validation-failed"
+	DecisionValidationLatency          = CadenceMetricsPrefix + "decision-validation-latency"
+	UnregisteredWorkflowTypeCounter    = CadenceMetricsPrefix + "unregistered-workflow-type"
+
+	ConsistentQueryAnsweredCounter = CadenceMetricsPrefix + "consistent-query-answered"
+	ConsistentQueryFailedCounter   = CadenceMetricsPrefix + "consistent-query-failed"
+	QueryHandlerTimeoutCounter     = CadenceMetricsPrefix + "query-handler-timeout"
 
 	ActivityPollCounter                         = CadenceMetricsPrefix + "activity-poll-total"
 	ActivityPollFailedCounter                   = CadenceMetricsPrefix + "activity-poll-failed"
@@ -71,6 +81,7 @@ const (
 	ActivityTaskCompletedByIDCounter            = CadenceMetricsPrefix + "activity-task-completed-by-id"
 	ActivityTaskFailedByIDCounter               = CadenceMetricsPrefix + "activity-task-failed-by-id"
 	ActivityTaskCanceledByIDCounter             = CadenceMetricsPrefix + "activity-task-canceled-by-id"
+	ActivityResultCacheHitCounter               = CadenceMetricsPrefix + "activity-result-cache-hit"
 	LocalActivityTotalCounter                   = CadenceMetricsPrefix + "local-activity-total"
 	LocalActivityTimeoutCounter                 = CadenceMetricsPrefix + "local-activity-timeout"
 	LocalActivityCanceledCounter                = CadenceMetricsPrefix + "local-activity-canceled"
@@ -83,6 +94,7 @@ const (
 	ActivityLocalDispatchFailedCounter          = CadenceMetricsPrefix + "activity-local-dispatch-failed"
 	ActivityLocalDispatchSucceedCounter         = CadenceMetricsPrefix + "activity-local-dispatch-succeed"
 	WorkerPanicCounter                          = CadenceMetricsPrefix + "worker-panic"
+	ActivityPollSuppressedCounter               = CadenceMetricsPrefix + "activity-poll-suppressed"
 
 	UnhandledSignalsCounter = CadenceMetricsPrefix + "unhandled-signals"
 	CorruptedSignalsCounter = CadenceMetricsPrefix + "corrupted-signals"
@@ -101,8 +113,26 @@ const (
 	StickyCacheStall = CadenceMetricsPrefix + "sticky-cache-stall"
 	StickyCacheSize  = CadenceMetricsPrefix + "sticky-cache-size"
 
+	StickyQueryHit      = CadenceMetricsPrefix + "sticky-query-hit"
+	StickyQueryMiss     = CadenceMetricsPrefix + "sticky-query-miss"
+	StickyQueryBypassed = CadenceMetricsPrefix + "sticky-query-bypassed"
+
+	StickyCacheWarmUp       = CadenceMetricsPrefix + "sticky-cache-warmup"
+	StickyCacheWarmUpFailed = CadenceMetricsPrefix + "sticky-cache-warmup-failed"
+
+	StickyCacheResetBacklog = CadenceMetricsPrefix + "sticky-cache-reset-backlog"
+	StickyCacheResetDropped = CadenceMetricsPrefix + "sticky-cache-reset-dropped"
+
 	NonDeterministicError = CadenceMetricsPrefix + "non-deterministic-error"
 
+	WorkflowHistorySize    = CadenceMetricsPrefix + "workflow-history-size"
+	WorkflowStateSize      = CadenceMetricsPrefix + "workflow-state-size"
+	WorkflowCoroutineCount = CadenceMetricsPrefix + "workflow-coroutines"
+
+	WorkflowSnapshotSaved    = CadenceMetricsPrefix + "workflow-snapshot-saved"
+	WorkflowSnapshotRestored = CadenceMetricsPrefix + "workflow-snapshot-restored"
+	WorkflowSnapshotFailed   = CadenceMetricsPrefix + "workflow-snapshot-failed"
+
 	ReplaySucceedCounter = CadenceMetricsPrefix + "replay-succeed"
 	ReplayFailedCounter  = CadenceMetricsPrefix + "replay-failed"
 	ReplaySkippedCounter = CadenceMetricsPrefix + "replay-skipped"
@@ -94,6 +94,31 @@ func Encode(obj ThriftObject) ([]byte, error) {
 	return writer.Bytes(), nil
 }
 
+// EncodedSize returns the length of the bytes returned by Encode for the object, without allocating them.
+func EncodedSize(obj ThriftObject) (int64, error) {
+	if obj == nil {
+		return 0, MsgPayloadNotThriftEncoded
+	}
+	val, err := obj.ToWire()
+	if err != nil {
+		return 0, err
+	}
+	var writer countingWriter
+	if err := protocol.Binary.Encode(val, &writer); err != nil {
+		return 0, err
+	}
+	// the first byte versions the serialization
+	return int64(writer) + 1, nil
+}
+
+// countingWriter discards the bytes written to it and only counts them
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
 // SerializeBatchEvents will serialize history event data to blob data
 func SerializeBatchEvents(events []*shared.HistoryEvent, encodingType shared.EncodingType) (*shared.DataBlob, error) {
 	return serialize(events, encodingType)
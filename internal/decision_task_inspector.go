@@ -0,0 +1,109 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+type (
+	// DecisionTaskInspector is called with the history and the decisions of every decision task completed by a
+	// worker, right before the decisions are sent to the server, see WorkerOptions.DecisionTaskInspector. It is meant
+	// for auditing tools, e.g. recording a hash of the decisions to detect workflows whose decisions drift between
+	// deployments. The history events and decisions must not be modified, and the inspector is called from the
+	// goroutines processing the decision tasks, so it must not block.
+	DecisionTaskInspector func(info DecisionTaskInfo)
+
+	// DecisionTaskInfo is a decision task completed by a worker, passed to a DecisionTaskInspector.
+	DecisionTaskInfo struct {
+		Domain            string
+		TaskList          string
+		WorkflowType      string
+		WorkflowExecution WorkflowExecution
+		// PreviousStartedEventID is the ID of the DecisionTaskStarted event of the previous decision task
+		// completed by the workflow execution, 0 for its first decision task.
+		PreviousStartedEventID int64
+		// StartedEventID is the ID of the DecisionTaskStarted event of the decision task.
+		StartedEventID int64
+		// History is the history fetched for the decision task: the full history of the workflow execution, or
+		// only the events after PreviousStartedEventID when the workflow execution was in the sticky cache.
+		History []*s.HistoryEvent
+		// Decisions are the decisions produced by the decision task.
+		Decisions []*s.Decision
+	}
+
+	// inspectedHistoryIterator records the history pages fetched while processing a decision task, for the
+	// DecisionTaskInspector.
+	inspectedHistoryIterator struct {
+		HistoryIterator
+		events []*s.HistoryEvent
+	}
+)
+
+func (it *inspectedHistoryIterator) GetNextPage() (*s.History, error) {
+	page, err := it.HistoryIterator.GetNextPage()
+	if err == nil && page != nil {
+		it.events = append(it.events, page.Events...)
+	}
+	return page, err
+}
+
+func (it *inspectedHistoryIterator) Reset() {
+	it.HistoryIterator.Reset()
+	it.events = nil
+}
+
+// startDecisionTaskInspection records the history of the decision task while it is processed, when the worker has
+// a DecisionTaskInspector.
+func (wtp *workflowTaskPoller) startDecisionTaskInspection(task *workflowTask) {
+	if wtp.decisionTaskInspector == nil {
+		return
+	}
+	task.inspectedHistory = &inspectedHistoryIterator{
+		HistoryIterator: task.historyIterator,
+		events:          task.task.History.GetEvents(),
+	}
+	if task.historyIterator != nil {
+		task.historyIterator = task.inspectedHistory
+	}
+}
+
+// inspectDecisionTask calls the DecisionTaskInspector of the worker for the completed decision tasks, failed decision
+// tasks and query results are not inspected.
+func (wtp *workflowTaskPoller) inspectDecisionTask(task *workflowTask, completedRequest interface{}) {
+	request, ok := completedRequest.(*s.RespondDecisionTaskCompletedRequest)
+	if !ok || wtp.decisionTaskInspector == nil || task.inspectedHistory == nil {
+		return
+	}
+	wtp.decisionTaskInspector(DecisionTaskInfo{
+		Domain:       wtp.domain,
+		TaskList:     wtp.taskListName,
+		WorkflowType: task.task.WorkflowType.GetName(),
+		WorkflowExecution: WorkflowExecution{
+			ID:    task.task.WorkflowExecution.GetWorkflowId(),
+			RunID: task.task.WorkflowExecution.GetRunId(),
+		},
+		PreviousStartedEventID: task.task.GetPreviousStartedEventId(),
+		StartedEventID:         task.task.GetStartedEventId(),
+		History:                task.inspectedHistory.events,
+		Decisions:              request.Decisions,
+	})
+}
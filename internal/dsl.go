@@ -0,0 +1,374 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// DSLWorkflow is a declarative workflow definition interpreted by ExecuteDSLWorkflow. Activities read their
+	// arguments from and store their results into string variables, initialized from Variables.
+	DSLWorkflow struct {
+		// Variables are the initial variables of the workflow.
+		Variables map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+		// ActivityOptions are the default options of the activities of the workflow, on top of the
+		// activity options of the workflow context.
+		ActivityOptions *DSLActivityOptions `json:"activityOptions,omitempty" yaml:"activityOptions,omitempty"`
+		// Root is the statement executed by the workflow.
+		Root DSLStatement `json:"root" yaml:"root"`
+	}
+
+	// DSLStatement is a node of a DSLWorkflow. Exactly one of its fields must be set.
+	DSLStatement struct {
+		Activity *DSLActivity `json:"activity,omitempty" yaml:"activity,omitempty"`
+		Sequence *DSLSequence `json:"sequence,omitempty" yaml:"sequence,omitempty"`
+		Parallel *DSLParallel `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+		Choice   *DSLChoice   `json:"choice,omitempty" yaml:"choice,omitempty"`
+		Retry    *DSLRetry    `json:"retry,omitempty" yaml:"retry,omitempty"`
+	}
+
+	// DSLActivity executes the activity Name with the values of the Arguments variables, and stores the string
+	// result of the activity into the Result variable when it is set.
+	DSLActivity struct {
+		Name      string              `json:"name" yaml:"name"`
+		Arguments []string            `json:"arguments,omitempty" yaml:"arguments,omitempty"`
+		Result    string              `json:"result,omitempty" yaml:"result,omitempty"`
+		Options   *DSLActivityOptions `json:"options,omitempty" yaml:"options,omitempty"`
+	}
+
+	// DSLActivityOptions override the options of the activities. Timeouts are durations parsed by
+	// time.ParseDuration, e.g. "1m30s". Empty fields keep the options of the workflow context.
+	DSLActivityOptions struct {
+		TaskList               string `json:"taskList,omitempty" yaml:"taskList,omitempty"`
+		ScheduleToStartTimeout string `json:"scheduleToStartTimeout,omitempty" yaml:"scheduleToStartTimeout,omitempty"`
+		StartToCloseTimeout    string `json:"startToCloseTimeout,omitempty" yaml:"startToCloseTimeout,omitempty"`
+		HeartbeatTimeout       string `json:"heartbeatTimeout,omitempty" yaml:"heartbeatTimeout,omitempty"`
+	}
+
+	// DSLSequence executes its elements one after another, and stops at the first failure.
+	DSLSequence struct {
+		Elements []*DSLStatement `json:"elements" yaml:"elements"`
+	}
+
+	// DSLParallel executes its branches concurrently. The first failure cancels the other branches.
+	DSLParallel struct {
+		Branches []*DSLStatement `json:"branches" yaml:"branches"`
+	}
+
+	// DSLChoice executes the body of the first case whose value equals the value of Variable, or Default when no
+	// case matches.
+	DSLChoice struct {
+		Variable string           `json:"variable" yaml:"variable"`
+		Cases    []*DSLChoiceCase `json:"cases" yaml:"cases"`
+		Default  *DSLStatement    `json:"default,omitempty" yaml:"default,omitempty"`
+	}
+
+	// DSLChoiceCase is a case of a DSLChoice.
+	DSLChoiceCase struct {
+		Value string        `json:"value" yaml:"value"`
+		Body  *DSLStatement `json:"body" yaml:"body"`
+	}
+
+	// DSLRetry executes Body again when it fails, waiting InitialInterval, which is required, before the first retry
+	// and BackoffCoefficient times longer before every next one, up to MaximumInterval. Intervals are durations parsed
+	// by time.ParseDuration. MaximumAttempts of 0 retries until the body succeeds or the workflow is canceled.
+	DSLRetry struct {
+		MaximumAttempts    int           `json:"maximumAttempts,omitempty" yaml:"maximumAttempts,omitempty"`
+		InitialInterval    string        `json:"initialInterval" yaml:"initialInterval"`
+		BackoffCoefficient float64       `json:"backoffCoefficient,omitempty" yaml:"backoffCoefficient,omitempty"`
+		MaximumInterval    string        `json:"maximumInterval,omitempty" yaml:"maximumInterval,omitempty"`
+		Body               *DSLStatement `json:"body" yaml:"body"`
+	}
+)
+
+// ParseDSLWorkflowJSON parses and validates a JSON workflow definition.
+func ParseDSLWorkflowJSON(data []byte) (*DSLWorkflow, error) {
+	var w DSLWorkflow
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&w); err != nil {
+		return nil, fmt.Errorf("unable to parse the workflow definition: %v", err)
+	}
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// ParseDSLWorkflowYAML parses and validates a YAML workflow definition.
+func ParseDSLWorkflowYAML(data []byte) (*DSLWorkflow, error) {
+	var w DSLWorkflow
+	if err := yaml.UnmarshalStrict(data, &w); err != nil {
+		return nil, fmt.Errorf("unable to parse the workflow definition: %v", err)
+	}
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Validate checks that the workflow definition can be interpreted.
+func (w *DSLWorkflow) Validate() error {
+	if w.ActivityOptions != nil {
+		if err := w.ActivityOptions.validate(); err != nil {
+			return err
+		}
+	}
+	return w.Root.validate("root")
+}
+
+// ExecuteDSLWorkflow interprets the workflow definition w and returns the variables once it is completed. It can
+// be registered as a workflow, so that the definition is the input of the workflow.
+func ExecuteDSLWorkflow(ctx Context, w DSLWorkflow) (map[string]string, error) {
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	variables := make(map[string]string, len(w.Variables))
+	for name, value := range w.Variables {
+		variables[name] = value
+	}
+	if w.ActivityOptions != nil {
+		ctx = w.ActivityOptions.apply(ctx)
+	}
+	if err := w.Root.execute(ctx, variables); err != nil {
+		return nil, err
+	}
+	return variables, nil
+}
+
+func (s *DSLStatement) validate(path string) error {
+	if s == nil {
+		return fmt.Errorf("dsl: missing statement at %v", path)
+	}
+	set := 0
+	for _, isSet := range []bool{s.Activity != nil, s.Sequence != nil, s.Parallel != nil, s.Choice != nil, s.Retry != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("dsl: statement at %v must have exactly one of activity, sequence, parallel, choice or retry", path)
+	}
+
+	switch {
+	case s.Activity != nil:
+		if s.Activity.Name == "" {
+			return fmt.Errorf("dsl: missing activity name at %v", path)
+		}
+		if s.Activity.Options != nil {
+			return s.Activity.Options.validate()
+		}
+	case s.Sequence != nil:
+		for i, element := range s.Sequence.Elements {
+			if err := element.validate(fmt.Sprintf("%v.sequence[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+	case s.Parallel != nil:
+		for i, branch := range s.Parallel.Branches {
+			if err := branch.validate(fmt.Sprintf("%v.parallel[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+	case s.Choice != nil:
+		if s.Choice.Variable == "" {
+			return fmt.Errorf("dsl: missing choice variable at %v", path)
+		}
+		for i, c := range s.Choice.Cases {
+			if c == nil {
+				return fmt.Errorf("dsl: missing choice case at %v.choice[%v]", path, i)
+			}
+			if err := c.Body.validate(fmt.Sprintf("%v.choice[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+		if s.Choice.Default != nil {
+			return s.Choice.Default.validate(path + ".choice.default")
+		}
+	case s.Retry != nil:
+		if s.Retry.MaximumAttempts < 0 {
+			return fmt.Errorf("dsl: negative retry maximumAttempts at %v", path)
+		}
+		if s.Retry.BackoffCoefficient != 0 && s.Retry.BackoffCoefficient < 1 {
+			return fmt.Errorf("dsl: retry backoffCoefficient less than 1 at %v", path)
+		}
+		if interval, err := parseDSLDuration(s.Retry.InitialInterval); err != nil {
+			return err
+		} else if interval == 0 {
+			// retrying without waiting would busy loop a body that keeps failing
+			return fmt.Errorf("dsl: missing retry initialInterval at %v", path)
+		}
+		if _, err := parseDSLDuration(s.Retry.MaximumInterval); err != nil {
+			return err
+		}
+		return s.Retry.Body.validate(path + ".retry")
+	}
+	return nil
+}
+
+func (s *DSLStatement) execute(ctx Context, variables map[string]string) error {
+	switch {
+	case s.Activity != nil:
+		return s.Activity.execute(ctx, variables)
+	case s.Sequence != nil:
+		for _, element := range s.Sequence.Elements {
+			if err := element.execute(ctx, variables); err != nil {
+				return err
+			}
+		}
+		return nil
+	case s.Parallel != nil:
+		return s.Parallel.execute(ctx, variables)
+	case s.Choice != nil:
+		value := variables[s.Choice.Variable]
+		for _, c := range s.Choice.Cases {
+			if c.Value == value {
+				return c.Body.execute(ctx, variables)
+			}
+		}
+		if s.Choice.Default != nil {
+			return s.Choice.Default.execute(ctx, variables)
+		}
+		return nil
+	case s.Retry != nil:
+		return s.Retry.execute(ctx, variables)
+	}
+	return errors.New("dsl: empty statement")
+}
+
+func (a *DSLActivity) execute(ctx Context, variables map[string]string) error {
+	args := make([]interface{}, 0, len(a.Arguments))
+	for _, name := range a.Arguments {
+		value, ok := variables[name]
+		if !ok {
+			return fmt.Errorf("dsl: undefined variable %v for activity %v", name, a.Name)
+		}
+		args = append(args, value)
+	}
+	if a.Options != nil {
+		ctx = a.Options.apply(ctx)
+	}
+
+	var result string
+	if err := ExecuteActivity(ctx, a.Name, args...).Get(ctx, &result); err != nil {
+		return err
+	}
+	if a.Result != "" {
+		variables[a.Result] = result
+	}
+	return nil
+}
+
+func (p *DSLParallel) execute(ctx Context, variables map[string]string) error {
+	childCtx, cancel := WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	selector := NewSelector(ctx)
+	for _, branch := range p.Branches {
+		branch := branch
+		future, settable := NewFuture(childCtx)
+		Go(childCtx, func(ctx Context) {
+			settable.Set(nil, branch.execute(ctx, variables))
+		})
+		selector.AddFuture(future, func(f Future) {
+			if err := f.Get(ctx, nil); err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+		})
+	}
+	for range p.Branches {
+		selector.Select(ctx)
+	}
+	return firstErr
+}
+
+func (r *DSLRetry) execute(ctx Context, variables map[string]string) error {
+	// the intervals are validated before the workflow is executed
+	interval, _ := parseDSLDuration(r.InitialInterval)
+	maximumInterval, _ := parseDSLDuration(r.MaximumInterval)
+	coefficient := r.BackoffCoefficient
+	if coefficient == 0 {
+		coefficient = 2
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.Body.execute(ctx, variables)
+		if err == nil || ctx.Err() != nil || (r.MaximumAttempts > 0 && attempt >= r.MaximumAttempts) {
+			return err
+		}
+		if err := Sleep(ctx, interval); err != nil {
+			return err
+		}
+		interval = time.Duration(float64(interval) * coefficient)
+		if maximumInterval > 0 && interval > maximumInterval {
+			interval = maximumInterval
+		}
+	}
+}
+
+func (o *DSLActivityOptions) validate() error {
+	for _, d := range []string{o.ScheduleToStartTimeout, o.StartToCloseTimeout, o.HeartbeatTimeout} {
+		if _, err := parseDSLDuration(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *DSLActivityOptions) apply(ctx Context) Context {
+	// the durations are validated before the workflow is executed
+	if o.TaskList != "" {
+		ctx = WithTaskList(ctx, o.TaskList)
+	}
+	if d, _ := parseDSLDuration(o.ScheduleToStartTimeout); d > 0 {
+		ctx = WithScheduleToStartTimeout(ctx, d)
+	}
+	if d, _ := parseDSLDuration(o.StartToCloseTimeout); d > 0 {
+		ctx = WithStartToCloseTimeout(ctx, d)
+	}
+	if d, _ := parseDSLDuration(o.HeartbeatTimeout); d > 0 {
+		ctx = WithHeartbeatTimeout(ctx, d)
+	}
+	return ctx
+}
+
+func parseDSLDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("dsl: invalid duration %q: %v", value, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("dsl: negative duration %q", value)
+	}
+	return d, nil
+}
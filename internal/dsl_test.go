@@ -0,0 +1,127 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDSLWorkflowYAML = `
+variables:
+  order: "order-1"
+activityOptions:
+  scheduleToStartTimeout: "1m"
+  startToCloseTimeout: "10m"
+root:
+  sequence:
+    elements:
+      - activity: {name: "ValidateOrder", arguments: ["order"], result: "region"}
+      - parallel:
+          branches:
+            - activity: {name: "Reserve", arguments: ["order"], result: "reservation"}
+            - retry:
+                maximumAttempts: 3
+                initialInterval: "1s"
+                body: {activity: {name: "Charge", arguments: ["order"], result: "payment"}}
+      - choice:
+          variable: "region"
+          cases:
+            - value: "eu"
+              body: {activity: {name: "ShipEU", arguments: ["order", "reservation"], result: "shipment"}}
+          default: {activity: {name: "Ship", arguments: ["order"], result: "shipment"}}
+`
+
+func newTestDSLWorkflowEnv(t *testing.T, chargeFailures int) *TestWorkflowEnvironment {
+	env := newTestWorkflowEnv(t)
+	env.RegisterWorkflow(ExecuteDSLWorkflow)
+	register := func(name string, fn interface{}) {
+		env.RegisterActivityWithOptions(fn, RegisterActivityOptions{Name: name})
+	}
+	register("ValidateOrder", func(ctx context.Context, order string) (string, error) { return "eu", nil })
+	register("Reserve", func(ctx context.Context, order string) (string, error) { return "reservation-" + order, nil })
+	register("Charge", func(ctx context.Context, order string) (string, error) {
+		if chargeFailures > 0 {
+			chargeFailures--
+			return "", errors.New("charge failed")
+		}
+		return "payment-" + order, nil
+	})
+	register("ShipEU", func(ctx context.Context, order, reservation string) (string, error) {
+		return "eu-" + reservation, nil
+	})
+	register("Ship", func(ctx context.Context, order string) (string, error) { return "ship-" + order, nil })
+	return env
+}
+
+func TestDSLWorkflow(t *testing.T) {
+	w, err := ParseDSLWorkflowYAML([]byte(testDSLWorkflowYAML))
+	require.NoError(t, err)
+
+	env := newTestDSLWorkflowEnv(t, 2)
+	env.ExecuteWorkflow(ExecuteDSLWorkflow, *w)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var variables map[string]string
+	require.NoError(t, env.GetWorkflowResult(&variables))
+	assert.Equal(t, map[string]string{
+		"order":       "order-1",
+		"region":      "eu",
+		"reservation": "reservation-order-1",
+		"payment":     "payment-order-1",
+		"shipment":    "eu-reservation-order-1",
+	}, variables)
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		env := newTestDSLWorkflowEnv(t, 3)
+		env.ExecuteWorkflow(ExecuteDSLWorkflow, *w)
+		require.True(t, env.IsWorkflowCompleted())
+		assert.Error(t, env.GetWorkflowError())
+	})
+}
+
+func TestDSLWorkflowParsing(t *testing.T) {
+	w, err := ParseDSLWorkflowJSON([]byte(`{"root": {"activity": {"name": "Ship", "arguments": ["order"]}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Ship", w.Root.Activity.Name)
+
+	for name, definition := range map[string]string{
+		"unknown field":     `{"root": {"task": {}}}`,
+		"empty statement":   `{"root": {}}`,
+		"two statements":    `{"root": {"activity": {"name": "A"}, "sequence": {"elements": []}}}`,
+		"missing name":      `{"root": {"sequence": {"elements": [{"activity": {}}]}}}`,
+		"invalid duration":  `{"root": {"retry": {"initialInterval": "soon", "body": {"activity": {"name": "A"}}}}}`,
+		"missing retry":     `{"root": {"retry": {"initialInterval": "1s"}}}`,
+		"missing variable":  `{"root": {"choice": {"cases": []}}}`,
+		"invalid timeouts":  `{"activityOptions": {"startToCloseTimeout": "-1s"}, "root": {"activity": {"name": "A"}}}`,
+		"invalid backoff":   `{"root": {"retry": {"initialInterval": "1s", "backoffCoefficient": 0.5, "body": {"activity": {"name": "A"}}}}}`,
+		"missing interval":  `{"root": {"retry": {"body": {"activity": {"name": "A"}}}}}`,
+		"zero interval":     `{"root": {"retry": {"initialInterval": "0s", "body": {"activity": {"name": "A"}}}}}`,
+		"missing case body": `{"root": {"choice": {"variable": "v", "cases": [{"value": "a"}]}}}`,
+	} {
+		_, err := ParseDSLWorkflowJSON([]byte(definition))
+		assert.Error(t, err, name)
+	}
+}
@@ -21,6 +21,7 @@
 package internal
 
 import (
+	"io"
 	"reflect"
 
 	"go.uber.org/cadence/internal/common"
@@ -62,6 +63,16 @@ type (
 		FromData(input []byte, valuePtr ...interface{}) error
 	}
 
+	// StreamingDataConverter is a DataConverter that can also encode values directly into a writer. It avoids
+	// holding a second copy of large arguments in memory when they are written to a file or a network stream.
+	// The default data converter implements it.
+	StreamingDataConverter interface {
+		DataConverter
+		// ToDataStream writes the conversion of a list of values to w. The written bytes are the same as
+		// the ones returned by ToData.
+		ToDataStream(w io.Writer, value ...interface{}) error
+	}
+
 	// defaultDataConverter uses thrift encoder/decoder when possible, for everything else use json.
 	defaultDataConverter struct{}
 )
@@ -95,6 +106,23 @@ func (dc *defaultDataConverter) ToData(r ...interface{}) ([]byte, error) {
 	return data, nil
 }
 
+func (dc *defaultDataConverter) ToDataStream(w io.Writer, r ...interface{}) error {
+	if len(r) == 1 && util.IsTypeByteSlice(reflect.TypeOf(r[0])) {
+		_, err := w.Write(r[0].([]byte))
+		return err
+	}
+
+	if common.IsUseThriftEncoding(r) {
+		data, err := thriftEncoding{}.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	return jsonEncoding{}.MarshalTo(w, r)
+}
+
 func (dc *defaultDataConverter) FromData(data []byte, to ...interface{}) error {
 	if len(to) == 1 && util.IsTypeByteSlice(reflect.TypeOf(to[0])) {
 		reflect.ValueOf(to[0]).Elem().SetBytes(data)
@@ -127,3 +127,33 @@ func TestDecodeArg(t *testing.T) {
 	require.NoError(t, err)
 	require.Error(t, decodeArg(dc, b, &r))
 }
+
+func TestDefaultDataConverterToDataStream(t *testing.T) {
+	t.Parallel()
+	dc, ok := getDefaultDataConverter().(StreamingDataConverter)
+	require.True(t, ok)
+
+	large := bytes.Repeat([]byte("a"), 2*maxPooledJSONBufferSize)
+	for _, args := range [][]interface{}{
+		{[]byte("test")},
+		{testErrorDetails1, testErrorDetails3},
+		{string(large), 1},
+		{},
+	} {
+		// run twice to exercise pooled buffers
+		for i := 0; i < 2; i++ {
+			data, err := dc.ToData(args...)
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, dc.ToDataStream(&buf, args...))
+			require.Equal(t, data, buf.Bytes())
+		}
+	}
+
+	// data returned by ToData must not be overwritten by later calls reusing the buffer
+	first, err := dc.ToData("first")
+	require.NoError(t, err)
+	_, err = dc.ToData("second")
+	require.NoError(t, err)
+	require.Equal(t, "\"first\"\n", string(first))
+}
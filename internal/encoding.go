@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sync"
 
 	"github.com/apache/thrift/lib/go/thrift"
 	"go.uber.org/cadence/internal/common"
@@ -41,25 +42,56 @@ type encoding interface {
 type jsonEncoding struct {
 }
 
+// maxPooledJSONBufferSize is the capacity above which an encoding buffer is not returned to the pool, so that a
+// few large payloads don't pin memory for the lifetime of the process.
+const maxPooledJSONBufferSize = 64 * 1024
+
+var jsonBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
 // Marshal encodes an array of object into bytes
 func (g jsonEncoding) Marshal(objs []interface{}) ([]byte, error) {
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
+	buf := jsonBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledJSONBufferSize {
+			buf.Reset()
+			jsonBufferPool.Put(buf)
+		}
+	}()
+
+	if err := g.MarshalTo(buf, objs); err != nil {
+		return nil, err
+	}
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	// the buffer is reused, so the result has to be copied out of it
+	data := make([]byte, buf.Len())
+	copy(data, buf.Bytes())
+	return data, nil
+}
+
+// MarshalTo encodes an array of object into w, without buffering the whole encoded array
+func (g jsonEncoding) MarshalTo(w io.Writer, objs []interface{}) error {
+	enc := json.NewEncoder(w)
 	for i, obj := range objs {
 		if err := enc.Encode(obj); err != nil {
 			if err == io.EOF {
-				return nil, fmt.Errorf("missing argument at index %d of type %T", i, obj)
+				return fmt.Errorf("missing argument at index %d of type %T", i, obj)
 			}
-			return nil, fmt.Errorf(
+			return fmt.Errorf(
 				"unable to encode argument: %d, %v, with json error: %v", i, reflect.TypeOf(obj), err)
 		}
 	}
-	return buf.Bytes(), nil
+	return nil
 }
 
 // Unmarshal decodes a byte array into the passed in objects
 func (g jsonEncoding) Unmarshal(data []byte, objs []interface{}) error {
-	dec := json.NewDecoder(bytes.NewBuffer(data))
+	dec := json.NewDecoder(bytes.NewReader(data))
 	dec.UseNumber()
 	for i, obj := range objs {
 		if err := dec.Decode(obj); err != nil {
@@ -97,10 +97,15 @@ type (
 		err string
 	}
 
+	// TimeoutType is the type of the timeout a TimeoutError was raised for.
+	TimeoutType = shared.TimeoutType
+
 	// TimeoutError returned when activity or child workflow timed out.
 	TimeoutError struct {
 		timeoutType shared.TimeoutType
 		details     Values
+		// the details of the last heartbeat recorded by a timed out activity.
+		heartbeat Values
 	}
 
 	// CanceledError returned when operation was canceled.
@@ -118,6 +123,16 @@ type (
 		stackTrace string
 	}
 
+	// PanicDetails are the details of a PanicError, retrieved with PanicError.Details or ErrorDetails.
+	PanicDetails struct {
+		// Value is the panic value formatted with %v.
+		Value string
+		// StackTrace is the stack trace of the panicking goroutine.
+		StackTrace string
+		// Location is the file:line the panic was raised at, empty if it can't be found in StackTrace.
+		Location string
+	}
+
 	// workflowPanicError contains information about panicked workflow.
 	// Used to distinguish go panic in the workflow code from a PanicError returned from a workflow function.
 	workflowPanicError struct {
@@ -132,9 +147,16 @@ type (
 		params *executeWorkflowParams
 	}
 
-	// UnknownExternalWorkflowExecutionError can be returned when external workflow doesn't exist
+	// UnknownExternalWorkflowExecutionError can be returned when external workflow doesn't exist, or has already
+	// completed, when it is signaled or its cancellation is requested.
 	UnknownExternalWorkflowExecutionError struct{}
 
+	// AggregateError is returned by AwaitAll when one or more of the awaited futures failed.
+	AggregateError struct {
+		errors []error
+		failed int
+	}
+
 	// ErrorDetailsValues is a type alias used hold error details objects.
 	ErrorDetailsValues []interface{}
 )
@@ -190,6 +212,16 @@ func NewHeartbeatTimeoutError(details ...interface{}) *TimeoutError {
 	return NewTimeoutError(shared.TimeoutTypeHeartbeat, details...)
 }
 
+// newActivityTimeoutError creates the TimeoutError of an activity timed out by the server, details being the ones of
+// the last heartbeat recorded by the activity.
+func newActivityTimeoutError(timeoutType shared.TimeoutType, details Values) *TimeoutError {
+	err := &TimeoutError{timeoutType: timeoutType, details: details}
+	if details != nil && details.HasValues() {
+		err.heartbeat = details
+	}
+	return err
+}
+
 // NewCanceledError creates CanceledError instance
 func NewCanceledError(details ...interface{}) *CanceledError {
 	if len(details) == 1 {
@@ -235,16 +267,18 @@ func NewContinueAsNewError(ctx Context, wfn interface{}, args ...interface{}) *C
 	if options.executionStartToCloseTimeoutSeconds == nil || *options.executionStartToCloseTimeoutSeconds <= 0 {
 		panic("invalid executionStartToCloseTimeoutSeconds provided")
 	}
-	if options.taskStartToCloseTimeoutSeconds == nil || *options.taskStartToCloseTimeoutSeconds <= 0 {
-		panic("invalid taskStartToCloseTimeoutSeconds provided")
-	}
-
 	params := &executeWorkflowParams{
 		workflowOptions: *options,
 		workflowType:    workflowType,
 		input:           input,
 		header:          getWorkflowHeader(ctx, options.contextPropagators),
 	}
+	if timeout := getRegisteredDecisionTaskTimeout(options, env.GetRegistry(), workflowType.Name); timeout != nil {
+		params.taskStartToCloseTimeoutSeconds = timeout
+	}
+	if params.taskStartToCloseTimeoutSeconds == nil || *params.taskStartToCloseTimeoutSeconds <= 0 {
+		panic("invalid taskStartToCloseTimeoutSeconds provided")
+	}
 	return &ContinueAsNewError{wfn: wfn, args: args, params: params}
 }
 
@@ -299,6 +333,22 @@ func (e *TimeoutError) Details(d ...interface{}) error {
 	return e.details.Get(d...)
 }
 
+// HasLastHeartbeatDetails return if the activity that timed out recorded heartbeat details before timing out,
+// whatever the timeout type is.
+func (e *TimeoutError) HasLastHeartbeatDetails() bool {
+	return e.heartbeat != nil
+}
+
+// LastHeartbeatDetails extracts the details of the last heartbeat recorded by the activity before timing out. They can
+// be used to resume the progress of the activity, e.g. when retrying it after a heartbeat timeout. If the activity did
+// not record heartbeat details, or err is not the timeout of an activity, it will return ErrNoData.
+func (e *TimeoutError) LastHeartbeatDetails(d ...interface{}) error {
+	if !e.HasLastHeartbeatDetails() {
+		return ErrNoData
+	}
+	return e.heartbeat.Get(d...)
+}
+
 // Error from error interface
 func (e *CanceledError) Error() string {
 	return "CanceledError"
@@ -335,6 +385,57 @@ func (e *PanicError) StackTrace() string {
 	return e.stackTrace
 }
 
+// Location return the file:line the panic was raised at, or empty string if it can't be found in the stack trace.
+func (e *PanicError) Location() string {
+	return getPanicLocation(e.stackTrace)
+}
+
+// HasDetails return if this error has strong typed detail data. It is always true for PanicError.
+func (e *PanicError) HasDetails() bool {
+	return true
+}
+
+// Details extracts the PanicDetails of the panic into a *PanicDetails.
+func (e *PanicError) Details(d ...interface{}) error {
+	return ErrorDetailsValues{PanicDetails{
+		Value:      e.Error(),
+		StackTrace: e.stackTrace,
+		Location:   e.Location(),
+	}}.Get(d...)
+}
+
+// ErrorDetails extracts the strong typed details of err, or of the first error in its chain that has details, into
+// valuePtr. It supports CustomError, CanceledError, TimeoutError and PanicError, whose details are a PanicDetails.
+// It returns ErrNoData if err has no details.
+func ErrorDetails(err error, valuePtr ...interface{}) error {
+	var detailsErr interface {
+		HasDetails() bool
+		Details(d ...interface{}) error
+	}
+	if !errors.As(err, &detailsErr) || !detailsErr.HasDetails() {
+		return ErrNoData
+	}
+	return detailsErr.Details(valuePtr...)
+}
+
+// getPanicLocation returns the file:line of the first frame in stackTrace outside of the go runtime.
+func getPanicLocation(stackTrace string) string {
+	for _, line := range strings.Split(stackTrace, "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		location := strings.TrimPrefix(line, "\t")
+		if i := strings.LastIndex(location, " +0x"); i >= 0 {
+			location = location[:i]
+		}
+		if strings.Contains(location, "/src/runtime/") {
+			continue
+		}
+		return location
+	}
+	return ""
+}
+
 // Error from error interface
 func (e *workflowPanicError) Error() string {
 	return fmt.Sprintf("%v", e.value)
@@ -380,6 +481,35 @@ func (e *UnknownExternalWorkflowExecutionError) Error() string {
 	return "UnknownExternalWorkflowExecution"
 }
 
+func newAggregateError(errs []error) *AggregateError {
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return &AggregateError{errors: errs, failed: failed}
+}
+
+// Error from error interface
+func (e *AggregateError) Error() string {
+	for _, err := range e.errors {
+		if err != nil {
+			return fmt.Sprintf("%d of %d futures failed, first error: %v", e.failed, len(e.errors), err)
+		}
+	}
+	return fmt.Sprintf("%d of %d futures failed", e.failed, len(e.errors))
+}
+
+// Errors returns the error of every awaited future in the order the futures were passed.
+// The entry is nil for futures that completed successfully.
+func (e *AggregateError) Errors() []error {
+	return e.errors
+}
+
 // HasValues return whether there are values.
 func (b ErrorDetailsValues) HasValues() bool {
 	return b != nil && len(b) != 0
@@ -0,0 +1,84 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/yarpc/yarpcerrors"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+// The errors of the Cadence server are converted to the generated Thrift types by both the Thrift and the gRPC
+// transports. The classifications below also match the raw yarpc status errors the gRPC transport returns when the
+// server did not attach the error details, and every error wrapping one of those.
+
+// IsServiceBusy tells whether the server rejected the request because it is overloaded or the rate limit of the
+// domain is exceeded. The request can be retried after a delay.
+func IsServiceBusy(err error) bool {
+	if target := (*s.ServiceBusyError)(nil); errors.As(err, &target) {
+		return true
+	}
+	return hasStatusCode(err, yarpcerrors.CodeResourceExhausted)
+}
+
+// IsEntityNotExists tells whether the domain, workflow execution or other entity targeted by the request does not
+// exist.
+func IsEntityNotExists(err error) bool {
+	if target := (*s.EntityNotExistsError)(nil); errors.As(err, &target) {
+		return true
+	}
+	return hasStatusCode(err, yarpcerrors.CodeNotFound)
+}
+
+// IsWorkflowAlreadyCompleted tells whether the request targeted a workflow execution that is already closed, e.g. a
+// signal sent to a completed workflow.
+func IsWorkflowAlreadyCompleted(err error) bool {
+	target := (*s.WorkflowExecutionAlreadyCompletedError)(nil)
+	return errors.As(err, &target)
+}
+
+// IsTimeout tells whether err is a TimeoutError of one of timeoutTypes. Without timeoutTypes it matches the
+// TimeoutError of any timeout type, and also the deadline of the context or of the request being exceeded.
+func IsTimeout(err error, timeoutTypes ...s.TimeoutType) bool {
+	if target := (*TimeoutError)(nil); errors.As(err, &target) {
+		if len(timeoutTypes) == 0 {
+			return true
+		}
+		for _, timeoutType := range timeoutTypes {
+			if target.TimeoutType() == timeoutType {
+				return true
+			}
+		}
+		return false
+	}
+	if len(timeoutTypes) > 0 {
+		return false
+	}
+	return errors.Is(err, context.DeadlineExceeded) || hasStatusCode(err, yarpcerrors.CodeDeadlineExceeded)
+}
+
+func hasStatusCode(err error, code yarpcerrors.Code) bool {
+	var status *yarpcerrors.Status
+	return errors.As(err, &status) && status.Code() == code
+}
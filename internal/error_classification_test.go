@@ -0,0 +1,72 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcerrors"
+
+	"go.uber.org/cadence/.gen/go/shared"
+)
+
+func Test_IsServiceBusy(t *testing.T) {
+	require.True(t, IsServiceBusy(&shared.ServiceBusyError{}))
+	require.True(t, IsServiceBusy(fmt.Errorf("poll failed: %w", &shared.ServiceBusyError{})))
+	require.True(t, IsServiceBusy(yarpcerrors.ResourceExhaustedErrorf("rate limit exceeded")))
+	require.False(t, IsServiceBusy(&shared.InternalServiceError{}))
+	require.False(t, IsServiceBusy(yarpcerrors.InternalErrorf("internal")))
+	require.False(t, IsServiceBusy(nil))
+}
+
+func Test_IsEntityNotExists(t *testing.T) {
+	require.True(t, IsEntityNotExists(&shared.EntityNotExistsError{}))
+	require.True(t, IsEntityNotExists(fmt.Errorf("describe failed: %w", &shared.EntityNotExistsError{})))
+	require.True(t, IsEntityNotExists(yarpcerrors.NotFoundErrorf("workflow not found")))
+	require.False(t, IsEntityNotExists(&shared.WorkflowExecutionAlreadyCompletedError{}))
+	require.False(t, IsEntityNotExists(errors.New("some error")))
+}
+
+func Test_IsWorkflowAlreadyCompleted(t *testing.T) {
+	require.True(t, IsWorkflowAlreadyCompleted(&shared.WorkflowExecutionAlreadyCompletedError{}))
+	require.True(t, IsWorkflowAlreadyCompleted(fmt.Errorf("signal failed: %w", &shared.WorkflowExecutionAlreadyCompletedError{})))
+	require.False(t, IsWorkflowAlreadyCompleted(&shared.EntityNotExistsError{}))
+	require.False(t, IsWorkflowAlreadyCompleted(nil))
+}
+
+func Test_IsTimeout(t *testing.T) {
+	startToClose := NewTimeoutError(shared.TimeoutTypeStartToClose)
+	require.True(t, IsTimeout(startToClose))
+	require.True(t, IsTimeout(startToClose, shared.TimeoutTypeStartToClose))
+	require.True(t, IsTimeout(startToClose, shared.TimeoutTypeHeartbeat, shared.TimeoutTypeStartToClose))
+	require.False(t, IsTimeout(startToClose, shared.TimeoutTypeHeartbeat))
+	require.True(t, IsTimeout(fmt.Errorf("activity failed: %w", NewHeartbeatTimeoutError()), shared.TimeoutTypeHeartbeat))
+
+	require.True(t, IsTimeout(context.DeadlineExceeded))
+	require.True(t, IsTimeout(yarpcerrors.DeadlineExceededErrorf("deadline exceeded")))
+	require.False(t, IsTimeout(context.DeadlineExceeded, shared.TimeoutTypeStartToClose))
+	require.False(t, IsTimeout(context.Canceled))
+	require.False(t, IsTimeout(nil))
+}
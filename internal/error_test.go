@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/cadence/.gen/go/shared"
@@ -112,6 +113,8 @@ func Test_TimeoutError(t *testing.T) {
 	require.False(t, timeoutErr.HasDetails())
 	var data string
 	require.Equal(t, ErrNoData, timeoutErr.Details(&data))
+	require.False(t, timeoutErr.HasLastHeartbeatDetails())
+	require.Equal(t, ErrNoData, timeoutErr.LastHeartbeatDetails(&data))
 
 	heartbeatErr := NewHeartbeatTimeoutError(testErrorDetails1)
 	require.True(t, heartbeatErr.HasDetails())
@@ -158,6 +161,11 @@ func testTimeoutErrorDetails(t *testing.T, timeoutType shared.TimeoutType) {
 	data := ""
 	require.NoError(t, err.Details(&data))
 	require.Equal(t, testErrorDetails1, data)
+	require.Equal(t, timeoutType, err.TimeoutType())
+	require.True(t, err.HasLastHeartbeatDetails())
+	heartbeatData := ""
+	require.NoError(t, err.LastHeartbeatDetails(&heartbeatData))
+	require.Equal(t, testErrorDetails1, heartbeatData)
 }
 
 func Test_CustomError(t *testing.T) {
@@ -370,6 +378,57 @@ func Test_CanceledError(t *testing.T) {
 	require.Equal(t, testErrorDetails3, b3)
 }
 
+func Test_PanicError(t *testing.T) {
+	panicActivityFn := func() error {
+		var m map[string]int
+		m["key"] = 1 // panics
+		return nil
+	}
+	s := &WorkflowTestSuite{}
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterActivity(panicActivityFn)
+	panicWorkflowFn := func(ctx Context) error {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		return ExecuteActivity(ctx, panicActivityFn).Get(ctx, nil)
+	}
+	env.RegisterWorkflow(panicWorkflowFn)
+	env.ExecuteWorkflow(panicWorkflowFn)
+	err := env.GetWorkflowError()
+	require.Error(t, err)
+	panicErr, ok := err.(*PanicError)
+	require.True(t, ok)
+	require.True(t, panicErr.HasDetails())
+
+	var details PanicDetails
+	require.NoError(t, ErrorDetails(fmt.Errorf("wrapped: %w", err), &details))
+	require.Equal(t, "assignment to entry in nil map", details.Value)
+	require.Equal(t, panicErr.StackTrace(), details.StackTrace)
+	require.Contains(t, details.StackTrace, "Test_PanicError.func1")
+	require.Contains(t, details.Location, "error_test.go:")
+	require.Equal(t, panicErr.Location(), details.Location)
+
+	var reason string
+	require.NoError(t, ErrorDetails(NewCustomError(customErrReasonA, testErrorDetails1), &reason))
+	require.Equal(t, testErrorDetails1, reason)
+	require.Equal(t, ErrNoData, ErrorDetails(NewCustomError(customErrReasonA), &reason))
+	require.Equal(t, ErrNoData, ErrorDetails(errors.New("plain error"), &reason))
+}
+
+func Test_PanicLocation(t *testing.T) {
+	stackTrace := `activity for tasklist [panic]:
+runtime.mapassign_faststr(0x0, 0x0)
+	/usr/local/go/src/runtime/map_faststr.go:203 +0x3f
+example.com/app.(*Activities).Charge(0x0)
+	/src/app/activities.go:42 +0x28
+reflect.Value.call(0x0)
+	/usr/local/go/src/reflect/value.go:586 +0xed9`
+	require.Equal(t, "/src/app/activities.go:42", getPanicLocation(stackTrace))
+	require.Equal(t, "", getPanicLocation("no frames"))
+}
+
 func Test_IsCanceledError(t *testing.T) {
 
 	tests := []struct {
@@ -464,6 +523,37 @@ func Test_SignalExternalWorkflowExecutionFailedError(t *testing.T) {
 	require.True(t, ok)
 }
 
+func Test_RequestCancelExternalWorkflowExecutionFailedError(t *testing.T) {
+	context := &workflowEnvironmentImpl{
+		decisionsHelper: newDecisionsHelper(),
+		dataConverter:   getDefaultDataConverter(),
+	}
+	var actualErr error
+	var initiatedEventID int64 = 101
+	cancellationID := "cancellationID"
+	context.decisionsHelper.scheduledEventIDToCancellationID[initiatedEventID] = cancellationID
+	di := context.decisionsHelper.newCancelExternalWorkflowStateMachine(
+		&shared.RequestCancelExternalWorkflowExecutionDecisionAttributes{},
+		cancellationID,
+	)
+	di.state = decisionStateInitiated
+	di.setData(&scheduledCancellation{
+		callback: func(r []byte, e error) {
+			actualErr = e
+		},
+	})
+	context.decisionsHelper.addDecision(di)
+	weh := &workflowExecutionEventHandlerImpl{context, nil}
+	event := createTestEventRequestCancelExternalWorkflowExecutionFailed(1, &shared.RequestCancelExternalWorkflowExecutionFailedEventAttributes{
+		InitiatedEventId:  common.Int64Ptr(initiatedEventID),
+		WorkflowExecution: &shared.WorkflowExecution{WorkflowId: common.StringPtr("wid")},
+		Cause:             shared.CancelExternalWorkflowExecutionFailedCauseUnknownExternalWorkflowExecution.Ptr(),
+	})
+	require.NoError(t, weh.handleRequestCancelExternalWorkflowExecutionFailed(event))
+	_, ok := actualErr.(*UnknownExternalWorkflowExecutionError)
+	require.True(t, ok)
+}
+
 func Test_ContinueAsNewError(t *testing.T) {
 	var a1 = 1234
 	var a2 = "some random input"
@@ -93,3 +93,36 @@ func NewHeaderWriter(header *shared.Header) HeaderWriter {
 	}
 	return &headerWriter{header}
 }
+
+const (
+	workflowHeaderContextKey contextKey = "workflowHeader"
+	outgoingHeaderContextKey contextKey = "outgoingHeader"
+)
+
+// GetHeader returns the value of the given key in the header the current workflow was started with, and whether the
+// key was present.
+func GetHeader(ctx Context, key string) ([]byte, bool) {
+	header, _ := ctx.Value(workflowHeaderContextKey).(*shared.Header)
+	if header == nil {
+		return nil, false
+	}
+	value, ok := header.Fields[key]
+	return value, ok
+}
+
+// WithHeader returns a copy of ctx with the given header field set on the activities and child workflows started
+// with it. It is typically called by workflow interceptors before forwarding the call to the next interceptor.
+// Fields injected by context propagators take precedence over the fields set by WithHeader.
+func WithHeader(ctx Context, key string, value []byte) Context {
+	fields := make(map[string][]byte)
+	for k, v := range getOutgoingHeaderFields(ctx) {
+		fields[k] = v
+	}
+	fields[key] = value
+	return WithValue(ctx, outgoingHeaderContextKey, fields)
+}
+
+func getOutgoingHeaderFields(ctx Context) map[string][]byte {
+	fields, _ := ctx.Value(outgoingHeaderContextKey).(map[string][]byte)
+	return fields
+}
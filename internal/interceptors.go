@@ -68,6 +68,7 @@ type WorkflowInterceptor interface {
 	IsReplaying(ctx Context) bool
 	HasLastCompletionResult(ctx Context) bool
 	GetLastCompletionResult(ctx Context, d ...interface{}) error
+	GetConfigValue(ctx Context, key string) Value
 }
 
 var _ WorkflowInterceptor = (*WorkflowInterceptorBase)(nil)
@@ -181,3 +182,8 @@ func (t *WorkflowInterceptorBase) HasLastCompletionResult(ctx Context) bool {
 func (t *WorkflowInterceptorBase) GetLastCompletionResult(ctx Context, d ...interface{}) error {
 	return t.Next.GetLastCompletionResult(ctx, d...)
 }
+
+// GetConfigValue forwards to t.Next
+func (t *WorkflowInterceptorBase) GetConfigValue(ctx Context, key string) Value {
+	return t.Next.GetConfigValue(ctx, key)
+}
@@ -28,6 +28,9 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -65,6 +68,15 @@ type (
 		WaitForCancellation           bool
 		OriginalTaskListName          string
 		RetryPolicy                   *shared.RetryPolicy
+		TaskListResolver              ActivityTaskListResolver
+		NonRetriableErrors            []error
+	}
+
+	// nonRetriableTypeError is an error returned by an activity which is reported with a reason naming its type,
+	// because the type is one of the ActivityOptions.NonRetriableErrors.
+	nonRetriableTypeError struct {
+		err      error
+		typeName string
 	}
 
 	localActivityOptions struct {
@@ -120,6 +132,7 @@ type (
 		serviceInvoker     ServiceInvoker
 		logger             *zap.Logger
 		metricsScope       tally.Scope
+		metricsTags        *activityMetricsTags
 		isLocalActivity    bool
 		heartbeatTimeout   time.Duration
 		deadline           time.Time
@@ -134,6 +147,29 @@ type (
 		workerStopChannel  <-chan struct{}
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
+		hostID             string
+	}
+
+	// activityMetricsTags holds the metrics tags added by an activity, they tag both the activity metrics scope and
+	// the metrics the worker emits for the activity task after the activity returns.
+	activityMetricsTags struct {
+		sync.Mutex
+		tags map[string]string
+	}
+
+	// activityMetricsScope is the metrics scope returned to an activity. It holds its own copy of the tags added by
+	// its Tagged calls, and adds them to the metrics tags of the activity without changing the tags of the other
+	// scopes of the activity.
+	activityMetricsScope struct {
+		tally.Scope
+		tags        map[string]string
+		metricsTags *activityMetricsTags
+	}
+
+	// activityMetricsTagsTaskHandler is implemented by the activity task handlers which collect the metrics tags
+	// added by the activity, see executeActivityTask.
+	activityMetricsTagsTaskHandler interface {
+		executeWithMetricsTags(taskList string, task *shared.PollForActivityTaskResponse, metricsTags *activityMetricsTags) (interface{}, error)
 	}
 
 	// context.WithValue need this type instead of basic type string to avoid lint error
@@ -154,6 +190,62 @@ func getActivityEnv(ctx context.Context) *activityEnvironment {
 	return env.(*activityEnvironment)
 }
 
+func (t *activityMetricsTags) add(tags map[string]string) {
+	t.Lock()
+	defer t.Unlock()
+	if t.tags == nil {
+		t.tags = make(map[string]string, len(tags))
+	}
+	for k, v := range tags {
+		t.tags[k] = v
+	}
+}
+
+// tagged returns scope tagged with the tags added so far, or scope itself if there are none.
+func (t *activityMetricsTags) tagged(scope tally.Scope) tally.Scope {
+	if t == nil {
+		return scope
+	}
+	t.Lock()
+	defer t.Unlock()
+	if len(t.tags) == 0 {
+		return scope
+	}
+	tags := make(map[string]string, len(t.tags))
+	for k, v := range t.tags {
+		tags[k] = v
+	}
+	return scope.Tagged(tags)
+}
+
+// Tagged returns a scope with tags added, which also tag the metrics the worker emits for the activity task.
+func (s *activityMetricsScope) Tagged(tags map[string]string) tally.Scope {
+	scopeTags := make(map[string]string, len(s.tags)+len(tags))
+	for k, v := range s.tags {
+		scopeTags[k] = v
+	}
+	for k, v := range tags {
+		scopeTags[k] = v
+	}
+	s.metricsTags.add(scopeTags)
+	return &activityMetricsScope{Scope: s.Scope.Tagged(tags), tags: scopeTags, metricsTags: s.metricsTags}
+}
+
+// SubScope returns a child scope with name appended to the prefix, whose Tagged scopes still tag the metrics the
+// worker emits for the activity task.
+func (s *activityMetricsScope) SubScope(name string) tally.Scope {
+	return &activityMetricsScope{Scope: s.Scope.SubScope(name), tags: s.tags, metricsTags: s.metricsTags}
+}
+
+// executeActivityTask executes the activity task with handler, collecting the metrics tags added by the activity
+// into metricsTags when the handler supports it.
+func executeActivityTask(handler ActivityTaskHandler, taskList string, task *shared.PollForActivityTaskResponse, metricsTags *activityMetricsTags) (interface{}, error) {
+	if h, ok := handler.(activityMetricsTagsTaskHandler); ok {
+		return h.executeWithMetricsTags(taskList, task, metricsTags)
+	}
+	return handler.Execute(taskList, task)
+}
+
 func getActivityOptions(ctx Context) *activityOptions {
 	eap := ctx.Value(activityOptionsContextKey)
 	if eap == nil {
@@ -180,27 +272,69 @@ func getValidatedActivityOptions(ctx Context) (*activityOptions, error) {
 		// We default to origin task list name.
 		p.TaskListName = p.OriginalTaskListName
 	}
-	if p.ScheduleToStartTimeoutSeconds <= 0 {
-		return nil, errors.New("missing or negative ScheduleToStartTimeoutSeconds")
-	}
-	if p.StartToCloseTimeoutSeconds <= 0 {
-		return nil, errors.New("missing or negative StartToCloseTimeoutSeconds")
+	if err := validateActivityOptions(p); err != nil {
+		return nil, err
 	}
+
+	return p, nil
+}
+
+// validateActivityOptions rejects the activity options which would be rejected by the server, or would make the
+// activity time out before it can complete, and defaults the unset timeouts like the server does.
+func validateActivityOptions(p *activityOptions) error {
 	if p.ScheduleToCloseTimeoutSeconds < 0 {
-		return nil, errors.New("missing or negative ScheduleToCloseTimeoutSeconds")
+		return errors.New("negative ScheduleToCloseTimeout")
+	}
+	if p.ScheduleToStartTimeoutSeconds < 0 {
+		return errors.New("negative ScheduleToStartTimeout")
+	}
+	if p.StartToCloseTimeoutSeconds < 0 {
+		return errors.New("negative StartToCloseTimeout")
+	}
+	if p.HeartbeatTimeoutSeconds < 0 {
+		return errors.New("negative HeartbeatTimeout")
 	}
 	if p.ScheduleToCloseTimeoutSeconds == 0 {
+		if p.ScheduleToStartTimeoutSeconds == 0 || p.StartToCloseTimeoutSeconds == 0 {
+			return errors.New("missing timeouts: either ScheduleToCloseTimeout, or both ScheduleToStartTimeout and StartToCloseTimeout must be set")
+		}
 		// This is a optional parameter, we default to sum of the other two timeouts.
 		p.ScheduleToCloseTimeoutSeconds = p.ScheduleToStartTimeoutSeconds + p.StartToCloseTimeoutSeconds
 	}
-	if p.HeartbeatTimeoutSeconds < 0 {
-		return nil, errors.New("invalid negative HeartbeatTimeoutSeconds")
+	if p.ScheduleToStartTimeoutSeconds == 0 {
+		p.ScheduleToStartTimeoutSeconds = p.ScheduleToCloseTimeoutSeconds
+	}
+	if p.StartToCloseTimeoutSeconds == 0 {
+		p.StartToCloseTimeoutSeconds = p.ScheduleToCloseTimeoutSeconds
+	}
+	if p.HeartbeatTimeoutSeconds > p.StartToCloseTimeoutSeconds {
+		return fmt.Errorf("HeartbeatTimeout (%vs) is longer than StartToCloseTimeout (%vs), the activity would time out before its first heartbeat is due",
+			p.HeartbeatTimeoutSeconds, p.StartToCloseTimeoutSeconds)
 	}
 	if err := validateRetryPolicy(p.RetryPolicy); err != nil {
-		return nil, err
+		return err
+	}
+	if expiration := p.RetryPolicy.GetExpirationIntervalInSeconds(); expiration > 0 && expiration < p.StartToCloseTimeoutSeconds {
+		return fmt.Errorf("ExpirationInterval (%vs) on retry policy is shorter than StartToCloseTimeout (%vs), the activity would not be retried after its first attempt times out",
+			expiration, p.StartToCloseTimeoutSeconds)
 	}
+	return nil
+}
 
-	return p, nil
+// getCallSite returns the file and line of the first caller outside of the packages of the client, e.g. the
+// workflow code calling ExecuteActivity.
+func getCallSite() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "go.uber.org/cadence/") || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%v:%v", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
 }
 
 func getValidatedLocalActivityOptions(ctx Context) (*localActivityOptions, error) {
@@ -230,6 +364,10 @@ func validateRetryPolicy(p *shared.RetryPolicy) error {
 		// if not set, default to 100x of initial interval
 		p.MaximumIntervalInSeconds = common.Int32Ptr(100 * p.GetInitialIntervalInSeconds())
 	}
+	if p.GetMaximumIntervalInSeconds() < p.GetInitialIntervalInSeconds() {
+		return fmt.Errorf("MaximumIntervalInSeconds (%v) cannot be less than InitialIntervalInSeconds (%v) on retry policy",
+			p.GetMaximumIntervalInSeconds(), p.GetInitialIntervalInSeconds())
+	}
 	if p.GetMaximumAttempts() < 0 {
 		return errors.New("negative MaximumAttempts on retry policy is invalid")
 	}
@@ -242,10 +380,101 @@ func validateRetryPolicy(p *shared.RetryPolicy) error {
 	if p.GetMaximumAttempts() == 0 && p.GetExpirationIntervalInSeconds() == 0 {
 		return errors.New("both MaximumAttempts and ExpirationIntervalInSeconds on retry policy are not set, at least one of them must be set")
 	}
+	for _, reason := range p.NonRetriableErrorReasons {
+		if reason == "" {
+			return errors.New("empty reason in NonRetriableErrorReasons on retry policy is invalid")
+		}
+	}
 
 	return nil
 }
 
+// defaultRetryExpiration limits a workflow retry policy which sets neither MaximumAttempts nor ExpirationInterval to
+// timeoutSeconds, the execution timeout of the workflow, instead of leaving it to be rejected.
+func defaultRetryExpiration(p *shared.RetryPolicy, timeoutSeconds int32) {
+	if p != nil && p.GetMaximumAttempts() == 0 && p.GetExpirationIntervalInSeconds() == 0 && timeoutSeconds > 0 {
+		p.ExpirationIntervalInSeconds = common.Int32Ptr(timeoutSeconds)
+	}
+}
+
+// applyNonRetriableErrors returns a copy of the retry policy which does not retry the nonRetriableErrors. Errors with
+// a reason of their own, like *CustomError, are matched by reason. Other errors are matched by type, the types are
+// listed in the header so that the activity worker reports them with a reason naming the type.
+func applyNonRetriableErrors(p *shared.RetryPolicy, header *shared.Header, nonRetriableErrors []error) *shared.RetryPolicy {
+	policy := *p
+	policy.NonRetriableErrorReasons = append([]string(nil), p.NonRetriableErrorReasons...)
+	var types []string
+	for _, err := range nonRetriableErrors {
+		switch err := err.(type) {
+		case nil:
+		case *CustomError:
+			policy.NonRetriableErrorReasons = append(policy.NonRetriableErrorReasons, err.Reason())
+		case *PanicError:
+			policy.NonRetriableErrorReasons = append(policy.NonRetriableErrorReasons, errReasonPanic)
+		case *TimeoutError:
+			policy.NonRetriableErrorReasons = append(policy.NonRetriableErrorReasons,
+				fmt.Sprintf("%v %v", errReasonTimeout, err.TimeoutType()))
+		default:
+			typeName := getErrorTypeName(err)
+			policy.NonRetriableErrorReasons = append(policy.NonRetriableErrorReasons,
+				fmt.Sprintf("%v %v", errReasonGeneric, typeName))
+			types = append(types, typeName)
+		}
+	}
+	if len(types) > 0 {
+		header.Fields[nonRetriableErrorTypesHeaderName] = []byte(strings.Join(types, "\n"))
+	}
+	return &policy
+}
+
+// withNonRetriableErrorType wraps the error returned by an activity into a *nonRetriableTypeError if the error, or an
+// error it wraps, is of one of the types listed in the header of the activity task.
+func withNonRetriableErrorType(err error, header *shared.Header) error {
+	if err == nil || err == context.Canceled || err == ErrActivityResultPending || header == nil {
+		return err
+	}
+	switch err.(type) {
+	case *CustomError, *CanceledError, *PanicError, *TimeoutError:
+		return err
+	}
+	value, ok := header.Fields[nonRetriableErrorTypesHeaderName]
+	if !ok {
+		return err
+	}
+	types := strings.Split(string(value), "\n")
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		typeName := getErrorTypeName(e)
+		for _, t := range types {
+			if t == typeName {
+				return &nonRetriableTypeError{err: err, typeName: typeName}
+			}
+		}
+	}
+	return err
+}
+
+// getErrorTypeName returns the name of the type of the error qualified by its package path.
+func getErrorTypeName(err error) string {
+	t := reflect.TypeOf(err)
+	pointer := ""
+	if t.Kind() == reflect.Ptr {
+		pointer = "*"
+		t = t.Elem()
+	}
+	if t.Name() == "" || t.PkgPath() == "" {
+		return pointer + t.String()
+	}
+	return pointer + t.PkgPath() + "." + t.Name()
+}
+
+func (e *nonRetriableTypeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nonRetriableTypeError) Unwrap() error {
+	return e.err
+}
+
 func validateFunctionArgs(f interface{}, args []interface{}, isWorkflow bool) error {
 	fType := reflect.TypeOf(f)
 	if fType == nil || fType.Kind() != reflect.Func {
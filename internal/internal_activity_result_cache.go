@@ -0,0 +1,71 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.uber.org/cadence/internal/common/cache"
+)
+
+const defaultActivityResultCacheSize = 1000
+
+type (
+	// activityResultCache holds the results of the successful executions of idempotent activities, so that the
+	// retries of an activity with the same input are completed without running it again.
+	activityResultCache struct {
+		cache cache.Cache
+	}
+
+	cachedActivityResult struct {
+		output []byte
+	}
+)
+
+// newActivityResultCache returns nil when the ttl is not positive, which disables the cache.
+func newActivityResultCache(size int, ttl time.Duration) *activityResultCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = defaultActivityResultCacheSize
+	}
+	return &activityResultCache{cache: cache.New(size, &cache.Options{TTL: ttl})}
+}
+
+func (c *activityResultCache) get(activityType string, input []byte) ([]byte, bool) {
+	result, ok := c.cache.Get(getActivityResultCacheKey(activityType, input)).(*cachedActivityResult)
+	if !ok {
+		return nil, false
+	}
+	return result.output, true
+}
+
+func (c *activityResultCache) put(activityType string, input []byte, output []byte) {
+	c.cache.Put(getActivityResultCacheKey(activityType, input), &cachedActivityResult{output: output})
+}
+
+func getActivityResultCacheKey(activityType string, input []byte) string {
+	hash := sha256.Sum256(input)
+	return activityType + "_" + hex.EncodeToString(hash[:])
+}
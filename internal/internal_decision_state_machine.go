@@ -160,6 +160,7 @@ const (
 	versionMarkerName           = "Version"
 	localActivityMarkerName     = "LocalActivity"
 	mutableSideEffectMarkerName = "MutableSideEffect"
+	timerSummaryMarkerName      = "TimerSummary"
 )
 
 func (d decisionState) String() string {
@@ -822,6 +823,22 @@ func (h *decisionsHelper) recordMutableSideEffectMarker(mutableSideEffectID stri
 	return decision
 }
 
+func (h *decisionsHelper) recordTimerSummaryMarker(timerID string, summary string, dataConverter DataConverter) decisionStateMachine {
+	markerID := fmt.Sprintf("%v_%v", timerSummaryMarkerName, timerID)
+	details, err := encodeArgs(dataConverter, []interface{}{timerID, summary})
+	if err != nil {
+		panic(err)
+	}
+
+	attributes := &s.RecordMarkerDecisionAttributes{
+		MarkerName: common.StringPtr(timerSummaryMarkerName),
+		Details:    details,
+	}
+	decision := h.newMarkerDecisionStateMachine(markerID, attributes)
+	h.addDecision(decision)
+	return decision
+}
+
 func (h *decisionsHelper) startChildWorkflowExecution(attributes *s.StartChildWorkflowExecutionDecisionAttributes) decisionStateMachine {
 	decision := h.newChildWorkflowDecisionStateMachine(attributes)
 	h.addDecision(decision)
@@ -996,9 +1013,10 @@ func (h *decisionsHelper) handleTimerClosed(timerID string) decisionStateMachine
 	return decision
 }
 
-func (h *decisionsHelper) handleTimerStarted(timerID string) {
+func (h *decisionsHelper) handleTimerStarted(timerID string) decisionStateMachine {
 	decision := h.getDecision(makeDecisionID(decisionTypeTimer, timerID))
 	decision.handleInitiatedEvent()
+	return decision
 }
 
 func (h *decisionsHelper) handleTimerCanceled(timerID string) {
@@ -0,0 +1,142 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+// defaultDecisionTaskDumpRedactor is used when WorkerOptions.DecisionTaskFailureDumpRedactor is not set, it keeps
+// workflow inputs, results and other payloads out of the logs.
+func defaultDecisionTaskDumpRedactor(payload []byte) string {
+	return fmt.Sprintf("<redacted %d bytes>", len(payload))
+}
+
+// dumpFailedDecisionTask logs everything needed to debug a failed decision task offline: the decisions produced so
+// far, the state of every decision state machine and the history events of the decision task.
+// The decisions already taken from the state machines for the response are passed in, the ones not taken yet are
+// added from the state machines.
+func (w *workflowExecutionContextImpl) dumpFailedDecisionTask(
+	task *s.PollForDecisionTaskResponse,
+	newDecisions []*s.Decision,
+	taskErr error,
+) {
+	redact := w.wth.decisionTaskFailureDumpRedactor
+	if redact == nil {
+		redact = defaultDecisionTaskDumpRedactor
+	}
+
+	var decisions []interface{}
+	for _, decision := range newDecisions {
+		decisions = append(decisions, dumpValue(reflect.ValueOf(decision), redact))
+	}
+	var stateMachines []string
+	if eventHandler := w.getEventHandler(); eventHandler != nil {
+		for curr := eventHandler.decisionsHelper.orderedDecisions.Front(); curr != nil; curr = curr.Next() {
+			d := curr.Value.(decisionStateMachine)
+			if decision := d.getDecision(); decision != nil {
+				decisions = append(decisions, dumpValue(reflect.ValueOf(decision), redact))
+			}
+			stateMachines = append(stateMachines, fmt.Sprintf("%v", d))
+		}
+	}
+
+	var history []interface{}
+	if task.History != nil {
+		for _, event := range task.History.Events {
+			if event.GetEventId() > task.GetPreviousStartedEventId() {
+				history = append(history, dumpValue(reflect.ValueOf(event), redact))
+			}
+		}
+	}
+
+	w.wth.logger.Warn("Dumping failed decision task.",
+		zap.String(tagWorkflowType, task.WorkflowType.GetName()),
+		zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
+		zap.String(tagRunID, task.WorkflowExecution.GetRunId()),
+		zap.Int64("PreviousStartedEventID", task.GetPreviousStartedEventId()),
+		zap.Int64("StartedEventID", task.GetStartedEventId()),
+		zap.Any("Decisions", decisions),
+		zap.Strings("DecisionStateMachines", stateMachines),
+		zap.Any("History", history),
+		zap.Error(taskErr))
+}
+
+// dumpValue converts a thrift value to plain maps and slices keyed by the json field names, with every binary
+// payload replaced by the result of redact.
+func dumpValue(v reflect.Value, redact func([]byte) string) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return dumpValue(v.Elem(), redact)
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			value := v.Field(i)
+			if field.PkgPath != "" || isEmptyDumpValue(value) {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+			fields[name] = dumpValue(value, redact)
+		}
+		return fields
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return redact(v.Bytes())
+		}
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = dumpValue(v.Index(i), redact)
+		}
+		return values
+	case reflect.Map:
+		values := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			values[fmt.Sprint(key)] = dumpValue(v.MapIndex(key), redact)
+		}
+		return values
+	}
+	// enums are dumped by name
+	if stringer, ok := v.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return v.Interface()
+}
+
+func isEmptyDumpValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
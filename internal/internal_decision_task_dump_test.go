@@ -0,0 +1,115 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func TestDecisionTaskFailureDump(t *testing.T) {
+	registry := newRegistry()
+	registry.RegisterWorkflowWithOptions(func(ctx Context, input string) error {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		ExecuteActivity(ctx, "Greeter_Activity", "top secret")
+		panic("boom")
+	}, RegisterWorkflowOptions{Name: "DumpWorkflow"})
+
+	taskList := "tl1"
+	startedAttributes := &s.WorkflowExecutionStartedEventAttributes{
+		TaskList: &s.TaskList{Name: &taskList},
+		Input:    []byte(`"top secret"`),
+	}
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, startedAttributes),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+
+	for _, enabled := range []bool{false, true} {
+		core, observed := observer.New(zapcore.WarnLevel)
+		params := workerExecutionParameters{
+			TaskList:                      taskList,
+			Identity:                      "test-id-1",
+			Logger:                        zap.New(core),
+			EnableDecisionTaskFailureDump: enabled,
+		}
+		taskHandler := newWorkflowTaskHandler(testDomain, params, nil, registry)
+		task := createWorkflowTask(testEvents, 0, "DumpWorkflow")
+		request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+		require.NoError(t, err)
+		require.IsType(t, &s.RespondDecisionTaskFailedRequest{}, request)
+
+		logs := observed.FilterMessage("Dumping failed decision task.").All()
+		if !enabled {
+			assert.Empty(t, logs)
+			continue
+		}
+		require.Len(t, logs, 1)
+		fields := logs[0].ContextMap()
+		assert.Equal(t, "DumpWorkflow", fields[tagWorkflowType])
+		assert.Len(t, fields["Decisions"], 1)
+		assert.Len(t, fields["DecisionStateMachines"], 1)
+		assert.Contains(t, fields["DecisionStateMachines"].([]interface{})[0], "state=DecisionSent")
+		assert.Len(t, fields["History"], 3)
+		assert.Contains(t, fields["error"], "boom")
+
+		dump, err := json.Marshal(fields)
+		require.NoError(t, err)
+		assert.NotContains(t, string(dump), "top secret")
+		assert.Contains(t, string(dump), "redacted 12 bytes")
+		assert.Contains(t, string(dump), s.DecisionTypeScheduleActivityTask.String())
+	}
+}
+
+func TestDumpValue(t *testing.T) {
+	event := &s.HistoryEvent{
+		EventId:   common.Int64Ptr(5),
+		EventType: common.EventTypePtr(s.EventTypeActivityTaskCompleted),
+		ActivityTaskCompletedEventAttributes: &s.ActivityTaskCompletedEventAttributes{
+			Result:           []byte("result"),
+			ScheduledEventId: common.Int64Ptr(4),
+		},
+	}
+	dumped := dumpValue(reflect.ValueOf(event), func(payload []byte) string { return "[" + string(payload) + "]" })
+	assert.Equal(t, map[string]interface{}{
+		"eventId":   int64(5),
+		"eventType": "ActivityTaskCompleted",
+		"activityTaskCompletedEventAttributes": map[string]interface{}{
+			"result":           "[result]",
+			"scheduledEventId": int64(4),
+		},
+	}, dumped)
+}
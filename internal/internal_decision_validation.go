@@ -0,0 +1,142 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/metrics"
+	"go.uber.org/cadence/internal/common/util"
+	"go.uber.org/zap"
+)
+
+// validateDecisions replays the whole history of the task in a new execution context, which is not cached, and
+// returns an error, which fails the decision task, if it does not build the decisions of the response. It catches
+// state that drifted from what the workflow code builds from the history, e.g. because of non-deterministic workflow
+// code that replays hide while the execution stays in the sticky cache.
+// The decision tasks that ran local activities are not validated, as the replay does not run them again.
+func (wth *workflowTaskHandlerImpl) validateDecisions(decisionTask *workflowTask, response interface{}) error {
+	request, ok := response.(*s.RespondDecisionTaskCompletedRequest)
+	task := decisionTask.task
+	if !ok || task.Query != nil || hasLocalActivityMarker(request.Decisions) {
+		return nil
+	}
+	logger := wth.logger.With(
+		zap.String(tagWorkflowType, task.WorkflowType.GetName()),
+		zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
+		zap.String(tagRunID, task.WorkflowExecution.GetRunId()))
+
+	replayTask := *task
+	replayTask.Queries = nil
+	if decisionTask.historyIterator != nil {
+		if _, err := resetHistory(&replayTask, decisionTask.historyIterator); err != nil {
+			logger.Warn("Failed to load the history to validate decisions.", zap.Error(err))
+			return nil
+		}
+	} else if !isFullHistory(replayTask.History) {
+		return nil
+	}
+
+	// the replay does not report metrics or use the workflow snapshots, nothing but its decisions is kept
+	replayHandler := *wth
+	replayHandler.metricsScope = metrics.NewTaggedScope(nil)
+	replayHandler.snapshotStore = nil
+	replayContext, err := replayHandler.createWorkflowContext(&replayTask)
+	if err != nil {
+		return err
+	}
+	defer replayContext.clearState()
+
+	startTime := time.Now()
+	replayResponse, err := replayContext.ProcessWorkflowTask(&workflowTask{
+		task:            &replayTask,
+		historyIterator: decisionTask.historyIterator,
+	})
+	metricsScope := wth.metricsScope.GetTaggedScope(tagWorkflowType, task.WorkflowType.GetName())
+	metricsScope.Timer(metrics.DecisionValidationLatency).Record(time.Now().Sub(startTime))
+	if err == nil {
+		replayRequest, ok := replayResponse.(*s.RespondDecisionTaskCompletedRequest)
+		if !ok {
+			err = fmt.Errorf("replay failed the decision task: %v", replayResponse)
+		} else {
+			err = matchDecisions(request.Decisions, replayRequest.Decisions)
+		}
+	}
+	if err != nil {
+		metricsScope.Counter(metrics.DecisionValidationFailed).Inc(1)
+		logger.Error("Decisions differ from the replay of the whole history.", zap.Error(err))
+		return fmt.Errorf("decision validation failed: %v", err)
+	}
+	return nil
+}
+
+func hasLocalActivityMarker(decisions []*s.Decision) bool {
+	for _, d := range decisions {
+		if d.GetDecisionType() == s.DecisionTypeRecordMarker &&
+			d.RecordMarkerDecisionAttributes.GetMarkerName() == localActivityMarkerName {
+			return true
+		}
+	}
+	return false
+}
+
+func matchDecisions(decisions, replayDecisions []*s.Decision) error {
+	if len(decisions) != len(replayDecisions) {
+		return fmt.Errorf("%v decisions were built but the replay built %v", len(decisions), len(replayDecisions))
+	}
+	for i, d := range decisions {
+		if decisionIdentity(d) != decisionIdentity(replayDecisions[i]) {
+			return fmt.Errorf("decision %v: %v, replay decision: %v",
+				i, util.DecisionToString(d), util.DecisionToString(replayDecisions[i]))
+		}
+	}
+	return nil
+}
+
+// decisionIdentity returns what identifies the decision the way isDecisionMatchEvent does in non-strict mode: its type
+// and the IDs and type names of what it acts on. Inputs and results are left out, as the replay runs the side effects of
+// the decision task again and the decisions built from their fresh values differ from the original ones.
+func decisionIdentity(d *s.Decision) string {
+	var id []string
+	switch d.GetDecisionType() {
+	case s.DecisionTypeScheduleActivityTask:
+		attributes := d.ScheduleActivityTaskDecisionAttributes
+		id = []string{attributes.GetActivityId(), lastPartOfName(attributes.ActivityType.GetName())}
+	case s.DecisionTypeRequestCancelActivityTask:
+		id = []string{d.RequestCancelActivityTaskDecisionAttributes.GetActivityId()}
+	case s.DecisionTypeStartTimer:
+		id = []string{d.StartTimerDecisionAttributes.GetTimerId()}
+	case s.DecisionTypeCancelTimer:
+		id = []string{d.CancelTimerDecisionAttributes.GetTimerId()}
+	case s.DecisionTypeRecordMarker:
+		id = []string{d.RecordMarkerDecisionAttributes.GetMarkerName()}
+	case s.DecisionTypeRequestCancelExternalWorkflowExecution:
+		id = []string{d.RequestCancelExternalWorkflowExecutionDecisionAttributes.GetWorkflowId()}
+	case s.DecisionTypeSignalExternalWorkflowExecution:
+		attributes := d.SignalExternalWorkflowExecutionDecisionAttributes
+		id = []string{attributes.Execution.GetWorkflowId(), attributes.GetSignalName()}
+	case s.DecisionTypeStartChildWorkflowExecution:
+		id = []string{lastPartOfName(d.StartChildWorkflowExecutionDecisionAttributes.WorkflowType.GetName())}
+	}
+	return fmt.Sprintf("%v%q", d.GetDecisionType(), id)
+}
@@ -28,6 +28,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -60,8 +61,9 @@ type (
 	}
 
 	scheduledTimer struct {
-		callback resultHandler
-		handled  bool
+		callback    resultHandler
+		handled     bool
+		startToFire time.Duration // duration of the started timer event, used to chain timers
 	}
 
 	scheduledActivity struct {
@@ -118,6 +120,19 @@ type (
 		contextPropagators   []ContextPropagator
 		tracer               opentracing.Tracer
 		workflowInterceptors []WorkflowInterceptorFactory
+
+		configProvider         WorkflowConfigProvider
+		configSnapshot         map[string][]byte // snapshot of the config provider taken for the current decision
+		configSnapshotDecision int64             // DecisionStartedEventID of the decision the snapshot was taken for
+
+		maxTimerDuration time.Duration // longer timers are split into chained timers, 0 disables splitting
+
+		defaultActivityOptions      *ActivityOptions
+		defaultLocalActivityOptions *LocalActivityOptions
+
+		snapshotter     WorkflowSnapshotter
+		resumeSnapshot  *WorkflowSnapshot // snapshot the execution resumes from, until the workflow restores it
+		cancelRequested bool
 	}
 
 	localActivityTask struct {
@@ -197,11 +212,16 @@ func newWorkflowExecutionEventHandler(
 	logger *zap.Logger,
 	enableLoggingInReplay bool,
 	scope tally.Scope,
+	metricsBuffer *metrics.Buffer,
 	registry *registry,
 	dataConverter DataConverter,
 	contextPropagators []ContextPropagator,
 	tracer opentracing.Tracer,
 	workflowInterceptors []WorkflowInterceptorFactory,
+	configProvider WorkflowConfigProvider,
+	maxTimerDuration time.Duration,
+	defaultActivityOptions *ActivityOptions,
+	defaultLocalActivityOptions *LocalActivityOptions,
 ) workflowExecutionEventHandler {
 	context := &workflowEnvironmentImpl{
 		workflowInfo:          workflowInfo,
@@ -219,6 +239,11 @@ func newWorkflowExecutionEventHandler(
 		contextPropagators:    contextPropagators,
 		tracer:                tracer,
 		workflowInterceptors:  workflowInterceptors,
+		configProvider:        configProvider,
+		maxTimerDuration:      maxTimerDuration,
+
+		defaultActivityOptions:      defaultActivityOptions,
+		defaultLocalActivityOptions: defaultLocalActivityOptions,
 	}
 	context.logger = logger.With(
 		zapcore.Field{Key: tagWorkflowType, Type: zapcore.StringType, String: workflowInfo.WorkflowType.Name},
@@ -226,7 +251,10 @@ func newWorkflowExecutionEventHandler(
 		zapcore.Field{Key: tagRunID, Type: zapcore.StringType, String: workflowInfo.WorkflowExecution.RunID},
 	).WithOptions(zap.WrapCore(wrapLogger(&context.isReplay, &context.enableLoggingInReplay)))
 
-	if scope != nil {
+	if scope != nil && metricsBuffer != nil {
+		context.metricsScope = tagScope(metrics.WrapBufferedScope(&context.isReplay, scope, context, metricsBuffer),
+			tagWorkflowType, workflowInfo.WorkflowType.Name)
+	} else if scope != nil {
 		context.metricsScope = tagScope(metrics.WrapScope(&context.isReplay, scope, context),
 			tagWorkflowType, workflowInfo.WorkflowType.Name)
 	}
@@ -564,14 +592,49 @@ func (wc *workflowEnvironmentImpl) NewTimer(d time.Duration, callback resultHand
 		callback(nil, nil)
 		return nil
 	}
+	if wc.maxTimerDuration > 0 && d > wc.maxTimerDuration {
+		return wc.newChainedTimer(d, callback)
+	}
+	return wc.startTimer(d, &scheduledTimer{callback: callback})
+}
+
+// newChainedTimer starts timers of at most maxTimerDuration one after another until they add up to d. The returned
+// timerInfo always refers to the pending timer of the chain, so that it can be canceled. The fired durations are
+// taken from the started timer events, so a history with a single longer timer replays the same.
+func (wc *workflowEnvironmentImpl) newChainedTimer(d time.Duration, callback resultHandler) *timerInfo {
+	info := &timerInfo{}
+	var elapsed time.Duration
+	var startNext func()
+	startNext = func() {
+		timer := &scheduledTimer{}
+		timer.callback = func(r []byte, e error) {
+			elapsed += timer.startToFire
+			if e == nil && elapsed < d {
+				startNext()
+				return
+			}
+			callback(r, e)
+		}
+		next := d - elapsed
+		if next > wc.maxTimerDuration {
+			next = wc.maxTimerDuration
+		}
+		info.timerID = wc.startTimer(next, timer).timerID
+	}
+	startNext()
+	return info
+}
 
+func (wc *workflowEnvironmentImpl) startTimer(d time.Duration, timer *scheduledTimer) *timerInfo {
+	durationInSeconds := common.Int64Ceil(d.Seconds())
 	timerID := wc.GenerateSequenceID()
 	startTimerAttr := &m.StartTimerDecisionAttributes{}
 	startTimerAttr.TimerId = common.StringPtr(timerID)
 	startTimerAttr.StartToFireTimeoutSeconds = common.Int64Ptr(durationInSeconds)
 
+	timer.startToFire = time.Duration(durationInSeconds) * time.Second
 	decision := wc.decisionsHelper.startTimer(startTimerAttr)
-	decision.setData(&scheduledTimer{callback: callback})
+	decision.setData(timer)
 
 	wc.logger.Debug("NewTimer",
 		zap.String(tagTimerID, startTimerAttr.GetTimerId()),
@@ -582,6 +645,10 @@ func (wc *workflowEnvironmentImpl) NewTimer(d time.Duration, callback resultHand
 	return &timerInfo{timerID: timerID}
 }
 
+func (wc *workflowEnvironmentImpl) RecordTimerSummary(timerID string, summary string) {
+	wc.decisionsHelper.recordTimerSummaryMarker(timerID, summary, wc.GetDataConverter())
+}
+
 func (wc *workflowEnvironmentImpl) RequestCancelTimer(timerID string) {
 	decision := wc.decisionsHelper.cancelTimer(timerID)
 	timer := decision.getData().(*scheduledTimer)
@@ -766,6 +833,24 @@ func (wc *workflowEnvironmentImpl) getOpenSessions() []*SessionInfo {
 	return openSessions
 }
 
+func (wc *workflowEnvironmentImpl) getWorkerMetadata() WorkerMetadata {
+	workflowTypes := wc.registry.getRegisteredWorkflowTypes()
+	sort.Strings(workflowTypes)
+	activityTypes := make([]string, 0)
+	for _, a := range wc.registry.getRegisteredActivities() {
+		activityTypes = append(activityTypes, a.ActivityType().Name)
+	}
+	sort.Strings(activityTypes)
+	return WorkerMetadata{
+		LibraryVersion: LibraryVersion,
+		FeatureVersion: FeatureVersion,
+		BinaryChecksum: getBinaryChecksum(),
+		HostName:       getHostName(),
+		WorkflowTypes:  workflowTypes,
+		ActivityTypes:  activityTypes,
+	}
+}
+
 func (wc *workflowEnvironmentImpl) GetRegistry() *registry {
 	return wc.registry
 }
@@ -774,6 +859,10 @@ func (wc *workflowEnvironmentImpl) GetWorkflowInterceptors() []WorkflowIntercept
 	return wc.workflowInterceptors
 }
 
+func (wc *workflowEnvironmentImpl) GetDefaultActivityOptions() (*ActivityOptions, *LocalActivityOptions) {
+	return wc.defaultActivityOptions, wc.defaultLocalActivityOptions
+}
+
 func (weh *workflowExecutionEventHandlerImpl) ProcessEvent(
 	event *m.HistoryEvent,
 	isReplay bool,
@@ -855,7 +944,7 @@ func (weh *workflowExecutionEventHandlerImpl) ProcessEvent(
 		err = weh.handleActivityTaskCanceled(event)
 
 	case m.EventTypeTimerStarted:
-		weh.decisionsHelper.handleTimerStarted(event.TimerStartedEventAttributes.GetTimerId())
+		weh.handleTimerStarted(event)
 
 	case m.EventTypeTimerFired:
 		weh.handleTimerFired(event)
@@ -955,6 +1044,8 @@ func (weh *workflowExecutionEventHandlerImpl) ProcessQuery(queryType string, que
 		return weh.encodeArg(weh.StackTrace())
 	case QueryTypeOpenSessions:
 		return weh.encodeArg(weh.getOpenSessions())
+	case QueryTypeWorkerMetadata:
+		return weh.encodeArg(weh.getWorkerMetadata())
 	default:
 		result, err := weh.queryHandler(queryType, queryArgs)
 		if err != nil {
@@ -978,6 +1069,13 @@ func (weh *workflowExecutionEventHandlerImpl) StackTrace() string {
 	return weh.workflowDefinition.StackTrace()
 }
 
+func (weh *workflowExecutionEventHandlerImpl) CoroutineCount() int {
+	if weh.workflowDefinition == nil {
+		return 0
+	}
+	return weh.workflowDefinition.CoroutineCount()
+}
+
 func (weh *workflowExecutionEventHandlerImpl) Close() {
 	if weh.workflowDefinition != nil {
 		weh.workflowDefinition.Close()
@@ -1041,7 +1139,7 @@ func (weh *workflowExecutionEventHandlerImpl) handleActivityTaskTimedOut(event *
 		err = constructError(attributes.GetLastFailureReason(), attributes.LastFailureDetails, weh.GetDataConverter())
 	} else {
 		details := newEncodedValues(attributes.Details, weh.GetDataConverter())
-		err = NewTimeoutError(attributes.GetTimeoutType(), details)
+		err = newActivityTimeoutError(attributes.GetTimeoutType(), details)
 	}
 	activity.handle(nil, err)
 	return nil
@@ -1065,6 +1163,14 @@ func (weh *workflowExecutionEventHandlerImpl) handleActivityTaskCanceled(event *
 	return nil
 }
 
+func (weh *workflowExecutionEventHandlerImpl) handleTimerStarted(event *m.HistoryEvent) {
+	attributes := event.TimerStartedEventAttributes
+	decision := weh.decisionsHelper.handleTimerStarted(attributes.GetTimerId())
+	if timer, ok := decision.getData().(*scheduledTimer); ok && attributes.StartToFireTimeoutSeconds != nil {
+		timer.startToFire = time.Duration(attributes.GetStartToFireTimeoutSeconds()) * time.Second
+	}
+}
+
 func (weh *workflowExecutionEventHandlerImpl) handleTimerFired(event *m.HistoryEvent) {
 	timerID := event.TimerFiredEventAttributes.GetTimerId()
 	decision := weh.decisionsHelper.handleTimerClosed(timerID)
@@ -1077,6 +1183,7 @@ func (weh *workflowExecutionEventHandlerImpl) handleTimerFired(event *m.HistoryE
 }
 
 func (weh *workflowExecutionEventHandlerImpl) handleWorkflowExecutionCancelRequested() {
+	weh.cancelRequested = true
 	weh.cancelHandler()
 }
 
@@ -1106,6 +1213,9 @@ func (weh *workflowExecutionEventHandlerImpl) handleMarkerRecorded(
 		encodedValues.Get(&fixedID, &result)
 		weh.mutableSideEffect[fixedID] = []byte(result)
 		return nil
+	case timerSummaryMarkerName:
+		// the summary is only recorded for the users reading the history
+		return nil
 	default:
 		return fmt.Errorf("unknown marker name \"%v\" for eventID \"%v\"",
 			attributes.GetMarkerName(), eventID)
@@ -1339,7 +1449,13 @@ func (weh *workflowExecutionEventHandlerImpl) handleRequestCancelExternalWorkflo
 		if cancellation.handled {
 			return nil
 		}
-		err := fmt.Errorf("cancel external workflow failed, %v", attributes.GetCause())
+		var err error
+		switch attributes.GetCause() {
+		case m.CancelExternalWorkflowExecutionFailedCauseUnknownExternalWorkflowExecution:
+			err = newUnknownExternalWorkflowExecutionError()
+		default:
+			err = fmt.Errorf("cancel external workflow failed, %v", attributes.GetCause())
+		}
 		cancellation.handle(nil, err)
 	}
 
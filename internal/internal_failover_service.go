@@ -0,0 +1,591 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFailoverResolveInterval     = 30 * time.Second
+	defaultFailoverHealthCheckInterval = 5 * time.Second
+	defaultFailoverHealthCheckTimeout  = 5 * time.Second
+)
+
+type (
+	// FailoverServiceOptions configures a workflow service client spreading calls over several frontend hosts.
+	FailoverServiceOptions struct {
+		// Required: Creates the service client of a single frontend host, typically from a yarpc dispatcher with
+		// an outbound to hostPort.
+		NewService func(hostPort string) (workflowserviceclient.Interface, error)
+
+		// Optional: The frontend hosts, as host:port. Either HostPorts or DNSName is required.
+		HostPorts []string
+
+		// Optional: A DNS name resolving to the frontend hosts, which listen on DNSPort. The name is resolved again
+		// every ResolveInterval, so hosts can be added and removed without restarting the process.
+		DNSName string
+		DNSPort int
+
+		// Optional: Sets how often DNSName is resolved again.
+		// default: 30s
+		ResolveInterval time.Duration
+
+		// Optional: Sets how often the hosts marked unhealthy are checked. A host is marked unhealthy when a call to it
+		// fails with a connection error, and healthy again once HealthCheck succeeds.
+		// default: 5s
+		HealthCheckInterval time.Duration
+
+		// Optional: Checks whether a frontend host is healthy.
+		// default: a GetClusterInfo call with a 5s timeout
+		HealthCheck func(ctx context.Context, service workflowserviceclient.Interface) error
+
+		// Optional: Logger the failover events are logged to.
+		// default: no logging
+		Logger *zap.Logger
+	}
+
+	// FailoverWorkflowService is a workflow service client spreading calls over several frontend hosts. Calls are
+	// sent round robin to the healthy hosts, so a single host failure doesn't stall all pollers until the
+	// connection times out.
+	FailoverWorkflowService interface {
+		workflowserviceclient.Interface
+		// Close stops the health checks and the DNS resolution.
+		Close()
+	}
+
+	failoverServiceWrapper struct {
+		options   FailoverServiceOptions
+		logger    *zap.Logger
+		lookup    func(host string) ([]string, error)
+		lock      sync.RWMutex
+		endpoints []*failoverEndpoint
+		next      int
+		closeCh   chan struct{}
+		closeOnce sync.Once
+		closeWG   sync.WaitGroup
+	}
+
+	failoverEndpoint struct {
+		hostPort string
+		service  workflowserviceclient.Interface
+		healthy  bool
+	}
+)
+
+var _ FailoverWorkflowService = (*failoverServiceWrapper)(nil)
+
+// NewFailoverWorkflowService creates a workflow service client spreading calls over several frontend hosts and
+// failing over to another host when one of them is unreachable.
+func NewFailoverWorkflowService(options FailoverServiceOptions) (FailoverWorkflowService, error) {
+	return newFailoverServiceWrapper(options, net.LookupHost)
+}
+
+func newFailoverServiceWrapper(options FailoverServiceOptions, lookup func(host string) ([]string, error)) (*failoverServiceWrapper, error) {
+	if options.NewService == nil {
+		return nil, errors.New("NewService is required")
+	}
+	if len(options.HostPorts) == 0 && options.DNSName == "" {
+		return nil, errors.New("either HostPorts or DNSName is required")
+	}
+	if options.ResolveInterval <= 0 {
+		options.ResolveInterval = defaultFailoverResolveInterval
+	}
+	if options.HealthCheckInterval <= 0 {
+		options.HealthCheckInterval = defaultFailoverHealthCheckInterval
+	}
+	if options.HealthCheck == nil {
+		options.HealthCheck = defaultFailoverHealthCheck
+	}
+	logger := options.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	w := &failoverServiceWrapper{
+		options: options,
+		logger:  logger,
+		lookup:  lookup,
+		closeCh: make(chan struct{}),
+	}
+	if err := w.resolve(); err != nil {
+		return nil, err
+	}
+
+	w.closeWG.Add(1)
+	go w.healthCheckLoop()
+	if options.DNSName != "" {
+		w.closeWG.Add(1)
+		go w.resolveLoop()
+	}
+	return w, nil
+}
+
+func defaultFailoverHealthCheck(ctx context.Context, service workflowserviceclient.Interface) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultFailoverHealthCheckTimeout)
+	defer cancel()
+	_, err := service.GetClusterInfo(ctx, getYarpcCallOptions(FeatureFlags{})...)
+	return err
+}
+
+// Close stops the health checks and the DNS resolution.
+func (w *failoverServiceWrapper) Close() {
+	w.closeOnce.Do(func() {
+		close(w.closeCh)
+	})
+	w.closeWG.Wait()
+}
+
+// resolve updates the endpoints to the configured hosts, keeping the state of the hosts already known.
+func (w *failoverServiceWrapper) resolve() error {
+	hostPorts := w.options.HostPorts
+	if w.options.DNSName != "" {
+		hosts, err := w.lookup(w.options.DNSName)
+		if err != nil {
+			return fmt.Errorf("unable to resolve %v: %v", w.options.DNSName, err)
+		}
+		hostPorts = make([]string, 0, len(hosts))
+		for _, host := range hosts {
+			hostPorts = append(hostPorts, net.JoinHostPort(host, strconv.Itoa(w.options.DNSPort)))
+		}
+	}
+	if len(hostPorts) == 0 {
+		return fmt.Errorf("no frontend host found for %v", w.options.DNSName)
+	}
+
+	w.lock.RLock()
+	known := make(map[string]*failoverEndpoint, len(w.endpoints))
+	for _, e := range w.endpoints {
+		known[e.hostPort] = e
+	}
+	w.lock.RUnlock()
+
+	endpoints := make([]*failoverEndpoint, 0, len(hostPorts))
+	for _, hostPort := range hostPorts {
+		if e, ok := known[hostPort]; ok {
+			endpoints = append(endpoints, e)
+			continue
+		}
+		service, err := w.options.NewService(hostPort)
+		if err != nil {
+			return fmt.Errorf("unable to create service client for %v: %v", hostPort, err)
+		}
+		endpoints = append(endpoints, &failoverEndpoint{hostPort: hostPort, service: service, healthy: true})
+	}
+
+	w.lock.Lock()
+	w.endpoints = endpoints
+	w.lock.Unlock()
+	return nil
+}
+
+func (w *failoverServiceWrapper) resolveLoop() {
+	defer w.closeWG.Done()
+	ticker := time.NewTicker(w.options.ResolveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case <-ticker.C:
+			if err := w.resolve(); err != nil {
+				// keep using the hosts resolved last time
+				w.logger.Warn("Failed to resolve frontend hosts.", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (w *failoverServiceWrapper) healthCheckLoop() {
+	defer w.closeWG.Done()
+	ticker := time.NewTicker(w.options.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case <-ticker.C:
+			w.checkUnhealthyEndpoints()
+		}
+	}
+}
+
+func (w *failoverServiceWrapper) checkUnhealthyEndpoints() {
+	w.lock.RLock()
+	var unhealthy []*failoverEndpoint
+	for _, e := range w.endpoints {
+		if !e.healthy {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	w.lock.RUnlock()
+
+	for _, e := range unhealthy {
+		if err := w.options.HealthCheck(context.Background(), e.service); err != nil {
+			continue
+		}
+		w.lock.Lock()
+		e.healthy = true
+		w.lock.Unlock()
+		w.logger.Info("Frontend host is healthy again.", zap.String("HostPort", e.hostPort))
+	}
+}
+
+// pick returns the endpoint the next call is sent to: the healthy endpoints are used round robin, all of them
+// when none is healthy.
+func (w *failoverServiceWrapper) pick() *failoverEndpoint {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for i := 0; i < len(w.endpoints); i++ {
+		e := w.endpoints[(w.next+i)%len(w.endpoints)]
+		if e.healthy {
+			w.next = (w.next + i + 1) % len(w.endpoints)
+			return e
+		}
+	}
+	e := w.endpoints[w.next%len(w.endpoints)]
+	w.next = (w.next + 1) % len(w.endpoints)
+	return e
+}
+
+// report marks the endpoint unhealthy when the call failed to reach it, the retry of the call then goes to another
+// host.
+func (w *failoverServiceWrapper) report(ctx context.Context, e *failoverEndpoint, err error) {
+	if !isConnectionError(ctx, err) {
+		return
+	}
+	w.lock.Lock()
+	wasHealthy := e.healthy
+	e.healthy = false
+	w.lock.Unlock()
+	if wasHealthy {
+		w.logger.Warn("Frontend host is unhealthy, failing over to other hosts.",
+			zap.String("HostPort", e.hostPort), zap.Error(err))
+	}
+}
+
+// isConnectionError returns whether err means the host couldn't be reached, rather than the request failed.
+func isConnectionError(ctx context.Context, err error) bool {
+	if err == nil || !yarpcerrors.IsStatus(err) {
+		return false
+	}
+	switch yarpcerrors.FromError(err).Code() {
+	case yarpcerrors.CodeUnavailable:
+		return true
+	case yarpcerrors.CodeDeadlineExceeded:
+		// the host didn't answer in time, unless the caller's own deadline is the one which expired
+		return ctx.Err() == nil
+	default:
+		return false
+	}
+}
+
+func (w *failoverServiceWrapper) DeprecateDomain(ctx context.Context, request *s.DeprecateDomainRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.DeprecateDomain(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) ListDomains(ctx context.Context, request *s.ListDomainsRequest, opts ...yarpc.CallOption) (*s.ListDomainsResponse, error) {
+	e := w.pick()
+	resp, err := e.service.ListDomains(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) DescribeDomain(ctx context.Context, request *s.DescribeDomainRequest, opts ...yarpc.CallOption) (*s.DescribeDomainResponse, error) {
+	e := w.pick()
+	resp, err := e.service.DescribeDomain(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) DescribeWorkflowExecution(ctx context.Context, request *s.DescribeWorkflowExecutionRequest, opts ...yarpc.CallOption) (*s.DescribeWorkflowExecutionResponse, error) {
+	e := w.pick()
+	resp, err := e.service.DescribeWorkflowExecution(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) GetWorkflowExecutionHistory(ctx context.Context, request *s.GetWorkflowExecutionHistoryRequest, opts ...yarpc.CallOption) (*s.GetWorkflowExecutionHistoryResponse, error) {
+	e := w.pick()
+	resp, err := e.service.GetWorkflowExecutionHistory(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) ListClosedWorkflowExecutions(ctx context.Context, request *s.ListClosedWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*s.ListClosedWorkflowExecutionsResponse, error) {
+	e := w.pick()
+	resp, err := e.service.ListClosedWorkflowExecutions(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) ListOpenWorkflowExecutions(ctx context.Context, request *s.ListOpenWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*s.ListOpenWorkflowExecutionsResponse, error) {
+	e := w.pick()
+	resp, err := e.service.ListOpenWorkflowExecutions(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) ListWorkflowExecutions(ctx context.Context, request *s.ListWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*s.ListWorkflowExecutionsResponse, error) {
+	e := w.pick()
+	resp, err := e.service.ListWorkflowExecutions(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) ListArchivedWorkflowExecutions(ctx context.Context, request *s.ListArchivedWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*s.ListArchivedWorkflowExecutionsResponse, error) {
+	e := w.pick()
+	resp, err := e.service.ListArchivedWorkflowExecutions(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) ScanWorkflowExecutions(ctx context.Context, request *s.ListWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*s.ListWorkflowExecutionsResponse, error) {
+	e := w.pick()
+	resp, err := e.service.ScanWorkflowExecutions(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) CountWorkflowExecutions(ctx context.Context, request *s.CountWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*s.CountWorkflowExecutionsResponse, error) {
+	e := w.pick()
+	resp, err := e.service.CountWorkflowExecutions(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) PollForActivityTask(ctx context.Context, request *s.PollForActivityTaskRequest, opts ...yarpc.CallOption) (*s.PollForActivityTaskResponse, error) {
+	e := w.pick()
+	resp, err := e.service.PollForActivityTask(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) PollForDecisionTask(ctx context.Context, request *s.PollForDecisionTaskRequest, opts ...yarpc.CallOption) (*s.PollForDecisionTaskResponse, error) {
+	e := w.pick()
+	resp, err := e.service.PollForDecisionTask(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) RecordActivityTaskHeartbeat(ctx context.Context, request *s.RecordActivityTaskHeartbeatRequest, opts ...yarpc.CallOption) (*s.RecordActivityTaskHeartbeatResponse, error) {
+	e := w.pick()
+	resp, err := e.service.RecordActivityTaskHeartbeat(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) RecordActivityTaskHeartbeatByID(ctx context.Context, request *s.RecordActivityTaskHeartbeatByIDRequest, opts ...yarpc.CallOption) (*s.RecordActivityTaskHeartbeatResponse, error) {
+	e := w.pick()
+	resp, err := e.service.RecordActivityTaskHeartbeatByID(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) RegisterDomain(ctx context.Context, request *s.RegisterDomainRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RegisterDomain(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) RequestCancelWorkflowExecution(ctx context.Context, request *s.RequestCancelWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RequestCancelWorkflowExecution(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) RespondActivityTaskCanceled(ctx context.Context, request *s.RespondActivityTaskCanceledRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RespondActivityTaskCanceled(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) RespondActivityTaskCompleted(ctx context.Context, request *s.RespondActivityTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RespondActivityTaskCompleted(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) RespondActivityTaskFailed(ctx context.Context, request *s.RespondActivityTaskFailedRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RespondActivityTaskFailed(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) RespondActivityTaskCanceledByID(ctx context.Context, request *s.RespondActivityTaskCanceledByIDRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RespondActivityTaskCanceledByID(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) RespondActivityTaskCompletedByID(ctx context.Context, request *s.RespondActivityTaskCompletedByIDRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RespondActivityTaskCompletedByID(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) RespondActivityTaskFailedByID(ctx context.Context, request *s.RespondActivityTaskFailedByIDRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RespondActivityTaskFailedByID(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) RespondDecisionTaskCompleted(ctx context.Context, request *s.RespondDecisionTaskCompletedRequest, opts ...yarpc.CallOption) (*s.RespondDecisionTaskCompletedResponse, error) {
+	e := w.pick()
+	resp, err := e.service.RespondDecisionTaskCompleted(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) RespondDecisionTaskFailed(ctx context.Context, request *s.RespondDecisionTaskFailedRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RespondDecisionTaskFailed(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) SignalWorkflowExecution(ctx context.Context, request *s.SignalWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.SignalWorkflowExecution(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) SignalWithStartWorkflowExecution(ctx context.Context, request *s.SignalWithStartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*s.StartWorkflowExecutionResponse, error) {
+	e := w.pick()
+	resp, err := e.service.SignalWithStartWorkflowExecution(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) StartWorkflowExecution(ctx context.Context, request *s.StartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*s.StartWorkflowExecutionResponse, error) {
+	e := w.pick()
+	resp, err := e.service.StartWorkflowExecution(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) TerminateWorkflowExecution(ctx context.Context, request *s.TerminateWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.TerminateWorkflowExecution(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) ResetWorkflowExecution(ctx context.Context, request *s.ResetWorkflowExecutionRequest, opts ...yarpc.CallOption) (*s.ResetWorkflowExecutionResponse, error) {
+	e := w.pick()
+	resp, err := e.service.ResetWorkflowExecution(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) UpdateDomain(ctx context.Context, request *s.UpdateDomainRequest, opts ...yarpc.CallOption) (*s.UpdateDomainResponse, error) {
+	e := w.pick()
+	resp, err := e.service.UpdateDomain(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) QueryWorkflow(ctx context.Context, request *s.QueryWorkflowRequest, opts ...yarpc.CallOption) (*s.QueryWorkflowResponse, error) {
+	e := w.pick()
+	resp, err := e.service.QueryWorkflow(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) ResetStickyTaskList(ctx context.Context, request *s.ResetStickyTaskListRequest, opts ...yarpc.CallOption) (*s.ResetStickyTaskListResponse, error) {
+	e := w.pick()
+	resp, err := e.service.ResetStickyTaskList(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) DescribeTaskList(ctx context.Context, request *s.DescribeTaskListRequest, opts ...yarpc.CallOption) (*s.DescribeTaskListResponse, error) {
+	e := w.pick()
+	resp, err := e.service.DescribeTaskList(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) RespondQueryTaskCompleted(ctx context.Context, request *s.RespondQueryTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RespondQueryTaskCompleted(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
+
+func (w *failoverServiceWrapper) GetSearchAttributes(ctx context.Context, opts ...yarpc.CallOption) (*s.GetSearchAttributesResponse, error) {
+	e := w.pick()
+	resp, err := e.service.GetSearchAttributes(ctx, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) ListTaskListPartitions(ctx context.Context, request *s.ListTaskListPartitionsRequest, opts ...yarpc.CallOption) (*s.ListTaskListPartitionsResponse, error) {
+	e := w.pick()
+	resp, err := e.service.ListTaskListPartitions(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) GetClusterInfo(ctx context.Context, opts ...yarpc.CallOption) (*s.ClusterInfo, error) {
+	e := w.pick()
+	resp, err := e.service.GetClusterInfo(ctx, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) GetTaskListsByDomain(ctx context.Context, request *s.GetTaskListsByDomainRequest, opts ...yarpc.CallOption) (*s.GetTaskListsByDomainResponse, error) {
+	e := w.pick()
+	resp, err := e.service.GetTaskListsByDomain(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return resp, err
+}
+
+func (w *failoverServiceWrapper) RefreshWorkflowTasks(ctx context.Context, request *s.RefreshWorkflowTasksRequest, opts ...yarpc.CallOption) error {
+	e := w.pick()
+	err := e.service.RefreshWorkflowTasks(ctx, request, opts...)
+	w.report(ctx, e, err)
+	return err
+}
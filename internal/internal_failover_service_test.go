@@ -0,0 +1,161 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcerrors"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func TestFailoverWorkflowService(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	services := map[string]*workflowservicetest.MockClient{
+		"host1:7933": workflowservicetest.NewMockClient(mockCtrl),
+		"host2:7933": workflowservicetest.NewMockClient(mockCtrl),
+	}
+	healthy := map[string]bool{}
+	service, err := newFailoverServiceWrapper(FailoverServiceOptions{
+		NewService: func(hostPort string) (workflowserviceclient.Interface, error) {
+			return services[hostPort], nil
+		},
+		HostPorts:           []string{"host1:7933", "host2:7933"},
+		HealthCheckInterval: time.Hour,
+		HealthCheck: func(ctx context.Context, service workflowserviceclient.Interface) error {
+			for hostPort, mock := range services {
+				if mock == service && healthy[hostPort] {
+					return nil
+				}
+			}
+			return errors.New("unhealthy")
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer service.Close()
+
+	request := &s.DescribeDomainRequest{}
+	response := &s.DescribeDomainResponse{}
+	ctx := context.Background()
+
+	// calls are sent round robin
+	services["host1:7933"].EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).Return(response, nil)
+	services["host2:7933"].EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).Return(response, nil)
+	for i := 0; i < 2; i++ {
+		resp, err := service.DescribeDomain(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, response, resp)
+	}
+
+	// an unreachable host is not used anymore, request errors don't mark the host unhealthy
+	services["host1:7933"].EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).
+		Return(nil, yarpcerrors.UnavailableErrorf("connection refused"))
+	services["host2:7933"].EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).
+		Return(nil, &s.EntityNotExistsError{})
+	_, err = service.DescribeDomain(ctx, request)
+	require.Error(t, err)
+	_, err = service.DescribeDomain(ctx, request)
+	require.Error(t, err)
+	services["host2:7933"].EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).Return(response, nil).Times(3)
+	for i := 0; i < 3; i++ {
+		_, err := service.DescribeDomain(ctx, request)
+		require.NoError(t, err)
+	}
+
+	// the host is used again once the health check passes
+	service.checkUnhealthyEndpoints()
+	services["host2:7933"].EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).Return(response, nil)
+	_, err = service.DescribeDomain(ctx, request)
+	require.NoError(t, err)
+	healthy["host1:7933"] = true
+	service.checkUnhealthyEndpoints()
+	services["host1:7933"].EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).Return(response, nil)
+	services["host2:7933"].EXPECT().DescribeDomain(gomock.Any(), request, gomock.Any()).Return(response, nil)
+	for i := 0; i < 2; i++ {
+		_, err := service.DescribeDomain(ctx, request)
+		require.NoError(t, err)
+	}
+}
+
+func TestFailoverWorkflowService_DNS(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var created []string
+	hosts := []string{"10.0.0.1", "10.0.0.2"}
+	service, err := newFailoverServiceWrapper(FailoverServiceOptions{
+		NewService: func(hostPort string) (workflowserviceclient.Interface, error) {
+			created = append(created, hostPort)
+			return workflowservicetest.NewMockClient(mockCtrl), nil
+		},
+		DNSName:             "cadence-frontend",
+		DNSPort:             7833,
+		ResolveInterval:     time.Hour,
+		HealthCheckInterval: time.Hour,
+	}, func(host string) ([]string, error) {
+		require.Equal(t, "cadence-frontend", host)
+		return hosts, nil
+	})
+	require.NoError(t, err)
+	defer service.Close()
+	require.Equal(t, []string{"10.0.0.1:7833", "10.0.0.2:7833"}, created)
+
+	// known hosts keep their client, removed hosts are dropped
+	hosts = []string{"10.0.0.2", "10.0.0.3"}
+	require.NoError(t, service.resolve())
+	require.Equal(t, []string{"10.0.0.1:7833", "10.0.0.2:7833", "10.0.0.3:7833"}, created)
+	var hostPorts []string
+	for _, e := range service.endpoints {
+		hostPorts = append(hostPorts, e.hostPort)
+	}
+	require.Equal(t, []string{"10.0.0.2:7833", "10.0.0.3:7833"}, hostPorts)
+
+	_, err = newFailoverServiceWrapper(FailoverServiceOptions{
+		NewService: service.options.NewService,
+		DNSName:    "cadence-frontend",
+	}, func(host string) ([]string, error) {
+		return nil, errors.New("no such host")
+	})
+	require.Error(t, err)
+}
+
+func TestIsConnectionError(t *testing.T) {
+	ctx := context.Background()
+	require.False(t, isConnectionError(ctx, nil))
+	require.False(t, isConnectionError(ctx, &s.BadRequestError{}))
+	require.False(t, isConnectionError(ctx, yarpcerrors.InvalidArgumentErrorf("invalid")))
+	require.True(t, isConnectionError(ctx, yarpcerrors.UnavailableErrorf("unavailable")))
+	require.True(t, isConnectionError(ctx, yarpcerrors.DeadlineExceededErrorf("timeout")))
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.False(t, isConnectionError(canceledCtx, yarpcerrors.DeadlineExceededErrorf("timeout")))
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const defaultHealthCheckTimeout = 5 * time.Second
+
+type (
+	// ConnectionOptions configures how Connect establishes the connection to the Cadence frontend.
+	ConnectionOptions struct {
+		// Optional: Skips the health check in Connect, so that the connection is only established by the first
+		// request like with NewClient.
+		// default: false, Connect fails fast when the frontend can't be reached
+		LazyConnect bool
+
+		// Optional: Timeout of the health check performed by Connect.
+		// default: 5s
+		HealthCheckTimeout time.Duration
+	}
+
+	// DNSResolutionError is returned by the health checks when the host name of the frontend can't be resolved.
+	DNSResolutionError struct {
+		cause error
+	}
+
+	// ConnectError is returned by the health checks when the frontend can't be reached or doesn't answer in time.
+	ConnectError struct {
+		cause error
+	}
+
+	// AuthenticationError is returned by the health checks when the frontend rejects the credentials of the client.
+	AuthenticationError struct {
+		cause error
+	}
+)
+
+func (e *DNSResolutionError) Error() string {
+	return fmt.Sprintf("failed to resolve the cadence frontend host: %v", e.cause)
+}
+
+// Unwrap returns the error of the health check request.
+func (e *DNSResolutionError) Unwrap() error {
+	return e.cause
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("failed to connect to the cadence frontend: %v", e.cause)
+}
+
+// Unwrap returns the error of the health check request.
+func (e *ConnectError) Unwrap() error {
+	return e.cause
+}
+
+func (e *AuthenticationError) Error() string {
+	return fmt.Sprintf("cadence frontend rejected the client credentials: %v", e.cause)
+}
+
+// Unwrap returns the error of the health check request.
+func (e *AuthenticationError) Unwrap() error {
+	return e.cause
+}
+
+// Connect creates a workflow client like NewClient and, unless options.ConnectionOptions.LazyConnect is set,
+// health checks the frontend so that an unreachable or misconfigured frontend fails at creation, see HealthCheck
+// for the errors it can return.
+func Connect(ctx context.Context, service workflowserviceclient.Interface, domain string, options *ClientOptions) (Client, error) {
+	client := NewClient(service, domain, options)
+	var connectionOptions ConnectionOptions
+	if options != nil {
+		connectionOptions = options.ConnectionOptions
+	}
+	if connectionOptions.LazyConnect {
+		return client, nil
+	}
+	timeout := connectionOptions.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := client.HealthCheck(ctx); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// healthCheck pings the frontend once, without retries, and classifies the failure.
+func healthCheck(ctx context.Context, service workflowserviceclient.Interface, featureFlags FeatureFlags) error {
+	tchCtx, cancel, opt := newChannelContext(ctx, featureFlags)
+	defer cancel()
+	_, err := service.GetClusterInfo(tchCtx, opt...)
+	return toConnectionError(err)
+}
+
+// toConnectionError wraps err into DNSResolutionError, ConnectError or AuthenticationError when it is one of those
+// failures, other errors are returned as is.
+func toConnectionError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return &DNSResolutionError{cause: err}
+	}
+	if _, ok := err.(*s.AccessDeniedError); ok {
+		return &AuthenticationError{cause: err}
+	}
+	var netErr *net.OpError
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return &ConnectError{cause: err}
+	}
+	if !yarpcerrors.IsStatus(err) {
+		return err
+	}
+	// transports report the underlying network errors as messages of yarpc statuses
+	status := yarpcerrors.FromError(err)
+	switch status.Code() {
+	case yarpcerrors.CodeUnauthenticated, yarpcerrors.CodePermissionDenied:
+		return &AuthenticationError{cause: err}
+	case yarpcerrors.CodeUnavailable, yarpcerrors.CodeDeadlineExceeded:
+		if strings.Contains(status.Message(), "no such host") {
+			return &DNSResolutionError{cause: err}
+		}
+		return &ConnectError{cause: err}
+	default:
+		return err
+	}
+}
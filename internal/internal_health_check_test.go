@@ -0,0 +1,99 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcerrors"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	s "go.uber.org/cadence/.gen/go/shared"
+)
+
+func TestToConnectionError(t *testing.T) {
+	tests := []struct {
+		msg      string
+		err      error
+		expected interface{}
+	}{
+		{"dns error", &net.DNSError{Err: "no such host", Name: "cadence"}, &DNSResolutionError{}},
+		{"dns error in yarpc status", yarpcerrors.UnavailableErrorf("dial tcp: lookup cadence: no such host"), &DNSResolutionError{}},
+		{"connection refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, &ConnectError{}},
+		{"unavailable", yarpcerrors.UnavailableErrorf("connection refused"), &ConnectError{}},
+		{"deadline exceeded", yarpcerrors.DeadlineExceededErrorf("timeout"), &ConnectError{}},
+		{"context deadline exceeded", context.DeadlineExceeded, &ConnectError{}},
+		{"unauthenticated", yarpcerrors.UnauthenticatedErrorf("invalid token"), &AuthenticationError{}},
+		{"permission denied", yarpcerrors.PermissionDeniedErrorf("denied"), &AuthenticationError{}},
+		{"access denied", &s.AccessDeniedError{Message: "denied"}, &AuthenticationError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			err := toConnectionError(tt.err)
+			require.IsType(t, tt.expected, err)
+			require.True(t, errors.Is(err, tt.err))
+		})
+	}
+
+	other := &s.InternalServiceError{Message: "internal"}
+	require.Equal(t, other, toConnectionError(other))
+	require.NoError(t, toConnectionError(nil))
+}
+
+func TestHealthCheck(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	client := NewClient(service, domain, nil)
+
+	service.EXPECT().GetClusterInfo(gomock.Any(), callOptions()...).Return(&s.ClusterInfo{}, nil)
+	require.NoError(t, client.HealthCheck(context.Background()))
+
+	service.EXPECT().GetClusterInfo(gomock.Any(), callOptions()...).Return(nil, yarpcerrors.UnavailableErrorf("connection refused"))
+	err := client.HealthCheck(context.Background())
+	var connectErr *ConnectError
+	require.True(t, errors.As(err, &connectErr))
+}
+
+func TestConnect(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(mockCtrl)
+
+	service.EXPECT().GetClusterInfo(gomock.Any(), callOptions()...).Return(&s.ClusterInfo{}, nil)
+	client, err := Connect(context.Background(), service, domain, nil)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	service.EXPECT().GetClusterInfo(gomock.Any(), callOptions()...).Return(nil, yarpcerrors.UnauthenticatedErrorf("invalid token"))
+	client, err = Connect(context.Background(), service, domain, &ClientOptions{})
+	require.Nil(t, client)
+	var authErr *AuthenticationError
+	require.True(t, errors.As(err, &authErr))
+
+	// no call is expected with lazy connect
+	client, err = Connect(context.Background(), service, domain, &ClientOptions{ConnectionOptions: ConnectionOptions{LazyConnect: true}})
+	require.NoError(t, err)
+	require.NotNil(t, client)
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cadencePackagePrefix = "go.uber.org/cadence/"
+
+type (
+	// ReplayCoverage records the workflow code paths exercised by the histories replayed by a WorkflowReplayer,
+	// see ReplayOptions.Coverage. A code path is identified by the location in the workflow code of every call to
+	// the workflow APIs that interact with the history, like ExecuteActivity, NewTimer, GetVersion or
+	// GetSignalChannel, so the coverage tells which branches of the workflow code the replayed histories went
+	// through. It is safe to use concurrently by multiple replayers.
+	ReplayCoverage struct {
+		lock      sync.Mutex
+		histories []*ReplayHistoryCoverage
+	}
+
+	// ReplayHistoryCoverage is the coverage of a single replayed history.
+	ReplayHistoryCoverage struct {
+		WorkflowType string
+		WorkflowID   string
+		RunID        string
+		// Locations in the workflow code reached while replaying the history, ordered by file and line.
+		Locations []ReplayCodeLocation
+
+		locations map[ReplayCodeLocation]int
+	}
+
+	// ReplayCodeLocation is a location in the workflow code that was reached during replay.
+	ReplayCodeLocation struct {
+		// Function is the fully qualified name of the function, e.g. "github.com/org/repo/workflows.OrderWorkflow".
+		Function string
+		// File is the absolute path of the source file.
+		File string
+		Line int
+		// Count is the number of times the location was reached.
+		Count int
+	}
+
+	replayCoverageInterceptorFactory struct {
+		history *ReplayHistoryCoverage
+		lock    *sync.Mutex
+	}
+
+	replayCoverageInterceptor struct {
+		WorkflowInterceptorBase
+		factory *replayCoverageInterceptorFactory
+	}
+)
+
+// NewReplayCoverage creates a ReplayCoverage to set on ReplayOptions.Coverage.
+func NewReplayCoverage() *ReplayCoverage {
+	return &ReplayCoverage{}
+}
+
+// Histories returns the coverage of every history replayed so far, in replay order.
+func (c *ReplayCoverage) Histories() []ReplayHistoryCoverage {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result := make([]ReplayHistoryCoverage, 0, len(c.histories))
+	for _, h := range c.histories {
+		result = append(result, ReplayHistoryCoverage{
+			WorkflowType: h.WorkflowType,
+			WorkflowID:   h.WorkflowID,
+			RunID:        h.RunID,
+			Locations:    h.sortedLocations(),
+		})
+	}
+	return result
+}
+
+// WriteProfile writes the locations reached by all the replayed histories in the Go coverage profile format, in
+// count mode, with a single line block per location. The profile can be merged with profiles of go test -coverprofile
+// or inspected with go tool cover -html, as long as the workflow source files can be found through GOPATH or modules.
+func (c *ReplayCoverage) WriteProfile(w io.Writer) error {
+	c.lock.Lock()
+	counts := make(map[ReplayCodeLocation]int)
+	for _, h := range c.histories {
+		for l, count := range h.locations {
+			counts[l] += count
+		}
+	}
+	c.lock.Unlock()
+
+	locations := make([]ReplayCodeLocation, 0, len(counts))
+	for l, count := range counts {
+		l.Count = count
+		locations = append(locations, l)
+	}
+	sortReplayCodeLocations(locations)
+
+	if _, err := fmt.Fprintln(w, "mode: count"); err != nil {
+		return err
+	}
+	for _, l := range locations {
+		if _, err := fmt.Fprintf(w, "%s:%d.1,%d.1 1 %d\n", coverageProfileFileName(l), l.Line, l.Line+1, l.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ReplayCoverage) newInterceptorFactory(workflowType string, execution WorkflowExecution) WorkflowInterceptorFactory {
+	history := &ReplayHistoryCoverage{
+		WorkflowType: workflowType,
+		WorkflowID:   execution.ID,
+		RunID:        execution.RunID,
+		locations:    make(map[ReplayCodeLocation]int),
+	}
+	c.lock.Lock()
+	c.histories = append(c.histories, history)
+	c.lock.Unlock()
+	return &replayCoverageInterceptorFactory{history: history, lock: &c.lock}
+}
+
+func (h *ReplayHistoryCoverage) sortedLocations() []ReplayCodeLocation {
+	locations := make([]ReplayCodeLocation, 0, len(h.locations))
+	for l, count := range h.locations {
+		l.Count = count
+		locations = append(locations, l)
+	}
+	sortReplayCodeLocations(locations)
+	return locations
+}
+
+func sortReplayCodeLocations(locations []ReplayCodeLocation) {
+	sort.Slice(locations, func(i, j int) bool {
+		if locations[i].File != locations[j].File {
+			return locations[i].File < locations[j].File
+		}
+		return locations[i].Line < locations[j].Line
+	})
+}
+
+// coverageProfileFileName returns the file name of the location as go test -coverprofile reports it, i.e. the import
+// path of the package followed by the base name of the file.
+func coverageProfileFileName(l ReplayCodeLocation) string {
+	pkg := l.Function
+	lastSlash := strings.LastIndex(pkg, "/")
+	if dot := strings.Index(pkg[lastSlash+1:], "."); dot >= 0 {
+		pkg = pkg[:lastSlash+1+dot]
+	}
+	file := l.File
+	if slash := strings.LastIndex(file, "/"); slash >= 0 {
+		file = file[slash+1:]
+	}
+	return pkg + "/" + file
+}
+
+func (f *replayCoverageInterceptorFactory) NewInterceptor(_ *WorkflowInfo, next WorkflowInterceptor) WorkflowInterceptor {
+	return &replayCoverageInterceptor{WorkflowInterceptorBase: WorkflowInterceptorBase{Next: next}, factory: f}
+}
+
+// record attributes the intercepted call to the first caller outside of the cadence client, the interceptor is
+// the head of the chain so the frames in between all belong to the workflow APIs.
+func (f *replayCoverageInterceptorFactory) record() {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		isClientFrame := strings.HasPrefix(frame.Function, cadencePackagePrefix) && !strings.HasSuffix(frame.File, "_test.go")
+		if !isClientFrame && !strings.HasPrefix(frame.Function, "runtime.") {
+			f.lock.Lock()
+			f.history.locations[ReplayCodeLocation{Function: frame.Function, File: frame.File, Line: frame.Line}]++
+			f.lock.Unlock()
+			return
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+func (i *replayCoverageInterceptor) ExecuteActivity(ctx Context, activityType string, args ...interface{}) Future {
+	i.factory.record()
+	return i.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+func (i *replayCoverageInterceptor) ExecuteLocalActivity(ctx Context, activityType string, args ...interface{}) Future {
+	i.factory.record()
+	return i.Next.ExecuteLocalActivity(ctx, activityType, args...)
+}
+
+func (i *replayCoverageInterceptor) ExecuteChildWorkflow(ctx Context, childWorkflowType string, args ...interface{}) ChildWorkflowFuture {
+	i.factory.record()
+	return i.Next.ExecuteChildWorkflow(ctx, childWorkflowType, args...)
+}
+
+func (i *replayCoverageInterceptor) NewTimer(ctx Context, d time.Duration) Future {
+	i.factory.record()
+	return i.Next.NewTimer(ctx, d)
+}
+
+func (i *replayCoverageInterceptor) Sleep(ctx Context, d time.Duration) (err error) {
+	i.factory.record()
+	return i.Next.Sleep(ctx, d)
+}
+
+func (i *replayCoverageInterceptor) RequestCancelExternalWorkflow(ctx Context, workflowID, runID string) Future {
+	i.factory.record()
+	return i.Next.RequestCancelExternalWorkflow(ctx, workflowID, runID)
+}
+
+func (i *replayCoverageInterceptor) SignalExternalWorkflow(ctx Context, workflowID, runID, signalName string, arg interface{}) Future {
+	i.factory.record()
+	return i.Next.SignalExternalWorkflow(ctx, workflowID, runID, signalName, arg)
+}
+
+func (i *replayCoverageInterceptor) UpsertSearchAttributes(ctx Context, attributes map[string]interface{}) error {
+	i.factory.record()
+	return i.Next.UpsertSearchAttributes(ctx, attributes)
+}
+
+func (i *replayCoverageInterceptor) GetSignalChannel(ctx Context, signalName string) Channel {
+	i.factory.record()
+	return i.Next.GetSignalChannel(ctx, signalName)
+}
+
+func (i *replayCoverageInterceptor) SideEffect(ctx Context, f func(ctx Context) interface{}) Value {
+	i.factory.record()
+	return i.Next.SideEffect(ctx, f)
+}
+
+func (i *replayCoverageInterceptor) MutableSideEffect(ctx Context, id string, f func(ctx Context) interface{}, equals func(a, b interface{}) bool) Value {
+	i.factory.record()
+	return i.Next.MutableSideEffect(ctx, id, f, equals)
+}
+
+func (i *replayCoverageInterceptor) GetVersion(ctx Context, changeID string, minSupported, maxSupported Version) Version {
+	i.factory.record()
+	return i.Next.GetVersion(ctx, changeID, minSupported, maxSupported)
+}
+
+func (i *replayCoverageInterceptor) GetConfigValue(ctx Context, key string) Value {
+	i.factory.record()
+	return i.Next.GetConfigValue(ctx, key)
+}
+
+func (i *replayCoverageInterceptor) SetQueryHandler(ctx Context, queryType string, handler interface{}) error {
+	i.factory.record()
+	return i.Next.SetQueryHandler(ctx, queryType, handler)
+}
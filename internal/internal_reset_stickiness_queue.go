@@ -0,0 +1,157 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/metrics"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultStickyCacheResetsPerSecond = 100
+	defaultStickyCacheResetQueueSize  = defaultStickyCacheSize
+)
+
+type (
+	// resetStickinessQueue issues the ResetStickyTaskList calls for the executions evicted from the sticky cache in
+	// the background at a bounded rate, so that a mass eviction does not turn into a burst of calls to the frontend.
+	// The reset only spares the next decision task of an execution the sticky schedule to start timeout, so requests
+	// that do not fit into the queue are dropped.
+	resetStickinessQueue struct {
+		service      workflowserviceclient.Interface
+		featureFlags FeatureFlags
+		limiter      *rate.Limiter
+		maxSize      int
+		logger       *zap.Logger
+		metricsScope *metrics.TaggedScope
+		stopCh       <-chan struct{}
+
+		sync.Mutex
+		pending  []*s.ResetStickyTaskListRequest
+		queued   map[string]struct{} // run IDs of the pending requests
+		notifyCh chan struct{}
+	}
+)
+
+func newResetStickinessQueue(
+	service workflowserviceclient.Interface,
+	params workerExecutionParameters,
+	stopCh <-chan struct{},
+) *resetStickinessQueue {
+	resetsPerSecond := params.StickyCacheResetsPerSecond
+	if resetsPerSecond <= 0 {
+		resetsPerSecond = defaultStickyCacheResetsPerSecond
+	}
+	return &resetStickinessQueue{
+		service:      service,
+		featureFlags: params.FeatureFlags,
+		limiter:      rate.NewLimiter(rate.Limit(resetsPerSecond), 1),
+		maxSize:      defaultStickyCacheResetQueueSize,
+		logger:       params.Logger,
+		metricsScope: metrics.NewTaggedScope(params.MetricsScope),
+		stopCh:       stopCh,
+		queued:       make(map[string]struct{}),
+		notifyCh:     make(chan struct{}, 1),
+	}
+}
+
+// add queues the request unless the execution already has a pending one or the queue is full. It never blocks.
+func (q *resetStickinessQueue) add(request *s.ResetStickyTaskListRequest) {
+	runID := request.Execution.GetRunId()
+	q.Lock()
+	if _, ok := q.queued[runID]; ok {
+		q.Unlock()
+		return
+	}
+	if len(q.pending) >= q.maxSize {
+		q.Unlock()
+		q.metricsScope.Counter(metrics.StickyCacheResetDropped).Inc(1)
+		return
+	}
+	q.pending = append(q.pending, request)
+	q.queued[runID] = struct{}{}
+	backlog := len(q.pending)
+	q.Unlock()
+
+	q.metricsScope.Gauge(metrics.StickyCacheResetBacklog).Update(float64(backlog))
+	select {
+	case q.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// run issues the queued requests until the worker stops.
+func (q *resetStickinessQueue) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-q.stopCh
+		cancel()
+	}()
+
+	for {
+		request, ok := q.next()
+		if !ok {
+			select {
+			case <-q.notifyCh:
+				continue
+			case <-q.stopCh:
+				return
+			}
+		}
+		if err := q.limiter.Wait(ctx); err != nil {
+			return
+		}
+		q.reset(ctx, request)
+	}
+}
+
+func (q *resetStickinessQueue) next() (*s.ResetStickyTaskListRequest, bool) {
+	q.Lock()
+	defer q.Unlock()
+	if len(q.pending) == 0 {
+		return nil, false
+	}
+	request := q.pending[0]
+	q.pending[0] = nil
+	q.pending = q.pending[1:]
+	delete(q.queued, request.Execution.GetRunId())
+	q.metricsScope.Gauge(metrics.StickyCacheResetBacklog).Update(float64(len(q.pending)))
+	return request, true
+}
+
+func (q *resetStickinessQueue) reset(ctx context.Context, request *s.ResetStickyTaskListRequest) {
+	tchCtx, cancel, opt := newChannelContext(ctx, q.featureFlags)
+	defer cancel()
+	q.metricsScope.Counter(metrics.StickyCacheEvict).Inc(1)
+	if _, err := q.service.ResetStickyTaskList(tchCtx, request, opt...); err != nil {
+		q.logger.Warn("ResetStickyTaskList failed",
+			zap.String(tagWorkflowID, request.Execution.GetWorkflowId()),
+			zap.String(tagRunID, request.Execution.GetRunId()),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally/v4"
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestResetStickyTaskListRequest(runID string) *s.ResetStickyTaskListRequest {
+	return &s.ResetStickyTaskListRequest{
+		Domain: common.StringPtr(testDomain),
+		Execution: &s.WorkflowExecution{
+			WorkflowId: common.StringPtr("wid-" + runID),
+			RunId:      common.StringPtr(runID),
+		},
+	}
+}
+
+func TestResetStickinessQueue(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	testScope := tally.NewTestScope("", nil)
+	stopC := make(chan struct{})
+	queue := newResetStickinessQueue(service, workerExecutionParameters{
+		Logger:                     zaptest.NewLogger(t),
+		MetricsScope:               testScope,
+		StickyCacheResetsPerSecond: 1000,
+	}, stopC)
+	queue.maxSize = 3
+
+	var mutex sync.Mutex
+	var resetRunIDs []string
+	var wg sync.WaitGroup
+	wg.Add(3)
+	service.EXPECT().ResetStickyTaskList(gomock.Any(), gomock.Any(), callOptions()...).Return(&s.ResetStickyTaskListResponse{}, nil).Times(3).Do(
+		func(_ interface{}, request *s.ResetStickyTaskListRequest, _ ...interface{}) {
+			mutex.Lock()
+			resetRunIDs = append(resetRunIDs, request.Execution.GetRunId())
+			mutex.Unlock()
+			wg.Done()
+		})
+
+	// the requests are queued before the queue runs, a duplicate is ignored and the last one does not fit
+	queue.add(newTestResetStickyTaskListRequest("run-1"))
+	queue.add(newTestResetStickyTaskListRequest("run-2"))
+	queue.add(newTestResetStickyTaskListRequest("run-1"))
+	queue.add(newTestResetStickyTaskListRequest("run-3"))
+	queue.add(newTestResetStickyTaskListRequest("run-4"))
+	snapshot := testScope.Snapshot()
+	assert.Equal(t, float64(3), snapshot.Gauges()[metrics.StickyCacheResetBacklog+"+"].Value())
+	assert.Equal(t, int64(1), snapshot.Counters()[metrics.StickyCacheResetDropped+"+"].Value())
+
+	go queue.run()
+	wg.Wait()
+	close(stopC)
+	mutex.Lock()
+	assert.Equal(t, []string{"run-1", "run-2", "run-3"}, resetRunIDs)
+	mutex.Unlock()
+	assert.Eventually(t, func() bool {
+		return testScope.Snapshot().Gauges()[metrics.StickyCacheResetBacklog+"+"].Value() == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(3), testScope.Snapshot().Counters()[metrics.StickyCacheEvict+"+"].Value())
+}
+
+func TestResetStickinessQueueRateLimit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	stopC := make(chan struct{})
+	defer close(stopC)
+	queue := newResetStickinessQueue(service, workerExecutionParameters{
+		Logger:                     zaptest.NewLogger(t),
+		MetricsScope:               tally.NoopScope,
+		StickyCacheResetsPerSecond: 20,
+	}, stopC)
+
+	var wg sync.WaitGroup
+	wg.Add(5)
+	service.EXPECT().ResetStickyTaskList(gomock.Any(), gomock.Any(), callOptions()...).Return(&s.ResetStickyTaskListResponse{}, nil).Times(5).Do(
+		func(_ ...interface{}) { wg.Done() })
+	for _, runID := range []string{"run-1", "run-2", "run-3", "run-4", "run-5"} {
+		queue.add(newTestResetStickyTaskListRequest(runID))
+	}
+
+	start := time.Now()
+	go queue.run()
+	wg.Wait()
+	// the first call uses the burst of the limiter, the other four wait 50ms each
+	assert.True(t, time.Since(start) >= 190*time.Millisecond)
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+// Portions of the Software are attributed to Copyright (c) 2020 Temporal Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/robfig/cron"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/backoff"
+)
+
+// Assert that structs do indeed implement the interfaces
+var _ ScheduleClient = (*scheduleClient)(nil)
+
+const (
+	schedulePausedReasonPrefix = "schedule paused"
+
+	// maxScheduleBackfillRuns caps the number of runs a single BackfillSchedule call may start.
+	maxScheduleBackfillRuns = 1000
+)
+
+type (
+	// scheduleClient implements ScheduleClient on top of cron workflows.
+	scheduleClient struct {
+		workflowClient *workflowClient
+	}
+
+	// scheduleState is the latest run of a schedule along with the attributes it was started with.
+	scheduleState struct {
+		info       *s.WorkflowExecutionInfo
+		attributes *s.WorkflowExecutionStartedEventAttributes
+		// paused is true when the run was terminated by PauseSchedule, rather than closed in any other way.
+		paused bool
+	}
+)
+
+// CreateSchedule starts the cron workflow backing a schedule.
+func (sc *scheduleClient) CreateSchedule(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (*WorkflowExecution, error) {
+	if options.ID == "" {
+		return nil, errors.New("missing schedule ID")
+	}
+	if options.CronSchedule == "" {
+		return nil, errors.New("missing CronSchedule")
+	}
+	if _, err := cron.ParseStandard(options.CronSchedule); err != nil {
+		return nil, fmt.Errorf("invalid CronSchedule %q: %v", options.CronSchedule, err)
+	}
+	return sc.workflowClient.StartWorkflow(ctx, options, workflow, args...)
+}
+
+// DescribeSchedule describes the latest run of a schedule.
+func (sc *scheduleClient) DescribeSchedule(ctx context.Context, scheduleID string) (*ScheduleDescription, error) {
+	state, err := sc.getScheduleState(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	description := &ScheduleDescription{
+		ID:           scheduleID,
+		WorkflowType: state.attributes.GetWorkflowType().GetName(),
+		TaskList:     state.attributes.GetTaskList().GetName(),
+		CronSchedule: state.attributes.GetCronSchedule(),
+		Paused:       state.paused,
+		LatestRun: WorkflowExecution{
+			ID:    state.info.GetExecution().GetWorkflowId(),
+			RunID: state.info.GetExecution().GetRunId(),
+		},
+	}
+	if !description.Paused && description.CronSchedule != "" {
+		if schedule, err := cron.ParseStandard(description.CronSchedule); err == nil {
+			// cron workflows are scheduled in UTC
+			description.NextRunTime = schedule.Next(time.Now().UTC())
+		}
+	}
+	return description, nil
+}
+
+// UpdateSchedule restarts a running schedule with a new cron schedule.
+func (sc *scheduleClient) UpdateSchedule(ctx context.Context, scheduleID string, cronSchedule string) error {
+	if _, err := cron.ParseStandard(cronSchedule); err != nil {
+		return fmt.Errorf("invalid CronSchedule %q: %v", cronSchedule, err)
+	}
+	state, err := sc.getScheduleState(ctx, scheduleID)
+	if err != nil {
+		return err
+	}
+	if state.paused {
+		return fmt.Errorf("schedule %v is paused", scheduleID)
+	}
+	// TerminateIfRunning terminates the current run and starts the new one in one transaction, so no fire time is
+	// lost between the two.
+	_, err = sc.restartSchedule(ctx, scheduleID, state.attributes, cronSchedule, WorkflowIDReusePolicyTerminateIfRunning)
+	return err
+}
+
+// PauseSchedule terminates the running cron workflow of a schedule.
+func (sc *scheduleClient) PauseSchedule(ctx context.Context, scheduleID string, reason string) error {
+	terminateReason := schedulePausedReasonPrefix
+	if reason != "" {
+		terminateReason = fmt.Sprintf("%v: %v", schedulePausedReasonPrefix, reason)
+	}
+	return sc.workflowClient.TerminateWorkflow(ctx, scheduleID, "", terminateReason, nil)
+}
+
+// ResumeSchedule starts a new cron workflow for a paused schedule.
+func (sc *scheduleClient) ResumeSchedule(ctx context.Context, scheduleID string) error {
+	state, err := sc.getScheduleState(ctx, scheduleID)
+	if err != nil {
+		return err
+	}
+	if !state.paused {
+		return fmt.Errorf("schedule %v is not paused", scheduleID)
+	}
+	_, err = sc.restartSchedule(ctx, scheduleID, state.attributes, state.attributes.GetCronSchedule(), WorkflowIDReusePolicyAllowDuplicate)
+	return err
+}
+
+// BackfillSchedule starts a run for every fire time of the schedule in [start, end].
+func (sc *scheduleClient) BackfillSchedule(ctx context.Context, scheduleID string, start, end time.Time) ([]*WorkflowExecution, error) {
+	if end.Before(start) {
+		return nil, errors.New("backfill end time is before start time")
+	}
+	state, err := sc.getScheduleState(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := cron.ParseStandard(state.attributes.GetCronSchedule())
+	if err != nil {
+		return nil, fmt.Errorf("schedule %v has invalid CronSchedule %q: %v", scheduleID, state.attributes.GetCronSchedule(), err)
+	}
+
+	var fireTimes []time.Time
+	// Next is exclusive, step back so that a fire time equal to start is included. The fire times are computed in
+	// UTC, like the server schedules cron workflows.
+	start, end = start.UTC(), end.UTC()
+	for next := schedule.Next(start.Add(-time.Second)); !next.IsZero() && !next.After(end); next = schedule.Next(next) {
+		if len(fireTimes) == maxScheduleBackfillRuns {
+			return nil, fmt.Errorf("backfill range contains more than %v runs", maxScheduleBackfillRuns)
+		}
+		fireTimes = append(fireTimes, next)
+	}
+
+	var executions []*WorkflowExecution
+	for _, fireTime := range fireTimes {
+		backfillID := fmt.Sprintf("%v-backfill-%v", scheduleID, fireTime.Format(time.RFC3339))
+		execution, err := sc.restartSchedule(ctx, backfillID, state.attributes, "", WorkflowIDReusePolicyAllowDuplicateFailedOnly)
+		if err != nil {
+			if _, ok := err.(*s.WorkflowExecutionAlreadyStartedError); ok {
+				continue
+			}
+			return executions, err
+		}
+		executions = append(executions, execution)
+	}
+	return executions, nil
+}
+
+// getScheduleState loads the latest run of a schedule and the attributes of its started event.
+func (sc *scheduleClient) getScheduleState(ctx context.Context, scheduleID string) (*scheduleState, error) {
+	if scheduleID == "" {
+		return nil, errors.New("missing schedule ID")
+	}
+	describeResponse, err := sc.workflowClient.DescribeWorkflowExecution(ctx, scheduleID, "")
+	if err != nil {
+		return nil, err
+	}
+	info := describeResponse.GetWorkflowExecutionInfo()
+	if info == nil {
+		return nil, fmt.Errorf("schedule %v has no execution info", scheduleID)
+	}
+
+	iter := sc.workflowClient.GetWorkflowHistory(ctx, scheduleID, info.GetExecution().GetRunId(), false, s.HistoryEventFilterTypeAllEvent)
+	if !iter.HasNext() {
+		return nil, fmt.Errorf("schedule %v has empty history", scheduleID)
+	}
+	event, err := iter.Next()
+	if err != nil {
+		return nil, err
+	}
+	attributes := event.GetWorkflowExecutionStartedEventAttributes()
+	if attributes == nil {
+		return nil, fmt.Errorf("schedule %v history does not start with WorkflowExecutionStarted", scheduleID)
+	}
+	state := &scheduleState{info: info, attributes: attributes}
+	if info.GetCloseStatus() == s.WorkflowExecutionCloseStatusTerminated {
+		if state.paused, err = sc.isTerminatedByPause(ctx, scheduleID, info.GetExecution().GetRunId()); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// isTerminatedByPause returns whether the terminated run of a schedule was terminated by PauseSchedule, rather than
+// by another client terminating the workflow.
+func (sc *scheduleClient) isTerminatedByPause(ctx context.Context, scheduleID, runID string) (bool, error) {
+	iter := sc.workflowClient.GetWorkflowHistory(ctx, scheduleID, runID, false, s.HistoryEventFilterTypeCloseEvent)
+	if !iter.HasNext() {
+		return false, fmt.Errorf("schedule %v has no close event", scheduleID)
+	}
+	event, err := iter.Next()
+	if err != nil {
+		return false, err
+	}
+	reason := event.GetWorkflowExecutionTerminatedEventAttributes().GetReason()
+	return reason == schedulePausedReasonPrefix || strings.HasPrefix(reason, schedulePausedReasonPrefix+": "), nil
+}
+
+// restartSchedule starts a new run with the attributes of a previous run and the given cron schedule.
+func (sc *scheduleClient) restartSchedule(
+	ctx context.Context,
+	workflowID string,
+	attributes *s.WorkflowExecutionStartedEventAttributes,
+	cronSchedule string,
+	reusePolicy WorkflowIDReusePolicy,
+) (*WorkflowExecution, error) {
+	wc := sc.workflowClient
+	startRequest := &s.StartWorkflowExecutionRequest{
+		Domain:                              common.StringPtr(wc.domain),
+		RequestId:                           common.StringPtr(uuid.New()),
+		WorkflowId:                          common.StringPtr(workflowID),
+		WorkflowType:                        attributes.WorkflowType,
+		TaskList:                            attributes.TaskList,
+		Input:                               attributes.Input,
+		ExecutionStartToCloseTimeoutSeconds: attributes.ExecutionStartToCloseTimeoutSeconds,
+		TaskStartToCloseTimeoutSeconds:      attributes.TaskStartToCloseTimeoutSeconds,
+		Identity:                            common.StringPtr(wc.identity),
+		WorkflowIdReusePolicy:               reusePolicy.toThriftPtr(),
+		RetryPolicy:                         attributes.RetryPolicy,
+		CronSchedule:                        common.StringPtr(cronSchedule),
+		Memo:                                attributes.Memo,
+		SearchAttributes:                    attributes.SearchAttributes,
+		Header:                              attributes.Header,
+	}
+
+	var response *s.StartWorkflowExecutionResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			tchCtx, cancel, opt := newChannelContext(ctx, wc.featureFlags)
+			defer cancel()
+
+			var err1 error
+			response, err1 = wc.workflowService.StartWorkflowExecution(tchCtx, startRequest, opt...)
+			return err1
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkflowExecution{ID: workflowID, RunID: response.GetRunId()}, nil
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+// Portions of the Software are attributed to Copyright (c) 2020 Temporal Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/yarpc"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+const (
+	scheduleID       = "some random schedule ID"
+	scheduleCron     = "0 * * * *"
+	scheduleNextCron = "30 * * * *"
+)
+
+type scheduleClientTestSuite struct {
+	suite.Suite
+	mockCtrl *gomock.Controller
+	service  *workflowservicetest.MockClient
+	client   ScheduleClient
+}
+
+func TestScheduleClientSuite(t *testing.T) {
+	suite.Run(t, new(scheduleClientTestSuite))
+}
+
+func (s *scheduleClientTestSuite) SetupTest() {
+	s.mockCtrl = gomock.NewController(s.T())
+	s.service = workflowservicetest.NewMockClient(s.mockCtrl)
+	s.client = NewScheduleClient(s.service, domain, nil)
+}
+
+func (s *scheduleClientTestSuite) TearDownTest() {
+	s.mockCtrl.Finish()
+}
+
+func (s *scheduleClientTestSuite) expectScheduleState(paused bool) {
+	if paused {
+		s.expectTerminatedScheduleState("schedule paused: maintenance")
+		return
+	}
+	s.expectClosedScheduleState(nil, nil)
+}
+
+func (s *scheduleClientTestSuite) expectTerminatedScheduleState(reason string) {
+	s.expectClosedScheduleState(shared.WorkflowExecutionCloseStatusTerminated.Ptr(), &shared.HistoryEvent{
+		EventId:   common.Int64Ptr(2),
+		EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionTerminated),
+		WorkflowExecutionTerminatedEventAttributes: &shared.WorkflowExecutionTerminatedEventAttributes{
+			Reason: common.StringPtr(reason),
+		},
+	})
+}
+
+func (s *scheduleClientTestSuite) expectClosedScheduleState(closeStatus *shared.WorkflowExecutionCloseStatus, closeEvent *shared.HistoryEvent) {
+	info := &shared.WorkflowExecutionInfo{
+		Execution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(scheduleID),
+			RunId:      common.StringPtr(runID),
+		},
+		CloseStatus: closeStatus,
+	}
+	s.service.EXPECT().DescribeWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).Return(
+		&shared.DescribeWorkflowExecutionResponse{WorkflowExecutionInfo: info}, nil)
+
+	startedEvent := createTestEventWorkflowExecutionStarted(1, &shared.WorkflowExecutionStartedEventAttributes{
+		WorkflowType:                        &shared.WorkflowType{Name: common.StringPtr(workflowType)},
+		TaskList:                            &shared.TaskList{Name: common.StringPtr(tasklist)},
+		Input:                               []byte("input"),
+		ExecutionStartToCloseTimeoutSeconds: common.Int32Ptr(timeoutInSeconds),
+		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(timeoutInSeconds),
+		CronSchedule:                        common.StringPtr(scheduleCron),
+	})
+	s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *shared.GetWorkflowExecutionHistoryRequest, _ ...yarpc.CallOption) (*shared.GetWorkflowExecutionHistoryResponse, error) {
+			s.Equal(runID, request.Execution.GetRunId())
+			s.Equal(shared.HistoryEventFilterTypeAllEvent, request.GetHistoryEventFilterType())
+			return &shared.GetWorkflowExecutionHistoryResponse{
+				History: &shared.History{Events: []*shared.HistoryEvent{startedEvent}},
+			}, nil
+		})
+	if closeEvent != nil {
+		s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+			func(_ context.Context, request *shared.GetWorkflowExecutionHistoryRequest, _ ...yarpc.CallOption) (*shared.GetWorkflowExecutionHistoryResponse, error) {
+				s.Equal(shared.HistoryEventFilterTypeCloseEvent, request.GetHistoryEventFilterType())
+				return &shared.GetWorkflowExecutionHistoryResponse{
+					History: &shared.History{Events: []*shared.HistoryEvent{closeEvent}},
+				}, nil
+			})
+	}
+}
+
+func (s *scheduleClientTestSuite) TestCreateSchedule() {
+	options := StartWorkflowOptions{
+		ID:                              scheduleID,
+		TaskList:                        tasklist,
+		ExecutionStartToCloseTimeout:    timeoutInSeconds,
+		DecisionTaskStartToCloseTimeout: timeoutInSeconds,
+	}
+	_, err := s.client.CreateSchedule(context.Background(), options, workflowType)
+	s.EqualError(err, "missing CronSchedule")
+
+	options.CronSchedule = "not a cron"
+	_, err = s.client.CreateSchedule(context.Background(), options, workflowType)
+	s.Error(err)
+
+	options.CronSchedule = scheduleCron
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *shared.StartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			s.Equal(scheduleID, request.GetWorkflowId())
+			s.Equal(scheduleCron, request.GetCronSchedule())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+		})
+	execution, err := s.client.CreateSchedule(context.Background(), options, workflowType)
+	s.NoError(err)
+	s.Equal(WorkflowExecution{ID: scheduleID, RunID: runID}, *execution)
+}
+
+func (s *scheduleClientTestSuite) TestDescribeSchedule() {
+	s.expectScheduleState(false)
+	description, err := s.client.DescribeSchedule(context.Background(), scheduleID)
+	s.NoError(err)
+	s.Equal(workflowType, description.WorkflowType)
+	s.Equal(tasklist, description.TaskList)
+	s.Equal(scheduleCron, description.CronSchedule)
+	s.False(description.Paused)
+	s.Equal(WorkflowExecution{ID: scheduleID, RunID: runID}, description.LatestRun)
+	s.True(description.NextRunTime.After(time.Now()))
+	s.Equal(0, description.NextRunTime.Minute())
+	s.Equal(time.UTC, description.NextRunTime.Location())
+
+	s.expectScheduleState(true)
+	description, err = s.client.DescribeSchedule(context.Background(), scheduleID)
+	s.NoError(err)
+	s.True(description.Paused)
+	s.True(description.NextRunTime.IsZero())
+
+	// only a run terminated by PauseSchedule pauses the schedule
+	s.expectTerminatedScheduleState("terminated by operator")
+	description, err = s.client.DescribeSchedule(context.Background(), scheduleID)
+	s.NoError(err)
+	s.False(description.Paused)
+
+	s.expectClosedScheduleState(shared.WorkflowExecutionCloseStatusFailed.Ptr(), nil)
+	description, err = s.client.DescribeSchedule(context.Background(), scheduleID)
+	s.NoError(err)
+	s.False(description.Paused)
+}
+
+func (s *scheduleClientTestSuite) TestUpdateSchedule() {
+	s.expectScheduleState(false)
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *shared.StartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			s.Equal(scheduleID, request.GetWorkflowId())
+			s.Equal(scheduleNextCron, request.GetCronSchedule())
+			s.Equal(workflowType, request.WorkflowType.GetName())
+			s.Equal([]byte("input"), request.Input)
+			s.Equal(shared.WorkflowIdReusePolicyTerminateIfRunning, request.GetWorkflowIdReusePolicy())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr("new run ID")}, nil
+		})
+	s.NoError(s.client.UpdateSchedule(context.Background(), scheduleID, scheduleNextCron))
+
+	s.expectScheduleState(true)
+	s.EqualError(s.client.UpdateSchedule(context.Background(), scheduleID, scheduleNextCron), "schedule "+scheduleID+" is paused")
+}
+
+func (s *scheduleClientTestSuite) TestPauseAndResumeSchedule() {
+	s.service.EXPECT().TerminateWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *shared.TerminateWorkflowExecutionRequest, _ ...yarpc.CallOption) error {
+			s.Equal(scheduleID, request.WorkflowExecution.GetWorkflowId())
+			s.Equal("schedule paused: maintenance", request.GetReason())
+			return nil
+		})
+	s.NoError(s.client.PauseSchedule(context.Background(), scheduleID, "maintenance"))
+
+	s.expectScheduleState(false)
+	s.EqualError(s.client.ResumeSchedule(context.Background(), scheduleID), "schedule "+scheduleID+" is not paused")
+
+	s.expectScheduleState(true)
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *shared.StartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			s.Equal(scheduleCron, request.GetCronSchedule())
+			s.Equal(shared.WorkflowIdReusePolicyAllowDuplicate, request.GetWorkflowIdReusePolicy())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr("new run ID")}, nil
+		})
+	s.NoError(s.client.ResumeSchedule(context.Background(), scheduleID))
+}
+
+func (s *scheduleClientTestSuite) TestBackfillSchedule() {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+
+	s.expectScheduleState(false)
+	var startedIDs []string
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *shared.StartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			startedIDs = append(startedIDs, request.GetWorkflowId())
+			s.Equal("", request.GetCronSchedule())
+			if len(startedIDs) == 2 {
+				return nil, &shared.WorkflowExecutionAlreadyStartedError{}
+			}
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+		}).Times(3)
+
+	executions, err := s.client.BackfillSchedule(context.Background(), scheduleID, start, end)
+	s.NoError(err)
+	s.Equal([]string{
+		scheduleID + "-backfill-2020-01-01T00:00:00Z",
+		scheduleID + "-backfill-2020-01-01T01:00:00Z",
+		scheduleID + "-backfill-2020-01-01T02:00:00Z",
+	}, startedIDs)
+	s.Equal(2, len(executions))
+	s.Equal(startedIDs[0], executions[0].ID)
+	s.Equal(startedIDs[2], executions[1].ID)
+
+	_, err = s.client.BackfillSchedule(context.Background(), scheduleID, end, start)
+	s.Error(err)
+}
+
+func (s *scheduleClientTestSuite) TestBackfillSchedule_LocalTime() {
+	// the fire times of an hourly schedule are on the hour in UTC, whatever the location of the range
+	location := time.FixedZone("UTC+5:30", 5*60*60+30*60)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, location)
+	end := start.Add(24 * time.Hour)
+
+	s.expectClosedScheduleState(nil, nil)
+	var startedIDs []string
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *shared.StartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			startedIDs = append(startedIDs, request.GetWorkflowId())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+		}).AnyTimes()
+
+	_, err := s.client.BackfillSchedule(context.Background(), scheduleID, start, end)
+	s.NoError(err)
+	s.Equal(24, len(startedIDs))
+	s.Equal(scheduleID+"-backfill-2019-12-31T19:00:00Z", startedIDs[0])
+}
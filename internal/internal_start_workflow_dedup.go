@@ -0,0 +1,104 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/cache"
+)
+
+const defaultStartWorkflowDedupCacheSize = 1000
+
+type (
+	// startWorkflowDedupCache remembers the runs started by a client by their workflow ID and request ID, so that
+	// the retries of a start return the run started by the first attempt.
+	startWorkflowDedupCache struct {
+		cache               cache.Cache
+		attachToExistingRun bool
+	}
+
+	dedupedWorkflowStart struct {
+		runID string
+	}
+
+	startWorkflowDedupKey struct {
+		workflowID string
+		requestID  string
+	}
+)
+
+// newStartWorkflowDedupCache returns nil when the options are nil or the ttl is not positive, which disables the
+// deduplication.
+func newStartWorkflowDedupCache(options *StartWorkflowDedupOptions) *startWorkflowDedupCache {
+	if options == nil || options.TTL <= 0 {
+		return nil
+	}
+	size := options.CacheSize
+	if size <= 0 {
+		size = defaultStartWorkflowDedupCacheSize
+	}
+	return &startWorkflowDedupCache{
+		cache:               cache.New(size, &cache.Options{TTL: options.TTL}),
+		attachToExistingRun: options.AttachToExistingRun,
+	}
+}
+
+func (c *startWorkflowDedupCache) get(workflowID, requestID string) (string, bool) {
+	start, ok := c.cache.Get(startWorkflowDedupKey{workflowID: workflowID, requestID: requestID}).(*dedupedWorkflowStart)
+	if !ok {
+		return "", false
+	}
+	return start.runID, true
+}
+
+func (c *startWorkflowDedupCache) put(workflowID, requestID, runID string) {
+	c.cache.Put(startWorkflowDedupKey{workflowID: workflowID, requestID: requestID}, &dedupedWorkflowStart{runID: runID})
+}
+
+// getExistingRun returns the run ID of the workflow which caused a WorkflowExecutionAlreadyStartedError, if the
+// start is to return it as a success. The run of a previous attempt of the start is always returned. Another run is
+// only attached to while it is open, as attaching to a closed run would bypass the policy which rejected the start:
+// checkOpen is true when the error does not tell whether the run is still open.
+func (c *startWorkflowDedupCache) getExistingRun(err error, requestID string, policy WorkflowIDReusePolicy) (runID string, checkOpen bool, ok bool) {
+	alreadyStartedErr, ok := err.(*shared.WorkflowExecutionAlreadyStartedError)
+	if !ok || alreadyStartedErr.GetRunId() == "" {
+		return "", false, false
+	}
+	if alreadyStartedErr.GetStartRequestId() == requestID {
+		return alreadyStartedErr.GetRunId(), false, true
+	}
+	if !c.attachToExistingRun {
+		return "", false, false
+	}
+	// WorkflowIDReusePolicyAllowDuplicate only rejects a start while a run is open
+	return alreadyStartedErr.GetRunId(), policy != WorkflowIDReusePolicyAllowDuplicate, true
+}
+
+// isRunOpen describes the run, an error is reported as a closed run so that the start returns its error.
+func (wc *workflowClient) isRunOpen(ctx context.Context, workflowID, runID string) bool {
+	response, err := wc.DescribeWorkflowExecution(ctx, workflowID, runID)
+	if err != nil {
+		return false
+	}
+	return response.GetWorkflowExecutionInfo().CloseStatus == nil
+}
@@ -36,12 +36,15 @@ import (
 	"time"
 
 	"github.com/opentracing/opentracing-go"
+	"github.com/pborman/uuid"
+	"github.com/uber-go/tally/v4"
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	s "go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
 	"go.uber.org/cadence/internal/common/backoff"
 	"go.uber.org/cadence/internal/common/cache"
 	"go.uber.org/cadence/internal/common/metrics"
+	"go.uber.org/cadence/internal/common/serializer"
 	"go.uber.org/cadence/internal/common/util"
 	"go.uber.org/zap"
 )
@@ -50,6 +53,9 @@ const (
 	defaultHeartBeatIntervalInSec = 10 * 60
 
 	defaultStickyCacheSize = 10000
+	defaultStickyCacheTTL  = 10 * time.Minute
+
+	defaultStickyCacheMaxPinned = 1000
 
 	noRetryBackoff = time.Duration(-1)
 )
@@ -73,6 +79,11 @@ type (
 		historyIterator HistoryIterator
 		doneCh          chan struct{}
 		laResultCh      chan *localActivityResult
+		// metricsBuffer is set by ProcessWorkflowTask when the workflow metrics are buffered, it is flushed once
+		// the decision task is completed and discarded when it fails.
+		metricsBuffer *metrics.Buffer
+		// inspectedHistory records the history of the decision task for the DecisionTaskInspector of the worker
+		inspectedHistory *inspectedHistoryIterator
 	}
 
 	// activityTask wraps a activity task.
@@ -102,12 +113,27 @@ type (
 		result              []byte
 		err                 error
 
+		// isCached is true if the context was put into the workflow cache. Contexts built to answer a query
+		// without the cached state are not, even though the execution may be cached under the same run ID.
+		isCached bool
+
 		previousStartedEventID int64
 
+		// warmedStartedEventID is the started event ID of the last completed decision when the state was
+		// rebuilt by the sticky cache warm-up instead of a decision task. It is zero otherwise.
+		warmedStartedEventID int64
+
+		// lastSnapshotEventID is the last processed event ID of the last workflow snapshot saved or resumed from.
+		lastSnapshotEventID int64
+
 		newDecisions        []*s.Decision
 		currentDecisionTask *s.PollForDecisionTaskResponse
 		laTunnel            *localActivityTunnel
 		decisionStartTime   time.Time
+
+		// metricsBuffer holds the workflow metrics until the decision task is completed, it is nil unless
+		// enableBufferedWorkflowMetrics is set.
+		metricsBuffer *metrics.Buffer
 	}
 
 	// workflowTaskHandlerImpl is the implementation of WorkflowTaskHandler
@@ -118,14 +144,31 @@ type (
 		logger                         *zap.Logger
 		identity                       string
 		enableLoggingInReplay          bool
+		enableBufferedWorkflowMetrics  bool
 		disableStickyExecution         bool
+		disableStickyQuery             bool
 		registry                       *registry
 		laTunnel                       *localActivityTunnel
+		resetStickinessQueue           *resetStickinessQueue
 		nonDeterministicWorkflowPolicy NonDeterministicWorkflowPolicy
+		workflowPanicClassifier        func(value interface{}, stackTrace string) WorkflowPanicAction
 		dataConverter                  DataConverter
 		contextPropagators             []ContextPropagator
 		tracer                         opentracing.Tracer
 		workflowInterceptors           []WorkflowInterceptorFactory
+		workflowConfigProvider         WorkflowConfigProvider
+		maxTimerDuration               time.Duration // 0 disables timer splitting
+		defaultActivityOptions         *ActivityOptions
+		defaultLocalActivityOptions    *LocalActivityOptions
+
+		snapshotStore    WorkflowSnapshotStore
+		snapshotInterval int64
+
+		enableDecisionTaskFailureDump   bool
+		decisionTaskFailureDumpRedactor func(payload []byte) string
+		enableDecisionValidation        bool
+
+		sizeTracker *workflowSizeTracker
 	}
 
 	activityProvider func(name string) activity
@@ -145,6 +188,11 @@ type (
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
 		featureFlags       FeatureFlags
+		resultCache        *activityResultCache
+		deadlineGrace      time.Duration
+		maxHbThrottle      time.Duration
+		defaultHbThrottle  time.Duration
+		hostID             string
 	}
 
 	// history wrapper method to help information about events.
@@ -156,7 +204,9 @@ type (
 		nextEventID    int64 // next expected eventID for sanity
 		lastEventID    int64 // last expected eventID, zero indicates read until end of stream
 		next           []*s.HistoryEvent
+		nextSize       int64 // encoded size of the events in next
 		binaryChecksum *string
+		resumeEventID  int64 // events before it are skipped as a snapshot restores their state, zero disables skipping
 	}
 
 	decisionHeartbeatError struct {
@@ -226,7 +276,12 @@ func (eh *history) loadMoreEvents() error {
 	if err != nil {
 		return err
 	}
-	eh.loadedEvents = append(eh.loadedEvents, historyPage.Events...)
+	if len(eh.loadedEvents) == 0 {
+		// all loaded events are processed, use the page as is instead of copying it
+		eh.loadedEvents = historyPage.Events
+	} else {
+		eh.loadedEvents = append(eh.loadedEvents, historyPage.Events...)
+	}
 	if eh.nextEventID == 0 && len(eh.loadedEvents) > 0 {
 		eh.nextEventID = eh.loadedEvents[0].GetEventId()
 	}
@@ -258,19 +313,27 @@ func isDecisionEvent(eventType s.EventType) bool {
 // NextDecisionEvents returns events that there processed as new by the next decision.
 // TODO(maxim): Refactor to return a struct instead of multiple parameters
 func (eh *history) NextDecisionEvents() (result []*s.HistoryEvent, markers []*s.HistoryEvent, binaryChecksum *string, err error) {
+	result, markers, binaryChecksum, _, err = eh.nextDecisionEventsWithSize()
+	return
+}
+
+// nextDecisionEventsWithSize is NextDecisionEvents that also returns the encoded size of all the history events
+// up to the end of the returned events, including the ones that are not returned like DecisionTaskScheduled.
+func (eh *history) nextDecisionEventsWithSize() (result []*s.HistoryEvent, markers []*s.HistoryEvent, binaryChecksum *string, size int64, err error) {
 	if eh.next == nil {
-		eh.next, _, err = eh.nextDecisionEvents()
+		eh.next, _, eh.nextSize, err = eh.nextDecisionEvents()
 		if err != nil {
-			return result, markers, eh.binaryChecksum, err
+			return result, markers, eh.binaryChecksum, 0, err
 		}
 	}
 
 	result = eh.next
+	size = eh.nextSize
 	checksum := eh.binaryChecksum
 	if len(result) > 0 {
-		eh.next, markers, err = eh.nextDecisionEvents()
+		eh.next, markers, eh.nextSize, err = eh.nextDecisionEvents()
 	}
-	return result, markers, checksum, err
+	return result, markers, checksum, size, err
 }
 
 func (eh *history) HasNextDecisionEvents() bool {
@@ -302,12 +365,12 @@ func (eh *history) verifyAllEventsProcessed() error {
 	return nil
 }
 
-func (eh *history) nextDecisionEvents() (nextEvents []*s.HistoryEvent, markers []*s.HistoryEvent, err error) {
+func (eh *history) nextDecisionEvents() (nextEvents []*s.HistoryEvent, markers []*s.HistoryEvent, size int64, err error) {
 	if eh.currentIndex == len(eh.loadedEvents) && !eh.hasMoreEvents() {
 		if err := eh.verifyAllEventsProcessed(); err != nil {
-			return nil, nil, err
+			return nil, nil, 0, err
 		}
-		return []*s.HistoryEvent{}, []*s.HistoryEvent{}, nil
+		return []*s.HistoryEvent{}, []*s.HistoryEvent{}, 0, nil
 	}
 
 	// Process events
@@ -337,6 +400,12 @@ OrderEvents:
 		}
 
 		eh.nextEventID++
+		if eh.resumeEventID > 0 && eventID > 1 && eventID < eh.resumeEventID {
+			// the workflow started event is still needed to start the workflow function that restores the snapshot
+			eh.currentIndex++
+			continue
+		}
+		size += historyEventSize(event)
 
 		switch event.GetEventType() {
 		case s.EventTypeDecisionTaskStarted:
@@ -368,7 +437,17 @@ OrderEvents:
 	eh.loadedEvents = eh.loadedEvents[eh.currentIndex:]
 	eh.currentIndex = 0
 
-	return nextEvents, markers, nil
+	return nextEvents, markers, size, nil
+}
+
+// historyEventSize returns the size of the thrift encoded event, or 0 if it cannot be encoded. The encoded bytes are
+// counted instead of buffered, but the event is still converted to its wire value once.
+func historyEventSize(event *s.HistoryEvent) int64 {
+	size, err := serializer.EncodedSize(event)
+	if err != nil {
+		return 0
+	}
+	return size
 }
 
 func isPreloadMarkerEvent(event *s.HistoryEvent) bool {
@@ -390,19 +469,45 @@ func newWorkflowTaskHandler(
 		metricsScope:                   metrics.NewTaggedScope(params.MetricsScope),
 		identity:                       params.Identity,
 		enableLoggingInReplay:          params.EnableLoggingInReplay,
+		enableBufferedWorkflowMetrics:  params.EnableBufferedWorkflowMetrics,
 		disableStickyExecution:         params.DisableStickyExecution,
+		disableStickyQuery:             params.DisableStickyQuery,
 		registry:                       registry,
 		nonDeterministicWorkflowPolicy: params.NonDeterministicWorkflowPolicy,
+		workflowPanicClassifier:        params.WorkflowPanicClassifier,
 		dataConverter:                  params.DataConverter,
 		contextPropagators:             params.ContextPropagators,
 		tracer:                         params.Tracer,
 		workflowInterceptors:           params.WorkflowInterceptors,
+		workflowConfigProvider:         params.WorkflowConfigProvider,
+		maxTimerDuration:               getMaxTimerDuration(params),
+		defaultActivityOptions:         params.DefaultActivityOptions,
+		defaultLocalActivityOptions:    params.DefaultLocalActivityOptions,
+
+		snapshotStore:    params.WorkflowSnapshotStore,
+		snapshotInterval: getWorkflowSnapshotInterval(params),
+
+		enableDecisionTaskFailureDump:   params.EnableDecisionTaskFailureDump,
+		decisionTaskFailureDumpRedactor: params.DecisionTaskFailureDumpRedactor,
+		enableDecisionValidation:        params.EnableDecisionValidation,
+
+		sizeTracker: newWorkflowSizeTracker(params.Logger),
+	}
+}
+
+func getMaxTimerDuration(params workerExecutionParameters) time.Duration {
+	if params.DisableTimerSplitting {
+		return 0
+	}
+	if params.MaxTimerDuration <= 0 {
+		return defaultMaxTimerDuration
 	}
+	return params.MaxTimerDuration
 }
 
-// TODO: need a better eviction policy based on memory usage
 var workflowCache cache.Cache
 var stickyCacheSize = defaultStickyCacheSize
+var stickyCacheConfig stickyCacheOptions
 var initCacheOnce sync.Once
 var stickyCacheLock sync.Mutex
 
@@ -422,16 +527,66 @@ func SetStickyWorkflowCacheSize(cacheSize int) {
 	stickyCacheSize = cacheSize
 }
 
+// stickyCacheOptions are the sticky workflow cache settings configured via WorkerOptions.
+type stickyCacheOptions struct {
+	maxBytes  int64
+	policy    StickyCacheEvictionPolicy
+	ttl       time.Duration
+	maxPinned int
+}
+
+// setStickyWorkflowCacheOptions configures the memory budget and eviction policy of the sticky workflow cache. The
+// cache is shared by all workers of the process, so the options only take effect if they are set before the cache
+// is created.
+func setStickyWorkflowCacheOptions(options stickyCacheOptions, logger *zap.Logger) {
+	stickyCacheLock.Lock()
+	defer stickyCacheLock.Unlock()
+	if workflowCache != nil {
+		if options != stickyCacheConfig {
+			logger.Warn("Sticky workflow cache already created, ignoring its options of this worker.",
+				zap.Int64("StickyCacheMaxBytes", options.maxBytes),
+				zap.Int("StickyCacheEvictionPolicy", int(options.policy)),
+				zap.Duration("StickyCacheTTL", options.ttl),
+				zap.Int("StickyCacheMaxPinned", options.maxPinned))
+		}
+		return
+	}
+	stickyCacheConfig = options
+}
+
+// newStickyWorkflowCacheOptions translates the sticky cache options into the options of the underlying cache.
+func newStickyWorkflowCacheOptions(options stickyCacheOptions) *cache.Options {
+	cacheOptions := &cache.Options{
+		MaxBytes:     options.maxBytes,
+		MaxProtected: options.maxPinned,
+		RemovedFunc: func(cachedEntity interface{}) {
+			wc := cachedEntity.(*workflowExecutionContextImpl)
+			wc.onEviction()
+		},
+	}
+	if cacheOptions.MaxProtected <= 0 {
+		cacheOptions.MaxProtected = defaultStickyCacheMaxPinned
+	}
+	switch options.policy {
+	case StickyCacheEvictionPolicyLFU:
+		cacheOptions.Policy = cache.PolicyLFU
+	case StickyCacheEvictionPolicyTTL:
+		ttl := options.ttl
+		if ttl <= 0 {
+			ttl = defaultStickyCacheTTL
+		}
+		cacheOptions.TTL = ttl
+		cacheOptions.SlidingTTL = true
+		cacheOptions.SweepInterval = ttl / 2
+	}
+	return cacheOptions
+}
+
 func getWorkflowCache() cache.Cache {
 	initCacheOnce.Do(func() {
 		stickyCacheLock.Lock()
 		defer stickyCacheLock.Unlock()
-		workflowCache = cache.New(stickyCacheSize, &cache.Options{
-			RemovedFunc: func(cachedEntity interface{}) {
-				wc := cachedEntity.(*workflowExecutionContextImpl)
-				wc.onEviction()
-			},
-		})
+		workflowCache = cache.New(stickyCacheSize, newStickyWorkflowCacheOptions(stickyCacheConfig))
 	})
 	return workflowCache
 }
@@ -446,10 +601,16 @@ func getWorkflowContext(runID string) *workflowExecutionContextImpl {
 }
 
 func putWorkflowContext(runID string, wc *workflowExecutionContextImpl) (*workflowExecutionContextImpl, error) {
+	// set before the context is shared through the cache, it is reset below if another context is cached
+	wc.isCached = true
 	existing, err := getWorkflowCache().PutIfNotExist(runID, wc)
 	if err != nil {
+		wc.isCached = false
 		return nil, err
 	}
+	if existing != wc {
+		wc.isCached = false
+	}
 	return existing.(*workflowExecutionContextImpl), nil
 }
 
@@ -457,6 +618,20 @@ func removeWorkflowContext(runID string) {
 	getWorkflowCache().Delete(runID)
 }
 
+// pinWorkflowContext exempts the cached execution from eviction if its workflow type is registered with
+// PinInStickyCache. The execution stays pinned until it is removed from the cache when it closes or fails.
+func (wth *workflowTaskHandlerImpl) pinWorkflowContext(runID string, workflowType string) {
+	options, ok := wth.registry.getWorkflowOptions(workflowType)
+	if !ok || !options.PinInStickyCache {
+		return
+	}
+	if !getWorkflowCache().Protect(runID) {
+		wth.logger.Debug("Sticky workflow cache reached its pinned executions limit, execution is not pinned.",
+			zap.String(tagRunID, runID),
+			zap.String(tagWorkflowType, workflowType))
+	}
+}
+
 func newWorkflowExecutionContext(
 	startTime time.Time,
 	workflowInfo *WorkflowInfo,
@@ -467,6 +642,9 @@ func newWorkflowExecutionContext(
 		workflowInfo:      workflowInfo,
 		wth:               taskHandler,
 	}
+	if taskHandler.enableBufferedWorkflowMetrics {
+		workflowContext.metricsBuffer = metrics.NewBuffer()
+	}
 	workflowContext.createEventHandler()
 	return workflowContext
 }
@@ -477,7 +655,8 @@ func (w *workflowExecutionContextImpl) Lock() {
 
 func (w *workflowExecutionContextImpl) Unlock(err error) {
 	cleared := false
-	cached := getWorkflowCache().Exist(w.workflowInfo.WorkflowExecution.RunID)
+	// the context may have been evicted from the cache while it was locked
+	cached := w.isCached && getWorkflowCache().Exist(w.workflowInfo.WorkflowExecution.RunID)
 	if err != nil || w.err != nil || w.isWorkflowCompleted || (w.wth.disableStickyExecution && !w.hasPendingLocalActivityWork()) {
 		// TODO: in case of closed, it assumes the close decision always succeed. need server side change to return
 		// error to indicate the close failure case. This should be rare case. For now, always remove the cache, and
@@ -495,6 +674,10 @@ func (w *workflowExecutionContextImpl) Unlock(err error) {
 	if !cleared && !cached {
 		w.clearState()
 	}
+	if !cleared && cached {
+		// the history size approximates the memory held by the cached workflow state
+		getWorkflowCache().UpdateSize(w.workflowInfo.WorkflowExecution.RunID, w.workflowInfo.HistoryBytes)
+	}
 
 	w.mutex.Unlock()
 }
@@ -555,6 +738,10 @@ func (w *workflowExecutionContextImpl) queueResetStickinessTask() {
 			RunId:      common.StringPtr(w.workflowInfo.WorkflowExecution.RunID),
 		},
 	}
+	if w.wth.resetStickinessQueue != nil {
+		w.wth.resetStickinessQueue.add(task.task)
+		return
+	}
 	// w.laTunnel could be nil for worker.ReplayHistory() because there is no worker started, in that case we don't
 	// care about resetStickinessTask.
 	if w.laTunnel != nil && w.laTunnel.resultCh != nil {
@@ -568,6 +755,7 @@ func (w *workflowExecutionContextImpl) clearState() {
 	w.result = nil
 	w.err = nil
 	w.previousStartedEventID = 0
+	w.warmedStartedEventID = 0
 	w.newDecisions = nil
 
 	eventHandler := w.getEventHandler()
@@ -581,17 +769,24 @@ func (w *workflowExecutionContextImpl) clearState() {
 
 func (w *workflowExecutionContextImpl) createEventHandler() {
 	w.clearState()
+	w.workflowInfo.HistoryLength = 0
+	w.workflowInfo.HistoryBytes = 0
 	eventHandler := newWorkflowExecutionEventHandler(
 		w.workflowInfo,
 		w.completeWorkflow,
 		w.wth.logger,
 		w.wth.enableLoggingInReplay,
 		w.wth.metricsScope,
+		w.metricsBuffer,
 		w.wth.registry,
 		w.wth.dataConverter,
 		w.wth.contextPropagators,
 		w.wth.tracer,
 		w.wth.workflowInterceptors,
+		w.wth.workflowConfigProvider,
+		w.wth.maxTimerDuration,
+		w.wth.defaultActivityOptions,
+		w.wth.defaultLocalActivityOptions,
 	)
 	w.eventHandler.Store(eventHandler)
 }
@@ -670,29 +865,49 @@ func (wth *workflowTaskHandlerImpl) getOrCreateWorkflowContext(
 
 	history := task.History
 	isFullHistory := isFullHistory(history)
+	isStickyQuery := task.Query != nil && !isFullHistory
 
 	workflowContext = nil
-	if task.Query == nil || (task.Query != nil && !isFullHistory) {
+	if task.Query == nil || (isStickyQuery && !wth.disableStickyQuery) {
 		workflowContext = getWorkflowContext(runID)
 	}
 
 	if workflowContext != nil {
 		workflowContext.Lock()
-		if task.Query != nil && !isFullHistory {
+		if isStickyQuery {
 			// query task and we have a valid cached state
 			metricsScope.Counter(metrics.StickyCacheHit).Inc(1)
+			metricsScope.Counter(metrics.StickyQueryHit).Inc(1)
 		} else if history.Events[0].GetEventId() == workflowContext.previousStartedEventID+1 {
 			// non query task and we have a valid cached state
 			metricsScope.Counter(metrics.StickyCacheHit).Inc(1)
+		} else if workflowContext.isWarmedFor(task) {
+			// full history task for a state rebuilt by the warm-up, skip the events that were already replayed
+			if err = workflowContext.skipWarmedEvents(task, historyIterator); err != nil {
+				workflowContext.Unlock(err)
+				return
+			}
+			if task.History.Events[0].GetEventId() == workflowContext.previousStartedEventID+1 {
+				metricsScope.Counter(metrics.StickyCacheHit).Inc(1)
+			} else {
+				workflowContext.ResetIfStale(task, historyIterator)
+			}
 		} else {
 			// non query task and cached state is missing events, we need to discard the cached state and rebuild one.
 			workflowContext.ResetIfStale(task, historyIterator)
 		}
 	} else {
 		if !isFullHistory {
-			// we are getting partial history task, but cached state was already evicted.
+			// we are getting partial history task, but cached state was already evicted, or is not used for queries.
 			// we need to reset history so we get events from beginning to replay/rebuild the state
-			metricsScope.Counter(metrics.StickyCacheMiss).Inc(1)
+			if isStickyQuery && wth.disableStickyQuery {
+				metricsScope.Counter(metrics.StickyQueryBypassed).Inc(1)
+			} else {
+				metricsScope.Counter(metrics.StickyCacheMiss).Inc(1)
+				if isStickyQuery {
+					metricsScope.Counter(metrics.StickyQueryMiss).Inc(1)
+				}
+			}
 			if history, err = resetHistory(task, historyIterator); err != nil {
 				return
 			}
@@ -703,7 +918,12 @@ func (wth *workflowTaskHandlerImpl) getOrCreateWorkflowContext(
 		}
 
 		if !wth.disableStickyExecution && task.Query == nil {
-			workflowContext, _ = putWorkflowContext(runID, workflowContext)
+			if existing, err := putWorkflowContext(runID, workflowContext); err == nil {
+				if existing == workflowContext {
+					wth.pinWorkflowContext(runID, task.WorkflowType.GetName())
+				}
+				workflowContext = existing
+			}
 		}
 		workflowContext.Lock()
 	}
@@ -779,6 +999,7 @@ func (wth *workflowTaskHandlerImpl) ProcessWorkflowTask(
 	defer func() {
 		workflowContext.Unlock(errRet)
 	}()
+	workflowTask.metricsBuffer = workflowContext.metricsBuffer
 
 	var response interface{}
 process_Workflow_Loop:
@@ -803,6 +1024,7 @@ process_Workflow_Loop:
 					if workflowTask == nil {
 						return nil, nil
 					}
+					workflowTask.metricsBuffer = workflowContext.metricsBuffer
 					continue process_Workflow_Loop
 
 				case lar := <-workflowTask.laResultCh:
@@ -819,6 +1041,12 @@ process_Workflow_Loop:
 			break process_Workflow_Loop
 		}
 	}
+	if err == nil && wth.enableDecisionValidation {
+		err = wth.validateDecisions(workflowTask, response)
+	}
+	if err != nil && wth.enableDecisionTaskFailureDump {
+		workflowContext.dumpFailedDecisionTask(workflowTask.task, nil, err)
+	}
 	return response, err
 }
 
@@ -828,10 +1056,16 @@ func (w *workflowExecutionContextImpl) ProcessWorkflowTask(workflowTask *workflo
 	if err := w.ResetIfStale(task, historyIterator); err != nil {
 		return nil, err
 	}
+	// a snapshot is only loaded for an execution that is not cached, before the task updates previousStartedEventID
+	resumeSnapshot := w.loadSnapshot(task)
 	w.SetCurrentTask(task)
 
 	eventHandler := w.getEventHandler()
 	reorderedHistory := newHistory(workflowTask, eventHandler)
+	if resumeSnapshot != nil {
+		reorderedHistory.resumeEventID = resumeSnapshot.LastProcessedEventID
+		eventHandler.resumeSnapshot = resumeSnapshot
+	}
 	var replayDecisions []*s.Decision
 	var respondEvents []*s.HistoryEvent
 
@@ -847,7 +1081,7 @@ func (w *workflowExecutionContextImpl) ProcessWorkflowTask(workflowTask *workflo
 	// Process events
 ProcessEvents:
 	for {
-		reorderedEvents, markers, binaryChecksum, err := reorderedHistory.NextDecisionEvents()
+		reorderedEvents, markers, binaryChecksum, size, err := reorderedHistory.nextDecisionEventsWithSize()
 		if err != nil {
 			return nil, err
 		}
@@ -855,6 +1089,8 @@ ProcessEvents:
 		if len(reorderedEvents) == 0 {
 			break ProcessEvents
 		}
+		w.workflowInfo.HistoryLength = reorderedEvents[len(reorderedEvents)-1].GetEventId()
+		w.workflowInfo.HistoryBytes += size
 		if binaryChecksum == nil {
 			w.workflowInfo.BinaryChecksum = common.StringPtr(getBinaryChecksum())
 		} else {
@@ -913,6 +1149,13 @@ ProcessEvents:
 				}
 			}
 		}
+		if resumeSnapshot != nil && reorderedEvents[len(reorderedEvents)-1].GetEventId() >= reorderedHistory.resumeEventID {
+			if eventHandler.resumeSnapshot != nil {
+				return nil, w.deleteUnrestoredSnapshot(resumeSnapshot)
+			}
+			w.wth.metricsScope.GetTaggedScope(tagWorkflowType, task.WorkflowType.GetName()).Counter(metrics.WorkflowSnapshotRestored).Inc(1)
+			resumeSnapshot = nil
+		}
 		isReplay := len(reorderedEvents) > 0 && reorderedHistory.IsReplayEvent(reorderedEvents[len(reorderedEvents)-1])
 		lastDecisionEventsForReplayTest := isReplayTest && !reorderedHistory.HasNextDecisionEvents()
 		if isReplay && !lastDecisionEventsForReplayTest {
@@ -1126,6 +1369,10 @@ func (w *workflowExecutionContextImpl) CompleteDecisionTask(workflowTask *workfl
 	}
 
 	completeRequest := w.wth.completeWorkflow(eventHandler, w.currentDecisionTask, w, w.newDecisions, !waitLocalActivities)
+	if _, failed := completeRequest.(*s.RespondDecisionTaskFailedRequest); failed && w.wth.enableDecisionTaskFailureDump {
+		w.dumpFailedDecisionTask(w.currentDecisionTask, w.newDecisions, w.err)
+	}
+	w.saveSnapshotIfDue(completeRequest)
 	w.clearCurrentTask()
 
 	return completeRequest
@@ -1154,10 +1401,122 @@ func (w *workflowExecutionContextImpl) SetCurrentTask(task *s.PollForDecisionTas
 	// do not update the previousStartedEventID for query task
 	if task.Query == nil {
 		w.previousStartedEventID = task.GetStartedEventId()
+		w.warmedStartedEventID = 0
 	}
 	w.decisionStartTime = time.Now()
 }
 
+// isWarmedFor returns true if the state was rebuilt by the sticky cache warm-up and the task continues from the
+// decision the warm-up replayed up to.
+func (w *workflowExecutionContextImpl) isWarmedFor(task *s.PollForDecisionTaskResponse) bool {
+	return w.warmedStartedEventID > 0 &&
+		isFullHistory(task.History) &&
+		task.GetPreviousStartedEventId() == w.warmedStartedEventID
+}
+
+// skipWarmedEvents drops the events that were already replayed by the warm-up from the full history of the task,
+// loading more pages from the history iterator if needed. If the remaining events do not continue right after the
+// replayed ones, the history of the task is reset to the first page so the state can be rebuilt from scratch.
+func (w *workflowExecutionContextImpl) skipWarmedEvents(task *s.PollForDecisionTaskResponse, historyIterator HistoryIterator) error {
+	events := task.History.Events
+	paged := false
+	for {
+		for len(events) > 0 && events[0].GetEventId() <= w.previousStartedEventID {
+			events = events[1:]
+		}
+		if len(events) > 0 || historyIterator == nil || !historyIterator.HasNextPage() {
+			break
+		}
+		page, err := historyIterator.GetNextPage()
+		if err != nil {
+			return err
+		}
+		paged = true
+		events = page.Events
+	}
+
+	if len(events) > 0 && events[0].GetEventId() == w.previousStartedEventID+1 {
+		task.History = &s.History{Events: events}
+		return nil
+	}
+	if paged {
+		_, err := resetHistory(task, historyIterator)
+		return err
+	}
+	return nil
+}
+
+// warmUpWorkflowContext rebuilds the state of a workflow execution from its history and puts it into the sticky
+// cache, so the first decision task of the execution on this worker does not have to replay the whole history.
+// The history is replayed up to the decisions of the last completed decision task, which is the point the next
+// decision task of the execution continues from. Executions that are already cached, closed or waiting on local
+// activities are skipped.
+func (wth *workflowTaskHandlerImpl) warmUpWorkflowContext(
+	execution *s.WorkflowExecution,
+	workflowType *s.WorkflowType,
+	history *s.History,
+) error {
+	runID := execution.GetRunId()
+	if getWorkflowContext(runID) != nil {
+		return nil
+	}
+	if !isFullHistory(history) {
+		return errors.New("history does not start with WorkflowExecutionStarted")
+	}
+
+	// find the last completed decision task and the decisions it recorded
+	events := history.Events
+	completedIndex := -1
+	for i, event := range events {
+		if event.GetEventType() == s.EventTypeDecisionTaskCompleted {
+			completedIndex = i
+		}
+	}
+	if completedIndex < 0 {
+		// no decision task was completed yet, there is no state to warm up
+		return nil
+	}
+	lastIndex := completedIndex
+	for lastIndex+1 < len(events) && isDecisionEvent(events[lastIndex+1].GetEventType()) {
+		lastIndex++
+	}
+	lastEventID := events[lastIndex].GetEventId()
+
+	// all the events are replayed, so side effects and local activities are read back from their markers
+	task := &s.PollForDecisionTaskResponse{
+		WorkflowExecution:      execution,
+		WorkflowType:           workflowType,
+		PreviousStartedEventId: common.Int64Ptr(lastEventID),
+		StartedEventId:         common.Int64Ptr(lastEventID),
+		History:                &s.History{Events: events[:lastIndex+1]},
+	}
+	workflowContext, err := wth.createWorkflowContext(task)
+	if err != nil {
+		return err
+	}
+	// the context is not shared until it is put into the cache, so it does not need to be locked here
+	if _, err = workflowContext.ProcessWorkflowTask(&workflowTask{task: task}); err != nil {
+		workflowContext.clearState()
+		return err
+	}
+	if workflowContext.err != nil || workflowContext.isWorkflowCompleted ||
+		len(workflowContext.getEventHandler().pendingLaTasks) > 0 {
+		workflowContext.clearState()
+		return nil
+	}
+
+	workflowContext.warmedStartedEventID = events[completedIndex].DecisionTaskCompletedEventAttributes.GetStartedEventId()
+	workflowContext.laTunnel = wth.laTunnel
+	existing, err := putWorkflowContext(runID, workflowContext)
+	if err != nil || existing != workflowContext {
+		// a decision task for the execution got cached in the meantime
+		workflowContext.clearState()
+		return err
+	}
+	wth.pinWorkflowContext(runID, workflowType.GetName())
+	return nil
+}
+
 func (w *workflowExecutionContextImpl) ResetIfStale(task *s.PollForDecisionTaskResponse, historyIterator HistoryIterator) error {
 	if len(task.History.Events) > 0 && task.History.Events[0].GetEventId() != w.previousStartedEventID+1 {
 		w.wth.logger.Debug("Cached state staled, new task has unexpected events",
@@ -1185,7 +1544,7 @@ func (w *workflowExecutionContextImpl) GetDecisionTimeout() time.Duration {
 func skipDeterministicCheckForDecision(d *s.Decision) bool {
 	if d.GetDecisionType() == s.DecisionTypeRecordMarker {
 		markerName := d.RecordMarkerDecisionAttributes.GetMarkerName()
-		if markerName == versionMarkerName || markerName == mutableSideEffectMarkerName {
+		if markerName == versionMarkerName || markerName == mutableSideEffectMarkerName || markerName == timerSummaryMarkerName {
 			return true
 		}
 	}
@@ -1195,7 +1554,7 @@ func skipDeterministicCheckForDecision(d *s.Decision) bool {
 func skipDeterministicCheckForEvent(e *s.HistoryEvent) bool {
 	if e.GetEventType() == s.EventTypeMarkerRecorded {
 		markerName := e.MarkerRecordedEventAttributes.GetMarkerName()
-		if markerName == versionMarkerName || markerName == mutableSideEffectMarkerName {
+		if markerName == versionMarkerName || markerName == mutableSideEffectMarkerName || markerName == timerSummaryMarkerName {
 			return true
 		}
 	}
@@ -1521,9 +1880,25 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 	}
 
 	metricsScope := wth.metricsScope.GetTaggedScope(tagWorkflowType, eventHandler.workflowEnvironmentImpl.workflowInfo.WorkflowType.Name)
-
-	// fail decision task on decider panic
-	if panicErr, ok := workflowContext.err.(*workflowPanicError); ok {
+	size := workflowSize{
+		workflowType: eventHandler.workflowEnvironmentImpl.workflowInfo.WorkflowType.Name,
+		workflowID:   task.WorkflowExecution.GetWorkflowId(),
+		runID:        task.WorkflowExecution.GetRunId(),
+		historyBytes: eventHandler.workflowEnvironmentImpl.workflowInfo.HistoryBytes,
+		coroutines:   eventHandler.CoroutineCount(),
+		stateSize:    eventHandler.EstimatedStateSize(),
+	}
+	// histograms rather than gauges, as the executions of a workflow type report concurrently
+	metricsScope.Histogram(metrics.WorkflowHistorySize, workflowSizeBuckets).RecordValue(float64(size.historyBytes))
+	metricsScope.Histogram(metrics.WorkflowStateSize, workflowSizeBuckets).RecordValue(float64(size.stateSize))
+	metricsScope.Histogram(metrics.WorkflowCoroutineCount, workflowCoroutineBuckets).RecordValue(float64(size.coroutines))
+	if wth.sizeTracker != nil {
+		wth.sizeTracker.record(size, time.Now())
+	}
+
+	// fail decision task on decider panic, unless the panic classifier decides to fail the workflow
+	workflowErr := workflowContext.err
+	if panicErr, ok := workflowErr.(*workflowPanicError); ok {
 		// Workflow panic
 		metricsScope.Counter(metrics.DecisionTaskPanicCounter).Inc(1)
 		wth.logger.Error("Workflow panic.",
@@ -1531,12 +1906,15 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 			zap.String(tagRunID, task.WorkflowExecution.GetRunId()),
 			zap.String(tagPanicError, panicErr.Error()),
 			zap.String(tagPanicStack, panicErr.StackTrace()))
-		return errorToFailDecisionTask(task.TaskToken, panicErr, wth.identity)
+		if wth.classifyWorkflowPanic(panicErr) != WorkflowPanicActionFail {
+			return errorToFailDecisionTask(task.TaskToken, panicErr, wth.identity)
+		}
+		workflowErr = newPanicError(panicErr.value, panicErr.StackTrace())
 	}
 
 	// complete decision task
 	var closeDecision *s.Decision
-	if canceledErr, ok := workflowContext.err.(*CanceledError); ok {
+	if canceledErr, ok := workflowErr.(*CanceledError); ok {
 		// Workflow cancelled
 		metricsScope.Counter(metrics.WorkflowCanceledCounter).Inc(1)
 		closeDecision = createNewDecision(s.DecisionTypeCancelWorkflowExecution)
@@ -1544,7 +1922,7 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 		closeDecision.CancelWorkflowExecutionDecisionAttributes = &s.CancelWorkflowExecutionDecisionAttributes{
 			Details: details,
 		}
-	} else if contErr, ok := workflowContext.err.(*ContinueAsNewError); ok {
+	} else if contErr, ok := workflowErr.(*ContinueAsNewError); ok {
 		// Continue as new error.
 		metricsScope.Counter(metrics.WorkflowContinueAsNewCounter).Inc(1)
 		closeDecision = createNewDecision(s.DecisionTypeContinueAsNewWorkflowExecution)
@@ -1559,11 +1937,11 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 			SearchAttributes:                    workflowContext.workflowInfo.SearchAttributes,
 			RetryPolicy:                         workflowContext.workflowInfo.RetryPolicy,
 		}
-	} else if workflowContext.err != nil {
+	} else if workflowErr != nil {
 		// Workflow failures
 		metricsScope.Counter(metrics.WorkflowFailedCounter).Inc(1)
 		closeDecision = createNewDecision(s.DecisionTypeFailWorkflowExecution)
-		reason, details := getErrorDetails(workflowContext.err, wth.dataConverter)
+		reason, details := getErrorDetails(workflowErr, wth.dataConverter)
 		closeDecision.FailWorkflowExecutionDecisionAttributes = &s.FailWorkflowExecutionDecisionAttributes{
 			Reason:  common.StringPtr(reason),
 			Details: details,
@@ -1580,29 +1958,12 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 	if closeDecision != nil {
 		decisions = append(decisions, closeDecision)
 		elapsed := time.Now().Sub(workflowContext.workflowStartTime)
-		metricsScope.Timer(metrics.WorkflowEndToEndLatency).Record(elapsed)
+		workflowInfo := eventHandler.workflowEnvironmentImpl.workflowInfo
+		getMetricsScopeForWorkflowTaskList(wth.metricsScope, workflowInfo.WorkflowType.Name, workflowInfo.TaskListName).
+			Timer(metrics.WorkflowEndToEndLatency).Record(elapsed)
 		forceNewDecision = false
 	}
 
-	var queryResults map[string]*s.WorkflowQueryResult
-	if len(task.Queries) != 0 {
-		queryResults = make(map[string]*s.WorkflowQueryResult)
-		for queryID, query := range task.Queries {
-			result, err := eventHandler.ProcessQuery(query.GetQueryType(), query.QueryArgs)
-			if err != nil {
-				queryResults[queryID] = &s.WorkflowQueryResult{
-					ResultType:   common.QueryResultTypePtr(s.QueryResultTypeFailed),
-					ErrorMessage: common.StringPtr(err.Error()),
-				}
-			} else {
-				queryResults[queryID] = &s.WorkflowQueryResult{
-					ResultType: common.QueryResultTypePtr(s.QueryResultTypeAnswered),
-					Answer:     result,
-				}
-			}
-		}
-	}
-
 	return &s.RespondDecisionTaskCompletedRequest{
 		TaskToken:                  task.TaskToken,
 		Decisions:                  decisions,
@@ -1610,8 +1971,58 @@ func (wth *workflowTaskHandlerImpl) completeWorkflow(
 		ReturnNewDecisionTask:      common.BoolPtr(true),
 		ForceCreateNewDecisionTask: common.BoolPtr(forceNewDecision),
 		BinaryChecksum:             common.StringPtr(getBinaryChecksum()),
-		QueryResults:               queryResults,
+		QueryResults:               wth.answerConsistentQueries(eventHandler, task, metricsScope),
+	}
+}
+
+// classifyWorkflowPanic returns the action for a panic in workflow code. Panics of the decision state machine signal
+// non-determinism and always block the workflow.
+func (wth *workflowTaskHandlerImpl) classifyWorkflowPanic(panicErr *workflowPanicError) WorkflowPanicAction {
+	if wth.workflowPanicClassifier == nil {
+		return WorkflowPanicActionBlock
+	}
+	if _, isStateMachinePanic := panicErr.value.(stateMachineIllegalStatePanic); isStateMachinePanic {
+		return WorkflowPanicActionBlock
 	}
+	return wth.workflowPanicClassifier(panicErr.value, panicErr.StackTrace())
+}
+
+// answerConsistentQueries answers the strongly consistent queries embedded in the decision task.
+// The results are reported back to the server through the QueryResults field of RespondDecisionTaskCompleted,
+// keyed by the query ID assigned by the server.
+func (wth *workflowTaskHandlerImpl) answerConsistentQueries(
+	eventHandler *workflowExecutionEventHandlerImpl,
+	task *s.PollForDecisionTaskResponse,
+	metricsScope tally.Scope,
+) map[string]*s.WorkflowQueryResult {
+	if len(task.Queries) == 0 {
+		return nil
+	}
+
+	queryResults := make(map[string]*s.WorkflowQueryResult, len(task.Queries))
+	for queryID, query := range task.Queries {
+		result, err := eventHandler.ProcessQuery(query.GetQueryType(), query.QueryArgs)
+		if err != nil {
+			metricsScope.Counter(metrics.ConsistentQueryFailedCounter).Inc(1)
+			wth.logger.Debug("Failed to answer consistent query.",
+				zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
+				zap.String(tagRunID, task.WorkflowExecution.GetRunId()),
+				zap.String("QueryID", queryID),
+				zap.String(tagQueryType, query.GetQueryType()),
+				zap.Error(err))
+			queryResults[queryID] = &s.WorkflowQueryResult{
+				ResultType:   common.QueryResultTypePtr(s.QueryResultTypeFailed),
+				ErrorMessage: common.StringPtr(err.Error()),
+			}
+		} else {
+			metricsScope.Counter(metrics.ConsistentQueryAnsweredCounter).Inc(1)
+			queryResults[queryID] = &s.WorkflowQueryResult{
+				ResultType: common.QueryResultTypePtr(s.QueryResultTypeAnswered),
+				Answer:     result,
+			}
+		}
+	}
+	return queryResults
 }
 
 func errorToFailDecisionTask(taskToken []byte, err error, identity string) *s.RespondDecisionTaskFailedRequest {
@@ -1670,6 +2081,11 @@ func newActivityTaskHandlerWithCustomProvider(
 		contextPropagators: params.ContextPropagators,
 		tracer:             params.Tracer,
 		featureFlags:       params.FeatureFlags,
+		resultCache:        params.activityResultCache,
+		deadlineGrace:      params.ActivityDeadlineGracePeriod,
+		maxHbThrottle:      params.MaxHeartbeatThrottleInterval,
+		defaultHbThrottle:  params.DefaultHeartbeatThrottleInterval,
+		hostID:             params.HostID,
 	}
 }
 
@@ -1679,13 +2095,15 @@ type cadenceInvoker struct {
 	service               workflowserviceclient.Interface
 	taskToken             []byte
 	cancelHandler         func()
-	heartBeatTimeoutInSec int32       // The heart beat interval configured for this activity.
-	hbBatchEndTimer       *time.Timer // Whether we started a batch of operations that need to be reported in the cycle. This gets started on a user call.
-	detailsToReport       *[]byte     // Details to be reported in the next reporting interval.
-	lastDetailsReported   *[]byte     // Details that were reported in the last reporting interval.
+	heartBeatTimeoutInSec int32         // The heart beat interval configured for this activity.
+	hbThrottleInterval    time.Duration // The duration of the batches of heartbeats, zero derives it from the heart beat timeout.
+	hbBatchEndTimer       *time.Timer   // Whether we started a batch of operations that need to be reported in the cycle. This gets started on a user call.
+	detailsToReport       *[]byte       // Details to be reported in the next reporting interval.
+	lastDetailsReported   *[]byte       // Details that were reported in the last reporting interval.
 	closeCh               chan struct{}
 	workerStopChannel     <-chan struct{}
 	featureFlags          FeatureFlags
+	cancelReason          ActivityCancelReason // Why the heart beat cancelled the activity, if it did.
 }
 
 func (i *cadenceInvoker) Heartbeat(details []byte) error {
@@ -1742,14 +2160,10 @@ func (i *cadenceInvoker) heartbeatAndScheduleNextRun(details []byte) error {
 		i.detailsToReport = nil
 
 		// Create timer to fire before the threshold to report.
-		deadlineToTrigger := i.heartBeatTimeoutInSec
-		if deadlineToTrigger <= 0 {
-			// If we don't have any heartbeat timeout configured.
-			deadlineToTrigger = defaultHeartBeatIntervalInSec
+		duration := i.hbThrottleInterval
+		if duration <= 0 {
+			duration = getHeartbeatThrottleInterval(i.heartBeatTimeoutInSec, 0, 0)
 		}
-
-		// We set a deadline at 80% of the timeout.
-		duration := time.Duration(0.8*float32(deadlineToTrigger)) * time.Second
 		i.hbBatchEndTimer = time.NewTimer(duration)
 
 		go func() {
@@ -1795,13 +2209,20 @@ func (i *cadenceInvoker) internalHeartBeat(details []byte) (bool, error) {
 	switch err.(type) {
 	case *CanceledError:
 		// We are asked to cancel. inform the activity about cancellation through context.
-		i.cancelHandler()
+		i.cancel(ActivityCancelReasonCancelRequested)
 		isActivityCancelled = true
 
-	case *s.EntityNotExistsError, *s.WorkflowExecutionAlreadyCompletedError, *s.DomainNotActiveError:
-		// We will pass these through as cancellation for now but something we can change
-		// later when we have setter on cancel handler.
-		i.cancelHandler()
+	// We pass these through as cancellation, the activity can tell them apart with GetActivityCancelReason.
+	case *s.EntityNotExistsError:
+		i.cancel(ActivityCancelReasonTimedOut)
+		isActivityCancelled = true
+
+	case *s.WorkflowExecutionAlreadyCompletedError:
+		i.cancel(ActivityCancelReasonWorkflowCompleted)
+		isActivityCancelled = true
+
+	case *s.DomainNotActiveError:
+		i.cancel(ActivityCancelReasonDomainNotActive)
 		isActivityCancelled = true
 	}
 
@@ -1810,6 +2231,21 @@ func (i *cadenceInvoker) internalHeartBeat(details []byte) (bool, error) {
 	return isActivityCancelled, err
 }
 
+// cancel records the reason and cancels the activity context, the first reason recorded wins.
+// It must be called with the lock held.
+func (i *cadenceInvoker) cancel(reason ActivityCancelReason) {
+	if i.cancelReason == ActivityCancelReasonNone {
+		i.cancelReason = reason
+	}
+	i.cancelHandler()
+}
+
+func (i *cadenceInvoker) getCancelReason() ActivityCancelReason {
+	i.Lock()
+	defer i.Unlock()
+	return i.cancelReason
+}
+
 func (i *cadenceInvoker) Close(flushBufferedHeartbeat bool) {
 	i.Lock()
 	defer i.Unlock()
@@ -1825,7 +2261,26 @@ func (i *cadenceInvoker) Close(flushBufferedHeartbeat bool) {
 }
 
 func (i *cadenceInvoker) SignalWorkflow(ctx context.Context, domain, workflowID, runID, signalName string, signalInput []byte) error {
-	return signalWorkflow(ctx, i.service, i.identity, domain, workflowID, runID, signalName, signalInput, i.featureFlags)
+	return signalWorkflow(ctx, i.service, i.identity, domain, workflowID, runID, signalName, signalInput, uuid.New(), i.featureFlags)
+}
+
+// getHeartbeatThrottleInterval returns the duration of the batches of heartbeats of an activity, the heartbeats
+// recorded within a batch are coalesced into one with the latest details sent at its end.
+func getHeartbeatThrottleInterval(heartBeatTimeoutInSec int32, defaultInterval, maxInterval time.Duration) time.Duration {
+	var interval time.Duration
+	if heartBeatTimeoutInSec > 0 {
+		// We set a deadline at 80% of the timeout.
+		interval = time.Duration(0.8*float32(heartBeatTimeoutInSec)) * time.Second
+	} else if defaultInterval > 0 {
+		interval = defaultInterval
+	} else {
+		// If we don't have any heartbeat timeout configured.
+		interval = time.Duration(0.8*float32(defaultHeartBeatIntervalInSec)) * time.Second
+	}
+	if maxInterval > 0 && interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
 }
 
 func newServiceInvoker(
@@ -1834,6 +2289,7 @@ func newServiceInvoker(
 	service workflowserviceclient.Interface,
 	cancelHandler func(),
 	heartBeatTimeoutInSec int32,
+	hbThrottleInterval time.Duration,
 	workerStopChannel <-chan struct{},
 	featureFlags FeatureFlags,
 ) ServiceInvoker {
@@ -1843,6 +2299,7 @@ func newServiceInvoker(
 		service:               service,
 		cancelHandler:         cancelHandler,
 		heartBeatTimeoutInSec: heartBeatTimeoutInSec,
+		hbThrottleInterval:    hbThrottleInterval,
 		closeCh:               make(chan struct{}),
 		workerStopChannel:     workerStopChannel,
 		featureFlags:          featureFlags,
@@ -1851,6 +2308,10 @@ func newServiceInvoker(
 
 // Execute executes an implementation of the activity.
 func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivityTaskResponse) (result interface{}, err error) {
+	return ath.executeWithMetricsTags(taskList, t, &activityMetricsTags{})
+}
+
+func (ath *activityTaskHandlerImpl) executeWithMetricsTags(taskList string, t *s.PollForActivityTaskResponse, metricsTags *activityMetricsTags) (result interface{}, err error) {
 	traceLog(func() {
 		ath.logger.Debug("Processing new activity task",
 			zap.String(tagWorkflowID, t.WorkflowExecution.GetWorkflowId()),
@@ -1865,7 +2326,8 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 	canCtx, cancel := context.WithCancel(rootCtx)
 	defer cancel()
 
-	invoker := newServiceInvoker(t.TaskToken, ath.identity, ath.service, cancel, t.GetHeartbeatTimeoutSeconds(), ath.workerStopCh, ath.featureFlags)
+	hbThrottleInterval := getHeartbeatThrottleInterval(t.GetHeartbeatTimeoutSeconds(), ath.defaultHbThrottle, ath.maxHbThrottle)
+	invoker := newServiceInvoker(t.TaskToken, ath.identity, ath.service, cancel, t.GetHeartbeatTimeoutSeconds(), hbThrottleInterval, ath.workerStopCh, ath.featureFlags)
 	defer func() {
 		_, activityCompleted := result.(*s.RespondActivityTaskCompletedRequest)
 		invoker.Close(!activityCompleted) // flush buffered heartbeat if activity was not successfully completed.
@@ -1875,6 +2337,8 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 	activityType := t.ActivityType.GetName()
 	metricsScope := getMetricsScopeForActivity(ath.metricsScope, workflowType, activityType)
 	ctx := WithActivityTask(canCtx, t, taskList, invoker, ath.logger, metricsScope, ath.dataConverter, ath.workerStopCh, ath.contextPropagators, ath.tracer)
+	ctx.Value(activityEnvContextKey).(*activityEnvironment).metricsTags = metricsTags
+	ctx.Value(activityEnvContextKey).(*activityEnvironment).hostID = ath.hostID
 
 	activityImplementation := ath.getActivity(activityType)
 	if activityImplementation == nil {
@@ -1894,7 +2358,7 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 				zap.String(tagActivityType, activityType),
 				zap.String(tagPanicError, fmt.Sprintf("%v", p)),
 				zap.String(tagPanicStack, st))
-			metricsScope.Counter(metrics.ActivityTaskPanicCounter).Inc(1)
+			metricsTags.tagged(metricsScope).Counter(metrics.ActivityTaskPanicCounter).Inc(1)
 			panicErr := newPanicError(p, st)
 			result, err = convertActivityResultToRespondRequest(ath.identity, t.TaskToken, nil, panicErr, ath.dataConverter), nil
 		}
@@ -1909,7 +2373,11 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 	}
 
 	info := ctx.Value(activityEnvContextKey).(*activityEnvironment)
-	ctx, dlCancelFunc := context.WithDeadline(ctx, info.deadline)
+	ctxDeadline := info.deadline
+	if graced := ctxDeadline.Add(-ath.deadlineGrace); graced.After(time.Now()) {
+		ctxDeadline = graced
+	}
+	ctx, dlCancelFunc := context.WithDeadline(ctx, ctxDeadline)
 	defer dlCancelFunc()
 
 	ctx, span := createOpenTracingActivitySpan(ctx, ath.tracer, time.Now(), activityType, t.WorkflowExecution.GetWorkflowId(), t.WorkflowExecution.GetRunId())
@@ -1941,10 +2409,19 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 		}()
 	}
 
+	idempotent := ath.resultCache != nil && activityImplementation.GetOptions().Idempotent
+	if idempotent {
+		if output, ok := ath.resultCache.get(activityType, t.Input); ok {
+			metricsScope.Counter(metrics.ActivityResultCacheHitCounter).Inc(1)
+			return convertActivityResultToRespondRequest(ath.identity, t.TaskToken, output, nil, ath.dataConverter), nil
+		}
+	}
+
 	output, err := activityImplementation.Execute(ctx, t.Input)
 
 	dlCancelFunc()
-	if <-ctx.Done(); ctx.Err() == context.DeadlineExceeded {
+	// the result of an activity which returned within the grace period is still reported
+	if <-ctx.Done(); ctx.Err() == context.DeadlineExceeded && !time.Now().Before(info.deadline) {
 		return nil, ctx.Err()
 	}
 	if err != nil && err != ErrActivityResultPending {
@@ -1955,6 +2432,10 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 			zap.Error(err),
 		)
 	}
+	if idempotent && err == nil {
+		ath.resultCache.put(activityType, t.Input, output)
+	}
+	err = withNonRetriableErrorType(err, t.Header)
 	return convertActivityResultToRespondRequest(ath.identity, t.TaskToken, output, err, ath.dataConverter), nil
 }
 
@@ -1991,6 +2472,7 @@ func signalWorkflow(
 	runID string,
 	signalName string,
 	signalInput []byte,
+	requestID string,
 	featureFlags FeatureFlags,
 ) error {
 	request := &s.SignalWorkflowExecutionRequest{
@@ -2002,6 +2484,7 @@ func signalWorkflow(
 		SignalName: common.StringPtr(signalName),
 		Input:      signalInput,
 		Identity:   common.StringPtr(identity),
+		RequestId:  common.StringPtr(requestID),
 	}
 
 	return backoff.Retry(ctx,
@@ -37,9 +37,13 @@ import (
 	"github.com/pborman/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally/v4"
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	s "go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/cache"
+	"go.uber.org/cadence/internal/common/metrics"
+	"go.uber.org/cadence/internal/common/serializer"
 	"go.uber.org/goleak"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
@@ -91,6 +95,117 @@ func registerWorkflows(r *registry) {
 		binaryChecksumWorkflowFunc,
 		RegisterWorkflowOptions{Name: "BinaryChecksumWorkflow"},
 	)
+	r.RegisterWorkflowWithOptions(
+		configWorkflowFunc,
+		RegisterWorkflowOptions{Name: "ConfigWorkflow"},
+	)
+	r.RegisterWorkflowWithOptions(
+		timerSummaryWorkflowFunc,
+		RegisterWorkflowOptions{Name: "TimerSummaryWorkflow"},
+	)
+	r.RegisterWorkflowWithOptions(
+		longTimerWorkflowFunc,
+		RegisterWorkflowOptions{Name: "LongTimerWorkflow"},
+	)
+	r.RegisterWorkflowWithOptions(
+		helloWorldWorkflowFunc,
+		RegisterWorkflowOptions{Name: "PinnedHelloWorld_Workflow", PinInStickyCache: true},
+	)
+	r.RegisterWorkflowWithOptions(
+		snapshotSignalWorkflowFunc,
+		RegisterWorkflowOptions{Name: "SnapshotSignalWorkflow"},
+	)
+}
+
+type signalCounter struct {
+	Count    int
+	restored bool
+}
+
+func (c *signalCounter) Snapshot() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+func (c *signalCounter) Restore(state []byte) error {
+	c.restored = true
+	return json.Unmarshal(state, c)
+}
+
+// snapshotSignalWorkflowFunc completes after two signals and returns whether it resumed from a snapshot.
+func snapshotSignalWorkflowFunc(ctx Context) (bool, error) {
+	counter := &signalCounter{}
+	if _, err := SetWorkflowSnapshotter(ctx, counter); err != nil {
+		return false, err
+	}
+	signalCh := GetSignalChannel(ctx, "add")
+	for counter.Count < 2 {
+		signalCh.Receive(ctx, nil)
+		counter.Count++
+	}
+	return counter.restored, nil
+}
+
+type memorySnapshotStore struct {
+	sync.Mutex
+	snapshots map[string]*WorkflowSnapshot
+}
+
+func (m *memorySnapshotStore) SaveSnapshot(_ context.Context, _ string, snapshot *WorkflowSnapshot) error {
+	m.Lock()
+	defer m.Unlock()
+	m.snapshots[snapshot.RunID] = snapshot
+	return nil
+}
+
+func (m *memorySnapshotStore) LoadSnapshot(_ context.Context, _, _, runID string) (*WorkflowSnapshot, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.snapshots[runID], nil
+}
+
+func (m *memorySnapshotStore) DeleteSnapshot(_ context.Context, _, _, runID string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.snapshots, runID)
+	return nil
+}
+
+func longTimerWorkflowFunc(ctx Context) error {
+	return Sleep(ctx, 150*time.Minute)
+}
+
+func timerSummaryWorkflowFunc(ctx Context) error {
+	return NewTimerWithOptions(ctx, time.Second, TimerOptions{Summary: "wait for payment"}).Get(ctx, nil)
+}
+
+func configWorkflowFunc(ctx Context) ([]int, error) {
+	var limits []int
+	for i := 0; i < 2; i++ {
+		var limit int
+		if value := GetConfigValue(ctx, "limit"); value.HasValue() {
+			if err := value.Get(&limit); err != nil {
+				return nil, err
+			}
+		}
+		limits = append(limits, limit)
+		if GetConfigValue(ctx, "missing").HasValue() {
+			return nil, errors.New("unexpected config value")
+		}
+		if err := Sleep(ctx, time.Second); err != nil {
+			return nil, err
+		}
+	}
+	return limits, nil
+}
+
+type testWorkflowConfigProvider struct {
+	limit int
+	calls int
+}
+
+func (p *testWorkflowConfigProvider) GetWorkflowConfig(info WorkflowInfo) (map[string]interface{}, error) {
+	p.calls++
+	return map[string]interface{}{"limit": p.limit}, nil
 }
 
 func returnPanicWorkflowFunc(ctx Context, input []byte) error {
@@ -228,6 +343,14 @@ func createTestEventSignalExternalWorkflowExecutionFailed(eventID int64, attr *s
 	}
 }
 
+func createTestEventRequestCancelExternalWorkflowExecutionFailed(eventID int64, attr *s.RequestCancelExternalWorkflowExecutionFailedEventAttributes) *s.HistoryEvent {
+	return &s.HistoryEvent{
+		EventId:   common.Int64Ptr(eventID),
+		EventType: common.EventTypePtr(s.EventTypeRequestCancelExternalWorkflowExecutionFailed),
+		RequestCancelExternalWorkflowExecutionFailedEventAttributes: attr,
+	}
+}
+
 func createWorkflowTask(
 	events []*s.HistoryEvent,
 	previousStartEventID int64,
@@ -374,6 +497,304 @@ func (t *TaskHandlersTestSuite) TestWorkflowTask_BinaryChecksum() {
 	t.Equal(getBinaryChecksum(), checksums[2])
 }
 
+func (t *TaskHandlersTestSuite) TestWorkflowTask_EndToEndLatencyTags() {
+	taskList := "tl1"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+		createTestEventTimerStarted(5, 0),
+		createTestEventTimerFired(6, 0),
+		createTestEventDecisionTaskScheduled(7, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(8),
+		createTestEventDecisionTaskCompleted(9, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(7)}),
+		createTestEventTimerStarted(10, 1),
+		createTestEventTimerFired(11, 1),
+		createTestEventDecisionTaskScheduled(12, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(13),
+	}
+	task := createWorkflowTask(testEvents, 8, "BinaryChecksumWorkflow")
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:     taskList,
+		Identity:     "test-id-1",
+		Logger:       t.logger,
+		MetricsScope: testScope,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	response := request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Equal(s.DecisionTypeCompleteWorkflowExecution, response.Decisions[0].GetDecisionType())
+
+	var tags map[string]string
+	for _, timer := range testScope.Snapshot().Timers() {
+		if timer.Name() == metrics.WorkflowEndToEndLatency {
+			tags = timer.Tags()
+		}
+	}
+	t.Equal("BinaryChecksumWorkflow", tags[tagWorkflowType])
+	t.Equal(taskList, tags[tagTaskList])
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_PinInStickyCache() {
+	original := getWorkflowCache()
+	workflowCache = cache.New(4, newStickyWorkflowCacheOptions(stickyCacheOptions{maxPinned: 1}))
+	defer func() { workflowCache = original }()
+
+	taskList := "tl1"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		Identity: "test-id-1",
+		Logger:   t.logger,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	var runIDs []string
+	process := func(workflowType string) string {
+		task := createWorkflowTask(testEvents, 0, workflowType)
+		_, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+		t.NoError(err)
+		runIDs = append(runIDs, task.WorkflowExecution.GetRunId())
+		return task.WorkflowExecution.GetRunId()
+	}
+	defer func() {
+		for _, runID := range runIDs {
+			getWorkflowCache().Delete(runID)
+		}
+	}()
+
+	pinned := process("PinnedHelloWorld_Workflow")
+	// the second pinned execution is over the limit and cached like any other
+	overLimit := process("PinnedHelloWorld_Workflow")
+	evicted := process("HelloWorld_Workflow")
+	for i := 0; i < 3; i++ {
+		process("HelloWorld_Workflow")
+	}
+
+	t.NotNil(getWorkflowContext(pinned))
+	t.Nil(getWorkflowContext(overLimit))
+	t.Nil(getWorkflowContext(evicted))
+	t.Equal(3, getWorkflowCache().Size())
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_ResumeFromSnapshot() {
+	taskList := "tl1"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+		createTestEventWorkflowExecutionSignaled(5, "add"),
+		createTestEventDecisionTaskScheduled(6, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(7),
+	}
+	store := &memorySnapshotStore{snapshots: make(map[string]*WorkflowSnapshot)}
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:                 taskList,
+		Identity:                 "test-id-1",
+		Logger:                   t.logger,
+		MetricsScope:             testScope,
+		WorkflowSnapshotStore:    store,
+		WorkflowSnapshotInterval: 5,
+	}
+
+	// the first worker saves a snapshot as the workflow waits for the second signal
+	task := createWorkflowTask(testEvents, 3, "SnapshotSignalWorkflow")
+	task.StartedEventId = common.Int64Ptr(7)
+	runID := task.WorkflowExecution.GetRunId()
+	request, err := newWorkflowTaskHandler(testDomain, params, nil, t.registry).ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	t.Empty(request.(*s.RespondDecisionTaskCompletedRequest).Decisions)
+	getWorkflowCache().Delete(runID)
+	t.Eventually(func() bool {
+		snapshot, _ := store.LoadSnapshot(context.Background(), testDomain, "fake-workflow-id", runID)
+		return snapshot != nil
+	}, time.Second, 10*time.Millisecond)
+	snapshot, _ := store.LoadSnapshot(context.Background(), testDomain, "fake-workflow-id", runID)
+	t.Equal(int64(7), snapshot.LastProcessedEventID)
+	t.JSONEq(`{"Count":1}`, string(snapshot.State))
+
+	// another worker resumes from the snapshot, so the first signal is not delivered again
+	testEvents = append(testEvents,
+		createTestEventDecisionTaskCompleted(8, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(6)}),
+		createTestEventWorkflowExecutionSignaled(9, "add"),
+		createTestEventDecisionTaskScheduled(10, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(11),
+	)
+	task = createWorkflowTask(testEvents, 7, "SnapshotSignalWorkflow")
+	task.StartedEventId = common.Int64Ptr(11)
+	task.WorkflowExecution.RunId = common.StringPtr(runID)
+	request, err = newWorkflowTaskHandler(testDomain, params, nil, t.registry).ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	response := request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Len(response.Decisions, 1)
+	t.Equal(s.DecisionTypeCompleteWorkflowExecution, response.Decisions[0].GetDecisionType())
+	var restored bool
+	t.NoError(getDefaultDataConverter().FromData(response.Decisions[0].CompleteWorkflowExecutionDecisionAttributes.Result, &restored))
+	t.True(restored)
+	t.Equal(int64(1), testScope.Snapshot().Counters()[metrics.WorkflowSnapshotRestored+"+WorkflowType=SnapshotSignalWorkflow"].Value())
+
+	// the snapshot of the completed workflow is deleted
+	t.Eventually(func() bool {
+		snapshot, _ := store.LoadSnapshot(context.Background(), testDomain, "fake-workflow-id", runID)
+		return snapshot == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_DecisionValidation() {
+	activityType := "Greeter_Activity"
+	t.registry.RegisterWorkflowWithOptions(
+		func(ctx Context) error {
+			// the cached state keeps the activity type read by the first decision task
+			name := activityType
+			if err := Sleep(ctx, time.Second); err != nil {
+				return err
+			}
+			ctx = WithActivityOptions(ctx, ActivityOptions{ScheduleToStartTimeout: time.Minute, StartToCloseTimeout: time.Minute})
+			return ExecuteActivity(ctx, name).Get(ctx, nil)
+		},
+		RegisterWorkflowOptions{Name: "DriftingWorkflow"},
+	)
+	taskList := "tl1"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+		createTestEventTimerStarted(5, 0),
+		createTestEventTimerFired(6, 0),
+		createTestEventDecisionTaskScheduled(7, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(8),
+	}
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:                 taskList,
+		Identity:                 "test-id-1",
+		Logger:                   t.logger,
+		MetricsScope:             testScope,
+		EnableDecisionValidation: true,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+
+	// the replay builds the same timer
+	task := createWorkflowTask(testEvents[:3], 0, "DriftingWorkflow")
+	task.StartedEventId = common.Int64Ptr(3)
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	t.Equal(s.DecisionTypeStartTimer, request.(*s.RespondDecisionTaskCompletedRequest).Decisions[0].GetDecisionType())
+
+	// the sticky decision task continues from the cached state, while the replay reads the new activity type
+	activityType = "Drifted_Activity"
+	runID := task.WorkflowExecution.GetRunId()
+	task = createWorkflowTask(testEvents[3:], 3, "DriftingWorkflow")
+	task.StartedEventId = common.Int64Ptr(8)
+	task.WorkflowExecution.RunId = common.StringPtr(runID)
+	historyIterator := &historyIteratorImpl{
+		iteratorFunc: func(nextToken []byte) (*s.History, []byte, error) {
+			return &s.History{Events: testEvents}, nil, nil
+		},
+	}
+	_, err = taskHandler.ProcessWorkflowTask(&workflowTask{task: task, historyIterator: historyIterator}, nil)
+	t.Error(err)
+	t.Contains(err.Error(), "decision validation failed")
+	t.Contains(err.Error(), "Drifted_Activity")
+	t.Equal(int64(1), testScope.Snapshot().Counters()[metrics.DecisionValidationFailed+"+WorkflowType=DriftingWorkflow"].Value())
+	t.Nil(getWorkflowContext(runID))
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_DecisionValidation_SideEffect() {
+	var sideEffectRuns int
+	t.registry.RegisterWorkflowWithOptions(
+		func(ctx Context) error {
+			// the replay runs the side effect again and schedules the activity with another input
+			var input int
+			if err := SideEffect(ctx, func(ctx Context) interface{} {
+				sideEffectRuns++
+				return sideEffectRuns
+			}).Get(&input); err != nil {
+				return err
+			}
+			ctx = WithActivityOptions(ctx, ActivityOptions{ScheduleToStartTimeout: time.Minute, StartToCloseTimeout: time.Minute})
+			return ExecuteActivity(ctx, "Greeter_Activity", input).Get(ctx, nil)
+		},
+		RegisterWorkflowOptions{Name: "SideEffectWorkflow"},
+	)
+	taskList := "tl1"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	params := workerExecutionParameters{
+		TaskList:                 taskList,
+		Identity:                 "test-id-1",
+		Logger:                   t.logger,
+		EnableDecisionValidation: true,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+
+	task := createWorkflowTask(testEvents, 0, "SideEffectWorkflow")
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	t.Equal(2, sideEffectRuns)
+	decisions := request.(*s.RespondDecisionTaskCompletedRequest).Decisions
+	t.Len(decisions, 2)
+	t.Equal(s.DecisionTypeRecordMarker, decisions[0].GetDecisionType())
+	t.Equal(s.DecisionTypeScheduleActivityTask, decisions[1].GetDecisionType())
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_HistorySizeMetrics() {
+	taskList := "tl1"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	task := createWorkflowTask(testEvents, 0, "HelloWorld_Workflow")
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:     taskList,
+		Identity:     "test-id-1",
+		Logger:       t.logger,
+		MetricsScope: testScope,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	response := request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Equal(s.DecisionTypeScheduleActivityTask, response.Decisions[0].GetDecisionType())
+
+	histograms := map[string]tally.HistogramSnapshot{}
+	for _, histogram := range testScope.Snapshot().Histograms() {
+		histograms[histogram.Name()] = histogram
+	}
+	// the bucket a single value was recorded into, by its upper bound
+	recordedBucket := func(name string) float64 {
+		histogram, ok := histograms[name]
+		t.True(ok, name)
+		t.Equal("HelloWorld_Workflow", histogram.Tags()[tagWorkflowType])
+		for bound, count := range histogram.Values() {
+			if count == 1 {
+				return bound
+			}
+		}
+		t.Fail("no value recorded", name)
+		return 0
+	}
+	t.True(recordedBucket(metrics.WorkflowHistorySize) > 0)
+	// the state holds at least the blocked root coroutine and the decision of the scheduled activity
+	t.True(recordedBucket(metrics.WorkflowStateSize) > estimatedCoroutineSize)
+	// the root coroutine is blocked on the scheduled activity
+	t.Equal(float64(1), recordedBucket(metrics.WorkflowCoroutineCount))
+}
+
 func (t *TaskHandlersTestSuite) TestWorkflowTask_ActivityTaskScheduled() {
 	// Schedule an activity and see if we complete workflow.
 	taskList := "tl1"
@@ -465,6 +886,118 @@ func (t *TaskHandlersTestSuite) TestWorkflowTask_QueryWorkflow() {
 	t.verifyQueryResult(queryResp, "waiting-activity-result")
 }
 
+func (t *TaskHandlersTestSuite) TestWorkflowTask_QueryWorkerMetadata() {
+	taskList := "tl1"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+	}
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		Identity: "test-id-1",
+		Logger:   t.logger,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+
+	task := createQueryTask(testEvents, 1, "HelloWorld_Workflow", QueryTypeWorkerMetadata)
+	response, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	queryResp, ok := response.(*s.RespondQueryTaskCompletedRequest)
+	t.True(ok)
+	t.Nil(queryResp.ErrorMessage)
+
+	var metadata WorkerMetadata
+	t.NoError(newEncodedValue(queryResp.QueryResult, nil).Get(&metadata))
+	t.Equal(LibraryVersion, metadata.LibraryVersion)
+	t.Equal(FeatureVersion, metadata.FeatureVersion)
+	t.Equal(getBinaryChecksum(), metadata.BinaryChecksum)
+	t.Equal(getHostName(), metadata.HostName)
+	t.Contains(metadata.WorkflowTypes, "HelloWorld_Workflow")
+	t.Contains(metadata.ActivityTypes, "Greeter_Activity")
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_StickyQuery() {
+	taskList := "sticky-tl"
+	execution := &s.WorkflowExecution{
+		WorkflowId: common.StringPtr("fake-workflow-id"),
+		RunId:      common.StringPtr(uuid.New()),
+	}
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:     taskList,
+		Identity:     "test-id-1",
+		Logger:       t.logger,
+		MetricsScope: testScope,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	params.DisableStickyQuery = true
+	noStickyQueryTaskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	defer getWorkflowCache().Delete(execution.GetRunId())
+
+	task := createWorkflowTask(testEvents, 0, "HelloWorld_Workflow")
+	task.WorkflowExecution = execution
+	_, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+
+	historyPages := 0
+	queryTask := func() *workflowTask {
+		task := createQueryTask([]*s.HistoryEvent{}, 3, "HelloWorld_Workflow", queryType)
+		task.WorkflowExecution = execution
+		return &workflowTask{task: task, historyIterator: &historyIteratorImpl{
+			iteratorFunc: func(nextPageToken []byte) (*s.History, []byte, error) {
+				historyPages++
+				return &s.History{Events: testEvents}, nil, nil
+			},
+		}}
+	}
+	counters := func() map[string]int64 {
+		counters := map[string]int64{}
+		for _, counter := range testScope.Snapshot().Counters() {
+			counters[counter.Name()] = counter.Value()
+		}
+		return counters
+	}
+
+	// the cached state answers the query
+	queryResp, err := taskHandler.ProcessWorkflowTask(queryTask(), nil)
+	t.NoError(err)
+	t.verifyQueryResult(queryResp, "waiting-activity-result")
+	t.EqualValues(1, counters()[metrics.StickyQueryHit])
+	t.Equal(0, historyPages)
+
+	// the cached state is ignored, the query is answered from the full history
+	queryResp, err = noStickyQueryTaskHandler.ProcessWorkflowTask(queryTask(), nil)
+	t.NoError(err)
+	t.verifyQueryResult(queryResp, "waiting-activity-result")
+	t.EqualValues(1, counters()[metrics.StickyQueryBypassed])
+	t.Equal(1, historyPages)
+
+	// the context built for the query is not cached, unlocking it clears its own state and keeps the cached execution
+	cachedContext := getWorkflowContext(execution.GetRunId())
+	t.NotNil(cachedContext)
+	bypassedQuery := queryTask()
+	queryContext, err := noStickyQueryTaskHandler.(*workflowTaskHandlerImpl).getOrCreateWorkflowContext(bypassedQuery.task, bypassedQuery.historyIterator)
+	t.NoError(err)
+	t.False(cachedContext == queryContext)
+	queryContext.completeWorkflow(nil, errors.New("query failed"))
+	queryContext.Unlock(nil)
+	t.True(queryContext.IsDestroyed())
+	t.True(cachedContext == getWorkflowContext(execution.GetRunId()))
+	t.False(cachedContext.IsDestroyed())
+
+	// the cached state was evicted
+	getWorkflowCache().Delete(execution.GetRunId())
+	queryResp, err = taskHandler.ProcessWorkflowTask(queryTask(), nil)
+	t.NoError(err)
+	t.verifyQueryResult(queryResp, "waiting-activity-result")
+	t.EqualValues(1, counters()[metrics.StickyQueryMiss])
+	t.Equal(3, historyPages)
+}
+
 func (t *TaskHandlersTestSuite) TestWorkflowTask_QueryWorkflow_2() {
 	// Schedule an activity and see if we complete workflow.
 
@@ -856,6 +1389,100 @@ func (t *TaskHandlersTestSuite) TestWorkflowTask_WorkflowPanics() {
 	t.EqualValues("panicError", string(r.Details))
 }
 
+func (t *TaskHandlersTestSuite) TestWorkflowTask_WorkflowPanicClassifier() {
+	taskList := "taskList"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	for _, action := range []WorkflowPanicAction{WorkflowPanicActionBlock, WorkflowPanicActionFail} {
+		var classifiedValue interface{}
+		var classifiedStack string
+		params := workerExecutionParameters{
+			TaskList:                       taskList,
+			Identity:                       "test-id-1",
+			Logger:                         zap.NewNop(),
+			NonDeterministicWorkflowPolicy: NonDeterministicWorkflowPolicyBlockWorkflow,
+			WorkflowPanicClassifier: func(value interface{}, stackTrace string) WorkflowPanicAction {
+				classifiedValue, classifiedStack = value, stackTrace
+				return action
+			},
+		}
+
+		taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+		task := createWorkflowTask(testEvents, 3, "PanicWorkflow")
+		request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+		t.NoError(err)
+		t.Equal("panicError", classifiedValue)
+		t.Contains(classifiedStack, "panicWorkflowFunc")
+
+		if action == WorkflowPanicActionBlock {
+			r, ok := request.(*s.RespondDecisionTaskFailedRequest)
+			t.True(ok)
+			t.EqualValues("panicError", string(r.Details))
+			continue
+		}
+		r, ok := request.(*s.RespondDecisionTaskCompletedRequest)
+		t.True(ok)
+		t.EqualValues(s.DecisionTypeFailWorkflowExecution, r.Decisions[0].GetDecisionType())
+		attr := r.Decisions[0].FailWorkflowExecutionDecisionAttributes
+		t.EqualValues("cadenceInternal:Panic", attr.GetReason())
+		details := string(attr.Details)
+		t.True(strings.HasPrefix(details, "\"panicError"), details)
+		t.Contains(details, "panicWorkflowFunc")
+	}
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_BufferedWorkflowMetrics() {
+	taskList := "taskList"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	registry := newRegistry()
+	registry.RegisterWorkflowWithOptions(func(ctx Context) error {
+		GetMetricsScope(ctx).Counter("workflow-counter").Inc(1)
+		return nil
+	}, RegisterWorkflowOptions{Name: "MetricsWorkflow"})
+
+	for _, completed := range []bool{false, true} {
+		testScope := tally.NewTestScope("", nil)
+		params := workerExecutionParameters{
+			TaskList:                      taskList,
+			Identity:                      "test-id-1",
+			Logger:                        zap.NewNop(),
+			MetricsScope:                  testScope,
+			EnableBufferedWorkflowMetrics: true,
+		}
+		taskHandler := newWorkflowTaskHandler(testDomain, params, nil, registry)
+		task := &workflowTask{task: createWorkflowTask(testEvents, 0, "MetricsWorkflow")}
+		request, err := taskHandler.ProcessWorkflowTask(task, nil)
+		t.NoError(err)
+		t.NotNil(task.metricsBuffer)
+		t.Equal(1, task.metricsBuffer.Len())
+
+		counterValue := func() int64 {
+			for _, counter := range testScope.Snapshot().Counters() {
+				if counter.Name() == "workflow-counter" {
+					return counter.Value()
+				}
+			}
+			return 0
+		}
+		t.EqualValues(0, counterValue())
+		if completed {
+			task.completeMetrics(request, nil)
+			t.EqualValues(1, counterValue())
+		} else {
+			task.completeMetrics(request, errors.New("respond failed"))
+			t.EqualValues(0, counterValue())
+		}
+		t.Equal(0, task.metricsBuffer.Len())
+	}
+}
+
 func (t *TaskHandlersTestSuite) TestGetWorkflowInfo() {
 	taskList := "taskList"
 	parentID := "parentID"
@@ -927,6 +1554,17 @@ func (t *TaskHandlersTestSuite) TestGetWorkflowInfo() {
 	t.EqualValues(workflowType, result.WorkflowType.Name)
 	t.EqualValues(testDomain, result.Domain)
 	t.EqualValues(retryPolicy, result.RetryPolicy)
+	t.EqualValues(continuedRunID, result.GetContinuedExecutionRunID())
+	t.EqualValues(3, result.GetHistoryLength())
+	t.EqualValues(testHistoryEventsSize(testEvents), result.GetHistoryBytes())
+}
+
+func testHistoryEventsSize(events []*s.HistoryEvent) int64 {
+	var size int64
+	for _, event := range events {
+		size += historyEventSize(event)
+	}
+	return size
 }
 
 func (t *TaskHandlersTestSuite) TestConsistentQuery_InvalidQueryTask() {
@@ -1028,6 +1666,51 @@ func (t *TaskHandlersTestSuite) TestConsistentQuery_Success() {
 	getWorkflowCache().Delete(*task.WorkflowExecution.RunId)
 }
 
+func (t *TaskHandlersTestSuite) TestConsistentQuery_Metrics() {
+	taskList := "tl1"
+	numberOfSignalsToComplete, err := getDefaultDataConverter().ToData(2)
+	t.NoError(err)
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{
+			TaskList: &s.TaskList{Name: &taskList},
+			Input:    numberOfSignalsToComplete,
+		}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskStarted(3),
+	}
+
+	queries := map[string]*s.WorkflowQuery{
+		"id1": {QueryType: common.StringPtr(queryType)},
+		"id2": {QueryType: common.StringPtr(queryType)},
+		"id3": {QueryType: common.StringPtr(errQueryType)},
+	}
+	task := createWorkflowTaskWithQueries(testEvents, 0, "QuerySignalWorkflow", queries)
+
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:     taskList,
+		Identity:     "test-id-1",
+		Logger:       t.logger,
+		MetricsScope: testScope,
+	}
+
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	response := request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Len(response.QueryResults, 3)
+
+	counters := map[string]int64{}
+	for _, counter := range testScope.Snapshot().Counters() {
+		counters[counter.Name()] += counter.Value()
+	}
+	t.EqualValues(2, counters[metrics.ConsistentQueryAnsweredCounter])
+	t.EqualValues(1, counters[metrics.ConsistentQueryFailedCounter])
+
+	// clean up workflow left in cache
+	getWorkflowCache().Delete(*task.WorkflowExecution.RunId)
+}
+
 func (t *TaskHandlersTestSuite) assertQueryResultsEqual(expected map[string]*s.WorkflowQueryResult, actual map[string]*s.WorkflowQueryResult) {
 	t.Equal(len(expected), len(actual))
 	for expectedID, expectedResult := range expected {
@@ -1036,6 +1719,113 @@ func (t *TaskHandlersTestSuite) assertQueryResultsEqual(expected map[string]*s.W
 	}
 }
 
+func (t *TaskHandlersTestSuite) testStickyCacheWarmUpEvents(taskList string) []*s.HistoryEvent {
+	return []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{
+			ScheduledEventId: common.Int64Ptr(2),
+			StartedEventId:   common.Int64Ptr(3),
+		}),
+		createTestEventActivityTaskScheduled(5, &s.ActivityTaskScheduledEventAttributes{
+			ActivityId:   common.StringPtr("0"),
+			ActivityType: &s.ActivityType{Name: common.StringPtr("Greeter_Activity")},
+			TaskList:     &s.TaskList{Name: &taskList},
+		}),
+		createTestEventActivityTaskStarted(6, &s.ActivityTaskStartedEventAttributes{}),
+		createTestEventActivityTaskCompleted(7, &s.ActivityTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(5)}),
+		createTestEventDecisionTaskScheduled(8, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(9),
+	}
+}
+
+func (t *TaskHandlersTestSuite) TestStickyCacheWarmUp() {
+	taskList := "tl1"
+	testEvents := t.testStickyCacheWarmUpEvents(taskList)
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:     taskList,
+		Identity:     "test-id-1",
+		Logger:       t.logger,
+		MetricsScope: testScope,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry).(*workflowTaskHandlerImpl)
+
+	// the warm-up only sees the history up to the activity started event
+	task := createWorkflowTask(testEvents, 3, "HelloWorld_Workflow")
+	err := taskHandler.warmUpWorkflowContext(task.WorkflowExecution, task.WorkflowType, &s.History{Events: testEvents[:6]})
+	t.NoError(err)
+	workflowContext := getWorkflowContext(task.WorkflowExecution.GetRunId())
+	t.NotNil(workflowContext)
+	t.EqualValues(5, workflowContext.previousStartedEventID)
+	t.EqualValues(3, workflowContext.warmedStartedEventID)
+	t.EqualValues(5, workflowContext.workflowInfo.HistoryLength)
+	t.EqualValues(testHistoryEventsSize(testEvents[:5]), workflowContext.workflowInfo.HistoryBytes)
+	workflowInfo := workflowContext.workflowInfo
+
+	// the first decision task is a full history task, only the events after the warm-up are processed
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	response := request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Equal(1, len(response.Decisions))
+	t.Equal(s.DecisionTypeCompleteWorkflowExecution, response.Decisions[0].GetDecisionType())
+	t.EqualValues(6, task.History.Events[0].GetEventId())
+	t.EqualValues(9, workflowInfo.HistoryLength)
+	t.EqualValues(testHistoryEventsSize(testEvents), workflowInfo.HistoryBytes)
+
+	counters := map[string]int64{}
+	for _, counter := range testScope.Snapshot().Counters() {
+		counters[counter.Name()] += counter.Value()
+	}
+	t.EqualValues(1, counters[metrics.StickyCacheHit])
+	t.EqualValues(0, counters[metrics.StickyCacheStall])
+
+	// completed workflow is removed from the cache
+	t.Nil(getWorkflowContext(task.WorkflowExecution.GetRunId()))
+}
+
+func (t *TaskHandlersTestSuite) TestStickyCacheWarmUp_Stale() {
+	taskList := "tl1"
+	testEvents := t.testStickyCacheWarmUpEvents(taskList)
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		Identity: "test-id-1",
+		Logger:   t.logger,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry).(*workflowTaskHandlerImpl)
+
+	task := createWorkflowTask(testEvents, 3, "HelloWorld_Workflow")
+	err := taskHandler.warmUpWorkflowContext(task.WorkflowExecution, task.WorkflowType, &s.History{Events: testEvents[:6]})
+	t.NoError(err)
+
+	// a task that does not continue from the warmed up decision, as if another decision was completed after the
+	// warm-up fetched the history, rebuilds the state from the beginning
+	getWorkflowContext(task.WorkflowExecution.GetRunId()).warmedStartedEventID = 1
+	request, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	response := request.(*s.RespondDecisionTaskCompletedRequest)
+	t.Equal(s.DecisionTypeCompleteWorkflowExecution, response.Decisions[len(response.Decisions)-1].GetDecisionType())
+	t.EqualValues(1, task.History.Events[0].GetEventId())
+	t.Nil(getWorkflowContext(task.WorkflowExecution.GetRunId()))
+}
+
+func (t *TaskHandlersTestSuite) TestStickyCacheWarmUp_NoCompletedDecision() {
+	taskList := "tl1"
+	testEvents := t.testStickyCacheWarmUpEvents(taskList)
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		Identity: "test-id-1",
+		Logger:   t.logger,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry).(*workflowTaskHandlerImpl)
+
+	task := createWorkflowTask(testEvents, 0, "HelloWorld_Workflow")
+	err := taskHandler.warmUpWorkflowContext(task.WorkflowExecution, task.WorkflowType, &s.History{Events: testEvents[:3]})
+	t.NoError(err)
+	t.Nil(getWorkflowContext(task.WorkflowExecution.GetRunId()))
+}
+
 func (t *TaskHandlersTestSuite) TestWorkflowTask_CancelActivityBeforeSent() {
 	// Schedule an activity and see if we complete workflow.
 	taskList := "tl1"
@@ -1273,6 +2063,7 @@ func (t *TaskHandlersTestSuite) TestHeartBeat_NilResponseWithError() {
 		mockService,
 		func() {},
 		0,
+		0,
 		make(chan struct{}),
 		FeatureFlags{},
 	)
@@ -1299,6 +2090,7 @@ func (t *TaskHandlersTestSuite) TestHeartBeat_NilResponseWithDomainNotActiveErro
 		mockService,
 		cancelHandler,
 		0,
+		0,
 		make(chan struct{}),
 		FeatureFlags{},
 	)
@@ -1457,6 +2249,112 @@ func (t *TaskHandlersTestSuite) TestActivityExecutionWorkerStop() {
 	t.NotNil(r)
 }
 
+func (t *TaskHandlersTestSuite) TestActivityExecutionResultCache() {
+	calls := 0
+	registry := t.registry
+	registry.RegisterActivityWithOptions(
+		func(name string) (string, error) {
+			calls++
+			if name == "" {
+				return "", errors.New("empty name")
+			}
+			return "hello " + name, nil
+		},
+		RegisterActivityOptions{Name: "IdempotentActivity", Idempotent: true, DisableAlreadyRegisteredCheck: true},
+	)
+
+	mockCtrl := gomock.NewController(t.T())
+	mockService := workflowservicetest.NewMockClient(mockCtrl)
+	wep := workerExecutionParameters{
+		Logger:              t.logger,
+		DataConverter:       getDefaultDataConverter(),
+		Tracer:              opentracing.NoopTracer{},
+		activityResultCache: newActivityResultCache(0, time.Minute),
+	}
+	activityHandler := newActivityTaskHandler(mockService, wep, registry)
+	execute := func(name string) interface{} {
+		input, err := encodeArg(getDefaultDataConverter(), name)
+		t.NoError(err)
+		r, err := activityHandler.Execute(tasklist, &s.PollForActivityTaskResponse{
+			TaskToken: []byte("token"),
+			WorkflowExecution: &s.WorkflowExecution{
+				WorkflowId: common.StringPtr("wID"),
+				RunId:      common.StringPtr("rID")},
+			ActivityType:                    &s.ActivityType{Name: common.StringPtr("IdempotentActivity")},
+			ActivityId:                      common.StringPtr(uuid.New()),
+			Input:                           input,
+			ScheduledTimestamp:              common.Int64Ptr(time.Now().UnixNano()),
+			ScheduledTimestampOfThisAttempt: common.Int64Ptr(time.Now().UnixNano()),
+			ScheduleToCloseTimeoutSeconds:   common.Int32Ptr(1),
+			StartedTimestamp:                common.Int64Ptr(time.Now().UnixNano()),
+			StartToCloseTimeoutSeconds:      common.Int32Ptr(1),
+			WorkflowType: &s.WorkflowType{
+				Name: common.StringPtr("wType"),
+			},
+			WorkflowDomain: common.StringPtr("domain"),
+		})
+		t.NoError(err)
+		return r
+	}
+
+	r := execute("cadence")
+	t.IsType(&s.RespondActivityTaskCompletedRequest{}, r)
+	r = execute("cadence")
+	t.Equal(1, calls)
+	var result string
+	t.NoError(getDefaultDataConverter().FromData(r.(*s.RespondActivityTaskCompletedRequest).Result, &result))
+	t.Equal("hello cadence", result)
+
+	execute("uber")
+	t.Equal(2, calls)
+
+	// failures are not cached
+	t.IsType(&s.RespondActivityTaskFailedRequest{}, execute(""))
+	t.IsType(&s.RespondActivityTaskFailedRequest{}, execute(""))
+	t.Equal(4, calls)
+}
+
+func (t *TaskHandlersTestSuite) TestActivityExecutionHostID() {
+	registry := t.registry
+	registry.RegisterActivityWithOptions(
+		func(ctx context.Context) (string, error) {
+			return GetActivityInfo(ctx).HostID, nil
+		},
+		RegisterActivityOptions{Name: "HostIDActivity", DisableAlreadyRegisteredCheck: true},
+	)
+
+	mockCtrl := gomock.NewController(t.T())
+	mockService := workflowservicetest.NewMockClient(mockCtrl)
+	wep := workerExecutionParameters{
+		Logger:        t.logger,
+		DataConverter: getDefaultDataConverter(),
+		Tracer:        opentracing.NoopTracer{},
+		HostID:        "host-1",
+	}
+	activityHandler := newActivityTaskHandler(mockService, wep, registry)
+	r, err := activityHandler.Execute(tasklist, &s.PollForActivityTaskResponse{
+		TaskToken: []byte("token"),
+		WorkflowExecution: &s.WorkflowExecution{
+			WorkflowId: common.StringPtr("wID"),
+			RunId:      common.StringPtr("rID")},
+		ActivityType:                    &s.ActivityType{Name: common.StringPtr("HostIDActivity")},
+		ActivityId:                      common.StringPtr(uuid.New()),
+		ScheduledTimestamp:              common.Int64Ptr(time.Now().UnixNano()),
+		ScheduledTimestampOfThisAttempt: common.Int64Ptr(time.Now().UnixNano()),
+		ScheduleToCloseTimeoutSeconds:   common.Int32Ptr(1),
+		StartedTimestamp:                common.Int64Ptr(time.Now().UnixNano()),
+		StartToCloseTimeoutSeconds:      common.Int32Ptr(1),
+		WorkflowType: &s.WorkflowType{
+			Name: common.StringPtr("wType"),
+		},
+		WorkflowDomain: common.StringPtr("domain"),
+	})
+	t.NoError(err)
+	var hostID string
+	t.NoError(getDefaultDataConverter().FromData(r.(*s.RespondActivityTaskCompletedRequest).Result, &hostID))
+	t.Equal("host-1", hostID)
+}
+
 // a regrettably-hacky func to use goleak to count leaking goroutines.
 // ideally there will be a structured way to do this in the future, rather than string parsing
 func countLeaks(leaks error) int {
@@ -1555,6 +2453,258 @@ func (t *TaskHandlersTestSuite) TestRegression_QueriesDoNotLeakGoroutines() {
 		"expected the query to leak no new goroutines.  before query:\n%v\n\nafter query:\n%v", oneCachedLeak, newLeaks)
 }
 
+func (t *TaskHandlersTestSuite) TestWorkflowTask_LongTimerSplitting() {
+	taskList := "tl1"
+	params := workerExecutionParameters{
+		TaskList:         taskList,
+		Identity:         "test-id-1",
+		Logger:           t.logger,
+		MaxTimerDuration: time.Hour,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+	timerStarted := func(eventID int64, id int, d time.Duration) *s.HistoryEvent {
+		event := createTestEventTimerStarted(eventID, id)
+		event.TimerStartedEventAttributes.StartToFireTimeoutSeconds = common.Int64Ptr(int64(d.Seconds()))
+		return event
+	}
+	process := func(events []*s.HistoryEvent, previousStartedEventID int64) []*s.Decision {
+		task := createWorkflowTask(events, previousStartedEventID, "LongTimerWorkflow")
+		response, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+		t.NoError(err)
+		return response.(*s.RespondDecisionTaskCompletedRequest).Decisions
+	}
+
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	decisions := process(testEvents, 0)
+	t.Len(decisions, 1)
+	t.Equal(s.DecisionTypeStartTimer, decisions[0].GetDecisionType())
+	t.Equal(int64(3600), decisions[0].StartTimerDecisionAttributes.GetStartToFireTimeoutSeconds())
+
+	// the next timer of the chain is started when the first one fires
+	for i, expected := range []int64{3600, 1800} {
+		eventID := int64(len(testEvents)) + 1
+		testEvents = append(testEvents,
+			createTestEventDecisionTaskCompleted(eventID, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(eventID - 2)}),
+			timerStarted(eventID+1, i, time.Hour),
+			createTestEventTimerFired(eventID+2, i),
+			createTestEventDecisionTaskScheduled(eventID+3, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+			createTestEventDecisionTaskStarted(eventID+4),
+		)
+		decisions = process(testEvents, eventID-1)
+		t.Len(decisions, 1)
+		t.Equal(s.DecisionTypeStartTimer, decisions[0].GetDecisionType())
+		t.Equal(fmt.Sprintf("%v", i+1), decisions[0].StartTimerDecisionAttributes.GetTimerId())
+		t.Equal(expected, decisions[0].StartTimerDecisionAttributes.GetStartToFireTimeoutSeconds())
+	}
+
+	// a history with a single timer started before the timers were split replays the same
+	testEvents = append(testEvents[:3],
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+		timerStarted(5, 0, 150*time.Minute),
+		createTestEventTimerFired(6, 0),
+		createTestEventDecisionTaskScheduled(7, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(8),
+	)
+	decisions = process(testEvents, 3)
+	t.Len(decisions, 1)
+	t.Equal(s.DecisionTypeCompleteWorkflowExecution, decisions[0].GetDecisionType())
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_TimerSummary() {
+	taskList := "tl1"
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		Identity: "test-id-1",
+		Logger:   t.logger,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	task := createWorkflowTask(testEvents, 0, "TimerSummaryWorkflow")
+	response, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	decisions := response.(*s.RespondDecisionTaskCompletedRequest).Decisions
+	t.Len(decisions, 2)
+	t.Equal(s.DecisionTypeStartTimer, decisions[0].GetDecisionType())
+	t.Equal(s.DecisionTypeRecordMarker, decisions[1].GetDecisionType())
+	t.Equal(timerSummaryMarkerName, decisions[1].RecordMarkerDecisionAttributes.GetMarkerName())
+	var timerID, summary string
+	t.NoError(newEncodedValues(decisions[1].RecordMarkerDecisionAttributes.Details, getDefaultDataConverter()).Get(&timerID, &summary))
+	t.Equal(decisions[0].StartTimerDecisionAttributes.GetTimerId(), timerID)
+	t.Equal("wait for payment", summary)
+
+	// histories with or without the summary marker replay the same
+	for _, withMarker := range []bool{true, false} {
+		events := append([]*s.HistoryEvent{}, testEvents...)
+		events = append(events,
+			createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+			createTestEventTimerStarted(5, 0),
+		)
+		nextEventID := int64(6)
+		if withMarker {
+			events = append(events, createTestEventLocalActivity(nextEventID, &s.MarkerRecordedEventAttributes{
+				MarkerName: common.StringPtr(timerSummaryMarkerName),
+				Details:    decisions[1].RecordMarkerDecisionAttributes.Details,
+			}))
+			nextEventID++
+		}
+		events = append(events,
+			createTestEventTimerFired(nextEventID, 0),
+			createTestEventDecisionTaskScheduled(nextEventID+1, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+			createTestEventDecisionTaskStarted(nextEventID+2),
+		)
+		task = createWorkflowTask(events, 3, "TimerSummaryWorkflow")
+		response, err = taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+		t.NoError(err)
+		replayDecisions := response.(*s.RespondDecisionTaskCompletedRequest).Decisions
+		t.Len(replayDecisions, 1)
+		t.Equal(s.DecisionTypeCompleteWorkflowExecution, replayDecisions[0].GetDecisionType())
+	}
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_ConfigValue() {
+	taskList := "tl1"
+	provider := &testWorkflowConfigProvider{limit: 1}
+	params := workerExecutionParameters{
+		TaskList:               taskList,
+		Identity:               "test-id-1",
+		Logger:                 t.logger,
+		WorkflowConfigProvider: provider,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, t.registry)
+
+	// the snapshot is taken once for the decision and recorded as a single marker
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	task := createWorkflowTask(testEvents, 0, "ConfigWorkflow")
+	response, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	decisions := response.(*s.RespondDecisionTaskCompletedRequest).Decisions
+	t.Len(decisions, 2)
+	t.Equal(s.DecisionTypeRecordMarker, decisions[0].GetDecisionType())
+	t.Equal(mutableSideEffectMarkerName, decisions[0].RecordMarkerDecisionAttributes.GetMarkerName())
+	t.Equal(s.DecisionTypeStartTimer, decisions[1].GetDecisionType())
+	t.Equal(1, provider.calls)
+
+	// the recorded snapshot is replayed, the changed snapshot of the new decision is recorded again
+	provider.limit = 2
+	testEvents = append(testEvents[:3],
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+		createTestEventLocalActivity(5, &s.MarkerRecordedEventAttributes{
+			MarkerName: common.StringPtr(mutableSideEffectMarkerName),
+			Details:    decisions[0].RecordMarkerDecisionAttributes.Details,
+		}),
+		createTestEventTimerStarted(6, 0),
+		createTestEventTimerFired(7, 0),
+		createTestEventDecisionTaskScheduled(8, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(9),
+	)
+	task = createWorkflowTask(testEvents, 3, "ConfigWorkflow")
+	response, err = taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	decisions = response.(*s.RespondDecisionTaskCompletedRequest).Decisions
+	t.Len(decisions, 2)
+	t.Equal(s.DecisionTypeRecordMarker, decisions[0].GetDecisionType())
+	t.Equal(s.DecisionTypeStartTimer, decisions[1].GetDecisionType())
+	t.Equal(2, provider.calls)
+
+	// nothing is recorded while the snapshot is unchanged
+	testEvents = append(testEvents[:9],
+		createTestEventDecisionTaskCompleted(10, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(8)}),
+		createTestEventLocalActivity(11, &s.MarkerRecordedEventAttributes{
+			MarkerName: common.StringPtr(mutableSideEffectMarkerName),
+			Details:    decisions[0].RecordMarkerDecisionAttributes.Details,
+		}),
+		createTestEventTimerStarted(12, 1),
+		createTestEventTimerFired(13, 1),
+		createTestEventDecisionTaskScheduled(14, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(15),
+	)
+	task = createWorkflowTask(testEvents, 9, "ConfigWorkflow")
+	response, err = taskHandler.ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	decisions = response.(*s.RespondDecisionTaskCompletedRequest).Decisions
+	t.Len(decisions, 1)
+	t.Equal(s.DecisionTypeCompleteWorkflowExecution, decisions[0].GetDecisionType())
+	var limits []int
+	t.NoError(getDefaultDataConverter().FromData(decisions[0].CompleteWorkflowExecutionDecisionAttributes.Result, &limits))
+	t.Equal([]int{1, 2}, limits)
+}
+
+func (t *TaskHandlersTestSuite) TestWorkflowTask_ConfigValueProviderAdded() {
+	taskList := "tl1"
+	params := workerExecutionParameters{
+		TaskList: taskList,
+		Identity: "test-id-1",
+		Logger:   t.logger,
+	}
+
+	// an empty snapshot is recorded without a provider
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+	}
+	task := createWorkflowTask(testEvents, 0, "ConfigWorkflow")
+	response, err := newWorkflowTaskHandler(testDomain, params, nil, t.registry).ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	decisions := response.(*s.RespondDecisionTaskCompletedRequest).Decisions
+	t.Len(decisions, 2)
+	t.Equal(s.DecisionTypeRecordMarker, decisions[0].GetDecisionType())
+	t.Equal(mutableSideEffectMarkerName, decisions[0].RecordMarkerDecisionAttributes.GetMarkerName())
+	t.Equal(s.DecisionTypeStartTimer, decisions[1].GetDecisionType())
+
+	// a worker the provider was added to replays the history and records the snapshot of the provider
+	provider := &testWorkflowConfigProvider{limit: 1}
+	params.WorkflowConfigProvider = provider
+	testEvents = append(testEvents,
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+		createTestEventLocalActivity(5, &s.MarkerRecordedEventAttributes{
+			MarkerName: common.StringPtr(mutableSideEffectMarkerName),
+			Details:    decisions[0].RecordMarkerDecisionAttributes.Details,
+		}),
+		createTestEventTimerStarted(6, 0),
+		createTestEventTimerFired(7, 0),
+		createTestEventDecisionTaskScheduled(8, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(9),
+	)
+	task = createWorkflowTask(testEvents, 3, "ConfigWorkflow")
+	response, err = newWorkflowTaskHandler(testDomain, params, nil, t.registry).ProcessWorkflowTask(&workflowTask{task: task}, nil)
+	t.NoError(err)
+	decisions = response.(*s.RespondDecisionTaskCompletedRequest).Decisions
+	t.Len(decisions, 2)
+	t.Equal(s.DecisionTypeRecordMarker, decisions[0].GetDecisionType())
+	t.Equal(s.DecisionTypeStartTimer, decisions[1].GetDecisionType())
+	t.Equal(1, provider.calls)
+}
+
+func Test_HistoryEventSize(t *testing.T) {
+	taskList := "taskList"
+	for _, event := range []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{
+			TaskList: &s.TaskList{Name: &taskList},
+			Input:    []byte(strings.Repeat("input", 1000)),
+		}),
+		createTestEventDecisionTaskStarted(3),
+		{},
+	} {
+		data, err := serializer.Encode(event)
+		require.NoError(t, err)
+		require.EqualValues(t, len(data), historyEventSize(event))
+	}
+}
+
 func Test_NonDeterministicCheck(t *testing.T) {
 	decisionTypes := s.DecisionType_Values()
 	require.Equal(t, 13, len(decisionTypes), "If you see this error, you are adding new decision type. "+
@@ -24,6 +24,7 @@ package internal
 // All code in this file is private to the package.
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"sync"
@@ -79,11 +80,35 @@ type (
 		disableStickyExecution       bool
 		StickyScheduleToStartTimeout time.Duration
 
+		decisionTaskFailureThreshold   int
+		onDecisionTaskFailureThreshold func(execution WorkflowExecution, err error)
+		decisionTaskFailurePolicy      DecisionTaskFailurePolicy
+		slowDecisionTaskThreshold      time.Duration
+		eventListeners                 []WorkerEventListener
+		workflowTaskFilter             WorkflowTaskFilter
+		decisionTaskInspector          DecisionTaskInspector
+		rejectUnregisteredTypes        bool
+		unregisteredTypeRequeueDelay   time.Duration
+		historyPrefetch                bool
+		pollHedgingDelay               time.Duration
+
 		pendingRegularPollCount int
 		pendingStickyPollCount  int
 		stickyBacklog           int64
 		requestLock             sync.Mutex
 		featureFlags            FeatureFlags
+		// hedgedResponses are the tasks returned by the slower of two hedged poll requests, protected by requestLock
+		hedgedResponses []hedgedPollResponse
+	}
+
+	hedgedPollResponse struct {
+		response     *s.PollForDecisionTaskResponse
+		taskListKind s.TaskListKind
+	}
+
+	hedgedPollResult struct {
+		response *s.PollForDecisionTaskResponse
+		err      error
 	}
 
 	// activityTaskPoller implements polling/processing a workflow task
@@ -98,6 +123,9 @@ type (
 		logger              *zap.Logger
 		activitiesPerSecond float64
 		featureFlags        FeatureFlags
+
+		slowActivityThreshold time.Duration
+		eventListeners        []WorkerEventListener
 	}
 
 	// locallyDispatchedActivityTaskPoller implements polling/processing a locally dispatched activity task
@@ -107,7 +135,9 @@ type (
 	}
 
 	historyIteratorImpl struct {
-		iteratorFunc   func(nextPageToken []byte) (*s.History, []byte, error)
+		iteratorFunc func(nextPageToken []byte) (*s.History, []byte, error)
+		// pageFunc fetches the pages when iteratorFunc is not set, leaving the raw event batches encoded
+		pageFunc       func(nextPageToken []byte) (*historyPage, error)
 		execution      *s.WorkflowExecution
 		nextPageToken  []byte
 		domain         string
@@ -116,6 +146,27 @@ type (
 		startedEventID int64
 		maxEventID     int64
 		featureFlags   FeatureFlags
+		// prefetch fetches the next page in the background as soon as a page is returned
+		prefetch   bool
+		prefetched *historyPagePrefetch
+		// rawBatches are the raw event batches of the current page not returned yet, GetNextPage decodes and
+		// returns one at a time so that the events are only decoded when they are replayed
+		rawBatches []*s.DataBlob
+	}
+
+	// historyPage is a page of a history, either decoded or as the raw event batches returned by the server.
+	historyPage struct {
+		history       *s.History
+		rawBatches    []*s.DataBlob
+		nextPageToken []byte
+	}
+
+	// historyPagePrefetch is a page fetched ahead by historyIteratorImpl, done is closed once the result is set
+	historyPagePrefetch struct {
+		pageToken []byte
+		done      chan struct{}
+		page      *historyPage
+		err       error
 	}
 
 	localActivityTaskPoller struct {
@@ -133,6 +184,7 @@ type (
 		dataConverter      DataConverter
 		contextPropagators []ContextPropagator
 		tracer             opentracing.Tracer
+		eventListeners     []WorkerEventListener
 	}
 
 	localActivityResult struct {
@@ -152,6 +204,8 @@ type (
 		taskCh       chan *locallyDispatchedActivityTask
 		stopCh       <-chan struct{}
 		metricsScope *metrics.TaggedScope
+		// registry of the activity worker, only the activities it has are dispatched through the tunnel
+		registry *registry
 	}
 )
 
@@ -208,6 +262,15 @@ func (ldat *locallyDispatchedActivityTunnel) getTask() *locallyDispatchedActivit
 	}
 }
 
+// canDispatch returns whether the activity worker at the other end of the tunnel can execute activityType
+func (ldat *locallyDispatchedActivityTunnel) canDispatch(activityType string) bool {
+	if ldat.registry == nil {
+		return true
+	}
+	_, ok := ldat.registry.GetActivity(activityType)
+	return ok
+}
+
 func (ldat *locallyDispatchedActivityTunnel) sendTask(task *locallyDispatchedActivityTask) bool {
 	select {
 	case ldat.taskCh <- task:
@@ -236,6 +299,19 @@ func (bp *basePoller) shuttingDown() bool {
 	}
 }
 
+// stopContext returns a context that is canceled once the worker is shutting down.
+func (bp *basePoller) stopContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-bp.shutdownC:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 // doPoll runs the given pollFunc in a separate go routine. Returns when either of the conditions are met:
 // - poll succeeds, poll fails or worker is shutting down
 func (bp *basePoller) doPoll(
@@ -289,6 +365,18 @@ func newWorkflowTaskPoller(
 		disableStickyExecution:       params.DisableStickyExecution,
 		StickyScheduleToStartTimeout: params.StickyScheduleToStartTimeout,
 		featureFlags:                 params.FeatureFlags,
+
+		decisionTaskFailureThreshold:   params.DecisionTaskFailureThreshold,
+		onDecisionTaskFailureThreshold: params.OnDecisionTaskFailureThreshold,
+		decisionTaskFailurePolicy:      params.DecisionTaskFailurePolicy,
+		slowDecisionTaskThreshold:      params.SlowDecisionTaskThreshold,
+		eventListeners:                 params.EventListeners,
+		workflowTaskFilter:             params.WorkflowTaskFilter,
+		decisionTaskInspector:          params.DecisionTaskInspector,
+		rejectUnregisteredTypes:        params.RejectUnregisteredWorkflowTypes,
+		unregisteredTypeRequeueDelay:   params.UnregisteredWorkflowTypeRequeueDelay,
+		historyPrefetch:                params.EnableHistoryPrefetch,
+		pollHedgingDelay:               params.DecisionPollHedgingDelay,
 	}
 }
 
@@ -328,12 +416,30 @@ func (wtp *workflowTaskPoller) processWorkflowTask(task *workflowTask) error {
 		return nil
 	}
 
+	if err := wtp.filterWorkflowTask(task.task); err != nil {
+		// rejected tasks are not failures of the workflow, so they don't count towards DecisionTaskFailureThreshold
+		wtp.logger.Info("Workflow task rejected by WorkflowTaskFilter.",
+			zap.String(tagWorkflowType, task.task.WorkflowType.GetName()),
+			zap.String(tagWorkflowID, task.task.WorkflowExecution.GetWorkflowId()),
+			zap.String(tagRunID, task.task.WorkflowExecution.GetRunId()),
+			zap.Error(err))
+		wtp.metricsScope.GetTaggedScope(tagWorkflowType, task.task.WorkflowType.GetName()).Counter(metrics.DecisionTaskFilteredCounter).Inc(1)
+		_, err = wtp.RespondTaskCompleted(errorToFailDecisionTask(task.task.TaskToken, err, wtp.identity), task.task)
+		return err
+	}
+	if wtp.isUnregisteredWorkflowType(task.task) {
+		return wtp.rejectUnregisteredWorkflowType(task.task)
+	}
+
 	doneCh := make(chan struct{})
 	laResultCh := make(chan *localActivityResult)
 	// close doneCh so local activity worker won't get blocked forever when trying to send back result to laResultCh.
 	defer close(doneCh)
 
 	for {
+		// the task the handler is processing, it changes when the decision task is heartbeated
+		inspectedTask := task
+		wtp.startDecisionTaskInspection(inspectedTask)
 		var response *s.RespondDecisionTaskCompletedResponse
 		startTime := time.Now()
 		task.doneCh = doneCh
@@ -343,7 +449,9 @@ func (wtp *workflowTaskPoller) processWorkflowTask(task *workflowTask) error {
 			func(response interface{}, startTime time.Time) (*workflowTask, error) {
 				wtp.logger.Debug("Force RespondDecisionTaskCompleted.", zap.Int64("TaskStartedEventID", task.task.GetStartedEventId()))
 				wtp.metricsScope.Counter(metrics.DecisionTaskForceCompleted).Inc(1)
-				heartbeatResponse, err := wtp.RespondTaskCompletedWithMetrics(response, nil, task.task, startTime)
+				wtp.inspectDecisionTask(inspectedTask, response)
+				heartbeatResponse, err := wtp.RespondTaskCompletedWithMetrics(response, nil, inspectedTask.task, startTime)
+				task.completeMetrics(response, err)
 				if err != nil {
 					return nil, err
 				}
@@ -353,6 +461,8 @@ func (wtp *workflowTaskPoller) processWorkflowTask(task *workflowTask) error {
 				task := wtp.toWorkflowTask(heartbeatResponse.DecisionTask)
 				task.doneCh = doneCh
 				task.laResultCh = laResultCh
+				wtp.startDecisionTaskInspection(task)
+				inspectedTask = task
 				return task, nil
 			},
 		)
@@ -362,7 +472,16 @@ func (wtp *workflowTaskPoller) processWorkflowTask(task *workflowTask) error {
 		if _, ok := err.(decisionHeartbeatError); ok {
 			return err
 		}
-		response, err = wtp.RespondTaskCompletedWithMetrics(completedRequest, err, task.task, startTime)
+		taskErr := err
+		if taskErr == nil {
+			wtp.inspectDecisionTask(inspectedTask, completedRequest)
+		}
+		response, err = wtp.RespondTaskCompletedWithMetrics(completedRequest, taskErr, task.task, startTime)
+		if taskErr != nil {
+			task.completeMetrics(nil, taskErr)
+		} else {
+			task.completeMetrics(completedRequest, err)
+		}
 		if err != nil {
 			return err
 		}
@@ -376,6 +495,19 @@ func (wtp *workflowTaskPoller) processWorkflowTask(task *workflowTask) error {
 	}
 }
 
+// completeMetrics flushes the buffered workflow metrics of the decision task once it is completed, and discards them
+// when the decision task failed or its completion could not be reported.
+func (task *workflowTask) completeMetrics(completedRequest interface{}, err error) {
+	if task.metricsBuffer == nil {
+		return
+	}
+	if _, failed := completedRequest.(*s.RespondDecisionTaskFailedRequest); failed || err != nil {
+		task.metricsBuffer.Discard()
+		return
+	}
+	task.metricsBuffer.Flush()
+}
+
 func (wtp *workflowTaskPoller) processResetStickinessTask(rst *resetStickinessTask) error {
 	tchCtx, cancel, opt := newChannelContext(context.Background(), wtp.featureFlags)
 	defer cancel()
@@ -407,18 +539,218 @@ func (wtp *workflowTaskPoller) RespondTaskCompletedWithMetrics(completedRequest
 		metricsScope.Counter(metrics.DecisionTaskCompletedCounter).Inc(1)
 	}
 
-	metricsScope.Timer(metrics.DecisionExecutionLatency).Record(time.Now().Sub(startTime))
+	executionLatency := time.Now().Sub(startTime)
+	metricsScope.Timer(metrics.DecisionExecutionLatency).Record(executionLatency)
+	if wtp.slowDecisionTaskThreshold > 0 && executionLatency > wtp.slowDecisionTaskThreshold {
+		wtp.logger.Warn("Slow decision task.",
+			zap.String(tagWorkflowType, task.WorkflowType.GetName()),
+			zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
+			zap.String(tagRunID, task.WorkflowExecution.GetRunId()),
+			zap.Int64("PreviousStartedEventID", task.GetPreviousStartedEventId()),
+			zap.Int64("StartedEventID", task.GetStartedEventId()),
+			zap.Duration("Duration", executionLatency),
+			zap.Duration("Threshold", wtp.slowDecisionTaskThreshold))
+	}
 
 	responseStartTime := time.Now()
-	if response, err = wtp.RespondTaskCompleted(completedRequest, task); err != nil {
+	response, err = wtp.RespondTaskCompleted(completedRequest, task)
+	if taskErr != nil {
+		wtp.handleDecisionTaskFailure(task, taskErr)
+	}
+	if err != nil {
 		metricsScope.Counter(metrics.DecisionResponseFailedCounter).Inc(1)
 		return
 	}
 	metricsScope.Timer(metrics.DecisionResponseLatency).Record(time.Now().Sub(responseStartTime))
+	// the listeners are only notified once the server accepted the decisions
+	if request, ok := completedRequest.(*s.RespondDecisionTaskCompletedRequest); ok {
+		wtp.notifyDecisionTaskCompleted(request, task)
+	}
 
 	return
 }
 
+// notifyDecisionTaskCompleted notifies the event listeners about the workflow and activity events caused by a
+// completed decision task.
+func (wtp *workflowTaskPoller) notifyDecisionTaskCompleted(request *s.RespondDecisionTaskCompletedRequest, task *s.PollForDecisionTaskResponse) {
+	if len(wtp.eventListeners) == 0 {
+		return
+	}
+	workflowEvent := WorkflowEvent{
+		Domain:       wtp.domain,
+		TaskList:     wtp.taskListName,
+		WorkflowType: task.WorkflowType.GetName(),
+		WorkflowExecution: WorkflowExecution{
+			ID:    task.WorkflowExecution.GetWorkflowId(),
+			RunID: task.WorkflowExecution.GetRunId(),
+		},
+	}
+	if isFirstDecisionTask(task) {
+		for _, listener := range wtp.eventListeners {
+			listener.OnWorkflowStarted(workflowEvent)
+		}
+	}
+	for _, decision := range request.Decisions {
+		var closeStatus WorkflowStatus
+		switch decision.GetDecisionType() {
+		case s.DecisionTypeScheduleActivityTask:
+			attributes := decision.ScheduleActivityTaskDecisionAttributes
+			activityEvent := ActivityEvent{
+				Domain:            wtp.domain,
+				TaskList:          attributes.TaskList.GetName(),
+				WorkflowType:      workflowEvent.WorkflowType,
+				WorkflowExecution: workflowEvent.WorkflowExecution,
+				ActivityType:      attributes.ActivityType.GetName(),
+				ActivityID:        attributes.GetActivityId(),
+			}
+			if attributes.Domain != nil {
+				activityEvent.Domain = attributes.GetDomain()
+			}
+			for _, listener := range wtp.eventListeners {
+				listener.OnActivityScheduled(activityEvent)
+			}
+			continue
+		case s.DecisionTypeCompleteWorkflowExecution:
+			closeStatus = WorkflowStatusCompleted
+		case s.DecisionTypeFailWorkflowExecution:
+			closeStatus = WorkflowStatusFailed
+		case s.DecisionTypeCancelWorkflowExecution:
+			closeStatus = WorkflowStatusCanceled
+		case s.DecisionTypeContinueAsNewWorkflowExecution:
+			closeStatus = WorkflowStatusContinuedAsNew
+		default:
+			continue
+		}
+		closedEvent := workflowEvent
+		closedEvent.CloseStatus = closeStatus
+		for _, listener := range wtp.eventListeners {
+			listener.OnWorkflowCompleted(closedEvent)
+		}
+	}
+}
+
+// isFirstDecisionTask returns true if no decision task of the workflow execution completed before the given one.
+// Unlike a zero PreviousStartedEventId this is false for a later decision task replayed after a cache miss, as its
+// full history contains the completed decision tasks. Sticky and heartbeat decision tasks only contain the events
+// after a completed decision task, so their history does not start with the first event.
+func isFirstDecisionTask(task *s.PollForDecisionTaskResponse) bool {
+	events := task.History.GetEvents()
+	if len(events) == 0 || events[0].GetEventId() != 1 {
+		return false
+	}
+	for _, event := range events {
+		if event.GetEventType() == s.EventTypeDecisionTaskCompleted {
+			return false
+		}
+	}
+	return true
+}
+
+// handleDecisionTaskFailure calls the failure threshold hook and applies the DecisionTaskFailurePolicy once the
+// decision task of a workflow failed decisionTaskFailureThreshold times in a row. The attempt of a decision task is
+// only reset by the server when a decision task completes, so attempt+1 is the number of consecutive failures.
+// The policy is only applied by the attempt reaching the threshold, not by the attempts failing after it.
+func (wtp *workflowTaskPoller) handleDecisionTaskFailure(task *s.PollForDecisionTaskResponse, taskErr error) {
+	if wtp.decisionTaskFailureThreshold <= 0 || task.GetAttempt()+1 < int64(wtp.decisionTaskFailureThreshold) {
+		return
+	}
+
+	workflowType := task.WorkflowType.GetName()
+	execution := WorkflowExecution{
+		ID:    task.WorkflowExecution.GetWorkflowId(),
+		RunID: task.WorkflowExecution.GetRunId(),
+	}
+	logger := wtp.logger.With(
+		zap.String(tagWorkflowType, workflowType),
+		zap.String(tagWorkflowID, execution.ID),
+		zap.String(tagRunID, execution.RunID),
+		zap.Int64("Attempt", task.GetAttempt()),
+	)
+	wtp.metricsScope.GetTaggedScope(tagWorkflowType, workflowType).Counter(metrics.DecisionTaskFailureThreshold).Inc(1)
+	logger.Warn("Decision task failure threshold reached.", zap.Error(taskErr))
+
+	if wtp.onDecisionTaskFailureThreshold != nil {
+		wtp.onDecisionTaskFailureThreshold(execution, taskErr)
+	}
+	if task.GetAttempt()+1 > int64(wtp.decisionTaskFailureThreshold) {
+		// the policy was applied by the attempt that reached the threshold
+		return
+	}
+
+	reason := fmt.Sprintf("decision task failed %v times in a row: %v", task.GetAttempt()+1, taskErr)
+	ctx, cancel := wtp.stopContext()
+	defer cancel()
+	var err error
+	switch wtp.decisionTaskFailurePolicy {
+	case DecisionTaskFailurePolicyTerminate:
+		request := &s.TerminateWorkflowExecutionRequest{
+			Domain:            common.StringPtr(wtp.domain),
+			WorkflowExecution: task.WorkflowExecution,
+			Reason:            common.StringPtr(reason),
+			Identity:          common.StringPtr(wtp.identity),
+		}
+		err = backoff.Retry(ctx,
+			func() error {
+				tchCtx, cancel, opt := newChannelContext(ctx, wtp.featureFlags)
+				defer cancel()
+				return wtp.service.TerminateWorkflowExecution(tchCtx, request, opt...)
+			}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	case DecisionTaskFailurePolicyReset:
+		resetEventID, alreadyReset := getDecisionTaskFailureResetPoint(task)
+		if resetEventID <= 0 {
+			logger.Warn("Skip resetting workflow without completed decision task.")
+			return
+		}
+		if alreadyReset {
+			// resetting again would replay the same events, which keep failing the decision task
+			logger.Warn("Skip resetting workflow already reset since its last completed decision task.")
+			return
+		}
+		request := &s.ResetWorkflowExecutionRequest{
+			Domain:                common.StringPtr(wtp.domain),
+			WorkflowExecution:     task.WorkflowExecution,
+			Reason:                common.StringPtr(reason),
+			DecisionFinishEventId: common.Int64Ptr(resetEventID),
+			RequestId:             common.StringPtr(uuid.New()),
+		}
+		err = backoff.Retry(ctx,
+			func() error {
+				tchCtx, cancel, opt := newChannelContext(ctx, wtp.featureFlags)
+				defer cancel()
+				_, err1 := wtp.service.ResetWorkflowExecution(tchCtx, request, opt...)
+				return err1
+			}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	}
+	if err != nil {
+		logger.Warn("Failed to apply decision task failure policy.", zap.Error(err))
+	}
+}
+
+// getDecisionTaskFailureResetPoint returns the ID of the DecisionTaskCompleted event of the last completed decision
+// task, or 0 if no decision task completed, and whether the workflow was already reset after it. A reset run records
+// a DecisionTaskFailed event with the ResetWorkflow cause at the reset point, which is followed by the failing
+// decision tasks until a decision task completes again.
+func getDecisionTaskFailureResetPoint(task *s.PollForDecisionTaskResponse) (int64, bool) {
+	var completedEventID int64
+	var resetEventID int64
+	for _, event := range task.History.GetEvents() {
+		switch event.GetEventType() {
+		case s.EventTypeDecisionTaskCompleted:
+			completedEventID = event.GetEventId()
+		case s.EventTypeDecisionTaskFailed:
+			if event.DecisionTaskFailedEventAttributes.GetCause() == s.DecisionTaskFailedCauseResetWorkflow {
+				resetEventID = event.GetEventId()
+			}
+		}
+	}
+	if completedEventID == 0 && task.GetPreviousStartedEventId() > 0 {
+		// the events are paginated and the first page doesn't hold the last completed decision task, which directly
+		// follows its DecisionTaskStarted event
+		completedEventID = task.GetPreviousStartedEventId() + 1
+	}
+	return completedEventID, resetEventID > completedEventID
+}
+
 func (wtp *workflowTaskPoller) RespondTaskCompleted(completedRequest interface{}, task *s.PollForDecisionTaskResponse) (response *s.RespondDecisionTaskCompletedResponse, err error) {
 	ctx := context.Background()
 	// Respond task completion.
@@ -452,8 +784,8 @@ func (wtp *workflowTaskPoller) RespondTaskCompleted(completedRequest interface{}
 				if wtp.ldaTunnel != nil {
 					for _, decision := range request.Decisions {
 						attr := decision.ScheduleActivityTaskDecisionAttributes
-						if attr != nil && wtp.taskListName == attr.TaskList.GetName() {
-							// assume the activity type is in registry otherwise the activity would be failed and retried from server
+						if attr != nil && wtp.taskListName == attr.TaskList.GetName() &&
+							wtp.ldaTunnel.canDispatch(attr.ActivityType.GetName()) {
 							activityTask := &locallyDispatchedActivityTask{
 								readyCh:                       make(chan bool, 1),
 								ActivityId:                    attr.ActivityId,
@@ -526,6 +858,7 @@ func newLocalActivityPoller(params workerExecutionParameters, laTunnel *localAct
 		dataConverter:      params.DataConverter,
 		contextPropagators: params.ContextPropagators,
 		tracer:             params.Tracer,
+		eventListeners:     params.EventListeners,
 	}
 	return &localActivityTaskPoller{
 		basePoller:   basePoller{shutdownC: params.WorkerStopChannel},
@@ -574,6 +907,7 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 	}
 
 	workflowTypeLocal := task.params.WorkflowInfo.WorkflowType
+	metricsTags := &activityMetricsTags{}
 
 	ctx := context.WithValue(rootCtx, activityEnvContextKey, &activityEnvironment{
 		workflowType:      &workflowTypeLocal,
@@ -584,6 +918,7 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 		workflowExecution: task.params.WorkflowInfo.WorkflowExecution,
 		logger:            lath.logger,
 		metricsScope:      metricsScope,
+		metricsTags:       metricsTags,
 		isLocalActivity:   true,
 		dataConverter:     lath.dataConverter,
 		attempt:           task.attempt,
@@ -605,7 +940,7 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 	// count all failures beyond this point, as they come from the activity itself
 	defer func() {
 		if result.err != nil {
-			metricsScope.Counter(metrics.LocalActivityFailedCounter).Inc(1)
+			metricsTags.tagged(metricsScope).Counter(metrics.LocalActivityFailedCounter).Inc(1)
 		}
 	}()
 
@@ -627,6 +962,28 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 	task.cancelFunc = cancel
 	task.Unlock()
 
+	if len(lath.eventListeners) > 0 {
+		activityEvent := ActivityEvent{
+			Domain:            task.params.WorkflowInfo.Domain,
+			TaskList:          task.params.WorkflowInfo.TaskListName,
+			WorkflowType:      workflowType,
+			WorkflowExecution: task.params.WorkflowInfo.WorkflowExecution,
+			ActivityType:      activityType,
+			ActivityID:        task.activityID,
+			Attempt:           task.attempt,
+			Local:             true,
+		}
+		for _, listener := range lath.eventListeners {
+			listener.OnActivityStarted(activityEvent)
+		}
+		defer func() {
+			activityEvent.Err = result.err
+			for _, listener := range lath.eventListeners {
+				listener.OnActivityCompleted(activityEvent)
+			}
+		}()
+	}
+
 	var laResult []byte
 	var err error
 	doneCh := make(chan struct{})
@@ -643,7 +1000,7 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 					zap.String(tagActivityType, activityType),
 					zap.String(tagPanicError, fmt.Sprintf("%v", p)),
 					zap.String(tagPanicStack, st))
-				metricsScope.Counter(metrics.LocalActivityPanicCounter).Inc(1)
+				metricsTags.tagged(metricsScope).Counter(metrics.LocalActivityPanicCounter).Inc(1)
 				err = newPanicError(p, st)
 			}
 		}()
@@ -653,7 +1010,7 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 		defer span.Finish()
 		laResult, err = ae.ExecuteWithActualArgs(ctx, task.params.InputArgs)
 		executionLatency := time.Now().Sub(laStartTime)
-		metricsScope.Timer(metrics.LocalActivityExecutionLatency).Record(executionLatency)
+		metricsTags.tagged(metricsScope).Timer(metrics.LocalActivityExecutionLatency).Record(executionLatency)
 		if executionLatency > timeoutDuration {
 			// If local activity takes longer than expected timeout, the context would already be DeadlineExceeded and
 			// the result would be discarded. Print a warning in this case.
@@ -760,27 +1117,35 @@ func (wtp *workflowTaskPoller) poll(ctx context.Context) (interface{}, error) {
 		wtp.logger.Debug("workflowTaskPoller::Poll")
 	})
 
-	request := wtp.getNextPollRequest()
-	defer wtp.release(request.TaskList.GetKind())
+	var response *s.PollForDecisionTaskResponse
+	var taskListKind s.TaskListKind
+	if hedged, ok := wtp.popHedgedResponse(); ok {
+		response, taskListKind = hedged.response, hedged.taskListKind
+	} else {
+		request := wtp.getNextPollRequest()
+		defer wtp.release(request.TaskList.GetKind())
+		taskListKind = request.TaskList.GetKind()
 
-	response, err := wtp.service.PollForDecisionTask(ctx, request, getYarpcCallOptions(wtp.featureFlags)...)
-	if err != nil {
-		if isServiceTransientError(err) {
-			wtp.metricsScope.Counter(metrics.DecisionPollTransientFailedCounter).Inc(1)
-		} else {
-			wtp.metricsScope.Counter(metrics.DecisionPollFailedCounter).Inc(1)
+		var err error
+		response, err = wtp.pollForDecisionTask(ctx, request)
+		if err != nil {
+			if isServiceTransientError(err) {
+				wtp.metricsScope.Counter(metrics.DecisionPollTransientFailedCounter).Inc(1)
+			} else {
+				wtp.metricsScope.Counter(metrics.DecisionPollFailedCounter).Inc(1)
+			}
+			wtp.updateBacklog(taskListKind, 0)
+			return nil, err
 		}
-		wtp.updateBacklog(request.TaskList.GetKind(), 0)
-		return nil, err
 	}
 
 	if response == nil || len(response.TaskToken) == 0 {
 		wtp.metricsScope.Counter(metrics.DecisionPollNoTaskCounter).Inc(1)
-		wtp.updateBacklog(request.TaskList.GetKind(), 0)
+		wtp.updateBacklog(taskListKind, 0)
 		return &workflowTask{}, nil
 	}
 
-	wtp.updateBacklog(request.TaskList.GetKind(), response.GetBacklogCountHint())
+	wtp.updateBacklog(taskListKind, response.GetBacklogCountHint())
 
 	task := wtp.toWorkflowTask(response)
 	traceLog(func() {
@@ -799,11 +1164,116 @@ func (wtp *workflowTaskPoller) poll(ctx context.Context) (interface{}, error) {
 	metricsScope.Counter(metrics.DecisionPollSucceedCounter).Inc(1)
 	metricsScope.Timer(metrics.DecisionPollLatency).Record(time.Now().Sub(startTime))
 
+	// sticky decisions are polled from the worker specific task list, report them against the workflow's task list
+	taskListName := response.WorkflowExecutionTaskList.GetName()
+	if taskListName == "" {
+		taskListName = wtp.taskListName
+	}
 	scheduledToStartLatency := time.Duration(response.GetStartedTimestamp() - response.GetScheduledTimestamp())
-	metricsScope.Timer(metrics.DecisionScheduledToStartLatency).Record(scheduledToStartLatency)
+	getMetricsScopeForWorkflowTaskList(wtp.metricsScope, response.WorkflowType.GetName(), taskListName).
+		Timer(metrics.DecisionScheduledToStartLatency).Record(scheduledToStartLatency)
 	return task, nil
 }
 
+// pollForDecisionTask sends the poll request, and a second hedged one if the first didn't return after
+// pollHedgingDelay.
+func (wtp *workflowTaskPoller) pollForDecisionTask(
+	ctx context.Context,
+	request *s.PollForDecisionTaskRequest,
+) (*s.PollForDecisionTaskResponse, error) {
+	if wtp.pollHedgingDelay <= 0 {
+		return wtp.service.PollForDecisionTask(ctx, request, getYarpcCallOptions(wtp.featureFlags)...)
+	}
+
+	// The requests get their own context: the ones still running when the poll returns are not canceled, as a task
+	// already dispatched to them would be lost until it times out. Their tasks are buffered for the next polls, only
+	// a worker shutting down drops them.
+	pollCtx, cancel, _ := newChannelContext(context.Background(), wtp.featureFlags, chanTimeout(pollTaskServiceTimeOut))
+	results := make(chan hedgedPollResult, 2)
+	poll := func() {
+		response, err := wtp.service.PollForDecisionTask(pollCtx, request, getYarpcCallOptions(wtp.featureFlags)...)
+		results <- hedgedPollResult{response: response, err: err}
+	}
+	bufferRemaining := func(running int) {
+		go func() {
+			defer cancel()
+			for ; running > 0; running-- {
+				select {
+				case result := <-results:
+					if hasDecisionTask(result) {
+						wtp.requestLock.Lock()
+						wtp.hedgedResponses = append(wtp.hedgedResponses, hedgedPollResponse{
+							response:     result.response,
+							taskListKind: request.TaskList.GetKind(),
+						})
+						wtp.requestLock.Unlock()
+					}
+				case <-wtp.shutdownC:
+					return
+				}
+			}
+		}()
+	}
+	go poll()
+
+	timer := time.NewTimer(wtp.pollHedgingDelay)
+	defer timer.Stop()
+	select {
+	case result := <-results:
+		cancel()
+		return result.response, result.err
+	case <-ctx.Done():
+		bufferRemaining(1)
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+	wtp.metricsScope.Counter(metrics.DecisionPollHedgedCounter).Inc(1)
+	go poll()
+
+	var first hedgedPollResult
+	select {
+	case first = <-results:
+	case <-ctx.Done():
+		bufferRemaining(2)
+		return nil, ctx.Err()
+	}
+	if hasDecisionTask(first) {
+		bufferRemaining(1)
+		return first.response, first.err
+	}
+
+	var second hedgedPollResult
+	select {
+	case second = <-results:
+	case <-ctx.Done():
+		bufferRemaining(1)
+		if first.err != nil {
+			return nil, first.err
+		}
+		return nil, ctx.Err()
+	}
+	cancel()
+	if hasDecisionTask(second) || first.err == nil {
+		return second.response, second.err
+	}
+	return first.response, first.err
+}
+
+func hasDecisionTask(result hedgedPollResult) bool {
+	return result.err == nil && result.response != nil && len(result.response.TaskToken) > 0
+}
+
+func (wtp *workflowTaskPoller) popHedgedResponse() (hedgedPollResponse, bool) {
+	wtp.requestLock.Lock()
+	defer wtp.requestLock.Unlock()
+	if len(wtp.hedgedResponses) == 0 {
+		return hedgedPollResponse{}, false
+	}
+	hedged := wtp.hedgedResponses[0]
+	wtp.hedgedResponses = wtp.hedgedResponses[1:]
+	return hedged, true
+}
+
 func (wtp *workflowTaskPoller) toWorkflowTask(response *s.PollForDecisionTaskResponse) *workflowTask {
 	startEventID := response.GetStartedEventId()
 	nextEventID := response.GetNextEventId()
@@ -833,6 +1303,7 @@ func (wtp *workflowTaskPoller) toWorkflowTask(response *s.PollForDecisionTaskRes
 		startedEventID: startEventID,
 		maxEventID:     nextEventID - 1,
 		featureFlags:   wtp.featureFlags,
+		prefetch:       wtp.historyPrefetch,
 	}
 	task := &workflowTask{
 		task:            response,
@@ -842,8 +1313,11 @@ func (wtp *workflowTaskPoller) toWorkflowTask(response *s.PollForDecisionTaskRes
 }
 
 func (h *historyIteratorImpl) GetNextPage() (*s.History, error) {
-	if h.iteratorFunc == nil {
-		h.iteratorFunc = newGetHistoryPageFunc(
+	if len(h.rawBatches) > 0 {
+		return h.nextRawBatch()
+	}
+	if h.iteratorFunc == nil && h.pageFunc == nil {
+		h.pageFunc = newGetLazyHistoryPageFunc(
 			context.Background(),
 			h.service,
 			h.domain,
@@ -854,20 +1328,96 @@ func (h *historyIteratorImpl) GetNextPage() (*s.History, error) {
 			h.featureFlags)
 	}
 
-	history, token, err := h.iteratorFunc(h.nextPageToken)
+	var page *historyPage
+	var err error
+	if prefetched := h.prefetched; prefetched != nil && bytes.Equal(prefetched.pageToken, h.nextPageToken) {
+		<-prefetched.done
+		page, err = prefetched.page, prefetched.err
+	} else {
+		h.waitForPrefetch()
+		page, err = h.fetchPage(h.nextPageToken)
+	}
+	h.prefetched = nil
+	if err != nil {
+		return nil, err
+	}
+	h.nextPageToken = page.nextPageToken
+	if h.prefetch && page.nextPageToken != nil {
+		h.prefetchPage(page.nextPageToken)
+	}
+	if page.rawBatches != nil {
+		if len(page.rawBatches) == 0 {
+			return &s.History{}, nil
+		}
+		h.rawBatches = page.rawBatches
+		return h.nextRawBatch()
+	}
+	return page.history, nil
+}
+
+// prefetchPage fetches the page of pageToken in the background, the next GetNextPage call picks it up.
+func (h *historyIteratorImpl) prefetchPage(pageToken []byte) {
+	prefetched := &historyPagePrefetch{pageToken: pageToken, done: make(chan struct{})}
+	h.prefetched = prefetched
+	go func() {
+		defer close(prefetched.done)
+		prefetched.page, prefetched.err = h.fetchPage(pageToken)
+	}()
+}
+
+func (h *historyIteratorImpl) fetchPage(pageToken []byte) (*historyPage, error) {
+	if h.iteratorFunc != nil {
+		history, token, err := h.iteratorFunc(pageToken)
+		if err != nil {
+			return nil, err
+		}
+		return &historyPage{history: history, nextPageToken: token}, nil
+	}
+	return h.pageFunc(pageToken)
+}
+
+// nextRawBatch decodes the next raw event batch of the current page. A batch with events after the ones the task is
+// for is truncated and ends the history.
+func (h *historyIteratorImpl) nextRawBatch() (*s.History, error) {
+	batch := h.rawBatches[0]
+	h.rawBatches = h.rawBatches[1:]
+	events, err := serializer.DeserializeBatchEvents(batch)
 	if err != nil {
 		return nil, err
 	}
-	h.nextPageToken = token
+	if len(events) == 0 {
+		return nil, &s.InternalServiceError{Message: "corrupted history event batch, empty events"}
+	}
+	history := &s.History{Events: events}
+	truncated, err := truncateHistory(history, h.startedEventID, h.maxEventID)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		h.rawBatches = nil
+		h.waitForPrefetch()
+		h.nextPageToken = nil
+	}
 	return history, nil
 }
 
+// waitForPrefetch drops the prefetched page, waiting for the fetch to finish so the iterator func is never
+// called concurrently.
+func (h *historyIteratorImpl) waitForPrefetch() {
+	if h.prefetched != nil {
+		<-h.prefetched.done
+		h.prefetched = nil
+	}
+}
+
 func (h *historyIteratorImpl) Reset() {
+	h.waitForPrefetch()
 	h.nextPageToken = nil
+	h.rawBatches = nil
 }
 
 func (h *historyIteratorImpl) HasNextPage() bool {
-	return h.nextPageToken != nil
+	return len(h.rawBatches) > 0 || h.nextPageToken != nil
 }
 
 func newGetHistoryPageFunc(
@@ -880,7 +1430,43 @@ func newGetHistoryPageFunc(
 	metricsScope tally.Scope,
 	featureFlags FeatureFlags,
 ) func(nextPageToken []byte) (*s.History, []byte, error) {
+	getPage := newGetLazyHistoryPageFunc(ctx, service, domain, execution, atDecisionTaskCompletedEventID, maxEventID, metricsScope, featureFlags)
 	return func(nextPageToken []byte) (*s.History, []byte, error) {
+		page, err := getPage(nextPageToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		if page.rawBatches == nil {
+			return page.history, page.nextPageToken, nil
+		}
+		h, err := serializer.DeserializeBlobDataToHistoryEvents(page.rawBatches, s.HistoryEventFilterTypeAllEvent)
+		if err != nil {
+			return nil, nil, err
+		}
+		truncated, err := truncateHistory(h, atDecisionTaskCompletedEventID, maxEventID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if truncated {
+			return h, nil, nil
+		}
+		return h, page.nextPageToken, nil
+	}
+}
+
+// newGetLazyHistoryPageFunc returns a func fetching the pages of a history. The event batches of a page the server
+// returned raw are left encoded, to be decoded and truncated one by one as they are replayed.
+func newGetLazyHistoryPageFunc(
+	ctx context.Context,
+	service workflowserviceclient.Interface,
+	domain string,
+	execution *s.WorkflowExecution,
+	atDecisionTaskCompletedEventID int64,
+	maxEventID int64,
+	metricsScope tally.Scope,
+	featureFlags FeatureFlags,
+) func(nextPageToken []byte) (*historyPage, error) {
+	return func(nextPageToken []byte) (*historyPage, error) {
 		metricsScope.Counter(metrics.WorkflowGetHistoryCounter).Inc(1)
 		startTime := time.Now()
 		var resp *s.GetWorkflowExecutionHistoryResponse
@@ -899,50 +1485,67 @@ func newGetHistoryPageFunc(
 			}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
 		if err != nil {
 			metricsScope.Counter(metrics.WorkflowGetHistoryFailedCounter).Inc(1)
-			return nil, nil, err
+			return nil, err
 		}
 
 		metricsScope.Counter(metrics.WorkflowGetHistorySucceedCounter).Inc(1)
 		metricsScope.Timer(metrics.WorkflowGetHistoryLatency).Record(time.Now().Sub(startTime))
 
-		var h *s.History
-
 		if resp.RawHistory != nil {
-			var err1 error
-			h, err1 = serializer.DeserializeBlobDataToHistoryEvents(resp.RawHistory, s.HistoryEventFilterTypeAllEvent)
-			if err1 != nil {
-				return nil, nil, err1
+			for _, batch := range resp.RawHistory {
+				if batch == nil {
+					return nil, &s.InternalServiceError{Message: "corrupted history event batch, nil batch"}
+				}
 			}
-		} else {
-			h = resp.History
+			return &historyPage{rawBatches: resp.RawHistory, nextPageToken: resp.NextPageToken}, nil
 		}
-
-		// TODO: is this check valid/useful? atDecisionTaskCompletedEventID is startedEventID in pollForDecisionTaskResponse and
-		// - For decision tasks, since there's only one inflight decision task, there won't be any event after startEventID.
-		//   Those events will be buffered. If there're too many buffer events, the current decision will be failed and events passed
-		//   startEventID may be returned. In that case, the last event after truncation is still decision task started event not completed.
-		// - For query tasks startEventID is not assigned so this check is never executed.
-		if shouldTruncateHistory(h, atDecisionTaskCompletedEventID) {
-			first := h.Events[0].GetEventId() // eventIds start from 1
-			h.Events = h.Events[:atDecisionTaskCompletedEventID-first+1]
-			if h.Events[len(h.Events)-1].GetEventType() != s.EventTypeDecisionTaskCompleted {
-				return nil, nil, fmt.Errorf("newGetHistoryPageFunc: atDecisionTaskCompletedEventID(%v) "+
-					"points to event that is not DecisionTaskCompleted", atDecisionTaskCompletedEventID)
-			}
-			return h, nil, nil
+		truncated, err := truncateHistory(resp.History, atDecisionTaskCompletedEventID, maxEventID)
+		if err != nil {
+			return nil, err
 		}
+		if truncated {
+			return &historyPage{history: resp.History}, nil
+		}
+		return &historyPage{history: resp.History, nextPageToken: resp.NextPageToken}, nil
+	}
+}
 
-		// TODO: Apply the check to decision tasks (remove the last condition)
-		// after validating maxEventID always equal to atDecisionTaskCompletedEventID (startedEventID).
-		// For now only apply to query task to be safe.
-		if shouldTruncateHistory(h, maxEventID) && isQueryTask(atDecisionTaskCompletedEventID) {
-			first := h.Events[0].GetEventId()
-			h.Events = h.Events[:maxEventID-first+1]
-			return h, nil, nil
+// truncateHistory drops the events of a history page after the events the task is for, and returns whether any
+// event was dropped, in which case the history ends with the page.
+func truncateHistory(h *s.History, atDecisionTaskCompletedEventID int64, maxEventID int64) (bool, error) {
+	// TODO: is this check valid/useful? atDecisionTaskCompletedEventID is startedEventID in pollForDecisionTaskResponse and
+	// - For decision tasks, since there's only one inflight decision task, there won't be any event after startEventID.
+	//   Those events will be buffered. If there're too many buffer events, the current decision will be failed and events passed
+	//   startEventID may be returned. In that case, the last event after truncation is still decision task started event not completed.
+	// - For query tasks startEventID is not assigned so this check is never executed.
+	if shouldTruncateHistory(h, atDecisionTaskCompletedEventID) {
+		first := h.Events[0].GetEventId() // eventIds start from 1
+		if first > atDecisionTaskCompletedEventID {
+			// the page only holds events after it, the previous one ended with it
+			h.Events = nil
+			return true, nil
+		}
+		h.Events = h.Events[:atDecisionTaskCompletedEventID-first+1]
+		if h.Events[len(h.Events)-1].GetEventType() != s.EventTypeDecisionTaskCompleted {
+			return false, fmt.Errorf("newGetHistoryPageFunc: atDecisionTaskCompletedEventID(%v) "+
+				"points to event that is not DecisionTaskCompleted", atDecisionTaskCompletedEventID)
 		}
+		return true, nil
+	}
 
-		return h, resp.NextPageToken, nil
+	// TODO: Apply the check to decision tasks (remove the last condition)
+	// after validating maxEventID always equal to atDecisionTaskCompletedEventID (startedEventID).
+	// For now only apply to query task to be safe.
+	if shouldTruncateHistory(h, maxEventID) && isQueryTask(atDecisionTaskCompletedEventID) {
+		first := h.Events[0].GetEventId()
+		if first > maxEventID {
+			h.Events = nil
+			return true, nil
+		}
+		h.Events = h.Events[:maxEventID-first+1]
+		return true, nil
 	}
+	return false, nil
 }
 
 func shouldTruncateHistory(h *s.History, maxEventID int64) bool {
@@ -968,6 +1571,9 @@ func newActivityTaskPoller(taskHandler ActivityTaskHandler, service workflowserv
 		metricsScope:        metrics.NewTaggedScope(params.MetricsScope),
 		activitiesPerSecond: params.TaskListActivitiesPerSecond,
 		featureFlags:        params.FeatureFlags,
+
+		slowActivityThreshold: params.SlowActivityThreshold,
+		eventListeners:        params.EventListeners,
 	}
 	return activityTaskPoller
 }
@@ -1030,7 +1636,8 @@ func (atp *activityTaskPoller) pollWithMetrics(ctx context.Context,
 	metricsScope.Timer(metrics.ActivityPollLatency).Record(time.Now().Sub(startTime))
 
 	scheduledToStartLatency := time.Duration(response.GetStartedTimestamp() - response.GetScheduledTimestampOfThisAttempt())
-	metricsScope.Timer(metrics.ActivityScheduledToStartLatency).Record(scheduledToStartLatency)
+	getMetricsScopeForActivityTaskList(atp.metricsScope, workflowType, activityType, atp.taskListName).
+		Timer(metrics.ActivityScheduledToStartLatency).Record(scheduledToStartLatency)
 
 	return &activityTask{task: response, pollStartTime: startTime}, nil
 }
@@ -1064,19 +1671,61 @@ func (atp *activityTaskPoller) ProcessTask(task interface{}) error {
 	activityType := activityTask.task.ActivityType.GetName()
 	metricsScope := getMetricsScopeForActivity(atp.metricsScope, workflowType, activityType)
 
+	activityEvent := ActivityEvent{
+		Domain:       atp.domain,
+		TaskList:     atp.taskListName,
+		WorkflowType: workflowType,
+		WorkflowExecution: WorkflowExecution{
+			ID:    activityTask.task.WorkflowExecution.GetWorkflowId(),
+			RunID: activityTask.task.WorkflowExecution.GetRunId(),
+		},
+		ActivityType: activityType,
+		ActivityID:   activityTask.task.GetActivityId(),
+		Attempt:      activityTask.task.GetAttempt(),
+	}
+	for _, listener := range atp.eventListeners {
+		listener.OnActivityStarted(activityEvent)
+	}
+
 	executionStartTime := time.Now()
 	// Process the activity task.
-	request, err := atp.taskHandler.Execute(atp.taskListName, activityTask.task)
+	metricsTags := &activityMetricsTags{}
+	request, err := executeActivityTask(atp.taskHandler, atp.taskListName, activityTask.task, metricsTags)
+	metricsScope = metricsTags.tagged(metricsScope)
+	executionLatency := time.Now().Sub(executionStartTime)
+	if atp.slowActivityThreshold > 0 && executionLatency > atp.slowActivityThreshold {
+		atp.logger.Warn("Slow activity task.",
+			zap.String(tagWorkflowType, workflowType),
+			zap.String(tagWorkflowID, activityTask.task.WorkflowExecution.GetWorkflowId()),
+			zap.String(tagRunID, activityTask.task.WorkflowExecution.GetRunId()),
+			zap.String(tagActivityType, activityType),
+			zap.String(tagActivityID, activityTask.task.GetActivityId()),
+			zap.Int32("Attempt", activityTask.task.GetAttempt()),
+			zap.Duration("Duration", executionLatency),
+			zap.Duration("Threshold", atp.slowActivityThreshold))
+	}
 	if err != nil {
 		metricsScope.Counter(metrics.ActivityExecutionFailedCounter).Inc(1)
 		return err
 	}
-	metricsScope.Timer(metrics.ActivityExecutionLatency).Record(time.Now().Sub(executionStartTime))
+	metricsScope.Timer(metrics.ActivityExecutionLatency).Record(executionLatency)
 
 	if request == ErrActivityResultPending {
 		return nil
 	}
 
+	if len(atp.eventListeners) > 0 {
+		switch request := request.(type) {
+		case *s.RespondActivityTaskFailedRequest:
+			activityEvent.Err = NewCustomError(request.GetReason())
+		case *s.RespondActivityTaskCanceledRequest:
+			activityEvent.Err = NewCanceledError()
+		}
+		for _, listener := range atp.eventListeners {
+			listener.OnActivityCompleted(activityEvent)
+		}
+	}
+
 	// if worker is shutting down, don't bother reporting activity completion
 	if atp.shuttingDown() {
 		return errShutdown
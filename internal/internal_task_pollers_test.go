@@ -26,9 +26,21 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/mock/gomock"
+	"github.com/opentracing/opentracing-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally/v4"
+	"go.uber.org/yarpc"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
 )
 
 func TestLocalActivityPanic(t *testing.T) {
@@ -54,3 +66,886 @@ func TestLocalActivityPanic(t *testing.T) {
 	assert.Contains(t, perr.StackTrace(), "panic")
 	assert.Contains(t, perr.StackTrace(), t.Name(), "should mention the source location of the local activity that panicked")
 }
+
+func TestDecisionTaskFailureThreshold(t *testing.T) {
+	newTask := func(attempt, previousStartedEventID int64) *s.PollForDecisionTaskResponse {
+		return &s.PollForDecisionTaskResponse{
+			TaskToken:              []byte("token"),
+			WorkflowExecution:      &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+			WorkflowType:           &s.WorkflowType{Name: common.StringPtr("wt")},
+			Attempt:                common.Int64Ptr(attempt),
+			PreviousStartedEventId: common.Int64Ptr(previousStartedEventID),
+		}
+	}
+	newPoller := func(mockCtrl *gomock.Controller, policy DecisionTaskFailurePolicy, hook func(WorkflowExecution, error)) (*workflowTaskPoller, *workflowservicetest.MockClient) {
+		service := workflowservicetest.NewMockClient(mockCtrl)
+		poller := newWorkflowTaskPoller(nil, nil, service, "domain", workerExecutionParameters{
+			TaskList:                       "tasklist",
+			Identity:                       "identity",
+			MetricsScope:                   tally.NoopScope,
+			Logger:                         zap.NewNop(),
+			DecisionTaskFailureThreshold:   3,
+			OnDecisionTaskFailureThreshold: hook,
+			DecisionTaskFailurePolicy:      policy,
+		})
+		return poller, service
+	}
+	taskErr := errors.New("workflow panic")
+
+	t.Run("below threshold", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		poller, service := newPoller(mockCtrl, DecisionTaskFailurePolicyTerminate, func(WorkflowExecution, error) {
+			t.Fatal("hook should not be called below the threshold")
+		})
+		service.EXPECT().RespondDecisionTaskFailed(gomock.Any(), gomock.Any(), callOptions()...).Return(nil)
+		_, err := poller.RespondTaskCompletedWithMetrics(nil, taskErr, newTask(0, 5), time.Now())
+		require.NoError(t, err)
+		_, err = poller.RespondTaskCompletedWithMetrics(nil, taskErr, newTask(1, 5), time.Now())
+		require.NoError(t, err)
+	})
+
+	t.Run("terminate", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		var hookExecution WorkflowExecution
+		var hookErr error
+		poller, service := newPoller(mockCtrl, DecisionTaskFailurePolicyTerminate, func(execution WorkflowExecution, err error) {
+			hookExecution, hookErr = execution, err
+		})
+		service.EXPECT().TerminateWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+			func(_ context.Context, request *s.TerminateWorkflowExecutionRequest, _ ...yarpc.CallOption) error {
+				assert.Equal(t, "wid", request.WorkflowExecution.GetWorkflowId())
+				assert.Equal(t, "rid", request.WorkflowExecution.GetRunId())
+				assert.Contains(t, request.GetReason(), "3 times in a row")
+				return nil
+			})
+		_, err := poller.RespondTaskCompletedWithMetrics(nil, taskErr, newTask(2, 5), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, WorkflowExecution{ID: "wid", RunID: "rid"}, hookExecution)
+		assert.Equal(t, taskErr, hookErr)
+	})
+
+	t.Run("beyond threshold", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		hookCalls := 0
+		poller, _ := newPoller(mockCtrl, DecisionTaskFailurePolicyTerminate, func(WorkflowExecution, error) {
+			hookCalls++
+		})
+		// the policy was applied by the attempt reaching the threshold, only the hook is called again
+		_, err := poller.RespondTaskCompletedWithMetrics(nil, taskErr, newTask(3, 5), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 1, hookCalls)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		poller, service := newPoller(mockCtrl, DecisionTaskFailurePolicyReset, nil)
+		service.EXPECT().ResetWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+			func(_ context.Context, request *s.ResetWorkflowExecutionRequest, _ ...yarpc.CallOption) (*s.ResetWorkflowExecutionResponse, error) {
+				assert.Equal(t, int64(4), request.GetDecisionFinishEventId())
+				assert.NotEmpty(t, request.GetRequestId())
+				return &s.ResetWorkflowExecutionResponse{}, nil
+			})
+		task := newTask(2, 9)
+		task.History = &s.History{Events: []*s.HistoryEvent{
+			createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{}),
+			createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{}),
+			createTestEventDecisionTaskStarted(3),
+			createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{}),
+			createTestEventDecisionTaskScheduled(5, &s.DecisionTaskScheduledEventAttributes{}),
+			createTestEventDecisionTaskStarted(6),
+			createTestEventDecisionTaskFailed(7, &s.DecisionTaskFailedEventAttributes{}),
+		}}
+		_, err := poller.RespondTaskCompletedWithMetrics(nil, taskErr, task, time.Now())
+		require.NoError(t, err)
+
+		// nothing to reset to before the first decision task completed
+		_, err = poller.RespondTaskCompletedWithMetrics(nil, taskErr, newTask(2, 0), time.Now())
+		require.NoError(t, err)
+
+		// the reset run keeps failing from the same decision task
+		task = newTask(2, 3)
+		task.History = &s.History{Events: []*s.HistoryEvent{
+			createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{}),
+			createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{}),
+			createTestEventDecisionTaskStarted(3),
+			createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{}),
+			createTestEventDecisionTaskScheduled(5, &s.DecisionTaskScheduledEventAttributes{}),
+			createTestEventDecisionTaskStarted(6),
+			createTestEventDecisionTaskFailed(7, &s.DecisionTaskFailedEventAttributes{
+				Cause: s.DecisionTaskFailedCauseResetWorkflow.Ptr(),
+			}),
+		}}
+		_, err = poller.RespondTaskCompletedWithMetrics(nil, taskErr, task, time.Now())
+		require.NoError(t, err)
+	})
+}
+
+func TestActivityMetricsTags(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	service.EXPECT().RespondActivityTaskCompleted(gomock.Any(), gomock.Any(), callOptions()...).Return(nil)
+
+	registry := newRegistry()
+	registry.RegisterActivityWithOptions(func(ctx context.Context) error {
+		scope := GetActivityMetricsScope(ctx)
+		scope.Tagged(map[string]string{"tier": "gold"}).Counter("custom").Inc(1)
+		scope.SubScope("sub").Tagged(map[string]string{"region": "east"}).Counter("regional").Inc(1)
+		// the tags of the Tagged scopes don't tag the other scopes of the activity
+		GetActivityMetricsScope(ctx).Counter("plain").Inc(1)
+		return nil
+	}, RegisterActivityOptions{Name: "test"})
+
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:     "tasklist",
+		Identity:     "identity",
+		Logger:       zap.NewNop(),
+		MetricsScope: testScope,
+		Tracer:       opentracing.NoopTracer{},
+	}
+	poller := newActivityTaskPoller(newActivityTaskHandler(service, params, registry), service, "domain", params)
+	now := time.Now()
+	task := &s.PollForActivityTaskResponse{
+		TaskToken:                       []byte("token"),
+		WorkflowExecution:               &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+		ActivityType:                    &s.ActivityType{Name: common.StringPtr("test")},
+		ActivityId:                      common.StringPtr("aid"),
+		ScheduledTimestamp:              common.Int64Ptr(now.UnixNano()),
+		ScheduledTimestampOfThisAttempt: common.Int64Ptr(now.UnixNano()),
+		ScheduleToCloseTimeoutSeconds:   common.Int32Ptr(10),
+		StartedTimestamp:                common.Int64Ptr(now.UnixNano()),
+		StartToCloseTimeoutSeconds:      common.Int32Ptr(10),
+		WorkflowType:                    &s.WorkflowType{Name: common.StringPtr("wt")},
+		WorkflowDomain:                  common.StringPtr("domain"),
+	}
+	require.NoError(t, poller.ProcessTask(&activityTask{task: task, pollStartTime: now}))
+
+	snapshot := testScope.Snapshot()
+	counterTags := make(map[string]map[string]string)
+	for _, counter := range snapshot.Counters() {
+		counterTags[counter.Name()] = counter.Tags()
+	}
+	assert.Equal(t, "gold", counterTags["custom"]["tier"])
+	assert.NotContains(t, counterTags["custom"], "region")
+	assert.Equal(t, "east", counterTags["sub.regional"]["region"])
+	assert.NotContains(t, counterTags["sub.regional"], "tier")
+	assert.NotContains(t, counterTags["plain"], "tier")
+	assert.NotContains(t, counterTags["plain"], "region")
+	assert.Equal(t, "gold", counterTags[metrics.ActivityTaskCompletedCounter]["tier"])
+	assert.Equal(t, "east", counterTags[metrics.ActivityTaskCompletedCounter]["region"])
+	var tagged bool
+	for _, timer := range snapshot.Timers() {
+		if timer.Name() == metrics.ActivityExecutionLatency {
+			tagged = timer.Tags()["tier"] == "gold" && timer.Tags()["region"] == "east"
+		}
+	}
+	assert.True(t, tagged, metrics.ActivityExecutionLatency)
+}
+
+func TestScheduledToStartLatencyTaskListTags(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:     "tasklist",
+		Identity:     "identity",
+		Logger:       zap.NewNop(),
+		MetricsScope: testScope,
+		Tracer:       opentracing.NoopTracer{},
+	}
+	now := time.Now()
+
+	// sticky decision tasks are reported against the workflow's task list
+	service.EXPECT().PollForDecisionTask(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *s.PollForDecisionTaskRequest, _ ...yarpc.CallOption) (*s.PollForDecisionTaskResponse, error) {
+			assert.Equal(t, s.TaskListKindSticky, request.TaskList.GetKind())
+			return &s.PollForDecisionTaskResponse{
+				TaskToken:                 []byte("token"),
+				WorkflowExecution:         &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+				WorkflowType:              &s.WorkflowType{Name: common.StringPtr("wt")},
+				WorkflowExecutionTaskList: &s.TaskList{Name: common.StringPtr("tasklist")},
+				ScheduledTimestamp:        common.Int64Ptr(now.Add(-time.Second).UnixNano()),
+				StartedTimestamp:          common.Int64Ptr(now.UnixNano()),
+			}, nil
+		})
+	decisionPoller := newWorkflowTaskPoller(nil, nil, service, "domain", params)
+	_, err := decisionPoller.poll(context.Background())
+	require.NoError(t, err)
+
+	service.EXPECT().PollForActivityTask(gomock.Any(), gomock.Any(), callOptions()...).Return(&s.PollForActivityTaskResponse{
+		TaskToken:                       []byte("token"),
+		ActivityType:                    &s.ActivityType{Name: common.StringPtr("at")},
+		WorkflowType:                    &s.WorkflowType{Name: common.StringPtr("wt")},
+		ScheduledTimestampOfThisAttempt: common.Int64Ptr(now.Add(-time.Second).UnixNano()),
+		StartedTimestamp:                common.Int64Ptr(now.UnixNano()),
+	}, nil)
+	activityPoller := newActivityTaskPoller(nil, service, "domain", params)
+	_, err = activityPoller.pollWithMetrics(context.Background(), activityPoller.poll)
+	require.NoError(t, err)
+
+	timers := map[string]map[string]string{}
+	for _, timer := range testScope.Snapshot().Timers() {
+		if _, ok := timer.Tags()[tagTaskList]; ok {
+			timers[timer.Name()] = timer.Tags()
+		}
+	}
+	require.Contains(t, timers, metrics.DecisionScheduledToStartLatency)
+	assert.Equal(t, "wt", timers[metrics.DecisionScheduledToStartLatency][tagWorkflowType])
+	assert.Equal(t, "tasklist", timers[metrics.DecisionScheduledToStartLatency][tagTaskList])
+	require.Contains(t, timers, metrics.ActivityScheduledToStartLatency)
+	assert.Equal(t, "wt", timers[metrics.ActivityScheduledToStartLatency][tagWorkflowType])
+	assert.Equal(t, "at", timers[metrics.ActivityScheduledToStartLatency][tagActivityType])
+	assert.Equal(t, "tasklist", timers[metrics.ActivityScheduledToStartLatency][tagTaskList])
+}
+
+func TestSlowTaskLogging(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	core, observed := observer.New(zapcore.WarnLevel)
+	params := workerExecutionParameters{
+		TaskList:                  "tasklist",
+		Identity:                  "identity",
+		Logger:                    zap.New(core),
+		MetricsScope:              tally.NoopScope,
+		Tracer:                    opentracing.NoopTracer{},
+		SlowDecisionTaskThreshold: time.Second,
+		SlowActivityThreshold:     time.Millisecond,
+	}
+
+	service.EXPECT().RespondDecisionTaskCompleted(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, nil).Times(2)
+	decisionPoller := newWorkflowTaskPoller(nil, nil, service, "domain", params)
+	decisionTask := &s.PollForDecisionTaskResponse{
+		TaskToken:              []byte("token"),
+		WorkflowExecution:      &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+		WorkflowType:           &s.WorkflowType{Name: common.StringPtr("wt")},
+		PreviousStartedEventId: common.Int64Ptr(3),
+		StartedEventId:         common.Int64Ptr(8),
+	}
+	completed := &s.RespondDecisionTaskCompletedRequest{TaskToken: []byte("token")}
+	_, err := decisionPoller.RespondTaskCompletedWithMetrics(completed, nil, decisionTask, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, observed.FilterMessage("Slow decision task.").Len())
+	_, err = decisionPoller.RespondTaskCompletedWithMetrics(completed, nil, decisionTask, time.Now().Add(-2*time.Second))
+	require.NoError(t, err)
+	logs := observed.FilterMessage("Slow decision task.").All()
+	require.Len(t, logs, 1)
+	fields := logs[0].ContextMap()
+	assert.Equal(t, "wt", fields[tagWorkflowType])
+	assert.Equal(t, int64(3), fields["PreviousStartedEventID"])
+	assert.Equal(t, int64(8), fields["StartedEventID"])
+
+	service.EXPECT().RespondActivityTaskCompleted(gomock.Any(), gomock.Any(), callOptions()...).Return(nil)
+	registry := newRegistry()
+	registry.RegisterActivityWithOptions(func(ctx context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, RegisterActivityOptions{Name: "slow"})
+	activityPoller := newActivityTaskPoller(newActivityTaskHandler(service, params, registry), service, "domain", params)
+	now := time.Now()
+	task := &s.PollForActivityTaskResponse{
+		TaskToken:                       []byte("token"),
+		WorkflowExecution:               &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+		ActivityType:                    &s.ActivityType{Name: common.StringPtr("slow")},
+		ActivityId:                      common.StringPtr("aid"),
+		ScheduledTimestamp:              common.Int64Ptr(now.UnixNano()),
+		ScheduledTimestampOfThisAttempt: common.Int64Ptr(now.UnixNano()),
+		ScheduleToCloseTimeoutSeconds:   common.Int32Ptr(10),
+		StartedTimestamp:                common.Int64Ptr(now.UnixNano()),
+		StartToCloseTimeoutSeconds:      common.Int32Ptr(10),
+		WorkflowType:                    &s.WorkflowType{Name: common.StringPtr("wt")},
+		WorkflowDomain:                  common.StringPtr("domain"),
+	}
+	require.NoError(t, activityPoller.ProcessTask(&activityTask{task: task, pollStartTime: now}))
+	logs = observed.FilterMessage("Slow activity task.").All()
+	require.Len(t, logs, 1)
+	fields = logs[0].ContextMap()
+	assert.Equal(t, "wt", fields[tagWorkflowType])
+	assert.Equal(t, "slow", fields[tagActivityType])
+	assert.Equal(t, "aid", fields[tagActivityID])
+}
+
+type recordingEventListener struct {
+	WorkerEventListenerBase
+	events []string
+}
+
+func (l *recordingEventListener) OnWorkflowStarted(event WorkflowEvent) {
+	l.events = append(l.events, "started:"+event.WorkflowType)
+}
+
+func (l *recordingEventListener) OnWorkflowCompleted(event WorkflowEvent) {
+	l.events = append(l.events, "completed:"+string(event.CloseStatus))
+}
+
+func (l *recordingEventListener) OnActivityScheduled(event ActivityEvent) {
+	l.events = append(l.events, "scheduled:"+event.ActivityType+":"+event.TaskList)
+}
+
+func (l *recordingEventListener) OnActivityStarted(event ActivityEvent) {
+	if event.Local {
+		l.events = append(l.events, "localActivityStarted:"+event.ActivityType)
+		return
+	}
+	l.events = append(l.events, "activityStarted:"+event.ActivityID)
+}
+
+func (l *recordingEventListener) OnActivityCompleted(event ActivityEvent) {
+	l.events = append(l.events, "activityCompleted:"+event.Err.Error())
+}
+
+func TestWorkerEventListeners(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	listener := &recordingEventListener{}
+	params := workerExecutionParameters{
+		TaskList:       "tasklist",
+		Identity:       "identity",
+		Logger:         zap.NewNop(),
+		MetricsScope:   tally.NoopScope,
+		Tracer:         opentracing.NoopTracer{},
+		EventListeners: []WorkerEventListener{listener},
+	}
+
+	gomock.InOrder(
+		service.EXPECT().RespondDecisionTaskCompleted(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, nil),
+		service.EXPECT().RespondDecisionTaskCompleted(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, &s.EntityNotExistsError{}),
+		service.EXPECT().RespondDecisionTaskCompleted(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, nil),
+	)
+	decisionPoller := newWorkflowTaskPoller(nil, nil, service, "domain", params)
+	task := &s.PollForDecisionTaskResponse{
+		TaskToken:         []byte("token"),
+		WorkflowExecution: &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+		WorkflowType:      &s.WorkflowType{Name: common.StringPtr("wt")},
+		History: &s.History{Events: []*s.HistoryEvent{
+			createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{}),
+			createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{}),
+			createTestEventDecisionTaskStarted(3),
+		}},
+	}
+	scheduleActivity := createNewDecision(s.DecisionTypeScheduleActivityTask)
+	scheduleActivity.ScheduleActivityTaskDecisionAttributes = &s.ScheduleActivityTaskDecisionAttributes{
+		ActivityId:   common.StringPtr("0"),
+		ActivityType: &s.ActivityType{Name: common.StringPtr("at")},
+		TaskList:     &s.TaskList{Name: common.StringPtr("activities")},
+	}
+	_, err := decisionPoller.RespondTaskCompletedWithMetrics(&s.RespondDecisionTaskCompletedRequest{
+		Decisions: []*s.Decision{scheduleActivity},
+	}, nil, task, time.Now())
+	require.NoError(t, err)
+
+	// a later decision task replayed from the full history after a cache miss does not start the workflow again,
+	// whatever its PreviousStartedEventId
+	task.History.Events = append(task.History.Events,
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{}),
+		createTestEventActivityTaskScheduled(5, &s.ActivityTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskScheduled(6, &s.DecisionTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskStarted(7),
+	)
+	// the listeners are not notified when the decisions are rejected
+	_, err = decisionPoller.RespondTaskCompletedWithMetrics(&s.RespondDecisionTaskCompletedRequest{
+		Decisions: []*s.Decision{createNewDecision(s.DecisionTypeCompleteWorkflowExecution)},
+	}, nil, task, time.Now())
+	require.Error(t, err)
+	_, err = decisionPoller.RespondTaskCompletedWithMetrics(&s.RespondDecisionTaskCompletedRequest{
+		Decisions: []*s.Decision{createNewDecision(s.DecisionTypeCompleteWorkflowExecution)},
+	}, nil, task, time.Now())
+	require.NoError(t, err)
+
+	service.EXPECT().RespondActivityTaskFailed(gomock.Any(), gomock.Any(), callOptions()...).Return(nil)
+	registry := newRegistry()
+	registry.RegisterActivityWithOptions(func(ctx context.Context) error {
+		return NewCustomError("bad input")
+	}, RegisterActivityOptions{Name: "at"})
+	activityPoller := newActivityTaskPoller(newActivityTaskHandler(service, params, registry), service, "domain", params)
+	now := time.Now()
+	activity := &s.PollForActivityTaskResponse{
+		TaskToken:                       []byte("token"),
+		WorkflowExecution:               &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+		ActivityType:                    &s.ActivityType{Name: common.StringPtr("at")},
+		ActivityId:                      common.StringPtr("0"),
+		ScheduledTimestamp:              common.Int64Ptr(now.UnixNano()),
+		ScheduledTimestampOfThisAttempt: common.Int64Ptr(now.UnixNano()),
+		ScheduleToCloseTimeoutSeconds:   common.Int32Ptr(10),
+		StartedTimestamp:                common.Int64Ptr(now.UnixNano()),
+		StartToCloseTimeoutSeconds:      common.Int32Ptr(10),
+		WorkflowType:                    &s.WorkflowType{Name: common.StringPtr("wt")},
+		WorkflowDomain:                  common.StringPtr("domain"),
+	}
+	require.NoError(t, activityPoller.ProcessTask(&activityTask{task: activity, pollStartTime: now}))
+
+	localActivityPoller := newLocalActivityPoller(params, nil)
+	result := localActivityPoller.handler.executeLocalActivityTask(&localActivityTask{
+		activityID: "1",
+		params: &executeLocalActivityParams{
+			localActivityOptions: localActivityOptions{ScheduleToCloseTimeoutSeconds: 10},
+			ActivityFn: func(ctx context.Context) error {
+				return NewCustomError("local failure")
+			},
+			ActivityType: "lat",
+			WorkflowInfo: &WorkflowInfo{
+				WorkflowType:      WorkflowType{Name: "wt"},
+				Domain:            "domain",
+				TaskListName:      "tasklist",
+				WorkflowExecution: WorkflowExecution{ID: "wid", RunID: "rid"},
+			},
+		},
+	})
+	require.Error(t, result.err)
+
+	assert.Equal(t, []string{
+		"started:wt",
+		"scheduled:at:activities",
+		"completed:COMPLETED",
+		"activityStarted:0",
+		"activityCompleted:bad input",
+		"localActivityStarted:lat",
+		"activityCompleted:local failure",
+	}, listener.events)
+}
+
+func TestHistoryIteratorPrefetch(t *testing.T) {
+	pages := map[string]*s.History{
+		"":  {Events: []*s.HistoryEvent{createTestEventDecisionTaskStarted(1)}},
+		"2": {Events: []*s.HistoryEvent{createTestEventDecisionTaskStarted(2)}},
+		"3": {Events: []*s.HistoryEvent{createTestEventDecisionTaskStarted(3)}},
+	}
+	nextTokens := map[string][]byte{"": []byte("2"), "2": []byte("3")}
+	fetched := make(chan string, len(pages))
+	iterator := &historyIteratorImpl{
+		prefetch: true,
+		iteratorFunc: func(nextPageToken []byte) (*s.History, []byte, error) {
+			fetched <- string(nextPageToken)
+			return pages[string(nextPageToken)], nextTokens[string(nextPageToken)], nil
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		var eventIDs []int64
+		for _, token := range []string{"", "2", "3"} {
+			if token != "" {
+				// the page was fetched before it is asked for
+				select {
+				case f := <-fetched:
+					require.Equal(t, token, f)
+				case <-time.After(time.Second):
+					require.FailNow(t, "page not prefetched", token)
+				}
+			}
+			page, err := iterator.GetNextPage()
+			require.NoError(t, err)
+			eventIDs = append(eventIDs, page.Events[0].GetEventId())
+			if token == "" {
+				require.Equal(t, "", <-fetched)
+			}
+		}
+		require.Equal(t, []int64{1, 2, 3}, eventIDs)
+		require.False(t, iterator.HasNextPage())
+		require.Empty(t, fetched)
+		iterator.Reset()
+	}
+}
+
+func TestHistoryIteratorRawHistory(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	corrupted := &s.DataBlob{EncodingType: s.EncodingTypeThriftRW.Ptr(), Data: []byte("corrupted")}
+	service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&s.GetWorkflowExecutionHistoryResponse{
+		RawHistory: []*s.DataBlob{
+			serializeEvents([]*s.HistoryEvent{
+				createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{}),
+				createTestEventDecisionTaskStarted(3),
+			}),
+			corrupted,
+		},
+		NextPageToken: []byte("2"),
+	}, nil)
+	iterator := &historyIteratorImpl{
+		nextPageToken:  []byte("1"),
+		execution:      &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+		domain:         "domain",
+		service:        service,
+		metricsScope:   tally.NoopScope,
+		startedEventID: 3,
+	}
+
+	// the batches of a raw page are decoded one at a time, when they are asked for
+	page, err := iterator.GetNextPage()
+	require.NoError(t, err)
+	require.Len(t, page.Events, 2)
+	require.True(t, iterator.HasNextPage())
+	_, err = iterator.GetNextPage()
+	require.Error(t, err)
+
+	// a batch past the started event ends the history
+	service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&s.GetWorkflowExecutionHistoryResponse{
+		RawHistory: []*s.DataBlob{
+			serializeEvents([]*s.HistoryEvent{
+				createTestEventDecisionTaskStarted(3),
+				createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{}),
+				createTestEventDecisionTaskScheduled(5, &s.DecisionTaskScheduledEventAttributes{}),
+			}),
+			corrupted,
+		},
+		NextPageToken: []byte("3"),
+	}, nil)
+	iterator.startedEventID = 4
+	page, err = iterator.GetNextPage()
+	require.NoError(t, err)
+	require.Len(t, page.Events, 2)
+	require.False(t, iterator.HasNextPage())
+}
+
+func TestHedgedDecisionPoll(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:                 "tasklist",
+		Identity:                 "identity",
+		Logger:                   zap.NewNop(),
+		MetricsScope:             testScope,
+		Tracer:                   opentracing.NoopTracer{},
+		DisableStickyExecution:   true,
+		DecisionPollHedgingDelay: 10 * time.Millisecond,
+	}
+	newResponse := func(runID string) *s.PollForDecisionTaskResponse {
+		return &s.PollForDecisionTaskResponse{
+			TaskToken:         []byte(runID),
+			WorkflowExecution: &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr(runID)},
+			WorkflowType:      &s.WorkflowType{Name: common.StringPtr("wt")},
+		}
+	}
+
+	// the first request is slow, the hedged one returns a task
+	releaseSlowPoll := make(chan struct{})
+	service.EXPECT().PollForDecisionTask(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(ctx context.Context, _ *s.PollForDecisionTaskRequest, _ ...yarpc.CallOption) (*s.PollForDecisionTaskResponse, error) {
+			<-releaseSlowPoll
+			return newResponse("slow"), nil
+		})
+	service.EXPECT().PollForDecisionTask(gomock.Any(), gomock.Any(), callOptions()...).Return(newResponse("hedged"), nil)
+	decisionPoller := newWorkflowTaskPoller(nil, nil, service, "domain", params)
+	task, err := decisionPoller.poll(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "hedged", task.(*workflowTask).task.WorkflowExecution.GetRunId())
+
+	// the task of the slow request is returned by the next poll, without polling again
+	close(releaseSlowPoll)
+	require.Eventually(t, func() bool {
+		decisionPoller.requestLock.Lock()
+		defer decisionPoller.requestLock.Unlock()
+		return len(decisionPoller.hedgedResponses) == 1
+	}, time.Second, time.Millisecond)
+	task, err = decisionPoller.poll(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "slow", task.(*workflowTask).task.WorkflowExecution.GetRunId())
+
+	// a fast request is not hedged
+	service.EXPECT().PollForDecisionTask(gomock.Any(), gomock.Any(), callOptions()...).Return(newResponse("fast"), nil)
+	task, err = decisionPoller.poll(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "fast", task.(*workflowTask).task.WorkflowExecution.GetRunId())
+	require.EqualValues(t, 1, testScope.Snapshot().Counters()[metrics.DecisionPollHedgedCounter+"+"].Value())
+
+	// a task dispatched after the poll gave up is not dropped either
+	releaseSlowPoll = make(chan struct{})
+	service.EXPECT().PollForDecisionTask(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(ctx context.Context, _ *s.PollForDecisionTaskRequest, _ ...yarpc.CallOption) (*s.PollForDecisionTaskResponse, error) {
+			<-releaseSlowPoll
+			return newResponse("late"), nil
+		})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = decisionPoller.poll(ctx)
+	require.Equal(t, context.Canceled, err)
+	close(releaseSlowPoll)
+	require.Eventually(t, func() bool {
+		decisionPoller.requestLock.Lock()
+		defer decisionPoller.requestLock.Unlock()
+		return len(decisionPoller.hedgedResponses) == 1
+	}, time.Second, time.Millisecond)
+	task, err = decisionPoller.poll(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "late", task.(*workflowTask).task.WorkflowExecution.GetRunId())
+}
+
+func TestEagerActivityDispatch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	params := workerExecutionParameters{
+		TaskList:               "tasklist",
+		Identity:               "identity",
+		Logger:                 zap.NewNop(),
+		MetricsScope:           tally.NoopScope,
+		Tracer:                 opentracing.NoopTracer{},
+		DisableStickyExecution: true,
+	}
+	registry := newRegistry()
+	registry.RegisterActivityWithOptions(func() error { return nil }, RegisterActivityOptions{Name: "registered"})
+	ldaTunnel := newLocallyDispatchedActivityTunnel(nil)
+	ldaTunnel.registry = registry
+	// free activity pollers waiting on the tunnel
+	ldaTunnel.taskCh = make(chan *locallyDispatchedActivityTask, 3)
+
+	scheduleActivity := func(activityID, activityType, taskList string) *s.Decision {
+		return &s.Decision{
+			DecisionType: s.DecisionTypeScheduleActivityTask.Ptr(),
+			ScheduleActivityTaskDecisionAttributes: &s.ScheduleActivityTaskDecisionAttributes{
+				ActivityId:   common.StringPtr(activityID),
+				ActivityType: &s.ActivityType{Name: common.StringPtr(activityType)},
+				TaskList:     &s.TaskList{Name: common.StringPtr(taskList)},
+			},
+		}
+	}
+	request := &s.RespondDecisionTaskCompletedRequest{
+		Decisions: []*s.Decision{
+			scheduleActivity("1", "registered", "tasklist"),
+			scheduleActivity("2", "unregistered", "tasklist"),
+			scheduleActivity("3", "registered", "other-tasklist"),
+		},
+	}
+	service.EXPECT().RespondDecisionTaskCompleted(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *s.RespondDecisionTaskCompletedRequest, _ ...yarpc.CallOption) (*s.RespondDecisionTaskCompletedResponse, error) {
+			var dispatched []string
+			for _, decision := range request.Decisions {
+				if decision.ScheduleActivityTaskDecisionAttributes.GetRequestLocalDispatch() {
+					dispatched = append(dispatched, decision.ScheduleActivityTaskDecisionAttributes.GetActivityId())
+				}
+			}
+			assert.Equal(t, []string{"1"}, dispatched)
+			return &s.RespondDecisionTaskCompletedResponse{
+				ActivitiesToDispatchLocally: map[string]*s.ActivityLocalDispatchInfo{
+					"1": {ActivityId: common.StringPtr("1"), TaskToken: []byte("token")},
+				},
+			}, nil
+		})
+
+	decisionPoller := newWorkflowTaskPoller(nil, ldaTunnel, service, "domain", params)
+	_, err := decisionPoller.RespondTaskCompleted(request, &s.PollForDecisionTaskResponse{
+		WorkflowExecution: &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+		WorkflowType:      &s.WorkflowType{Name: common.StringPtr("wt")},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, ldaTunnel.taskCh, 1)
+	task := ldaTunnel.getTask()
+	require.NotNil(t, task)
+	assert.Equal(t, "1", *task.ActivityId)
+	assert.Equal(t, []byte("token"), task.TaskToken)
+}
+
+func TestWorkflowTaskFilter(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	var infos []WorkflowTaskFilterInfo
+	poller := newWorkflowTaskPoller(nil, nil, service, "domain", workerExecutionParameters{
+		TaskList:                     "tasklist",
+		Identity:                     "identity",
+		MetricsScope:                 tally.NoopScope,
+		Logger:                       zap.NewNop(),
+		DecisionTaskFailureThreshold: 1,
+		DecisionTaskFailurePolicy:    DecisionTaskFailurePolicyTerminate,
+		WorkflowTaskFilter: func(info WorkflowTaskFilterInfo) error {
+			infos = append(infos, info)
+			var tenant string
+			info.Header.ForEachKey(func(key string, value []byte) error {
+				if key == "tenant" {
+					tenant = string(value)
+				}
+				return nil
+			})
+			if tenant != "tenant-a" {
+				return errors.New("tenant not assigned to this deployment: " + tenant)
+			}
+			return nil
+		},
+	})
+	newTask := func(tenant string) *s.PollForDecisionTaskResponse {
+		return &s.PollForDecisionTaskResponse{
+			TaskToken:         []byte("token"),
+			WorkflowExecution: &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+			WorkflowType:      &s.WorkflowType{Name: common.StringPtr("wt")},
+			Attempt:           common.Int64Ptr(0),
+			History: &s.History{Events: []*s.HistoryEvent{
+				createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{
+					TaskList: &s.TaskList{Name: common.StringPtr("tasklist")},
+					Header:   &s.Header{Fields: map[string][]byte{"tenant": []byte(tenant)}},
+				}),
+			}},
+		}
+	}
+
+	require.NoError(t, poller.filterWorkflowTask(newTask("tenant-a")))
+	require.Len(t, infos, 1)
+	assert.Equal(t, "domain", infos[0].Domain)
+	assert.Equal(t, "tasklist", infos[0].TaskList)
+	assert.Equal(t, "wt", infos[0].WorkflowType)
+	assert.Equal(t, WorkflowExecution{ID: "wid", RunID: "rid"}, infos[0].WorkflowExecution)
+
+	// sticky decision tasks don't start from the beginning of the history and are not filtered
+	stickyTask := newTask("tenant-b")
+	stickyTask.History.Events = []*s.HistoryEvent{createTestEventDecisionTaskStarted(5)}
+	require.NoError(t, poller.filterWorkflowTask(stickyTask))
+	require.Len(t, infos, 1)
+
+	// query tasks are not filtered
+	queryTask := newTask("tenant-b")
+	queryTask.Query = &s.WorkflowQuery{QueryType: common.StringPtr("state")}
+	require.NoError(t, poller.filterWorkflowTask(queryTask))
+	require.Len(t, infos, 1)
+
+	// the rejected task is failed without applying the DecisionTaskFailurePolicy
+	service.EXPECT().RespondDecisionTaskFailed(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *s.RespondDecisionTaskFailedRequest, _ ...yarpc.CallOption) error {
+			assert.Equal(t, s.DecisionTaskFailedCauseWorkflowWorkerUnhandledFailure, request.GetCause())
+			assert.Equal(t, "identity", request.GetIdentity())
+			return nil
+		})
+	require.NoError(t, poller.ProcessTask(&workflowTask{task: newTask("tenant-b")}))
+	require.Len(t, infos, 2)
+}
+
+func TestRejectUnregisteredWorkflowTypes(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	testScope := tally.NewTestScope("", nil)
+	params := workerExecutionParameters{
+		TaskList:                             "tasklist",
+		Identity:                             "identity",
+		MetricsScope:                         testScope,
+		Logger:                               zap.NewNop(),
+		DecisionTaskFailureThreshold:         1,
+		DecisionTaskFailurePolicy:            DecisionTaskFailurePolicyTerminate,
+		RejectUnregisteredWorkflowTypes:      true,
+		UnregisteredWorkflowTypeRequeueDelay: 50 * time.Millisecond,
+	}
+	registry := newRegistry()
+	registry.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{Name: "registered"})
+	taskHandler := newWorkflowTaskHandler("domain", params, nil, registry)
+	poller := newWorkflowTaskPoller(taskHandler, nil, service, "domain", params)
+	newTask := func(workflowType string) *s.PollForDecisionTaskResponse {
+		return &s.PollForDecisionTaskResponse{
+			TaskToken:         []byte("token"),
+			WorkflowExecution: &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+			WorkflowType:      &s.WorkflowType{Name: common.StringPtr(workflowType)},
+			Attempt:           common.Int64Ptr(0),
+			History: &s.History{Events: []*s.HistoryEvent{
+				createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{
+					TaskList: &s.TaskList{Name: common.StringPtr("tasklist")},
+				}),
+			}},
+		}
+	}
+
+	require.False(t, poller.isUnregisteredWorkflowType(newTask("registered")))
+	require.False(t, poller.isUnregisteredWorkflowType(&s.PollForDecisionTaskResponse{
+		WorkflowType: &s.WorkflowType{Name: common.StringPtr("unregistered")},
+		Query:        &s.WorkflowQuery{},
+	}), "query tasks are not rejected")
+
+	// the rejected task is failed after the requeue delay without applying the DecisionTaskFailurePolicy
+	service.EXPECT().RespondDecisionTaskFailed(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *s.RespondDecisionTaskFailedRequest, _ ...yarpc.CallOption) error {
+			assert.Equal(t, s.DecisionTaskFailedCauseWorkflowWorkerUnhandledFailure, request.GetCause())
+			assert.Contains(t, string(request.Details), errMsgUnknownWorkflowType)
+			return nil
+		})
+	start := time.Now()
+	require.NoError(t, poller.ProcessTask(&workflowTask{task: newTask("unregistered")}))
+	assert.True(t, time.Since(start) >= params.UnregisteredWorkflowTypeRequeueDelay)
+
+	counters := testScope.Snapshot().Counters()
+	var rejected int64
+	for _, counter := range counters {
+		if counter.Name() == metrics.UnregisteredWorkflowTypeCounter {
+			assert.Equal(t, "unregistered", counter.Tags()[tagWorkflowType])
+			rejected += counter.Value()
+		}
+	}
+	assert.Equal(t, int64(1), rejected)
+}
+
+type workflowTaskHandlerFunc func(task *workflowTask, heartbeat decisionHeartbeatFunc) (interface{}, error)
+
+func (f workflowTaskHandlerFunc) ProcessWorkflowTask(task *workflowTask, heartbeat decisionHeartbeatFunc) (interface{}, error) {
+	return f(task, heartbeat)
+}
+
+func TestDecisionTaskInspector(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	decisions := []*s.Decision{{DecisionType: s.DecisionTypeCompleteWorkflowExecution.Ptr()}}
+	// the handler loads the second page of the history before completing the decision task
+	taskHandler := workflowTaskHandlerFunc(func(task *workflowTask, _ decisionHeartbeatFunc) (interface{}, error) {
+		_, err := task.historyIterator.GetNextPage()
+		require.NoError(t, err)
+		return &s.RespondDecisionTaskCompletedRequest{TaskToken: task.task.TaskToken, Decisions: decisions}, nil
+	})
+	var infos []DecisionTaskInfo
+	poller := newWorkflowTaskPoller(taskHandler, nil, service, "domain", workerExecutionParameters{
+		TaskList:     "tasklist",
+		Identity:     "identity",
+		MetricsScope: tally.NoopScope,
+		Logger:       zap.NewNop(),
+		DecisionTaskInspector: func(info DecisionTaskInfo) {
+			infos = append(infos, info)
+		},
+	})
+	service.EXPECT().RespondDecisionTaskCompleted(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *s.RespondDecisionTaskCompletedRequest, _ ...yarpc.CallOption) (*s.RespondDecisionTaskCompletedResponse, error) {
+			// the decision task is inspected before it is completed
+			require.Len(t, infos, 1)
+			return &s.RespondDecisionTaskCompletedResponse{}, nil
+		})
+
+	task := &workflowTask{
+		task: &s.PollForDecisionTaskResponse{
+			TaskToken:              []byte("token"),
+			WorkflowExecution:      &s.WorkflowExecution{WorkflowId: common.StringPtr("wid"), RunId: common.StringPtr("rid")},
+			WorkflowType:           &s.WorkflowType{Name: common.StringPtr("wt")},
+			PreviousStartedEventId: common.Int64Ptr(0),
+			StartedEventId:         common.Int64Ptr(3),
+			History: &s.History{Events: []*s.HistoryEvent{
+				createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{}),
+			}},
+			NextPageToken: []byte("2"),
+		},
+		historyIterator: &historyIteratorImpl{
+			nextPageToken: []byte("2"),
+			iteratorFunc: func(nextPageToken []byte) (*s.History, []byte, error) {
+				return &s.History{Events: []*s.HistoryEvent{
+					createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{}),
+					createTestEventDecisionTaskStarted(3),
+				}}, nil, nil
+			},
+		},
+	}
+	require.NoError(t, poller.ProcessTask(task))
+
+	require.Len(t, infos, 1)
+	assert.Equal(t, "domain", infos[0].Domain)
+	assert.Equal(t, "tasklist", infos[0].TaskList)
+	assert.Equal(t, "wt", infos[0].WorkflowType)
+	assert.Equal(t, WorkflowExecution{ID: "wid", RunID: "rid"}, infos[0].WorkflowExecution)
+	assert.Equal(t, int64(3), infos[0].StartedEventID)
+	var eventIDs []int64
+	for _, event := range infos[0].History {
+		eventIDs = append(eventIDs, event.GetEventId())
+	}
+	assert.Equal(t, []int64{1, 2, 3}, eventIDs)
+	assert.Equal(t, decisions, infos[0].Decisions)
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"math"
+	"sync"
+)
+
+const defaultDecisionTaskSlotRatio = 0.2
+
+type (
+	// taskSlotScheduler limits the number of decision and activity tasks a worker executes concurrently. A share of
+	// the slots is reserved for decision tasks, which can also take any slot not used by activities, and decision
+	// tasks waiting for a slot are always served ahead of activity tasks, so busy activities can't starve decisions.
+	taskSlotScheduler struct {
+		sync.Mutex
+		total            int
+		activityLimit    int
+		inUse            int
+		activitiesInUse  int
+		waitingDecisions int
+		released         chan struct{} // closed and replaced every time a slot is released
+	}
+
+	// taskSlotPool acquires the slots of one type of tasks from a taskSlotScheduler.
+	taskSlotPool struct {
+		scheduler *taskSlotScheduler
+		decision  bool
+	}
+)
+
+// newTaskSlotScheduler returns nil, which disables the shared limit, when total is not positive.
+func newTaskSlotScheduler(total int, decisionRatio float64) *taskSlotScheduler {
+	if total <= 0 {
+		return nil
+	}
+	if decisionRatio <= 0 {
+		decisionRatio = defaultDecisionTaskSlotRatio
+	}
+	reserved := int(math.Ceil(float64(total) * decisionRatio))
+	if reserved > total-1 {
+		// always leave at least one slot to activities
+		reserved = total - 1
+	}
+	return &taskSlotScheduler{
+		total:         total,
+		activityLimit: total - reserved,
+		released:      make(chan struct{}),
+	}
+}
+
+func (s *taskSlotScheduler) forDecisions() *taskSlotPool {
+	if s == nil {
+		return nil
+	}
+	return &taskSlotPool{scheduler: s, decision: true}
+}
+
+func (s *taskSlotScheduler) forActivities() *taskSlotPool {
+	if s == nil {
+		return nil
+	}
+	return &taskSlotPool{scheduler: s}
+}
+
+func (s *taskSlotScheduler) canAcquire(decision bool) bool {
+	if s.inUse >= s.total {
+		return false
+	}
+	return decision || (s.waitingDecisions == 0 && s.activitiesInUse < s.activityLimit)
+}
+
+// acquire blocks until a slot is available. It returns false if shutdownCh is closed while waiting.
+func (p *taskSlotPool) acquire(shutdownCh <-chan struct{}) bool {
+	s := p.scheduler
+	s.Lock()
+	if p.decision {
+		s.waitingDecisions++
+	}
+	for !s.canAcquire(p.decision) {
+		released := s.released
+		s.Unlock()
+		select {
+		case <-shutdownCh:
+			s.Lock()
+			if p.decision {
+				s.waitingDecisions--
+			}
+			s.Unlock()
+			return false
+		case <-released:
+		}
+		s.Lock()
+	}
+	s.inUse++
+	if p.decision {
+		s.waitingDecisions--
+	} else {
+		s.activitiesInUse++
+	}
+	s.Unlock()
+	return true
+}
+
+func (p *taskSlotPool) release() {
+	s := p.scheduler
+	s.Lock()
+	s.inUse--
+	if !p.decision {
+		s.activitiesInUse--
+	}
+	close(s.released)
+	s.released = make(chan struct{})
+	s.Unlock()
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskSlotScheduler(t *testing.T) {
+	assert.Nil(t, newTaskSlotScheduler(0, 0.5).forDecisions())
+
+	scheduler := newTaskSlotScheduler(4, 0.5)
+	decisions, activities := scheduler.forDecisions(), scheduler.forActivities()
+	shutdownCh := make(chan struct{})
+
+	// activities can't use the slots reserved for decisions
+	require.True(t, activities.acquire(shutdownCh))
+	require.True(t, activities.acquire(shutdownCh))
+	activityAcquired := make(chan struct{})
+	go func() {
+		activities.acquire(shutdownCh)
+		close(activityAcquired)
+	}()
+	require.True(t, decisions.acquire(shutdownCh))
+	require.True(t, decisions.acquire(shutdownCh))
+
+	// decisions waiting for a slot are served first
+	decisionAcquired := make(chan struct{})
+	go func() {
+		decisions.acquire(shutdownCh)
+		close(decisionAcquired)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	activities.release()
+	select {
+	case <-decisionAcquired:
+	case <-time.After(time.Second):
+		t.Fatal("decision did not get the released slot")
+	}
+	select {
+	case <-activityAcquired:
+		t.Fatal("activity got a slot ahead of the decision")
+	default:
+	}
+
+	decisions.release()
+	decisions.release()
+	select {
+	case <-activityAcquired:
+	case <-time.After(time.Second):
+		t.Fatal("activity did not get a slot")
+	}
+
+	// waiting stops on shutdown
+	close(shutdownCh)
+	assert.False(t, activities.acquire(shutdownCh))
+}
+
+func TestTaskSlotScheduler_LeavesSlotToActivities(t *testing.T) {
+	scheduler := newTaskSlotScheduler(2, 1)
+	assert.Equal(t, 1, scheduler.activityLimit)
+	assert.True(t, scheduler.forActivities().acquire(make(chan struct{})))
+}
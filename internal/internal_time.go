@@ -26,6 +26,8 @@ import (
 
 // All code in this file is private to the package.
 
+const timerOptionsContextKey contextKey = "timerOptions"
+
 type (
 	timerInfo struct {
 		timerID string
@@ -42,6 +44,9 @@ type (
 		// The callback indicates the error(TimerCanceledError) if the timer is cancelled.
 		NewTimer(d time.Duration, callback resultHandler) *timerInfo
 
+		// RecordTimerSummary - Records a human readable summary of a started timer in the workflow history.
+		RecordTimerSummary(timerID string, summary string)
+
 		// RequestCancelTimer - Requests cancel of a timer, this one doesn't wait for cancellation request
 		// to complete, instead invokes the resultHandler with TimerCanceledError
 		// If the timer is not started then it is a no-operation.
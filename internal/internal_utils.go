@@ -58,6 +58,9 @@ const (
 
 	clientFeatureFlagsHeaderName = "cadence-client-feature-flags"
 
+	// nonRetriableErrorTypesHeaderName is the activity header listing the types of ActivityOptions.NonRetriableErrors
+	nonRetriableErrorTypesHeaderName = "cadence-non-retriable-error-types"
+
 	// defaultRPCTimeout is the default tchannel rpc call timeout
 	defaultRPCTimeout = 10 * time.Second
 	//minRPCTimeout is minimum rpc call timeout allowed
@@ -213,6 +216,12 @@ func getHostName() string {
 	return hostName
 }
 
+// getHostPinnedTasklist returns the activity task list polled by the workers with the host ID, see
+// WorkerOptions.EnableHostPinnedActivities.
+func getHostPinnedTasklist(hostID string) string {
+	return hostID + "__internal_host_pinned"
+}
+
 func getWorkerTaskList(stickyUUID string) string {
 	// includes hostname for debuggability, stickyUUID guarantees the uniqueness
 	return fmt.Sprintf("%s:%s", getHostName(), stickyUUID)
@@ -286,6 +295,9 @@ func getErrorDetails(err error, dataConverter DataConverter) (string, []byte) {
 			panic(err0)
 		}
 		return fmt.Sprintf("%v %v", errReasonTimeout, err.timeoutType), data
+	case *nonRetriableTypeError:
+		// will be convert to GenericError when receiving from server, like any other error.
+		return fmt.Sprintf("%v %v", errReasonGeneric, err.typeName), []byte(err.Error())
 	default:
 		// will be convert to GenericError when receiving from server.
 		return errReasonGeneric, []byte(err.Error())
@@ -307,6 +319,11 @@ func constructError(reason string, details []byte, dataConverter DataConverter)
 		return NewTimeoutError(timeoutType, details)
 	}
 
+	if strings.HasPrefix(reason, errReasonGeneric+" ") {
+		// errors reported with the name of their type, see ActivityOptions.NonRetriableErrors.
+		return &GenericError{err: string(details)}
+	}
+
 	switch reason {
 	case errReasonPanic:
 		// panic error
@@ -338,6 +355,17 @@ func getMetricsScopeForActivity(ts *metrics.TaggedScope, workflowType, activityT
 	return ts.GetTaggedScope(tagWorkflowType, workflowType, tagActivityType, activityType)
 }
 
+// getMetricsScopeForWorkflowTaskList return tally scope tagged with workflow type and the task list it runs on.
+// It is used for the latency metrics that SLOs are usually tracked against.
+func getMetricsScopeForWorkflowTaskList(ts *metrics.TaggedScope, workflowType, taskList string) tally.Scope {
+	return ts.GetTaggedScope(tagWorkflowType, workflowType, tagTaskList, taskList)
+}
+
+// getMetricsScopeForActivityTaskList return tally scope tagged with workflow type, activity type and task list
+func getMetricsScopeForActivityTaskList(ts *metrics.TaggedScope, workflowType, activityType, taskList string) tally.Scope {
+	return ts.GetTaggedScope(tagWorkflowType, workflowType, tagActivityType, activityType, tagTaskList, taskList)
+}
+
 // getMetricsScopeForLocalActivity return properly tagged tally scope for local activity
 func getMetricsScopeForLocalActivity(ts *metrics.TaggedScope, workflowType, localActivityType string) tally.Scope {
 	return ts.GetTaggedScope(tagWorkflowType, workflowType, tagLocalActivityType, localActivityType)
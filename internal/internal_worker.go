@@ -33,6 +33,7 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -41,8 +42,10 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/pborman/uuid"
 	"github.com/uber-go/tally/v4"
+	"go.uber.org/atomic"
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
 	"go.uber.org/cadence/internal/common/auth"
 	"go.uber.org/cadence/internal/common/backoff"
 	"go.uber.org/cadence/internal/common/metrics"
@@ -71,6 +74,13 @@ const (
 
 	defaultMaxConcurrentSessionExecutionSize = 1000 // Large concurrent session execution size (1k)
 
+	defaultMaxTimerDuration = 365 * 24 * time.Hour
+
+	workerHealthCheckInterval = 10 * time.Second
+
+	lazyStartRetryInitialInterval = time.Second
+	lazyStartRetryMaximumInterval = time.Minute
+
 	testTagsContextKey = "cadence-testTags"
 )
 
@@ -83,10 +93,13 @@ type (
 		workflowService     workflowserviceclient.Interface
 		domain              string
 		poller              taskPoller // taskPoller to poll and process the tasks.
+		taskHandler         WorkflowTaskHandler
 		worker              *baseWorker
 		localActivityWorker *baseWorker
 		identity            string
 		stopC               chan struct{}
+
+		resetStickinessQueue *resetStickinessQueue
 	}
 
 	// ActivityWorker wraps the code for hosting activity types.
@@ -159,6 +172,9 @@ type (
 		// Enable logging in replay mode
 		EnableLoggingInReplay bool
 
+		// Buffer workflow metrics until the decision task is completed
+		EnableBufferedWorkflowMetrics bool
+
 		// Context to store user provided key/value pairs
 		UserContext context.Context
 
@@ -168,12 +184,59 @@ type (
 		// Disable sticky execution
 		DisableStickyExecution bool
 
+		// DisableStickyQuery answers sticky queries by replaying the whole history
+		DisableStickyQuery bool
+
 		StickyScheduleToStartTimeout time.Duration
 
+		// Number of open workflow executions to replay into the sticky cache on start
+		StickyCacheWarmUpSize int
+
+		// Rate of the ResetStickyTaskList calls for the executions evicted from the sticky cache
+		StickyCacheResetsPerSecond float64
+
+		// WorkflowSnapshotStore saves the snapshots of the workflows that set a WorkflowSnapshotter
+		WorkflowSnapshotStore WorkflowSnapshotStore
+
+		// Number of history events between two snapshots of a workflow
+		WorkflowSnapshotInterval int64
+
 		// NonDeterministicWorkflowPolicy is used for configuring how client's decision task handler deals with
 		// mismatched history events (presumably arising from non-deterministic workflow definitions).
 		NonDeterministicWorkflowPolicy NonDeterministicWorkflowPolicy
 
+		// WorkflowPanicClassifier decides whether a panic in workflow code fails or blocks the workflow.
+		WorkflowPanicClassifier func(value interface{}, stackTrace string) WorkflowPanicAction
+
+		// DecisionTaskFailureThreshold is the number of consecutive decision task failures after which
+		// OnDecisionTaskFailureThreshold is called and DecisionTaskFailurePolicy is applied.
+		DecisionTaskFailureThreshold   int
+		OnDecisionTaskFailureThreshold func(execution WorkflowExecution, err error)
+		DecisionTaskFailurePolicy      DecisionTaskFailurePolicy
+
+		// SlowDecisionTaskThreshold and SlowActivityThreshold are the task execution durations after which a
+		// warning is logged.
+		SlowDecisionTaskThreshold time.Duration
+		SlowActivityThreshold     time.Duration
+
+		// EnableDecisionTaskFailureDump logs a dump of every failed decision task, with payloads replaced by
+		// DecisionTaskFailureDumpRedactor.
+		EnableDecisionTaskFailureDump   bool
+		DecisionTaskFailureDumpRedactor func(payload []byte) string
+
+		// EnableDecisionValidation replays the whole history again to check the decisions of every decision task
+		EnableDecisionValidation bool
+
+		// EnableHistoryPrefetch fetches the next history page while the current one is replayed.
+		EnableHistoryPrefetch bool
+
+		// DecisionPollHedgingDelay is the delay after which a second decision task poll request is sent.
+		DecisionPollHedgingDelay time.Duration
+
+		// PollBackoff backs off the pollers after poll failures, ServiceBusyBackoff while the server is busy
+		PollBackoff        pollBackoffOptions
+		ServiceBusyBackoff pollBackoffOptions
+
 		DataConverter DataConverter
 
 		// WorkerStopTimeout is the time delay before hard terminate worker
@@ -185,12 +248,55 @@ type (
 		// SessionResourceID is a unique identifier of the resource the session will consume
 		SessionResourceID string
 
+		// HostID is reported to the activities to pin activities to the worker, empty unless pinning is enabled
+		HostID string
+
 		ContextPropagators []ContextPropagator
 
 		Tracer opentracing.Tracer
 
 		WorkflowInterceptors []WorkflowInterceptorFactory
 
+		WorkflowConfigProvider WorkflowConfigProvider
+
+		// MaxTimerDuration is the longest timer started by the workflows, 0 uses defaultMaxTimerDuration
+		MaxTimerDuration time.Duration
+
+		DisableTimerSplitting bool
+
+		DefaultActivityOptions *ActivityOptions
+
+		DefaultLocalActivityOptions *LocalActivityOptions
+
+		EventListeners []WorkerEventListener
+
+		WorkflowTaskFilter WorkflowTaskFilter
+
+		DecisionTaskInspector DecisionTaskInspector
+
+		RejectUnregisteredWorkflowTypes bool
+
+		UnregisteredWorkflowTypeRequeueDelay time.Duration
+
+		// ActivityResourceController suppresses activity polling while the host is overloaded
+		ActivityResourceController ResourceControllerOptions
+
+		// activityResultCache holds the results of idempotent activities, nil disables it
+		activityResultCache *activityResultCache
+
+		// ActivityDeadlineGracePeriod is subtracted from the deadline of the context passed to activities
+		ActivityDeadlineGracePeriod time.Duration
+
+		MaxHeartbeatThrottleInterval time.Duration
+
+		DefaultHeartbeatThrottleInterval time.Duration
+
+		// taskSlots is shared by the decision and activity workers to prioritize decision tasks, nil disables it
+		taskSlots *taskSlotScheduler
+
+		// LifecycleHooks are notified when the pollers of the worker start and stop
+		LifecycleHooks WorkerLifecycleHooks
+
 		// flags to turn on/off some server side features
 		FeatureFlags FeatureFlags
 	}
@@ -272,6 +378,36 @@ func verifyDomainExist(
 	return backoff.Retry(ctx, descDomainOp, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
 }
 
+// verifyDomainExistWithRetry does a DescribeDomain operation on the specified domain and retries
+// transient errors with backoff until the domain is verified or the ctx is canceled.
+// It returns an error, if the server returns a non transient error like EntityNotExist or BadRequest
+func verifyDomainExistWithRetry(
+	ctx context.Context,
+	client workflowserviceclient.Interface,
+	domain string,
+	logger *zap.Logger,
+	featureFlags FeatureFlags,
+) error {
+	descDomainOp := func() error {
+		tchCtx, cancel, opt := newChannelContext(ctx, featureFlags)
+		defer cancel()
+		_, err := client.DescribeDomain(tchCtx, &shared.DescribeDomainRequest{Name: &domain}, opt...)
+		if err != nil {
+			logger.Warn("unable to verify if domain exist, will retry", zap.String("domain", domain), zap.Error(err))
+		}
+		return err
+	}
+
+	if len(domain) == 0 {
+		return errors.New("domain cannot be empty")
+	}
+
+	policy := backoff.NewExponentialRetryPolicy(lazyStartRetryInitialInterval)
+	policy.SetMaximumInterval(lazyStartRetryMaximumInterval)
+	policy.SetExpirationInterval(backoff.NoInterval)
+	return backoff.Retry(ctx, descDomainOp, policy, isServiceTransientError)
+}
+
 func newWorkflowWorkerInternal(
 	service workflowserviceclient.Interface,
 	domain string,
@@ -318,7 +454,13 @@ func newWorkflowTaskWorkerInternal(
 		taskWorker:        poller,
 		identity:          params.Identity,
 		workerType:        "DecisionWorker",
-		shutdownTimeout:   params.WorkerStopTimeout},
+		shutdownTimeout:   params.WorkerStopTimeout,
+		taskSlots:         params.taskSlots.forDecisions(),
+		pollBackoff:       params.PollBackoff,
+		busyBackoff:       params.ServiceBusyBackoff,
+		domain:            domain,
+		taskList:          params.TaskList,
+		lifecycleHooks:    params.LifecycleHooks},
 		params.Logger,
 		params.MetricsScope,
 		nil,
@@ -350,15 +492,23 @@ func newWorkflowTaskWorkerInternal(
 	// 3) the result pushed to laTunnel will be send as task to workflow worker to process.
 	worker.taskQueueCh = laTunnel.resultCh
 
+	// the executions evicted from the sticky cache have their stickiness reset in the background
+	resetStickinessQueue := newResetStickinessQueue(service, params, stopC)
+	if handlerImpl, ok := taskHandler.(*workflowTaskHandlerImpl); ok {
+		handlerImpl.resetStickinessQueue = resetStickinessQueue
+	}
+
 	return &workflowWorker{
-		executionParameters: params,
-		workflowService:     service,
-		poller:              poller,
-		worker:              worker,
-		localActivityWorker: localActivityWorker,
-		identity:            params.Identity,
-		domain:              domain,
-		stopC:               stopC,
+		executionParameters:  params,
+		workflowService:      service,
+		poller:               poller,
+		taskHandler:          taskHandler,
+		worker:               worker,
+		localActivityWorker:  localActivityWorker,
+		resetStickinessQueue: resetStickinessQueue,
+		identity:             params.Identity,
+		domain:               domain,
+		stopC:                stopC,
 	}
 }
 
@@ -369,7 +519,9 @@ func (ww *workflowWorker) Start() error {
 		return err
 	}
 	ww.localActivityWorker.Start()
+	go ww.resetStickinessQueue.run()
 	ww.worker.Start()
+	ww.startStickyCacheWarmUp()
 	return nil // TODO: propagate error
 }
 
@@ -379,10 +531,142 @@ func (ww *workflowWorker) Run() error {
 		return err
 	}
 	ww.localActivityWorker.Start()
+	go ww.resetStickinessQueue.run()
+	ww.startStickyCacheWarmUp()
 	ww.worker.Run()
 	return nil
 }
 
+func (ww *workflowWorker) startStickyCacheWarmUp() {
+	params := ww.executionParameters
+	if params.StickyCacheWarmUpSize <= 0 || params.DisableStickyExecution {
+		return
+	}
+	if wth, ok := ww.taskHandler.(*workflowTaskHandlerImpl); ok {
+		go ww.warmUpStickyCache(wth, params.StickyCacheWarmUpSize)
+	}
+}
+
+// warmUpStickyCache replays the most recently started open executions of the registered workflow types on the
+// task list of the worker into the sticky cache. Failures are logged and only skip the affected executions, as the
+// state of those executions is rebuilt by their next decision task anyway.
+func (ww *workflowWorker) warmUpStickyCache(wth *workflowTaskHandlerImpl, size int) {
+	logger := ww.worker.logger
+	metricsScope := ww.executionParameters.MetricsScope
+	// the calls to the server are cancelled when the worker stops
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-ww.stopC:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	executions, err := ww.listStickyCacheWarmUpExecutions(ctx, wth.registry.getRegisteredWorkflowTypes(), size)
+	if err != nil {
+		logger.Warn("Failed to list workflow executions to warm up the sticky cache.", zap.Error(err))
+		return
+	}
+
+	warmed := 0
+	for _, execution := range executions {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		history, err := ww.getStickyCacheWarmUpHistory(ctx, execution.Execution)
+		if err == nil {
+			err = wth.warmUpWorkflowContext(execution.Execution, execution.Type, history)
+		}
+		if err != nil {
+			metricsScope.Counter(metrics.StickyCacheWarmUpFailed).Inc(1)
+			logger.Debug("Failed to warm up the sticky cache for workflow execution.",
+				zap.String(tagWorkflowType, execution.Type.GetName()),
+				zap.String(tagWorkflowID, execution.Execution.GetWorkflowId()),
+				zap.String(tagRunID, execution.Execution.GetRunId()),
+				zap.Error(err))
+			continue
+		}
+		metricsScope.Counter(metrics.StickyCacheWarmUp).Inc(1)
+		warmed++
+	}
+	logger.Info("Sticky cache warm-up finished.",
+		zap.Int("Candidates", len(executions)),
+		zap.Int("Warmed", warmed))
+}
+
+// listStickyCacheWarmUpExecutions returns up to size open executions of the given workflow types on the task list of
+// the worker, most recently started first.
+func (ww *workflowWorker) listStickyCacheWarmUpExecutions(ctx context.Context, workflowTypes []string, size int) ([]*shared.WorkflowExecutionInfo, error) {
+	var executions []*shared.WorkflowExecutionInfo
+	for _, workflowType := range workflowTypes {
+		var resp *shared.ListOpenWorkflowExecutionsResponse
+		err := backoff.Retry(ctx,
+			func() error {
+				tchCtx, cancel, opt := newChannelContext(ctx, ww.executionParameters.FeatureFlags)
+				defer cancel()
+
+				var err1 error
+				resp, err1 = ww.workflowService.ListOpenWorkflowExecutions(tchCtx, &shared.ListOpenWorkflowExecutionsRequest{
+					Domain:          common.StringPtr(ww.domain),
+					MaximumPageSize: common.Int32Ptr(int32(size)),
+					StartTimeFilter: &shared.StartTimeFilter{
+						EarliestTime: common.Int64Ptr(0),
+						LatestTime:   common.Int64Ptr(time.Now().UnixNano()),
+					},
+					TypeFilter: &shared.WorkflowTypeFilter{Name: common.StringPtr(workflowType)},
+				}, opt...)
+				return err1
+			}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+		if err != nil {
+			return nil, err
+		}
+		for _, execution := range resp.Executions {
+			if execution.TaskList == nil || execution.GetTaskList() == ww.executionParameters.TaskList {
+				executions = append(executions, execution)
+			}
+		}
+	}
+
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].GetStartTime() > executions[j].GetStartTime()
+	})
+	if len(executions) > size {
+		executions = executions[:size]
+	}
+	return executions, nil
+}
+
+func (ww *workflowWorker) getStickyCacheWarmUpHistory(ctx context.Context, execution *shared.WorkflowExecution) (*shared.History, error) {
+	getHistoryPage := newGetHistoryPageFunc(
+		ctx,
+		ww.workflowService,
+		ww.domain,
+		execution,
+		0,
+		0,
+		ww.executionParameters.MetricsScope,
+		ww.executionParameters.FeatureFlags)
+
+	history := &shared.History{}
+	var nextPageToken []byte
+	for {
+		page, token, err := getHistoryPage(nextPageToken)
+		if err != nil {
+			return nil, err
+		}
+		history.Events = append(history.Events, page.Events...)
+		if len(token) == 0 {
+			return history, nil
+		}
+		nextPageToken = token
+	}
+}
+
 // Shutdown the worker.
 func (ww *workflowWorker) Stop() {
 	select {
@@ -497,6 +781,12 @@ func newActivityTaskWorker(
 	workerType string,
 ) (worker *activityWorker) {
 	ensureRequiredParams(&workerParams)
+	// the session creation activity runs for the whole session, sessions are limited by
+	// MaxConcurrentSessionExecutionSize rather than by the task slots
+	var taskSlots *taskSlotPool
+	if sessionTokenBucket == nil {
+		taskSlots = workerParams.taskSlots.forActivities()
+	}
 	base := newBaseWorker(
 		baseWorkerOptions{
 			pollerCount:       workerParams.MaxConcurrentActivityPollers,
@@ -507,7 +797,16 @@ func newActivityTaskWorker(
 			identity:          workerParams.Identity,
 			workerType:        workerType,
 			shutdownTimeout:   workerParams.WorkerStopTimeout,
-			userContextCancel: workerParams.UserContextCancel},
+			userContextCancel: workerParams.UserContextCancel,
+			domain:            domain,
+			taskList:          workerParams.TaskList,
+			lifecycleHooks:    workerParams.LifecycleHooks,
+			resourceController: newResourceController(
+				workerParams.ActivityResourceController, workerParams.Logger, workerParams.MetricsScope),
+			taskSlots:   taskSlots,
+			pollBackoff: workerParams.PollBackoff,
+			busyBackoff: workerParams.ServiceBusyBackoff,
+		},
 		workerParams.Logger,
 		workerParams.MetricsScope,
 		sessionTokenBucket,
@@ -706,6 +1005,29 @@ type activityExecutor struct {
 	name    string
 	fn      interface{}
 	options RegisterActivityOptions
+	// factory creates the receiver of the method with index method per execution, fn is a method of a zero receiver
+	factory *activityFactory
+	method  int
+}
+
+type activityFactory struct {
+	factory reflect.Value
+}
+
+// newInstance calls the factory, the release function it returns is never nil.
+func (f *activityFactory) newInstance(ctx context.Context) (reflect.Value, func(), error) {
+	results := f.factory.Call([]reflect.Value{reflect.ValueOf(ctx)})
+	if len(results) > 1 && !results[len(results)-1].IsNil() {
+		return reflect.Value{}, nil, results[len(results)-1].Interface().(error)
+	}
+	if results[0].IsNil() {
+		return reflect.Value{}, nil, errors.New("activity factory returned nil")
+	}
+	release := func() {}
+	if len(results) == 3 && !results[1].IsNil() {
+		release = results[1].Interface().(func())
+	}
+	return results[0], release, nil
 }
 
 func (ae *activityExecutor) ActivityType() ActivityType {
@@ -720,6 +1042,19 @@ func (ae *activityExecutor) GetOptions() RegisterActivityOptions {
 	return ae.options
 }
 
+// getFunction returns the function to execute, which is a method of a new receiver when an activity factory is used,
+// and the function releasing the receiver to call once the function returns.
+func (ae *activityExecutor) getFunction(ctx context.Context) (interface{}, func(), error) {
+	if ae.factory == nil {
+		return ae.fn, func() {}, nil
+	}
+	instance, release, err := ae.factory.newInstance(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create the activity struct for activity: %v, error: %v", ae.name, err)
+	}
+	return instance.Method(ae.method).Interface(), release, nil
+}
+
 func (ae *activityExecutor) Execute(ctx context.Context, input []byte) ([]byte, error) {
 	fnType := reflect.TypeOf(ae.fn)
 	var args []reflect.Value
@@ -742,13 +1077,24 @@ func (ae *activityExecutor) Execute(ctx context.Context, input []byte) ([]byte,
 		args = append(args, decoded...)
 	}
 
-	fnValue := reflect.ValueOf(ae.fn)
+	fn, release, err := ae.getFunction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	fnValue := reflect.ValueOf(fn)
 	retValues := fnValue.Call(args)
 	return validateFunctionAndGetResults(ae.fn, retValues, dataConverter)
 }
 
 func (ae *activityExecutor) ExecuteWithActualArgs(ctx context.Context, actualArgs []interface{}) ([]byte, error) {
-	retValues := ae.executeWithActualArgsWithoutParseResult(ctx, actualArgs)
+	fn, release, err := ae.getFunction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	executor := &activityExecutor{name: ae.name, fn: fn, options: ae.options}
+	retValues := executor.executeWithActualArgsWithoutParseResult(ctx, actualArgs)
 	dataConverter := getDataConverterFromActivityCtx(ctx)
 
 	return validateFunctionAndGetResults(ae.fn, retValues, dataConverter)
@@ -794,12 +1140,44 @@ type aggregatedWorker struct {
 	workflowWorker                  *workflowWorker
 	activityWorker                  *activityWorker
 	locallyDispatchedActivityWorker *activityWorker
+	hostPinnedActivityWorker        *activityWorker
 	sessionWorker                   *sessionWorker
 	shadowWorker                    *shadowWorker
 	logger                          *zap.Logger
 	registry                        *registry
+	workerKind                      workerKind
+	unhealthyCh                     chan WorkerStatus
+	healthStopC                     chan struct{}
+	healthOnce                      sync.Once
+
+	// lazy start state, see WorkerOptions.LazyStart
+	service         workflowserviceclient.Interface
+	domain          string
+	featureFlags    FeatureFlags
+	lazyStart       bool
+	lazyStarting    atomic.Bool // true until the lazily started pollers run
+	lazyStartLock   sync.Mutex
+	lazyStartCtx    context.Context
+	lazyStartCancel context.CancelFunc
+
+	// lifecycle hooks, see WorkerOptions.LifecycleHooks
+	lifecycleHooks WorkerLifecycleHooks
+	lifecycleInfo  WorkerLifecycleInfo
+	stopped        bool
 }
 
+// workerKind identifies which task types an aggregatedWorker is dedicated to.
+type workerKind int
+
+const (
+	// defaultWorkerKind hosts workflows and activities as configured through WorkerOptions.
+	defaultWorkerKind workerKind = iota
+	// activityOnlyWorkerKind only hosts activities, created through NewActivityWorker.
+	activityOnlyWorkerKind
+	// workflowOnlyWorkerKind only hosts workflows, created through NewWorkflowWorker.
+	workflowOnlyWorkerKind
+)
+
 func (aw *aggregatedWorker) RegisterWorkflow(w interface{}) {
 	aw.registry.RegisterWorkflow(w)
 }
@@ -816,11 +1194,70 @@ func (aw *aggregatedWorker) RegisterActivityWithOptions(a interface{}, options R
 	aw.registry.RegisterActivityWithOptions(a, options)
 }
 
+func (aw *aggregatedWorker) RegisterActivityFactory(factory interface{}, options RegisterActivityOptions) {
+	aw.registry.RegisterActivityFactory(factory, options)
+}
+
+func (aw *aggregatedWorker) RegisterWorkflowStruct(w interface{}) {
+	aw.registry.RegisterWorkflowStruct(w)
+}
+
+func (aw *aggregatedWorker) RegisterActivityStruct(a interface{}) {
+	aw.registry.RegisterActivityStruct(a)
+}
+
+// GetRegisteredWorkflows returns the workflow types registered with the worker, including the ones registered
+// globally, sorted by name.
+func (aw *aggregatedWorker) GetRegisteredWorkflows() []RegisteredWorkflowInfo {
+	return aw.registry.getRegisteredWorkflows()
+}
+
+// GetRegisteredActivities returns the activity types registered with the worker, including the ones registered
+// globally, sorted by name.
+func (aw *aggregatedWorker) GetRegisteredActivities() []RegisteredActivityInfo {
+	return aw.registry.getRegisteredActivityInfos()
+}
+
 func (aw *aggregatedWorker) Start() error {
+	if err := aw.validateRegistrations(); err != nil {
+		return err
+	}
+
 	if err := initBinaryChecksum(); err != nil {
 		return fmt.Errorf("failed to get executable checksum: %v", err)
 	}
 
+	if aw.lazyStart {
+		aw.lazyStarting.Store(true)
+		go aw.startLazily()
+		return nil
+	}
+	return aw.startWorkers()
+}
+
+// startLazily verifies the domain in the background, retrying transient errors
+// until it succeeds or the worker is stopped, and then starts the workers.
+func (aw *aggregatedWorker) startLazily() {
+	aw.logger.Info("Lazily starting worker, waiting for domain to be verified.")
+	if err := verifyDomainExistWithRetry(aw.lazyStartCtx, aw.service, aw.domain, aw.logger, aw.featureFlags); err != nil {
+		if aw.lazyStartCtx.Err() == nil {
+			aw.logger.Error("Unable to verify domain, worker will not be started.", zap.Error(err))
+		}
+		return
+	}
+
+	aw.lazyStartLock.Lock()
+	defer aw.lazyStartLock.Unlock()
+	if aw.lazyStartCtx.Err() != nil {
+		// worker was stopped while the domain was being verified
+		return
+	}
+	if err := aw.startWorkers(); err != nil {
+		aw.logger.Error("Failed to lazily start worker.", zap.Error(err))
+	}
+}
+
+func (aw *aggregatedWorker) startWorkers() error {
 	if aw.workflowWorker != nil {
 		if len(aw.registry.getRegisteredWorkflowTypes()) == 0 {
 			aw.logger.Info(
@@ -856,6 +1293,19 @@ func (aw *aggregatedWorker) Start() error {
 					return err
 				}
 			}
+			if aw.hostPinnedActivityWorker != nil {
+				if err := aw.hostPinnedActivityWorker.Start(); err != nil {
+					// stop workflow worker.
+					if aw.workflowWorker != nil {
+						aw.workflowWorker.Stop()
+					}
+					aw.activityWorker.Stop()
+					if aw.locallyDispatchedActivityWorker != nil {
+						aw.locallyDispatchedActivityWorker.Stop()
+					}
+					return err
+				}
+			}
 			aw.logger.Info("Started Activity Worker")
 		}
 	}
@@ -872,6 +1322,9 @@ func (aw *aggregatedWorker) Start() error {
 			if aw.locallyDispatchedActivityWorker != nil {
 				aw.locallyDispatchedActivityWorker.Stop()
 			}
+			if aw.hostPinnedActivityWorker != nil {
+				aw.hostPinnedActivityWorker.Stop()
+			}
 			return err
 		}
 		aw.logger.Info("Started Session Worker")
@@ -888,6 +1341,9 @@ func (aw *aggregatedWorker) Start() error {
 			if aw.locallyDispatchedActivityWorker != nil {
 				aw.locallyDispatchedActivityWorker.Stop()
 			}
+			if aw.hostPinnedActivityWorker != nil {
+				aw.hostPinnedActivityWorker.Stop()
+			}
 			if aw.sessionWorker != nil {
 				aw.sessionWorker.Stop()
 			}
@@ -896,6 +1352,97 @@ func (aw *aggregatedWorker) Start() error {
 		aw.logger.Info("Started Shadow Worker")
 	}
 
+	aw.lazyStarting.Store(false)
+	aw.healthOnce.Do(func() {
+		go aw.monitorHealth()
+	})
+	if aw.lifecycleHooks.OnStart != nil {
+		aw.lifecycleHooks.OnStart(aw.lifecycleInfo)
+	}
+	return nil
+}
+
+// Status returns a point in time health report of the worker.
+func (aw *aggregatedWorker) Status() WorkerStatus {
+	var pollers []PollerStatus
+	if aw.workflowWorker != nil {
+		pollers = append(pollers, aw.workflowWorker.worker.getStatus(aw.workflowWorker.executionParameters.TaskList))
+	}
+	if aw.activityWorker != nil {
+		pollers = append(pollers, aw.activityWorker.worker.getStatus(aw.activityWorker.executionParameters.TaskList))
+	}
+	if aw.hostPinnedActivityWorker != nil {
+		hostPinnedWorker := aw.hostPinnedActivityWorker
+		pollers = append(pollers, hostPinnedWorker.worker.getStatus(hostPinnedWorker.executionParameters.TaskList))
+	}
+	if aw.sessionWorker != nil {
+		creationWorker := aw.sessionWorker.creationWorker
+		pollers = append(pollers, creationWorker.worker.getStatus(creationWorker.executionParameters.TaskList))
+		activityWorker := aw.sessionWorker.activityWorker
+		pollers = append(pollers, activityWorker.worker.getStatus(activityWorker.executionParameters.TaskList))
+	}
+
+	status := WorkerStatus{
+		Healthy:  true,
+		Starting: aw.lazyStarting.Load(),
+		Pollers:  pollers,
+	}
+	if status.Starting {
+		status.Healthy = false
+	}
+	for _, poller := range pollers {
+		if !poller.Healthy {
+			status.Healthy = false
+		}
+	}
+	if aw.workflowWorker != nil {
+		status.StickyCacheSize = getWorkflowCache().Size()
+	}
+	return status
+}
+
+// Unhealthy returns a channel that receives the worker status every time the worker
+// transitions from healthy to unhealthy. Reports are dropped if the channel is not drained.
+func (aw *aggregatedWorker) Unhealthy() <-chan WorkerStatus {
+	return aw.unhealthyCh
+}
+
+func (aw *aggregatedWorker) monitorHealth() {
+	ticker := time.NewTicker(workerHealthCheckInterval)
+	defer ticker.Stop()
+
+	healthy := true
+	for {
+		select {
+		case <-aw.healthStopC:
+			return
+		case <-ticker.C:
+			status := aw.Status()
+			if healthy && !status.Healthy {
+				aw.logger.Warn("Worker became unhealthy.", zap.Any("WorkerStatus", status))
+				select {
+				case aw.unhealthyCh <- status:
+				default:
+				}
+			}
+			healthy = status.Healthy
+		}
+	}
+}
+
+// validateRegistrations ensures that a dedicated activity or workflow worker
+// has implementations registered for the kind of tasks it polls.
+func (aw *aggregatedWorker) validateRegistrations() error {
+	switch aw.workerKind {
+	case activityOnlyWorkerKind:
+		if len(aw.registry.getRegisteredActivities()) == 0 {
+			return errors.New("activity worker has no activities registered")
+		}
+	case workflowOnlyWorkerKind:
+		if len(aw.registry.getRegisteredWorkflowTypes()) == 0 {
+			return errors.New("workflow worker has no workflows registered")
+		}
+	}
 	return nil
 }
 
@@ -972,6 +1519,18 @@ func (aw *aggregatedWorker) Run() error {
 }
 
 func (aw *aggregatedWorker) Stop() {
+	aw.lazyStartCancel()
+	aw.lazyStartLock.Lock()
+	defer aw.lazyStartLock.Unlock()
+
+	if aw.stopped {
+		return
+	}
+	aw.stopped = true
+	if aw.lifecycleHooks.OnShutdownBegin != nil {
+		aw.lifecycleHooks.OnShutdownBegin(aw.lifecycleInfo)
+	}
+
 	if aw.workflowWorker != nil {
 		aw.workflowWorker.Stop()
 	}
@@ -981,13 +1540,25 @@ func (aw *aggregatedWorker) Stop() {
 	if aw.locallyDispatchedActivityWorker != nil {
 		aw.locallyDispatchedActivityWorker.Stop()
 	}
+	if aw.hostPinnedActivityWorker != nil {
+		aw.hostPinnedActivityWorker.Stop()
+	}
 	if aw.sessionWorker != nil {
 		aw.sessionWorker.Stop()
 	}
 	if aw.shadowWorker != nil {
 		aw.shadowWorker.Stop()
 	}
+	select {
+	case <-aw.healthStopC:
+		// channel is already closed
+	default:
+		close(aw.healthStopC)
+	}
 	aw.logger.Info("Stopped Worker")
+	if aw.lifecycleHooks.OnShutdownComplete != nil {
+		aw.lifecycleHooks.OnShutdownComplete(aw.lifecycleInfo)
+	}
 }
 
 // AggregatedWorker returns an instance to manage the workers. Use defaultConcurrentPollRoutineSize (which is 2) as
@@ -1020,18 +1591,60 @@ func newAggregatedWorker(
 		MetricsScope:                         wOptions.MetricsScope,
 		Logger:                               wOptions.Logger,
 		EnableLoggingInReplay:                wOptions.EnableLoggingInReplay,
+		EnableBufferedWorkflowMetrics:        wOptions.EnableBufferedWorkflowMetrics,
 		UserContext:                          backgroundActivityContext,
 		UserContextCancel:                    backgroundActivityContextCancel,
 		DisableStickyExecution:               wOptions.DisableStickyExecution,
+		DisableStickyQuery:                   wOptions.DisableStickyQuery,
 		StickyScheduleToStartTimeout:         wOptions.StickyScheduleToStartTimeout,
+		StickyCacheWarmUpSize:                wOptions.StickyCacheWarmUpSize,
+		StickyCacheResetsPerSecond:           wOptions.StickyCacheResetsPerSecond,
+		WorkflowSnapshotStore:                wOptions.WorkflowSnapshotStore,
+		WorkflowSnapshotInterval:             wOptions.WorkflowSnapshotInterval,
 		TaskListActivitiesPerSecond:          wOptions.TaskListActivitiesPerSecond,
 		NonDeterministicWorkflowPolicy:       wOptions.NonDeterministicWorkflowPolicy,
+		WorkflowPanicClassifier:              wOptions.WorkflowPanicClassifier,
+		DecisionTaskFailureThreshold:         wOptions.DecisionTaskFailureThreshold,
+		OnDecisionTaskFailureThreshold:       wOptions.OnDecisionTaskFailureThreshold,
+		DecisionTaskFailurePolicy:            wOptions.DecisionTaskFailurePolicy,
+		SlowDecisionTaskThreshold:            wOptions.SlowDecisionTaskThreshold,
+		SlowActivityThreshold:                wOptions.SlowActivityThreshold,
+		EnableDecisionTaskFailureDump:        wOptions.EnableDecisionTaskFailureDump,
+		EnableDecisionValidation:             wOptions.EnableDecisionValidation,
+		DecisionTaskFailureDumpRedactor:      wOptions.DecisionTaskFailureDumpRedactor,
+		EnableHistoryPrefetch:                wOptions.EnableHistoryPrefetch,
+		DecisionPollHedgingDelay:             wOptions.DecisionPollHedgingDelay,
+		PollBackoff: pollBackoffOptions{
+			initialInterval: wOptions.PollBackoffInitialInterval,
+			maxInterval:     wOptions.PollBackoffMaxInterval,
+		},
+		ServiceBusyBackoff: pollBackoffOptions{
+			initialInterval: wOptions.ServiceBusyBackoffInitialInterval,
+			maxInterval:     wOptions.ServiceBusyBackoffMaxInterval,
+		},
 		DataConverter:                        wOptions.DataConverter,
 		WorkerStopTimeout:                    wOptions.WorkerStopTimeout,
 		ContextPropagators:                   wOptions.ContextPropagators,
 		Tracer:                               wOptions.Tracer,
 		WorkflowInterceptors:                 wOptions.WorkflowInterceptorChainFactories,
+		WorkflowConfigProvider:               wOptions.WorkflowConfigProvider,
+		MaxTimerDuration:                     wOptions.MaxTimerDuration,
+		DisableTimerSplitting:                wOptions.DisableTimerSplitting,
+		DefaultActivityOptions:               wOptions.DefaultActivityOptions,
+		DefaultLocalActivityOptions:          wOptions.DefaultLocalActivityOptions,
+		EventListeners:                       wOptions.EventListeners,
+		WorkflowTaskFilter:                   wOptions.WorkflowTaskFilter,
+		DecisionTaskInspector:                wOptions.DecisionTaskInspector,
+		RejectUnregisteredWorkflowTypes:      wOptions.RejectUnregisteredWorkflowTypes,
+		UnregisteredWorkflowTypeRequeueDelay: wOptions.UnregisteredWorkflowTypeRequeueDelay,
+		ActivityResourceController:           wOptions.ActivityResourceController,
+		activityResultCache:                  newActivityResultCache(wOptions.ActivityResultCacheSize, wOptions.ActivityResultCacheTTL),
+		ActivityDeadlineGracePeriod:          wOptions.ActivityDeadlineGracePeriod,
+		MaxHeartbeatThrottleInterval:         wOptions.MaxHeartbeatThrottleInterval,
+		DefaultHeartbeatThrottleInterval:     wOptions.DefaultHeartbeatThrottleInterval,
 		FeatureFlags:                         wOptions.FeatureFlags,
+		LifecycleHooks:                       wOptions.LifecycleHooks,
+		taskSlots:                            newTaskSlotScheduler(wOptions.MaxConcurrentTaskExecutionSize, wOptions.DecisionTaskSlotRatio),
 	}
 
 	ensureRequiredParams(&workerParams)
@@ -1042,6 +1655,15 @@ func newAggregatedWorker(
 		zapcore.Field{Key: tagWorkerID, Type: zapcore.StringType, String: workerParams.Identity},
 	)
 	logger := workerParams.Logger
+	if wOptions.StickyCacheMaxBytes > 0 || wOptions.StickyCacheEvictionPolicy != StickyCacheEvictionPolicyLRU ||
+		wOptions.StickyCacheMaxPinned > 0 {
+		setStickyWorkflowCacheOptions(stickyCacheOptions{
+			maxBytes:  wOptions.StickyCacheMaxBytes,
+			policy:    wOptions.StickyCacheEvictionPolicy,
+			ttl:       wOptions.StickyCacheTTL,
+			maxPinned: wOptions.StickyCacheMaxPinned,
+		}, logger)
+	}
 	if options.Authorization != nil {
 		service = auth.NewWorkflowServiceWrapper(service, options.Authorization)
 	}
@@ -1050,14 +1672,32 @@ func newAggregatedWorker(
 
 	// worker specific registry
 	registry := newRegistry()
+	registry.activityNameMapper = wOptions.ActivityNameMapper
 
 	// ldaTunnel is a one way tunnel to dispatch activity tasks from workflow poller to activity poller
 	var ldaTunnel *locallyDispatchedActivityTunnel
 
 	// activity types.
-	var activityWorker, locallyDispatchedActivityWorker *activityWorker
+	var activityWorker, locallyDispatchedActivityWorker, hostPinnedActivityWorker *activityWorker
 
 	if !wOptions.DisableActivityWorker {
+		if wOptions.EnableHostPinnedActivities {
+			workerParams.HostID = wOptions.HostID
+			if workerParams.HostID == "" {
+				workerParams.HostID = getWorkerTaskList(uuid.New())
+			}
+			hostPinnedParams := workerParams
+			hostPinnedParams.TaskList = getHostPinnedTasklist(workerParams.HostID)
+			hostPinnedActivityWorker = newActivityWorker(
+				service,
+				domain,
+				hostPinnedParams,
+				nil,
+				registry,
+				nil,
+			)
+		}
+
 		activityWorker = newActivityWorker(
 			service,
 			domain,
@@ -1068,7 +1708,7 @@ func newAggregatedWorker(
 		)
 
 		// do not dispatch locally if TaskListActivitiesPerSecond is set
-		if workerParams.TaskListActivitiesPerSecond == defaultTaskListActivitiesPerSecond {
+		if workerParams.TaskListActivitiesPerSecond == defaultTaskListActivitiesPerSecond && !wOptions.DisableEagerActivityDispatch {
 			// TODO update taskPoller interface so one activity worker can multiplex on multiple pollers
 			locallyDispatchedActivityWorker = newActivityWorker(
 				service,
@@ -1080,6 +1720,7 @@ func newAggregatedWorker(
 			)
 			ldaTunnel = locallyDispatchedActivityWorker.poller.(*locallyDispatchedActivityTaskPoller).ldaTunnel
 			ldaTunnel.metricsScope = metrics.NewTaggedScope(workerParams.MetricsScope)
+			ldaTunnel.registry = registry
 		}
 	}
 
@@ -1127,6 +1768,8 @@ func newAggregatedWorker(
 
 	}
 
+	lazyStartCtx, lazyStartCancel := context.WithCancel(context.Background())
+
 	var shadowWorker *shadowWorker
 	if wOptions.EnableShadowWorker {
 		shadowWorker = newShadowWorker(
@@ -1142,10 +1785,25 @@ func newAggregatedWorker(
 		workflowWorker:                  workflowWorker,
 		activityWorker:                  activityWorker,
 		locallyDispatchedActivityWorker: locallyDispatchedActivityWorker,
+		hostPinnedActivityWorker:        hostPinnedActivityWorker,
 		sessionWorker:                   sessionWorker,
 		shadowWorker:                    shadowWorker,
 		logger:                          logger,
 		registry:                        registry,
+		unhealthyCh:                     make(chan WorkerStatus, 1),
+		healthStopC:                     make(chan struct{}),
+		service:                         service,
+		domain:                          domain,
+		featureFlags:                    wOptions.FeatureFlags,
+		lazyStart:                       wOptions.LazyStart,
+		lazyStartCtx:                    lazyStartCtx,
+		lazyStartCancel:                 lazyStartCancel,
+		lifecycleHooks:                  wOptions.LifecycleHooks,
+		lifecycleInfo: WorkerLifecycleInfo{
+			Domain:   domain,
+			TaskList: taskList,
+			Identity: workerParams.Identity,
+		},
 	}
 }
 
@@ -29,6 +29,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -45,10 +46,15 @@ import (
 const (
 	retryPollOperationInitialInterval = 20 * time.Millisecond
 	retryPollOperationMaxInterval     = 10 * time.Second
-)
 
-var (
-	pollOperationRetryPolicy = createPollRetryPolicy()
+	// the server being busy backs off the pollers longer than other poll failures
+	retryPollServiceBusyInitialInterval = time.Second
+	retryPollServiceBusyMaxInterval     = 30 * time.Second
+
+	// a poller group is reported unhealthy after this many consecutive poll failures,
+	// or when it had no successful poll within unhealthyPollInterval.
+	unhealthyPollFailureThreshold = 5
+	unhealthyPollInterval         = 5 * time.Minute
 )
 
 var errShutdown = errors.New("worker shutting down")
@@ -86,6 +92,8 @@ type (
 		RegisterQueryHandler(handler func(queryType string, queryArgs []byte) ([]byte, error))
 		IsReplaying() bool
 		MutableSideEffect(id string, f func() interface{}, equals func(a, b interface{}) bool) Value
+		GetConfigValue(key string) Value
+		SetSnapshotter(snapshotter WorkflowSnapshotter) (bool, error)
 		GetDataConverter() DataConverter
 		AddSession(sessionInfo *SessionInfo)
 		RemoveSession(sessionID string)
@@ -93,6 +101,7 @@ type (
 		UpsertSearchAttributes(attributes map[string]interface{}) error
 		GetRegistry() *registry
 		GetWorkflowInterceptors() []WorkflowInterceptorFactory
+		GetDefaultActivityOptions() (*ActivityOptions, *LocalActivityOptions)
 	}
 
 	// WorkflowDefinition wraps the code that can execute a workflow.
@@ -101,7 +110,8 @@ type (
 		// Called for each non timed out startDecision event.
 		// Executed after all history events since the previous decision are applied to workflowDefinition
 		OnDecisionTaskStarted()
-		StackTrace() string // Stack trace of all coroutines owned by the Dispatcher instance
+		StackTrace() string  // Stack trace of all coroutines owned by the Dispatcher instance
+		CoroutineCount() int // Number of coroutines owned by the Dispatcher instance that have not completed yet
 		Close()
 	}
 
@@ -116,12 +126,32 @@ type (
 		workerType        string
 		shutdownTimeout   time.Duration
 		userContextCancel context.CancelFunc
+
+		// resourceController suppresses polling while the host is overloaded, nil disables it
+		resourceController *resourceController
+		// taskSlots limits the polled tasks executed concurrently together with other workers, nil disables it
+		taskSlots *taskSlotPool
+		// pollBackoff backs off the pollers after poll failures, busyBackoff after the server rejected polls because
+		// it is busy
+		pollBackoff pollBackoffOptions
+		busyBackoff pollBackoffOptions
+
+		// lifecycleHooks are notified when the pollers start and stop, domain and taskList are reported to them
+		lifecycleHooks WorkerLifecycleHooks
+		domain         string
+		taskList       string
+	}
+
+	// pollBackoffOptions are the intervals of the exponential backoff of pollers, 0 uses the default interval
+	pollBackoffOptions struct {
+		initialInterval time.Duration
+		maxInterval     time.Duration
 	}
 
 	// baseWorker that wraps worker activities.
 	baseWorker struct {
 		options              baseWorkerOptions
-		isWorkerStarted      bool
+		isWorkerStarted      int32          // accessed atomically, see started()
 		shutdownCh           chan struct{}  // Channel used to shut down the go routines.
 		shutdownWG           sync.WaitGroup // The WaitGroup for shutting down existing routines.
 		pollLimiter          *rate.Limiter
@@ -129,12 +159,25 @@ type (
 		limiterContext       context.Context
 		limiterContextCancel func()
 		retrier              *backoff.ConcurrentRetrier // Service errors back off retrier
+		serviceBusyRetrier   *backoff.ConcurrentRetrier // Service busy errors back off retrier
 		logger               *zap.Logger
 		metricsScope         tally.Scope
 
 		pollerRequestCh    chan struct{}
 		taskQueueCh        chan interface{}
 		sessionTokenBucket *sessionTokenBucket
+
+		status baseWorkerStatus
+	}
+
+	// baseWorkerStatus tracks pollers and task slots of a base worker for health reporting.
+	// All fields are accessed atomically.
+	baseWorkerStatus struct {
+		pollersRunning          int32
+		tasksInProgress         int32
+		consecutivePollFailures int32
+		startedAt               int64 // unix nanoseconds
+		lastSuccessfulPoll      int64 // unix nanoseconds
 	}
 
 	polledTask struct {
@@ -142,9 +185,18 @@ type (
 	}
 )
 
-func createPollRetryPolicy() backoff.RetryPolicy {
-	policy := backoff.NewExponentialRetryPolicy(retryPollOperationInitialInterval)
-	policy.SetMaximumInterval(retryPollOperationMaxInterval)
+func createPollRetryPolicy(options pollBackoffOptions, defaultInitialInterval, defaultMaxInterval time.Duration) backoff.RetryPolicy {
+	if options.initialInterval <= 0 {
+		options.initialInterval = defaultInitialInterval
+	}
+	if options.maxInterval <= 0 {
+		options.maxInterval = defaultMaxInterval
+	}
+	if options.maxInterval < options.initialInterval {
+		options.maxInterval = options.initialInterval
+	}
+	policy := backoff.NewExponentialRetryPolicy(options.initialInterval)
+	policy.SetMaximumInterval(options.maxInterval)
 
 	// NOTE: We don't use expiration interval since we don't use retries from retrier class.
 	// We use it to calculate next backoff. We have additional layer that is built on poller
@@ -156,11 +208,13 @@ func createPollRetryPolicy() backoff.RetryPolicy {
 
 func newBaseWorker(options baseWorkerOptions, logger *zap.Logger, metricsScope tally.Scope, sessionTokenBucket *sessionTokenBucket) *baseWorker {
 	ctx, cancel := context.WithCancel(context.Background())
+	pollRetryPolicy := createPollRetryPolicy(options.pollBackoff, retryPollOperationInitialInterval, retryPollOperationMaxInterval)
+	serviceBusyRetryPolicy := createPollRetryPolicy(options.busyBackoff, retryPollServiceBusyInitialInterval, retryPollServiceBusyMaxInterval)
 	bw := &baseWorker{
 		options:         options,
 		shutdownCh:      make(chan struct{}),
 		taskLimiter:     rate.NewLimiter(rate.Limit(options.maxTaskPerSecond), 1),
-		retrier:         backoff.NewConcurrentRetrier(pollOperationRetryPolicy),
+		retrier:         backoff.NewConcurrentRetrier(pollRetryPolicy),
 		logger:          logger.With(zapcore.Field{Key: tagWorkerType, Type: zapcore.StringType, String: options.workerType}),
 		metricsScope:    tagScope(metricsScope, tagWorkerType, options.workerType),
 		pollerRequestCh: make(chan struct{}, options.maxConcurrentTask),
@@ -170,6 +224,7 @@ func newBaseWorker(options baseWorkerOptions, logger *zap.Logger, metricsScope t
 		limiterContextCancel: cancel,
 		sessionTokenBucket:   sessionTokenBucket,
 	}
+	bw.serviceBusyRetrier = backoff.NewConcurrentRetrier(serviceBusyRetryPolicy)
 	if options.pollerRate > 0 {
 		bw.pollLimiter = rate.NewLimiter(rate.Limit(options.pollerRate), 1)
 	}
@@ -179,11 +234,12 @@ func newBaseWorker(options baseWorkerOptions, logger *zap.Logger, metricsScope t
 
 // Start starts a fixed set of routines to do the work.
 func (bw *baseWorker) Start() {
-	if bw.isWorkerStarted {
+	if bw.started() {
 		return
 	}
 
 	bw.metricsScope.Counter(metrics.WorkerStartCounter).Inc(1)
+	atomic.StoreInt64(&bw.status.startedAt, time.Now().UnixNano())
 
 	for i := 0; i < bw.options.pollerCount; i++ {
 		bw.shutdownWG.Add(1)
@@ -193,7 +249,7 @@ func (bw *baseWorker) Start() {
 	bw.shutdownWG.Add(1)
 	go bw.runTaskDispatcher()
 
-	bw.isWorkerStarted = true
+	atomic.StoreInt32(&bw.isWorkerStarted, 1)
 	traceLog(func() {
 		bw.logger.Info("Started Worker",
 			zap.Int("PollerCount", bw.options.pollerCount),
@@ -201,6 +257,18 @@ func (bw *baseWorker) Start() {
 			zap.Float64("MaxTaskPerSecond", bw.options.maxTaskPerSecond),
 		)
 	})
+	if bw.options.lifecycleHooks.OnPollerStart != nil {
+		bw.options.lifecycleHooks.OnPollerStart(bw.pollerLifecycleInfo())
+	}
+}
+
+func (bw *baseWorker) pollerLifecycleInfo() PollerLifecycleInfo {
+	return PollerLifecycleInfo{
+		Domain:      bw.options.domain,
+		TaskList:    bw.options.taskList,
+		WorkerType:  bw.options.workerType,
+		PollerCount: bw.options.pollerCount,
+	}
 }
 
 func (bw *baseWorker) isShutdown() bool {
@@ -215,6 +283,8 @@ func (bw *baseWorker) isShutdown() bool {
 func (bw *baseWorker) runPoller() {
 	defer bw.shutdownWG.Done()
 	bw.metricsScope.Counter(metrics.PollerStartCounter).Inc(1)
+	atomic.AddInt32(&bw.status.pollersRunning, 1)
+	defer atomic.AddInt32(&bw.status.pollersRunning, -1)
 
 	for {
 		select {
@@ -224,6 +294,9 @@ func (bw *baseWorker) runPoller() {
 			if bw.sessionTokenBucket != nil {
 				bw.sessionTokenBucket.waitForAvailableToken()
 			}
+			if rc := bw.options.resourceController; rc != nil && !rc.waitForResources(bw.shutdownCh) {
+				return
+			}
 			bw.pollTask()
 		}
 	}
@@ -258,7 +331,8 @@ func (bw *baseWorker) runTaskDispatcher() {
 func (bw *baseWorker) pollTask() {
 	var err error
 	var task interface{}
-	bw.retrier.Throttle()
+	bw.serviceBusyRetrier.ThrottleUntil(bw.shutdownCh)
+	bw.retrier.ThrottleUntil(bw.shutdownCh)
 	if bw.pollLimiter == nil || bw.pollLimiter.Wait(bw.limiterContext) == nil {
 		task, err = bw.options.taskWorker.PollTask()
 		if err != nil && enableVerboseLogging {
@@ -271,22 +345,43 @@ func (bw *baseWorker) pollTask() {
 				p.Signal(syscall.SIGINT)
 				return
 			}
-			bw.retrier.Failed()
+			if IsServiceBusy(err) {
+				bw.serviceBusyRetrier.Failed()
+			} else {
+				bw.retrier.Failed()
+			}
+			atomic.AddInt32(&bw.status.consecutivePollFailures, 1)
 		} else {
 			bw.retrier.Succeeded()
+			bw.serviceBusyRetrier.Succeeded()
+			atomic.StoreInt32(&bw.status.consecutivePollFailures, 0)
+			atomic.StoreInt64(&bw.status.lastSuccessfulPoll, time.Now().UnixNano())
 		}
 	}
 
 	if task != nil {
+		// the slot is only acquired once a task is polled, so that idle long polls don't hold slots, and the
+		// poller waits for it rather than polling tasks there is no slot to execute
+		if slots := bw.options.taskSlots; slots != nil && !slots.acquire(bw.shutdownCh) {
+			return
+		}
 		select {
 		case bw.taskQueueCh <- &polledTask{task}:
 		case <-bw.shutdownCh:
+			bw.releaseTaskSlot()
 		}
 	} else {
 		bw.pollerRequestCh <- struct{}{} // poll failed, trigger a new poll
 	}
 }
 
+// releaseTaskSlot releases the task slot acquired for a polled task.
+func (bw *baseWorker) releaseTaskSlot() {
+	if slots := bw.options.taskSlots; slots != nil {
+		slots.release()
+	}
+}
+
 func isNonRetriableError(err error) bool {
 	if err == nil {
 		return false
@@ -306,7 +401,10 @@ func (bw *baseWorker) processTask(task interface{}) {
 	polledTask, isPolledTask := task.(*polledTask)
 	if isPolledTask {
 		task = polledTask.task
+		defer bw.releaseTaskSlot()
 	}
+	atomic.AddInt32(&bw.status.tasksInProgress, 1)
+	defer atomic.AddInt32(&bw.status.tasksInProgress, -1)
 	defer func() {
 		if p := recover(); p != nil {
 			bw.metricsScope.Counter(metrics.WorkerPanicCounter).Inc(1)
@@ -331,6 +429,41 @@ func (bw *baseWorker) processTask(task interface{}) {
 	}
 }
 
+// getStatus returns the current status of the base worker polling the given task list.
+func (bw *baseWorker) getStatus(taskList string) PollerStatus {
+	status := PollerStatus{
+		WorkerType:              bw.options.workerType,
+		TaskList:                taskList,
+		Started:                 bw.started() && !bw.isShutdown(),
+		PollersRunning:          int(atomic.LoadInt32(&bw.status.pollersRunning)),
+		TaskSlotsInUse:          int(atomic.LoadInt32(&bw.status.tasksInProgress)),
+		TaskSlotsCapacity:       bw.options.maxConcurrentTask,
+		ConsecutivePollFailures: int(atomic.LoadInt32(&bw.status.consecutivePollFailures)),
+	}
+	if lastPoll := atomic.LoadInt64(&bw.status.lastSuccessfulPoll); lastPoll != 0 {
+		status.LastSuccessfulPoll = time.Unix(0, lastPoll)
+	}
+
+	status.Healthy = true
+	if status.Started {
+		lastActivity := status.LastSuccessfulPoll
+		if lastActivity.IsZero() {
+			lastActivity = time.Unix(0, atomic.LoadInt64(&bw.status.startedAt))
+		}
+		if status.PollersRunning == 0 ||
+			status.ConsecutivePollFailures >= unhealthyPollFailureThreshold ||
+			time.Since(lastActivity) > unhealthyPollInterval {
+			status.Healthy = false
+		}
+	}
+	return status
+}
+
+// started returns true once Start has run. Safe to call concurrently with Start, e.g. from getStatus.
+func (bw *baseWorker) started() bool {
+	return atomic.LoadInt32(&bw.isWorkerStarted) == 1
+}
+
 func (bw *baseWorker) Run() {
 	bw.Start()
 	d := <-getKillSignal()
@@ -342,7 +475,7 @@ func (bw *baseWorker) Run() {
 
 // Shutdown is a blocking call and cleans up all the resources associated with worker.
 func (bw *baseWorker) Stop() {
-	if !bw.isWorkerStarted {
+	if !bw.started() {
 		return
 	}
 	close(bw.shutdownCh)
@@ -353,6 +486,9 @@ func (bw *baseWorker) Stop() {
 			bw.logger.Info("Worker graceful shutdown timed out.", zap.Duration("Shutdown timeout", bw.options.shutdownTimeout))
 		})
 	}
+	if bw.options.lifecycleHooks.OnPollerStop != nil {
+		bw.options.lifecycleHooks.OnPollerStop(bw.pollerLifecycleInfo())
+	}
 
 	// Close context
 	if bw.options.userContextCancel != nil {
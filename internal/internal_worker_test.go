@@ -27,6 +27,7 @@ import (
 	"os"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -39,6 +40,7 @@ import (
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/cache"
 	"go.uber.org/yarpc"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
@@ -285,6 +287,135 @@ func (s *internalWorkerTestSuite) TestWorkerStartFailsWithInvalidDomain() {
 	}
 }
 
+func (s *internalWorkerTestSuite) TestVerifyDomainExistWithRetry() {
+	t := s.T()
+	mockCtrl := gomock.NewController(t)
+	domain := "testDomain"
+
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	gomock.InOrder(
+		service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, &shared.InternalServiceError{}).Times(1),
+		service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.DescribeDomainResponse{}, nil).Times(1),
+	)
+	err := verifyDomainExistWithRetry(context.Background(), service, domain, zap.NewNop(), FeatureFlags{})
+	assert.NoError(t, err)
+
+	service = workflowservicetest.NewMockClient(mockCtrl)
+	service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, &shared.EntityNotExistsError{}).Times(1)
+	err = verifyDomainExistWithRetry(context.Background(), service, domain, zap.NewNop(), FeatureFlags{})
+	assert.IsType(t, &shared.EntityNotExistsError{}, err)
+
+	service = workflowservicetest.NewMockClient(mockCtrl)
+	service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, &shared.InternalServiceError{}).AnyTimes()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = verifyDomainExistWithRetry(ctx, service, domain, zap.NewNop(), FeatureFlags{})
+	assert.IsType(t, &shared.InternalServiceError{}, err)
+}
+
+func (s *internalWorkerTestSuite) TestLazyStartDoesNotFailOnInvalidDomain() {
+	t := s.T()
+	mockCtrl := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	describeCalled := make(chan struct{})
+	service.EXPECT().DescribeDomain(gomock.Any(), gomock.Any(), callOptions()...).Return(nil, &shared.EntityNotExistsError{}).Do(
+		func(ctx context.Context, request *shared.DescribeDomainRequest, opts ...yarpc.CallOption) {
+			close(describeCalled)
+		}).Times(1)
+
+	worker := newAggregatedWorker(service, "testDomain", "testTaskList", WorkerOptions{
+		Logger:    zaptest.NewLogger(t),
+		LazyStart: true,
+	})
+	worker.RegisterActivity(testActivityReturnString)
+	assert.NoError(t, worker.Start())
+
+	select {
+	case <-describeCalled:
+	case <-time.After(time.Second):
+		assert.Fail(t, "domain was not verified in the background")
+	}
+	status := worker.Status()
+	assert.True(t, status.Starting)
+	assert.False(t, status.Healthy)
+	worker.Stop()
+	assert.False(t, worker.activityWorker.worker.started())
+}
+
+func (s *internalWorkerTestSuite) TestListStickyCacheWarmUpExecutions() {
+	t := s.T()
+	mockCtrl := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	newExecution := func(workflowID, taskList string, startTime int64) *shared.WorkflowExecutionInfo {
+		return &shared.WorkflowExecutionInfo{
+			Execution: &shared.WorkflowExecution{WorkflowId: common.StringPtr(workflowID), RunId: common.StringPtr(workflowID + "-run")},
+			StartTime: common.Int64Ptr(startTime),
+			TaskList:  common.StringPtr(taskList),
+		}
+	}
+	service.EXPECT().ListOpenWorkflowExecutions(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(ctx context.Context, request *shared.ListOpenWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListOpenWorkflowExecutionsResponse, error) {
+			assert.Equal(t, int32(2), request.GetMaximumPageSize())
+			if request.TypeFilter.GetName() == "typeA" {
+				return &shared.ListOpenWorkflowExecutionsResponse{Executions: []*shared.WorkflowExecutionInfo{
+					newExecution("a1", "testTaskList", 10),
+					newExecution("a2", "otherTaskList", 30),
+				}}, nil
+			}
+			return &shared.ListOpenWorkflowExecutionsResponse{Executions: []*shared.WorkflowExecutionInfo{
+				newExecution("b1", "testTaskList", 20),
+				newExecution("b2", "testTaskList", 5),
+			}}, nil
+		}).Times(2)
+
+	ww := &workflowWorker{
+		executionParameters: workerExecutionParameters{TaskList: "testTaskList"},
+		workflowService:     service,
+		domain:              "testDomain",
+	}
+	executions, err := ww.listStickyCacheWarmUpExecutions(context.Background(), []string{"typeA", "typeB"}, 2)
+	assert.NoError(t, err)
+	assert.Len(t, executions, 2)
+	assert.Equal(t, "b1", executions[0].Execution.GetWorkflowId())
+	assert.Equal(t, "a1", executions[1].Execution.GetWorkflowId())
+}
+
+func (s *internalWorkerTestSuite) TestStickyCacheWarmUpCancelledOnStop() {
+	t := s.T()
+	mockCtrl := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	listed := make(chan struct{})
+	service.EXPECT().ListOpenWorkflowExecutions(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(ctx context.Context, request *shared.ListOpenWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListOpenWorkflowExecutionsResponse, error) {
+			close(listed)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+	registry := newRegistry()
+	registry.RegisterWorkflowWithOptions(testReplayWorkflow, RegisterWorkflowOptions{Name: "typeA"})
+	ww := &workflowWorker{
+		executionParameters: workerExecutionParameters{TaskList: "testTaskList"},
+		workflowService:     service,
+		domain:              "testDomain",
+		worker:              &baseWorker{logger: zap.NewNop()},
+		stopC:               make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		ww.warmUpStickyCache(&workflowTaskHandlerImpl{registry: registry}, 2)
+		close(done)
+	}()
+
+	<-listed
+	close(ww.stopC)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "the warm-up is not cancelled when the worker stops")
+	}
+}
+
 func (s *internalWorkerTestSuite) TestStartShadowWorkerFailWithInvalidOptions() {
 	invalidOptions := []*ShadowOptions{
 		{
@@ -1079,6 +1210,55 @@ func TestWorkerOptionNonDefaults(t *testing.T) {
 	assertWorkerExecutionParamsEqual(t, expected, activityWorker.executionParameters)
 }
 
+func TestActivityOnlyWorker(t *testing.T) {
+	domain := "worker-options-test"
+	taskList := "worker-options-tl"
+
+	_, err := NewActivityWorker(nil, domain, taskList, WorkerOptions{DisableActivityWorker: true})
+	require.Error(t, err)
+	_, err = NewActivityWorker(nil, domain, taskList, WorkerOptions{EnableShadowWorker: true})
+	require.Error(t, err)
+
+	aggWorker, err := NewActivityWorker(nil, domain, taskList, WorkerOptions{Logger: zap.NewNop()})
+	require.NoError(t, err)
+	require.Nil(t, aggWorker.workflowWorker)
+	require.NotNil(t, aggWorker.activityWorker)
+
+	// isolate from the global registry so no activity is registered
+	aggWorker.registry = &registry{
+		workflowFuncMap:  make(map[string]interface{}),
+		workflowAliasMap: make(map[string]string),
+		activityFuncMap:  make(map[string]activity),
+		activityAliasMap: make(map[string]string),
+	}
+	require.EqualError(t, aggWorker.Start(), "activity worker has no activities registered")
+}
+
+func TestWorkflowOnlyWorker(t *testing.T) {
+	domain := "worker-options-test"
+	taskList := "worker-options-tl"
+
+	_, err := NewWorkflowWorker(nil, domain, taskList, WorkerOptions{DisableWorkflowWorker: true})
+	require.Error(t, err)
+	_, err = NewWorkflowWorker(nil, domain, taskList, WorkerOptions{EnableSessionWorker: true})
+	require.Error(t, err)
+
+	aggWorker, err := NewWorkflowWorker(nil, domain, taskList, WorkerOptions{Logger: zap.NewNop()})
+	require.NoError(t, err)
+	require.NotNil(t, aggWorker.workflowWorker)
+	require.Nil(t, aggWorker.activityWorker)
+	require.Nil(t, aggWorker.locallyDispatchedActivityWorker)
+
+	// isolate from the global registry so no workflow is registered
+	aggWorker.registry = &registry{
+		workflowFuncMap:  make(map[string]interface{}),
+		workflowAliasMap: make(map[string]string),
+		activityFuncMap:  make(map[string]activity),
+		activityAliasMap: make(map[string]string),
+	}
+	require.EqualError(t, aggWorker.Start(), "workflow worker has no workflows registered")
+}
+
 func assertWorkerExecutionParamsEqual(t *testing.T, paramsA workerExecutionParameters, paramsB workerExecutionParameters) {
 	require.Equal(t, paramsA.TaskList, paramsA.TaskList)
 	require.Equal(t, paramsA.Identity, paramsB.Identity)
@@ -1184,3 +1364,276 @@ func TestIsNonRetriableError(t *testing.T) {
 		require.Equal(t, test.expected, isNonRetriableError(test.err))
 	}
 }
+
+type statusTestPoller struct {
+	pollErr error
+}
+
+func (p *statusTestPoller) PollTask() (interface{}, error) {
+	time.Sleep(time.Millisecond)
+	return nil, p.pollErr
+}
+
+func (p *statusTestPoller) ProcessTask(interface{}) error {
+	return nil
+}
+
+func TestBaseWorkerStatus(t *testing.T) {
+	poller := &statusTestPoller{}
+	bw := newBaseWorker(baseWorkerOptions{
+		pollerCount:       2,
+		maxConcurrentTask: 10,
+		maxTaskPerSecond:  1000,
+		taskWorker:        poller,
+		workerType:        "ActivityWorker",
+	}, zap.NewNop(), tally.NoopScope, nil)
+
+	status := bw.getStatus("tl")
+	require.False(t, status.Started)
+	require.True(t, status.Healthy)
+	require.Equal(t, "ActivityWorker", status.WorkerType)
+	require.Equal(t, "tl", status.TaskList)
+	require.Equal(t, 10, status.TaskSlotsCapacity)
+
+	bw.Start()
+	defer bw.Stop()
+	require.Eventually(t, func() bool {
+		status := bw.getStatus("tl")
+		return status.PollersRunning == 2 && !status.LastSuccessfulPoll.IsZero()
+	}, time.Second, 10*time.Millisecond)
+	status = bw.getStatus("tl")
+	require.True(t, status.Started)
+	require.True(t, status.Healthy)
+	require.Equal(t, 0, status.ConsecutivePollFailures)
+}
+
+func TestBaseWorkerStatus_PollFailures(t *testing.T) {
+	poller := &statusTestPoller{pollErr: &shared.InternalServiceError{Message: "poll failed"}}
+	bw := newBaseWorker(baseWorkerOptions{
+		pollerCount:       1,
+		maxConcurrentTask: 10,
+		maxTaskPerSecond:  1000,
+		taskWorker:        poller,
+		workerType:        "ActivityWorker",
+	}, zap.NewNop(), tally.NoopScope, nil)
+	bw.Start()
+	defer bw.Stop()
+
+	require.Eventually(t, func() bool {
+		return !bw.getStatus("tl").Healthy
+	}, 5*time.Second, 10*time.Millisecond)
+	status := bw.getStatus("tl")
+	require.True(t, status.LastSuccessfulPoll.IsZero())
+	require.True(t, status.ConsecutivePollFailures >= unhealthyPollFailureThreshold)
+}
+
+func TestBaseWorkerPollBackoff(t *testing.T) {
+	newWorker := func(pollErr error, busyInterval time.Duration) *baseWorker {
+		return newBaseWorker(baseWorkerOptions{
+			pollerCount:       1,
+			maxConcurrentTask: 10,
+			maxTaskPerSecond:  1000,
+			taskWorker:        &statusTestPoller{pollErr: pollErr},
+			workerType:        "ActivityWorker",
+			pollBackoff:       pollBackoffOptions{initialInterval: time.Millisecond, maxInterval: time.Millisecond},
+			busyBackoff:       pollBackoffOptions{initialInterval: busyInterval, maxInterval: busyInterval},
+		}, zap.NewNop(), tally.NoopScope, nil)
+	}
+	elapsed := func(f func()) time.Duration {
+		start := time.Now()
+		f()
+		return time.Since(start)
+	}
+
+	// the server being busy backs off the pollers with the service busy intervals
+	bw := newWorker(&shared.ServiceBusyError{}, 200*time.Millisecond)
+	bw.pollTask()
+	require.True(t, elapsed(bw.pollTask) >= 150*time.Millisecond)
+
+	// other poll failures back off with the poll intervals
+	bw = newWorker(&shared.InternalServiceError{}, 200*time.Millisecond)
+	bw.pollTask()
+	require.True(t, elapsed(bw.pollTask) < 150*time.Millisecond)
+
+	// the backoff is interrupted by the worker shutdown
+	bw = newWorker(&shared.ServiceBusyError{}, time.Minute)
+	bw.pollTask()
+	close(bw.shutdownCh)
+	require.True(t, elapsed(bw.pollTask) < time.Second)
+}
+
+type slotTestPoller struct {
+	polls     int32
+	processed int32
+	processCh chan struct{}
+}
+
+func (p *slotTestPoller) PollTask() (interface{}, error) {
+	time.Sleep(time.Millisecond)
+	if polls := atomic.AddInt32(&p.polls, 1); polls == 1 || polls == 5 {
+		return "task", nil
+	}
+	return nil, nil
+}
+
+func (p *slotTestPoller) ProcessTask(interface{}) error {
+	<-p.processCh
+	atomic.AddInt32(&p.processed, 1)
+	return nil
+}
+
+func TestBaseWorkerTaskSlots(t *testing.T) {
+	poller := &slotTestPoller{processCh: make(chan struct{})}
+	bw := newBaseWorker(baseWorkerOptions{
+		pollerCount:       2,
+		maxConcurrentTask: 10,
+		maxTaskPerSecond:  1000,
+		taskWorker:        poller,
+		workerType:        "ActivityWorker",
+		taskSlots:         newTaskSlotScheduler(1, 0).forActivities(),
+	}, zap.NewNop(), tally.NoopScope, nil)
+	bw.Start()
+	defer bw.Stop()
+
+	// the only slot is held by the first task, the polls don't need a slot and keep going, only the poller holding
+	// the second task waits for the slot
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&poller.polls) > 10
+	}, time.Second, time.Millisecond)
+	require.Equal(t, 1, bw.getStatus("tl").TaskSlotsInUse)
+
+	// once the first task is processed, the second one gets the slot
+	close(poller.processCh)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&poller.processed) == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestSessionCreationWorkerTaskSlots(t *testing.T) {
+	params := workerExecutionParameters{
+		TaskList:  "tasklist",
+		Logger:    zap.NewNop(),
+		taskSlots: newTaskSlotScheduler(10, 0),
+	}
+	activityWorker := newActivityTaskWorker(nil, "domain", params, nil, make(chan struct{}), nil, "ActivityWorker")
+	require.NotNil(t, activityWorker.worker.options.taskSlots)
+	// a session creation activity runs for the whole session, it must not hold a slot all along
+	creationWorker := newActivityTaskWorker(nil, "domain", params, newSessionTokenBucket(1), make(chan struct{}), nil, "ActivityWorker")
+	require.Nil(t, creationWorker.worker.options.taskSlots)
+}
+
+func TestAggregatedWorkerStatus(t *testing.T) {
+	aggWorker := newAggregatedWorker(nil, "worker-status-test", "worker-status-tl", WorkerOptions{Logger: zap.NewNop()})
+	status := aggWorker.Status()
+	require.True(t, status.Healthy)
+	require.Len(t, status.Pollers, 2)
+	require.Equal(t, "DecisionWorker", status.Pollers[0].WorkerType)
+	require.Equal(t, "ActivityWorker", status.Pollers[1].WorkerType)
+	require.NotNil(t, aggWorker.Unhealthy())
+}
+
+func TestBaseWorkerLifecycleHooks(t *testing.T) {
+	var events []string
+	hooks := WorkerLifecycleHooks{
+		OnPollerStart: func(info PollerLifecycleInfo) {
+			require.Equal(t, PollerLifecycleInfo{Domain: "d", TaskList: "tl", WorkerType: "ActivityWorker", PollerCount: 2}, info)
+			events = append(events, "pollerStart")
+		},
+		OnPollerStop: func(info PollerLifecycleInfo) {
+			require.Equal(t, "ActivityWorker", info.WorkerType)
+			events = append(events, "pollerStop")
+		},
+	}
+	bw := newBaseWorker(baseWorkerOptions{
+		pollerCount:       2,
+		maxConcurrentTask: 10,
+		maxTaskPerSecond:  1000,
+		taskWorker:        &statusTestPoller{},
+		workerType:        "ActivityWorker",
+		domain:            "d",
+		taskList:          "tl",
+		lifecycleHooks:    hooks,
+	}, zap.NewNop(), tally.NoopScope, nil)
+
+	// stopping a worker that was never started does not call the hooks
+	bw.Stop()
+	require.Empty(t, events)
+
+	bw = newBaseWorker(bw.options, zap.NewNop(), tally.NoopScope, nil)
+	bw.Start()
+	require.Equal(t, []string{"pollerStart"}, events)
+	bw.Stop()
+	require.Equal(t, []string{"pollerStart", "pollerStop"}, events)
+}
+
+func TestAggregatedWorkerLifecycleHooks(t *testing.T) {
+	var events []string
+	record := func(event string) func(info WorkerLifecycleInfo) {
+		return func(info WorkerLifecycleInfo) {
+			require.Equal(t, WorkerLifecycleInfo{Domain: "lifecycle-test", TaskList: "lifecycle-tl", Identity: "worker-id"}, info)
+			events = append(events, event)
+		}
+	}
+	aggWorker := newAggregatedWorker(nil, "lifecycle-test", "lifecycle-tl", WorkerOptions{
+		Logger:   zap.NewNop(),
+		Identity: "worker-id",
+		LifecycleHooks: WorkerLifecycleHooks{
+			OnStart:            record("start"),
+			OnShutdownBegin:    record("shutdownBegin"),
+			OnShutdownComplete: record("shutdownComplete"),
+		},
+	})
+
+	require.NoError(t, aggWorker.startWorkers())
+	require.Equal(t, []string{"start"}, events)
+	aggWorker.Stop()
+	aggWorker.Stop()
+	require.Equal(t, []string{"start", "shutdownBegin", "shutdownComplete"}, events)
+}
+
+func TestAggregatedWorkerHostPinnedActivities(t *testing.T) {
+	aggWorker := newAggregatedWorker(nil, "host-pinned-test", "host-pinned-tl", WorkerOptions{
+		Logger:                     zap.NewNop(),
+		EnableHostPinnedActivities: true,
+		HostID:                     "host-1",
+	})
+	status := aggWorker.Status()
+	require.Len(t, status.Pollers, 3)
+	require.Equal(t, "ActivityWorker", status.Pollers[2].WorkerType)
+	require.Equal(t, getHostPinnedTasklist("host-1"), status.Pollers[2].TaskList)
+	require.Equal(t, "host-1", aggWorker.activityWorker.executionParameters.HostID)
+
+	// without a HostID the worker generates a unique one
+	aggWorker = newAggregatedWorker(nil, "host-pinned-test", "host-pinned-tl", WorkerOptions{
+		Logger:                     zap.NewNop(),
+		EnableHostPinnedActivities: true,
+	})
+	hostID := aggWorker.activityWorker.executionParameters.HostID
+	require.NotEmpty(t, hostID)
+	require.Equal(t, getHostPinnedTasklist(hostID), aggWorker.hostPinnedActivityWorker.executionParameters.TaskList)
+
+	_, err := NewWorkflowWorker(nil, "host-pinned-test", "host-pinned-tl", WorkerOptions{EnableHostPinnedActivities: true})
+	require.Error(t, err)
+}
+
+func TestNewStickyWorkflowCacheOptions(t *testing.T) {
+	options := newStickyWorkflowCacheOptions(stickyCacheOptions{maxBytes: 1024})
+	assert.Equal(t, int64(1024), options.MaxBytes)
+	assert.Equal(t, cache.PolicyLRU, options.Policy)
+	assert.Zero(t, options.TTL)
+	assert.NotNil(t, options.RemovedFunc)
+
+	options = newStickyWorkflowCacheOptions(stickyCacheOptions{policy: StickyCacheEvictionPolicyLFU})
+	assert.Equal(t, cache.PolicyLFU, options.Policy)
+	assert.Zero(t, options.TTL)
+
+	options = newStickyWorkflowCacheOptions(stickyCacheOptions{policy: StickyCacheEvictionPolicyTTL})
+	assert.Equal(t, cache.PolicyLRU, options.Policy)
+	assert.Equal(t, defaultStickyCacheTTL, options.TTL)
+	assert.True(t, options.SlidingTTL)
+	assert.Equal(t, defaultStickyCacheTTL/2, options.SweepInterval)
+
+	options = newStickyWorkflowCacheOptions(stickyCacheOptions{policy: StickyCacheEvictionPolicyTTL, ttl: time.Minute})
+	assert.Equal(t, time.Minute, options.TTL)
+	assert.Equal(t, 30*time.Second, options.SweepInterval)
+}
@@ -78,6 +78,17 @@ type (
 		settable Settable // used to unblock the future when all coroutines have completed
 	}
 
+	// Implements Mutex interface
+	mutexImpl struct {
+		locked bool
+	}
+
+	// Implements Semaphore interface
+	semaphoreImpl struct {
+		size int64 // the total number of permits
+		cur  int64 // the number of permits currently held
+	}
+
 	// Dispatcher is a container of a set of coroutines.
 	dispatcher interface {
 		// ExecuteUntilAllBlocked executes coroutines one by one in deterministic order
@@ -85,6 +96,8 @@ type (
 		ExecuteUntilAllBlocked() (err error)
 		// IsDone returns true when all of coroutines are completed
 		IsDone() bool
+		// CoroutineCount returns the number of coroutines that have not completed yet
+		CoroutineCount() int
 		Close()             // Destroys all coroutines without waiting for their completion
 		StackTrace() string // Stack trace of all coroutines owned by the Dispatcher instance
 	}
@@ -170,10 +183,13 @@ type (
 		taskListName                        *string
 		executionStartToCloseTimeoutSeconds *int32
 		taskStartToCloseTimeoutSeconds      *int32
+		taskStartToCloseTimeoutInherited    bool // taskStartToCloseTimeoutSeconds is the one of the current workflow
 		domain                              *string
 		workflowID                          string
 		waitForCancellation                 bool
 		signalChannels                      map[string]Channel
+		signalOverflowHandlers              map[string]func(signal Value)
+		signalHandlers                      map[string]struct{}
 		queryHandlers                       map[string]func([]byte) ([]byte, error)
 		workflowIDReusePolicy               WorkflowIDReusePolicy
 		dataConverter                       DataConverter
@@ -231,6 +247,8 @@ type (
 		fn            interface{}
 		queryType     string
 		dataConverter DataConverter
+		ctx           Context
+		timeout       time.Duration
 	}
 )
 
@@ -242,12 +260,15 @@ const (
 	workflowResultContextKey         = "workflowResult"
 	coroutinesContextKey             = "coroutines"
 	workflowEnvOptionsContextKey     = "wfEnvOptions"
+	queryHandlerOptionsContextKey    = "queryHandlerOptions"
 )
 
 // Assert that structs do indeed implement the interfaces
 var _ Channel = (*channelImpl)(nil)
 var _ Selector = (*selectorImpl)(nil)
 var _ WaitGroup = (*waitGroupImpl)(nil)
+var _ Mutex = (*mutexImpl)(nil)
+var _ Semaphore = (*semaphoreImpl)(nil)
 var _ dispatcher = (*dispatcherImpl)(nil)
 
 var stackBuf [100000]byte
@@ -431,7 +452,17 @@ func newWorkflowContext(env workflowEnvironment, interceptors WorkflowIntercepto
 	rootCtx = WithWorkflowTaskList(rootCtx, wInfo.TaskListName)
 	rootCtx = WithExecutionStartToCloseTimeout(rootCtx, time.Duration(wInfo.ExecutionStartToCloseTimeoutSeconds)*time.Second)
 	rootCtx = WithWorkflowTaskStartToCloseTimeout(rootCtx, time.Duration(wInfo.TaskStartToCloseTimeoutSeconds)*time.Second)
-	rootCtx = WithTaskList(rootCtx, wInfo.TaskListName)
+	getWorkflowEnvOptions(rootCtx).taskStartToCloseTimeoutInherited = true
+	defaultActivityOptions, defaultLocalActivityOptions := env.GetDefaultActivityOptions()
+	if defaultActivityOptions != nil {
+		rootCtx = WithActivityOptions(rootCtx, *defaultActivityOptions)
+	}
+	if defaultLocalActivityOptions != nil {
+		rootCtx = WithLocalActivityOptions(rootCtx, *defaultLocalActivityOptions)
+	}
+	if defaultActivityOptions == nil || defaultActivityOptions.TaskList == "" {
+		rootCtx = WithTaskList(rootCtx, wInfo.TaskListName)
+	}
 	rootCtx = WithDataConverter(rootCtx, env.GetDataConverter())
 	rootCtx = withContextPropagators(rootCtx, env.GetContextPropagators())
 	getActivityOptions(rootCtx).OriginalTaskListName = wInfo.TaskListName
@@ -470,6 +501,7 @@ func (d *syncWorkflowDefinition) Execute(env workflowEnvironment, header *shared
 	})
 
 	// set the information from the headers that is to be propagated in the workflow context
+	rootCtx = WithValue(rootCtx, workflowHeaderContextKey, header)
 	for _, ctxProp := range env.GetContextPropagators() {
 		var err error
 		if rootCtx, err = ctxProp.ExtractToWorkflow(rootCtx, NewHeaderReader(header)); err != nil {
@@ -479,6 +511,7 @@ func (d *syncWorkflowDefinition) Execute(env workflowEnvironment, header *shared
 
 	d.rootCtx, d.cancel = WithCancel(rootCtx)
 	d.dispatcher = dispatcher
+	env.WorkflowInfo().signalChannels = getWorkflowEnvOptions(d.rootCtx).signalChannels
 
 	getWorkflowEnvironment(d.rootCtx).RegisterCancelHandler(func() {
 		// It is ok to call this method multiple times.
@@ -492,6 +525,12 @@ func (d *syncWorkflowDefinition) Execute(env workflowEnvironment, header *shared
 		ch := eo.getSignalChannel(d.rootCtx, name).(*channelImpl)
 		ok := ch.SendAsync(result)
 		if !ok {
+			if handler, bounded := eo.signalOverflowHandlers[name]; bounded {
+				if handler != nil {
+					handler(newEncodedValue(result, ch.dataConverter))
+				}
+				return
+			}
 			panic(fmt.Sprintf("Exceeded channel buffer size for signal: %v", name))
 		}
 	})
@@ -500,7 +539,7 @@ func (d *syncWorkflowDefinition) Execute(env workflowEnvironment, header *shared
 		eo := getWorkflowEnvOptions(d.rootCtx)
 		handler, ok := eo.queryHandlers[queryType]
 		if !ok {
-			keys := []string{QueryTypeStackTrace, QueryTypeOpenSessions}
+			keys := []string{QueryTypeStackTrace, QueryTypeOpenSessions, QueryTypeWorkerMetadata}
 			for k := range eo.queryHandlers {
 				keys = append(keys, k)
 			}
@@ -518,6 +557,13 @@ func (d *syncWorkflowDefinition) StackTrace() string {
 	return d.dispatcher.StackTrace()
 }
 
+func (d *syncWorkflowDefinition) CoroutineCount() int {
+	if d.dispatcher == nil {
+		return 0
+	}
+	return d.dispatcher.CoroutineCount()
+}
+
 func (d *syncWorkflowDefinition) Close() {
 	if d.dispatcher != nil {
 		d.dispatcher.Close()
@@ -645,6 +691,15 @@ func (c *channelImpl) ReceiveAsyncWithMoreFlag(valuePtr interface{}) (ok bool, m
 	}
 }
 
+// bufferedCount returns the number of values sent to the channel which were not received yet.
+func (c *channelImpl) bufferedCount() int {
+	count := len(c.buffer) + len(c.blockedSends)
+	if c.recValue != nil {
+		count++
+	}
+	return count
+}
+
 // ok = true means that value was received
 // more = true means that channel is not closed and more deliveries are possible
 func (c *channelImpl) receiveAsyncImpl(callback *receiveCallback) (v interface{}, ok bool, more bool) {
@@ -950,6 +1005,10 @@ func (d *dispatcherImpl) IsDone() bool {
 	return len(d.coroutines) == 0
 }
 
+func (d *dispatcherImpl) CoroutineCount() int {
+	return len(d.coroutines)
+}
+
 func (d *dispatcherImpl) Close() {
 	d.mutex.Lock()
 	if d.closed {
@@ -1183,6 +1242,7 @@ func getValidatedWorkflowOptions(ctx Context) (*workflowOptions, error) {
 	if p.executionStartToCloseTimeoutSeconds == nil || *p.executionStartToCloseTimeoutSeconds <= 0 {
 		return nil, errors.New("missing or invalid ExecutionStartToCloseTimeout")
 	}
+	defaultRetryExpiration(p.retryPolicy, *p.executionStartToCloseTimeoutSeconds)
 	if err := validateRetryPolicy(p.retryPolicy); err != nil {
 		return nil, err
 	}
@@ -1210,6 +1270,19 @@ func getWorkflowEnvOptions(ctx Context) *workflowOptions {
 	return nil
 }
 
+// getRegisteredDecisionTaskTimeout returns the DecisionTaskStartToCloseTimeout, in seconds, the workflow type was
+// registered with, or nil if it was registered without one or the workflow code set the timeout of the options.
+func getRegisteredDecisionTaskTimeout(options *workflowOptions, r *registry, workflowType string) *int32 {
+	if r == nil || (options.taskStartToCloseTimeoutSeconds != nil && *options.taskStartToCloseTimeoutSeconds != 0 &&
+		!options.taskStartToCloseTimeoutInherited) {
+		return nil
+	}
+	if timeout, ok := r.getWorkflowDecisionTaskTimeout(workflowType); ok {
+		return common.Int32Ptr(common.Int32Ceil(timeout.Seconds()))
+	}
+	return nil
+}
+
 func setWorkflowEnvOptionsIfNotExist(ctx Context) Context {
 	options := getWorkflowEnvOptions(ctx)
 	var newOptions workflowOptions
@@ -1217,6 +1290,8 @@ func setWorkflowEnvOptionsIfNotExist(ctx Context) Context {
 		newOptions = *options
 	} else {
 		newOptions.signalChannels = make(map[string]Channel)
+		newOptions.signalOverflowHandlers = make(map[string]func(signal Value))
+		newOptions.signalHandlers = make(map[string]struct{})
 		newOptions.queryHandlers = make(map[string]func([]byte) ([]byte, error))
 	}
 	if newOptions.dataConverter == nil {
@@ -1247,6 +1322,9 @@ func getHeadersFromContext(ctx Context) *shared.Header {
 	header := &s.Header{
 		Fields: make(map[string][]byte),
 	}
+	for key, value := range getOutgoingHeaderFields(ctx) {
+		header.Fields[key] = value
+	}
 	contextPropagators := getContextPropagatorsFromWorkflowContext(ctx)
 	for _, ctxProp := range contextPropagators {
 		ctxProp.InjectFromWorkflow(ctx, NewHeaderWriter(header))
@@ -1264,6 +1342,60 @@ func (w *workflowOptions) getSignalChannel(ctx Context, signalName string) Chann
 	return ch
 }
 
+// setSignalChannelOptions bounds the buffer of the signal channel and sets the handler for the signals that
+// do not fit in it.
+func (w *workflowOptions) setSignalChannelOptions(ctx Context, signalName string, options SignalChannelOptions) {
+	ch := w.getSignalChannel(ctx, signalName).(*channelImpl)
+	if options.MaxBufferedSignals > 0 {
+		ch.size = options.MaxBufferedSignals
+	}
+	w.signalOverflowHandlers[signalName] = options.OnOverflow
+}
+
+// setSignalHandler starts a coroutine that invokes the handler for every signal received on the signal channel.
+func (w *workflowOptions) setSignalHandler(ctx Context, signalName string, handler interface{}) error {
+	fnType := reflect.TypeOf(handler)
+	if err := validateSignalHandlerFn(fnType); err != nil {
+		return err
+	}
+	if _, ok := w.signalHandlers[signalName]; ok {
+		return fmt.Errorf("signal handler for signal %v is already set", signalName)
+	}
+	w.signalHandlers[signalName] = struct{}{}
+
+	ch := GetSignalChannel(ctx, signalName)
+	fnValue := reflect.ValueOf(handler)
+	GoNamed(ctx, "signal-handler-"+signalName, func(ctx Context) {
+		for {
+			args := []reflect.Value{reflect.ValueOf(ctx)}
+			var valuePtr interface{}
+			if fnType.NumIn() == 2 {
+				arg := reflect.New(fnType.In(1))
+				valuePtr = arg.Interface()
+				args = append(args, arg.Elem())
+			}
+			if more := ch.Receive(ctx, valuePtr); !more {
+				return
+			}
+			fnValue.Call(args)
+		}
+	})
+	return nil
+}
+
+func validateSignalHandlerFn(fnType reflect.Type) error {
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return fmt.Errorf("signal handler must be function but was %v", fnType)
+	}
+	if fnType.NumIn() == 0 || fnType.NumIn() > 2 || !isWorkflowContext(fnType.In(0)) {
+		return errors.New("signal handler must accept workflow.Context as the first parameter and optionally the signal argument")
+	}
+	if fnType.NumOut() != 0 {
+		return fmt.Errorf("signal handler must not return any value, but found %d return values", fnType.NumOut())
+	}
+	return nil
+}
+
 // getUnhandledSignals checks if there are any signal channels that have data to be consumed.
 func (w *workflowOptions) getUnhandledSignals() []string {
 	unhandledSignals := []string{}
@@ -1311,7 +1443,13 @@ func newDecodeFuture(ctx Context, fn interface{}) (Future, Settable) {
 
 // setQueryHandler sets query handler for given queryType.
 func setQueryHandler(ctx Context, queryType string, handler interface{}) error {
-	qh := &queryHandler{fn: handler, queryType: queryType, dataConverter: getDataConverterFromWorkflowContext(ctx)}
+	qh := &queryHandler{fn: handler, queryType: queryType, dataConverter: getDataConverterFromWorkflowContext(ctx), ctx: ctx}
+	if options, ok := ctx.Value(queryHandlerOptionsContextKey).(QueryHandlerOptions); ok {
+		qh.timeout = options.Timeout
+		if options.DataConverter != nil {
+			qh.dataConverter = options.DataConverter
+		}
+	}
 	err := qh.validateHandlerFn()
 	if err != nil {
 		return err
@@ -1365,7 +1503,12 @@ func (h *queryHandler) execute(input []byte) (result []byte, err error) {
 	fnType := reflect.TypeOf(h.fn)
 	var args []reflect.Value
 
-	if fnType.NumIn() == 1 && util.IsTypeByteSlice(fnType.In(0)) {
+	inputIndex := 0
+	if fnType.NumIn() > 0 && isWorkflowContext(fnType.In(0)) {
+		args = append(args, reflect.ValueOf(h.ctx))
+		inputIndex = 1
+	}
+	if fnType.NumIn() == inputIndex+1 && util.IsTypeByteSlice(fnType.In(inputIndex)) {
 		args = append(args, reflect.ValueOf(input))
 	} else {
 		decoded, err := decodeArgs(h.dataConverter, fnType, input)
@@ -1377,7 +1520,19 @@ func (h *queryHandler) execute(input []byte) (result []byte, err error) {
 
 	// invoke the query handler with arguments.
 	fnValue := reflect.ValueOf(h.fn)
+	startTime := time.Now()
 	retValues := fnValue.Call(args)
+	if h.timeout > 0 {
+		if elapsed := time.Since(startTime); elapsed > h.timeout {
+			env := getWorkflowEnvironment(h.ctx)
+			env.GetMetricsScope().Counter(metrics.QueryHandlerTimeoutCounter).Inc(1)
+			env.GetLogger().Warn("Query handler exceeded its timeout.",
+				zap.String(tagQueryType, h.queryType),
+				zap.Duration("Timeout", h.timeout),
+				zap.Duration("Elapsed", elapsed))
+			return nil, fmt.Errorf("query handler for queryType: %v exceeded its timeout of %v, took %v", h.queryType, h.timeout, elapsed)
+		}
+	}
 
 	// we already verified (in validateHandlerFn()) that the query handler returns 2 values
 	retValue := retValues[0]
@@ -1450,3 +1605,76 @@ func (wg *waitGroupImpl) Wait(ctx Context) {
 	}
 	wg.future, wg.settable = NewFuture(ctx)
 }
+
+// Lock blocks until the mutex is acquired by the calling coroutine.
+// Coroutines waiting on the mutex acquire it in the deterministic order
+// in which they are scheduled by the dispatcher.
+//
+// param ctx Context -> workflow context
+func (m *mutexImpl) Lock(ctx Context) error {
+	err := Await(ctx, func() bool {
+		return !m.locked
+	})
+	if err != nil {
+		return err
+	}
+	m.locked = true
+	return nil
+}
+
+// TryLock acquires the mutex without blocking if it is not held.
+func (m *mutexImpl) TryLock(ctx Context) bool {
+	if m.locked {
+		return false
+	}
+	m.locked = true
+	return true
+}
+
+// Unlock releases the mutex.
+func (m *mutexImpl) Unlock() {
+	if !m.locked {
+		panic("unlock of unlocked Mutex")
+	}
+	m.locked = false
+}
+
+// IsLocked reports whether the mutex is currently held.
+func (m *mutexImpl) IsLocked() bool {
+	return m.locked
+}
+
+// Acquire blocks until n permits are available and acquires them.
+//
+// param ctx Context -> workflow context
+// param n int64 -> the number of permits to acquire
+func (s *semaphoreImpl) Acquire(ctx Context, n int64) error {
+	if n <= 0 || n > s.size {
+		panic(fmt.Sprintf("invalid number of permits to acquire: %v", n))
+	}
+	err := Await(ctx, func() bool {
+		return s.cur+n <= s.size
+	})
+	if err != nil {
+		return err
+	}
+	s.cur += n
+	return nil
+}
+
+// TryAcquire acquires n permits without blocking if they are available.
+func (s *semaphoreImpl) TryAcquire(ctx Context, n int64) bool {
+	if n <= 0 || s.cur+n > s.size {
+		return false
+	}
+	s.cur += n
+	return true
+}
+
+// Release returns n permits to the semaphore.
+func (s *semaphoreImpl) Release(n int64) {
+	if n <= 0 || n > s.cur {
+		panic("semaphore released more permits than held")
+	}
+	s.cur -= n
+}
@@ -27,6 +27,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	"go.uber.org/cadence/internal/common/serializer"
@@ -58,15 +59,17 @@ var (
 type (
 	// workflowClient is the client for starting a workflow execution.
 	workflowClient struct {
-		workflowService    workflowserviceclient.Interface
-		domain             string
-		registry           *registry
-		metricsScope       *metrics.TaggedScope
-		identity           string
-		dataConverter      DataConverter
-		contextPropagators []ContextPropagator
-		tracer             opentracing.Tracer
-		featureFlags       FeatureFlags
+		workflowService     workflowserviceclient.Interface
+		domain              string
+		registry            *registry
+		metricsScope        *metrics.TaggedScope
+		identity            string
+		dataConverter       DataConverter
+		contextPropagators  []ContextPropagator
+		tracer              opentracing.Tracer
+		featureFlags        FeatureFlags
+		workflowIDGenerator WorkflowIDGenerator
+		startWorkflowDedup  *startWorkflowDedupCache
 	}
 
 	// domainClient is the client for managing domains.
@@ -137,8 +140,57 @@ type (
 		// func which use a next token to get next page of history events
 		paginate func(nexttoken []byte) (*s.GetWorkflowExecutionHistoryResponse, error)
 	}
+
+	// WorkflowAlreadyStartedError is returned by StartWorkflow when the workflow ID is used by a running execution,
+	// or by a closed one which the WorkflowIDReusePolicy does not allow to reuse. It wraps the
+	// WorkflowExecutionAlreadyStartedError returned by the server, which errors.As still finds.
+	WorkflowAlreadyStartedError struct {
+		execution WorkflowExecution
+		cause     *s.WorkflowExecutionAlreadyStartedError
+		client    *workflowClient
+	}
 )
 
+func (e *WorkflowAlreadyStartedError) Error() string {
+	return e.cause.Error()
+}
+
+// Unwrap returns the WorkflowExecutionAlreadyStartedError returned by the server.
+func (e *WorkflowAlreadyStartedError) Unwrap() error {
+	return e.cause
+}
+
+// RunID returns the run ID of the existing execution.
+func (e *WorkflowAlreadyStartedError) RunID() string {
+	return e.execution.RunID
+}
+
+// WorkflowExecution returns the existing execution, which can be passed to GetWorkflow to wait for its result.
+func (e *WorkflowAlreadyStartedError) WorkflowExecution() WorkflowExecution {
+	return e.execution
+}
+
+// StartTime describes the existing execution to return its start time. The server doesn't return the start time
+// with the error, so it is only described when asked for rather than on every conflict.
+func (e *WorkflowAlreadyStartedError) StartTime(ctx context.Context) (time.Time, error) {
+	response, err := e.client.DescribeWorkflowExecution(ctx, e.execution.ID, e.execution.RunID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, response.GetWorkflowExecutionInfo().GetStartTime()), nil
+}
+
+// GetWorkflowAlreadyStartedRunID returns the run ID of the execution that uses the workflow ID when StartWorkflow
+// failed with a WorkflowExecutionAlreadyStartedError, so that the execution can be waited for with GetWorkflow
+// without describing it. It returns false if err is not a WorkflowExecutionAlreadyStartedError.
+func GetWorkflowAlreadyStartedRunID(err error) (string, bool) {
+	var alreadyStartedErr *s.WorkflowExecutionAlreadyStartedError
+	if !errors.As(err, &alreadyStartedErr) {
+		return "", false
+	}
+	return alreadyStartedErr.GetRunId(), true
+}
+
 // StartWorkflow starts a workflow execution
 // The user can use this to start using a functor like.
 // Either by
@@ -153,11 +205,28 @@ func (wc *workflowClient) StartWorkflow(
 	workflowFunc interface{},
 	args ...interface{},
 ) (*WorkflowExecution, error) {
-	workflowID := options.ID
-	if len(workflowID) == 0 {
-		workflowID = uuid.NewRandom().String()
+	execution, err := wc.startWorkflow(ctx, options, workflowFunc, args...)
+	if err != nil {
+		if alreadyStartedErr, ok := err.(*s.WorkflowExecutionAlreadyStartedError); ok {
+			return nil, &WorkflowAlreadyStartedError{
+				execution: WorkflowExecution{ID: execution.ID, RunID: alreadyStartedErr.GetRunId()},
+				cause:     alreadyStartedErr,
+				client:    wc,
+			}
+		}
+		return nil, err
 	}
+	return execution, nil
+}
 
+// startWorkflow starts a workflow execution. Once the workflow ID is resolved the returned execution is non-nil even
+// when err is not, so callers can tell which workflow ID was used when options.ID is empty.
+func (wc *workflowClient) startWorkflow(
+	ctx context.Context,
+	options StartWorkflowOptions,
+	workflowFunc interface{},
+	args ...interface{},
+) (*WorkflowExecution, error) {
 	if options.TaskList == "" {
 		return nil, errors.New("missing TaskList")
 	}
@@ -171,8 +240,11 @@ func (wc *workflowClient) StartWorkflow(
 	if decisionTaskTimeout < 0 {
 		return nil, errors.New("negative DecisionTaskStartToCloseTimeout provided")
 	}
-	if decisionTaskTimeout == 0 {
-		decisionTaskTimeout = defaultDecisionTaskTimeoutInSecs
+
+	retryPolicy := convertRetryPolicy(options.RetryPolicy)
+	defaultRetryExpiration(retryPolicy, executionTimeout)
+	if err := validateRetryPolicy(retryPolicy); err != nil {
+		return nil, err
 	}
 
 	// Validate type and its arguments.
@@ -180,6 +252,24 @@ func (wc *workflowClient) StartWorkflow(
 	if err != nil {
 		return nil, err
 	}
+	if decisionTaskTimeout == 0 {
+		decisionTaskTimeout = wc.getDefaultDecisionTaskTimeout(workflowType.Name)
+	}
+
+	workflowID, err := wc.getWorkflowID(options.ID, workflowType.Name, input)
+	if err != nil {
+		return nil, err
+	}
+
+	requestID := options.RequestID
+	if requestID == "" {
+		requestID = uuid.New()
+	}
+	if wc.startWorkflowDedup != nil {
+		if runID, ok := wc.startWorkflowDedup.get(workflowID, requestID); ok {
+			return &WorkflowExecution{ID: workflowID, RunID: runID}, nil
+		}
+	}
 
 	memo, err := getWorkflowMemo(options.Memo, wc.dataConverter)
 	if err != nil {
@@ -217,7 +307,7 @@ func (wc *workflowClient) StartWorkflow(
 	// run propagators to extract information about tracing and other stuff, store in headers field
 	startRequest := &s.StartWorkflowExecutionRequest{
 		Domain:                              common.StringPtr(wc.domain),
-		RequestId:                           common.StringPtr(uuid.New()),
+		RequestId:                           common.StringPtr(requestID),
 		WorkflowId:                          common.StringPtr(workflowID),
 		WorkflowType:                        workflowTypePtr(*workflowType),
 		TaskList:                            common.TaskListPtr(s.TaskList{Name: common.StringPtr(options.TaskList)}),
@@ -226,7 +316,7 @@ func (wc *workflowClient) StartWorkflow(
 		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(decisionTaskTimeout),
 		Identity:                            common.StringPtr(wc.identity),
 		WorkflowIdReusePolicy:               options.WorkflowIDReusePolicy.toThriftPtr(),
-		RetryPolicy:                         convertRetryPolicy(options.RetryPolicy),
+		RetryPolicy:                         retryPolicy,
 		CronSchedule:                        common.StringPtr(options.CronSchedule),
 		Memo:                                memo,
 		SearchAttributes:                    searchAttr,
@@ -249,7 +339,17 @@ func (wc *workflowClient) StartWorkflow(
 		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
 
 	if err != nil {
-		return nil, err
+		if wc.startWorkflowDedup != nil {
+			runID, checkOpen, ok := wc.startWorkflowDedup.getExistingRun(err, requestID, options.WorkflowIDReusePolicy)
+			if ok && (!checkOpen || wc.isRunOpen(ctx, workflowID, runID)) {
+				wc.startWorkflowDedup.put(workflowID, requestID, runID)
+				return &WorkflowExecution{ID: workflowID, RunID: runID}, nil
+			}
+		}
+		return &WorkflowExecution{ID: workflowID}, err
+	}
+	if wc.startWorkflowDedup != nil {
+		wc.startWorkflowDedup.put(workflowID, requestID, response.GetRunId())
 	}
 
 	if wc.metricsScope != nil {
@@ -278,13 +378,12 @@ func (wc *workflowClient) ExecuteWorkflow(ctx context.Context, options StartWork
 	// start the workflow execution
 	var runID string
 	var workflowID string
-	executionInfo, err := wc.StartWorkflow(ctx, options, workflow, args...)
+	executionInfo, err := wc.startWorkflow(ctx, options, workflow, args...)
 	if err != nil {
 		if alreadyStartedErr, ok := err.(*s.WorkflowExecutionAlreadyStartedError); ok {
 			runID = alreadyStartedErr.GetRunId()
-			// Assumption is that AlreadyStarted is never returned when options.ID is empty as UUID generated by
-			// StartWorkflow is not going to collide ever.
-			workflowID = options.ID
+			// options.ID may be empty, use the workflow ID resolved by startWorkflow.
+			workflowID = executionInfo.ID
 		} else {
 			return nil, err
 		}
@@ -330,11 +429,72 @@ func (wc *workflowClient) GetWorkflow(ctx context.Context, workflowID string, ru
 
 // SignalWorkflow signals a workflow in execution.
 func (wc *workflowClient) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error {
+	return wc.signalWorkflowWithRequestID(ctx, workflowID, runID, signalName, arg, getRequestID(ctx))
+}
+
+func (wc *workflowClient) signalWorkflowWithRequestID(ctx context.Context, workflowID, runID, signalName string, arg interface{}, requestID string) error {
 	input, err := encodeArg(wc.dataConverter, arg)
 	if err != nil {
 		return err
 	}
-	return signalWorkflow(ctx, wc.workflowService, wc.identity, wc.domain, workflowID, runID, signalName, input, wc.featureFlags)
+	return signalWorkflow(ctx, wc.workflowService, wc.identity, wc.domain, workflowID, runID, signalName, input, requestID, wc.featureFlags)
+}
+
+// SignalWorkflows sends the signals of the requests, in order per workflow and in parallel across workflows.
+func (wc *workflowClient) SignalWorkflows(ctx context.Context, requests []SignalRequest, concurrency int) []error {
+	// group the indexes of the requests by workflow ID, every group is signaled by a single goroutine
+	var groups [][]int
+	groupByWorkflowID := make(map[string]int)
+	for i, request := range requests {
+		group, ok := groupByWorkflowID[request.WorkflowID]
+		if !ok {
+			group = len(groups)
+			groupByWorkflowID[request.WorkflowID] = group
+			groups = append(groups, nil)
+		}
+		groups[group] = append(groups[group], i)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, len(requests))
+	groupCh := make(chan []int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(groups); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range groupCh {
+				wc.signalInOrder(ctx, requests, group, errs)
+			}
+		}()
+	}
+	for _, group := range groups {
+		groupCh <- group
+	}
+	close(groupCh)
+	wg.Wait()
+	return errs
+}
+
+// signalInOrder sends the signals of the requests at the indexes one at a time, and stops at the first failure.
+func (wc *workflowClient) signalInOrder(ctx context.Context, requests []SignalRequest, indexes []int, errs []error) {
+	var failed error
+	for _, i := range indexes {
+		request := requests[i]
+		if failed != nil {
+			errs[i] = fmt.Errorf("signal %v to workflow %v not sent after a previous signal failed: %w",
+				request.SignalName, request.WorkflowID, failed)
+			continue
+		}
+		requestID := request.RequestID
+		if requestID == "" {
+			requestID = uuid.New()
+		}
+		errs[i] = wc.signalWorkflowWithRequestID(ctx, request.WorkflowID, request.RunID, request.SignalName, request.Arg, requestID)
+		failed = errs[i]
+	}
 }
 
 // SignalWithStartWorkflow sends a signal to a running workflow.
@@ -347,10 +507,6 @@ func (wc *workflowClient) SignalWithStartWorkflow(ctx context.Context, workflowI
 		return nil, err
 	}
 
-	if workflowID == "" {
-		workflowID = uuid.NewRandom().String()
-	}
-
 	if options.TaskList == "" {
 		return nil, errors.New("missing TaskList")
 	}
@@ -364,8 +520,11 @@ func (wc *workflowClient) SignalWithStartWorkflow(ctx context.Context, workflowI
 	if decisionTaskTimeout < 0 {
 		return nil, errors.New("negative DecisionTaskStartToCloseTimeout provided")
 	}
-	if decisionTaskTimeout == 0 {
-		decisionTaskTimeout = defaultDecisionTaskTimeoutInSecs
+
+	retryPolicy := convertRetryPolicy(options.RetryPolicy)
+	defaultRetryExpiration(retryPolicy, executionTimeout)
+	if err := validateRetryPolicy(retryPolicy); err != nil {
+		return nil, err
 	}
 
 	// Validate type and its arguments.
@@ -373,6 +532,14 @@ func (wc *workflowClient) SignalWithStartWorkflow(ctx context.Context, workflowI
 	if err != nil {
 		return nil, err
 	}
+	if decisionTaskTimeout == 0 {
+		decisionTaskTimeout = wc.getDefaultDecisionTaskTimeout(workflowType.Name)
+	}
+
+	workflowID, err = wc.getWorkflowID(workflowID, workflowType.Name, input)
+	if err != nil {
+		return nil, err
+	}
 
 	memo, err := getWorkflowMemo(options.Memo, wc.dataConverter)
 	if err != nil {
@@ -401,9 +568,14 @@ func (wc *workflowClient) SignalWithStartWorkflow(ctx context.Context, workflowI
 	// get workflow headers from the context
 	header := wc.getWorkflowHeader(ctx)
 
+	requestID := options.RequestID
+	if requestID == "" {
+		requestID = uuid.New()
+	}
+
 	signalWithStartRequest := &s.SignalWithStartWorkflowExecutionRequest{
 		Domain:                              common.StringPtr(wc.domain),
-		RequestId:                           common.StringPtr(uuid.New()),
+		RequestId:                           common.StringPtr(requestID),
 		WorkflowId:                          common.StringPtr(workflowID),
 		WorkflowType:                        workflowTypePtr(*workflowType),
 		TaskList:                            common.TaskListPtr(s.TaskList{Name: common.StringPtr(options.TaskList)}),
@@ -413,7 +585,7 @@ func (wc *workflowClient) SignalWithStartWorkflow(ctx context.Context, workflowI
 		SignalName:                          common.StringPtr(signalName),
 		SignalInput:                         signalInput,
 		Identity:                            common.StringPtr(wc.identity),
-		RetryPolicy:                         convertRetryPolicy(options.RetryPolicy),
+		RetryPolicy:                         retryPolicy,
 		CronSchedule:                        common.StringPtr(options.CronSchedule),
 		Memo:                                memo,
 		SearchAttributes:                    searchAttr,
@@ -451,6 +623,18 @@ func (wc *workflowClient) SignalWithStartWorkflow(ctx context.Context, workflowI
 	return executionInfo, nil
 }
 
+// getRequestID returns the request ID of a call made with ctx, see WithRequestID and WithRequestIDOutput.
+func getRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	if requestID == "" {
+		requestID = uuid.New()
+	}
+	if output, _ := ctx.Value(requestIDOutputContextKey).(*string); output != nil {
+		*output = requestID
+	}
+	return requestID
+}
+
 // CancelWorkflow cancels a workflow in execution.  It allows workflow to properly clean up and gracefully close.
 // workflowID is required, other parameters are optional.
 // If runID is omit, it will terminate currently running workflow (if there is one) based on the workflowID.
@@ -461,7 +645,8 @@ func (wc *workflowClient) CancelWorkflow(ctx context.Context, workflowID string,
 			WorkflowId: common.StringPtr(workflowID),
 			RunId:      getRunID(runID),
 		},
-		Identity: common.StringPtr(wc.identity),
+		Identity:  common.StringPtr(wc.identity),
+		RequestId: common.StringPtr(getRequestID(ctx)),
 	}
 
 	return backoff.Retry(ctx,
@@ -505,8 +690,28 @@ func (wc *workflowClient) GetWorkflowHistory(
 	isLongPoll bool,
 	filterType s.HistoryEventFilterType,
 ) HistoryEventIterator {
+	return wc.GetWorkflowHistoryWithOptions(ctx, &GetWorkflowHistoryWithOptionsRequest{
+		WorkflowID: workflowID,
+		RunID:      runID,
+		IsLongPoll: isLongPoll,
+		FilterType: filterType,
+	})
+}
 
+// GetWorkflowHistoryWithOptions return a channel which contains the history events of a given workflow, reading
+// archived history according to request.ArchivalPolicy.
+func (wc *workflowClient) GetWorkflowHistoryWithOptions(ctx context.Context, request *GetWorkflowHistoryWithOptionsRequest) HistoryEventIterator {
 	domain := wc.domain
+	workflowID := request.WorkflowID
+	runID := request.RunID
+	filterType := request.FilterType
+	archivalPolicy := request.ArchivalPolicy
+	// archived history can't be long polled, archived workflows are closed anyway
+	isLongPoll := request.IsLongPoll && archivalPolicy != HistoryArchivalPolicyRequire
+	// readArchival is set once the history is read from archival, the page tokens of archived history are only
+	// valid for archival reads.
+	readArchival := archivalPolicy == HistoryArchivalPolicyRequire || (!isLongPoll && archivalPolicy == HistoryArchivalPolicyAllow)
+
 	paginate := func(nextToken []byte) (*s.GetWorkflowExecutionHistoryResponse, error) {
 		request := &s.GetWorkflowExecutionHistoryRequest{
 			Domain: common.StringPtr(domain),
@@ -517,7 +722,7 @@ func (wc *workflowClient) GetWorkflowHistory(
 			WaitForNewEvent:        common.BoolPtr(isLongPoll),
 			HistoryEventFilterType: &filterType,
 			NextPageToken:          nextToken,
-			SkipArchival:           common.BoolPtr(isLongPoll),
+			SkipArchival:           common.BoolPtr(!readArchival),
 		}
 
 		var response *s.GetWorkflowExecutionHistoryResponse
@@ -561,8 +766,19 @@ func (wc *workflowClient) GetWorkflowHistory(
 			)
 
 			if err != nil {
+				if _, ok := err.(*s.EntityNotExistsError); ok && !readArchival && archivalPolicy == HistoryArchivalPolicyAllow && len(request.NextPageToken) == 0 {
+					// the history may have been deleted after being archived, read it from archival instead
+					readArchival = true
+					isLongPoll = false
+					request.WaitForNewEvent = common.BoolPtr(false)
+					request.SkipArchival = common.BoolPtr(false)
+					continue Loop
+				}
 				return nil, err
 			}
+			if archivalPolicy == HistoryArchivalPolicyRequire && !response.GetArchived() {
+				return nil, &s.BadRequestError{Message: fmt.Sprintf("history of workflow %v run %v is not archived", workflowID, runID)}
+			}
 			if isLongPoll && len(response.History.Events) == 0 && len(response.NextPageToken) != 0 {
 				if isFinalLongPoll {
 					// essentially a deadline exceeded, the last attempt did not get a result.
@@ -797,6 +1013,63 @@ func (wc *workflowClient) CountWorkflow(ctx context.Context, request *s.CountWor
 	return response, nil
 }
 
+// CountWorkflowWithOptions implementation
+func (wc *workflowClient) CountWorkflowWithOptions(ctx context.Context, request *CountWorkflowWithOptionsRequest) (*CountWorkflowWithOptionsResponse, error) {
+	groupByValues := request.GroupByValues
+	if request.GroupBy != "" && len(groupByValues) == 0 {
+		if request.GroupBy != keyCloseStatus {
+			return nil, &s.BadRequestError{Message: fmt.Sprintf("GroupByValues are required to group by %v", request.GroupBy)}
+		}
+		groupByValues = []interface{}{
+			WorkflowStatusCompleted,
+			WorkflowStatusFailed,
+			WorkflowStatusCanceled,
+			WorkflowStatusTerminated,
+			WorkflowStatusContinuedAsNew,
+			WorkflowStatusTimedOut,
+		}
+	}
+
+	count := func(query string) (int64, error) {
+		response, err := wc.CountWorkflow(ctx, &s.CountWorkflowExecutionsRequest{
+			Domain: common.StringPtr(wc.domain),
+			Query:  common.StringPtr(query),
+		})
+		if err != nil {
+			return 0, err
+		}
+		return response.GetCount(), nil
+	}
+
+	total, err := count(request.Query)
+	if err != nil {
+		return nil, err
+	}
+	response := &CountWorkflowWithOptionsResponse{Count: total}
+	if request.GroupBy == "" {
+		return response, nil
+	}
+
+	// the server can't group the count, so each group is counted with its own query
+	response.Groups = make(map[string]int64, len(groupByValues))
+	for _, value := range groupByValues {
+		predicate, err := NewVisibilityQueryBuilder().Eq(request.GroupBy, value).Build()
+		if err != nil {
+			return nil, &s.BadRequestError{Message: err.Error()}
+		}
+		query := predicate
+		if request.Query != "" {
+			query = "(" + request.Query + ") and " + predicate
+		}
+		groupCount, err := count(query)
+		if err != nil {
+			return nil, err
+		}
+		response.Groups[fmt.Sprint(value)] = groupCount
+	}
+	return response, nil
+}
+
 // ResetWorkflow implementation
 func (wc *workflowClient) ResetWorkflow(ctx context.Context, request *s.ResetWorkflowExecutionRequest) (*s.ResetWorkflowExecutionResponse, error) {
 	if len(request.GetDomain()) == 0 {
@@ -817,6 +1090,135 @@ func (wc *workflowClient) ResetWorkflow(ctx context.Context, request *s.ResetWor
 	return response, nil
 }
 
+// ResetWorkflowExecution implementation
+func (wc *workflowClient) ResetWorkflowExecution(ctx context.Context, options ResetOptions) (*WorkflowExecution, error) {
+	if options.WorkflowID == "" {
+		return nil, errors.New("missing WorkflowID")
+	}
+	if options.Reason == "" {
+		return nil, errors.New("missing Reason")
+	}
+
+	runID := options.RunID
+	var info *s.WorkflowExecutionInfo
+	if runID == "" || options.ResetType == ResetTypeBadBinary {
+		// pin the run, so that the reset point and the reset request refer to the same run
+		response, err := wc.DescribeWorkflowExecution(ctx, options.WorkflowID, runID)
+		if err != nil {
+			return nil, err
+		}
+		info = response.GetWorkflowExecutionInfo()
+		runID = info.GetExecution().GetRunId()
+	}
+
+	var decisionFinishEventID int64
+	var err error
+	switch options.ResetType {
+	case ResetTypeLastDecisionCompleted, ResetTypeFirstDecisionCompleted:
+		decisionFinishEventID, err = wc.getDecisionCompletedEventID(ctx, options.WorkflowID, runID, options.ResetType == ResetTypeFirstDecisionCompleted)
+	case ResetTypeEventID:
+		if options.EventID <= 0 {
+			return nil, errors.New("missing or invalid EventID")
+		}
+		decisionFinishEventID = options.EventID
+	case ResetTypeBadBinary:
+		// the auto-reset point may belong to a previous run of the workflow, e.g. before a continue as new
+		runID, decisionFinishEventID, err = getBadBinaryResetPoint(info, options.BadBinaryChecksum)
+	default:
+		return nil, fmt.Errorf("unknown ResetType %v", options.ResetType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := wc.ResetWorkflow(ctx, &s.ResetWorkflowExecutionRequest{
+		Domain: common.StringPtr(wc.domain),
+		WorkflowExecution: &s.WorkflowExecution{
+			WorkflowId: common.StringPtr(options.WorkflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		Reason:                common.StringPtr(options.Reason),
+		DecisionFinishEventId: common.Int64Ptr(decisionFinishEventID),
+		RequestId:             common.StringPtr(uuid.New()),
+		SkipSignalReapply:     common.BoolPtr(options.ReapplyType == ResetReapplyTypeNone),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &WorkflowExecution{ID: options.WorkflowID, RunID: response.GetRunId()}, nil
+}
+
+// getDecisionCompletedEventID returns the ID of the first or last DecisionTaskCompleted event of a run.
+func (wc *workflowClient) getDecisionCompletedEventID(ctx context.Context, workflowID, runID string, first bool) (int64, error) {
+	var eventID int64
+	iter := wc.GetWorkflowHistory(ctx, workflowID, runID, false, s.HistoryEventFilterTypeAllEvent)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return 0, err
+		}
+		if event.GetEventType() != s.EventTypeDecisionTaskCompleted {
+			continue
+		}
+		eventID = event.GetEventId()
+		if first {
+			break
+		}
+	}
+	if eventID == 0 {
+		return 0, fmt.Errorf("workflow %v run %v has no completed decision to reset to", workflowID, runID)
+	}
+	return eventID, nil
+}
+
+// getBadBinaryResetPoint returns the run and the ID of the first decision completed by the binary from the auto-reset
+// points of a workflow execution.
+func getBadBinaryResetPoint(info *s.WorkflowExecutionInfo, binaryChecksum string) (string, int64, error) {
+	if binaryChecksum == "" {
+		return "", 0, errors.New("missing BadBinaryChecksum")
+	}
+	for _, point := range getResetPoints(info) {
+		if point.BinaryChecksum == binaryChecksum && point.Resettable {
+			runID := point.RunID
+			if runID == "" {
+				runID = info.GetExecution().GetRunId()
+			}
+			return runID, point.FirstDecisionCompletedID, nil
+		}
+	}
+	return "", 0, fmt.Errorf("no resettable auto-reset point for binary checksum %v", binaryChecksum)
+}
+
+// GetResetPoints returns the auto-reset points of a workflow execution described by DescribeWorkflowExecution,
+// oldest first.
+func GetResetPoints(response *s.DescribeWorkflowExecutionResponse) []ResetPoint {
+	return getResetPoints(response.GetWorkflowExecutionInfo())
+}
+
+func getResetPoints(info *s.WorkflowExecutionInfo) []ResetPoint {
+	points := info.GetAutoResetPoints().GetPoints()
+	if len(points) == 0 {
+		return nil
+	}
+	resetPoints := make([]ResetPoint, 0, len(points))
+	for _, point := range points {
+		resetPoint := ResetPoint{
+			BinaryChecksum:           point.GetBinaryChecksum(),
+			RunID:                    point.GetRunId(),
+			FirstDecisionCompletedID: point.GetFirstDecisionCompletedId(),
+			Resettable:               point.GetResettable(),
+		}
+		if point.CreatedTimeNano != nil {
+			resetPoint.CreatedTime = time.Unix(0, point.GetCreatedTimeNano())
+		}
+		if point.GetExpiringTimeNano() > 0 {
+			resetPoint.ExpiringTime = time.Unix(0, point.GetExpiringTimeNano())
+		}
+		resetPoints = append(resetPoints, resetPoint)
+	}
+	return resetPoints
+}
+
 // GetSearchAttributes implementation
 func (wc *workflowClient) GetSearchAttributes(ctx context.Context) (*s.GetSearchAttributesResponse, error) {
 	var response *s.GetSearchAttributesResponse
@@ -834,6 +1236,11 @@ func (wc *workflowClient) GetSearchAttributes(ctx context.Context) (*s.GetSearch
 	return response, nil
 }
 
+// HealthCheck implementation
+func (wc *workflowClient) HealthCheck(ctx context.Context) error {
+	return healthCheck(ctx, wc.workflowService, wc.featureFlags)
+}
+
 // DescribeWorkflowExecution returns information about the specified workflow execution.
 // The errors it can return:
 //  - BadRequestError
@@ -888,6 +1295,80 @@ func (wc *workflowClient) QueryWorkflow(ctx context.Context, workflowID string,
 	return result.QueryResult, nil
 }
 
+// QueryWorkerMetadata queries a given workflow execution for the metadata of the worker answering the query.
+func (wc *workflowClient) QueryWorkerMetadata(ctx context.Context, workflowID string, runID string) (*WorkerMetadata, error) {
+	value, err := wc.QueryWorkflow(ctx, workflowID, runID, QueryTypeWorkerMetadata)
+	if err != nil {
+		return nil, err
+	}
+	var metadata WorkerMetadata
+	if err := value.Get(&metadata); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// CountWorkflowWithOptionsRequest is the request to CountWorkflowWithOptions
+type CountWorkflowWithOptionsRequest struct {
+	// Query is an optional advanced visibility query selecting the workflow executions to count, for example built
+	// with the visibility package. All the executions of the domain are counted if empty.
+	// The query must not have an order by clause.
+	Query string
+
+	// GroupBy is an optional search attribute to group the count by, for example "WorkflowType" or "CloseStatus".
+	GroupBy string
+
+	// GroupByValues are the values of GroupBy to count the executions of. They are required unless GroupBy is
+	// "CloseStatus", which defaults to all the close statuses.
+	// See visibility.QueryBuilder for the supported value types.
+	GroupByValues []interface{}
+}
+
+// CountWorkflowWithOptionsResponse is the response to CountWorkflowWithOptions
+type CountWorkflowWithOptionsResponse struct {
+	// Count is the number of workflow executions matching the query.
+	Count int64
+
+	// Groups maps each value of GroupBy, formatted with fmt.Sprint, to the number of workflow executions matching
+	// the query with that value. It is nil without GroupBy.
+	Groups map[string]int64
+}
+
+// GetWorkflowHistoryWithOptionsRequest is the request to GetWorkflowHistoryWithOptions
+type GetWorkflowHistoryWithOptionsRequest struct {
+	// WorkflowID is a required field indicating the workflow whose history is read.
+	WorkflowID string
+
+	// RunID is an optional field used to identify a specific run of the workflow.
+	// If RunID is not provided the latest run will be used.
+	RunID string
+
+	// IsLongPoll makes the iterator wait for new events until the workflow is closed. It is ignored with
+	// HistoryArchivalPolicyRequire.
+	IsLongPoll bool
+
+	// FilterType selects all history events or just the close event.
+	FilterType s.HistoryEventFilterType
+
+	// ArchivalPolicy is an optional field controlling whether archived history may be read.
+	// Default: HistoryArchivalPolicyAllow.
+	ArchivalPolicy HistoryArchivalPolicy
+}
+
+// HistoryArchivalPolicy controls whether GetWorkflowHistoryWithOptions reads archived history.
+type HistoryArchivalPolicy int
+
+const (
+	// HistoryArchivalPolicyAllow reads the history from archival when it is no longer in the primary storage
+	// of the server, for example because the retention period of the closed workflow expired.
+	HistoryArchivalPolicyAllow HistoryArchivalPolicy = iota
+	// HistoryArchivalPolicyForbid never reads archived history, EntityNotExistsError is returned instead.
+	HistoryArchivalPolicyForbid
+	// HistoryArchivalPolicyRequire only reads archived history, BadRequestError is returned if the server
+	// serves the history from its primary storage.
+	HistoryArchivalPolicyRequire
+)
+
 // QueryWorkflowWithOptionsRequest is the request to QueryWorkflowWithOptions
 type QueryWorkflowWithOptionsRequest struct {
 	// WorkflowID is a required field indicating the workflow which should be queried.
@@ -1105,6 +1586,36 @@ func (dc *domainClient) Update(ctx context.Context, request *s.UpdateDomainReque
 		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
 }
 
+// getDefaultDecisionTaskTimeout returns the DecisionTaskStartToCloseTimeout, in seconds, of a workflow started
+// without one: the one it was registered with, the default one otherwise.
+func (wc *workflowClient) getDefaultDecisionTaskTimeout(workflowType string) int32 {
+	if wc.registry != nil {
+		if timeout, ok := wc.registry.getWorkflowDecisionTaskTimeout(workflowType); ok {
+			return common.Int32Ceil(timeout.Seconds())
+		}
+	}
+	return defaultDecisionTaskTimeoutInSecs
+}
+
+// getWorkflowID returns workflowID if it is set, otherwise it generates one with the configured WorkflowIDGenerator,
+// defaulting to a random uuid.
+func (wc *workflowClient) getWorkflowID(workflowID, workflowType string, input []byte) (string, error) {
+	if workflowID != "" {
+		return workflowID, nil
+	}
+	if wc.workflowIDGenerator == nil {
+		return uuid.NewRandom().String(), nil
+	}
+	workflowID, err := wc.workflowIDGenerator.GenerateWorkflowID(workflowType, input)
+	if err != nil {
+		return "", err
+	}
+	if workflowID == "" {
+		return "", errors.New("WorkflowIDGenerator generated an empty workflow ID")
+	}
+	return workflowID, nil
+}
+
 func getRunID(runID string) *string {
 	if runID == "" {
 		// Cadence Server will pick current runID if provided empty.
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -38,6 +39,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/cadence/internal/common/metrics"
 )
@@ -344,6 +346,100 @@ func (s *historyEventIteratorSuite) TestIterator_StopsTryingNearTimeout() {
 	s.Contains(err.Error(), "waiting for the workflow to finish", "should be descriptive of what happened")
 }
 
+func (s *historyEventIteratorSuite) TestIterator_ArchivalFallback() {
+	filterType := shared.HistoryEventFilterTypeAllEvent
+	request1 := getGetWorkflowExecutionHistoryRequest(filterType)
+	archivalRequest1 := getGetWorkflowExecutionHistoryRequest(filterType)
+	archivalRequest1.WaitForNewEvent = common.BoolPtr(false)
+	archivalRequest1.SkipArchival = common.BoolPtr(false)
+	response1 := &shared.GetWorkflowExecutionHistoryResponse{
+		History:       &shared.History{Events: []*shared.HistoryEvent{{}}},
+		NextPageToken: []byte{1, 2, 3},
+		Archived:      common.BoolPtr(true),
+	}
+	archivalRequest2 := getGetWorkflowExecutionHistoryRequest(filterType)
+	archivalRequest2.WaitForNewEvent = common.BoolPtr(false)
+	archivalRequest2.SkipArchival = common.BoolPtr(false)
+	archivalRequest2.NextPageToken = response1.NextPageToken
+	response2 := &shared.GetWorkflowExecutionHistoryResponse{
+		RawHistory: []*shared.DataBlob{serializeEvents([]*shared.HistoryEvent{{}})},
+		Archived:   common.BoolPtr(true),
+	}
+
+	gomock.InOrder(
+		s.workflowServiceClient.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), request1, gomock.Any()).Return(nil, &shared.EntityNotExistsError{}).Times(1),
+		s.workflowServiceClient.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), archivalRequest1, gomock.Any()).Return(response1, nil).Times(1),
+		s.workflowServiceClient.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), archivalRequest2, gomock.Any()).Return(response2, nil).Times(1),
+	)
+
+	events := []*shared.HistoryEvent{}
+	iter := s.wfClient.GetWorkflowHistory(context.Background(), workflowID, runID, true, filterType)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		s.Nil(err)
+		events = append(events, event)
+	}
+	s.Equal(2, len(events))
+}
+
+func (s *historyEventIteratorSuite) TestIterator_ArchivalForbidden() {
+	filterType := shared.HistoryEventFilterTypeAllEvent
+	request := getGetWorkflowExecutionHistoryRequest(filterType)
+	request.WaitForNewEvent = common.BoolPtr(false)
+
+	s.workflowServiceClient.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), request, gomock.Any()).Return(nil, &shared.EntityNotExistsError{}).Times(1)
+
+	iter := s.wfClient.GetWorkflowHistoryWithOptions(context.Background(), &GetWorkflowHistoryWithOptionsRequest{
+		WorkflowID:     workflowID,
+		RunID:          runID,
+		FilterType:     filterType,
+		ArchivalPolicy: HistoryArchivalPolicyForbid,
+	})
+	s.True(iter.HasNext())
+	event, err := iter.Next()
+	s.Nil(event)
+	s.IsType(&shared.EntityNotExistsError{}, err)
+}
+
+func (s *historyEventIteratorSuite) TestIterator_ArchivalRequired() {
+	filterType := shared.HistoryEventFilterTypeAllEvent
+	request := getGetWorkflowExecutionHistoryRequest(filterType)
+	request.WaitForNewEvent = common.BoolPtr(false)
+	request.SkipArchival = common.BoolPtr(false)
+	response := &shared.GetWorkflowExecutionHistoryResponse{
+		History: &shared.History{Events: []*shared.HistoryEvent{{}}},
+	}
+
+	s.workflowServiceClient.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), request, gomock.Any()).Return(response, nil).Times(1)
+
+	// long poll is ignored as archived history can't be long polled
+	iter := s.wfClient.GetWorkflowHistoryWithOptions(context.Background(), &GetWorkflowHistoryWithOptionsRequest{
+		WorkflowID:     workflowID,
+		RunID:          runID,
+		IsLongPoll:     true,
+		FilterType:     filterType,
+		ArchivalPolicy: HistoryArchivalPolicyRequire,
+	})
+	s.True(iter.HasNext())
+	event, err := iter.Next()
+	s.Nil(event)
+	s.IsType(&shared.BadRequestError{}, err)
+
+	response.Archived = common.BoolPtr(true)
+	s.workflowServiceClient.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), request, gomock.Any()).Return(response, nil).Times(1)
+	iter = s.wfClient.GetWorkflowHistoryWithOptions(context.Background(), &GetWorkflowHistoryWithOptionsRequest{
+		WorkflowID:     workflowID,
+		RunID:          runID,
+		FilterType:     filterType,
+		ArchivalPolicy: HistoryArchivalPolicyRequire,
+	})
+	s.True(iter.HasNext())
+	event, err = iter.Next()
+	s.NotNil(event)
+	s.Nil(err)
+	s.False(iter.HasNext())
+}
+
 // minor helper type to allow faking deadlines between calls, as we cannot normally modify a context that way.
 type fakeDeadlineContext struct {
 	context.Context
@@ -1086,6 +1182,92 @@ func (s *workflowClientTestSuite) TestSignalWithStartWorkflow_Error() {
 	s.Equal(createResponse.GetRunId(), resp.RunID)
 }
 
+func (s *workflowClientTestSuite) TestSignalWithStartWorkflow_RequestID() {
+	options := StartWorkflowOptions{
+		ID:                              workflowID,
+		RequestID:                       "my request",
+		TaskList:                        tasklist,
+		ExecutionStartToCloseTimeout:    timeoutInSeconds,
+		DecisionTaskStartToCloseTimeout: timeoutInSeconds,
+	}
+	s.service.EXPECT().SignalWithStartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *shared.SignalWithStartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			s.Equal("my request", request.GetRequestId())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+		})
+	_, err := s.client.SignalWithStartWorkflow(context.Background(), workflowID, "my signal", nil, options, workflowType)
+	s.NoError(err)
+}
+
+func (s *workflowClientTestSuite) TestSignalWorkflow_RequestID() {
+	var requestIDs []string
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *shared.SignalWorkflowExecutionRequest, _ ...yarpc.CallOption) error {
+			requestIDs = append(requestIDs, request.GetRequestId())
+			return nil
+		}).Times(2)
+
+	var generated string
+	s.NoError(s.client.SignalWorkflow(WithRequestIDOutput(context.Background(), &generated), workflowID, runID, "my signal", nil))
+	s.NotEmpty(generated)
+
+	var provided string
+	ctx := WithRequestIDOutput(WithRequestID(context.Background(), "my request"), &provided)
+	s.NoError(s.client.SignalWorkflow(ctx, workflowID, runID, "my signal", nil))
+	s.Equal("my request", provided)
+	s.Equal([]string{generated, "my request"}, requestIDs)
+}
+
+func (s *workflowClientTestSuite) TestSignalWorkflows() {
+	var lock sync.Mutex
+	signals := make(map[string][]string)
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *shared.SignalWorkflowExecutionRequest, _ ...yarpc.CallOption) error {
+			if request.WorkflowExecution.GetWorkflowId() == "wid-2" {
+				return &shared.EntityNotExistsError{}
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			id := request.WorkflowExecution.GetWorkflowId()
+			signals[id] = append(signals[id], request.GetSignalName()+"/"+request.GetRequestId())
+			return nil
+		}).Times(5)
+
+	errs := s.client.SignalWorkflows(context.Background(), []SignalRequest{
+		{WorkflowID: "wid-1", SignalName: "s1", RequestID: "r1"},
+		{WorkflowID: "wid-2", SignalName: "s1", RequestID: "r2"},
+		{WorkflowID: "wid-1", SignalName: "s2", RequestID: "r3"},
+		{WorkflowID: "wid-3", SignalName: "s1", RequestID: "r4"},
+		{WorkflowID: "wid-2", SignalName: "s2", RequestID: "r5"},
+		{WorkflowID: "wid-1", SignalName: "s3", RequestID: "r6"},
+	}, 2)
+	s.Len(errs, 6)
+	for _, i := range []int{0, 2, 3, 5} {
+		s.NoError(errs[i])
+	}
+	s.IsType(&shared.EntityNotExistsError{}, errs[1])
+	// the signal following the failed one is not sent
+	var notExists *shared.EntityNotExistsError
+	s.True(errors.As(errs[4], &notExists))
+	s.Equal([]string{"s1/r1", "s2/r3", "s3/r6"}, signals["wid-1"])
+	s.Equal([]string{"s1/r4"}, signals["wid-3"])
+}
+
+func (s *workflowClientTestSuite) TestCancelWorkflow_RequestID() {
+	var requestIDs []string
+	s.service.EXPECT().RequestCancelWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *shared.RequestCancelWorkflowExecutionRequest, _ ...yarpc.CallOption) error {
+			requestIDs = append(requestIDs, request.GetRequestId())
+			return nil
+		}).Times(2)
+
+	s.NoError(s.client.CancelWorkflow(context.Background(), workflowID, runID))
+	s.NoError(s.client.CancelWorkflow(WithRequestID(context.Background(), "my request"), workflowID, runID))
+	s.Len(requestIDs, 2)
+	s.NotEmpty(requestIDs[0])
+	s.Equal("my request", requestIDs[1])
+}
+
 func (s *workflowClientTestSuite) TestStartWorkflow() {
 	client, ok := s.client.(*workflowClient)
 	s.True(ok)
@@ -1110,6 +1292,372 @@ func (s *workflowClientTestSuite) TestStartWorkflow() {
 	s.Equal(createResponse.GetRunId(), resp.RunID)
 }
 
+func (s *workflowClientTestSuite) TestStartWorkflow_RegisteredDecisionTaskTimeout() {
+	client, ok := s.client.(*workflowClient)
+	s.True(ok)
+	client.registry.RegisterWorkflowWithOptions(func(ctx Context) error { return nil }, RegisterWorkflowOptions{
+		Name:                            "heavy-workflow",
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	})
+
+	for _, tc := range []struct {
+		workflowType    string
+		decisionTimeout time.Duration
+		expected        int32
+	}{
+		{workflowType: "heavy-workflow", expected: 60},
+		{workflowType: "heavy-workflow", decisionTimeout: 5 * time.Second, expected: 5},
+		{workflowType: workflowType, expected: defaultDecisionTaskTimeoutInSecs},
+	} {
+		options := StartWorkflowOptions{
+			ID:                              workflowID,
+			TaskList:                        tasklist,
+			ExecutionStartToCloseTimeout:    timeoutInSeconds,
+			DecisionTaskStartToCloseTimeout: tc.decisionTimeout,
+		}
+		s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, request *shared.StartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+				s.Equal(tc.expected, request.GetTaskStartToCloseTimeoutSeconds())
+				return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+			})
+		_, err := client.StartWorkflow(context.Background(), options, tc.workflowType)
+		s.NoError(err)
+	}
+
+	s.service.EXPECT().SignalWithStartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, request *shared.SignalWithStartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			s.Equal(int32(60), request.GetTaskStartToCloseTimeoutSeconds())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+		})
+	_, err := client.SignalWithStartWorkflow(context.Background(), workflowID, "signal", nil, StartWorkflowOptions{
+		TaskList:                     tasklist,
+		ExecutionStartToCloseTimeout: timeoutInSeconds,
+	}, "heavy-workflow")
+	s.NoError(err)
+}
+
+func (s *workflowClientTestSuite) TestStartWorkflow_WithWorkflowIDGenerator() {
+	generator := NewArgsHashWorkflowIDGenerator("prefix-")
+	client := NewClient(s.service, domain, &ClientOptions{WorkflowIDGenerator: generator})
+	options := StartWorkflowOptions{
+		TaskList:                        tasklist,
+		ExecutionStartToCloseTimeout:    timeoutInSeconds,
+		DecisionTaskStartToCloseTimeout: timeoutInSeconds,
+	}
+	input, err := encodeArgs(getDefaultDataConverter(), []interface{}{"arg"})
+	s.NoError(err)
+	expectedID, err := generator.GenerateWorkflowID(workflowType, input)
+	s.NoError(err)
+
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, request *shared.StartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			s.Equal(expectedID, request.GetWorkflowId())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+		})
+	resp, err := client.StartWorkflow(context.Background(), options, workflowType, "arg")
+	s.NoError(err)
+	s.Equal(expectedID, resp.ID)
+
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil,
+		&shared.WorkflowExecutionAlreadyStartedError{RunId: common.StringPtr(runID)})
+	run, err := client.ExecuteWorkflow(context.Background(), options, workflowType, "arg")
+	s.NoError(err)
+	s.Equal(expectedID, run.GetID())
+	s.Equal(runID, run.GetRunID())
+
+	options.ID = workflowID
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil)
+	resp, err = client.StartWorkflow(context.Background(), options, workflowType, "arg")
+	s.NoError(err)
+	s.Equal(workflowID, resp.ID)
+}
+
+func (s *workflowClientTestSuite) TestStartWorkflow_RetryPolicy() {
+	options := StartWorkflowOptions{
+		ID:                           workflowID,
+		TaskList:                     tasklist,
+		ExecutionStartToCloseTimeout: time.Minute,
+		RetryPolicy:                  &RetryPolicy{InitialInterval: time.Second},
+	}
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, request *shared.StartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			// neither MaximumAttempts nor ExpirationInterval is set, so the retries expire with the workflow timeout
+			s.Equal(int32(60), request.RetryPolicy.GetExpirationIntervalInSeconds())
+			s.Equal(int32(100), request.RetryPolicy.GetMaximumIntervalInSeconds())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+		})
+	_, err := s.client.StartWorkflow(context.Background(), options, workflowType)
+	s.NoError(err)
+
+	for name, test := range map[string]struct {
+		policy  RetryPolicy
+		message string
+	}{
+		"maximum interval less than initial interval": {
+			policy:  RetryPolicy{InitialInterval: time.Minute, MaximumInterval: time.Second, MaximumAttempts: 3},
+			message: "MaximumIntervalInSeconds (1) cannot be less than InitialIntervalInSeconds (60)",
+		},
+		"empty non-retriable reason": {
+			policy:  RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3, NonRetriableErrorReasons: []string{"bad-input", ""}},
+			message: "empty reason in NonRetriableErrorReasons",
+		},
+		"negative maximum attempts": {
+			policy:  RetryPolicy{InitialInterval: time.Second, MaximumAttempts: -1},
+			message: "negative MaximumAttempts",
+		},
+	} {
+		s.Run(name, func() {
+			policy := test.policy
+			options.RetryPolicy = &policy
+			_, err := s.client.StartWorkflow(context.Background(), options, workflowType)
+			s.Error(err)
+			s.Contains(err.Error(), test.message)
+			_, err = s.client.SignalWithStartWorkflow(context.Background(), workflowID, "signal", nil, options, workflowType)
+			s.Error(err)
+			s.Contains(err.Error(), test.message)
+		})
+	}
+}
+
+func (s *workflowClientTestSuite) TestStartWorkflow_Dedup() {
+	client := NewClient(s.service, domain, &ClientOptions{
+		StartWorkflowDedup: &StartWorkflowDedupOptions{TTL: time.Minute},
+	})
+	options := StartWorkflowOptions{
+		ID:                           workflowID,
+		RequestID:                    "request-1",
+		TaskList:                     tasklist,
+		ExecutionStartToCloseTimeout: timeoutInSeconds,
+	}
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, request *shared.StartWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+			s.Equal("request-1", request.GetRequestId())
+			return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(runID)}, nil
+		})
+	resp, err := client.StartWorkflow(context.Background(), options, workflowType)
+	s.NoError(err)
+	s.Equal(runID, resp.RunID)
+
+	// the same start is answered from the cache
+	resp, err = client.StartWorkflow(context.Background(), options, workflowType)
+	s.NoError(err)
+	s.Equal(runID, resp.RunID)
+	run, err := client.ExecuteWorkflow(context.Background(), options, workflowType)
+	s.NoError(err)
+	s.Equal(runID, run.GetRunID())
+
+	// a start with another request ID is rejected by the run started by a different request
+	options.RequestID = "request-2"
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil,
+		&shared.WorkflowExecutionAlreadyStartedError{StartRequestId: common.StringPtr("request-1"), RunId: common.StringPtr(runID)})
+	_, err = client.StartWorkflow(context.Background(), options, workflowType)
+	s.IsType(&WorkflowAlreadyStartedError{}, err)
+
+	// but not by the run started by a previous attempt of the same request
+	options.RequestID = "request-3"
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil,
+		&shared.WorkflowExecutionAlreadyStartedError{StartRequestId: common.StringPtr("request-3"), RunId: common.StringPtr("run-3")})
+	resp, err = client.StartWorkflow(context.Background(), options, workflowType)
+	s.NoError(err)
+	s.Equal("run-3", resp.RunID)
+	resp, err = client.StartWorkflow(context.Background(), options, workflowType)
+	s.NoError(err)
+	s.Equal("run-3", resp.RunID)
+
+	// the workflow and request IDs are not mixed up when one of them contains the other's separator
+	dedupCache := client.(*workflowClient).startWorkflowDedup
+	dedupCache.put("workflow_a", "b", "run-4")
+	_, ok := dedupCache.get("workflow", "a_b")
+	s.False(ok)
+
+	client = NewClient(s.service, domain, &ClientOptions{
+		StartWorkflowDedup: &StartWorkflowDedupOptions{TTL: time.Minute, AttachToExistingRun: true},
+	})
+	expectAlreadyStarted := func() {
+		s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil,
+			&shared.WorkflowExecutionAlreadyStartedError{StartRequestId: common.StringPtr("request-1"), RunId: common.StringPtr(runID)})
+	}
+	expectDescribe := func(closeStatus *shared.WorkflowExecutionCloseStatus) {
+		s.service.EXPECT().DescribeWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+			func(_ context.Context, request *shared.DescribeWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.DescribeWorkflowExecutionResponse, error) {
+				s.Equal(runID, request.Execution.GetRunId())
+				return &shared.DescribeWorkflowExecutionResponse{
+					WorkflowExecutionInfo: &shared.WorkflowExecutionInfo{CloseStatus: closeStatus},
+				}, nil
+			})
+	}
+
+	// the existing run is attached to while it is open
+	options.RequestID = "request-4"
+	expectAlreadyStarted()
+	expectDescribe(nil)
+	resp, err = client.StartWorkflow(context.Background(), options, workflowType)
+	s.NoError(err)
+	s.Equal(workflowID, resp.ID)
+	s.Equal(runID, resp.RunID)
+
+	// a closed run the reuse policy rejected the start for is not
+	options.RequestID = "request-5"
+	options.WorkflowIDReusePolicy = WorkflowIDReusePolicyRejectDuplicate
+	expectAlreadyStarted()
+	expectDescribe(shared.WorkflowExecutionCloseStatusCompleted.Ptr())
+	_, err = client.StartWorkflow(context.Background(), options, workflowType)
+	s.IsType(&WorkflowAlreadyStartedError{}, err)
+
+	// WorkflowIDReusePolicyAllowDuplicate only rejects a start for an open run, which is not described
+	options.RequestID = "request-6"
+	options.WorkflowIDReusePolicy = WorkflowIDReusePolicyAllowDuplicate
+	expectAlreadyStarted()
+	resp, err = client.StartWorkflow(context.Background(), options, workflowType)
+	s.NoError(err)
+	s.Equal(runID, resp.RunID)
+}
+
+func (s *workflowClientTestSuite) TestStartWorkflow_AlreadyStarted() {
+	options := StartWorkflowOptions{
+		ID:                           workflowID,
+		TaskList:                     tasklist,
+		ExecutionStartToCloseTimeout: timeoutInSeconds,
+	}
+	cause := &shared.WorkflowExecutionAlreadyStartedError{Message: common.StringPtr("already started"), RunId: common.StringPtr(runID)}
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, cause)
+
+	// the run ID is read from the error of the server without describing the execution
+	resp, err := s.client.StartWorkflow(context.Background(), options, workflowType)
+	s.Nil(resp)
+	alreadyStartedErr, ok := err.(*WorkflowAlreadyStartedError)
+	s.True(ok)
+	s.Equal(runID, alreadyStartedErr.RunID())
+	s.Equal(WorkflowExecution{ID: workflowID, RunID: runID}, alreadyStartedErr.WorkflowExecution())
+	s.Equal(cause.Error(), err.Error())
+	var thriftErr *shared.WorkflowExecutionAlreadyStartedError
+	s.True(errors.As(err, &thriftErr))
+	s.Equal(cause, thriftErr)
+
+	// the start time is only described when asked for
+	startTime := time.Unix(1600000000, 0)
+	s.service.EXPECT().DescribeWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+		func(_ context.Context, request *shared.DescribeWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.DescribeWorkflowExecutionResponse, error) {
+			s.Equal(workflowID, request.Execution.GetWorkflowId())
+			s.Equal(runID, request.Execution.GetRunId())
+			return &shared.DescribeWorkflowExecutionResponse{
+				WorkflowExecutionInfo: &shared.WorkflowExecutionInfo{StartTime: common.Int64Ptr(startTime.UnixNano())},
+			}, nil
+		})
+	existingStartTime, err2 := alreadyStartedErr.StartTime(context.Background())
+	s.NoError(err2)
+	s.True(startTime.Equal(existingStartTime))
+
+	existingRunID, ok := GetWorkflowAlreadyStartedRunID(err)
+	s.True(ok)
+	s.Equal(runID, existingRunID)
+	existingRunID, ok = GetWorkflowAlreadyStartedRunID(fmt.Errorf("start failed: %w", err))
+	s.True(ok)
+	s.Equal(runID, existingRunID)
+	_, ok = GetWorkflowAlreadyStartedRunID(&shared.BadRequestError{})
+	s.False(ok)
+}
+
+func (s *workflowClientTestSuite) TestResetWorkflowExecution() {
+	events := []*shared.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &shared.WorkflowExecutionStartedEventAttributes{}),
+		createTestEventDecisionTaskScheduled(2, &shared.DecisionTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &shared.DecisionTaskCompletedEventAttributes{}),
+		createTestEventWorkflowExecutionSignaled(5, "signal"),
+		createTestEventDecisionTaskScheduled(6, &shared.DecisionTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskStarted(7),
+		createTestEventDecisionTaskCompleted(8, &shared.DecisionTaskCompletedEventAttributes{}),
+	}
+	expectHistory := func() {
+		s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+			func(_ context.Context, request *shared.GetWorkflowExecutionHistoryRequest, _ ...yarpc.CallOption) (*shared.GetWorkflowExecutionHistoryResponse, error) {
+				s.Equal(runID, request.Execution.GetRunId())
+				return &shared.GetWorkflowExecutionHistoryResponse{History: &shared.History{Events: events}}, nil
+			})
+	}
+	expectDescribe := func(points ...*shared.ResetPointInfo) {
+		s.service.EXPECT().DescribeWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).Return(
+			&shared.DescribeWorkflowExecutionResponse{
+				WorkflowExecutionInfo: &shared.WorkflowExecutionInfo{
+					Execution:       &shared.WorkflowExecution{WorkflowId: common.StringPtr(workflowID), RunId: common.StringPtr(runID)},
+					AutoResetPoints: &shared.ResetPoints{Points: points},
+				},
+			}, nil)
+	}
+	expectResetRun := func(resetRunID string, eventID int64, skipSignalReapply bool) {
+		s.service.EXPECT().ResetWorkflowExecution(gomock.Any(), gomock.Any(), callOptions()...).DoAndReturn(
+			func(_ context.Context, request *shared.ResetWorkflowExecutionRequest, _ ...yarpc.CallOption) (*shared.ResetWorkflowExecutionResponse, error) {
+				s.Equal(domain, request.GetDomain())
+				s.Equal(workflowID, request.WorkflowExecution.GetWorkflowId())
+				s.Equal(resetRunID, request.WorkflowExecution.GetRunId())
+				s.Equal("reason", request.GetReason())
+				s.Equal(eventID, request.GetDecisionFinishEventId())
+				s.Equal(skipSignalReapply, request.GetSkipSignalReapply())
+				s.NotEmpty(request.GetRequestId())
+				return &shared.ResetWorkflowExecutionResponse{RunId: common.StringPtr("new run ID")}, nil
+			})
+	}
+	expectReset := func(eventID int64, skipSignalReapply bool) {
+		expectResetRun(runID, eventID, skipSignalReapply)
+	}
+	ctx := context.Background()
+
+	// last decision completed of the current run
+	expectDescribe()
+	expectHistory()
+	expectReset(8, false)
+	execution, err := s.client.ResetWorkflowExecution(ctx, ResetOptions{WorkflowID: workflowID, Reason: "reason"})
+	s.NoError(err)
+	s.Equal(WorkflowExecution{ID: workflowID, RunID: "new run ID"}, *execution)
+
+	// first decision completed of a given run, without reapplying signals
+	expectHistory()
+	expectReset(4, true)
+	_, err = s.client.ResetWorkflowExecution(ctx, ResetOptions{
+		WorkflowID:  workflowID,
+		RunID:       runID,
+		Reason:      "reason",
+		ResetType:   ResetTypeFirstDecisionCompleted,
+		ReapplyType: ResetReapplyTypeNone,
+	})
+	s.NoError(err)
+
+	// explicit event ID
+	expectReset(7, false)
+	_, err = s.client.ResetWorkflowExecution(ctx, ResetOptions{WorkflowID: workflowID, RunID: runID, Reason: "reason", ResetType: ResetTypeEventID, EventID: 7})
+	s.NoError(err)
+	_, err = s.client.ResetWorkflowExecution(ctx, ResetOptions{WorkflowID: workflowID, RunID: runID, Reason: "reason", ResetType: ResetTypeEventID})
+	s.Error(err)
+
+	// bad binary
+	badBinaryOptions := ResetOptions{WorkflowID: workflowID, RunID: runID, Reason: "reason", ResetType: ResetTypeBadBinary, BadBinaryChecksum: "bad"}
+	expectDescribe(
+		&shared.ResetPointInfo{BinaryChecksum: common.StringPtr("good"), FirstDecisionCompletedId: common.Int64Ptr(4), Resettable: common.BoolPtr(true)},
+		&shared.ResetPointInfo{BinaryChecksum: common.StringPtr("bad"), FirstDecisionCompletedId: common.Int64Ptr(8), Resettable: common.BoolPtr(true)},
+	)
+	expectReset(8, false)
+	_, err = s.client.ResetWorkflowExecution(ctx, badBinaryOptions)
+	s.NoError(err)
+	expectDescribe(&shared.ResetPointInfo{BinaryChecksum: common.StringPtr("bad"), FirstDecisionCompletedId: common.Int64Ptr(8), Resettable: common.BoolPtr(false)})
+	_, err = s.client.ResetWorkflowExecution(ctx, badBinaryOptions)
+	s.Error(err)
+	// the reset point of a run before a continue as new resets that run
+	expectDescribe(&shared.ResetPointInfo{
+		BinaryChecksum:           common.StringPtr("bad"),
+		RunId:                    common.StringPtr("previous run ID"),
+		FirstDecisionCompletedId: common.Int64Ptr(4),
+		Resettable:               common.BoolPtr(true),
+	})
+	expectResetRun("previous run ID", 4, false)
+	_, err = s.client.ResetWorkflowExecution(ctx, badBinaryOptions)
+	s.NoError(err)
+
+	_, err = s.client.ResetWorkflowExecution(ctx, ResetOptions{WorkflowID: workflowID})
+	s.EqualError(err, "missing Reason")
+}
+
 func (s *workflowClientTestSuite) TestStartWorkflow_WithContext() {
 	s.client = NewClient(s.service, domain, &ClientOptions{ContextPropagators: []ContextPropagator{NewStringMapPropagator([]string{testHeader})}})
 	client, ok := s.client.(*workflowClient)
@@ -1381,6 +1929,39 @@ func (s *workflowClientTestSuite) TestCountWorkflow() {
 	s.Equal(responseErr, err)
 }
 
+func (s *workflowClientTestSuite) TestCountWorkflowWithOptions() {
+	counts := map[string]int64{
+		`WorkflowType = "a"`:                                 10,
+		`(WorkflowType = "a") and CloseStatus = "FAILED"`:    3,
+		`(WorkflowType = "a") and CloseStatus = "TIMED_OUT"`: 1,
+	}
+	s.service.EXPECT().CountWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, req *shared.CountWorkflowExecutionsRequest, _ ...yarpc.CallOption) (*shared.CountWorkflowExecutionsResponse, error) {
+			s.Equal(domain, req.GetDomain())
+			return &shared.CountWorkflowExecutionsResponse{Count: common.Int64Ptr(counts[req.GetQuery()])}, nil
+		}).Times(3)
+	resp, err := s.client.CountWorkflowWithOptions(context.Background(), &CountWorkflowWithOptionsRequest{
+		Query:         `WorkflowType = "a"`,
+		GroupBy:       "CloseStatus",
+		GroupByValues: []interface{}{WorkflowStatusFailed, WorkflowStatusTimedOut},
+	})
+	s.NoError(err)
+	s.Equal(&CountWorkflowWithOptionsResponse{
+		Count:  10,
+		Groups: map[string]int64{"FAILED": 3, "TIMED_OUT": 1},
+	}, resp)
+
+	// close statuses are the default values of CloseStatus
+	s.service.EXPECT().CountWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&shared.CountWorkflowExecutionsResponse{Count: common.Int64Ptr(1)}, nil).Times(7)
+	resp, err = s.client.CountWorkflowWithOptions(context.Background(), &CountWorkflowWithOptionsRequest{GroupBy: "CloseStatus"})
+	s.NoError(err)
+	s.Equal(6, len(resp.Groups))
+
+	_, err = s.client.CountWorkflowWithOptions(context.Background(), &CountWorkflowWithOptionsRequest{GroupBy: "WorkflowType"})
+	s.IsType(&shared.BadRequestError{}, err)
+}
+
 func (s *workflowClientTestSuite) TestGetSearchAttributes() {
 	response := &shared.GetSearchAttributesResponse{}
 	s.service.EXPECT().GetSearchAttributes(gomock.Any(), gomock.Any()).Return(response, nil)
@@ -1403,3 +1984,47 @@ func serializeEvents(events []*shared.HistoryEvent) *shared.DataBlob {
 		Data:         blob.Data,
 	}
 }
+
+func TestGetResetPoints(t *testing.T) {
+	created := time.Unix(1600000000, 0)
+	response := &shared.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &shared.WorkflowExecutionInfo{
+			AutoResetPoints: &shared.ResetPoints{Points: []*shared.ResetPointInfo{
+				{
+					BinaryChecksum:           common.StringPtr("checksum-1"),
+					RunId:                    common.StringPtr(runID),
+					FirstDecisionCompletedId: common.Int64Ptr(4),
+					CreatedTimeNano:          common.Int64Ptr(created.UnixNano()),
+					ExpiringTimeNano:         common.Int64Ptr(created.Add(time.Hour).UnixNano()),
+					Resettable:               common.BoolPtr(true),
+				},
+				{
+					BinaryChecksum:           common.StringPtr("checksum-2"),
+					RunId:                    common.StringPtr(runID),
+					FirstDecisionCompletedId: common.Int64Ptr(10),
+					CreatedTimeNano:          common.Int64Ptr(created.UnixNano()),
+				},
+			}},
+		},
+	}
+
+	points := GetResetPoints(response)
+	require.Equal(t, []ResetPoint{
+		{
+			BinaryChecksum:           "checksum-1",
+			RunID:                    runID,
+			FirstDecisionCompletedID: 4,
+			CreatedTime:              time.Unix(0, created.UnixNano()),
+			ExpiringTime:             time.Unix(0, created.Add(time.Hour).UnixNano()),
+			Resettable:               true,
+		},
+		{
+			BinaryChecksum:           "checksum-2",
+			RunID:                    runID,
+			FirstDecisionCompletedID: 10,
+			CreatedTime:              time.Unix(0, created.UnixNano()),
+		},
+	}, points)
+
+	require.Nil(t, GetResetPoints(&shared.DescribeWorkflowExecutionResponse{}))
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// estimatedCoroutineSize is the memory a blocked coroutine typically holds, mostly its goroutine stack.
+	estimatedCoroutineSize = 8 << 10
+	// estimatedStateEntrySize is the memory an entry of the maps and lists of the execution state holds besides its
+	// payload.
+	estimatedStateEntrySize = 128
+
+	workflowSizeLogInterval = 5 * time.Minute
+	workflowSizeLogCount    = 10
+)
+
+var (
+	// workflowSizeBuckets are the buckets of the history and state size histograms, from 1KiB to 512MiB.
+	workflowSizeBuckets = tally.MustMakeExponentialValueBuckets(1<<10, 2, 20)
+	// workflowCoroutineBuckets are the buckets of the coroutine count histogram, from 1 to 8192.
+	workflowCoroutineBuckets = tally.MustMakeExponentialValueBuckets(1, 2, 14)
+)
+
+type (
+	// workflowSize is the size of an execution after a decision.
+	workflowSize struct {
+		workflowType string
+		workflowID   string
+		runID        string
+		historyBytes int64
+		coroutines   int
+		stateSize    int
+	}
+
+	// workflowSizeTracker keeps the executions of the largest estimated state seen by a worker and logs them
+	// periodically, so that the executions behind a large sticky cache can be found while the histograms only tell
+	// how the sizes spread.
+	workflowSizeTracker struct {
+		sync.Mutex
+		logger   *zap.Logger
+		interval time.Duration
+		count    int
+		lastLog  time.Time
+		largest  []workflowSize // by descending state size, at most count
+	}
+)
+
+func newWorkflowSizeTracker(logger *zap.Logger) *workflowSizeTracker {
+	return &workflowSizeTracker{logger: logger, interval: workflowSizeLogInterval, count: workflowSizeLogCount}
+}
+
+// record adds size to the largest executions, and logs them if the interval passed since they were last logged.
+func (t *workflowSizeTracker) record(size workflowSize, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	if t.lastLog.IsZero() {
+		t.lastLog = now
+	}
+	for i, tracked := range t.largest {
+		if tracked.workflowID == size.workflowID && tracked.runID == size.runID {
+			t.largest = append(t.largest[:i], t.largest[i+1:]...)
+			break
+		}
+	}
+	i := sort.Search(len(t.largest), func(i int) bool { return t.largest[i].stateSize < size.stateSize })
+	if i < t.count {
+		t.largest = append(t.largest, workflowSize{})
+		copy(t.largest[i+1:], t.largest[i:])
+		t.largest[i] = size
+		if len(t.largest) > t.count {
+			t.largest = t.largest[:t.count]
+		}
+	}
+
+	if now.Sub(t.lastLog) < t.interval {
+		return
+	}
+	for rank, tracked := range t.largest {
+		t.logger.Info("Large workflow execution.",
+			zap.Int("Rank", rank+1),
+			zap.String(tagWorkflowType, tracked.workflowType),
+			zap.String(tagWorkflowID, tracked.workflowID),
+			zap.String(tagRunID, tracked.runID),
+			zap.Int64("HistoryBytes", tracked.historyBytes),
+			zap.Int("Coroutines", tracked.coroutines),
+			zap.Int("EstimatedStateSize", tracked.stateSize))
+	}
+	t.largest = t.largest[:0]
+	t.lastLog = now
+}
+
+// estimatedStateSize returns an estimate in bytes of the memory the execution state holds besides its coroutines:
+// the retained side effect, mutable side effect and config payloads, and the state machines of the decisions.
+func (wc *workflowEnvironmentImpl) estimatedStateSize() int {
+	size := 0
+	for _, result := range wc.sideEffectResult {
+		size += len(result) + estimatedStateEntrySize
+	}
+	for id, value := range wc.mutableSideEffect {
+		size += len(id) + len(value) + estimatedStateEntrySize
+	}
+	for key, value := range wc.configSnapshot {
+		size += len(key) + len(value) + estimatedStateEntrySize
+	}
+	for changeID := range wc.changeVersions {
+		size += len(changeID) + estimatedStateEntrySize
+	}
+	entries := len(wc.pendingLaTasks) + len(wc.unstartedLaTasks) + len(wc.openSessions)
+	if h := wc.decisionsHelper; h != nil {
+		entries += h.orderedDecisions.Len() + len(h.decisions) + len(h.scheduledEventIDToActivityID) +
+			len(h.scheduledEventIDToCancellationID) + len(h.scheduledEventIDToSignalID)
+	}
+	return size + entries*estimatedStateEntrySize
+}
+
+// EstimatedStateSize returns an estimate in bytes of the memory the execution holds while it is cached.
+func (weh *workflowExecutionEventHandlerImpl) EstimatedStateSize() int {
+	return weh.workflowEnvironmentImpl.estimatedStateSize() + weh.CoroutineCount()*estimatedCoroutineSize
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWorkflowSizeTracker(t *testing.T) {
+	core, observed := observer.New(zapcore.InfoLevel)
+	tracker := newWorkflowSizeTracker(zap.New(core))
+	tracker.count = 2
+	now := time.Now()
+	size := func(id string, stateSize int) workflowSize {
+		return workflowSize{workflowType: "wt", workflowID: id, runID: "rid", stateSize: stateSize}
+	}
+
+	tracker.record(size("small", 10), now)
+	tracker.record(size("large", 1000), now.Add(time.Second))
+	// a later decision of the same execution replaces its size
+	tracker.record(size("small", 100), now.Add(2*time.Second))
+	tracker.record(size("tiny", 1), now.Add(3*time.Second))
+	assert.Zero(t, observed.Len(), "nothing is logged before the interval passes")
+
+	tracker.record(size("medium", 500), now.Add(workflowSizeLogInterval))
+	logs := observed.FilterMessage("Large workflow execution.").All()
+	if assert.Len(t, logs, 2) {
+		for i, id := range []string{"large", "medium"} {
+			fields := logs[i].ContextMap()
+			assert.Equal(t, id, fields[tagWorkflowID])
+			assert.Equal(t, int64(i+1), fields["Rank"])
+		}
+	}
+
+	// the executions are tracked anew after they are logged
+	tracker.record(size("next", 1), now.Add(workflowSizeLogInterval+time.Second))
+	tracker.record(size("last", 2), now.Add(2*workflowSizeLogInterval))
+	logs = observed.FilterMessage("Large workflow execution.").All()
+	if assert.Len(t, logs, 4) {
+		assert.Equal(t, "last", logs[2].ContextMap()[tagWorkflowID])
+		assert.Equal(t, "next", logs[3].ContextMap()[tagWorkflowID])
+	}
+}
+
+func TestEstimatedStateSize(t *testing.T) {
+	env := &workflowEnvironmentImpl{
+		decisionsHelper:   newDecisionsHelper(),
+		sideEffectResult:  map[int32][]byte{1: make([]byte, 1000)},
+		mutableSideEffect: map[string][]byte{"id": make([]byte, 2000)},
+		changeVersions:    map[string]Version{},
+	}
+	size := env.estimatedStateSize()
+	assert.True(t, size >= 3000, strconv.Itoa(size))
+
+	env.changeVersions["change"] = 1
+	assert.True(t, env.estimatedStateSize() > size)
+}
@@ -24,6 +24,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"testing"
 	"time"
@@ -644,6 +645,137 @@ func receiveAsyncCorruptSignalWorkflowTest(ctx Context) ([]message, error) {
 	return result, nil
 }
 
+func boundedSignalWorkflowTest(ctx Context) ([]string, error) {
+	var overflowed []string
+	ch := GetSignalChannelWithOptions(ctx, "testSig", SignalChannelOptions{
+		MaxBufferedSignals: 2,
+		OnOverflow: func(signal Value) {
+			var v string
+			if err := signal.Get(&v); err != nil {
+				panic(err)
+			}
+			overflowed = append(overflowed, v)
+		},
+	})
+	if err := Sleep(ctx, time.Minute); err != nil {
+		return nil, err
+	}
+
+	var result []string
+	var v string
+	for ch.ReceiveAsync(&v) {
+		result = append(result, v)
+	}
+	return append(result, overflowed...), nil
+}
+
+func (s *WorkflowUnitTest) Test_BoundedSignalChannelWorkflow() {
+	env := newTestWorkflowEnv(s.T())
+	env.RegisterDelayedCallback(func() {
+		for i := 0; i < 4; i++ {
+			env.SignalWorkflow("testSig", fmt.Sprintf("value%v", i))
+		}
+	}, time.Second)
+	env.ExecuteWorkflow(boundedSignalWorkflowTest)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	var result []string
+	s.NoError(env.GetWorkflowResult(&result))
+	// the first two signals are buffered, the rest go to the overflow callback in order
+	s.Equal([]string{"value0", "value1", "value2", "value3"}, result)
+}
+
+func (s *WorkflowUnitTest) Test_BoundedSignalChannelDropsWithoutCallbackWorkflow() {
+	wf := func(ctx Context) (int, error) {
+		ch := GetSignalChannelWithOptions(ctx, "testSig", SignalChannelOptions{MaxBufferedSignals: 1})
+		if err := Sleep(ctx, time.Minute); err != nil {
+			return 0, err
+		}
+		count := 0
+		for ch.ReceiveAsync(nil) {
+			count++
+		}
+		return count, nil
+	}
+	env := newTestWorkflowEnv(s.T())
+	env.RegisterWorkflow(wf)
+	env.RegisterDelayedCallback(func() {
+		for i := 0; i < 3; i++ {
+			env.SignalWorkflow("testSig", i)
+		}
+	}, time.Second)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	var count int
+	s.NoError(env.GetWorkflowResult(&count))
+	s.Equal(1, count)
+}
+
+func signalHandlerWorkflowTest(ctx Context) ([]string, error) {
+	var result []string
+	err := SetSignalHandler(ctx, "testSig", func(ctx Context, v string) {
+		// a blocking handler delays the next signal but keeps the order
+		if err := Sleep(ctx, time.Minute); err != nil {
+			panic(err)
+		}
+		result = append(result, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	done := false
+	if err := SetSignalHandler(ctx, "doneSig", func(ctx Context) { done = true }); err != nil {
+		return nil, err
+	}
+	if err := Await(ctx, func() bool { return done && len(result) == 3 }); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *WorkflowUnitTest) Test_SignalHandlerWorkflow() {
+	env := newTestWorkflowEnv(s.T())
+	env.RegisterDelayedCallback(func() {
+		for i := 0; i < 3; i++ {
+			env.SignalWorkflow("testSig", fmt.Sprintf("value%v", i))
+		}
+		env.SignalWorkflow("doneSig", nil)
+	}, time.Second)
+	env.ExecuteWorkflow(signalHandlerWorkflowTest)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	var result []string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal([]string{"value0", "value1", "value2"}, result)
+}
+
+func (s *WorkflowUnitTest) Test_SignalHandlerInvalidWorkflow() {
+	wf := func(ctx Context) error {
+		if err := SetSignalHandler(ctx, "testSig", func(v string) {}); err == nil {
+			return errors.New("handler without context was accepted")
+		}
+		if err := SetSignalHandler(ctx, "testSig", func(ctx Context) error { return nil }); err == nil {
+			return errors.New("handler with return value was accepted")
+		}
+		if err := SetSignalHandler(ctx, "testSig", func(ctx Context) {}); err != nil {
+			return err
+		}
+		if err := SetSignalHandler(ctx, "testSig", func(ctx Context) {}); err == nil {
+			return errors.New("second handler for the same signal was accepted")
+		}
+		return nil
+	}
+	env := newTestWorkflowEnv(s.T())
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+}
+
 func (s *WorkflowUnitTest) Test_CorruptedSignalWorkflow_ShouldLogMetricsAndNotPanic() {
 	scope, closer, reporter := metrics.NewTaggedMetricsScope()
 	s.SetMetricsScope(scope)
@@ -1182,6 +1314,236 @@ func (s *WorkflowUnitTest) Test_WaitGroupWorkflowTest() {
 	s.Equal(n, total)
 }
 
+func (s *WorkflowUnitTest) Test_WaitGroupReusedWorkflowTest() {
+	env := newTestWorkflowEnv(s.T())
+	wf := func(ctx Context) (int, error) {
+		waitGroup := NewWaitGroup(ctx)
+		// Wait on a WaitGroup with a zero counter returns immediately
+		waitGroup.Wait(ctx)
+
+		count := 0
+		for round := 0; round < 3; round++ {
+			for i := 0; i < 5; i++ {
+				waitGroup.Add(1)
+				Go(ctx, func(ctx Context) {
+					defer waitGroup.Done()
+					_ = Sleep(ctx, time.Second)
+					count++
+				})
+			}
+			waitGroup.Wait(ctx)
+		}
+		return count, nil
+	}
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	var count int
+	s.NoError(env.GetWorkflowResult(&count))
+	s.Equal(15, count)
+}
+
+func (s *WorkflowUnitTest) Test_AwaitAllWorkflowTest() {
+	env := newTestWorkflowEnv(s.T())
+	wf := func(ctx Context) error {
+		var futures []Future
+		var settables []Settable
+		for i := 0; i < 3; i++ {
+			f, settable := NewFuture(ctx)
+			futures = append(futures, f)
+			settables = append(settables, settable)
+		}
+		Go(ctx, func(ctx Context) {
+			for i, settable := range settables {
+				_ = Sleep(ctx, time.Second)
+				if i == 1 {
+					settable.SetError(errors.New("future-1 failed"))
+				} else {
+					settable.SetValue(i)
+				}
+			}
+		})
+
+		err := AwaitAll(ctx, futures)
+		s.NotNil(err)
+		aggregateErr, ok := err.(*AggregateError)
+		s.True(ok)
+		s.Len(aggregateErr.Errors(), 3)
+		s.NoError(aggregateErr.Errors()[0])
+		s.EqualError(aggregateErr.Errors()[1], "future-1 failed")
+		s.NoError(aggregateErr.Errors()[2])
+		for _, f := range futures {
+			s.True(f.IsReady())
+		}
+
+		s.NoError(AwaitAll(ctx, []Future{futures[0], futures[2]}))
+		return nil
+	}
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+}
+
+func (s *WorkflowUnitTest) Test_AwaitAnyWorkflowTest() {
+	env := newTestWorkflowEnv(s.T())
+	wf := func(ctx Context) (int, error) {
+		futures := []Future{
+			NewTimer(ctx, time.Minute),
+			NewTimer(ctx, time.Second),
+			NewTimer(ctx, time.Hour),
+		}
+		index, err := AwaitAny(ctx, futures)
+		if err != nil {
+			return 0, err
+		}
+		s.False(futures[0].IsReady())
+		return index, nil
+	}
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	var index int
+	s.NoError(env.GetWorkflowResult(&index))
+	s.Equal(1, index)
+}
+
+func (s *WorkflowUnitTest) Test_AwaitAnyCanceledWorkflowTest() {
+	env := newTestWorkflowEnv(s.T())
+	wf := func(ctx Context) error {
+		f, _ := NewFuture(ctx)
+		cancelCtx, cancel := WithCancel(ctx)
+		Go(ctx, func(ctx Context) {
+			_ = Sleep(ctx, time.Second)
+			cancel()
+		})
+		index, err := AwaitAny(cancelCtx, []Future{f})
+		s.Equal(-1, index)
+		return err
+	}
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+
+	_, ok := env.GetWorkflowError().(*CanceledError)
+	s.True(ok)
+}
+
+func mutexWorkflowTest(ctx Context, n int) ([]string, error) {
+	var trace []string
+	mutex := NewMutex(ctx)
+	waitGroup := NewWaitGroup(ctx)
+	for i := 0; i < n; i++ {
+		waitGroup.Add(1)
+		id := i
+		Go(ctx, func(ctx Context) {
+			defer waitGroup.Done()
+			if err := mutex.Lock(ctx); err != nil {
+				return
+			}
+			trace = append(trace, fmt.Sprintf("lock-%v", id))
+			_ = Sleep(ctx, time.Second)
+			trace = append(trace, fmt.Sprintf("unlock-%v", id))
+			mutex.Unlock()
+		})
+	}
+	waitGroup.Wait(ctx)
+	return trace, nil
+}
+
+func (s *WorkflowUnitTest) Test_MutexWorkflowTest() {
+	env := newTestWorkflowEnv(s.T())
+	env.RegisterWorkflow(mutexWorkflowTest)
+	env.ExecuteWorkflow(mutexWorkflowTest, 3)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	var trace []string
+	s.NoError(env.GetWorkflowResult(&trace))
+	s.Equal([]string{"lock-0", "unlock-0", "lock-1", "unlock-1", "lock-2", "unlock-2"}, trace)
+}
+
+func (s *WorkflowUnitTest) Test_MutexUnlockOfUnlockedPanicsWorkflowTest() {
+	env := newTestWorkflowEnv(s.T())
+	wf := func(ctx Context) error {
+		mutex := NewMutex(ctx)
+		s.True(mutex.TryLock(ctx))
+		s.False(mutex.TryLock(ctx))
+		mutex.Unlock()
+		mutex.Unlock()
+		return nil
+	}
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+
+	resultErr := env.GetWorkflowError().(*PanicError)
+	s.EqualValues("unlock of unlocked Mutex", resultErr.Error())
+}
+
+func semaphoreWorkflowTest(ctx Context, n int, permits int64) (int64, error) {
+	var running, maxRunning int64
+	semaphore := NewSemaphore(ctx, permits)
+	waitGroup := NewWaitGroup(ctx)
+	for i := 0; i < n; i++ {
+		waitGroup.Add(1)
+		Go(ctx, func(ctx Context) {
+			defer waitGroup.Done()
+			if err := semaphore.Acquire(ctx, 1); err != nil {
+				return
+			}
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			_ = Sleep(ctx, time.Second)
+			running--
+			semaphore.Release(1)
+		})
+	}
+	waitGroup.Wait(ctx)
+	if semaphore.TryAcquire(ctx, permits+1) {
+		return 0, errors.New("acquired more permits than the semaphore size")
+	}
+	return maxRunning, nil
+}
+
+func (s *WorkflowUnitTest) Test_SemaphoreWorkflowTest() {
+	env := newTestWorkflowEnv(s.T())
+	env.RegisterWorkflow(semaphoreWorkflowTest)
+	env.ExecuteWorkflow(semaphoreWorkflowTest, 10, int64(3))
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+
+	var maxRunning int64
+	s.NoError(env.GetWorkflowResult(&maxRunning))
+	s.EqualValues(3, maxRunning)
+}
+
+func (s *WorkflowUnitTest) Test_SemaphoreAcquireCanceledWorkflowTest() {
+	env := newTestWorkflowEnv(s.T())
+	wf := func(ctx Context) error {
+		semaphore := NewSemaphore(ctx, 1)
+		s.True(semaphore.TryAcquire(ctx, 1))
+		cancelCtx, cancel := WithCancel(ctx)
+		Go(ctx, func(ctx Context) {
+			_ = Sleep(ctx, time.Second)
+			cancel()
+		})
+		return semaphore.Acquire(cancelCtx, 1)
+	}
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	s.True(env.IsWorkflowCompleted())
+
+	_, ok := env.GetWorkflowError().(*CanceledError)
+	s.True(ok)
+}
+
 func (s *WorkflowUnitTest) Test_StaleGoroutinesAreShutDown() {
 	env := newTestWorkflowEnv(s.T())
 	deferred := make(chan struct{})
@@ -1255,3 +1617,48 @@ func (t *tracingInterceptor) ExecuteWorkflow(ctx Context, workflowType string, a
 	t.trace = append(t.trace, "ExecuteWorkflow "+workflowType+" end")
 	return result
 }
+
+func TestSortedMapKeys(t *testing.T) {
+	require.Equal(t, []string{"a", "b", "c"}, SortedMapKeys(map[string]int{"c": 3, "a": 1, "b": 2}))
+	require.Equal(t, []int{-1, 2, 10}, SortedMapKeys(map[int]bool{10: true, -1: false, 2: true}))
+	require.Equal(t, []uint8{1, 2}, SortedMapKeys(map[uint8]string{2: "b", 1: "a"}))
+	require.Equal(t, []float64{0.5, 1.5}, SortedMapKeys(map[float64]string{1.5: "b", 0.5: "a"}))
+	require.Equal(t, []bool{false, true}, SortedMapKeys(map[bool]string{true: "t", false: "f"}))
+	require.Equal(t, []string{}, SortedMapKeys(map[string]int{}))
+
+	require.Panics(t, func() { SortedMapKeys([]string{"a"}) }, "not a map")
+	require.Panics(t, func() { SortedMapKeys(map[struct{}]int{{}: 1}) }, "unsupported key type")
+	require.Panics(t, func() { SortedMapKeys(map[float64]int{math.NaN(): 1, 1: 2}) }, "NaN key")
+}
+
+func TestSortedMapRange(t *testing.T) {
+	m := map[string]int{"c": 3, "a": 1, "d": 4, "b": 2}
+
+	var keys []string
+	var values []int
+	SortedMapRange(m, func(key string, value int) {
+		keys = append(keys, key)
+		values = append(values, value)
+	})
+	require.Equal(t, []string{"a", "b", "c", "d"}, keys)
+	require.Equal(t, []int{1, 2, 3, 4}, values)
+
+	keys = nil
+	SortedMapRange(m, func(key string, value int) bool {
+		keys = append(keys, key)
+		return key != "b"
+	})
+	require.Equal(t, []string{"a", "b"}, keys)
+
+	require.Panics(t, func() { SortedMapRange(m, func(key int, value int) {}) }, "wrong key type")
+	require.Panics(t, func() { SortedMapRange(m, func(key string) {}) }, "wrong arity")
+	require.Panics(t, func() { SortedMapRange(m, func(key string, value int) error { return nil }) }, "wrong result")
+	require.Panics(t, func() { SortedMapRange("m", func(key string, value int) {}) }, "not a map")
+}
+
+func TestWithHostPinnedActivityOptions(t *testing.T) {
+	ctx := WithActivityOptions(Background(), ActivityOptions{TaskList: "tl"})
+	pinned := WithHostPinnedActivityOptions(ctx, "host-1")
+	require.Equal(t, getHostPinnedTasklist("host-1"), getActivityOptions(pinned).TaskListName)
+	require.Equal(t, "tl", getActivityOptions(ctx).TaskListName)
+}
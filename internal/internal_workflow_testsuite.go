@@ -183,6 +183,9 @@ type (
 
 		heartbeatDetails []byte
 
+		// task tokens of the activities executed by TestActivityEnvironment that returned ErrActivityResultPending
+		pendingActivityTokens map[string]struct{}
+
 		workerStopChannel  chan struct{}
 		sessionEnvironment *testSessionEnvironmentImpl
 
@@ -249,8 +252,9 @@ func newTestWorkflowEnvironmentImpl(s *WorkflowTestSuite, parentRegistry *regist
 		},
 		registry: r,
 
-		changeVersions: make(map[string]Version),
-		openSessions:   make(map[string]*SessionInfo),
+		changeVersions:        make(map[string]Version),
+		openSessions:          make(map[string]*SessionInfo),
+		pendingActivityTokens: make(map[string]struct{}),
 
 		doneChannel:       make(chan struct{}),
 		workerStopChannel: make(chan struct{}),
@@ -435,6 +439,24 @@ func (env *testWorkflowEnvironmentImpl) setWorkerOptions(options WorkerOptions)
 	if options.Logger != nil {
 		env.workerOptions.Logger = options.Logger
 	}
+	if options.WorkflowConfigProvider != nil {
+		env.workerOptions.WorkflowConfigProvider = options.WorkflowConfigProvider
+	}
+	if options.DefaultActivityOptions != nil {
+		env.workerOptions.DefaultActivityOptions = options.DefaultActivityOptions
+	}
+	if options.DefaultLocalActivityOptions != nil {
+		env.workerOptions.DefaultLocalActivityOptions = options.DefaultLocalActivityOptions
+	}
+	if options.ActivityDeadlineGracePeriod > 0 {
+		env.workerOptions.ActivityDeadlineGracePeriod = options.ActivityDeadlineGracePeriod
+	}
+	if options.MaxHeartbeatThrottleInterval > 0 {
+		env.workerOptions.MaxHeartbeatThrottleInterval = options.MaxHeartbeatThrottleInterval
+	}
+	if options.DefaultHeartbeatThrottleInterval > 0 {
+		env.workerOptions.DefaultHeartbeatThrottleInterval = options.DefaultHeartbeatThrottleInterval
+	}
 	env.workflowInterceptors = options.WorkflowInterceptorChainFactories
 }
 
@@ -588,9 +610,48 @@ func (env *testWorkflowEnvironmentImpl) executeActivityWithOptions(
 	}
 
 	if result == ErrActivityResultPending {
+		env.locker.Lock()
+		env.pendingActivityTokens[string(task.TaskToken)] = struct{}{}
+		env.locker.Unlock()
 		return nil, ErrActivityResultPending
 	}
 
+	return env.activityRespondToResult(result)
+}
+
+// completeActivityByToken completes an activity executed by TestActivityEnvironment that returned
+// ErrActivityResultPending, and returns the result as executeActivity would have.
+func (env *testWorkflowEnvironmentImpl) completeActivityByToken(taskToken []byte, result interface{}, err error) (Value, error) {
+	if taskToken == nil {
+		return nil, errors.New("nil task token provided")
+	}
+	env.locker.Lock()
+	_, ok := env.pendingActivityTokens[string(taskToken)]
+	delete(env.pendingActivityTokens, string(taskToken))
+	env.locker.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no pending activity found for task token %q, could be already completed", taskToken)
+	}
+
+	var data []byte
+	if result != nil {
+		var encodeErr error
+		data, encodeErr = encodeArg(env.GetDataConverter(), result)
+		if encodeErr != nil {
+			return nil, encodeErr
+		}
+	}
+	request := convertActivityResultToRespondRequest("test-identity", taskToken, data, err, env.GetDataConverter())
+	if request == ErrActivityResultPending {
+		env.locker.Lock()
+		env.pendingActivityTokens[string(taskToken)] = struct{}{}
+		env.locker.Unlock()
+		return nil, ErrActivityResultPending
+	}
+	return env.activityRespondToResult(request)
+}
+
+func (env *testWorkflowEnvironmentImpl) activityRespondToResult(result interface{}) (Value, error) {
 	switch request := result.(type) {
 	case *shared.RespondActivityTaskCanceledRequest:
 		details := newEncodedValues(request.Details, env.GetDataConverter())
@@ -794,6 +855,10 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelActivity(activityID string)
 	}, true)
 }
 
+func (env *testWorkflowEnvironmentImpl) RecordTimerSummary(timerID string, summary string) {
+	env.logger.Debug("RecordTimerSummary", zap.String(tagTimerID, timerID), zap.String("Summary", summary))
+}
+
 // RequestCancelTimer request to cancel timer on this testWorkflowEnvironmentImpl.
 func (env *testWorkflowEnvironmentImpl) RequestCancelTimer(timerID string) {
 	env.logger.Debug("RequestCancelTimer", zap.String(tagTimerID, timerID))
@@ -1009,6 +1074,14 @@ func (env *testWorkflowEnvironmentImpl) CompleteActivity(taskToken []byte, resul
 	return nil
 }
 
+func (env *testWorkflowEnvironmentImpl) completeActivityByID(activityID string, result interface{}, err error) error {
+	if activityID == "" {
+		return errors.New("empty activity ID provided")
+	}
+	// the test environment uses the activityID as the task token of activity tasks.
+	return env.CompleteActivity([]byte(activityID), result, err)
+}
+
 func (env *testWorkflowEnvironmentImpl) GetLogger() *zap.Logger {
 	return env.logger
 }
@@ -1060,7 +1133,8 @@ func (env *testWorkflowEnvironmentImpl) ExecuteActivity(parameters executeActivi
 				}
 			} else if panicErr != nil {
 				reason := errReasonPanic
-				details, _ := env.GetDataConverter().ToData(fmt.Sprintf("%v", panicErr))
+				st := getStackTraceRaw(fmt.Sprintf("activity for %s [panic]:", parameters.TaskListName), 7, 0)
+				details, _ := env.GetDataConverter().ToData(fmt.Sprintf("%v", panicErr), st)
 				result = &shared.RespondActivityTaskFailedRequest{
 					Reason:  &reason,
 					Details: details,
@@ -1613,15 +1687,18 @@ func (m *mockWrapper) executeMockWithActualArgs(ctx interface{}, inputArgs []int
 func (env *testWorkflowEnvironmentImpl) newTestActivityTaskHandler(taskList string, dataConverter DataConverter) ActivityTaskHandler {
 	wOptions := augmentWorkerOptions(env.workerOptions)
 	params := workerExecutionParameters{
-		TaskList:           taskList,
-		Identity:           wOptions.Identity,
-		MetricsScope:       wOptions.MetricsScope,
-		Logger:             wOptions.Logger,
-		UserContext:        wOptions.BackgroundActivityContext,
-		DataConverter:      dataConverter,
-		WorkerStopChannel:  env.workerStopChannel,
-		ContextPropagators: wOptions.ContextPropagators,
-		Tracer:             wOptions.Tracer,
+		TaskList:                         taskList,
+		Identity:                         wOptions.Identity,
+		MetricsScope:                     wOptions.MetricsScope,
+		Logger:                           wOptions.Logger,
+		UserContext:                      wOptions.BackgroundActivityContext,
+		DataConverter:                    dataConverter,
+		WorkerStopChannel:                env.workerStopChannel,
+		ContextPropagators:               wOptions.ContextPropagators,
+		Tracer:                           wOptions.Tracer,
+		ActivityDeadlineGracePeriod:      wOptions.ActivityDeadlineGracePeriod,
+		MaxHeartbeatThrottleInterval:     wOptions.MaxHeartbeatThrottleInterval,
+		DefaultHeartbeatThrottleInterval: wOptions.DefaultHeartbeatThrottleInterval,
 	}
 	ensureRequiredParams(&params)
 	if params.UserContext == nil {
@@ -1651,6 +1728,9 @@ func (env *testWorkflowEnvironmentImpl) newTestActivityTaskHandler(taskList stri
 			return nil
 		}
 		ae := &activityExecutor{name: activity.ActivityType().Name, fn: activity.GetFunction()}
+		if executor, ok := activity.(*activityExecutor); ok {
+			ae.factory, ae.method = executor.factory, executor.method
+		}
 
 		// Special handling for session creation and completion activities.
 		// If real creation activity is used, it will block timers from autofiring.
@@ -1748,6 +1828,18 @@ func (env *testWorkflowEnvironmentImpl) RegisterActivityWithOptions(a interface{
 	env.registry.RegisterActivityWithOptions(a, options)
 }
 
+func (env *testWorkflowEnvironmentImpl) RegisterActivityFactory(factory interface{}, options RegisterActivityOptions) {
+	env.registry.RegisterActivityFactory(factory, options)
+}
+
+func (env *testWorkflowEnvironmentImpl) RegisterWorkflowStruct(w interface{}) {
+	env.registry.RegisterWorkflowStruct(w)
+}
+
+func (env *testWorkflowEnvironmentImpl) RegisterActivityStruct(a interface{}) {
+	env.registry.RegisterActivityStruct(a)
+}
+
 func (env *testWorkflowEnvironmentImpl) RegisterCancelHandler(handler func()) {
 	env.workflowCancelHandler = handler
 }
@@ -1770,7 +1862,7 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelChildWorkflow(domainName, w
 }
 
 func (env *testWorkflowEnvironmentImpl) RequestCancelExternalWorkflow(domainName, workflowID, runID string, callback resultHandler) {
-	if env.workflowInfo.WorkflowExecution.ID == workflowID {
+	if env.workflowInfo.WorkflowExecution.ID == workflowID && env.workflowInfo.Domain == domainName {
 		// cancel current workflow
 		env.workflowCancelHandler()
 		// check if current workflow is a child workflow
@@ -1780,7 +1872,13 @@ func (env *testWorkflowEnvironmentImpl) RequestCancelExternalWorkflow(domainName
 			}, false)
 		}
 		return
-	} else if childHandle, ok := env.runningWorkflows[workflowID]; ok && !childHandle.handled {
+	} else if childHandle, ok := env.getRunningWorkflow(domainName, workflowID); ok && childHandle.handled {
+		// the child workflow has already completed
+		env.postCallback(func() {
+			callback(nil, newUnknownExternalWorkflowExecutionError())
+		}, true)
+		return
+	} else if ok {
 		// current workflow is a parent workflow, and we are canceling a child workflow
 		if !childHandle.params.waitForCancellation {
 			childHandle.env.Complete(nil, ErrCanceled)
@@ -1828,7 +1926,7 @@ func (env *testWorkflowEnvironmentImpl) IsCron() bool {
 
 func (env *testWorkflowEnvironmentImpl) SignalExternalWorkflow(domainName, workflowID, runID, signalName string, input []byte, arg interface{}, childWorkflowOnly bool, callback resultHandler) {
 	// check if target workflow is a known workflow
-	if childHandle, ok := env.runningWorkflows[workflowID]; ok {
+	if childHandle, ok := env.getRunningWorkflow(domainName, workflowID); ok {
 		// target workflow is a child
 		childEnv := childHandle.env
 		if childEnv.isTestCompleted {
@@ -1873,6 +1971,16 @@ func (env *testWorkflowEnvironmentImpl) SignalExternalWorkflow(domainName, workf
 	}()
 }
 
+// getRunningWorkflow returns the child workflow started by the test with the given ID, if it runs in the given domain.
+// Workflows of other domains are external to the test and are served by the mocks.
+func (env *testWorkflowEnvironmentImpl) getRunningWorkflow(domainName, workflowID string) (*testWorkflowHandle, bool) {
+	childHandle, ok := env.runningWorkflows[workflowID]
+	if !ok || childHandle.env.workflowInfo.Domain != domainName {
+		return nil, false
+	}
+	return childHandle, true
+}
+
 func (env *testWorkflowEnvironmentImpl) ExecuteChildWorkflow(params executeWorkflowParams, callback resultHandler, startedHandler func(r WorkflowExecution, e error)) error {
 	return env.executeChildWorkflowWithDelay(0, params, callback, startedHandler)
 }
@@ -1976,6 +2084,23 @@ func (env *testWorkflowEnvironmentImpl) MutableSideEffect(id string, f func() in
 	return newEncodedValue(env.encodeValue(f()), env.GetDataConverter())
 }
 
+func (env *testWorkflowEnvironmentImpl) GetConfigValue(key string) Value {
+	provider := env.workerOptions.WorkflowConfigProvider
+	if provider == nil {
+		return newEncodedValue(nil, env.GetDataConverter())
+	}
+	snapshot, err := encodeConfigSnapshot(provider, *env.workflowInfo, env.GetDataConverter())
+	if err != nil {
+		panic(err)
+	}
+	return newEncodedValue(snapshot[key], env.GetDataConverter())
+}
+
+func (env *testWorkflowEnvironmentImpl) SetSnapshotter(snapshotter WorkflowSnapshotter) (bool, error) {
+	// the test environment runs the whole workflow in memory, so it never resumes from a snapshot
+	return false, nil
+}
+
 func (env *testWorkflowEnvironmentImpl) AddSession(sessionInfo *SessionInfo) {
 	env.openSessions[sessionInfo.SessionID] = sessionInfo
 }
@@ -2098,6 +2223,10 @@ func (env *testWorkflowEnvironmentImpl) GetWorkflowInterceptors() []WorkflowInte
 	return env.workflowInterceptors
 }
 
+func (env *testWorkflowEnvironmentImpl) GetDefaultActivityOptions() (*ActivityOptions, *LocalActivityOptions) {
+	return env.workerOptions.DefaultActivityOptions, env.workerOptions.DefaultLocalActivityOptions
+}
+
 func newTestSessionEnvironment(testWorkflowEnvironment *testWorkflowEnvironmentImpl,
 	params *workerExecutionParameters, concurrentSessionExecutionSize int) *testSessionEnvironmentImpl {
 	resourceID := params.SessionResourceID
@@ -187,6 +187,477 @@ func (s *WorkflowTestSuiteUnitTest) Test_OnActivityStartedListener() {
 	s.Equal(expectedCalls, activityCalls)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityTaskListResolver() {
+	workflowFn := func(ctx Context) error {
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		ctx = WithActivityTaskListResolver(ctx, func(activityType string, args []interface{}) string {
+			if region := args[0].(string); region != "default" {
+				return activityType + "-" + region
+			}
+			return ""
+		})
+		for _, region := range []string{"us", "eu", "default"} {
+			if err := ExecuteActivity(ctx, testActivityHello, region).Get(ctx, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivityWithOptions(testActivityHello, RegisterActivityOptions{Name: "testActivityHello"})
+
+	var taskLists []string
+	env.SetOnActivityStartedListener(func(activityInfo *ActivityInfo, ctx context.Context, args Values) {
+		taskLists = append(taskLists, activityInfo.TaskList)
+	})
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]string{"testActivityHello-us", "testActivityHello-eu", defaultTestTaskList}, taskLists)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_RunWithCleanup() {
+	workflowFn := func(ctx Context) (string, error) {
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		var result string
+		err := RunWithCleanup(ctx, func(ctx Context) error {
+			return Sleep(ctx, time.Hour)
+		}, func(ctx Context) error {
+			return ExecuteActivity(ctx, testActivityHello, "cleanup").Get(ctx, &result)
+		}, time.Minute)
+		return result, err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivityWithOptions(testActivityHello, RegisterActivityOptions{Name: "testActivityHello"})
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Minute)
+	var activityCalls []string
+	env.SetOnActivityCompletedListener(func(activityInfo *ActivityInfo, result Value, err error) {
+		var output string
+		s.NoError(result.Get(&output))
+		activityCalls = append(activityCalls, output)
+	})
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.True(IsCanceledError(env.GetWorkflowError()))
+	s.Equal([]string{"hello_cleanup"}, activityCalls)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_RunWithCleanup_Timeout() {
+	workflowFn := func(ctx Context) error {
+		return RunWithCleanup(ctx, func(ctx Context) error {
+			return nil
+		}, func(ctx Context) error {
+			return Sleep(ctx, time.Hour)
+		}, time.Minute)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	start := env.Now()
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.True(IsCanceledError(env.GetWorkflowError()))
+	s.Equal(time.Minute, env.Now().Sub(start))
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_TimerWithOptions_Canceled() {
+	workflowFn := func(ctx Context) (string, error) {
+		timerCtx, cancel := WithCancel(ctx)
+		timer := NewTimerWithOptions(timerCtx, time.Hour, TimerOptions{Summary: "wait for payment"})
+		if err := Sleep(ctx, time.Minute); err != nil {
+			return "", err
+		}
+		cancel()
+
+		err := timer.Get(ctx, nil)
+		canceledErr, ok := err.(*CanceledError)
+		if !ok {
+			return "", fmt.Errorf("unexpected timer error: %v", err)
+		}
+		var summary string
+		if err := canceledErr.Details(&summary); err != nil {
+			return "", err
+		}
+		return summary, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var summary string
+	s.NoError(env.GetWorkflowResult(&summary))
+	s.Equal("wait for payment", summary)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_SleepUntil() {
+	workflowFn := func(ctx Context) error {
+		if err := SleepUntil(ctx, Now(ctx).Add(-time.Hour)); err != nil {
+			return err
+		}
+		return SleepUntil(ctx, Now(ctx).Add(90*time.Minute))
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	start := env.Now()
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(90*time.Minute, env.Now().Sub(start))
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_CrossDomainChildWorkflowAndSignal() {
+	signalName := "test-signal-name"
+	childWorkflowFn := func(ctx Context) (string, error) {
+		var data string
+		GetSignalChannel(ctx, signalName).Receive(ctx, &data)
+		return GetWorkflowInfo(ctx).Domain + ":" + data, nil
+	}
+
+	workflowFn := func(ctx Context) (string, error) {
+		childCtx := WithChildWorkflowOptions(ctx, ChildWorkflowOptions{
+			Domain:                       "other-domain",
+			WorkflowID:                   "child-workflow-id",
+			ExecutionStartToCloseTimeout: time.Minute,
+		})
+		childFuture := ExecuteChildWorkflow(childCtx, childWorkflowFn)
+		if err := childFuture.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+			return "", err
+		}
+
+		// a workflow with the same ID in the current domain is not the child
+		if err := SignalExternalWorkflow(ctx, "child-workflow-id", "", signalName, "wrong-domain").Get(ctx, nil); err != nil {
+			return "", err
+		}
+		// child workflow options without a domain target the current domain
+		noDomainCtx := WithChildWorkflowOptions(ctx, ChildWorkflowOptions{ExecutionStartToCloseTimeout: time.Minute})
+		if err := SignalExternalWorkflow(noDomainCtx, "external-workflow-id", "", signalName, "no-domain").Get(ctx, nil); err != nil {
+			return "", err
+		}
+		if err := SignalExternalWorkflowInDomain(ctx, "other-domain", "child-workflow-id", "", signalName, "data").Get(ctx, nil); err != nil {
+			return "", err
+		}
+
+		var result string
+		err := childFuture.Get(ctx, &result)
+		return result, err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(childWorkflowFn)
+	env.RegisterWorkflow(workflowFn)
+	env.OnSignalExternalWorkflow(defaultTestDomain, "child-workflow-id", "", signalName, "wrong-domain").Return(nil).Once()
+	env.OnSignalExternalWorkflow(defaultTestDomain, "external-workflow-id", "", signalName, "no-domain").Return(nil).Once()
+	env.ExecuteWorkflow(workflowFn)
+	env.AssertExpectations(s.T())
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("other-domain:data", result)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_RequestCancelExternalWorkflow_Errors() {
+	childWorkflowFn := func(ctx Context) error {
+		return nil
+	}
+
+	workflowFn := func(ctx Context) error {
+		childCtx := WithChildWorkflowOptions(ctx, ChildWorkflowOptions{
+			WorkflowID:                   "child-workflow-id",
+			ExecutionStartToCloseTimeout: time.Minute,
+		})
+		if err := ExecuteChildWorkflow(childCtx, childWorkflowFn).Get(ctx, nil); err != nil {
+			return err
+		}
+
+		// the child workflow has already completed
+		err := RequestCancelExternalWorkflow(ctx, "child-workflow-id", "").Get(ctx, nil)
+		if _, ok := err.(*UnknownExternalWorkflowExecutionError); !ok {
+			return fmt.Errorf("unexpected error for completed child: %v", err)
+		}
+		// the external workflow does not exist
+		err = RequestCancelExternalWorkflowInDomain(ctx, "other-domain", "missing-workflow-id", "").Get(ctx, nil)
+		if _, ok := err.(*UnknownExternalWorkflowExecutionError); !ok {
+			return fmt.Errorf("unexpected error for missing workflow: %v", err)
+		}
+		return RequestCancelExternalWorkflowInDomain(ctx, "other-domain", "external-workflow-id", "").Get(ctx, nil)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(childWorkflowFn)
+	env.RegisterWorkflow(workflowFn)
+	env.OnRequestCancelExternalWorkflow("other-domain", "missing-workflow-id", "").Return(newUnknownExternalWorkflowExecutionError()).Once()
+	env.OnRequestCancelExternalWorkflow("other-domain", "external-workflow-id", "").Return(nil).Once()
+	env.ExecuteWorkflow(workflowFn)
+	env.AssertExpectations(s.T())
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+}
+
+type tenantHeaderInterceptorFactory struct{}
+
+func (f *tenantHeaderInterceptorFactory) NewInterceptor(info *WorkflowInfo, next WorkflowInterceptor) WorkflowInterceptor {
+	return &tenantHeaderInterceptor{WorkflowInterceptorBase{Next: next}}
+}
+
+type tenantHeaderInterceptor struct {
+	WorkflowInterceptorBase
+}
+
+func (t *tenantHeaderInterceptor) ExecuteChildWorkflow(ctx Context, childWorkflowType string, args ...interface{}) ChildWorkflowFuture {
+	ctx = WithHeader(ctx, "tenant", []byte("tenant-1"))
+	return t.Next.ExecuteChildWorkflow(ctx, childWorkflowType, args...)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowHeader() {
+	childWorkflowFn := func(ctx Context) ([]string, error) {
+		var fields []string
+		for _, key := range []string{"tenant", "auth", "missing"} {
+			if value, ok := GetHeader(ctx, key); ok {
+				fields = append(fields, key+"="+string(value))
+			}
+		}
+		return fields, nil
+	}
+
+	workflowFn := func(ctx Context) ([]string, error) {
+		if _, ok := GetHeader(ctx, "tenant"); ok {
+			return nil, errors.New("the parent workflow was not started with a tenant header")
+		}
+		ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{ExecutionStartToCloseTimeout: time.Minute})
+		ctx = WithHeader(ctx, "auth", []byte("token"))
+		var result []string
+		err := ExecuteChildWorkflow(ctx, childWorkflowFn).Get(ctx, &result)
+		return result, err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(childWorkflowFn)
+	env.RegisterWorkflow(workflowFn)
+	env.SetWorkerOptions(WorkerOptions{WorkflowInterceptorChainFactories: []WorkflowInterceptorFactory{&tenantHeaderInterceptorFactory{}}})
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result []string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal([]string{"tenant=tenant-1", "auth=token"}, result)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_DefaultActivityOptions() {
+	activityFn := func(ctx context.Context) (string, error) {
+		info := GetActivityInfo(ctx)
+		return fmt.Sprintf("%v:%v", info.TaskList, info.HeartbeatTimeout), nil
+	}
+	workflowFn := func(ctx Context) ([]string, error) {
+		var results []string
+		for _, ctx := range []Context{ctx, WithTaskList(ctx, "other-tasklist")} {
+			var result string
+			if err := ExecuteActivity(ctx, activityFn).Get(ctx, &result); err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+		var result string
+		if err := ExecuteLocalActivity(ctx, activityFn).Get(ctx, &result); err != nil {
+			return nil, err
+		}
+		return append(results, "local"), nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(activityFn)
+	env.SetWorkerOptions(WorkerOptions{
+		DefaultActivityOptions: &ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+			HeartbeatTimeout:       20 * time.Second,
+		},
+		DefaultLocalActivityOptions: &LocalActivityOptions{ScheduleToCloseTimeout: time.Minute},
+	})
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var results []string
+	s.NoError(env.GetWorkflowResult(&results))
+	s.Equal([]string{defaultTestTaskList + ":20s", "other-tasklist:20s", "local"}, results)
+
+	// without defaults the activity options are required
+	env = s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(activityFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.Error(env.GetWorkflowError())
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityOptionsValidation() {
+	activityFn := func(ctx context.Context) error {
+		return nil
+	}
+	for name, test := range map[string]struct {
+		options ActivityOptions
+		message string
+	}{
+		"schedule to close only": {
+			options: ActivityOptions{ScheduleToCloseTimeout: time.Minute},
+		},
+		"missing timeouts": {
+			options: ActivityOptions{ScheduleToStartTimeout: time.Minute},
+			message: "either ScheduleToCloseTimeout, or both ScheduleToStartTimeout and StartToCloseTimeout must be set",
+		},
+		"negative timeout": {
+			options: ActivityOptions{ScheduleToCloseTimeout: time.Minute, StartToCloseTimeout: -time.Second},
+			message: "negative StartToCloseTimeout",
+		},
+		"heartbeat longer than start to close": {
+			options: ActivityOptions{ScheduleToCloseTimeout: time.Hour, StartToCloseTimeout: time.Minute, HeartbeatTimeout: 2 * time.Minute},
+			message: "HeartbeatTimeout (120s) is longer than StartToCloseTimeout (60s)",
+		},
+		"heartbeat as long as start to close": {
+			options: ActivityOptions{ScheduleToCloseTimeout: time.Hour, StartToCloseTimeout: time.Minute, HeartbeatTimeout: time.Minute},
+		},
+		"retry expiration shorter than start to close": {
+			options: ActivityOptions{
+				ScheduleToCloseTimeout: time.Hour,
+				RetryPolicy:            &RetryPolicy{InitialInterval: time.Second, ExpirationInterval: time.Minute},
+			},
+			message: "ExpirationInterval (60s) on retry policy is shorter than StartToCloseTimeout (3600s)",
+		},
+		"retry expiration as long as start to close": {
+			options: ActivityOptions{
+				ScheduleToCloseTimeout: time.Hour,
+				StartToCloseTimeout:    time.Minute,
+				RetryPolicy:            &RetryPolicy{InitialInterval: time.Second, ExpirationInterval: time.Minute},
+			},
+		},
+	} {
+		options := test.options
+		workflowFn := func(ctx Context) error {
+			ctx = WithActivityOptions(ctx, options)
+			return ExecuteActivity(ctx, activityFn).Get(ctx, nil)
+		}
+		s.Run(name, func() {
+			env := s.NewTestWorkflowEnvironment()
+			env.RegisterWorkflow(workflowFn)
+			env.RegisterActivity(activityFn)
+			env.ExecuteWorkflow(workflowFn)
+			s.True(env.IsWorkflowCompleted())
+			if test.message == "" {
+				s.NoError(env.GetWorkflowError())
+				return
+			}
+			err := env.GetWorkflowError()
+			s.Error(err)
+			s.Contains(err.Error(), test.message)
+			// the error names the activity and the line of the workflow code executing it
+			s.Contains(err.Error(), getFunctionName(activityFn))
+			s.Contains(err.Error(), "internal_workflow_testsuite_test.go:")
+		})
+	}
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ChildWorkflowRetryPolicyDefaultExpiration() {
+	var attempts int32
+	childWorkflowFn := func(ctx Context) error {
+		attempts++
+		return NewCustomError("child-failed")
+	}
+	executeChild := func(policy RetryPolicy) error {
+		workflowFn := func(ctx Context) error {
+			ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{
+				ExecutionStartToCloseTimeout: time.Minute,
+				RetryPolicy:                  &policy,
+			})
+			return ExecuteChildWorkflow(ctx, childWorkflowFn).Get(ctx, nil)
+		}
+		env := s.NewTestWorkflowEnvironment()
+		env.RegisterWorkflow(workflowFn)
+		env.RegisterWorkflow(childWorkflowFn)
+		env.ExecuteWorkflow(workflowFn)
+		s.True(env.IsWorkflowCompleted())
+		return env.GetWorkflowError()
+	}
+
+	// neither MaximumAttempts nor ExpirationInterval is set, so the child is retried until its timeout expires
+	err := executeChild(RetryPolicy{InitialInterval: 10 * time.Second, BackoffCoefficient: 1})
+	s.Error(err)
+	var customErr *CustomError
+	s.True(errors.As(err, &customErr), "unexpected error: %v", err)
+	s.Equal("child-failed", customErr.Reason())
+	s.True(attempts > 1 && attempts <= 7, "attempts: %v", attempts)
+
+	err = executeChild(RetryPolicy{InitialInterval: time.Minute, MaximumInterval: time.Second})
+	s.Error(err)
+	s.Contains(err.Error(), "cannot be less than InitialIntervalInSeconds")
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityDeadlineGracePeriod() {
+	var grace time.Duration
+	activityFn := func(ctx context.Context) error {
+		ctxDeadline, ok := ctx.Deadline()
+		s.True(ok)
+		grace = GetActivityInfo(ctx).Deadline.Sub(ctxDeadline)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	workflowFn := func(ctx Context) error {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    2 * time.Second,
+		})
+		return ExecuteActivity(ctx, activityFn).Get(ctx, nil)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{ActivityDeadlineGracePeriod: 1500 * time.Millisecond})
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(activityFn)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.Equal(1500*time.Millisecond, grace)
+	// the activity failed with the error of its context instead of timing out
+	err := env.GetWorkflowError()
+	s.Error(err)
+	var genericErr *GenericError
+	s.True(errors.As(err, &genericErr), "unexpected error: %v", err)
+	s.Contains(genericErr.Error(), context.DeadlineExceeded.Error())
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityHeartbeatTimeout() {
+	activityFn := func(ctx context.Context) (time.Duration, error) {
+		return GetActivityInfo(ctx).HeartbeatTimeout, nil
+	}
+	workflowFn := func(ctx Context) (time.Duration, error) {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToCloseTimeout: time.Hour,
+			StartToCloseTimeout:    time.Minute,
+			HeartbeatTimeout:       30 * time.Second,
+		})
+		var heartbeatTimeout time.Duration
+		err := ExecuteActivity(ctx, activityFn).Get(ctx, &heartbeatTimeout)
+		return heartbeatTimeout, err
+	}
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(activityFn)
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var heartbeatTimeout time.Duration
+	s.NoError(env.GetWorkflowResult(&heartbeatTimeout))
+	// a heartbeat timeout within StartToCloseTimeout is passed to the activity as it is
+	s.Equal(30*time.Second, heartbeatTimeout)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_TimerWorkflow_ClockAutoFastForward() {
 	var firedTimerRecord []string
 	workflowFn := func(ctx Context) error {
@@ -378,6 +849,166 @@ func (s *WorkflowTestSuiteUnitTest) Test_ActivityWithUserContext() {
 	s.Equal(testValue, value)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityFactory() {
+	testKey := testContextKey("tenant")
+	created := 0
+	factory := func(ctx context.Context) (*testFactoryActivities, error) {
+		tenant, ok := ctx.Value(testKey).(string)
+		if !ok {
+			return nil, errors.New("tenant not found in ctx")
+		}
+		created++
+		return &testFactoryActivities{tenant: tenant}, nil
+	}
+
+	env := s.NewTestActivityEnvironment()
+	env.RegisterActivityFactory(factory, RegisterActivityOptions{Name: "factory_"})
+	env.SetWorkerOptions(WorkerOptions{BackgroundActivityContext: context.WithValue(context.Background(), testKey, "tenant1")})
+
+	var a *testFactoryActivities
+	blob, err := env.ExecuteActivity(a.Greet, "hello")
+	s.NoError(err)
+	var value string
+	s.NoError(blob.Get(&value))
+	s.Equal("hello tenant1", value)
+
+	blob, err = env.ExecuteActivity("factory_Greet", "bye")
+	s.NoError(err)
+	s.NoError(blob.Get(&value))
+	s.Equal("bye tenant1", value)
+	s.Equal(2, created)
+
+	env = s.NewTestActivityEnvironment()
+	env.RegisterActivityFactory(factory, RegisterActivityOptions{Name: "factory_"})
+	_, err = env.ExecuteActivity(a.Greet, "hello")
+	s.Error(err)
+	s.Contains(err.Error(), "tenant not found in ctx")
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityFactoryRelease() {
+	var events []string
+	var fail bool
+	factory := func(ctx context.Context) (*testFactoryActivities, func(), error) {
+		if fail {
+			return nil, func() { events = append(events, "unexpected release") }, errors.New("factory failed")
+		}
+		events = append(events, "create")
+		return &testFactoryActivities{tenant: "tenant1", events: &events}, func() { events = append(events, "release") }, nil
+	}
+
+	env := s.NewTestActivityEnvironment()
+	env.RegisterActivityFactory(factory, RegisterActivityOptions{})
+	var a *testFactoryActivities
+	_, err := env.ExecuteActivity(a.Greet, "hello")
+	s.NoError(err)
+	s.Equal([]string{"create", "greet", "release"}, events)
+
+	// the instance is released when the activity fails too
+	events = nil
+	_, err = env.ExecuteActivity(a.Greet, "fail")
+	s.Error(err)
+	s.Equal([]string{"create", "greet", "release"}, events)
+
+	// nothing is released when the factory fails
+	events = nil
+	fail = true
+	_, err = env.ExecuteActivity(a.Greet, "hello")
+	s.Error(err)
+	s.Contains(err.Error(), "factory failed")
+	s.Empty(events)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowConfigValue() {
+	env := s.NewTestWorkflowEnvironment()
+	provider := &testWorkflowConfigProvider{limit: 3}
+	env.SetWorkerOptions(WorkerOptions{WorkflowConfigProvider: provider})
+	env.RegisterWorkflow(configWorkflowFunc)
+	env.ExecuteWorkflow(configWorkflowFunc)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var limits []int
+	s.NoError(env.GetWorkflowResult(&limits))
+	s.Equal([]int{3, 3}, limits)
+
+	// interceptors see and can override the config reads
+	env = s.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{
+		WorkflowConfigProvider:            provider,
+		WorkflowInterceptorChainFactories: []WorkflowInterceptorFactory{&configOverrideInterceptorFactory{}},
+	})
+	env.RegisterWorkflow(configWorkflowFunc)
+	env.ExecuteWorkflow(configWorkflowFunc)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.NoError(env.GetWorkflowResult(&limits))
+	s.Equal([]int{4, 4}, limits)
+}
+
+type configOverrideInterceptorFactory struct{}
+
+func (f *configOverrideInterceptorFactory) NewInterceptor(info *WorkflowInfo, next WorkflowInterceptor) WorkflowInterceptor {
+	return &configOverrideInterceptor{WorkflowInterceptorBase{Next: next}}
+}
+
+type configOverrideInterceptor struct {
+	WorkflowInterceptorBase
+}
+
+func (t *configOverrideInterceptor) GetConfigValue(ctx Context, key string) Value {
+	value := t.Next.GetConfigValue(ctx, key)
+	if key != "limit" {
+		return value
+	}
+	var limit int
+	if err := value.Get(&limit); err != nil {
+		panic(err)
+	}
+	data, err := encodeArg(nil, limit+1)
+	if err != nil {
+		panic(err)
+	}
+	return newEncodedValue(data, nil)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityFactoryInWorkflow() {
+	factory := func(ctx context.Context) *testFactoryActivities {
+		return &testFactoryActivities{tenant: GetActivityInfo(ctx).WorkflowExecution.ID}
+	}
+	workflowFn := func(ctx Context) (string, error) {
+		var a *testFactoryActivities
+		var result string
+		err := ExecuteActivity(WithActivityOptions(ctx, s.activityOptions), a.Greet, "hello").Get(ctx, &result)
+		return result, err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivityFactory(factory, RegisterActivityOptions{})
+	env.ExecuteWorkflow(workflowFn)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("hello "+defaultTestWorkflowID, result)
+}
+
+type testFactoryActivities struct {
+	tenant string
+	events *[]string
+}
+
+func (a *testFactoryActivities) Greet(ctx context.Context, greeting string) (string, error) {
+	if a.events != nil {
+		*a.events = append(*a.events, "greet")
+	}
+	if greeting == "fail" {
+		return "", errors.New("greet failed")
+	}
+	return greeting + " " + a.tenant, nil
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ActivityWithHeaderContext() {
 	workerOptions := WorkerOptions{
 		ContextPropagators: []ContextPropagator{NewStringMapPropagator([]string{testHeader})},
@@ -445,6 +1076,63 @@ func (s *WorkflowTestSuiteUnitTest) Test_ActivityReturnsErrActivityResultPending
 	s.Equal(ErrActivityResultPending, err)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityEnvironment_CompleteActivityByToken() {
+	env := s.NewTestActivityEnvironment()
+	var taskToken []byte
+	activityFn := func(ctx context.Context) (string, error) {
+		taskToken = GetActivityInfo(ctx).TaskToken
+		return "", ErrActivityResultPending
+	}
+	env.RegisterActivity(activityFn)
+
+	_, err := env.ExecuteActivity(activityFn)
+	s.Equal(ErrActivityResultPending, err)
+	val, err := env.CompleteActivityByToken(taskToken, "async_complete", nil)
+	s.NoError(err)
+	var result string
+	s.NoError(val.Get(&result))
+	s.Equal("async_complete", result)
+
+	// already completed
+	_, err = env.CompleteActivityByToken(taskToken, "async_complete", nil)
+	s.Error(err)
+
+	_, err = env.ExecuteActivity(activityFn)
+	s.Equal(ErrActivityResultPending, err)
+	_, err = env.CompleteActivityByToken(taskToken, nil, NewCustomError("async_failure"))
+	s.IsType(&CustomError{}, err)
+	s.Equal("async_failure", err.(*CustomError).Reason())
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_CompleteActivityByID() {
+	env := s.NewTestWorkflowEnvironment()
+	activityFn := func(ctx context.Context) (string, error) {
+		env.RegisterDelayedCallback(func() {
+			s.NoError(env.CompleteActivityByID("async-activity", "async_complete", nil))
+		}, time.Minute)
+		return "", ErrActivityResultPending
+	}
+	workflowFn := func(ctx Context) (string, error) {
+		ao := s.activityOptions
+		ao.ActivityID = "async-activity"
+		ctx = WithActivityOptions(ctx, ao)
+		var result string
+		err := ExecuteActivity(ctx, activityFn).Get(ctx, &result)
+		return result, err
+	}
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(activityFn)
+
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("async_complete", result)
+	s.Error(env.CompleteActivityByID("", nil, nil))
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_WorkflowCancellation() {
 	workflowFn := func(ctx Context) error {
 		ctx = WithActivityOptions(ctx, s.activityOptions)
@@ -622,6 +1310,74 @@ func (s *WorkflowTestSuiteUnitTest) Test_ChildWorkflow_Basic() {
 	s.Equal("hello_activity hello_world", actualResult)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_ChildWorkflow_RegisteredDecisionTaskTimeout() {
+	childWorkflowFn := func(ctx Context) (int32, error) {
+		return GetWorkflowInfo(ctx).TaskStartToCloseTimeoutSeconds, nil
+	}
+	workflowFn := func(ctx Context) ([]int32, error) {
+		var timeouts []int32
+		for _, decisionTimeout := range []time.Duration{0, 5 * time.Second} {
+			ctx := WithChildWorkflowOptions(ctx, ChildWorkflowOptions{
+				ExecutionStartToCloseTimeout: time.Minute,
+				TaskStartToCloseTimeout:      decisionTimeout,
+			})
+			var timeout int32
+			if err := ExecuteChildWorkflow(ctx, "heavy-workflow").Get(ctx, &timeout); err != nil {
+				return nil, err
+			}
+			timeouts = append(timeouts, timeout)
+		}
+		return timeouts, nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterWorkflowWithOptions(childWorkflowFn, RegisterWorkflowOptions{
+		Name:                            "heavy-workflow",
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	})
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var timeouts []int32
+	s.NoError(env.GetWorkflowResult(&timeouts))
+	s.Equal([]int32{60, 5}, timeouts)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ContinueAsNew_RegisteredDecisionTaskTimeout() {
+	heavyWorkflowFn := func(ctx Context) error {
+		return nil
+	}
+	for _, tc := range []struct {
+		decisionTimeout time.Duration
+		expected        int32
+	}{
+		{expected: 60},
+		{decisionTimeout: 5 * time.Second, expected: 5},
+	} {
+		decisionTimeout := tc.decisionTimeout
+		workflowFn := func(ctx Context) error {
+			if decisionTimeout != 0 {
+				ctx = WithWorkflowTaskStartToCloseTimeout(ctx, decisionTimeout)
+			}
+			return NewContinueAsNewError(ctx, "heavy-workflow")
+		}
+		env := s.NewTestWorkflowEnvironment()
+		env.RegisterWorkflow(workflowFn)
+		env.RegisterWorkflowWithOptions(heavyWorkflowFn, RegisterWorkflowOptions{
+			Name:                            "heavy-workflow",
+			DecisionTaskStartToCloseTimeout: time.Minute,
+		})
+		env.ExecuteWorkflow(workflowFn)
+
+		s.True(env.IsWorkflowCompleted())
+		continueAsNewErr, ok := env.GetWorkflowError().(*ContinueAsNewError)
+		s.True(ok)
+		s.Equal(tc.expected, *continueAsNewErr.params.taskStartToCloseTimeoutSeconds)
+	}
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ChildWorkflow_Basic_WithDataConverter() {
 	workflowFn := func(ctx Context) (string, error) {
 		ctx = WithActivityOptions(ctx, s.activityOptions)
@@ -1760,6 +2516,79 @@ func (s *WorkflowTestSuiteUnitTest) Test_QueryWorkflow() {
 	verifyStateWithQuery(stateDone)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_QueryWorkflowWithOptions() {
+	workflowFn := func(ctx Context) error {
+		err := SetQueryHandlerWithOptions(ctx, "info", func(ctx Context, prefix string) (string, error) {
+			return prefix + GetWorkflowInfo(ctx).WorkflowType.Name, nil
+		}, QueryHandlerOptions{Timeout: time.Minute})
+		if err != nil {
+			return err
+		}
+		err = SetQueryHandlerWithOptions(ctx, "slow", func() (string, error) {
+			time.Sleep(10 * time.Millisecond)
+			return "late", nil
+		}, QueryHandlerOptions{Timeout: time.Millisecond})
+		if err != nil {
+			return err
+		}
+		return Sleep(ctx, time.Hour)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{Name: "query-options-workflow"})
+	env.RegisterDelayedCallback(func() {
+		encodedValue, err := env.QueryWorkflow("info", "type:")
+		s.NoError(err)
+		var result string
+		s.NoError(encodedValue.Get(&result))
+		s.Equal("type:query-options-workflow", result)
+
+		_, err = env.QueryWorkflow("slow")
+		s.Error(err)
+		s.Contains(err.Error(), "exceeded its timeout")
+	}, time.Minute)
+	env.ExecuteWorkflow("query-options-workflow")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_QueryWorkflowWithDataConverter() {
+	workflowFn := func(ctx Context) error {
+		// workflow data is gob encoded, but the "json" query result stays readable
+		ctx = WithDataConverter(ctx, newTestDataConverter())
+		state := map[string]int{"count": 1}
+		queryFn := func() (map[string]int, error) {
+			return state, nil
+		}
+		if err := SetQueryHandlerWithOptions(ctx, "json", queryFn, QueryHandlerOptions{DataConverter: getDefaultDataConverter()}); err != nil {
+			return err
+		}
+		if err := SetQueryHandler(ctx, "gob", queryFn); err != nil {
+			return err
+		}
+		return Sleep(ctx, time.Hour)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{Name: "query-data-converter-workflow"})
+	env.RegisterDelayedCallback(func() {
+		var result map[string]int
+		encodedValue, err := env.QueryWorkflow("json")
+		s.NoError(err)
+		s.NoError(encodedValue.Get(&result))
+		s.Equal(map[string]int{"count": 1}, result)
+
+		encodedValue, err = env.QueryWorkflow("gob")
+		s.NoError(err)
+		s.Error(encodedValue.Get(&result))
+	}, time.Minute)
+	env.ExecuteWorkflow("query-data-converter-workflow")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_WorkflowWithLocalActivity() {
 	localActivityFn := func(ctx context.Context, name string) (string, error) {
 		return "hello " + name, nil
@@ -1872,7 +2701,9 @@ func (s *WorkflowTestSuiteUnitTest) Test_SignalChildWorkflow() {
 		var data string
 		GetSignalChannel(ctx, signalName).Receive(ctx, &data)
 
-		err := SignalExternalWorkflow(ctx, parentExec.ID, parentExec.RunID, signalName, data+"-received").Get(ctx, nil)
+		// the parent runs in a different domain than the child
+		parentDomain := *GetWorkflowInfo(ctx).ParentWorkflowDomain
+		err := SignalExternalWorkflowInDomain(ctx, parentDomain, parentExec.ID, parentExec.RunID, signalName, data+"-received").Get(ctx, nil)
 		if err != nil {
 			return "", err
 		}
@@ -2224,6 +3055,39 @@ func (s *WorkflowTestSuiteUnitTest) Test_Channel() {
 	s.True(ok)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_PendingSignals() {
+	var pending []int
+	workflowFn := func(ctx Context) error {
+		info := GetWorkflowInfo(ctx)
+		pending = append(pending, info.PendingSignals())
+		ch := GetSignalChannel(ctx, "test-signal")
+		if err := Sleep(ctx, time.Hour); err != nil {
+			return err
+		}
+		// the signal sent to "other-signal" is buffered although its channel was never requested
+		pending = append(pending, info.PendingSignals())
+		ch.Receive(ctx, nil)
+		pending = append(pending, info.PendingSignals())
+		for ch.ReceiveAsync(nil) {
+		}
+		pending = append(pending, info.PendingSignals())
+		return nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("test-signal", "s1")
+		env.SignalWorkflow("test-signal", "s2")
+		env.SignalWorkflow("other-signal", "s3")
+	}, time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal([]int{0, 3, 2, 1}, pending)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ContextMisuse() {
 	workflowFn := func(ctx Context) error {
 		ch := NewChannel(ctx)
@@ -2344,6 +3208,73 @@ func (s *WorkflowTestSuiteUnitTest) Test_ActivityRetry() {
 	s.Equal(3, attempt2Count)
 }
 
+type nonRetriableTestError struct {
+	msg string
+}
+
+func (e *nonRetriableTestError) Error() string {
+	return e.msg
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ActivityRetryNonRetriableErrors() {
+	typedCount := 0
+	activityTypedFn := func(ctx context.Context) error {
+		typedCount++
+		return fmt.Errorf("wrapped: %w", &nonRetriableTestError{msg: "invalid input"})
+	}
+	customCount := 0
+	activityCustomFn := func(ctx context.Context) error {
+		customCount++
+		return NewCustomError("bad-luck")
+	}
+	retriedCount := 0
+	activityRetriedFn := func(ctx context.Context) error {
+		retriedCount++
+		if GetActivityInfo(ctx).Attempt < 2 {
+			return errors.New("transient")
+		}
+		return nil
+	}
+
+	workflowFn := func(ctx Context) error {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+			RetryPolicy: &RetryPolicy{
+				MaximumAttempts:    5,
+				InitialInterval:    time.Second,
+				BackoffCoefficient: 2,
+			},
+			NonRetriableErrors: []error{&nonRetriableTestError{}},
+		})
+
+		err := ExecuteActivity(ctx, activityTypedFn).Get(ctx, nil)
+		genericErr, ok := err.(*GenericError)
+		s.True(ok)
+		s.Equal("wrapped: invalid input", genericErr.Error())
+
+		err = ExecuteActivity(WithNonRetriableErrors(ctx, NewCustomError("bad-luck")), activityCustomFn).Get(ctx, nil)
+		customErr, ok := err.(*CustomError)
+		s.True(ok)
+		s.Equal("bad-luck", customErr.Reason())
+
+		return ExecuteActivity(ctx, activityRetriedFn).Get(ctx, nil)
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivity(activityTypedFn)
+	env.RegisterActivity(activityCustomFn)
+	env.RegisterActivity(activityRetriedFn)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.Equal(1, typedCount)
+	s.Equal(1, customCount)
+	s.Equal(3, retriedCount)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ActivityHeartbeatRetry() {
 	var startedFrom []int
 	activityHeartBeatFn := func(ctx context.Context, firstTaskID, taskCount int) error {
@@ -0,0 +1,300 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type (
+	// jsonDuration is a time.Duration encoded in JSON as a string parsed by time.ParseDuration, e.g. "1m30s".
+	jsonDuration time.Duration
+
+	// The options are encoded through these types rather than by JSON methods on the options themselves, so that
+	// the encoding of the options by a DataConverter, e.g. as workflow or activity arguments, stays unchanged.
+
+	startWorkflowOptionsJSON struct {
+		ID                              string                 `json:"id,omitempty"`
+		RequestID                       string                 `json:"requestID,omitempty"`
+		TaskList                        string                 `json:"taskList,omitempty"`
+		ExecutionStartToCloseTimeout    jsonDuration           `json:"executionStartToCloseTimeout,omitempty"`
+		DecisionTaskStartToCloseTimeout jsonDuration           `json:"decisionTaskStartToCloseTimeout,omitempty"`
+		WorkflowIDReusePolicy           string                 `json:"workflowIDReusePolicy,omitempty"`
+		RetryPolicy                     *retryPolicyJSON       `json:"retryPolicy,omitempty"`
+		CronSchedule                    string                 `json:"cronSchedule,omitempty"`
+		Memo                            map[string]interface{} `json:"memo,omitempty"`
+		SearchAttributes                map[string]interface{} `json:"searchAttributes,omitempty"`
+		DelayStart                      jsonDuration           `json:"delayStart,omitempty"`
+		JitterStart                     jsonDuration           `json:"jitterStart,omitempty"`
+	}
+
+	activityOptionsJSON struct {
+		TaskList               string           `json:"taskList,omitempty"`
+		ScheduleToCloseTimeout jsonDuration     `json:"scheduleToCloseTimeout,omitempty"`
+		ScheduleToStartTimeout jsonDuration     `json:"scheduleToStartTimeout,omitempty"`
+		StartToCloseTimeout    jsonDuration     `json:"startToCloseTimeout,omitempty"`
+		HeartbeatTimeout       jsonDuration     `json:"heartbeatTimeout,omitempty"`
+		WaitForCancellation    bool             `json:"waitForCancellation,omitempty"`
+		ActivityID             string           `json:"activityID,omitempty"`
+		RetryPolicy            *retryPolicyJSON `json:"retryPolicy,omitempty"`
+		NonRetriableErrors     []string         `json:"nonRetriableErrors,omitempty"`
+	}
+
+	retryPolicyJSON struct {
+		InitialInterval          jsonDuration `json:"initialInterval,omitempty"`
+		BackoffCoefficient       float64      `json:"backoffCoefficient,omitempty"`
+		MaximumInterval          jsonDuration `json:"maximumInterval,omitempty"`
+		ExpirationInterval       jsonDuration `json:"expirationInterval,omitempty"`
+		MaximumAttempts          int32        `json:"maximumAttempts,omitempty"`
+		NonRetriableErrorReasons []string     `json:"nonRetriableErrorReasons,omitempty"`
+	}
+)
+
+var workflowIDReusePolicyNames = map[WorkflowIDReusePolicy]string{
+	WorkflowIDReusePolicyAllowDuplicateFailedOnly: "AllowDuplicateFailedOnly",
+	WorkflowIDReusePolicyAllowDuplicate:           "AllowDuplicate",
+	WorkflowIDReusePolicyRejectDuplicate:          "RejectDuplicate",
+	WorkflowIDReusePolicyTerminateIfRunning:       "TerminateIfRunning",
+}
+
+// MarshalStartWorkflowOptionsJSON encodes the options as a JSON object with lowerCamelCase keys, durations formatted
+// like "1m30s" and the WorkflowIDReusePolicy named without its prefix, e.g. "RejectDuplicate". Unset fields are
+// omitted.
+func MarshalStartWorkflowOptionsJSON(o StartWorkflowOptions) ([]byte, error) {
+	var policy string
+	if o.WorkflowIDReusePolicy != WorkflowIDReusePolicyAllowDuplicateFailedOnly {
+		name, ok := workflowIDReusePolicyNames[o.WorkflowIDReusePolicy]
+		if !ok {
+			return nil, fmt.Errorf("unknown WorkflowIDReusePolicy %v", o.WorkflowIDReusePolicy)
+		}
+		policy = name
+	}
+	return json.Marshal(startWorkflowOptionsJSON{
+		ID:                              o.ID,
+		RequestID:                       o.RequestID,
+		TaskList:                        o.TaskList,
+		ExecutionStartToCloseTimeout:    jsonDuration(o.ExecutionStartToCloseTimeout),
+		DecisionTaskStartToCloseTimeout: jsonDuration(o.DecisionTaskStartToCloseTimeout),
+		WorkflowIDReusePolicy:           policy,
+		RetryPolicy:                     newRetryPolicyJSON(o.RetryPolicy),
+		CronSchedule:                    o.CronSchedule,
+		Memo:                            o.Memo,
+		SearchAttributes:                o.SearchAttributes,
+		DelayStart:                      jsonDuration(o.DelayStart),
+		JitterStart:                     jsonDuration(o.JitterStart),
+	})
+}
+
+// ParseStartWorkflowOptionsJSON decodes options encoded by MarshalStartWorkflowOptionsJSON. Unknown keys, durations
+// without a unit, negative durations, unknown WorkflowIDReusePolicy names, invalid cron schedules and invalid retry
+// policies are rejected.
+func ParseStartWorkflowOptionsJSON(data []byte) (StartWorkflowOptions, error) {
+	var decoded startWorkflowOptionsJSON
+	if err := decodeStrictJSON(data, &decoded); err != nil {
+		return StartWorkflowOptions{}, fmt.Errorf("invalid StartWorkflowOptions: %v", err)
+	}
+	var policy WorkflowIDReusePolicy
+	if decoded.WorkflowIDReusePolicy != "" {
+		found := false
+		for value, name := range workflowIDReusePolicyNames {
+			if name == decoded.WorkflowIDReusePolicy {
+				policy, found = value, true
+			}
+		}
+		if !found {
+			return StartWorkflowOptions{}, fmt.Errorf("invalid StartWorkflowOptions: unknown workflowIDReusePolicy %q", decoded.WorkflowIDReusePolicy)
+		}
+	}
+	if err := validateCronSchedule(decoded.CronSchedule); err != nil {
+		return StartWorkflowOptions{}, fmt.Errorf("invalid StartWorkflowOptions: invalid cronSchedule %q: %v", decoded.CronSchedule, err)
+	}
+	retryPolicy, err := decoded.RetryPolicy.retryPolicy()
+	if err != nil {
+		return StartWorkflowOptions{}, fmt.Errorf("invalid StartWorkflowOptions: %v", err)
+	}
+	return StartWorkflowOptions{
+		ID:                              decoded.ID,
+		RequestID:                       decoded.RequestID,
+		TaskList:                        decoded.TaskList,
+		ExecutionStartToCloseTimeout:    time.Duration(decoded.ExecutionStartToCloseTimeout),
+		DecisionTaskStartToCloseTimeout: time.Duration(decoded.DecisionTaskStartToCloseTimeout),
+		WorkflowIDReusePolicy:           policy,
+		RetryPolicy:                     retryPolicy,
+		CronSchedule:                    decoded.CronSchedule,
+		Memo:                            decoded.Memo,
+		SearchAttributes:                decoded.SearchAttributes,
+		DelayStart:                      time.Duration(decoded.DelayStart),
+		JitterStart:                     time.Duration(decoded.JitterStart),
+	}, nil
+}
+
+// MarshalActivityOptionsJSON encodes the options as a JSON object with lowerCamelCase keys and durations formatted
+// like "1m30s". The NonRetriableErrors are encoded by name, see ParseActivityOptionsJSON. Unset fields are omitted.
+func MarshalActivityOptionsJSON(o ActivityOptions) ([]byte, error) {
+	var nonRetriableErrors []string
+	for _, err := range o.NonRetriableErrors {
+		if err != nil {
+			nonRetriableErrors = append(nonRetriableErrors, getNonRetriableErrorName(err))
+		}
+	}
+	return json.Marshal(activityOptionsJSON{
+		TaskList:               o.TaskList,
+		ScheduleToCloseTimeout: jsonDuration(o.ScheduleToCloseTimeout),
+		ScheduleToStartTimeout: jsonDuration(o.ScheduleToStartTimeout),
+		StartToCloseTimeout:    jsonDuration(o.StartToCloseTimeout),
+		HeartbeatTimeout:       jsonDuration(o.HeartbeatTimeout),
+		WaitForCancellation:    o.WaitForCancellation,
+		ActivityID:             o.ActivityID,
+		RetryPolicy:            newRetryPolicyJSON(o.RetryPolicy),
+		NonRetriableErrors:     nonRetriableErrors,
+	})
+}
+
+// ParseActivityOptionsJSON decodes options encoded by MarshalActivityOptionsJSON. The NonRetriableErrors are listed
+// by name, the reason of a *CustomError or else the name of the type of the error qualified by its package path,
+// e.g. "*github.com/org/repo/pkg.NotFoundError" for a pointer type, and are resolved to the one of the knownErrors
+// with that name. Unknown keys, durations without a unit, negative durations, unknown error names and invalid retry
+// policies are rejected.
+func ParseActivityOptionsJSON(data []byte, knownErrors ...error) (ActivityOptions, error) {
+	var decoded activityOptionsJSON
+	if err := decodeStrictJSON(data, &decoded); err != nil {
+		return ActivityOptions{}, fmt.Errorf("invalid ActivityOptions: %v", err)
+	}
+	retryPolicy, err := decoded.RetryPolicy.retryPolicy()
+	if err != nil {
+		return ActivityOptions{}, fmt.Errorf("invalid ActivityOptions: %v", err)
+	}
+	var nonRetriableErrors []error
+	for _, name := range decoded.NonRetriableErrors {
+		var found error
+		for _, err := range knownErrors {
+			if err != nil && getNonRetriableErrorName(err) == name {
+				found = err
+				break
+			}
+		}
+		if found == nil {
+			return ActivityOptions{}, fmt.Errorf("invalid ActivityOptions: unknown nonRetriableErrors %q", name)
+		}
+		nonRetriableErrors = append(nonRetriableErrors, found)
+	}
+	return ActivityOptions{
+		TaskList:               decoded.TaskList,
+		ScheduleToCloseTimeout: time.Duration(decoded.ScheduleToCloseTimeout),
+		ScheduleToStartTimeout: time.Duration(decoded.ScheduleToStartTimeout),
+		StartToCloseTimeout:    time.Duration(decoded.StartToCloseTimeout),
+		HeartbeatTimeout:       time.Duration(decoded.HeartbeatTimeout),
+		WaitForCancellation:    decoded.WaitForCancellation,
+		ActivityID:             decoded.ActivityID,
+		RetryPolicy:            retryPolicy,
+		NonRetriableErrors:     nonRetriableErrors,
+	}, nil
+}
+
+// MarshalRetryPolicyJSON encodes the policy as a JSON object with lowerCamelCase keys and durations formatted like
+// "1m30s". Unset fields are omitted.
+func MarshalRetryPolicyJSON(p RetryPolicy) ([]byte, error) {
+	return json.Marshal(newRetryPolicyJSON(&p))
+}
+
+// ParseRetryPolicyJSON decodes a policy encoded by MarshalRetryPolicyJSON. Unknown keys, durations without a unit,
+// negative durations and policies which would be rejected when scheduling an activity or starting a workflow are
+// rejected.
+func ParseRetryPolicyJSON(data []byte) (*RetryPolicy, error) {
+	var decoded retryPolicyJSON
+	if err := decodeStrictJSON(data, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid RetryPolicy: %v", err)
+	}
+	return decoded.retryPolicy()
+}
+
+// getNonRetriableErrorName returns the name of a non retriable error in JSON, the reason of a *CustomError, as it is
+// matched by reason, or else the name of its type.
+func getNonRetriableErrorName(err error) string {
+	if err, ok := err.(*CustomError); ok {
+		return err.Reason()
+	}
+	return getErrorTypeName(err)
+}
+
+func newRetryPolicyJSON(p *RetryPolicy) *retryPolicyJSON {
+	if p == nil {
+		return nil
+	}
+	return &retryPolicyJSON{
+		InitialInterval:          jsonDuration(p.InitialInterval),
+		BackoffCoefficient:       p.BackoffCoefficient,
+		MaximumInterval:          jsonDuration(p.MaximumInterval),
+		ExpirationInterval:       jsonDuration(p.ExpirationInterval),
+		MaximumAttempts:          p.MaximumAttempts,
+		NonRetriableErrorReasons: p.NonRetriableErrorReasons,
+	}
+}
+
+// retryPolicy returns the decoded policy, or nil if it was not set.
+func (p *retryPolicyJSON) retryPolicy() (*RetryPolicy, error) {
+	if p == nil {
+		return nil, nil
+	}
+	policy := &RetryPolicy{
+		InitialInterval:          time.Duration(p.InitialInterval),
+		BackoffCoefficient:       p.BackoffCoefficient,
+		MaximumInterval:          time.Duration(p.MaximumInterval),
+		ExpirationInterval:       time.Duration(p.ExpirationInterval),
+		MaximumAttempts:          p.MaximumAttempts,
+		NonRetriableErrorReasons: p.NonRetriableErrorReasons,
+	}
+	// convertRetryPolicy defaults the BackoffCoefficient of its argument, so validate a copy
+	validated := *policy
+	if err := validateRetryPolicy(convertRetryPolicy(&validated)); err != nil {
+		return nil, fmt.Errorf("invalid RetryPolicy: %v", err)
+	}
+	return policy, nil
+}
+
+func decodeStrictJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations must be strings with a unit, e.g. \"30s\": %s", data)
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if duration < 0 {
+		return fmt.Errorf("negative duration %q", s)
+	}
+	*d = jsonDuration(duration)
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartWorkflowOptionsJSON(t *testing.T) {
+	options := StartWorkflowOptions{
+		ID:                              "wid",
+		RequestID:                       "rid",
+		TaskList:                        "tasklist",
+		ExecutionStartToCloseTimeout:    time.Hour,
+		DecisionTaskStartToCloseTimeout: 10 * time.Second,
+		WorkflowIDReusePolicy:           WorkflowIDReusePolicyRejectDuplicate,
+		RetryPolicy: &RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2,
+			MaximumAttempts:    5,
+		},
+		CronSchedule: "@every 1h",
+		Memo:         map[string]interface{}{"owner": "team"},
+		DelayStart:   90 * time.Second,
+	}
+	data, err := MarshalStartWorkflowOptionsJSON(options)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "wid",
+		"requestID": "rid",
+		"taskList": "tasklist",
+		"executionStartToCloseTimeout": "1h0m0s",
+		"decisionTaskStartToCloseTimeout": "10s",
+		"workflowIDReusePolicy": "RejectDuplicate",
+		"retryPolicy": {"initialInterval": "1s", "backoffCoefficient": 2, "maximumAttempts": 5},
+		"cronSchedule": "@every 1h",
+		"memo": {"owner": "team"},
+		"delayStart": "1m30s"
+	}`, string(data))
+
+	decoded, err := ParseStartWorkflowOptionsJSON(data)
+	require.NoError(t, err)
+	assert.Equal(t, options, decoded)
+
+	data, err = MarshalStartWorkflowOptionsJSON(StartWorkflowOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+}
+
+func TestActivityOptionsJSON(t *testing.T) {
+	reason := NewCustomError("not found")
+	options, err := ParseActivityOptionsJSON([]byte(`{
+		"taskList": "activities",
+		"scheduleToStartTimeout": "1m",
+		"startToCloseTimeout": "30s",
+		"heartbeatTimeout": "500ms",
+		"waitForCancellation": true,
+		"retryPolicy": {"initialInterval": "1s", "expirationInterval": "10m"},
+		"nonRetriableErrors": ["*go.uber.org/cadence/internal.nonRetriableTestError", "not found"]
+	}`), reason, &nonRetriableTestError{})
+	require.NoError(t, err)
+	assert.Equal(t, ActivityOptions{
+		TaskList:               "activities",
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    30 * time.Second,
+		HeartbeatTimeout:       500 * time.Millisecond,
+		WaitForCancellation:    true,
+		RetryPolicy: &RetryPolicy{
+			InitialInterval:    time.Second,
+			ExpirationInterval: 10 * time.Minute,
+		},
+		NonRetriableErrors: []error{&nonRetriableTestError{}, reason},
+	}, options)
+
+	data, err := MarshalActivityOptionsJSON(options)
+	require.NoError(t, err)
+	decoded, err := ParseActivityOptionsJSON(data, reason, &nonRetriableTestError{})
+	require.NoError(t, err)
+	assert.Equal(t, options, decoded)
+}
+
+func TestOptionsJSON_DataConverterEncodingUnchanged(t *testing.T) {
+	// the options can be workflow or activity arguments, their encoding must not change for replay
+	data, err := json.Marshal(StartWorkflowOptions{ID: "wid", ExecutionStartToCloseTimeout: time.Second})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"ID":"wid"`)
+	assert.Contains(t, string(data), `"ExecutionStartToCloseTimeout":1000000000`)
+
+	data, err = json.Marshal(ActivityOptions{RetryPolicy: &RetryPolicy{InitialInterval: time.Second}})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"RetryPolicy":{"InitialInterval":1000000000`)
+}
+
+func TestOptionsJSON_Invalid(t *testing.T) {
+	parsers := map[string]func(data []byte) error{
+		"activity": func(data []byte) error {
+			_, err := ParseActivityOptionsJSON(data)
+			return err
+		},
+		"workflow": func(data []byte) error {
+			_, err := ParseStartWorkflowOptionsJSON(data)
+			return err
+		},
+		"retry": func(data []byte) error {
+			_, err := ParseRetryPolicyJSON(data)
+			return err
+		},
+	}
+	for name, test := range map[string]struct {
+		data    string
+		parser  string
+		message string
+	}{
+		"unknown field":         {`{"startToClose": "1s"}`, "activity", "unknown field"},
+		"duration without unit": {`{"startToCloseTimeout": 30}`, "activity", `e.g. "30s"`},
+		"invalid duration":      {`{"heartbeatTimeout": "1 minute"}`, "activity", "1 minute"},
+		"unknown error":         {`{"nonRetriableErrors": ["pkg.Error"]}`, "activity", "pkg.Error"},
+		"negative duration":     {`{"delayStart": "-1s"}`, "workflow", "negative duration"},
+		"reuse policy":          {`{"workflowIDReusePolicy": "Sometimes"}`, "workflow", "Sometimes"},
+		"cron schedule":         {`{"cronSchedule": "every day"}`, "workflow", "cronSchedule"},
+		"nested unknown field":  {`{"retryPolicy": {"initialInterval": "1s", "maxAttempts": 3}}`, "workflow", "unknown field"},
+		"nested retry policy":   {`{"retryPolicy": {"initialInterval": "1s"}}`, "workflow", "at least one of them must be set"},
+		"retry policy":          {`{"initialInterval": "1s"}`, "retry", "at least one of them must be set"},
+		"backoff coefficient":   {`{"initialInterval": "1s", "maximumAttempts": 3, "backoffCoefficient": 0.5}`, "retry", "BackoffCoefficient"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := parsers[test.parser]([]byte(test.data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.message)
+		})
+	}
+}
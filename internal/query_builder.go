@@ -75,6 +75,7 @@ type (
 		WorkflowTypes([]string) QueryBuilder
 		WorkflowStatus([]WorkflowStatus) QueryBuilder
 		StartTime(time.Time, time.Time) QueryBuilder
+		CloseTime(time.Time, time.Time) QueryBuilder
 		Build() string
 	}
 
@@ -131,6 +132,19 @@ func (q *queryBuilderImpl) StartTime(minStartTime, maxStartTime time.Time) Query
 	return q
 }
 
+func (q *queryBuilderImpl) CloseTime(minCloseTime, maxCloseTime time.Time) QueryBuilder {
+	closeTimeQueries := make([]string, 0, 2)
+	if !minCloseTime.IsZero() {
+		closeTimeQueries = append(closeTimeQueries, fmt.Sprintf(keyCloseTime+` >= %v`, minCloseTime.UnixNano()))
+	}
+	if !maxCloseTime.Equal(maxTimestamp) {
+		closeTimeQueries = append(closeTimeQueries, fmt.Sprintf(keyCloseTime+` <= %v`, maxCloseTime.UnixNano()))
+	}
+
+	q.appendPartialQuery(strings.Join(closeTimeQueries, " and "))
+	return q
+}
+
 func (q *queryBuilderImpl) Build() string {
 	return q.builder.String()
 }
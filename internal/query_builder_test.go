@@ -153,6 +153,42 @@ func (s *queryBuilderSuite) TestStartTimeQuery() {
 	}
 }
 
+func (s *queryBuilderSuite) TestCloseTimeQuery() {
+	testTimestamp := time.Now()
+	testCases := []struct {
+		msg           string
+		minCloseTime  time.Time
+		maxCloseTime  time.Time
+		expectedQuery string
+	}{
+		{
+			msg:           "empty minTimestamp",
+			maxCloseTime:  testTimestamp,
+			expectedQuery: fmt.Sprintf("(CloseTime <= %v)", testTimestamp.UnixNano()),
+		},
+		{
+			msg:           "max maxTimestamp",
+			minCloseTime:  testTimestamp,
+			maxCloseTime:  maxTimestamp,
+			expectedQuery: fmt.Sprintf("(CloseTime >= %v)", testTimestamp.UnixNano()),
+		},
+		{
+			msg:           "both timestamps are used",
+			minCloseTime:  testTimestamp.Add(-time.Hour),
+			maxCloseTime:  testTimestamp,
+			expectedQuery: fmt.Sprintf("(CloseTime >= %v and CloseTime <= %v)", testTimestamp.Add(-time.Hour).UnixNano(), testTimestamp.UnixNano()),
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.msg, func(t *testing.T) {
+			builder := NewQueryBuilder()
+			builder.CloseTime(test.minCloseTime, test.maxCloseTime)
+			s.Equal(test.expectedQuery, builder.Build())
+		})
+	}
+}
+
 func (s *queryBuilderSuite) TestMultipleFilters() {
 	maxStartTime := time.Now()
 	minStartTime := maxStartTime.Add(-time.Hour)
@@ -24,8 +24,10 @@ package internal
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -39,21 +41,25 @@ var globalRegistry *registry
 
 func newRegistry() *registry {
 	return &registry{
-		workflowFuncMap:  make(map[string]interface{}),
-		workflowAliasMap: make(map[string]string),
-		activityFuncMap:  make(map[string]activity),
-		activityAliasMap: make(map[string]string),
-		next:             getGlobalRegistry(),
+		workflowFuncMap:              make(map[string]interface{}),
+		workflowAliasMap:             make(map[string]string),
+		workflowOptionsMap:           make(map[string]RegisterWorkflowOptions),
+		activityFuncMap:              make(map[string]activity),
+		activityAliasMap:             make(map[string]string),
+		workflowDecisionTaskTimeouts: make(map[string]time.Duration),
+		next:                         getGlobalRegistry(),
 	}
 }
 
 func getGlobalRegistry() *registry {
 	once.Do(func() {
 		globalRegistry = &registry{
-			workflowFuncMap:  make(map[string]interface{}),
-			workflowAliasMap: make(map[string]string),
-			activityFuncMap:  make(map[string]activity),
-			activityAliasMap: make(map[string]string),
+			workflowFuncMap:              make(map[string]interface{}),
+			workflowAliasMap:             make(map[string]string),
+			workflowOptionsMap:           make(map[string]RegisterWorkflowOptions),
+			activityFuncMap:              make(map[string]activity),
+			activityAliasMap:             make(map[string]string),
+			workflowDecisionTaskTimeouts: make(map[string]time.Duration),
 		}
 	})
 	return globalRegistry
@@ -65,9 +71,50 @@ type registry struct {
 	workflowAliasMap map[string]string
 	activityFuncMap  map[string]activity
 	activityAliasMap map[string]string
-	next             *registry // Allows to chain registries
+	// workflowOptionsMap are the options the workflow types were registered with
+	workflowOptionsMap map[string]RegisterWorkflowOptions
+	// workflowDecisionTaskTimeouts are the default DecisionTaskStartToCloseTimeout of the workflow types
+	workflowDecisionTaskTimeouts map[string]time.Duration
+	// registeredWorkflows and registeredActivities memoize the metadata of the types registered in this registry,
+	// they are reset by every registration
+	registeredWorkflows  []RegisteredWorkflowInfo
+	registeredActivities []RegisteredActivityInfo
+	// activityNameMapper maps the name of the activity types registered in this registry
+	activityNameMapper func(activityType string) string
+	next               *registry // Allows to chain registries
 }
 
+type (
+	// RegisteredWorkflowInfo is the metadata of a workflow type registered with a worker, see
+	// Worker.GetRegisteredWorkflows.
+	RegisteredWorkflowInfo struct {
+		// Name is the workflow type name.
+		Name string
+		// Aliases are the function names the workflow type is also resolved by, e.g. the fully qualified name of
+		// a workflow function registered with a name.
+		Aliases []string
+		// FunctionType is the signature of the workflow function.
+		FunctionType reflect.Type
+		// Options are the options the workflow was registered with.
+		Options RegisterWorkflowOptions
+	}
+
+	// RegisteredActivityInfo is the metadata of an activity type registered with a worker, see
+	// Worker.GetRegisteredActivities.
+	RegisteredActivityInfo struct {
+		// Name is the activity type name.
+		Name string
+		// Aliases are the function names the activity type is also resolved by, e.g. the fully qualified name of
+		// an activity function registered with a name.
+		Aliases []string
+		// FunctionType is the signature of the activity function, for the methods of a structure the receiver is
+		// not part of the signature.
+		FunctionType reflect.Type
+		// Options are the options the activity was registered with.
+		Options RegisterActivityOptions
+	}
+)
+
 func (r *registry) RegisterWorkflow(af interface{}) {
 	r.RegisterWorkflowWithOptions(af, RegisterWorkflowOptions{})
 }
@@ -81,7 +128,11 @@ func (r *registry) RegisterWorkflowWithOptions(
 	if err := validateFnFormat(fnType, true); err != nil {
 		panic(err)
 	}
-	fnName := getFunctionName(wf)
+	r.registerWorkflow(wf, getFunctionName(wf), options)
+}
+
+// registerWorkflow registers a validated workflow function, fnName is the name it is resolved by
+func (r *registry) registerWorkflow(wf interface{}, fnName string, options RegisterWorkflowOptions) {
 	alias := options.Name
 	registerName := fnName
 
@@ -104,6 +155,11 @@ func (r *registry) RegisterWorkflowWithOptions(
 	if len(alias) > 0 || options.EnableShortName {
 		r.workflowAliasMap[fnName] = registerName
 	}
+	if options.DecisionTaskStartToCloseTimeout > 0 {
+		r.workflowDecisionTaskTimeouts[registerName] = options.DecisionTaskStartToCloseTimeout
+	}
+	r.workflowOptionsMap[registerName] = options
+	r.registeredWorkflows = nil
 }
 
 func (r *registry) RegisterActivity(af interface{}) {
@@ -129,12 +185,15 @@ func (r *registry) registerActivityFunction(af interface{}, options RegisterActi
 	if err := validateFnFormat(fnType, false); err != nil {
 		return fmt.Errorf("failed to register activity method: %v", err)
 	}
+	return r.registerActivity(af, getFunctionName(af), options)
+}
 
-	fnName := getFunctionName(af)
+// registerActivity registers a validated activity function, fnName is the name it is resolved by
+func (r *registry) registerActivity(af interface{}, fnName string, options RegisterActivityOptions) error {
 	alias := options.Name
 	registerName := fnName
 
-	if options.EnableShortName {
+	if options.EnableShortName || len(options.Namespace) > 0 {
 		registerName = getShortFunctionName(fnName)
 	}
 	if len(alias) > 0 {
@@ -144,15 +203,18 @@ func (r *registry) registerActivityFunction(af interface{}, options RegisterActi
 	r.Lock()
 	defer r.Unlock()
 
+	registerName = r.qualifyActivityName(registerName, options)
+
 	if !options.DisableAlreadyRegisteredCheck {
 		if _, ok := r.getActivityNoLock(registerName); ok {
 			return fmt.Errorf("activity type \"%v\" is already registered", registerName)
 		}
 	}
-	r.activityFuncMap[registerName] = &activityExecutor{registerName, af, options}
-	if len(alias) > 0 || options.EnableShortName {
+	r.activityFuncMap[registerName] = &activityExecutor{name: registerName, fn: af, options: options}
+	if registerName != fnName {
 		r.activityAliasMap[fnName] = registerName
 	}
+	r.registeredActivities = nil
 
 	return nil
 }
@@ -176,35 +238,297 @@ func (r *registry) registerActivityStruct(aStruct interface{}, options RegisterA
 			return fmt.Errorf("failed to register activity method %v of %v: %e", methodName, structType.Name(), err)
 		}
 
-		structPrefix := options.Name
-		registerName := methodName
+		registerName := r.qualifyActivityName(getStructActivityName(methodName, options), options)
 
-		if options.EnableShortName {
-			registerName = getShortFunctionName(methodName)
+		if !options.DisableAlreadyRegisteredCheck {
+			if _, ok := r.getActivityNoLock(registerName); ok {
+				return fmt.Errorf("activity type \"%v\" is already registered", registerName)
+			}
 		}
-		if len(structPrefix) > 0 {
-			registerName = structPrefix + getShortFunctionName(methodName)
+		r.activityFuncMap[registerName] = &activityExecutor{name: registerName, fn: methodValue.Interface(), options: options}
+		if registerName != methodName {
+			r.activityAliasMap[methodName] = registerName
 		}
+		r.registeredActivities = nil
+		count++
+	}
+
+	if count == 0 {
+		return fmt.Errorf("no activities (public methods) found in %v structure", structType.Name())
+	}
+
+	return nil
+}
+
+// getStructActivityName returns the activity type name of a struct method, the name in options is a prefix
+func getStructActivityName(methodName string, options RegisterActivityOptions) string {
+	structPrefix := options.Name
+	registerName := methodName
+
+	if options.EnableShortName || len(options.Namespace) > 0 {
+		registerName = getShortFunctionName(methodName)
+	}
+	if len(structPrefix) > 0 {
+		registerName = structPrefix + getShortFunctionName(methodName)
+	}
+	return registerName
+}
+
+// RegisterActivityFactory registers the exported methods of the structure created by factory as activities.
+// The factory is called for every activity execution with the activity context, its signature is one of
+//
+//	func(ctx context.Context) *Activities
+//	func(ctx context.Context) (*Activities, error)
+//	func(ctx context.Context) (*Activities, func(), error)
+//
+// The func() result, if not nil, is called once the activity method returns to release what the factory acquired.
+//
+// The name in options is a prefix of the activity names like for RegisterActivityWithOptions with a structure.
+func (r *registry) RegisterActivityFactory(factory interface{}, options RegisterActivityOptions) {
+	if err := r.registerActivityFactory(factory, options); err != nil {
+		panic(err)
+	}
+}
+
+func (r *registry) registerActivityFactory(factory interface{}, options RegisterActivityOptions) error {
+	factoryType := reflect.TypeOf(factory)
+	if err := validateActivityFactory(factoryType); err != nil {
+		return fmt.Errorf("failed to register activity factory: %v", err)
+	}
+	structType := factoryType.Out(0)
+	activityFactory := &activityFactory{factory: reflect.ValueOf(factory)}
+	// Method values of a zero structure carry the signatures of the activities, while executions call the methods
+	// of the structure returned by the factory.
+	zeroValue := reflect.New(structType.Elem())
+
+	r.Lock()
+	defer r.Unlock()
+
+	count := 0
+	for i := 0; i < structType.NumMethod(); i++ {
+		method := structType.Method(i)
+		// skip private method
+		if method.PkgPath != "" {
+			continue
+		}
+		methodName := getFunctionName(method.Func.Interface())
+		fn := zeroValue.Method(i).Interface()
+		if err := validateFnFormat(reflect.TypeOf(fn), false); err != nil {
+			return fmt.Errorf("failed to register activity method %v of %v: %v", methodName, structType.Elem().Name(), err)
+		}
+		registerName := r.qualifyActivityName(getStructActivityName(methodName, options), options)
 
 		if !options.DisableAlreadyRegisteredCheck {
 			if _, ok := r.getActivityNoLock(registerName); ok {
 				return fmt.Errorf("activity type \"%v\" is already registered", registerName)
 			}
 		}
-		r.activityFuncMap[registerName] = &activityExecutor{registerName, methodValue.Interface(), options}
-		if len(structPrefix) > 0 || options.EnableShortName {
+		r.activityFuncMap[registerName] = &activityExecutor{
+			name:    registerName,
+			fn:      fn,
+			options: options,
+			factory: activityFactory,
+			method:  i,
+		}
+		if registerName != methodName {
 			r.activityAliasMap[methodName] = registerName
 		}
+		r.registeredActivities = nil
 		count++
 	}
 
 	if count == 0 {
-		return fmt.Errorf("no activities (public methods) found in %v structure", structType.Name())
+		return fmt.Errorf("no activities (public methods) found in %v structure", structType.Elem().Name())
 	}
+	return nil
+}
 
+func validateActivityFactory(factoryType reflect.Type) error {
+	if factoryType == nil || factoryType.Kind() != reflect.Func {
+		return fmt.Errorf("expected a func as input but was %v", factoryType)
+	}
+	if factoryType.NumIn() != 1 || !isActivityContext(factoryType.In(0)) {
+		return fmt.Errorf("expected a context.Context as the only input but was %v", factoryType)
+	}
+	if factoryType.NumOut() < 1 || factoryType.NumOut() > 3 {
+		return fmt.Errorf("expected a pointer to a structure, optionally a release func and an error as result but was %v", factoryType)
+	}
+	if out := factoryType.Out(0); out.Kind() != reflect.Ptr || out.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to a structure as first result but was %v", out)
+	}
+	if factoryType.NumOut() > 1 && !isError(factoryType.Out(factoryType.NumOut()-1)) {
+		return fmt.Errorf("expected an error as last result but was %v", factoryType.Out(factoryType.NumOut()-1))
+	}
+	if factoryType.NumOut() == 3 && factoryType.Out(1) != reflect.TypeOf(func() {}) {
+		return fmt.Errorf("expected a func() as second result but was %v", factoryType.Out(1))
+	}
 	return nil
 }
 
+// RegisterWorkflowStruct registers every exported method of the structure w points to as a workflow, see
+// registryStructTagName for the options set through struct tags.
+func (r *registry) RegisterWorkflowStruct(w interface{}) {
+	structValue, tags, err := parseRegistrationStruct(w)
+	if err != nil {
+		panic(fmt.Errorf("failed to register workflow struct: %v", err))
+	}
+	structType := structValue.Type()
+	count := 0
+	for i := 0; i < structValue.NumMethod(); i++ {
+		method := structType.Method(i)
+		options := tags.methods[method.Name]
+		if method.PkgPath != "" || options.skip {
+			continue
+		}
+		fn := structValue.Method(i).Interface()
+		if err := validateFnFormat(reflect.TypeOf(fn), true); err != nil {
+			panic(fmt.Errorf("failed to register workflow method %v of %v: %v", method.Name, structType, err))
+		}
+		// method values created through reflection have no name of their own, so they resolve by the method expression
+		r.registerWorkflow(fn, getFunctionName(method.Func.Interface()), RegisterWorkflowOptions{
+			Name:                            tags.registerName(method.Name),
+			DecisionTaskStartToCloseTimeout: options.decisionTaskTimeout,
+		})
+		count++
+	}
+	if count == 0 {
+		panic(fmt.Errorf("no workflows (public methods) found in %v structure", structType))
+	}
+}
+
+// RegisterActivityStruct registers every exported method of the structure a points to as an activity, see
+// registryStructTagName for the options set through struct tags.
+func (r *registry) RegisterActivityStruct(a interface{}) {
+	structValue, tags, err := parseRegistrationStruct(a)
+	if err != nil {
+		panic(fmt.Errorf("failed to register activity struct: %v", err))
+	}
+	structType := structValue.Type()
+	count := 0
+	for i := 0; i < structValue.NumMethod(); i++ {
+		method := structType.Method(i)
+		options := tags.methods[method.Name]
+		if method.PkgPath != "" || options.skip {
+			continue
+		}
+		fn := structValue.Method(i).Interface()
+		if err := validateFnFormat(reflect.TypeOf(fn), false); err != nil {
+			panic(fmt.Errorf("failed to register activity method %v of %v: %v", method.Name, structType, err))
+		}
+		err := r.registerActivity(fn, getFunctionName(method.Func.Interface()), RegisterActivityOptions{
+			Name:                tags.registerName(method.Name),
+			Namespace:           tags.namespace,
+			EnableAutoHeartbeat: options.autoHeartbeat,
+		})
+		if err != nil {
+			panic(err)
+		}
+		count++
+	}
+	if count == 0 {
+		panic(fmt.Errorf("no activities (public methods) found in %v structure", structType))
+	}
+}
+
+// registryStructTagName is the key of the struct tags configuring RegisterWorkflowStruct and
+// RegisterActivityStruct. The tags are set on fields of the struct, usually blank ones, as a comma separated list:
+//
+//	type OrderActivities struct {
+//		_ struct{} `cadence:"prefix=Order"`
+//		_ struct{} `cadence:"method=Charge,name=ChargeCard,autoHeartbeat"`
+//		_ struct{} `cadence:"method=Close,skip"`
+//	}
+//
+// Options for the whole struct:
+//   - prefix=<prefix>: prepended to the method names, the type names default to the method names
+//   - namespace=<namespace>: the RegisterActivityOptions.Namespace of the activities
+//
+// Options for the method of the same tag:
+//   - name=<name>: the type name of the method, the prefix is not applied
+//   - skip: the method is not registered
+//   - autoHeartbeat: the RegisterActivityOptions.EnableAutoHeartbeat of the activity
+//   - decisionTaskTimeout=<duration>: the RegisterWorkflowOptions.DecisionTaskStartToCloseTimeout of the workflow
+const registryStructTagName = "cadence"
+
+type (
+	registrationStructTags struct {
+		prefix    string
+		namespace string
+		methods   map[string]registrationMethodOptions
+	}
+
+	registrationMethodOptions struct {
+		name                string
+		skip                bool
+		autoHeartbeat       bool
+		decisionTaskTimeout time.Duration
+	}
+)
+
+func (t registrationStructTags) registerName(methodName string) string {
+	if name := t.methods[methodName].name; len(name) > 0 {
+		return name
+	}
+	return t.prefix + methodName
+}
+
+func parseRegistrationStruct(i interface{}) (reflect.Value, registrationStructTags, error) {
+	tags := registrationStructTags{methods: make(map[string]registrationMethodOptions)}
+	structValue := reflect.ValueOf(i)
+	structType := structValue.Type()
+	if structType.Kind() != reflect.Ptr || structType.Elem().Kind() != reflect.Struct {
+		return structValue, tags, fmt.Errorf("pointer to a structure is required, got %v", structType)
+	}
+
+	for i := 0; i < structType.Elem().NumField(); i++ {
+		tag, ok := structType.Elem().Field(i).Tag.Lookup(registryStructTagName)
+		if !ok {
+			continue
+		}
+		var method string
+		var options registrationMethodOptions
+		for _, item := range strings.Split(tag, ",") {
+			key, value := strings.TrimSpace(item), ""
+			if idx := strings.Index(key, "="); idx >= 0 {
+				key, value = key[:idx], key[idx+1:]
+			}
+			switch key {
+			case "prefix":
+				tags.prefix = value
+			case "namespace":
+				tags.namespace = value
+			case "method":
+				method = value
+			case "name":
+				options.name = value
+			case "skip":
+				options.skip = true
+			case "autoHeartbeat":
+				options.autoHeartbeat = true
+			case "decisionTaskTimeout":
+				timeout, err := time.ParseDuration(value)
+				if err != nil {
+					return structValue, tags, fmt.Errorf("invalid decisionTaskTimeout in tag %q: %v", tag, err)
+				}
+				options.decisionTaskTimeout = timeout
+			default:
+				return structValue, tags, fmt.Errorf("unknown option %q in tag %q", key, tag)
+			}
+		}
+		if method == "" {
+			if options != (registrationMethodOptions{}) {
+				return structValue, tags, fmt.Errorf("method options without method in tag %q", tag)
+			}
+			continue
+		}
+		if _, ok := structType.MethodByName(method); !ok {
+			return structValue, tags, fmt.Errorf("unknown method %v in tag %q", method, tag)
+		}
+		tags.methods[method] = options
+	}
+	return structValue, tags, nil
+}
+
 func getShortFunctionName(fnName string) string {
 	elements := strings.Split(fnName, ".")
 	return elements[len(elements)-1]
@@ -235,6 +559,40 @@ func (r *registry) getWorkflowFn(fnName string) (interface{}, bool) {
 	return fn, ok
 }
 
+// getWorkflowDecisionTaskTimeout returns the DecisionTaskStartToCloseTimeout the workflow type was registered with
+func (r *registry) getWorkflowDecisionTaskTimeout(workflowType string) (time.Duration, bool) {
+	r.Lock() // do not defer for Unlock to call next.getWorkflowDecisionTaskTimeout without lock
+	timeout, ok := r.workflowDecisionTaskTimeouts[workflowType]
+	if !ok && r.next != nil {
+		r.Unlock()
+		return r.next.getWorkflowDecisionTaskTimeout(workflowType)
+	}
+	r.Unlock()
+	return timeout, ok
+}
+
+// getWorkflowOptions returns the options the workflow type was registered with
+func (r *registry) getWorkflowOptions(workflowType string) (RegisterWorkflowOptions, bool) {
+	r.Lock() // do not defer for Unlock to call next.getWorkflowOptions without lock
+	options, ok := r.workflowOptionsMap[workflowType]
+	if !ok && r.next != nil {
+		r.Unlock()
+		return r.next.getWorkflowOptions(workflowType)
+	}
+	r.Unlock()
+	return options, ok
+}
+
+// hasWorkflowType tells whether the workflow type resolves to a registered workflow, like in getWorkflowDefinition
+func (r *registry) hasWorkflowType(workflowType string) bool {
+	lookup := workflowType
+	if alias, ok := r.getWorkflowAlias(lookup); ok {
+		lookup = alias
+	}
+	_, ok := r.getWorkflowFn(lookup)
+	return ok
+}
+
 func (r *registry) getWorkflowNoLock(registerName string) (interface{}, bool) {
 	a, ok := r.workflowFuncMap[registerName]
 	if !ok && r.next != nil {
@@ -257,6 +615,17 @@ func (r *registry) getRegisteredWorkflowTypes() []string {
 	return result
 }
 
+// qualifyActivityName prepends the namespace to the activity type name, then applies the name mapper
+func (r *registry) qualifyActivityName(name string, options RegisterActivityOptions) string {
+	if len(options.Namespace) > 0 {
+		name = options.Namespace + "." + name
+	}
+	if r.activityNameMapper != nil {
+		name = r.activityNameMapper(name)
+	}
+	return name
+}
+
 func (r *registry) getActivityAlias(fnName string) (string, bool) {
 	r.Lock() // do not defer for Unlock to call next.getActivityAlias without lock
 	alias, ok := r.activityAliasMap[fnName]
@@ -273,6 +642,7 @@ func (r *registry) addActivityWithLock(fnName string, a activity) {
 	r.Lock()
 	defer r.Unlock()
 	r.activityFuncMap[fnName] = a
+	r.registeredActivities = nil
 }
 
 func (r *registry) GetActivity(fnName string) (activity, bool) {
@@ -324,3 +694,81 @@ func (r *registry) getWorkflowDefinition(wt WorkflowType) (workflowDefinition, e
 	wd := &workflowExecutor{workflowType: lookup, fn: wf}
 	return newSyncWorkflowDefinition(wd), nil
 }
+
+// getRegisteredWorkflows returns the metadata of the workflow types registered in the registry and the registries it
+// is chained to, sorted by name.
+func (r *registry) getRegisteredWorkflows() []RegisteredWorkflowInfo {
+	r.Lock() // do not defer for Unlock to call next.getRegisteredWorkflows without lock
+	if r.registeredWorkflows == nil {
+		r.registeredWorkflows = make([]RegisteredWorkflowInfo, 0, len(r.workflowFuncMap))
+		for name, fn := range r.workflowFuncMap {
+			r.registeredWorkflows = append(r.registeredWorkflows, RegisteredWorkflowInfo{
+				Name:         name,
+				Aliases:      getRegisteredAliases(r.workflowAliasMap, name),
+				FunctionType: reflect.TypeOf(fn),
+				Options:      r.workflowOptionsMap[name],
+			})
+		}
+	}
+	result := append([]RegisteredWorkflowInfo(nil), r.registeredWorkflows...)
+	r.Unlock()
+	if r.next != nil {
+		// the types of this registry shadow the ones of the same name in the registries it is chained to
+		names := make(map[string]bool, len(result))
+		for _, info := range result {
+			names[info.Name] = true
+		}
+		for _, info := range r.next.getRegisteredWorkflows() {
+			if !names[info.Name] {
+				result = append(result, info)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// getRegisteredActivityInfos returns the metadata of the activity types registered in the registry and the
+// registries it is chained to, sorted by name.
+func (r *registry) getRegisteredActivityInfos() []RegisteredActivityInfo {
+	r.Lock() // do not defer for Unlock to call next.getRegisteredActivityInfos without lock
+	if r.registeredActivities == nil {
+		r.registeredActivities = make([]RegisteredActivityInfo, 0, len(r.activityFuncMap))
+		for name, a := range r.activityFuncMap {
+			r.registeredActivities = append(r.registeredActivities, RegisteredActivityInfo{
+				Name:         name,
+				Aliases:      getRegisteredAliases(r.activityAliasMap, name),
+				FunctionType: reflect.TypeOf(a.GetFunction()),
+				Options:      a.GetOptions(),
+			})
+		}
+	}
+	result := append([]RegisteredActivityInfo(nil), r.registeredActivities...)
+	r.Unlock()
+	if r.next != nil {
+		// the types of this registry shadow the ones of the same name in the registries it is chained to
+		names := make(map[string]bool, len(result))
+		for _, info := range result {
+			names[info.Name] = true
+		}
+		for _, info := range r.next.getRegisteredActivityInfos() {
+			if !names[info.Name] {
+				result = append(result, info)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// getRegisteredAliases returns the sorted function names of aliasMap resolving to registerName
+func getRegisteredAliases(aliasMap map[string]string, registerName string) []string {
+	var aliases []string
+	for fnName, name := range aliasMap {
+		if name == registerName {
+			aliases = append(aliases, fnName)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
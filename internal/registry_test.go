@@ -21,7 +21,11 @@
 package internal
 
 import (
+	"context"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -188,6 +192,58 @@ func TestActivityRegistration(t *testing.T) {
 			resolveByFunction: (&testActivityStruct{}).Method,
 			resolveByAlias:    "prefix.Method",
 		},
+		{
+			msg: "register activity function with a namespace",
+			register: func(r *registry) {
+				r.RegisterActivityWithOptions(testActivityFunction, RegisterActivityOptions{Namespace: "service"})
+			},
+			activityType:      "service.testActivityFunction",
+			resolveByFunction: testActivityFunction,
+		},
+		{
+			msg: "register activity function with a namespace and an alias",
+			register: func(r *registry) {
+				r.RegisterActivityWithOptions(testActivityFunction, RegisterActivityOptions{Namespace: "service", Name: "alias"})
+			},
+			activityType:      "service.alias",
+			resolveByFunction: testActivityFunction,
+		},
+		{
+			msg: "register activity struct with a namespace",
+			register: func(r *registry) {
+				r.RegisterActivityWithOptions(&testActivityStruct{}, RegisterActivityOptions{Namespace: "service"})
+			},
+			activityType:      "service.Method",
+			resolveByFunction: (&testActivityStruct{}).Method,
+		},
+		{
+			msg: "register activity struct with a namespace and a prefix",
+			register: func(r *registry) {
+				r.RegisterActivityWithOptions(&testActivityStruct{}, RegisterActivityOptions{Namespace: "service", Name: "prefix_"})
+			},
+			activityType:      "service.prefix_Method",
+			resolveByFunction: (&testActivityStruct{}).Method,
+		},
+		{
+			msg: "register activity function with a name mapper",
+			register: func(r *registry) {
+				r.activityNameMapper = func(activityType string) string {
+					return strings.Replace(activityType, "go.uber.org/cadence/internal.", "mapped.", 1)
+				}
+				r.RegisterActivity(testActivityFunction)
+			},
+			activityType:      "mapped.testActivityFunction",
+			resolveByFunction: testActivityFunction,
+		},
+		{
+			msg: "register activity struct with a namespace and a name mapper",
+			register: func(r *registry) {
+				r.activityNameMapper = strings.ToUpper
+				r.RegisterActivityWithOptions(&testActivityStruct{}, RegisterActivityOptions{Namespace: "service"})
+			},
+			activityType:      "SERVICE.METHOD",
+			resolveByFunction: (&testActivityStruct{}).Method,
+		},
 		{
 			msg: "register duplicated activity function in one registry (should panic)",
 			register: func(r *registry) {
@@ -260,3 +316,143 @@ func (ts *testActivityStruct) Method() error            { return nil }
 
 func testActivityFunction() error            { return nil }
 func testWorkflowFunction(ctx Context) error { return nil }
+
+func TestWorkflowStructRegistration(t *testing.T) {
+	r := newRegistry()
+	w := &testTaggedWorkflowStruct{}
+	r.RegisterWorkflowStruct(w)
+
+	var workflowTypes []string
+	for workflowType := range r.workflowFuncMap {
+		workflowTypes = append(workflowTypes, workflowType)
+	}
+	require.ElementsMatch(t, []string{"OrderProcess2", "ProcessOrder"}, workflowTypes)
+	require.Equal(t, "ProcessOrder", getWorkflowFunctionName(r, w.Process))
+	require.Equal(t, "OrderProcess2", getWorkflowFunctionName(r, w.Process2))
+	timeout, ok := r.getWorkflowDecisionTaskTimeout("ProcessOrder")
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, timeout)
+	_, ok = r.getWorkflowDecisionTaskTimeout("OrderProcess2")
+	require.False(t, ok)
+
+	require.Panics(t, func() { r.RegisterWorkflowStruct(w) }, "duplicated registration")
+	require.Panics(t, func() { newRegistry().RegisterWorkflowStruct(testTaggedWorkflowStruct{}) }, "not a pointer")
+	require.Panics(t, func() { newRegistry().RegisterWorkflowStruct(&testActivityStruct{}) }, "invalid workflow method")
+	require.Panics(t, func() { newRegistry().RegisterWorkflowStruct(&struct{}{}) }, "no methods")
+	require.Panics(t, func() { newRegistry().RegisterWorkflowStruct(&testInvalidTagStruct{}) }, "unknown method in tag")
+}
+
+func TestActivityStructRegistration(t *testing.T) {
+	r := newRegistry()
+	a := &testTaggedActivityStruct{}
+	r.RegisterActivityStruct(a)
+
+	var activityTypes []string
+	for activityType := range r.activityFuncMap {
+		activityTypes = append(activityTypes, activityType)
+	}
+	require.ElementsMatch(t, []string{"orders.OrderCharge", "orders.Refund"}, activityTypes)
+	require.Equal(t, "orders.OrderCharge", getActivityFunctionName(r, a.Charge))
+	require.Equal(t, "orders.Refund", getActivityFunctionName(r, a.Refund2))
+
+	activity, ok := r.GetActivity("orders.Refund")
+	require.True(t, ok)
+	require.True(t, activity.GetOptions().EnableAutoHeartbeat)
+	activity, ok = r.GetActivity("orders.OrderCharge")
+	require.True(t, ok)
+	require.False(t, activity.GetOptions().EnableAutoHeartbeat)
+
+	require.Panics(t, func() { newRegistry().RegisterActivityStruct(func() error { return nil }) }, "not a pointer")
+	require.Panics(t, func() { newRegistry().RegisterActivityStruct(&testInvalidTagStruct{}) }, "unknown method in tag")
+}
+
+type testTaggedWorkflowStruct struct {
+	_ struct{} `cadence:"prefix=Order"`
+	_ struct{} `cadence:"method=Process,name=ProcessOrder,decisionTaskTimeout=30s"`
+	_ struct{} `cadence:"method=Skipped,skip"`
+}
+
+func (ts *testTaggedWorkflowStruct) Process(ctx Context) error  { return nil }
+func (ts *testTaggedWorkflowStruct) Process2(ctx Context) error { return nil }
+func (ts *testTaggedWorkflowStruct) Skipped()                   {}
+
+type testTaggedActivityStruct struct {
+	_ struct{} `cadence:"prefix=Order,namespace=orders"`
+	_ struct{} `cadence:"method=Refund2,name=Refund,autoHeartbeat"`
+	_ struct{} `cadence:"method=Close,skip"`
+}
+
+func (ts *testTaggedActivityStruct) Charge() error  { return nil }
+func (ts *testTaggedActivityStruct) Refund2() error { return nil }
+func (ts *testTaggedActivityStruct) Close()         {}
+
+type testInvalidTagStruct struct {
+	_ struct{} `cadence:"method=Missing,skip"`
+}
+
+func (ts *testInvalidTagStruct) Method(ctx Context) error { return nil }
+
+func TestActivityFactoryRegistration(t *testing.T) {
+	r := newRegistry()
+	r.RegisterActivityFactory(func(ctx context.Context) *testActivityStruct { return &testActivityStruct{} }, RegisterActivityOptions{Name: "prefix_"})
+	a := &testActivityStruct{}
+	require.Equal(t, "prefix_Method", getActivityFunctionName(r, a.Method))
+	_, ok := r.GetActivity("prefix_Method")
+	require.True(t, ok)
+
+	require.Panics(t, func() {
+		newRegistry().RegisterActivityFactory(func() *testActivityStruct { return nil }, RegisterActivityOptions{})
+	}, "missing context")
+	require.Panics(t, func() {
+		newRegistry().RegisterActivityFactory(func(ctx context.Context) testActivityStruct { return testActivityStruct{} }, RegisterActivityOptions{})
+	}, "not a pointer")
+	require.Panics(t, func() {
+		newRegistry().RegisterActivityFactory(func(ctx context.Context) (*testActivityStruct, string) { return nil, "" }, RegisterActivityOptions{})
+	}, "second result not an error")
+	require.Panics(t, func() {
+		newRegistry().RegisterActivityFactory(func(ctx context.Context) (*testActivityStruct, func() error, error) { return nil, nil, nil }, RegisterActivityOptions{})
+	}, "second result not a release func")
+	require.Panics(t, func() {
+		newRegistry().RegisterActivityFactory(func(ctx context.Context) *struct{} { return nil }, RegisterActivityOptions{})
+	}, "no methods")
+	require.NotPanics(t, func() {
+		newRegistry().RegisterActivityFactory(func(ctx context.Context) (*testActivityStruct, func(), error) { return nil, nil, nil }, RegisterActivityOptions{})
+	}, "release func")
+}
+
+func TestGetRegisteredWorkflowsAndActivities(t *testing.T) {
+	parent := newRegistry()
+	parent.next = nil
+	parent.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{Name: "parent.workflow"})
+	parent.RegisterActivityWithOptions(testActivityFunction, RegisterActivityOptions{Name: "parent.activity"})
+	r := newRegistry()
+	r.next = parent
+	r.RegisterWorkflowWithOptions(testWorkflowFunction, RegisterWorkflowOptions{
+		Name:                            "workflow",
+		DecisionTaskStartToCloseTimeout: time.Minute,
+	})
+	r.RegisterActivityStruct(&testTaggedActivityStruct{})
+
+	workflows := r.getRegisteredWorkflows()
+	require.Len(t, workflows, 2)
+	require.Equal(t, "parent.workflow", workflows[0].Name)
+	require.Equal(t, "workflow", workflows[1].Name)
+	require.Equal(t, []string{"go.uber.org/cadence/internal.testWorkflowFunction"}, workflows[1].Aliases)
+	require.Equal(t, reflect.TypeOf(testWorkflowFunction), workflows[1].FunctionType)
+	require.Equal(t, time.Minute, workflows[1].Options.DecisionTaskStartToCloseTimeout)
+
+	activities := r.getRegisteredActivityInfos()
+	require.Len(t, activities, 3)
+	require.Equal(t, "orders.OrderCharge", activities[0].Name)
+	require.Equal(t, "orders.Refund", activities[1].Name)
+	require.Equal(t, "parent.activity", activities[2].Name)
+	require.Equal(t, []string{"go.uber.org/cadence/internal.(*testTaggedActivityStruct).Refund2"}, activities[1].Aliases)
+	require.Equal(t, reflect.TypeOf(func() error { return nil }), activities[1].FunctionType)
+	require.True(t, activities[1].Options.EnableAutoHeartbeat)
+
+	// the memoized metadata is reset by registrations
+	r.RegisterActivityWithOptions(testActivityFunction, RegisterActivityOptions{Name: "activity"})
+	activities = r.getRegisteredActivityInfos()
+	require.Len(t, activities, 4)
+	require.Equal(t, "activity", activities[0].Name)
+}
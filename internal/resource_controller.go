@@ -0,0 +1,139 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally/v4"
+	"go.uber.org/zap"
+
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+const defaultResourceCheckInterval = time.Second
+
+type (
+	// SystemResourceProvider reports the resource usage of the host a worker is running on.
+	SystemResourceProvider interface {
+		// CPUUsage returns the CPU usage of the host as a fraction between 0 and 1.
+		CPUUsage() (float64, error)
+		// MemoryUsage returns the memory usage of the host as a fraction between 0 and 1.
+		MemoryUsage() (float64, error)
+	}
+
+	// ResourceControllerOptions configures an activity worker to stop picking up new activity tasks while the
+	// resource usage of the host is above the thresholds. Activity tasks already running are not affected, polling
+	// resumes once the usage drops below the thresholds again.
+	ResourceControllerOptions struct {
+		// Required: Reports the resource usage of the host. Polling is never suppressed when it returns an error.
+		Provider SystemResourceProvider
+
+		// Optional: CPU usage, as a fraction between 0 and 1, above which polling is suppressed.
+		// default: 0, which ignores the CPU usage
+		MaxCPUUsage float64
+
+		// Optional: Memory usage, as a fraction between 0 and 1, above which polling is suppressed.
+		// default: 0, which ignores the memory usage
+		MaxMemoryUsage float64
+
+		// Optional: How often the resource usage is checked.
+		// default: 1s
+		CheckInterval time.Duration
+	}
+
+	// resourceController suppresses polling while the host resource usage is above the thresholds. The usage is
+	// shared by all pollers of a worker and refreshed at most once per check interval.
+	resourceController struct {
+		options      ResourceControllerOptions
+		logger       *zap.Logger
+		metricsScope tally.Scope
+
+		sync.Mutex
+		lastCheck  time.Time
+		overloaded bool
+	}
+)
+
+// newResourceController returns nil when no provider is configured.
+func newResourceController(options ResourceControllerOptions, logger *zap.Logger, metricsScope tally.Scope) *resourceController {
+	if options.Provider == nil {
+		return nil
+	}
+	if options.CheckInterval <= 0 {
+		options.CheckInterval = defaultResourceCheckInterval
+	}
+	return &resourceController{
+		options:      options,
+		logger:       logger,
+		metricsScope: metricsScope,
+	}
+}
+
+// waitForResources blocks while the host is overloaded. It returns false if shutdownCh is closed while waiting.
+func (rc *resourceController) waitForResources(shutdownCh <-chan struct{}) bool {
+	for rc.isOverloaded() {
+		rc.metricsScope.Counter(metrics.ActivityPollSuppressedCounter).Inc(1)
+		select {
+		case <-shutdownCh:
+			return false
+		case <-time.After(rc.options.CheckInterval):
+		}
+	}
+	return true
+}
+
+func (rc *resourceController) isOverloaded() bool {
+	rc.Lock()
+	defer rc.Unlock()
+	if time.Since(rc.lastCheck) < rc.options.CheckInterval {
+		return rc.overloaded
+	}
+	rc.lastCheck = time.Now()
+
+	overloaded := false
+	if rc.options.MaxCPUUsage > 0 {
+		usage, err := rc.options.Provider.CPUUsage()
+		if err != nil {
+			rc.logger.Warn("Failed to get CPU usage.", zap.Error(err))
+		} else if usage > rc.options.MaxCPUUsage {
+			overloaded = true
+		}
+	}
+	if rc.options.MaxMemoryUsage > 0 {
+		usage, err := rc.options.Provider.MemoryUsage()
+		if err != nil {
+			rc.logger.Warn("Failed to get memory usage.", zap.Error(err))
+		} else if usage > rc.options.MaxMemoryUsage {
+			overloaded = true
+		}
+	}
+	if overloaded != rc.overloaded {
+		if overloaded {
+			rc.logger.Warn("Host resource usage is above the thresholds, suppressing polling for activity tasks.")
+		} else {
+			rc.logger.Info("Host resource usage is back below the thresholds, resuming polling for activity tasks.")
+		}
+	}
+	rc.overloaded = overloaded
+	return overloaded
+}
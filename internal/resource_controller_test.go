@@ -0,0 +1,111 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally/v4"
+	"go.uber.org/zap"
+
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+type fakeResourceProvider struct {
+	sync.Mutex
+	cpu, memory float64
+	err         error
+}
+
+func (p *fakeResourceProvider) CPUUsage() (float64, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.cpu, p.err
+}
+
+func (p *fakeResourceProvider) MemoryUsage() (float64, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.memory, p.err
+}
+
+func (p *fakeResourceProvider) set(cpu, memory float64, err error) {
+	p.Lock()
+	defer p.Unlock()
+	p.cpu, p.memory, p.err = cpu, memory, err
+}
+
+func TestResourceController(t *testing.T) {
+	assert.Nil(t, newResourceController(ResourceControllerOptions{MaxMemoryUsage: 0.5}, zap.NewNop(), tally.NoopScope))
+
+	provider := &fakeResourceProvider{}
+	testScope := tally.NewTestScope("", nil)
+	rc := newResourceController(ResourceControllerOptions{
+		Provider:       provider,
+		MaxCPUUsage:    0.9,
+		MaxMemoryUsage: 0.8,
+		CheckInterval:  time.Millisecond,
+	}, zap.NewNop(), testScope)
+
+	for _, tc := range []struct {
+		name        string
+		cpu, memory float64
+		err         error
+		overloaded  bool
+	}{
+		{name: "below thresholds", cpu: 0.5, memory: 0.5},
+		{name: "cpu above threshold", cpu: 0.95, memory: 0.5, overloaded: true},
+		{name: "memory above threshold", cpu: 0.5, memory: 0.85, overloaded: true},
+		{name: "provider error", cpu: 1, memory: 1, err: errors.New("unavailable")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider.set(tc.cpu, tc.memory, tc.err)
+			time.Sleep(2 * time.Millisecond)
+			assert.Equal(t, tc.overloaded, rc.isOverloaded())
+		})
+	}
+
+	// polling resumes once the usage drops
+	provider.set(0.5, 0.85, nil)
+	time.Sleep(2 * time.Millisecond)
+	resumed := make(chan bool)
+	go func() { resumed <- rc.waitForResources(make(chan struct{})) }()
+	time.Sleep(10 * time.Millisecond)
+	provider.set(0.5, 0.5, nil)
+	select {
+	case ok := <-resumed:
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("polling was not resumed")
+	}
+	assert.True(t, testScope.Snapshot().Counters()[metrics.ActivityPollSuppressedCounter+"+"].Value() > 0)
+
+	// waiting stops on shutdown
+	provider.set(0.5, 0.85, nil)
+	time.Sleep(2 * time.Millisecond)
+	shutdownCh := make(chan struct{})
+	close(shutdownCh)
+	assert.False(t, rc.waitForResources(shutdownCh))
+}
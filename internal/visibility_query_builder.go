@@ -0,0 +1,247 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// VisibilityQueryBuilder builds advanced visibility queries for ListWorkflow, ScanWorkflow and CountWorkflow
+	// from typed predicates. Keys are validated and values are quoted and escaped, so the built query is
+	// syntactically valid whatever the values are. Predicates are joined with "and".
+	//
+	// Supported value types are string, WorkflowStatus, bool, all integer and float types and time.Time, which is
+	// encoded as unix nanoseconds like the StartTime and CloseTime system search attributes.
+	VisibilityQueryBuilder struct {
+		predicates []string
+		orderBy    []string
+		err        error
+	}
+)
+
+var visibilityQueryKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// NewVisibilityQueryBuilder creates a new, empty VisibilityQueryBuilder
+func NewVisibilityQueryBuilder() *VisibilityQueryBuilder {
+	return &VisibilityQueryBuilder{}
+}
+
+// Eq adds a `key = value` predicate.
+func (b *VisibilityQueryBuilder) Eq(key string, value interface{}) *VisibilityQueryBuilder {
+	return b.compare(key, "=", value)
+}
+
+// NotEq adds a `key != value` predicate.
+func (b *VisibilityQueryBuilder) NotEq(key string, value interface{}) *VisibilityQueryBuilder {
+	return b.compare(key, "!=", value)
+}
+
+// Gt adds a `key > value` predicate.
+func (b *VisibilityQueryBuilder) Gt(key string, value interface{}) *VisibilityQueryBuilder {
+	return b.compare(key, ">", value)
+}
+
+// Gte adds a `key >= value` predicate.
+func (b *VisibilityQueryBuilder) Gte(key string, value interface{}) *VisibilityQueryBuilder {
+	return b.compare(key, ">=", value)
+}
+
+// Lt adds a `key < value` predicate.
+func (b *VisibilityQueryBuilder) Lt(key string, value interface{}) *VisibilityQueryBuilder {
+	return b.compare(key, "<", value)
+}
+
+// Lte adds a `key <= value` predicate.
+func (b *VisibilityQueryBuilder) Lte(key string, value interface{}) *VisibilityQueryBuilder {
+	return b.compare(key, "<=", value)
+}
+
+// Between adds a `key between from and to` predicate, both bounds are inclusive.
+func (b *VisibilityQueryBuilder) Between(key string, from, to interface{}) *VisibilityQueryBuilder {
+	if !b.validKey(key) {
+		return b
+	}
+	fromLiteral, ok := b.literal(key, from)
+	if !ok {
+		return b
+	}
+	toLiteral, ok := b.literal(key, to)
+	if !ok {
+		return b
+	}
+	b.predicates = append(b.predicates, fmt.Sprintf("%v between %v and %v", key, fromLiteral, toLiteral))
+	return b
+}
+
+// In adds a `key in (values...)` predicate. At least one value is required.
+func (b *VisibilityQueryBuilder) In(key string, values ...interface{}) *VisibilityQueryBuilder {
+	if !b.validKey(key) {
+		return b
+	}
+	if len(values) == 0 {
+		b.setErr(fmt.Errorf("no values for in predicate on %v", key))
+		return b
+	}
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		literal, ok := b.literal(key, value)
+		if !ok {
+			return b
+		}
+		literals = append(literals, literal)
+	}
+	b.predicates = append(b.predicates, fmt.Sprintf("%v in (%v)", key, strings.Join(literals, ", ")))
+	return b
+}
+
+// Missing adds a `key = missing` predicate, matching the executions without the search attribute, for example
+// CloseTime is missing for open workflows.
+func (b *VisibilityQueryBuilder) Missing(key string) *VisibilityQueryBuilder {
+	if b.validKey(key) {
+		b.predicates = append(b.predicates, key+" = missing")
+	}
+	return b
+}
+
+// OrderBy sorts the executions by key in ascending order, later calls add secondary sort keys.
+func (b *VisibilityQueryBuilder) OrderBy(key string) *VisibilityQueryBuilder {
+	if b.validKey(key) {
+		b.orderBy = append(b.orderBy, key)
+	}
+	return b
+}
+
+// OrderByDesc sorts the executions by key in descending order, later calls add secondary sort keys.
+func (b *VisibilityQueryBuilder) OrderByDesc(key string) *VisibilityQueryBuilder {
+	if b.validKey(key) {
+		b.orderBy = append(b.orderBy, key+" desc")
+	}
+	return b
+}
+
+// Build returns the query, or the first error of the predicates added to the builder.
+func (b *VisibilityQueryBuilder) Build() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	query := strings.Join(b.predicates, " and ")
+	if len(b.orderBy) != 0 {
+		if len(query) != 0 {
+			query += " "
+		}
+		query += "order by " + strings.Join(b.orderBy, ", ")
+	}
+	return query, nil
+}
+
+func (b *VisibilityQueryBuilder) compare(key, operator string, value interface{}) *VisibilityQueryBuilder {
+	if !b.validKey(key) {
+		return b
+	}
+	if literal, ok := b.literal(key, value); ok {
+		b.predicates = append(b.predicates, fmt.Sprintf("%v %v %v", key, operator, literal))
+	}
+	return b
+}
+
+func (b *VisibilityQueryBuilder) validKey(key string) bool {
+	if !visibilityQueryKeyRegex.MatchString(key) {
+		b.setErr(fmt.Errorf("invalid search attribute key: %q", key))
+		return false
+	}
+	return true
+}
+
+func (b *VisibilityQueryBuilder) literal(key string, value interface{}) (string, bool) {
+	literal, err := visibilityQueryLiteral(value)
+	if err != nil {
+		b.setErr(fmt.Errorf("invalid value for %v: %v", key, err))
+		return "", false
+	}
+	return literal, true
+}
+
+func (b *VisibilityQueryBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func visibilityQueryLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return quoteVisibilityQueryString(v), nil
+	case WorkflowStatus:
+		return quoteVisibilityQueryString(string(v)), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return strconv.FormatInt(v.UnixNano(), 10), nil
+	case nil:
+		return "", errors.New("nil value")
+	default:
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+func quoteVisibilityQueryString(s string) string {
+	var builder strings.Builder
+	builder.Grow(len(s) + 2)
+	builder.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisibilityQueryBuilder(t *testing.T) {
+	startTime := time.Unix(0, 1000)
+	testCases := []struct {
+		msg           string
+		builder       *VisibilityQueryBuilder
+		expectedQuery string
+		expectErr     bool
+	}{
+		{
+			msg:           "empty query",
+			builder:       NewVisibilityQueryBuilder(),
+			expectedQuery: "",
+		},
+		{
+			msg: "predicates and order by",
+			builder: NewVisibilityQueryBuilder().
+				Eq("WorkflowType", "testWorkflowType").
+				In("CloseStatus", WorkflowStatusFailed, WorkflowStatusTimedOut).
+				Between("StartTime", startTime, startTime.Add(time.Microsecond)).
+				Gte("CustomIntField", 3).
+				NotEq("CustomBoolField", true).
+				Lt("CustomDoubleField", 1.5).
+				OrderByDesc("StartTime").
+				OrderBy("WorkflowID"),
+			expectedQuery: `WorkflowType = "testWorkflowType" and CloseStatus in ("FAILED", "TIMED_OUT") and StartTime between 1000 and 2000 and CustomIntField >= 3 and CustomBoolField != true and CustomDoubleField < 1.5 order by StartTime desc, WorkflowID`,
+		},
+		{
+			msg:           "missing",
+			builder:       NewVisibilityQueryBuilder().Missing("CloseTime"),
+			expectedQuery: `CloseTime = missing`,
+		},
+		{
+			msg:           "order by only",
+			builder:       NewVisibilityQueryBuilder().OrderBy("CloseTime"),
+			expectedQuery: `order by CloseTime`,
+		},
+		{
+			msg:           "values are escaped",
+			builder:       NewVisibilityQueryBuilder().Eq("WorkflowID", `id" or WorkflowID != "\`),
+			expectedQuery: `WorkflowID = "id\" or WorkflowID != \"\\"`,
+		},
+		{
+			msg:       "invalid key",
+			builder:   NewVisibilityQueryBuilder().Eq("WorkflowID = 1 or WorkflowID", "id"),
+			expectErr: true,
+		},
+		{
+			msg:       "invalid order by key",
+			builder:   NewVisibilityQueryBuilder().OrderBy("StartTime; drop"),
+			expectErr: true,
+		},
+		{
+			msg:       "unsupported value type",
+			builder:   NewVisibilityQueryBuilder().Eq("CustomKeywordField", []string{"a"}),
+			expectErr: true,
+		},
+		{
+			msg:       "nil value",
+			builder:   NewVisibilityQueryBuilder().Between("StartTime", nil, startTime),
+			expectErr: true,
+		},
+		{
+			msg:       "empty in",
+			builder:   NewVisibilityQueryBuilder().In("WorkflowType"),
+			expectErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.msg, func(t *testing.T) {
+			query, err := test.builder.Build()
+			if test.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedQuery, query)
+		})
+	}
+}
@@ -23,6 +23,7 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -73,6 +74,22 @@ type (
 		// The zero value of this uses the default value. Default: 100k
 		TaskListActivitiesPerSecond float64
 
+		// Optional: Activities scheduled by a decision on the task list of this worker are dispatched eagerly to
+		// the activity worker of this process when it has a free poller: the activity task is returned by the
+		// RespondDecisionTaskCompleted call and executed right away, skipping the round trip through matching.
+		// Only activity types registered on this worker are dispatched eagerly. Eager dispatch is never used when
+		// TaskListActivitiesPerSecond is set, as it would bypass the task list rate limit.
+		// Set this to always dispatch activities through matching.
+		// default: false, which enables eager dispatch
+		DisableEagerActivityDispatch bool
+
+		// Optional: Maps the name of every activity type registered on this worker, after RegisterActivityOptions
+		// are applied. Activity types are recorded in the workflow histories by name, a mapper keeps these names
+		// stable when activities move to another package, e.g. by stripping or replacing the package path.
+		// Workflows executed by this worker resolve activity functions to the mapped names.
+		// default: nil, which keeps the names unchanged
+		ActivityNameMapper func(activityType string) string
+
 		// optional: Sets the maximum number of goroutines that will concurrently poll the
 		// cadence-server to retrieve activity tasks. Changing this value will affect the
 		// rate at which the worker is able to consume tasks from a task list.
@@ -84,6 +101,21 @@ type (
 		// default: defaultMaxConcurrentTaskExecutionSize(1k)
 		MaxConcurrentDecisionTaskExecutionSize int
 
+		// Optional: Sets the maximum number of decision and activity tasks this worker executes concurrently in
+		// total, on top of MaxConcurrentDecisionTaskExecutionSize and MaxConcurrentActivityExecutionSize. A share
+		// of the slots, set by DecisionTaskSlotRatio, is reserved for decision tasks and decision tasks waiting for
+		// a slot are served ahead of activity tasks, so that busy activities can't delay decisions.
+		// A slot is taken once a task is polled, not while polling, so idle pollers don't use slots, and a poller
+		// holding a task waits for a slot before polling again. The session creation activities don't take slots,
+		// sessions are limited by MaxConcurrentSessionExecutionSize.
+		// default: 0, which doesn't share a limit between decision and activity tasks
+		MaxConcurrentTaskExecutionSize int
+
+		// Optional: Sets the share of MaxConcurrentTaskExecutionSize reserved for decision tasks, which can
+		// also use any slot not used by activity tasks. At least one slot is always left to activity tasks.
+		// default: 0.2
+		DecisionTaskSlotRatio float64
+
 		// Optional: Sets the rate limiting on number of decision tasks that can be executed per second per
 		// worker. This can be used to limit resources used by the worker.
 		// The zero value of this uses the default value. Default: 100k
@@ -139,6 +171,15 @@ type (
 		// default: false
 		EnableLoggingInReplay bool
 
+		// Optional: Buffer the metrics recorded by workflow code until the decision task is completed.
+		// Metrics recorded through workflow.GetMetricsScope(ctx) are never emitted while replaying history. Without
+		// buffering they are emitted as soon as they are recorded, so the metrics of a decision task that fails, or
+		// whose completion can't be reported, are emitted again when the next attempt re-executes the same code.
+		// With buffering they are only emitted once the decision task is completed and dropped when it fails, so each
+		// metric is emitted exactly once.
+		// default: false
+		EnableBufferedWorkflowMetrics bool
+
 		// Optional: Disable running workflow workers.
 		// default: false
 		DisableWorkflowWorker bool
@@ -162,6 +203,67 @@ type (
 		// The resolution is seconds. See details about StickyExecution on the comments for DisableStickyExecution.
 		StickyScheduleToStartTimeout time.Duration
 
+		// Optional: Approximate memory budget in bytes of the sticky workflow cache.
+		// default: 0, which bounds the cache by the number of executions only
+		// The size of a cached execution is approximated by the encoded size of the history its state was replayed
+		// from. When the budget is exceeded the largest executions are evicted first, and the least recently used
+		// one among executions of equal size, so a few huge workflows do not evict many small ones. The sticky cache
+		// is shared by all workers of the process, so the budget has to be set on the first worker that is created.
+		StickyCacheMaxBytes int64
+
+		// Optional: Policy choosing which execution is evicted from the sticky workflow cache once it is full.
+		// default: StickyCacheEvictionPolicyLRU
+		// Replay cost differs a lot between workflow types, and evicting the least recently used execution makes a
+		// mixed workload thrash. Like StickyCacheMaxBytes it has to be set on the first worker that is created.
+		StickyCacheEvictionPolicy StickyCacheEvictionPolicy
+
+		// Optional: Time an execution can stay in the sticky workflow cache without being used, with
+		// StickyCacheEvictionPolicyTTL.
+		// default: 10 minutes
+		StickyCacheTTL time.Duration
+
+		// Optional: Maximum number of executions pinned in the sticky workflow cache, see
+		// RegisterWorkflowOptions.PinInStickyCache. Like StickyCacheMaxBytes it has to be set on the first worker
+		// that is created.
+		// default: 1000
+		StickyCacheMaxPinned int
+
+		// Optional: Maximum number of ResetStickyTaskList calls per second the worker issues for the executions
+		// evicted from the sticky cache.
+		// default: 100
+		// Resetting the stickiness of an evicted execution lets its next decision task be dispatched to any worker
+		// right away, instead of after StickyScheduleToStartTimeout. The calls are issued in the background, so that
+		// evicting many executions at once does not cause a spike of requests to the cadence frontend. Resets that
+		// do not fit into the backlog of the worker are dropped.
+		StickyCacheResetsPerSecond float64
+
+		// Optional: Stores the snapshots of the workflows that call SetWorkflowSnapshotter. A worker that does not
+		// have such an execution in its sticky cache resumes it from its last snapshot, instead of replaying its
+		// whole history. The store must be shared by all the workers of the task list.
+		// default: nil, which disables the snapshots
+		// EXPERIMENTAL: the snapshot API may change in future releases.
+		WorkflowSnapshotStore WorkflowSnapshotStore
+
+		// Optional: Minimum number of history events between two snapshots of a workflow, see WorkflowSnapshotStore.
+		// The history of a workflow shorter than this is always replayed.
+		// default: 1000
+		WorkflowSnapshotInterval int64
+
+		// Optional: Answers the queries sent to this worker's sticky task list by replaying the whole history,
+		// instead of using the workflow state cached by sticky execution. Queries are slower, but always see the
+		// state rebuilt by the current workflow code, which matters while workers with different workflow code
+		// versions run side by side.
+		// default: false
+		DisableStickyQuery bool
+
+		// Optional: Number of open workflow executions to load into the sticky cache when the worker starts.
+		// default: 0, which disables the warm-up
+		// When set, the worker fetches the histories of the most recently started open executions of its registered
+		// workflow types on its task list in the background and replays them into the sticky cache. The first decision
+		// task of those executions after a deployment then does not have to replay the whole history, which reduces
+		// decision latency spikes right after the worker starts. The warm-up is skipped if sticky execution is disabled.
+		StickyCacheWarmUpSize int
+
 		// Optional: sets context for activity. The context can be used to pass any configuration to activity
 		// like common logger for all activities.
 		BackgroundActivityContext context.Context
@@ -171,6 +273,96 @@ type (
 		// default: NonDeterministicWorkflowPolicyBlockWorkflow, which just logs error but reply nothing back to server
 		NonDeterministicWorkflowPolicy NonDeterministicWorkflowPolicy
 
+		// Optional: Classifies panics in workflow code. It is called with the recovered panic value and the stack
+		// trace of the panicking workflow goroutine and decides whether the panic fails the workflow or blocks it.
+		// Panics caused by detected non-determinism are handled by NonDeterministicWorkflowPolicy instead.
+		// default: nil, which blocks the workflow on every panic: the decision task fails and is retried until the
+		// workflow code is fixed
+		WorkflowPanicClassifier func(value interface{}, stackTrace string) WorkflowPanicAction
+
+		// Optional: Sets the number of consecutive failed attempts of a decision task, for example because workflow
+		// code keeps panicking, after which OnDecisionTaskFailureThreshold is called and DecisionTaskFailurePolicy
+		// is applied to the workflow.
+		// default: 0, which disables the threshold
+		DecisionTaskFailureThreshold int
+
+		// Optional: Called for every failed attempt of a decision task once DecisionTaskFailureThreshold is reached,
+		// with the execution of the workflow and the error that failed the decision task.
+		OnDecisionTaskFailureThreshold func(execution WorkflowExecution, err error)
+
+		// Optional: Sets the action taken on a workflow once DecisionTaskFailureThreshold is reached. The action is
+		// taken once per run of consecutive failures, by the attempt reaching the threshold, and is given up when the
+		// worker stops.
+		// default: DecisionTaskFailurePolicyRetry, which keeps retrying the decision task
+		DecisionTaskFailurePolicy DecisionTaskFailurePolicy
+
+		// Optional: Logs a warning with the workflow type, the range of history events processed and the duration
+		// whenever executing a decision task takes longer than this. Useful to catch heavy decisions before they
+		// run into the decision task timeout.
+		// default: 0, which disables the logging
+		SlowDecisionTaskThreshold time.Duration
+
+		// Optional: Logs a warning with the workflow type, the activity type and the duration whenever executing an
+		// activity task takes longer than this.
+		// default: 0, which disables the logging
+		SlowActivityThreshold time.Duration
+
+		// Optional: Logs a dump of every failed decision task: the decisions produced so far, the state of all
+		// decision state machines and the history events of the decision task. Useful to debug a failure offline
+		// without reproducing it.
+		// default: false
+		EnableDecisionTaskFailureDump bool
+
+		// Optional: Sets what is logged in place of every payload (inputs, results, details, memo, headers...) in a
+		// decision task failure dump.
+		// default: nil, which logs only the size of the payload
+		DecisionTaskFailureDumpRedactor func(payload []byte) string
+
+		// Optional: Replays the whole history of every decision task a second time, without the sticky cache, and
+		// fails the decision task if the replay does not build the same decisions. Meant for canary workers, to
+		// detect non-deterministic workflow code before it breaks other workers. Every decision task costs a replay
+		// of the whole history, which also runs the side effects of the new events again. The decision tasks that
+		// run local activities are not validated.
+		// default: false
+		EnableDecisionValidation bool
+
+		// Optional: Fetches the next page of the workflow history in the background while the current page is
+		// replayed. Cuts the latency of decision tasks replaying a long history, e.g. after a sticky cache miss, at
+		// the cost of holding one more page in memory.
+		// default: false
+		EnableHistoryPrefetch bool
+
+		// Optional: Sends a second PollForDecisionTask request when the first one didn't return after this delay,
+		// and uses whichever returns a task first. Together with a service client spreading calls over several
+		// frontend hosts (see client.NewFailoverWorkflowService) the requests go to different hosts, which cuts the
+		// tail latency of task dispatch when a host or a region is slow. A task returned by the slower request is
+		// processed by the next poll, it is only dropped by a worker shutting down, in which case the server
+		// dispatches it again after its decision task timeout.
+		// Hedging costs load on the frontend: while the task list is idle every poll holds two long poll requests
+		// open instead of one, doubling the poll requests in the worst case. Only enable it for task lists whose
+		// dispatch latency matters more than that, with a delay well above the usual dispatch latency.
+		// default: 0, which disables hedging
+		DecisionPollHedgingDelay time.Duration
+
+		// Optional: Sets the initial interval of the exponential backoff of the pollers after poll failures such as
+		// network errors. The pollers back off separately when the server is busy, see ServiceBusyBackoffInitialInterval.
+		// default: 20ms
+		PollBackoffInitialInterval time.Duration
+
+		// Optional: Sets the maximum interval of the exponential backoff of the pollers after poll failures.
+		// default: 10s
+		PollBackoffMaxInterval time.Duration
+
+		// Optional: Sets the initial interval of the exponential backoff of the pollers after the server rejected
+		// polls because it is busy or the rate limit of the domain is exceeded. It is longer than for other poll
+		// failures, so that the pollers of the whole fleet back off from the overloaded server.
+		// default: 1s
+		ServiceBusyBackoffInitialInterval time.Duration
+
+		// Optional: Sets the maximum interval of the exponential backoff of the pollers while the server is busy.
+		// default: 30s
+		ServiceBusyBackoffMaxInterval time.Duration
+
 		// Optional: Sets DataConverter to customize serialization/deserialization of arguments in Cadence
 		// default: defaultDataConverter, an combination of thriftEncoder and jsonEncoder
 		DataConverter DataConverter
@@ -195,10 +387,119 @@ type (
 		// default: 1000
 		MaxConcurrentSessionExecutionSize int
 
+		// Optional: Enable this option to allow workflows to pin activities to this worker with
+		// WithHostPinnedActivityOptions, without creating a session. The worker additionally polls an activity
+		// task list specific to its HostID. Activities executed by the worker find the HostID in their
+		// ActivityInfo, so they can advertise it to the workflow, e.g. through their result or heartbeat details.
+		// default: false
+		EnableHostPinnedActivities bool
+
+		// Optional: The ID the activities are pinned to when EnableHostPinnedActivities is set.
+		// Workers using the same HostID share the pinned activities.
+		// default: a unique ID of the worker, prefixed with the host name
+		HostID string
+
 		// Optional: Specifies factories used to instantiate workflow interceptor chain
 		// The chain is instantiated per each replay of a workflow execution
 		WorkflowInterceptorChainFactories []WorkflowInterceptorFactory
 
+		// Optional: Provides the config snapshots read by workflow code through GetConfigValue. The snapshot is
+		// taken once per decision task and recorded in the workflow history as a single marker whenever it differs
+		// from the last recorded one, so that replays read the same values.
+		// default: nil, GetConfigValue records an empty snapshot and returns no value unless a snapshot is already
+		// recorded in the history
+		WorkflowConfigProvider WorkflowConfigProvider
+
+		// Optional: Sets the longest timer started by the workflows. Longer workflow.NewTimer and workflow.Sleep
+		// durations are split into a chain of timers of at most this duration, transparently to the workflow code,
+		// so that they don't exceed the timer limit of the server.
+		// The zero value of this uses the default value.
+		// default: defaultMaxTimerDuration(365 days)
+		MaxTimerDuration time.Duration
+
+		// Optional: Disables splitting long timers, see MaxTimerDuration.
+		// default: false
+		DisableTimerSplitting bool
+
+		// Optional: Sets the activity options of the workflows executed by the worker, used by the activities
+		// executed without calling WithActivityOptions. Options set with WithActivityOptions replace them entirely,
+		// while WithTaskList and WithActivityTaskListResolver only change the task list.
+		// default: nil, activities executed without options fail
+		DefaultActivityOptions *ActivityOptions
+
+		// Optional: Sets the local activity options of the workflows executed by the worker, used by the local
+		// activities executed without calling WithLocalActivityOptions.
+		// default: nil, local activities executed without options fail
+		DefaultLocalActivityOptions *LocalActivityOptions
+
+		// Optional: Sets listeners notified about the workflow executions and activities processed by the worker,
+		// see WorkerEventListener.
+		// default: no listeners
+		EventListeners []WorkerEventListener
+
+		// Optional: Decides whether the worker processes the workflow executions it polls, for example to only
+		// process the tenants assigned to this deployment based on the header the workflows were started with.
+		// The decision tasks of rejected workflow executions are failed and rescheduled by the server, to be picked
+		// up by another worker polling the same task list. They don't count towards DecisionTaskFailureThreshold.
+		// default: nil, which processes all workflow executions
+		WorkflowTaskFilter WorkflowTaskFilter
+
+		// Optional: Is called with the history and the decisions of every decision task completed by the worker,
+		// before the decisions are sent to the server, for example to record a hash of the decisions of every
+		// workflow execution and detect non-deterministic deployments across the fleet. See DecisionTaskInspector.
+		// default: nil
+		DecisionTaskInspector DecisionTaskInspector
+
+		// Optional: Rejects the decision tasks of the workflow types not registered with the worker before
+		// processing them, which is common during partial deployments when workers of different versions poll the
+		// same task list. The rejected decision tasks are failed as unknown workflow types and rescheduled by the
+		// server, they are counted by the unregistered-workflow-type metric tagged with the workflow type and don't
+		// count towards DecisionTaskFailureThreshold.
+		// default: false, the decision tasks fail while being processed like any other failure of the workflow
+		RejectUnregisteredWorkflowTypes bool
+
+		// Optional: With RejectUnregisteredWorkflowTypes, waits for the delay before failing the rejected decision
+		// tasks, without logging them, so that they are requeued by the server instead of bouncing between the
+		// workers missing the workflow type. The decision task slot is held while waiting, and the delay must be
+		// shorter than the decision task timeout of the workflows.
+		// default: 0, the rejected decision tasks are failed right away
+		UnregisteredWorkflowTypeRequeueDelay time.Duration
+
+		// Optional: Stops the worker from picking up new activity tasks while the CPU or memory usage of the host
+		// is above the thresholds, to prevent workers running memory heavy activities from being OOM killed.
+		// default: no Provider, which never suppresses polling
+		ActivityResourceController ResourceControllerOptions
+
+		// Optional: Sets how long the results of activities registered with RegisterActivityOptions.Idempotent are
+		// cached by the worker. A cached result is returned for the executions of an activity type with the same
+		// input instead of running the activity again. Only successful results are cached.
+		// default: 0, which disables the cache
+		ActivityResultCacheTTL time.Duration
+
+		// Optional: Sets the maximum number of activity results cached by the worker, see ActivityResultCacheTTL.
+		// The zero value of this uses the default value.
+		// default: defaultActivityResultCacheSize(1k)
+		ActivityResultCacheSize int
+
+		// Optional: Sets how much earlier than the activity deadline the context passed to the activity function
+		// expires, so that the calls made with the context, e.g. HTTP or database requests, time out while the
+		// activity can still report the failure, instead of the activity being timed out by the server. The activity
+		// deadline is the earlier of its ScheduleToClose and StartToClose deadlines, GetActivityInfo(ctx).Deadline
+		// still returns it. The grace period is not applied when it is longer than the time left to the activity.
+		// default: 0, the context expires at the activity deadline
+		ActivityDeadlineGracePeriod time.Duration
+
+		// Optional: Sets the longest interval the heartbeats recorded by an activity are throttled for. The first
+		// heartbeat is sent right away, the ones recorded within the interval after it are coalesced into a single
+		// heartbeat with the latest details sent at its end. The interval is 80% of the heartbeat timeout.
+		// default: 0, the interval is not capped
+		MaxHeartbeatThrottleInterval time.Duration
+
+		// Optional: Sets the interval the heartbeats of the activities without a heartbeat timeout are throttled for,
+		// see MaxHeartbeatThrottleInterval.
+		// default: 80% of defaultHeartBeatIntervalInSec(10m)
+		DefaultHeartbeatThrottleInterval time.Duration
+
 		// Optional: Sets ContextPropagators that allows users to control the context information passed through a workflow
 		// default: no ContextPropagators
 		ContextPropagators []ContextPropagator
@@ -230,6 +531,104 @@ type (
 		// Optional: Authorization interface to get the Auth Token
 		// default: No provider
 		Authorization auth.AuthorizationProvider
+
+		// Optional: Start the worker without waiting for the domain to be verified.
+		// When set, Worker.Start returns immediately and the domain is verified in the background,
+		// retrying transient failures with backoff. Pollers are only started once the domain is verified,
+		// so a brief unavailability of the cadence frontend does not fail the worker start.
+		// If the domain does not exist the worker logs an error and never starts polling.
+		// default: false
+		LazyStart bool
+
+		// Optional: Callbacks invoked at lifecycle points of the worker, e.g. to flush caches, deregister from
+		// service discovery or emit deployment markers. See WorkerLifecycleHooks.
+		// default: no hooks
+		LifecycleHooks WorkerLifecycleHooks
+	}
+
+	// WorkerStatus is a point in time health report of a worker, returned by Worker.Status().
+	WorkerStatus struct {
+		// Healthy is false if any of the started pollers is unhealthy, or while the worker is starting.
+		Healthy bool
+		// Starting is true while a LazyStart worker has been started but its pollers are not running yet,
+		// e.g. because the domain could not be verified so far.
+		Starting bool
+		// Pollers contains the status of each group of pollers hosted by the worker.
+		Pollers []PollerStatus
+		// StickyCacheSize is the number of workflow executions in the sticky cache.
+		// The cache is shared between all workers of the process.
+		StickyCacheSize int
+	}
+
+	// PollerStatus is the status of a group of pollers of the same type polling a task list.
+	PollerStatus struct {
+		// WorkerType is the kind of tasks polled, e.g. DecisionWorker or ActivityWorker.
+		WorkerType string
+		// TaskList is the name of the polled task list.
+		TaskList string
+		// Started is true if the pollers have been started and not stopped yet.
+		Started bool
+		// Healthy is false if no poller is running, or polls keep failing, or there
+		// has been no successful poll for a while.
+		Healthy bool
+		// PollersRunning is the number of poller goroutines currently running.
+		PollersRunning int
+		// LastSuccessfulPoll is the time of the last poll that did not fail. Zero if none succeeded yet.
+		LastSuccessfulPoll time.Time
+		// ConsecutivePollFailures is the number of poll failures since the last successful poll.
+		ConsecutivePollFailures int
+		// TaskSlotsInUse is the number of tasks currently being processed.
+		TaskSlotsInUse int
+		// TaskSlotsCapacity is the maximum number of tasks that can be processed concurrently.
+		TaskSlotsCapacity int
+	}
+
+	// WorkerLifecycleHooks are callbacks invoked at lifecycle points of a worker. All hooks are optional.
+	// They are called synchronously and delay the lifecycle transition until they return.
+	WorkerLifecycleHooks struct {
+		// OnStart is called once all pollers of the worker have been started. With LazyStart it is called
+		// after the domain has been verified in the background.
+		OnStart func(info WorkerLifecycleInfo)
+		// OnPollerStart is called after a group of pollers of the same type has been started.
+		OnPollerStart func(info PollerLifecycleInfo)
+		// OnPollerStop is called after a group of pollers has been stopped and its tasks in progress have
+		// completed or WorkerStopTimeout has expired.
+		OnPollerStop func(info PollerLifecycleInfo)
+		// OnShutdownBegin is called when the worker is stopped, before any poller is stopped.
+		OnShutdownBegin func(info WorkerLifecycleInfo)
+		// OnShutdownComplete is called after all pollers have been stopped, before Stop returns.
+		OnShutdownComplete func(info WorkerLifecycleInfo)
+	}
+
+	// WorkerLifecycleInfo identifies the worker passed to the WorkerLifecycleHooks.
+	WorkerLifecycleInfo struct {
+		Domain   string
+		TaskList string
+		Identity string
+	}
+
+	// PollerLifecycleInfo identifies the group of pollers passed to the WorkerLifecycleHooks.
+	PollerLifecycleInfo struct {
+		Domain   string
+		TaskList string
+		// WorkerType is the kind of tasks polled, e.g. DecisionWorker or ActivityWorker.
+		WorkerType string
+		// PollerCount is the number of poller goroutines of the group.
+		PollerCount int
+	}
+
+	// WorkerMetadata describes the runtime of a worker, returned by the QueryTypeWorkerMetadata query.
+	WorkerMetadata struct {
+		// LibraryVersion and FeatureVersion are the versions of the cadence client library used by the worker.
+		LibraryVersion string
+		FeatureVersion string
+		// BinaryChecksum identifies the binary of the worker.
+		BinaryChecksum string
+		// HostName is the name of the host running the worker.
+		HostName string
+		// WorkflowTypes and ActivityTypes are the sorted names of the types registered with the worker.
+		WorkflowTypes []string
+		ActivityTypes []string
 	}
 )
 
@@ -252,6 +651,50 @@ const (
 	NonDeterministicWorkflowPolicyFailWorkflow
 )
 
+// WorkflowPanicAction is an enum returned by WorkerOptions.WorkflowPanicClassifier deciding what a panic in
+// workflow code does to the workflow.
+type WorkflowPanicAction int
+
+const (
+	// WorkflowPanicActionBlock fails the decision task. The decision task is retried, so the workflow is blocked
+	// until the panic is fixed by a new deployment of the workflow code.
+	WorkflowPanicActionBlock WorkflowPanicAction = iota
+	// WorkflowPanicActionFail fails the workflow execution with a PanicError holding the panic value and stack trace.
+	WorkflowPanicActionFail
+)
+
+// DecisionTaskFailurePolicy is an enum for configuring what the worker does with a workflow whose decision task
+// failed WorkerOptions.DecisionTaskFailureThreshold times in a row.
+type DecisionTaskFailurePolicy int
+
+const (
+	// DecisionTaskFailurePolicyRetry is the default policy. The workflow is left alone and the server keeps
+	// retrying its decision task.
+	DecisionTaskFailurePolicyRetry DecisionTaskFailurePolicy = iota
+	// DecisionTaskFailurePolicyTerminate terminates the workflow.
+	DecisionTaskFailurePolicyTerminate
+	// DecisionTaskFailurePolicyReset resets the workflow to the last completed decision task, dropping the events
+	// after it. The workflow is left alone if no decision task of the run has completed yet, or if it was already
+	// reset to that decision task, since the reset run would keep failing the same way.
+	DecisionTaskFailurePolicyReset
+)
+
+// StickyCacheEvictionPolicy is an enum for configuring which workflow execution is evicted from the sticky workflow
+// cache, see WorkerOptions.StickyCacheEvictionPolicy.
+type StickyCacheEvictionPolicy int
+
+const (
+	// StickyCacheEvictionPolicyLRU is the default policy. It evicts the least recently used execution once the cache
+	// is full.
+	StickyCacheEvictionPolicyLRU StickyCacheEvictionPolicy = iota
+	// StickyCacheEvictionPolicyLFU evicts the execution with the fewest decision tasks served from the cache once the
+	// cache is full, so executions that are replayed often stay cached.
+	StickyCacheEvictionPolicyLFU
+	// StickyCacheEvictionPolicyTTL evicts executions that were not used for WorkerOptions.StickyCacheTTL with a
+	// background sweeper, in addition to evicting the least recently used execution once the cache is full.
+	StickyCacheEvictionPolicyTTL
+)
+
 // NewWorker creates an instance of worker for managing workflow and activity executions.
 // service 	- thrift connection to the cadence server.
 // domain - the name of the cadence domain.
@@ -267,6 +710,61 @@ func NewWorker(
 	return newAggregatedWorker(service, domain, taskList, options)
 }
 
+// NewActivityWorker creates an instance of worker that only hosts activity implementations.
+// No decision task poller is created, and starting the worker fails if no activity is registered.
+// service 	- thrift connection to the cadence server.
+// domain - the name of the cadence domain.
+// taskList 	- is the task list name the activity worker polls activity tasks from.
+// options 	-  configure any worker specific options like logger, metrics, identity.
+func NewActivityWorker(
+	service workflowserviceclient.Interface,
+	domain string,
+	taskList string,
+	options WorkerOptions,
+) (*aggregatedWorker, error) {
+	if options.DisableActivityWorker {
+		return nil, errors.New("activity worker cannot be created with DisableActivityWorker set")
+	}
+	if options.EnableShadowWorker {
+		return nil, errors.New("activity worker cannot be created with EnableShadowWorker set")
+	}
+	options.DisableWorkflowWorker = true
+	worker := newAggregatedWorker(service, domain, taskList, options)
+	worker.workerKind = activityOnlyWorkerKind
+	return worker, nil
+}
+
+// NewWorkflowWorker creates an instance of worker that only hosts workflow implementations
+// and processes their decision tasks.
+// No activity task poller is created, and starting the worker fails if no workflow is registered.
+// service 	- thrift connection to the cadence server.
+// domain - the name of the cadence domain.
+// taskList 	- is the task list name the workflow worker polls decision tasks from.
+// options 	-  configure any worker specific options like logger, metrics, identity.
+func NewWorkflowWorker(
+	service workflowserviceclient.Interface,
+	domain string,
+	taskList string,
+	options WorkerOptions,
+) (*aggregatedWorker, error) {
+	if options.DisableWorkflowWorker {
+		return nil, errors.New("workflow worker cannot be created with DisableWorkflowWorker set")
+	}
+	if options.EnableShadowWorker {
+		return nil, errors.New("workflow worker cannot be created with EnableShadowWorker set")
+	}
+	if options.EnableSessionWorker {
+		return nil, errors.New("workflow worker cannot be created with EnableSessionWorker set, sessions require an activity worker")
+	}
+	if options.EnableHostPinnedActivities {
+		return nil, errors.New("workflow worker cannot be created with EnableHostPinnedActivities set, pinned activities require an activity worker")
+	}
+	options.DisableActivityWorker = true
+	worker := newAggregatedWorker(service, domain, taskList, options)
+	worker.workerKind = workflowOnlyWorkerKind
+	return worker, nil
+}
+
 // ReplayWorkflowExecution loads a workflow execution history from the Cadence service and executes a single decision task for it.
 // Use for testing backwards compatibility of code changes and troubleshooting workflows in a debugger.
 // The logger is the only optional parameter. Defaults to the noop logger.
@@ -0,0 +1,90 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+type (
+	// WorkerEventListener is notified by a worker about the workflow executions and activities it processes. The
+	// notifications only carry metadata, never inputs or results, and are meant to feed audit pipelines without
+	// wrapping every workflow in an interceptor. The methods are called synchronously from the task processing
+	// goroutines, so they must not block. Embed WorkerEventListenerBase to only implement some of them.
+	WorkerEventListener interface {
+		// OnWorkflowStarted is called once the first decision task of a workflow execution is completed.
+		OnWorkflowStarted(event WorkflowEvent)
+		// OnWorkflowCompleted is called once a decision task closing a workflow execution is completed. The way
+		// the workflow was closed is set in event.CloseStatus.
+		OnWorkflowCompleted(event WorkflowEvent)
+		// OnActivityScheduled is called for every activity scheduled by a completed decision task. It is not called
+		// for local activities.
+		OnActivityScheduled(event ActivityEvent)
+		// OnActivityStarted is called before the worker executes an activity task or a local activity.
+		OnActivityStarted(event ActivityEvent)
+		// OnActivityCompleted is called once the activity function returned, before its result is reported.
+		// It is not called for activities completed asynchronously with ErrResultPending.
+		OnActivityCompleted(event ActivityEvent)
+	}
+
+	// WorkerEventListenerBase implements WorkerEventListener with methods doing nothing.
+	WorkerEventListenerBase struct{}
+
+	// WorkflowEvent is the metadata of a workflow execution passed to a WorkerEventListener.
+	WorkflowEvent struct {
+		Domain            string
+		TaskList          string
+		WorkflowType      string
+		WorkflowExecution WorkflowExecution
+		// CloseStatus is only set for OnWorkflowCompleted, for example WorkflowStatusFailed.
+		CloseStatus WorkflowStatus
+	}
+
+	// ActivityEvent is the metadata of an activity passed to a WorkerEventListener.
+	ActivityEvent struct {
+		Domain            string
+		TaskList          string
+		WorkflowType      string
+		WorkflowExecution WorkflowExecution
+		ActivityType      string
+		ActivityID        string
+		// Attempt is not set for OnActivityScheduled.
+		Attempt int32
+		// Local is true for local activities.
+		Local bool
+		// Err is only set for OnActivityCompleted, it is nil when the activity succeeded and otherwise carries the
+		// reason but not the details of the failure.
+		Err error
+	}
+)
+
+var _ WorkerEventListener = WorkerEventListenerBase{}
+
+// OnWorkflowStarted does nothing.
+func (WorkerEventListenerBase) OnWorkflowStarted(WorkflowEvent) {}
+
+// OnWorkflowCompleted does nothing.
+func (WorkerEventListenerBase) OnWorkflowCompleted(WorkflowEvent) {}
+
+// OnActivityScheduled does nothing.
+func (WorkerEventListenerBase) OnActivityScheduled(ActivityEvent) {}
+
+// OnActivityStarted does nothing.
+func (WorkerEventListenerBase) OnActivityStarted(ActivityEvent) {}
+
+// OnActivityCompleted does nothing.
+func (WorkerEventListenerBase) OnActivityCompleted(ActivityEvent) {}
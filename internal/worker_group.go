@@ -0,0 +1,125 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultRunGroupShutdownTimeout = 30 * time.Second
+
+type (
+	// GroupWorker is a worker that can be run as part of a group by RunGroup.
+	GroupWorker interface {
+		Start() error
+		Stop()
+	}
+
+	// RunGroupOptions configures RunGroup.
+	RunGroupOptions struct {
+		// Optional: Signals that trigger the shutdown of the workers.
+		// default: SIGINT and SIGTERM
+		Signals []os.Signal
+
+		// Optional: Triggers the shutdown of the workers when closed, in addition to the signals.
+		// default: nil, only the signals trigger the shutdown
+		StopChannel <-chan struct{}
+
+		// Optional: How long the graceful shutdown of all the workers may take. The workers that are not stopped
+		// in time are abandoned and RunGroup returns an error.
+		// default: 30s
+		ShutdownTimeout time.Duration
+
+		// Optional: Logger for the group lifecycle.
+		// default: the noop logger
+		Logger *zap.Logger
+	}
+)
+
+// RunGroup starts the workers in the given order and blocks until one of the signals is received or the stop
+// channel is closed, then stops the workers in the reverse order. When a worker fails to start, the workers
+// already started are stopped and the start error is returned.
+func RunGroup(options RunGroupOptions, workers ...GroupWorker) error {
+	if len(workers) == 0 {
+		return errors.New("no workers to run")
+	}
+	logger := options.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	shutdownTimeout := options.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultRunGroupShutdownTimeout
+	}
+	signals := options.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	// subscribe before starting the workers so that no signal is missed
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, signals...)
+	defer signal.Stop(signalCh)
+
+	for i, worker := range workers {
+		if err := worker.Start(); err != nil {
+			logger.Error("Worker failed to start, stopping the started workers", zap.Int("Worker", i), zap.Error(err))
+			if stopErr := stopGroupWorkers(workers[:i], shutdownTimeout); stopErr != nil {
+				logger.Error("Workers failed to stop", zap.Error(stopErr))
+			}
+			return fmt.Errorf("worker %v failed to start: %v", i, err)
+		}
+	}
+
+	select {
+	case s := <-signalCh:
+		logger.Info("Worker group has been killed", zap.String("Signal", s.String()))
+	case <-options.StopChannel:
+		logger.Info("Worker group has been stopped")
+	}
+	return stopGroupWorkers(workers, shutdownTimeout)
+}
+
+// stopGroupWorkers stops the workers one by one in the reverse order within the timeout.
+func stopGroupWorkers(workers []GroupWorker, timeout time.Duration) error {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := len(workers) - 1; i >= 0; i-- {
+			workers[i].Stop()
+		}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("workers did not stop within %v", timeout)
+	}
+}
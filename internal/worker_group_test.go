@@ -0,0 +1,124 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testGroupWorker struct {
+	name     string
+	startErr error
+	stopWait time.Duration
+	events   *[]string
+	lock     *sync.Mutex
+}
+
+func (w *testGroupWorker) Start() error {
+	w.record("start " + w.name)
+	return w.startErr
+}
+
+func (w *testGroupWorker) Stop() {
+	time.Sleep(w.stopWait)
+	w.record("stop " + w.name)
+}
+
+func (w *testGroupWorker) record(event string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	*w.events = append(*w.events, event)
+}
+
+func newTestGroupWorkers(names ...string) ([]*testGroupWorker, func() []string) {
+	var events []string
+	var lock sync.Mutex
+	var workers []*testGroupWorker
+	for _, name := range names {
+		workers = append(workers, &testGroupWorker{name: name, events: &events, lock: &lock})
+	}
+	return workers, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string(nil), events...)
+	}
+}
+
+func TestRunGroup_StopChannel(t *testing.T) {
+	workers, events := newTestGroupWorkers("a", "b", "c")
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	err := RunGroup(RunGroupOptions{StopChannel: stopCh}, workers[0], workers[1], workers[2])
+	require.NoError(t, err)
+	require.Equal(t, []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"}, events())
+}
+
+func TestRunGroup_Signal(t *testing.T) {
+	workers, events := newTestGroupWorkers("a", "b")
+	done := make(chan error, 1)
+	go func() {
+		done <- RunGroup(RunGroupOptions{Signals: []os.Signal{os.Interrupt}}, workers[0], workers[1])
+	}()
+
+	require.Eventually(t, func() bool { return len(events()) == 2 }, time.Second, time.Millisecond)
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(os.Interrupt))
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "worker group was not stopped by the signal")
+	}
+	require.Equal(t, []string{"start a", "start b", "stop b", "stop a"}, events())
+}
+
+func TestRunGroup_StartFailure(t *testing.T) {
+	workers, events := newTestGroupWorkers("a", "b", "c")
+	workers[1].startErr = errors.New("start failed")
+
+	err := RunGroup(RunGroupOptions{}, workers[0], workers[1], workers[2])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "start failed")
+	require.Equal(t, []string{"start a", "start b", "stop a"}, events())
+}
+
+func TestRunGroup_ShutdownTimeout(t *testing.T) {
+	workers, _ := newTestGroupWorkers("a")
+	workers[0].stopWait = time.Second
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	err := RunGroup(RunGroupOptions{StopChannel: stopCh, ShutdownTimeout: 10 * time.Millisecond}, workers[0])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "did not stop")
+}
+
+func TestRunGroup_NoWorkers(t *testing.T) {
+	require.Error(t, RunGroup(RunGroupOptions{}))
+}
@@ -24,7 +24,9 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -319,13 +321,48 @@ type (
 
 	// WaitGroup must be used instead of native go sync.WaitGroup by
 	// workflow code.  Use workflow.NewWaitGroup(ctx) method to create
-	// a new WaitGroup instance
+	// a new WaitGroup instance. A WaitGroup only relies on the deterministic
+	// coroutine scheduling of the workflow, so it is safe to use during replay.
 	WaitGroup interface {
+		// Add adds delta, which may be negative, to the WaitGroup counter.
+		// It panics if the counter goes negative.
 		Add(delta int)
+		// Done decrements the WaitGroup counter by one.
 		Done()
+		// Wait blocks until the WaitGroup counter is zero. A WaitGroup may be
+		// reused once Wait has returned.
 		Wait(ctx Context)
 	}
 
+	// Mutex must be used instead of native go sync.Mutex by workflow code to
+	// coordinate access to shared workflow state between coroutines. Use
+	// workflow.NewMutex(ctx) method to create a new Mutex instance.
+	Mutex interface {
+		// Lock blocks until the mutex is acquired. CanceledError is returned if
+		// the ctx is canceled before the mutex is acquired.
+		Lock(ctx Context) error
+		// TryLock acquires the mutex if it is not held and reports whether it did.
+		TryLock(ctx Context) bool
+		// Unlock releases the mutex. It panics if the mutex is not locked.
+		Unlock()
+		// IsLocked reports whether the mutex is currently held.
+		IsLocked() bool
+	}
+
+	// Semaphore must be used instead of native go semaphores by workflow code to
+	// limit the number of coroutines accessing a shared resource. Use
+	// workflow.NewSemaphore(ctx, n) method to create a new Semaphore instance.
+	Semaphore interface {
+		// Acquire blocks until n permits are available and acquires them. CanceledError
+		// is returned if the ctx is canceled before the permits are acquired.
+		Acquire(ctx Context, n int64) error
+		// TryAcquire acquires n permits if they are available and reports whether it did.
+		TryAcquire(ctx Context, n int64) bool
+		// Release returns n permits to the semaphore. It panics if more permits
+		// are released than are held.
+		Release(n int64)
+	}
+
 	// Future represents the result of an asynchronous computation.
 	Future interface {
 		// Get blocks until the future is ready.
@@ -541,6 +578,17 @@ type RegisterWorkflowOptions struct {
 	// This option has no effect when explicit Name is provided.
 	EnableShortName               bool
 	DisableAlreadyRegisteredCheck bool
+	// Optional: The DecisionTaskStartToCloseTimeout used when the workflow is started without one, instead of the
+	// 10s default, or of the timeout of the current workflow for child workflows and continue as new. Useful for
+	// workflows with heavy decisions, so that every caller starts them with a large enough timeout. It is applied by
+	// the clients and workflows which see this registration: the ones of this process for a global registration
+	// (workflow.RegisterWithOptions).
+	DecisionTaskStartToCloseTimeout time.Duration
+	// Optional: Pins the open executions of the workflow type in the sticky cache, so other executions never evict
+	// them. Useful for workflows that are very expensive to replay, like coordinators of many short lived child
+	// workflows. The number of pinned executions is bounded by WorkerOptions.StickyCacheMaxPinned, executions beyond
+	// the bound are cached like any other.
+	PinInStickyCache bool
 }
 
 // RegisterWorkflow - registers a workflow function with the framework.
@@ -646,6 +694,19 @@ func NewWaitGroup(ctx Context) WaitGroup {
 	return &waitGroupImpl{future: f, settable: s}
 }
 
+// NewMutex creates a new Mutex instance.
+func NewMutex(ctx Context) Mutex {
+	return &mutexImpl{}
+}
+
+// NewSemaphore creates a new Semaphore instance with n permits.
+func NewSemaphore(ctx Context, n int64) Semaphore {
+	if n <= 0 {
+		panic("semaphore size must be positive")
+	}
+	return &semaphoreImpl{size: n}
+}
+
 // Go creates a new coroutine. It has similar semantic to goroutine in a context of the workflow.
 func Go(ctx Context, f func(ctx Context)) {
 	state := getState(ctx)
@@ -666,6 +727,136 @@ func NewFuture(ctx Context) (Future, Settable) {
 	return impl, impl
 }
 
+// AwaitAll blocks until all of the futures are ready. It returns nil if every future succeeded,
+// otherwise an *AggregateError holding the error of each future in the order they were passed.
+// Returns CanceledError if the ctx is canceled before all the futures are ready.
+func AwaitAll(ctx Context, futures []Future) error {
+	err := Await(ctx, func() bool {
+		for _, f := range futures {
+			if !f.IsReady() {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(futures))
+	for i, f := range futures {
+		errs[i] = f.Get(ctx, nil)
+	}
+	if aggregateErr := newAggregateError(errs); aggregateErr != nil {
+		return aggregateErr
+	}
+	return nil
+}
+
+// AwaitAny blocks until at least one of the futures is ready. It returns the index of the ready
+// future and the error it completed with. When several futures are ready the lowest index is returned.
+// Returns -1 and CanceledError if the ctx is canceled before any of the futures is ready.
+func AwaitAny(ctx Context, futures []Future) (int, error) {
+	if len(futures) == 0 {
+		return -1, errors.New("no futures to await")
+	}
+
+	index := -1
+	err := Await(ctx, func() bool {
+		for i, f := range futures {
+			if f.IsReady() {
+				index = i
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return -1, err
+	}
+	return index, futures[index].Get(ctx, nil)
+}
+
+// SortedMapKeys returns the keys of the map m as a slice sorted in ascending order, for example a []string for a
+// map[string]int. Ranging over a map in workflow code is non-deterministic as the iteration order is random and
+// changes on replay, iterate over the sorted keys instead.
+// The keys must be of a boolean, integer, floating point or string kind, otherwise it panics. It also panics when
+// a floating point key is NaN, as NaN keys are distinct from each other and can't be ordered deterministically.
+func SortedMapKeys(m interface{}) interface{} {
+	mapValue := reflect.ValueOf(m)
+	if mapValue.Kind() != reflect.Map {
+		panic(fmt.Sprintf("expected a map but was %T", m))
+	}
+	return sortedMapKeys(mapValue).Interface()
+}
+
+// SortedMapRange calls f for each key and value of the map m in the ascending order of the keys, see SortedMapKeys.
+// f is a func(key K, value V) for a map[K]V, or a func(key K, value V) bool that stops the iteration by returning false.
+func SortedMapRange(m interface{}, f interface{}) {
+	mapValue := reflect.ValueOf(m)
+	if mapValue.Kind() != reflect.Map {
+		panic(fmt.Sprintf("expected a map but was %T", m))
+	}
+	fnValue := reflect.ValueOf(f)
+	if err := validateMapRangeFn(mapValue.Type(), fnValue.Type()); err != nil {
+		panic(err)
+	}
+
+	keys := sortedMapKeys(mapValue)
+	for i := 0; i < keys.Len(); i++ {
+		key := keys.Index(i)
+		results := fnValue.Call([]reflect.Value{key, mapValue.MapIndex(key)})
+		if len(results) == 1 && !results[0].Bool() {
+			return
+		}
+	}
+}
+
+func validateMapRangeFn(mapType, fnType reflect.Type) error {
+	if fnType.Kind() != reflect.Func || fnType.NumIn() != 2 ||
+		!mapType.Key().AssignableTo(fnType.In(0)) || !mapType.Elem().AssignableTo(fnType.In(1)) {
+		return fmt.Errorf("expected a func(%v, %v) but was %v", mapType.Key(), mapType.Elem(), fnType)
+	}
+	if fnType.NumOut() > 1 || (fnType.NumOut() == 1 && fnType.Out(0).Kind() != reflect.Bool) {
+		return fmt.Errorf("expected a func without result or returning a bool but was %v", fnType)
+	}
+	return nil
+}
+
+// sortedMapKeys returns a slice of the key type holding the keys of mapValue in ascending order.
+func sortedMapKeys(mapValue reflect.Value) reflect.Value {
+	keyType := mapValue.Type().Key()
+	var less func(a, b reflect.Value) bool
+	switch keyType.Kind() {
+	case reflect.Bool:
+		less = func(a, b reflect.Value) bool { return !a.Bool() && b.Bool() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(a, b reflect.Value) bool { return a.Int() < b.Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		less = func(a, b reflect.Value) bool { return a.Uint() < b.Uint() }
+	case reflect.Float32, reflect.Float64:
+		less = func(a, b reflect.Value) bool { return a.Float() < b.Float() }
+	case reflect.String:
+		less = func(a, b reflect.Value) bool { return a.String() < b.String() }
+	default:
+		panic(fmt.Sprintf("map keys of type %v can't be sorted, expected a boolean, integer, floating point or string kind", keyType))
+	}
+
+	keys := reflect.MakeSlice(reflect.SliceOf(keyType), 0, mapValue.Len())
+	iter := mapValue.MapRange()
+	for iter.Next() {
+		key := iter.Key()
+		if (keyType.Kind() == reflect.Float32 || keyType.Kind() == reflect.Float64) && math.IsNaN(key.Float()) {
+			panic(fmt.Sprintf("map keys of type %v can't be sorted, NaN keys have no deterministic order", keyType))
+		}
+		keys = reflect.Append(keys, key)
+	}
+	sort.Slice(keys.Interface(), func(i, j int) bool {
+		return less(keys.Index(i), keys.Index(j))
+	})
+	return keys
+}
+
 func (wc *workflowEnvironmentInterceptor) ExecuteWorkflow(ctx Context, workflowType string, inputArgs ...interface{}) (results []interface{}) {
 	args := []reflect.Value{reflect.ValueOf(ctx)}
 	for _, arg := range inputArgs {
@@ -726,13 +917,22 @@ func (wc *workflowEnvironmentInterceptor) ExecuteActivity(ctx Context, typeName
 		return future
 	}
 	// Validate context options.
-	options := getActivityOptions(ctx)
-	options, err = getValidatedActivityOptions(ctx)
+	options, err := getValidatedActivityOptions(ctx)
 	if err != nil {
-		settable.Set(nil, err)
+		settable.Set(nil, fmt.Errorf("invalid options of activity %v executed at %v: %w", typeName, getCallSite(), err))
 		return future
 	}
 
+	if options.TaskListResolver != nil {
+		if taskList := options.TaskListResolver(typeName, args); taskList != "" {
+			oldTaskListName := options.TaskListName
+			options.TaskListName = taskList
+			defer func() {
+				options.TaskListName = oldTaskListName
+			}()
+		}
+	}
+
 	// Validate session state.
 	if sessionInfo := getSessionInfo(ctx); sessionInfo != nil {
 		isCreationActivity := isSessionCreationActivity(typeName)
@@ -765,6 +965,9 @@ func (wc *workflowEnvironmentInterceptor) ExecuteActivity(ctx Context, typeName
 		DataConverter:   dataConverter,
 		Header:          header,
 	}
+	if params.RetryPolicy != nil && len(params.NonRetriableErrors) > 0 {
+		params.RetryPolicy = applyNonRetriableErrors(params.RetryPolicy, header, params.NonRetriableErrors)
+	}
 
 	ctxDone, cancellable := ctx.Done().(*channelImpl)
 	cancellationCallback := &receiveCallback{}
@@ -980,6 +1183,7 @@ func (wc *workflowEnvironmentInterceptor) ExecuteChildWorkflow(ctx Context, chil
 		mainSettable.Set(nil, err)
 		return result
 	}
+	registeredDecisionTaskTimeout := getRegisteredDecisionTaskTimeout(workflowOptionsFromCtx, env.GetRegistry(), wfType.Name)
 	options, err := getValidatedWorkflowOptions(ctx)
 	if err != nil {
 		executionSettable.Set(nil, err)
@@ -998,6 +1202,9 @@ func (wc *workflowEnvironmentInterceptor) ExecuteChildWorkflow(ctx Context, chil
 		header:          getWorkflowHeader(ctx, options.contextPropagators),
 		scheduledTime:   Now(ctx), /* this is needed for test framework, and is not send to server */
 	}
+	if registeredDecisionTaskTimeout != nil {
+		params.taskStartToCloseTimeoutSeconds = registeredDecisionTaskTimeout
+	}
 
 	var childWorkflowExecution *WorkflowExecution
 
@@ -1063,6 +1270,9 @@ func getWorkflowHeader(ctx Context, ctxProps []ContextPropagator) *s.Header {
 	header := &s.Header{
 		Fields: make(map[string][]byte),
 	}
+	for key, value := range getOutgoingHeaderFields(ctx) {
+		header.Fields[key] = value
+	}
 	writer := NewHeaderWriter(header)
 	for _, ctxProp := range ctxProps {
 		ctxProp.InjectFromWorkflow(ctx, writer)
@@ -1081,6 +1291,7 @@ type WorkflowInfo struct {
 	Domain                              string
 	Attempt                             int32 // Attempt starts from 0 and increased by 1 for every retry if retry policy is specified.
 	lastCompletionResult                []byte
+	signalChannels                      map[string]Channel
 	CronSchedule                        *string
 	ContinuedExecutionRunID             *string
 	ParentWorkflowDomain                *string
@@ -1090,6 +1301,8 @@ type WorkflowInfo struct {
 	BinaryChecksum                      *string             // The identifier(generated by md5sum by default) of worker code that is making the current decision(can be used for auto-reset feature)
 	DecisionStartedEventID              int64               // the eventID of DecisionStarted that is making the current decision(can be used for reset API)
 	RetryPolicy                         *s.RetryPolicy
+	HistoryLength                       int64 // Number of events in the history up to the current decision, including its DecisionStarted event
+	HistoryBytes                        int64 // Encoded size in bytes of the history events up to the current decision
 }
 
 // GetBinaryChecksum returns the binary checksum(identifier) of this worker
@@ -1102,6 +1315,40 @@ func (wInfo *WorkflowInfo) GetBinaryChecksum() string {
 	return *wInfo.BinaryChecksum
 }
 
+// GetContinuedExecutionRunID returns the run ID of the execution that continued as new into this one,
+// or an empty string if this execution was not started by continue-as-new.
+func (wInfo *WorkflowInfo) GetContinuedExecutionRunID() string {
+	if wInfo.ContinuedExecutionRunID == nil {
+		return ""
+	}
+	return *wInfo.ContinuedExecutionRunID
+}
+
+// GetHistoryLength returns the number of events in the workflow history up to the current decision.
+// The value is read from the history as it is replayed, so it is safe to be used in workflow logic, for example
+// to decide when to continue as new.
+func (wInfo *WorkflowInfo) GetHistoryLength() int64 {
+	return wInfo.HistoryLength
+}
+
+// GetHistoryBytes returns the encoded size in bytes of the workflow history up to the current decision.
+// Like GetHistoryLength, it is deterministic during replay.
+func (wInfo *WorkflowInfo) GetHistoryBytes() int64 {
+	return wInfo.HistoryBytes
+}
+
+// PendingSignals returns the number of signals delivered to the workflow which were not received from their signal
+// channels yet, including the signals whose channel was never requested by the workflow. Like GetHistoryLength, it is
+// deterministic during replay, so a workflow processing a stream of signals can use it to decide to continue as new
+// before too many signals are buffered.
+func (wInfo *WorkflowInfo) PendingSignals() int {
+	count := 0
+	for _, ch := range wInfo.signalChannels {
+		count += ch.(*channelImpl).bufferedCount()
+	}
+	return count
+}
+
 // GetDecisionCompletedEventID returns the eventID of DecisionStartedEvent that is making the current decision(can be used for reset API: decisionFinishEventID = DecisionStartedEventID + 1)
 func (wInfo *WorkflowInfo) GetDecisionStartedEventID() int64 {
 	return wInfo.DecisionStartedEventID
@@ -1127,7 +1374,9 @@ func (wc *workflowEnvironmentInterceptor) GetLogger(ctx Context) *zap.Logger {
 	return wc.env.GetLogger()
 }
 
-// GetMetricsScope returns a metrics scope to be used in workflow's context
+// GetMetricsScope returns a metrics scope to be used in workflow's context.
+// The scope does not emit metrics while the workflow code is replaying history. See
+// WorkerOptions.EnableBufferedWorkflowMetrics to only emit them once the decision task completes.
 func GetMetricsScope(ctx Context) tally.Scope {
 	i := getWorkflowInterceptor(ctx)
 	return i.GetMetricsScope(ctx)
@@ -1166,9 +1415,16 @@ func (wc *workflowEnvironmentInterceptor) NewTimer(ctx Context, d time.Duration)
 		return future
 	}
 
+	var summary string
+	if options := getTimerOptions(ctx); options != nil {
+		summary = options.Summary
+	}
 	ctxDone, cancellable := ctx.Done().(*channelImpl)
 	cancellationCallback := &receiveCallback{}
 	t := wc.env.NewTimer(d, func(r []byte, e error) {
+		if summary != "" && IsCanceledError(e) {
+			e = NewCanceledError(summary)
+		}
 		settable.Set(nil, e)
 		if cancellable {
 			// future is done, we don't need cancellation anymore
@@ -1176,6 +1432,9 @@ func (wc *workflowEnvironmentInterceptor) NewTimer(ctx Context, d time.Duration)
 		}
 	})
 
+	if t != nil && summary != "" {
+		wc.env.RecordTimerSummary(t.timerID, summary)
+	}
 	if t != nil && cancellable {
 		cancellationCallback.fn = func(v interface{}, more bool) bool {
 			if !future.IsReady() {
@@ -1191,6 +1450,25 @@ func (wc *workflowEnvironmentInterceptor) NewTimer(ctx Context, d time.Duration)
 	return future
 }
 
+// TimerOptions are the options of a timer created with NewTimerWithOptions.
+type TimerOptions struct {
+	// Optional: A human readable summary of the timer, e.g. "wait for payment". It is recorded in the workflow history
+	// as a marker next to the started timer so that long waits can be understood when inspecting the history.
+	// When the timer is canceled, the returned future fails with a *CanceledError with the summary as details.
+	Summary string
+}
+
+// NewTimerWithOptions works like NewTimer, with the options of the timer. The future becomes ready with a nil error
+// when the timer fires, or with a *CanceledError when the timer is canceled through the context.
+func NewTimerWithOptions(ctx Context, d time.Duration, options TimerOptions) Future {
+	return NewTimer(WithValue(ctx, timerOptionsContextKey, &options), d)
+}
+
+func getTimerOptions(ctx Context) *TimerOptions {
+	options, _ := ctx.Value(timerOptionsContextKey).(*TimerOptions)
+	return options
+}
+
 // Sleep pauses the current workflow for at least the duration d. A negative or zero duration causes Sleep to return
 // immediately. Workflow code needs to use this Sleep() to sleep instead of the Go lang library one(timer.Sleep()).
 // You can cancel the pending sleep by cancel the Context (using context from workflow.WithCancel(ctx)).
@@ -1210,6 +1488,49 @@ func (wc *workflowEnvironmentInterceptor) Sleep(ctx Context, d time.Duration) (e
 	return
 }
 
+// SleepUntil pauses the current workflow until at least the time t. The duration is computed from the workflow time
+// returned by Now, so that it stays the same when the workflow is replayed. A time t which is not after Now causes
+// SleepUntil to return immediately. SleepUntil returns the same errors as Sleep.
+func SleepUntil(ctx Context, t time.Time) (err error) {
+	return Sleep(ctx, t.Sub(Now(ctx)))
+}
+
+// RunWithCleanup runs mainFn with ctx and then cleanupFn with a context disconnected from the cancellation of ctx, so
+// that cleanupFn runs even when the workflow or ctx is canceled. When cleanupTimeout is positive the cleanup context
+// is canceled after cleanupTimeout, so that a stuck cleanup doesn't block the workflow forever.
+// RunWithCleanup returns the error of mainFn, or the error of cleanupFn when mainFn succeeded. The error of cleanupFn
+// is logged when both of them fail.
+//
+//	err := workflow.RunWithCleanup(ctx, func(ctx workflow.Context) error {
+//		return workflow.ExecuteActivity(ctx, ReserveActivity).Get(ctx, nil)
+//	}, func(ctx workflow.Context) error {
+//		return workflow.ExecuteActivity(ctx, ReleaseActivity).Get(ctx, nil)
+//	}, time.Minute)
+func RunWithCleanup(ctx Context, mainFn func(ctx Context) error, cleanupFn func(ctx Context) error, cleanupTimeout time.Duration) error {
+	err := mainFn(ctx)
+
+	cleanupCtx, cancel := NewDisconnectedContext(ctx)
+	defer cancel()
+	if cleanupTimeout > 0 {
+		timerCtx, cancelTimer := WithCancel(cleanupCtx)
+		defer cancelTimer()
+		Go(timerCtx, func(ctx Context) {
+			if NewTimer(ctx, cleanupTimeout).Get(ctx, nil) == nil {
+				cancel()
+			}
+		})
+	}
+
+	cleanupErr := cleanupFn(cleanupCtx)
+	if err != nil {
+		if cleanupErr != nil {
+			GetLogger(ctx).Warn("Cleanup failed.", zap.Error(cleanupErr))
+		}
+		return err
+	}
+	return cleanupErr
+}
+
 // RequestCancelExternalWorkflow can be used to request cancellation of an external workflow.
 // Input workflowID is the workflow ID of target workflow.
 // Input runID indicates the instance of a workflow. Input runID is optional (default is ""). When runID is not specified,
@@ -1228,7 +1549,8 @@ func (wc *workflowEnvironmentInterceptor) RequestCancelExternalWorkflow(ctx Cont
 	options := getWorkflowEnvOptions(ctx1)
 	future, settable := NewFuture(ctx1)
 
-	if options.domain == nil || *options.domain == "" {
+	domain := getTargetDomain(ctx1, options)
+	if domain == "" {
 		settable.Set(nil, errDomainNotSet)
 		return future
 	}
@@ -1243,7 +1565,7 @@ func (wc *workflowEnvironmentInterceptor) RequestCancelExternalWorkflow(ctx Cont
 	}
 
 	wc.env.RequestCancelExternalWorkflow(
-		*options.domain,
+		domain,
 		workflowID,
 		runID,
 		resultCallback,
@@ -1252,6 +1574,12 @@ func (wc *workflowEnvironmentInterceptor) RequestCancelExternalWorkflow(ctx Cont
 	return future
 }
 
+// RequestCancelExternalWorkflowInDomain requests cancellation of an external workflow running in the given domain.
+// It is equivalent to calling RequestCancelExternalWorkflow with a context created by WithWorkflowDomain.
+func RequestCancelExternalWorkflowInDomain(ctx Context, domain, workflowID, runID string) Future {
+	return RequestCancelExternalWorkflow(WithWorkflowDomain(ctx, domain), workflowID, runID)
+}
+
 // SignalExternalWorkflow can be used to send signal info to an external workflow.
 // Input workflowID is the workflow ID of target workflow.
 // Input runID indicates the instance of a workflow. Input runID is optional (default is ""). When runID is not specified,
@@ -1265,6 +1593,12 @@ func SignalExternalWorkflow(ctx Context, workflowID, runID, signalName string, a
 	return i.SignalExternalWorkflow(ctx, workflowID, runID, signalName, arg)
 }
 
+// SignalExternalWorkflowInDomain sends a signal to an external workflow running in the given domain.
+// It is equivalent to calling SignalExternalWorkflow with a context created by WithWorkflowDomain.
+func SignalExternalWorkflowInDomain(ctx Context, domain, workflowID, runID, signalName string, arg interface{}) Future {
+	return SignalExternalWorkflow(WithWorkflowDomain(ctx, domain), workflowID, runID, signalName, arg)
+}
+
 func (wc *workflowEnvironmentInterceptor) SignalExternalWorkflow(ctx Context, workflowID, runID, signalName string, arg interface{}) Future {
 	const childWorkflowOnly = false // this means we are not limited to child workflow
 	return signalExternalWorkflow(ctx, workflowID, runID, signalName, arg, childWorkflowOnly)
@@ -1276,7 +1610,8 @@ func signalExternalWorkflow(ctx Context, workflowID, runID, signalName string, a
 	options := getWorkflowEnvOptions(ctx1)
 	future, settable := NewFuture(ctx1)
 
-	if options.domain == nil || *options.domain == "" {
+	domain := getTargetDomain(ctx1, options)
+	if domain == "" {
 		settable.Set(nil, errDomainNotSet)
 		return future
 	}
@@ -1296,7 +1631,7 @@ func signalExternalWorkflow(ctx Context, workflowID, runID, signalName string, a
 		settable.Set(result, err)
 	}
 	env.SignalExternalWorkflow(
-		*options.domain,
+		domain,
 		workflowID,
 		runID,
 		signalName,
@@ -1309,6 +1644,16 @@ func signalExternalWorkflow(ctx Context, workflowID, runID, signalName string, a
 	return future
 }
 
+// getTargetDomain returns the domain of the external workflow targeted by a signal or a cancellation request: the
+// domain set on the context, or the current workflow's domain when none is set, e.g. after WithChildWorkflowOptions
+// was called without a Domain.
+func getTargetDomain(ctx Context, options *workflowOptions) string {
+	if options.domain != nil && *options.domain != "" {
+		return *options.domain
+	}
+	return GetWorkflowInfo(ctx).Domain
+}
+
 // UpsertSearchAttributes is used to add or update workflow search attributes.
 // The search attributes can be used in query of List/Scan/Count workflow APIs.
 // The key and value type must be registered on cadence server side;
@@ -1358,6 +1703,7 @@ func WithChildWorkflowOptions(ctx Context, cwo ChildWorkflowOptions) Context {
 	wfOptions.workflowID = cwo.WorkflowID
 	wfOptions.executionStartToCloseTimeoutSeconds = common.Int32Ptr(common.Int32Ceil(cwo.ExecutionStartToCloseTimeout.Seconds()))
 	wfOptions.taskStartToCloseTimeoutSeconds = common.Int32Ptr(common.Int32Ceil(cwo.TaskStartToCloseTimeout.Seconds()))
+	wfOptions.taskStartToCloseTimeoutInherited = false
 	wfOptions.waitForCancellation = cwo.WaitForCancellation
 	wfOptions.workflowIDReusePolicy = cwo.WorkflowIDReusePolicy
 	wfOptions.retryPolicy = convertRetryPolicy(cwo.RetryPolicy)
@@ -1406,6 +1752,7 @@ func WithExecutionStartToCloseTimeout(ctx Context, d time.Duration) Context {
 func WithWorkflowTaskStartToCloseTimeout(ctx Context, d time.Duration) Context {
 	ctx1 := setWorkflowEnvOptionsIfNotExist(ctx)
 	getWorkflowEnvOptions(ctx1).taskStartToCloseTimeoutSeconds = common.Int32Ptr(common.Int32Ceil(d.Seconds()))
+	getWorkflowEnvOptions(ctx1).taskStartToCloseTimeoutInherited = false
 	return ctx1
 }
 
@@ -1436,6 +1783,54 @@ func (wc *workflowEnvironmentInterceptor) GetSignalChannel(ctx Context, signalNa
 	return getWorkflowEnvOptions(ctx).getSignalChannel(ctx, signalName)
 }
 
+// SignalChannelOptions configure the buffering of a signal channel returned by GetSignalChannelWithOptions.
+type SignalChannelOptions struct {
+	// MaxBufferedSignals is the maximum number of signals kept in the channel until they are received by the workflow.
+	// Optional: default is 100K.
+	MaxBufferedSignals int
+
+	// OnOverflow is called for every signal that is delivered while the channel already holds MaxBufferedSignals
+	// signals. The signal is not added to the channel, so the callback can aggregate it into workflow state or just
+	// drop it. The callback is invoked from the event loop in the order signals appear in the history, so it must be
+	// deterministic and must not block; it cannot call blocking workflow APIs like Channel.Receive.
+	// Optional: if not set, signals that do not fit in the buffer are dropped.
+	OnOverflow func(signal Value)
+}
+
+// SetSignalHandler sets the handler that is invoked for every signal with the given name, as an alternative to
+// receiving from the channel returned by GetSignalChannel in a loop. The handler must be a function that accepts
+// workflow.Context as the first parameter and optionally the signal argument as the second one. It must not return
+// any value. Example:
+//  func MyWorkflow(ctx workflow.Context) error {
+//    var items []string
+//    err := workflow.SetSignalHandler(ctx, "add_item", func(ctx workflow.Context, item string) {
+//      items = append(items, item)
+//    })
+//    if err != nil {
+//      return err
+//    }
+//    // your normal workflow code begins here
+//  }
+// The handler is invoked from a dedicated coroutine, one signal at a time in the order the signals were received,
+// which keeps the execution deterministic. The handler may call blocking workflow APIs, the next signal is handled
+// after it returns. Signals received before the handler was set are also delivered to it. Signals that cannot be
+// decoded into the argument type are dropped and logged. Only one handler can be set for a signal name, and the
+// signal channel should not be read directly once the handler is set.
+func SetSignalHandler(ctx Context, signalName string, handler interface{}) error {
+	return getWorkflowEnvOptions(ctx).setSignalHandler(ctx, signalName, handler)
+}
+
+// GetSignalChannelWithOptions returns channel corresponding to the signal name, like GetSignalChannel, but with a
+// bounded buffer. Workflows receiving signals at a high rate can use it to implement drop or aggregate semantics
+// instead of buffering an unbounded number of signals in the workflow state.
+// The options apply to all later lookups of the same signal channel, including GetSignalChannel. Signals already
+// buffered in the channel are kept even if they exceed MaxBufferedSignals.
+func GetSignalChannelWithOptions(ctx Context, signalName string, options SignalChannelOptions) Channel {
+	ch := GetSignalChannel(ctx, signalName)
+	getWorkflowEnvOptions(ctx).setSignalChannelOptions(ctx, signalName, options)
+	return ch
+}
+
 func newEncodedValue(value []byte, dc DataConverter) Value {
 	if dc == nil {
 		dc = getDefaultDataConverter()
@@ -1657,6 +2052,36 @@ func SetQueryHandler(ctx Context, queryType string, handler interface{}) error {
 	return i.SetQueryHandler(ctx, queryType, handler)
 }
 
+// QueryHandlerOptions configure a query handler set by SetQueryHandlerWithOptions.
+type QueryHandlerOptions struct {
+	// Timeout is the maximum time the query handler is expected to run. Query handlers run while the workflow state
+	// is locked, so they cannot be interrupted; if the handler returns after the timeout, its result is discarded,
+	// the query fails with an error, and a warning is logged and reported with the query-handler-timeout metric,
+	// so slow handlers that delay decision task completion are noticed.
+	// Optional: default is no timeout.
+	Timeout time.Duration
+
+	// DataConverter is used to decode the query arguments and encode the query result of this handler instead of the
+	// data converter of the workflow. It allows query results to stay human readable, for example in cadence-web, when
+	// the workflow data is encoded in a binary or encrypted format. Callers of the query must decode the result with
+	// the same data converter, like a client created with it.
+	// Optional: default is the data converter of the workflow.
+	DataConverter DataConverter
+}
+
+// SetQueryHandlerWithOptions sets the query handler like SetQueryHandler, with additional options.
+// In addition to the handler functions accepted by SetQueryHandler, the handler may accept workflow.Context as its
+// first parameter. The context is the one passed to SetQueryHandlerWithOptions, and it can only be used for read
+// only access like GetInfo or GetLogger. Example:
+//  err := workflow.SetQueryHandlerWithOptions(ctx, "current_state", func(ctx workflow.Context) (string, error) {
+//    workflow.GetLogger(ctx).Info("Querying current state.")
+//    return currentState, nil
+//  }, workflow.QueryHandlerOptions{Timeout: time.Second})
+func SetQueryHandlerWithOptions(ctx Context, queryType string, handler interface{}, options QueryHandlerOptions) error {
+	ctx = WithValue(ctx, queryHandlerOptionsContextKey, options)
+	return SetQueryHandler(ctx, queryType, handler)
+}
+
 func (wc *workflowEnvironmentInterceptor) SetQueryHandler(ctx Context, queryType string, handler interface{}) error {
 	if strings.HasPrefix(queryType, "__") {
 		return errors.New("queryType starts with '__' is reserved for internal use")
@@ -1736,6 +2161,7 @@ func WithActivityOptions(ctx Context, options ActivityOptions) Context {
 	eap.WaitForCancellation = options.WaitForCancellation
 	eap.ActivityID = common.StringPtr(options.ActivityID)
 	eap.RetryPolicy = convertRetryPolicy(options.RetryPolicy)
+	eap.NonRetriableErrors = options.NonRetriableErrors
 	return ctx1
 }
 
@@ -1751,6 +2177,16 @@ func WithLocalActivityOptions(ctx Context, options LocalActivityOptions) Context
 	return ctx1
 }
 
+// WithHostPinnedActivityOptions adds the task list of the worker with the host ID to the copy of the context, so the
+// activities executed with it are only executed by that worker. The worker must set
+// WorkerOptions.EnableHostPinnedActivities, and hostID is the ActivityInfo.HostID of an activity it executed.
+// Pinned activities are not moved to another worker if the host goes away, set a ScheduleToStartTimeout to detect it.
+func WithHostPinnedActivityOptions(ctx Context, hostID string) Context {
+	ctx1 := setActivityParametersIfNotExist(ctx)
+	getActivityOptions(ctx1).TaskListName = getHostPinnedTasklist(hostID)
+	return ctx1
+}
+
 // WithTaskList adds a task list to the copy of the context.
 func WithTaskList(ctx Context, name string) Context {
 	ctx1 := setActivityParametersIfNotExist(ctx)
@@ -1758,6 +2194,19 @@ func WithTaskList(ctx Context, name string) Context {
 	return ctx1
 }
 
+// ActivityTaskListResolver returns the task list an activity is scheduled on from its type name and arguments. It is
+// called from the workflow code, so it must be deterministic. An empty task list keeps the one of the activity options.
+type ActivityTaskListResolver func(activityType string, args []interface{}) string
+
+// WithActivityTaskListResolver adds a task list resolver to the copy of the context. The task list returned by the
+// resolver takes precedence over the task list of the activity options, except for the activities of a session,
+// which always run on the task list of the session.
+func WithActivityTaskListResolver(ctx Context, resolver ActivityTaskListResolver) Context {
+	ctx1 := setActivityParametersIfNotExist(ctx)
+	getActivityOptions(ctx1).TaskListResolver = resolver
+	return ctx1
+}
+
 // WithScheduleToCloseTimeout adds a timeout to the copy of the context.
 // The current timeout resolution implementation is in seconds and uses math.Ceil(d.Seconds()) as the duration. But is
 // subjected to change in the future.
@@ -1808,6 +2257,14 @@ func WithRetryPolicy(ctx Context, retryPolicy RetryPolicy) Context {
 	return ctx1
 }
 
+// WithNonRetriableErrors adds non retriable errors to the copy of the context, overriding the
+// ActivityOptions.NonRetriableErrors of the activities executed with it.
+func WithNonRetriableErrors(ctx Context, errs ...error) Context {
+	ctx1 := setActivityParametersIfNotExist(ctx)
+	getActivityOptions(ctx1).NonRetriableErrors = errs
+	return ctx1
+}
+
 func convertRetryPolicy(retryPolicy *RetryPolicy) *s.RetryPolicy {
 	if retryPolicy == nil {
 		return nil
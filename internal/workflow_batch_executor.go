@@ -0,0 +1,180 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const defaultBatchExecutorBatchSize = 100
+
+type (
+	// BatchExecutorOptions configure a BatchExecutor.
+	BatchExecutorOptions struct {
+		// Optional: The number of items dispatched in a single activity call.
+		// default: 100
+		BatchSize int
+
+		// Optional: Dispatches the pending items this long, in workflow time, after the first of them was added, even
+		// when the batch is not full.
+		// default: 0, which dispatches the items only when the batch is full or Flush is called
+		FlushInterval time.Duration
+	}
+
+	// BatchExecutor accumulates small activity invocations and dispatches them as a single activity call per batch,
+	// which reduces the history events and the scheduling overhead of large fan-outs. The batch activity takes a
+	// slice of items, and either returns a slice of results with one result per item, or only an error.
+	//
+	// The activity is executed with the activity options of the context of the BatchExecutor. The workflow must call
+	// Flush before waiting for the futures of the items of a batch which is not full when no FlushInterval is set.
+	BatchExecutor struct {
+		ctx        Context
+		activity   interface{}
+		options    BatchExecutorOptions
+		itemType   reflect.Type
+		resultType reflect.Type // nil when the activity returns only an error
+
+		items       reflect.Value
+		settables   []Settable
+		batch       int // incremented on every flush, so that a flush timer of a dispatched batch is ignored
+		cancelTimer CancelFunc
+	}
+)
+
+// NewBatchExecutor creates a BatchExecutor dispatching its batches to activity, a function with the signature
+// func([ctx context.Context,] items []T) ([]R, error) or func([ctx context.Context,] items []T) error.
+func NewBatchExecutor(ctx Context, activity interface{}, options BatchExecutorOptions) (*BatchExecutor, error) {
+	fnType := reflect.TypeOf(activity)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return nil, errors.New("batch activity must be a function")
+	}
+	in := fnType.NumIn()
+	if in > 0 && isActivityContext(fnType.In(0)) {
+		in--
+	}
+	if in != 1 || fnType.In(fnType.NumIn()-1).Kind() != reflect.Slice {
+		return nil, fmt.Errorf("batch activity %v must take a single slice of items", getFunctionName(activity))
+	}
+	var resultType reflect.Type
+	switch fnType.NumOut() {
+	case 1:
+	case 2:
+		if fnType.Out(0).Kind() != reflect.Slice {
+			return nil, fmt.Errorf("batch activity %v must return a slice of results", getFunctionName(activity))
+		}
+		resultType = fnType.Out(0).Elem()
+	default:
+		return nil, fmt.Errorf("batch activity %v must return ([]R, error) or error", getFunctionName(activity))
+	}
+	if options.BatchSize < 0 || options.FlushInterval < 0 {
+		return nil, errors.New("negative batch size or flush interval")
+	}
+	if options.BatchSize == 0 {
+		options.BatchSize = defaultBatchExecutorBatchSize
+	}
+
+	itemsType := fnType.In(fnType.NumIn() - 1)
+	return &BatchExecutor{
+		ctx:        ctx,
+		activity:   activity,
+		options:    options,
+		itemType:   itemsType.Elem(),
+		resultType: resultType,
+		items:      reflect.MakeSlice(itemsType, 0, options.BatchSize),
+	}, nil
+}
+
+// Execute adds item to the current batch, and returns a future which becomes ready with the result of the item once
+// the batch is executed. The batch is dispatched when it is full or when the flush interval elapsed.
+func (b *BatchExecutor) Execute(item interface{}) Future {
+	future, settable := NewFuture(b.ctx)
+	value := reflect.ValueOf(item)
+	if !value.IsValid() {
+		value = reflect.Zero(b.itemType)
+	}
+	if !value.Type().AssignableTo(b.itemType) {
+		settable.Set(nil, fmt.Errorf("batch item of type %v is not assignable to %v", value.Type(), b.itemType))
+		return future
+	}
+
+	b.items = reflect.Append(b.items, value)
+	b.settables = append(b.settables, settable)
+	if b.items.Len() >= b.options.BatchSize {
+		b.Flush()
+	} else if b.items.Len() == 1 && b.options.FlushInterval > 0 {
+		b.startFlushTimer()
+	}
+	return future
+}
+
+// Flush dispatches the items of the current batch, if any.
+func (b *BatchExecutor) Flush() {
+	if b.items.Len() == 0 {
+		return
+	}
+	if b.cancelTimer != nil {
+		b.cancelTimer()
+		b.cancelTimer = nil
+	}
+	items, settables := b.items, b.settables
+	b.items = reflect.MakeSlice(items.Type(), 0, b.options.BatchSize)
+	b.settables = nil
+	b.batch++
+
+	future := ExecuteActivity(b.ctx, b.activity, items.Interface())
+	Go(b.ctx, func(ctx Context) {
+		if b.resultType == nil {
+			err := future.Get(ctx, nil)
+			for _, settable := range settables {
+				settable.Set(nil, err)
+			}
+			return
+		}
+
+		results := reflect.New(reflect.SliceOf(b.resultType))
+		err := future.Get(ctx, results.Interface())
+		if err == nil && results.Elem().Len() != len(settables) {
+			err = fmt.Errorf("batch activity %v returned %v results for %v items",
+				getFunctionName(b.activity), results.Elem().Len(), len(settables))
+		}
+		for i, settable := range settables {
+			if err != nil {
+				settable.Set(nil, err)
+			} else {
+				settable.Set(results.Elem().Index(i).Interface(), nil)
+			}
+		}
+	})
+}
+
+func (b *BatchExecutor) startFlushTimer() {
+	batch := b.batch
+	timerCtx, cancel := WithCancel(b.ctx)
+	b.cancelTimer = cancel
+	Go(timerCtx, func(ctx Context) {
+		if NewTimer(ctx, b.options.FlushInterval).Get(ctx, nil) == nil && b.batch == batch {
+			b.Flush()
+		}
+	})
+}
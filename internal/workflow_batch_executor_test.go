@@ -0,0 +1,123 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBatchUpperActivity(ctx context.Context, items []string) ([]string, error) {
+	var results []string
+	for _, item := range items {
+		if item == "" {
+			return nil, errors.New("empty item")
+		}
+		results = append(results, strings.ToUpper(item))
+	}
+	return results, nil
+}
+
+func testBatchWorkflow(ctx Context, items []string, delay time.Duration) ([]string, error) {
+	ctx = WithActivityOptions(ctx, ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+	})
+	batcher, err := NewBatchExecutor(ctx, testBatchUpperActivity, BatchExecutorOptions{BatchSize: 3, FlushInterval: time.Minute})
+	if err != nil {
+		return nil, err
+	}
+	var futures []Future
+	for i, item := range items {
+		if i == len(items)-1 {
+			// the last item is added after the flush interval of the pending batch
+			if err := Sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+		futures = append(futures, batcher.Execute(item))
+	}
+	var results []string
+	for _, f := range futures {
+		var result string
+		if err := f.Get(ctx, &result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func TestBatchExecutor(t *testing.T) {
+	env := newTestWorkflowEnv(t)
+	env.RegisterWorkflow(testBatchWorkflow)
+	env.RegisterActivity(testBatchUpperActivity)
+	var batches []string
+	env.SetOnActivityStartedListener(func(activityInfo *ActivityInfo, ctx context.Context, args Values) {
+		var items []string
+		require.NoError(t, args.Get(&items))
+		batches = append(batches, strings.Join(items, ","))
+	})
+	start := env.Now()
+	env.ExecuteWorkflow(testBatchWorkflow, []string{"a", "b", "c", "d", "e", "f"}, 2*time.Minute)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var results []string
+	require.NoError(t, env.GetWorkflowResult(&results))
+	assert.Equal(t, []string{"A", "B", "C", "D", "E", "F"}, results)
+	assert.Equal(t, []string{"a,b,c", "d,e", "f"}, batches)
+	assert.Equal(t, 3*time.Minute, env.Now().Sub(start))
+}
+
+func TestBatchExecutor_ActivityError(t *testing.T) {
+	env := newTestWorkflowEnv(t)
+	env.RegisterWorkflow(testBatchWorkflow)
+	env.RegisterActivity(testBatchUpperActivity)
+	env.ExecuteWorkflow(testBatchWorkflow, []string{"a", "", "c"}, time.Duration(0))
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	assert.Contains(t, env.GetWorkflowError().Error(), "empty item")
+}
+
+func TestNewBatchExecutor_Validation(t *testing.T) {
+	for i, activity := range []interface{}{
+		"activity",
+		func(ctx context.Context, item string) error { return nil },
+		func(items []string, other int) error { return nil },
+		func(items []string) (string, error) { return "", nil },
+		func(items []string) {},
+	} {
+		_, err := NewBatchExecutor(nil, activity, BatchExecutorOptions{})
+		assert.Error(t, err, fmt.Sprint(i))
+	}
+	_, err := NewBatchExecutor(nil, testBatchUpperActivity, BatchExecutorOptions{BatchSize: -1})
+	assert.Error(t, err)
+	b, err := NewBatchExecutor(nil, func(ctx context.Context, items []int) error { return nil }, BatchExecutorOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, defaultBatchExecutorBatchSize, b.options.BatchSize)
+}
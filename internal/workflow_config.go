@@ -0,0 +1,114 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// workflowConfigSideEffectID is the MutableSideEffect ID the config snapshots are recorded with.
+const workflowConfigSideEffectID = "__cadence_workflow_config"
+
+type (
+	// WorkflowConfigProvider provides the config snapshots read by workflow code through GetConfigValue, see
+	// WorkerOptions.WorkflowConfigProvider. The provider is called at most once per decision task of a workflow
+	// execution and should return quickly, e.g. an in-memory copy of the dynamic config.
+	WorkflowConfigProvider interface {
+		// GetWorkflowConfig returns the config snapshot for the workflow execution. The values are encoded with the
+		// data converter of the worker. An error fails the decision task, which is retried.
+		GetWorkflowConfig(info WorkflowInfo) (map[string]interface{}, error)
+	}
+)
+
+// GetConfigValue returns the value of key from the config snapshot of the WorkflowConfigProvider of the worker.
+// The snapshot is taken once per decision task and recorded in the workflow history as a single marker when it
+// differs from the last recorded one, so reading any number of keys doesn't require a SideEffect call per key and
+// replays read the values exactly as they were read originally. An empty snapshot is recorded when the worker has no
+// provider, so the provider can be added to the worker of running workflows.
+// The returned value has no value (encoded.Value.HasValue returns false) when key is not in the snapshot.
+//
+//	var enabled bool
+//	if value := workflow.GetConfigValue(ctx, "feature.enabled"); value.HasValue() {
+//		err := value.Get(&enabled)
+//	}
+func GetConfigValue(ctx Context, key string) Value {
+	i := getWorkflowInterceptor(ctx)
+	return i.GetConfigValue(ctx, key)
+}
+
+func (wc *workflowEnvironmentInterceptor) GetConfigValue(ctx Context, key string) Value {
+	return wc.env.GetConfigValue(key)
+}
+
+func (wc *workflowEnvironmentImpl) GetConfigValue(key string) Value {
+	// the snapshot is recorded even without a provider, an empty one then, so that the history has the marker
+	// when it is replayed by a worker a provider was added to
+	var snapshot map[string][]byte
+	value := wc.MutableSideEffect(workflowConfigSideEffectID, wc.getConfigSnapshot, func(a, b interface{}) bool {
+		return reflect.DeepEqual(a, b)
+	})
+	if err := value.Get(&snapshot); err != nil {
+		panic(err)
+	}
+	return newEncodedValue(snapshot[key], wc.GetDataConverter())
+}
+
+// getConfigSnapshot returns the snapshot of the config provider for the current decision task, taking it on the
+// first call of the decision task.
+func (wc *workflowEnvironmentImpl) getConfigSnapshot() interface{} {
+	if wc.configSnapshot != nil && wc.configSnapshotDecision == wc.workflowInfo.DecisionStartedEventID {
+		return wc.configSnapshot
+	}
+	if wc.configProvider == nil {
+		// the snapshot recorded in the history is kept when the provider is removed from the worker
+		snapshot := map[string][]byte{}
+		if recorded, ok := wc.mutableSideEffect[workflowConfigSideEffectID]; ok {
+			if err := newEncodedValue(recorded, wc.GetDataConverter()).Get(&snapshot); err != nil {
+				panic(err)
+			}
+		}
+		return snapshot
+	}
+	snapshot, err := encodeConfigSnapshot(wc.configProvider, *wc.workflowInfo, wc.GetDataConverter())
+	if err != nil {
+		panic(err)
+	}
+	wc.configSnapshot = snapshot
+	wc.configSnapshotDecision = wc.workflowInfo.DecisionStartedEventID
+	return snapshot
+}
+
+func encodeConfigSnapshot(provider WorkflowConfigProvider, info WorkflowInfo, dc DataConverter) (map[string][]byte, error) {
+	config, err := provider.GetWorkflowConfig(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the workflow config snapshot: %v", err)
+	}
+	snapshot := make(map[string][]byte, len(config))
+	for key, value := range config {
+		data, err := encodeArg(dc, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the workflow config value of %v: %v", key, err)
+		}
+		snapshot[key] = data
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+// Portions of the Software are attributed to Copyright (c) 2020 Temporal Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+var _ WorkflowIDGenerator = (*uuidV7WorkflowIDGenerator)(nil)
+var _ WorkflowIDGenerator = (*argsHashWorkflowIDGenerator)(nil)
+
+type (
+	// WorkflowIDGenerator generates a workflow ID when a workflow is started without one.
+	// It is configured through ClientOptions.WorkflowIDGenerator.
+	WorkflowIDGenerator interface {
+		// GenerateWorkflowID returns the workflow ID for a workflow of the given type. input is the workflow
+		// arguments encoded with the client's DataConverter.
+		GenerateWorkflowID(workflowType string, input []byte) (string, error)
+	}
+
+	uuidV7WorkflowIDGenerator struct{}
+
+	argsHashWorkflowIDGenerator struct {
+		prefix string
+	}
+)
+
+// NewUUIDv7WorkflowIDGenerator returns a WorkflowIDGenerator that generates version 7 UUIDs. They start with the
+// millisecond unix timestamp, so workflow IDs sort by creation time.
+func NewUUIDv7WorkflowIDGenerator() WorkflowIDGenerator {
+	return &uuidV7WorkflowIDGenerator{}
+}
+
+// NewArgsHashWorkflowIDGenerator returns a WorkflowIDGenerator that generates prefix followed by the hex encoded
+// SHA-256 of the workflow type and encoded arguments. Starting the same workflow with the same arguments twice
+// generates the same workflow ID, so the WorkflowIDReusePolicy applies to duplicate starts.
+func NewArgsHashWorkflowIDGenerator(prefix string) WorkflowIDGenerator {
+	return &argsHashWorkflowIDGenerator{prefix: prefix}
+}
+
+func (g *uuidV7WorkflowIDGenerator) GenerateWorkflowID(_ string, _ []byte) (string, error) {
+	return newUUIDv7(time.Now())
+}
+
+func (g *argsHashWorkflowIDGenerator) GenerateWorkflowID(workflowType string, input []byte) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(workflowType))
+	h.Write([]byte{0})
+	h.Write(input)
+	return g.prefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newUUIDv7 builds a version 7 UUID as defined in RFC 9562: 48 bits of unix milliseconds followed by random bits.
+func newUUIDv7(now time.Time) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(now.UnixNano()/int64(time.Millisecond)))
+	copy(id[:6], ts[2:])
+	id[6] = 0x70 | (id[6] & 0x0f) // version 7
+	id[8] = 0x80 | (id[8] & 0x3f) // RFC 4122 variant
+	return uuid.UUID(id).String(), nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+// Portions of the Software are attributed to Copyright (c) 2020 Temporal Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUUIDv7WorkflowIDGenerator(t *testing.T) {
+	generator := NewUUIDv7WorkflowIDGenerator()
+	first, err := generator.GenerateWorkflowID(workflowType, nil)
+	require.NoError(t, err)
+	second, err := generator.GenerateWorkflowID(workflowType, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+
+	parsed := uuid.Parse(first)
+	require.NotNil(t, parsed)
+	version, ok := parsed.Version()
+	require.True(t, ok)
+	require.Equal(t, uuid.Version(7), version)
+	require.Equal(t, uuid.RFC4122, parsed.Variant())
+
+	earlier, err := newUUIDv7(time.Unix(1600000000, 0))
+	require.NoError(t, err)
+	later, err := newUUIDv7(time.Unix(1600000000, int64(time.Millisecond)))
+	require.NoError(t, err)
+	require.True(t, earlier < later)
+	require.Equal(t, "0174876e-8000", earlier[:13])
+}
+
+func TestArgsHashWorkflowIDGenerator(t *testing.T) {
+	generator := NewArgsHashWorkflowIDGenerator("order-")
+	first, err := generator.GenerateWorkflowID(workflowType, []byte("input"))
+	require.NoError(t, err)
+	second, err := generator.GenerateWorkflowID(workflowType, []byte("input"))
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+	require.Equal(t, "order-", first[:6])
+	require.Len(t, first, 6+64)
+
+	otherInput, err := generator.GenerateWorkflowID(workflowType, []byte("other input"))
+	require.NoError(t, err)
+	require.NotEqual(t, first, otherInput)
+	otherType, err := generator.GenerateWorkflowID("other type", []byte("input"))
+	require.NoError(t, err)
+	require.NotEqual(t, first, otherType)
+}
@@ -23,7 +23,6 @@ package internal
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -85,6 +84,18 @@ type ReplayOptions struct {
 	// Optional: flags to turn on/off some features on server side
 	// default: all features under the struct is turned off
 	FeatureFlags FeatureFlags
+
+	// Optional: Sets the metrics scope of the replay worker. As the whole history is replayed, the metrics recorded
+	// through workflow.GetMetricsScope(ctx) are not emitted, tests can use a tally.TestScope to assert that the
+	// workflow code only emits its metrics once, when the history events are first created.
+	// default: no metrics - tally.NoopScope
+	MetricsScope tally.Scope
+
+	// Optional: Records the workflow code paths exercised by each replayed history, so that the coverage of a corpus
+	// of replay tests can be measured before refactoring a workflow. Create it with NewReplayCoverage and read it
+	// with ReplayCoverage.Histories or ReplayCoverage.WriteProfile after the replays.
+	// default: no coverage is recorded
+	Coverage *ReplayCoverage
 }
 
 // IsReplayDomain checks if the domainName is from replay
@@ -119,6 +130,11 @@ func (r *WorkflowReplayer) RegisterWorkflowWithOptions(w interface{}, options Re
 	r.registry.RegisterWorkflowWithOptions(w, options)
 }
 
+// RegisterWorkflowStruct registers the exported methods of a workflow struct to replay
+func (r *WorkflowReplayer) RegisterWorkflowStruct(w interface{}) {
+	r.registry.RegisterWorkflowStruct(w)
+}
+
 // ReplayWorkflowHistory executes a single decision task for the given history.
 // Use for testing backwards compatibility of code changes and troubleshooting workflows in a debugger.
 // The logger is an optional parameter. Defaults to the noop logger.
@@ -134,7 +150,21 @@ func (r *WorkflowReplayer) ReplayWorkflowHistory(logger *zap.Logger, history *sh
 	return r.replayWorkflowHistory(logger, service, replayDomainName, nil, history, nil)
 }
 
+// ReplayWorkflowHistoryFromBlob executes a single decision task for the given encoded history. The history can be
+// encoded in JSON, as written by the CLI, or be a thrift or proto binary encoded history, as exported by archival.
+// The format is detected from the content.
+// Use for testing the backwards compatibility of code changes and troubleshooting workflows in a debugger.
+// The logger is an optional parameter. Defaults to the noop logger.
+func (r *WorkflowReplayer) ReplayWorkflowHistoryFromBlob(logger *zap.Logger, blob []byte) error {
+	history, err := deserializeHistory(blob)
+	if err != nil {
+		return err
+	}
+	return r.ReplayWorkflowHistory(logger, history)
+}
+
 // ReplayWorkflowHistoryFromJSONFile executes a single decision task for the given json history file.
+// The file can also hold a thrift or proto binary encoded history, see ReplayWorkflowHistoryFromBlob.
 // Use for testing the backwards compatibility of code changes and troubleshooting workflows in a debugger.
 // The logger is an optional parameter. Defaults to the noop logger.
 func (r *WorkflowReplayer) ReplayWorkflowHistoryFromJSONFile(logger *zap.Logger, jsonfileName string) error {
@@ -143,6 +173,7 @@ func (r *WorkflowReplayer) ReplayWorkflowHistoryFromJSONFile(logger *zap.Logger,
 
 // ReplayPartialWorkflowHistoryFromJSONFile executes a single decision task for the given json history file up to provided
 // lastEventID(inclusive).
+// The file can also hold a thrift or proto binary encoded history, see ReplayWorkflowHistoryFromBlob.
 // Use for testing backwards compatibility of code changes and troubleshooting workflows in a debugger.
 // The logger is an optional parameter. Defaults to the noop logger.
 func (r *WorkflowReplayer) ReplayPartialWorkflowHistoryFromJSONFile(logger *zap.Logger, jsonfileName string, lastEventID int64) error {
@@ -267,14 +298,21 @@ func (r *WorkflowReplayer) replayWorkflowHistory(
 	if logger == nil {
 		logger = zap.NewNop()
 	}
+	workflowInterceptors := r.options.WorkflowInterceptorChainFactories
+	if r.options.Coverage != nil {
+		// the coverage interceptor must be the head of the chain to find the workflow code calling it.
+		coverageFactory := r.options.Coverage.newInterceptorFactory(workflowType.GetName(), *execution)
+		workflowInterceptors = append([]WorkflowInterceptorFactory{coverageFactory}, workflowInterceptors...)
+	}
 	workerParams := workerExecutionParameters{
 		TaskList:               replayTaskListName,
 		Identity:               replayWorkerIdentity,
 		DataConverter:          r.options.DataConverter,
 		ContextPropagators:     r.options.ContextPropagators,
-		WorkflowInterceptors:   r.options.WorkflowInterceptorChainFactories,
+		WorkflowInterceptors:   workflowInterceptors,
 		Tracer:                 r.options.Tracer,
 		Logger:                 logger,
+		MetricsScope:           r.options.MetricsScope,
 		DisableStickyExecution: true,
 	}
 
@@ -341,20 +379,18 @@ func extractHistoryFromFile(jsonfileName string, lastEventID int64) (*shared.His
 		return nil, err
 	}
 
-	var deserializedEvents []*shared.HistoryEvent
-	err = json.Unmarshal(raw, &deserializedEvents)
-
+	history, err := deserializeHistory(raw)
 	if err != nil {
 		return nil, err
 	}
 
 	if lastEventID <= 0 {
-		return &shared.History{Events: deserializedEvents}, nil
+		return history, nil
 	}
 
 	// Caller is potentially asking for subset of history instead of all history events
 	var events []*shared.HistoryEvent
-	for _, event := range deserializedEvents {
+	for _, event := range history.Events {
 		events = append(events, event)
 		if event.GetEventId() == lastEventID {
 			// Copy history up to last event (inclusive)
@@ -0,0 +1,96 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	apiv1 "github.com/uber/cadence-idl/go/proto/api/v1"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/serializer"
+	"go.uber.org/cadence/internal/compatibility/thrift"
+	"go.uber.org/thriftrw/protocol"
+	"go.uber.org/thriftrw/wire"
+)
+
+const (
+	// thriftrwHistoryPreamble is the first byte of a history encoded by serializer.Encode
+	thriftrwHistoryPreamble byte = 0x59
+)
+
+var errReplayUnknownHistoryFormat = errors.New("history is neither JSON, thrift nor proto encoded")
+
+// deserializeHistory decodes a history from any of the formats it is exported in, detected from its content:
+//   - the JSON array of events written by the CLI, or a JSON encoded shared.History
+//   - a thriftrw binary encoded shared.History, with or without the version byte of serializer.Encode
+//   - a proto binary encoded api.v1.History
+func deserializeHistory(raw []byte) (*shared.History, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return nil, errReplayEmptyHistory
+	}
+
+	switch trimmed[0] {
+	case '[':
+		var events []*shared.HistoryEvent
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return nil, err
+		}
+		return &shared.History{Events: events}, nil
+	case '{':
+		var history shared.History
+		if err := json.Unmarshal(trimmed, &history); err != nil {
+			return nil, err
+		}
+		return &history, nil
+	}
+
+	if raw[0] == thriftrwHistoryPreamble {
+		var history shared.History
+		if err := serializer.Decode(raw, &history); err == nil && isDecodedHistory(&history) {
+			return &history, nil
+		}
+	}
+	// the binary formats can't be told apart by their first bytes, a history is only accepted if it decodes to events
+	if wireValue, err := protocol.Binary.Decode(bytes.NewReader(raw), wire.TStruct); err == nil {
+		var history shared.History
+		if err := history.FromWire(wireValue); err == nil && isDecodedHistory(&history) {
+			return &history, nil
+		}
+	}
+	var protoHistory apiv1.History
+	if err := protoHistory.Unmarshal(raw); err == nil {
+		if history := thrift.History(&protoHistory); isDecodedHistory(history) {
+			return history, nil
+		}
+	}
+	return nil, errReplayUnknownHistoryFormat
+}
+
+func isDecodedHistory(history *shared.History) bool {
+	if history == nil || len(history.Events) == 0 {
+		return false
+	}
+	first := history.Events[0]
+	return first != nil && first.GetEventId() > 0 && first.EventType != nil
+}
@@ -21,16 +21,25 @@
 package internal
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally/v4"
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/serializer"
+	"go.uber.org/cadence/internal/compatibility/proto"
+	"go.uber.org/thriftrw/protocol"
 	"go.uber.org/zap"
 )
 
@@ -73,6 +82,47 @@ func (s *workflowReplayerSuite) TestReplayWorkflowHistory_Full() {
 	s.NoError(err)
 }
 
+func (s *workflowReplayerSuite) TestReplayWorkflowHistory_MetricsScope() {
+	testScope := tally.NewTestScope("", nil)
+	replayer := NewWorkflowReplayerWithOptions(ReplayOptions{MetricsScope: testScope})
+	replayer.RegisterWorkflowWithOptions(func(ctx Context) error {
+		GetMetricsScope(ctx).Counter("workflow-counter").Inc(1)
+		return testReplayWorkflow(ctx)
+	}, RegisterWorkflowOptions{Name: "go.uber.org/cadence/internal.testReplayWorkflow"})
+
+	err := replayer.ReplayWorkflowHistory(s.logger, getTestReplayWorkflowFullHistory(s.T()))
+	s.NoError(err)
+	for _, counter := range testScope.Snapshot().Counters() {
+		if counter.Name() == "workflow-counter" {
+			s.EqualValues(0, counter.Value(), "workflow metrics must not be emitted during replay")
+		}
+	}
+}
+
+func (s *workflowReplayerSuite) TestReplayWorkflowHistory_Coverage() {
+	coverage := NewReplayCoverage()
+	replayer := NewWorkflowReplayerWithOptions(ReplayOptions{Coverage: coverage})
+	replayer.RegisterWorkflow(testReplayWorkflow)
+
+	s.NoError(replayer.ReplayWorkflowHistory(s.logger, getTestReplayWorkflowFullHistory(s.T())))
+	s.NoError(replayer.ReplayWorkflowHistory(s.logger, getTestReplayWorkflowFullHistory(s.T())))
+
+	histories := coverage.Histories()
+	s.Len(histories, 2)
+	s.Equal("go.uber.org/cadence/internal.testReplayWorkflow", histories[0].WorkflowType)
+	s.Equal(replayWorkflowID, histories[0].WorkflowID)
+	s.Len(histories[0].Locations, 1)
+	location := histories[0].Locations[0]
+	s.Equal("go.uber.org/cadence/internal.testReplayWorkflow", location.Function)
+	s.True(strings.HasSuffix(location.File, "workflow_replayer_test.go"))
+	s.Equal(1, location.Count)
+
+	var profile bytes.Buffer
+	s.NoError(coverage.WriteProfile(&profile))
+	s.Equal(fmt.Sprintf("mode: count\ngo.uber.org/cadence/internal/workflow_replayer_test.go:%d.1,%d.1 1 2\n",
+		location.Line, location.Line+1), profile.String())
+}
+
 func (s *workflowReplayerSuite) TestReplayWorkflowHistory_Full_ResultMisMatch() {
 	fullHistory := getTestReplayWorkflowFullHistory(s.T())
 	completedEvent := fullHistory.Events[len(fullHistory.Events)-1]
@@ -138,6 +188,38 @@ func (s *workflowReplayerSuite) TestReplayWorkflowHistoryFromFile() {
 	s.NoError(err)
 }
 
+func (s *workflowReplayerSuite) TestReplayWorkflowHistoryFromBlob() {
+	history, err := extractHistoryFromFile("testdata/sampleHistory.json", 0)
+	s.NoError(err)
+
+	jsonHistory, err := json.Marshal(history)
+	s.NoError(err)
+	thriftHistory, err := serializer.Encode(history)
+	s.NoError(err)
+	wireHistory, err := history.ToWire()
+	s.NoError(err)
+	var rawThriftHistory bytes.Buffer
+	s.NoError(protocol.Binary.Encode(wireHistory, &rawThriftHistory))
+	protoHistory, err := proto.History(history).Marshal()
+	s.NoError(err)
+
+	for name, blob := range map[string][]byte{
+		"json":       jsonHistory,
+		"thrift":     thriftHistory,
+		"raw thrift": rawThriftHistory.Bytes(),
+		"proto":      protoHistory,
+	} {
+		s.NoError(s.replayer.ReplayWorkflowHistoryFromBlob(s.logger, blob), name)
+
+		file := filepath.Join(s.T().TempDir(), "history")
+		s.NoError(ioutil.WriteFile(file, blob, 0644))
+		s.NoError(s.replayer.ReplayWorkflowHistoryFromJSONFile(s.logger, file), name)
+	}
+
+	s.Error(s.replayer.ReplayWorkflowHistoryFromBlob(s.logger, []byte("not a history")))
+	s.Error(s.replayer.ReplayWorkflowHistoryFromBlob(s.logger, nil))
+}
+
 func testReplayWorkflow(ctx Context) error {
 	ao := ActivityOptions{
 		ScheduleToStartTimeout: time.Second,
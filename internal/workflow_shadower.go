@@ -31,9 +31,11 @@ import (
 	"time"
 
 	"github.com/facebookgo/clock"
+	"github.com/uber-go/tally/v4"
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	"go.uber.org/cadence/.gen/go/shadower"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
 	"go.uber.org/cadence/internal/common/util"
 	"go.uber.org/zap"
 )
@@ -91,6 +93,17 @@ type (
 		// An error will be returned if it's set to be larger than 1 when used to NewWorkflowShadower
 		// default: 1
 		Concurrency int
+
+		// Optional: Writes a report of the replayed workflow executions to this file when WorkflowShadower.Run
+		// returns, so that a CI pipeline can gate a deployment on the shadowing result.
+		// Note: this field only applies to the local WorkflowShadower. The shadow worker reports its results
+		// through the metrics of its replay activities.
+		// default: empty, no report is written
+		ReportPath string
+
+		// Optional: The format of the report written to ReportPath.
+		// default: ShadowReportFormatJUnit
+		ReportFormat ShadowReportFormat
 	}
 
 	// TimeFilter represents a time range through the min and max timestamp
@@ -123,6 +136,8 @@ type (
 		shadowOptions ShadowOptions
 		logger        *zap.Logger
 		replayer      *WorkflowReplayer
+		metricsScope  tally.Scope
+		report        ShadowReport
 
 		status     int32
 		shutdownCh chan struct{}
@@ -176,6 +191,8 @@ func NewWorkflowShadower(
 		shadowOptions: shadowOptions,
 		logger:        logger,
 		replayer:      NewWorkflowReplayerWithOptions(replayOptions),
+		metricsScope:  tagScope(replayOptions.MetricsScope, tagDomain, domain),
+		report:        ShadowReport{Domain: domain},
 
 		status:     statusInitialized,
 		shutdownCh: make(chan struct{}),
@@ -194,13 +211,35 @@ func (s *WorkflowShadower) RegisterWorkflowWithOptions(w interface{}, options Re
 	s.replayer.RegisterWorkflowWithOptions(w, options)
 }
 
-// Run starts WorkflowShadower in a blocking fashion
+// RegisterWorkflowStruct registers the exported methods of a workflow struct to replay
+func (s *WorkflowShadower) RegisterWorkflowStruct(w interface{}) {
+	s.replayer.RegisterWorkflowStruct(w)
+}
+
+// Run starts WorkflowShadower in a blocking fashion. The replay results are counted in the metrics scope of the
+// ReplayOptions, and written to ShadowOptions.ReportPath if it is set.
 func (s *WorkflowShadower) Run() error {
 	if !atomic.CompareAndSwapInt32(&s.status, statusInitialized, statusStarted) {
 		return errors.New("Workflow shadower already started")
 	}
 
-	return s.shadowWorker()
+	startTime := s.clock.Now()
+	err := s.shadowWorker()
+	if len(s.shadowOptions.ReportPath) == 0 {
+		return err
+	}
+
+	s.report.Duration = s.clock.Now().Sub(startTime)
+	if err != nil {
+		s.report.Error = err.Error()
+	}
+	if reportErr := s.report.write(s.shadowOptions.ReportPath, s.shadowOptions.ReportFormat); reportErr != nil {
+		s.logger.Error("Failed to write shadow report", zap.String("ReportPath", s.shadowOptions.ReportPath), zap.Error(reportErr))
+		if err == nil {
+			return reportErr
+		}
+	}
+	return err
 }
 
 // Stop stops WorkflowShadower and wait up to one miniute for all goroutines to finish before returning
@@ -252,22 +291,31 @@ func (s *WorkflowShadower) shadowWorker() error {
 				return nil
 			}
 
+			workflowExecution := WorkflowExecution{
+				ID:    execution.GetWorkflowId(),
+				RunID: execution.GetRunId(),
+			}
+			replayStartTime := s.clock.Now()
 			success, err := replayWorkflowExecutionHelper(
 				ctx,
 				s.replayer,
 				s.service,
 				s.logger,
 				s.domain,
-				WorkflowExecution{
-					ID:    execution.GetWorkflowId(),
-					RunID: execution.GetRunId(),
-				},
+				workflowExecution,
 			)
+			replayDuration := s.clock.Now().Sub(replayStartTime)
+			s.metricsScope.Timer(metrics.ReplayLatency).Record(replayDuration)
+			s.report.addExecution(workflowExecution, replayDuration, success, err)
 			if err != nil {
+				s.metricsScope.Counter(metrics.ReplayFailedCounter).Inc(1)
 				return err
 			}
 			if success {
+				s.metricsScope.Counter(metrics.ReplaySucceedCounter).Inc(1)
 				replayCount++
+			} else {
+				s.metricsScope.Counter(metrics.ReplaySkippedCounter).Inc(1)
 			}
 
 			if replayCount == maxReplayCount {
@@ -0,0 +1,178 @@
+// Copyright (c) 2017-2021 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	// ShadowReportFormatJUnit writes the shadowing report as a JUnit XML test suite, with a test case per replayed
+	// workflow execution.
+	ShadowReportFormatJUnit ShadowReportFormat = iota
+	// ShadowReportFormatJSON writes the shadowing report as a JSON encoded ShadowReport.
+	ShadowReportFormatJSON
+)
+
+const (
+	shadowResultSucceeded = "succeeded"
+	shadowResultSkipped   = "skipped"
+	shadowResultFailed    = "failed"
+)
+
+type (
+	// ShadowReportFormat is an enum for the format of the report written by the WorkflowShadower, see
+	// ShadowOptions.ReportPath.
+	ShadowReportFormat int
+
+	// ShadowReport is the result of a WorkflowShadower run.
+	ShadowReport struct {
+		Domain     string                  `json:"domain"`
+		Succeeded  int                     `json:"succeeded"`
+		Skipped    int                     `json:"skipped"`
+		Failed     int                     `json:"failed"`
+		Duration   time.Duration           `json:"duration"`
+		Executions []ShadowExecutionResult `json:"executions"`
+		// Error is the error returned by WorkflowShadower.Run, if any.
+		Error string `json:"error,omitempty"`
+	}
+
+	// ShadowExecutionResult is the result of the replay of a workflow execution by the WorkflowShadower.
+	ShadowExecutionResult struct {
+		WorkflowID string `json:"workflowId"`
+		RunID      string `json:"runId"`
+		// Result is one of "succeeded", "skipped" and "failed". A replay is skipped if the history could not be
+		// replayed for a reason other than a nondeterministic workflow, e.g. the execution does not exist anymore.
+		Result   string        `json:"result"`
+		Duration time.Duration `json:"duration"`
+		Error    string        `json:"error,omitempty"`
+	}
+
+	junitTestSuites struct {
+		XMLName xml.Name         `xml:"testsuites"`
+		Suites  []junitTestSuite `xml:"testsuite"`
+	}
+
+	junitTestSuite struct {
+		Name      string          `xml:"name,attr"`
+		Tests     int             `xml:"tests,attr"`
+		Failures  int             `xml:"failures,attr"`
+		Skipped   int             `xml:"skipped,attr"`
+		Time      string          `xml:"time,attr"`
+		TestCases []junitTestCase `xml:"testcase"`
+	}
+
+	junitTestCase struct {
+		ClassName string        `xml:"classname,attr"`
+		Name      string        `xml:"name,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitMessage `xml:"failure,omitempty"`
+		Skipped   *junitMessage `xml:"skipped,omitempty"`
+	}
+
+	junitMessage struct {
+		Message string `xml:"message,attr"`
+		Content string `xml:",chardata"`
+	}
+)
+
+func (r *ShadowReport) addExecution(execution WorkflowExecution, duration time.Duration, success bool, err error) {
+	result := ShadowExecutionResult{
+		WorkflowID: execution.ID,
+		RunID:      execution.RunID,
+		Duration:   duration,
+	}
+	switch {
+	case err != nil:
+		result.Result = shadowResultFailed
+		result.Error = err.Error()
+		r.Failed++
+	case success:
+		result.Result = shadowResultSucceeded
+		r.Succeeded++
+	default:
+		result.Result = shadowResultSkipped
+		r.Skipped++
+	}
+	r.Executions = append(r.Executions, result)
+}
+
+func (r *ShadowReport) write(path string, format ShadowReportFormat) error {
+	var content []byte
+	var err error
+	switch format {
+	case ShadowReportFormatJUnit:
+		content, err = xml.MarshalIndent(r.toJUnit(), "", "  ")
+		content = append([]byte(xml.Header), content...)
+	case ShadowReportFormatJSON:
+		content, err = json.MarshalIndent(r, "", "  ")
+	default:
+		return fmt.Errorf("unknown shadow report format %v", format)
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+func (r *ShadowReport) toJUnit() junitTestSuites {
+	suite := junitTestSuite{
+		Name:     "cadence-shadow-" + r.Domain,
+		Tests:    len(r.Executions),
+		Failures: r.Failed,
+		Skipped:  r.Skipped,
+		Time:     junitSeconds(r.Duration),
+	}
+	for _, execution := range r.Executions {
+		testCase := junitTestCase{
+			ClassName: r.Domain,
+			Name:      execution.WorkflowID + "/" + execution.RunID,
+			Time:      junitSeconds(execution.Duration),
+		}
+		switch execution.Result {
+		case shadowResultFailed:
+			testCase.Failure = &junitMessage{Message: "replay failed", Content: execution.Error}
+		case shadowResultSkipped:
+			testCase.Skipped = &junitMessage{Message: "replay skipped"}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	if len(r.Error) != 0 && r.Failed == 0 {
+		// the run failed before any replay failed, e.g. because the workflows could not be scanned
+		suite.Tests++
+		suite.Failures++
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			ClassName: r.Domain,
+			Name:      "WorkflowShadower",
+			Time:      junitSeconds(0),
+			Failure:   &junitMessage{Message: "shadowing failed", Content: r.Error},
+		})
+	}
+	return junitTestSuites{Suites: []junitTestSuite{suite}}
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -21,6 +21,12 @@
 package internal
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -29,9 +35,11 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally/v4"
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/internal/common/metrics"
 )
 
 type workflowShadowerSuite struct {
@@ -364,6 +372,67 @@ func (s *workflowShadowerSuite) TestShadowWorker_ReplayFailed() {
 	s.Error(s.testShadower.shadowWorker())
 }
 
+func (s *workflowShadowerSuite) TestShadowWorker_Report() {
+	reportDir, err := ioutil.TempDir("", "shadow-report")
+	s.NoError(err)
+	defer os.RemoveAll(reportDir)
+
+	for _, format := range []ShadowReportFormat{ShadowReportFormatJUnit, ShadowReportFormatJSON} {
+		testScope := tally.NewTestScope("", nil)
+		reportPath := filepath.Join(reportDir, fmt.Sprintf("report-%v", format))
+		shadower, err := NewWorkflowShadower(s.mockService, "testDomain", ShadowOptions{
+			ReportPath:   reportPath,
+			ReportFormat: format,
+		}, ReplayOptions{MetricsScope: testScope}, nil)
+		s.NoError(err)
+		shadower.clock = clock.NewMock()
+		shadower.RegisterWorkflow(testReplayWorkflow)
+
+		s.mockService.EXPECT().ScanWorkflowExecutions(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.ListWorkflowExecutionsResponse{
+			Executions:    newTestWorkflowExecutions(3),
+			NextPageToken: nil,
+		}, nil).Times(1)
+		s.mockService.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.GetWorkflowExecutionHistoryResponse{
+			History: s.testWorkflowHistory,
+		}, nil).Times(1)
+		s.mockService.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(
+			nil, &shared.EntityNotExistsError{Message: "Workflow passed retention date"}).Times(1)
+		s.mockService.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), callOptions()...).Return(&shared.GetWorkflowExecutionHistoryResponse{
+			History: getTestReplayWorkflowMismatchHistory(s.T()),
+		}, nil).Times(1)
+
+		s.Error(shadower.Run())
+
+		counters := testScope.Snapshot().Counters()
+		s.Equal(int64(1), counters[metrics.ReplaySucceedCounter+"+Domain=testDomain"].Value())
+		s.Equal(int64(1), counters[metrics.ReplaySkippedCounter+"+Domain=testDomain"].Value())
+		s.Equal(int64(1), counters[metrics.ReplayFailedCounter+"+Domain=testDomain"].Value())
+
+		content, err := ioutil.ReadFile(reportPath)
+		s.NoError(err)
+		switch format {
+		case ShadowReportFormatJUnit:
+			var report junitTestSuites
+			s.NoError(xml.Unmarshal(content, &report))
+			s.Len(report.Suites, 1)
+			s.Equal(3, report.Suites[0].Tests)
+			s.Equal(1, report.Suites[0].Failures)
+			s.Equal(1, report.Suites[0].Skipped)
+			s.NotNil(report.Suites[0].TestCases[2].Failure)
+		case ShadowReportFormatJSON:
+			var report ShadowReport
+			s.NoError(json.Unmarshal(content, &report))
+			s.Equal("testDomain", report.Domain)
+			s.Equal(1, report.Succeeded)
+			s.Equal(1, report.Skipped)
+			s.Equal(1, report.Failed)
+			s.Equal([]string{shadowResultSucceeded, shadowResultSkipped, shadowResultFailed}, []string{
+				report.Executions[0].Result, report.Executions[1].Result, report.Executions[2].Result})
+			s.NotEmpty(report.Error)
+		}
+	}
+}
+
 func (s *workflowShadowerSuite) TestShadowWorker_ExpectedReplayError() {
 	testCases := []struct {
 		msg                string
@@ -0,0 +1,262 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWorkflowSnapshotInterval = 1000
+	workflowSnapshotStoreTimeout    = 10 * time.Second
+)
+
+var errWorkflowSnapshotNotRestored = errors.New("workflow did not restore its snapshot with SetSnapshotter during " +
+	"the decision task the snapshot was taken for, the snapshot is deleted and the history is replayed from the " +
+	"beginning by the next attempt")
+
+type (
+	// WorkflowSnapshotter saves and restores the state of a workflow execution, see SetWorkflowSnapshotter.
+	// EXPERIMENTAL: the snapshot API may change in future releases.
+	WorkflowSnapshotter interface {
+		// Snapshot encodes the state of the workflow. It is called at the end of a decision task, while the workflow
+		// is blocked, so it must not block or call any workflow API.
+		Snapshot() ([]byte, error)
+		// Restore replaces the state of the workflow with a state encoded by Snapshot.
+		Restore(state []byte) error
+	}
+
+	// WorkflowSnapshot is the state of a workflow execution after it processed the history events up to
+	// LastProcessedEventID, see WorkerOptions.WorkflowSnapshotStore.
+	// EXPERIMENTAL: the snapshot API may change in future releases.
+	WorkflowSnapshot struct {
+		WorkflowID           string
+		RunID                string
+		LastProcessedEventID int64
+		// State is the workflow state encoded by its WorkflowSnapshotter.
+		State []byte
+		// InternalState is the state the client library keeps for the execution, it is opaque to the store.
+		InternalState []byte
+	}
+
+	// WorkflowSnapshotStore is the external store of the workflow snapshots, see WorkerOptions.WorkflowSnapshotStore.
+	// It is shared by all workers that run the workflows, so that any of them can resume a workflow from the
+	// snapshot saved by another one.
+	// EXPERIMENTAL: the snapshot API may change in future releases.
+	WorkflowSnapshotStore interface {
+		// SaveSnapshot replaces the snapshot of the workflow execution.
+		SaveSnapshot(ctx context.Context, domain string, snapshot *WorkflowSnapshot) error
+		// LoadSnapshot returns the snapshot of the workflow execution, or nil if it has none.
+		LoadSnapshot(ctx context.Context, domain, workflowID, runID string) (*WorkflowSnapshot, error)
+		// DeleteSnapshot deletes the snapshot of the workflow execution, if it has one.
+		DeleteSnapshot(ctx context.Context, domain, workflowID, runID string) error
+	}
+
+	// workflowSnapshotInternalState is the state of the workflow environment that is needed to resume the
+	// execution deterministically.
+	workflowSnapshotInternalState struct {
+		CounterID         int32
+		ChangeVersions    map[string]Version
+		MutableSideEffect map[string][]byte
+		SearchAttributes  map[string][]byte
+	}
+)
+
+// SetWorkflowSnapshotter lets the worker snapshot the state of the workflow execution to the
+// WorkerOptions.WorkflowSnapshotStore, so that a worker without the execution in its sticky cache resumes it from
+// the snapshot instead of replaying the whole history. It returns true if the state was just restored from a
+// snapshot, in which case the workflow must continue from the restored state instead of starting over.
+// The workflow function is still called from its start when it is resumed, so SetWorkflowSnapshotter must be called
+// at its very beginning, before any other workflow API. A snapshot is only taken at the end of a decision task that
+// made no decisions, while the workflow has no pending activities, timers, child workflows, local activities,
+// sessions, cancellation request or unreceived signals, i.e. while it waits for a signal. The goroutines started by
+// the workflow are not part of the snapshot, the restored state has to describe them.
+// EXPERIMENTAL: the snapshot API may change in future releases.
+func SetWorkflowSnapshotter(ctx Context, snapshotter WorkflowSnapshotter) (bool, error) {
+	return getWorkflowEnvironment(ctx).SetSnapshotter(snapshotter)
+}
+
+func (wc *workflowEnvironmentImpl) SetSnapshotter(snapshotter WorkflowSnapshotter) (bool, error) {
+	wc.snapshotter = snapshotter
+	snapshot := wc.resumeSnapshot
+	if snapshot == nil {
+		return false, nil
+	}
+
+	// the snapshot stays pending if it can't be restored, which fails the decision task
+	var internalState workflowSnapshotInternalState
+	if err := json.Unmarshal(snapshot.InternalState, &internalState); err != nil {
+		return false, err
+	}
+	if err := snapshotter.Restore(snapshot.State); err != nil {
+		return false, err
+	}
+	wc.counterID = internalState.CounterID
+	for changeID, version := range internalState.ChangeVersions {
+		wc.changeVersions[changeID] = version
+	}
+	for id, value := range internalState.MutableSideEffect {
+		wc.mutableSideEffect[id] = value
+	}
+	if len(internalState.SearchAttributes) > 0 {
+		wc.workflowInfo.SearchAttributes = &shared.SearchAttributes{IndexedFields: internalState.SearchAttributes}
+	}
+	wc.resumeSnapshot = nil
+	return true, nil
+}
+
+// takeSnapshot returns nil if the workflow has no snapshotter or has pending work that is not part of a snapshot.
+func (wc *workflowEnvironmentImpl) takeSnapshot(lastProcessedEventID int64) (*WorkflowSnapshot, error) {
+	if wc.snapshotter == nil || wc.cancelRequested || len(wc.decisionsHelper.decisions) > 0 ||
+		len(wc.pendingLaTasks) > 0 || len(wc.openSessions) > 0 || wc.workflowInfo.PendingSignals() > 0 {
+		return nil, nil
+	}
+	state, err := wc.snapshotter.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	internalState := workflowSnapshotInternalState{
+		CounterID:         wc.counterID,
+		ChangeVersions:    wc.changeVersions,
+		MutableSideEffect: wc.mutableSideEffect,
+	}
+	if wc.workflowInfo.SearchAttributes != nil {
+		internalState.SearchAttributes = wc.workflowInfo.SearchAttributes.IndexedFields
+	}
+	encodedInternalState, err := json.Marshal(internalState)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkflowSnapshot{
+		WorkflowID:           wc.workflowInfo.WorkflowExecution.ID,
+		RunID:                wc.workflowInfo.WorkflowExecution.RunID,
+		LastProcessedEventID: lastProcessedEventID,
+		State:                state,
+		InternalState:        encodedInternalState,
+	}, nil
+}
+
+func getWorkflowSnapshotInterval(params workerExecutionParameters) int64 {
+	if params.WorkflowSnapshotInterval <= 0 {
+		return defaultWorkflowSnapshotInterval
+	}
+	return params.WorkflowSnapshotInterval
+}
+
+// loadSnapshot returns the snapshot to resume the execution from, or nil if the task is replayed from the
+// beginning of the history.
+func (w *workflowExecutionContextImpl) loadSnapshot(task *shared.PollForDecisionTaskResponse) *WorkflowSnapshot {
+	store := w.wth.snapshotStore
+	if store == nil || w.previousStartedEventID != 0 || !isFullHistory(task.History) ||
+		task.GetStartedEventId() < w.wth.snapshotInterval {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), workflowSnapshotStoreTimeout)
+	defer cancel()
+	execution := task.WorkflowExecution
+	snapshot, err := store.LoadSnapshot(ctx, w.workflowInfo.Domain, execution.GetWorkflowId(), execution.GetRunId())
+	if err != nil {
+		w.wth.logger.Warn("Failed to load workflow snapshot, replaying the whole history.",
+			zap.String(tagWorkflowID, execution.GetWorkflowId()),
+			zap.String(tagRunID, execution.GetRunId()),
+			zap.Error(err))
+		return nil
+	}
+	if snapshot == nil || snapshot.RunID != execution.GetRunId() || snapshot.LastProcessedEventID <= 1 ||
+		snapshot.LastProcessedEventID > task.GetStartedEventId() {
+		return nil
+	}
+	w.lastSnapshotEventID = snapshot.LastProcessedEventID
+	return snapshot
+}
+
+// saveSnapshotIfDue saves a snapshot of the execution after a decision task that made no decisions, if
+// WorkerOptions.WorkflowSnapshotInterval events were processed since the last one. The snapshot of a closed execution
+// is deleted.
+func (w *workflowExecutionContextImpl) saveSnapshotIfDue(completeRequest interface{}) {
+	store := w.wth.snapshotStore
+	eventHandler := w.getEventHandler()
+	if store == nil || eventHandler == nil || eventHandler.snapshotter == nil {
+		return
+	}
+	task := w.currentDecisionTask
+	domain := w.workflowInfo.Domain
+	execution := w.workflowInfo.WorkflowExecution
+	metricsScope := w.wth.metricsScope.GetTaggedScope(tagWorkflowType, w.workflowInfo.WorkflowType.Name)
+	logger := w.wth.logger.With(zap.String(tagWorkflowID, execution.ID), zap.String(tagRunID, execution.RunID))
+
+	if w.isWorkflowCompleted {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), workflowSnapshotStoreTimeout)
+			defer cancel()
+			if err := store.DeleteSnapshot(ctx, domain, execution.ID, execution.RunID); err != nil {
+				logger.Warn("Failed to delete workflow snapshot.", zap.Error(err))
+			}
+		}()
+		return
+	}
+
+	request, ok := completeRequest.(*shared.RespondDecisionTaskCompletedRequest)
+	if !ok || len(request.Decisions) > 0 || task.Query != nil ||
+		task.GetStartedEventId()-w.lastSnapshotEventID < w.wth.snapshotInterval {
+		return
+	}
+	snapshot, err := eventHandler.takeSnapshot(task.GetStartedEventId())
+	if err != nil {
+		metricsScope.Counter(metrics.WorkflowSnapshotFailed).Inc(1)
+		logger.Warn("Failed to snapshot workflow.", zap.Error(err))
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+	w.lastSnapshotEventID = snapshot.LastProcessedEventID
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), workflowSnapshotStoreTimeout)
+		defer cancel()
+		if err := store.SaveSnapshot(ctx, domain, snapshot); err != nil {
+			metricsScope.Counter(metrics.WorkflowSnapshotFailed).Inc(1)
+			logger.Warn("Failed to save workflow snapshot.", zap.Error(err))
+			return
+		}
+		metricsScope.Counter(metrics.WorkflowSnapshotSaved).Inc(1)
+	}()
+}
+
+// deleteUnrestoredSnapshot deletes a snapshot the workflow did not restore, so the next attempt of the decision
+// task replays the whole history.
+func (w *workflowExecutionContextImpl) deleteUnrestoredSnapshot(snapshot *WorkflowSnapshot) error {
+	ctx, cancel := context.WithTimeout(context.Background(), workflowSnapshotStoreTimeout)
+	defer cancel()
+	if err := w.wth.snapshotStore.DeleteSnapshot(ctx, w.workflowInfo.Domain, snapshot.WorkflowID, snapshot.RunID); err != nil {
+		return err
+	}
+	return errWorkflowSnapshotNotRestored
+}
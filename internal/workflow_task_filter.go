@@ -0,0 +1,110 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common/metrics"
+)
+
+type (
+	// WorkflowTaskFilter decides whether a worker processes the workflow executions it polls, see
+	// WorkerOptions.WorkflowTaskFilter. Returning an error rejects the workflow execution: its decision task is
+	// failed with the error and rescheduled by the server, so that it can be picked up by another worker polling the
+	// same task list.
+	WorkflowTaskFilter func(info WorkflowTaskFilterInfo) error
+
+	// WorkflowTaskFilterInfo is the metadata of a workflow execution passed to a WorkflowTaskFilter.
+	WorkflowTaskFilterInfo struct {
+		Domain            string
+		TaskList          string
+		WorkflowType      string
+		WorkflowExecution WorkflowExecution
+		// Header is the header the workflow execution was started with.
+		Header HeaderReader
+	}
+)
+
+// filterWorkflowTask calls the WorkflowTaskFilter of the worker for the decision tasks holding the history of a
+// workflow execution from its start. Decision tasks starting later in the history come from the sticky cache of
+// the worker, so the workflow execution was already accepted. Query tasks are not filtered, failing them as decision
+// tasks would fail the decision task of the workflow execution instead of answering the query.
+func (wtp *workflowTaskPoller) filterWorkflowTask(task *s.PollForDecisionTaskResponse) error {
+	if wtp.workflowTaskFilter == nil || task.Query != nil || task.History == nil || len(task.History.Events) == 0 {
+		return nil
+	}
+	attributes := task.History.Events[0].WorkflowExecutionStartedEventAttributes
+	if attributes == nil {
+		return nil
+	}
+	return wtp.workflowTaskFilter(WorkflowTaskFilterInfo{
+		Domain:       wtp.domain,
+		TaskList:     attributes.TaskList.GetName(),
+		WorkflowType: task.WorkflowType.GetName(),
+		WorkflowExecution: WorkflowExecution{
+			ID:    task.WorkflowExecution.GetWorkflowId(),
+			RunID: task.WorkflowExecution.GetRunId(),
+		},
+		Header: NewHeaderReader(attributes.Header),
+	})
+}
+
+// isUnregisteredWorkflowType tells whether the decision task is rejected because its workflow type is not registered
+// with the worker, see WorkerOptions.RejectUnregisteredWorkflowTypes. Query tasks are not rejected.
+func (wtp *workflowTaskPoller) isUnregisteredWorkflowType(task *s.PollForDecisionTaskResponse) bool {
+	if !wtp.rejectUnregisteredTypes || task.Query != nil {
+		return false
+	}
+	handler, ok := wtp.taskHandler.(*workflowTaskHandlerImpl)
+	if !ok {
+		return false
+	}
+	return !handler.registry.hasWorkflowType(task.WorkflowType.GetName())
+}
+
+// rejectUnregisteredWorkflowType fails the decision task of a workflow type not registered with the worker, so that
+// it is rescheduled by the server for another worker. With a requeue delay, the decision task is failed after the
+// delay or once the worker stops.
+func (wtp *workflowTaskPoller) rejectUnregisteredWorkflowType(task *s.PollForDecisionTaskResponse) error {
+	workflowType := task.WorkflowType.GetName()
+	wtp.metricsScope.GetTaggedScope(tagWorkflowType, workflowType).Counter(metrics.UnregisteredWorkflowTypeCounter).Inc(1)
+	if wtp.unregisteredTypeRequeueDelay > 0 {
+		timer := time.NewTimer(wtp.unregisteredTypeRequeueDelay)
+		select {
+		case <-timer.C:
+		case <-wtp.shutdownC:
+			timer.Stop()
+		}
+	} else {
+		wtp.logger.Warn("Workflow task rejected for unregistered workflow type.",
+			zap.String(tagWorkflowType, workflowType),
+			zap.String(tagWorkflowID, task.WorkflowExecution.GetWorkflowId()),
+			zap.String(tagRunID, task.WorkflowExecution.GetRunId()))
+	}
+	err := fmt.Errorf("%v: %v", errMsgUnknownWorkflowType, workflowType)
+	_, err = wtp.RespondTaskCompleted(errorToFailDecisionTask(task.TaskToken, err, wtp.identity), task)
+	return err
+}
@@ -140,6 +140,17 @@ func (t *TestActivityEnvironment) RegisterActivityWithOptions(a interface{}, opt
 	t.impl.RegisterActivityWithOptions(a, options)
 }
 
+// RegisterActivityFactory registers the methods of the activity struct created per execution by factory with
+// TestActivityEnvironment
+func (t *TestActivityEnvironment) RegisterActivityFactory(factory interface{}, options RegisterActivityOptions) {
+	t.impl.RegisterActivityFactory(factory, options)
+}
+
+// RegisterActivityStruct registers the exported methods of an activity struct with TestActivityEnvironment
+func (t *TestActivityEnvironment) RegisterActivityStruct(a interface{}) {
+	t.impl.RegisterActivityStruct(a)
+}
+
 // ExecuteActivity executes an activity. The tested activity will be executed synchronously in the calling goroutinue.
 // Caller should use Value.Get() to extract strong typed result value.
 func (t *TestActivityEnvironment) ExecuteActivity(activityFn interface{}, args ...interface{}) (Value, error) {
@@ -152,6 +163,14 @@ func (t *TestActivityEnvironment) ExecuteLocalActivity(activityFn interface{}, a
 	return t.impl.executeLocalActivity(activityFn, args...)
 }
 
+// CompleteActivityByToken completes an activity that had returned activity.ErrResultPending from ExecuteActivity, as
+// client.CompleteActivity does for a worker. The task token is the one returned by activity.GetInfo() within the
+// activity. It returns the result or error of the activity the same way ExecuteActivity does, and an error if there
+// is no pending activity for the task token.
+func (t *TestActivityEnvironment) CompleteActivityByToken(taskToken []byte, result interface{}, err error) (Value, error) {
+	return t.impl.completeActivityByToken(taskToken, result, err)
+}
+
 // SetWorkerOptions sets the WorkerOptions that will be use by TestActivityEnvironment. TestActivityEnvironment will
 // use options of Identity, MetricsScope and BackgroundActivityContext on the WorkerOptions. Other options are ignored.
 // Note: WorkerOptions is defined in internal package, use public type worker.Options instead.
@@ -205,6 +224,30 @@ func (t *TestWorkflowEnvironment) RegisterActivityWithOptions(a interface{}, opt
 	t.impl.RegisterActivityWithOptions(a, options)
 }
 
+// RegisterWorkflowStruct registers the exported methods of a workflow struct
+func (t *TestWorkflowEnvironment) RegisterWorkflowStruct(w interface{}) {
+	if len(t.ExpectedCalls) > 0 {
+		panic("RegisterWorkflow calls cannot follow mock related ones like OnWorkflow or similar")
+	}
+	t.impl.RegisterWorkflowStruct(w)
+}
+
+// RegisterActivityFactory registers the methods of the activity struct created per execution by factory
+func (t *TestWorkflowEnvironment) RegisterActivityFactory(factory interface{}, options RegisterActivityOptions) {
+	if len(t.ExpectedCalls) > 0 {
+		panic("RegisterActivity calls cannot follow mock related ones like OnActivity or similar")
+	}
+	t.impl.RegisterActivityFactory(factory, options)
+}
+
+// RegisterActivityStruct registers the exported methods of an activity struct
+func (t *TestWorkflowEnvironment) RegisterActivityStruct(a interface{}) {
+	if len(t.ExpectedCalls) > 0 {
+		panic("RegisterActivity calls cannot follow mock related ones like OnActivity or similar")
+	}
+	t.impl.RegisterActivityStruct(a)
+}
+
 // SetStartTime sets the start time of the workflow. This is optional, default start time will be the wall clock time when
 // workflow starts. Start time is the workflow.Now(ctx) time at the beginning of the workflow.
 func (t *TestWorkflowEnvironment) SetStartTime(startTime time.Time) {
@@ -593,6 +636,12 @@ func (t *TestWorkflowEnvironment) CompleteActivity(taskToken []byte, result inte
 	return t.impl.CompleteActivity(taskToken, result, err)
 }
 
+// CompleteActivityByID complete an activity of the test workflow that had returned activity.ErrResultPending error,
+// identified by the ActivityID of its ActivityOptions, as client.CompleteActivityByID does for a worker.
+func (t *TestWorkflowEnvironment) CompleteActivityByID(activityID string, result interface{}, err error) error {
+	return t.impl.completeActivityByID(activityID, result, err)
+}
+
 // CancelWorkflow requests cancellation (through workflow Context) to the currently running test workflow.
 func (t *TestWorkflowEnvironment) CancelWorkflow() {
 	t.impl.cancelWorkflow(func(result []byte, err error) {})
@@ -106,6 +106,29 @@ func (_m *Client) CountWorkflow(ctx context.Context, request *shared.CountWorkfl
 	return r0, r1
 }
 
+// CountWorkflowWithOptions provides a mock function with given fields: ctx, request
+func (_m *Client) CountWorkflowWithOptions(ctx context.Context, request *client.CountWorkflowWithOptionsRequest) (*client.CountWorkflowWithOptionsResponse, error) {
+	ret := _m.Called(ctx, request)
+
+	var r0 *client.CountWorkflowWithOptionsResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *client.CountWorkflowWithOptionsRequest) *client.CountWorkflowWithOptionsResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*client.CountWorkflowWithOptionsResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *client.CountWorkflowWithOptionsRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DescribeTaskList provides a mock function with given fields: ctx, tasklist, tasklistType
 func (_m *Client) DescribeTaskList(ctx context.Context, tasklist string, tasklistType shared.TaskListType) (*shared.DescribeTaskListResponse, error) {
 	ret := _m.Called(ctx, tasklist, tasklistType)
@@ -201,6 +224,20 @@ func (_m *Client) GetSearchAttributes(ctx context.Context) (*shared.GetSearchAtt
 	return r0, r1
 }
 
+// HealthCheck provides a mock function with given fields: ctx
+func (_m *Client) HealthCheck(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetWorkflow provides a mock function with given fields: ctx, workflowID, runID
 func (_m *Client) GetWorkflow(ctx context.Context, workflowID string, runID string) client.WorkflowRun {
 	ret := _m.Called(ctx, workflowID, runID)
@@ -233,6 +270,22 @@ func (_m *Client) GetWorkflowHistory(ctx context.Context, workflowID string, run
 	return r0
 }
 
+// GetWorkflowHistoryWithOptions provides a mock function with given fields: ctx, request
+func (_m *Client) GetWorkflowHistoryWithOptions(ctx context.Context, request *client.GetWorkflowHistoryWithOptionsRequest) client.HistoryEventIterator {
+	ret := _m.Called(ctx, request)
+
+	var r0 internal.HistoryEventIterator
+	if rf, ok := ret.Get(0).(func(context.Context, *client.GetWorkflowHistoryWithOptionsRequest) internal.HistoryEventIterator); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(internal.HistoryEventIterator)
+		}
+	}
+
+	return r0
+}
+
 // ListClosedWorkflow provides a mock function with given fields: ctx, request
 func (_m *Client) ListClosedWorkflow(ctx context.Context, request *shared.ListClosedWorkflowExecutionsRequest) (*shared.ListClosedWorkflowExecutionsResponse, error) {
 	ret := _m.Called(ctx, request)
@@ -351,6 +404,29 @@ func (_m *Client) QueryWorkflow(ctx context.Context, workflowID string, runID st
 	return r0, r1
 }
 
+// QueryWorkerMetadata provides a mock function with given fields: ctx, workflowID, runID
+func (_m *Client) QueryWorkerMetadata(ctx context.Context, workflowID string, runID string) (*client.WorkerMetadata, error) {
+	ret := _m.Called(ctx, workflowID, runID)
+
+	var r0 *client.WorkerMetadata
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *client.WorkerMetadata); ok {
+		r0 = rf(ctx, workflowID, runID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*client.WorkerMetadata)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, workflowID, runID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // QueryWorkflowWithOptions provides a mock function with given fields: ctx, request
 func (_m *Client) QueryWorkflowWithOptions(ctx context.Context, request *client.QueryWorkflowWithOptionsRequest) (*client.QueryWorkflowWithOptionsResponse, error) {
 	var _ca []interface{}
@@ -400,6 +476,29 @@ func (_m *Client) ResetWorkflow(ctx context.Context, request *s.ResetWorkflowExe
 	return r0, r1
 }
 
+// ResetWorkflowExecution provides a mock function with given fields: ctx, options
+func (_m *Client) ResetWorkflowExecution(ctx context.Context, options client.ResetOptions) (*workflow.Execution, error) {
+	ret := _m.Called(ctx, options)
+
+	var r0 *workflow.Execution
+	if rf, ok := ret.Get(0).(func(context.Context, client.ResetOptions) *workflow.Execution); ok {
+		r0 = rf(ctx, options)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*workflow.Execution)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, client.ResetOptions) error); ok {
+		r1 = rf(ctx, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RecordActivityHeartbeat provides a mock function with given fields: ctx, taskToken, details
 func (_m *Client) RecordActivityHeartbeat(ctx context.Context, taskToken []byte, details ...interface{}) error {
 	var _ca []interface{}
@@ -497,6 +596,22 @@ func (_m *Client) SignalWorkflow(ctx context.Context, workflowID string, runID s
 	return r0
 }
 
+// SignalWorkflows provides a mock function with given fields: ctx, requests, concurrency
+func (_m *Client) SignalWorkflows(ctx context.Context, requests []client.SignalRequest, concurrency int) []error {
+	ret := _m.Called(ctx, requests, concurrency)
+
+	var r0 []error
+	if rf, ok := ret.Get(0).(func(context.Context, []client.SignalRequest, int) []error); ok {
+		r0 = rf(ctx, requests, concurrency)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]error)
+		}
+	}
+
+	return r0
+}
+
 // StartWorkflow provides a mock function with given fields: ctx, options, workflow, args
 func (_m *Client) StartWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (*workflow.Execution, error) {
 	var _ca []interface{}
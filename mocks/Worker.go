@@ -0,0 +1,164 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Code generated by mockery v1.0.0
+// Modified manually for type alias to work correctly.
+// https://github.com/vektra/mockery/issues/236
+package mocks
+
+import activity "go.uber.org/cadence/activity"
+import mock "github.com/stretchr/testify/mock"
+import worker "go.uber.org/cadence/worker"
+import workflow "go.uber.org/cadence/workflow"
+
+// Worker is an autogenerated mock type for the Worker type
+type Worker struct {
+	mock.Mock
+}
+
+// GetRegisteredActivities provides a mock function with given fields:
+func (_m *Worker) GetRegisteredActivities() []worker.RegisteredActivityInfo {
+	ret := _m.Called()
+
+	var r0 []worker.RegisteredActivityInfo
+	if rf, ok := ret.Get(0).(func() []worker.RegisteredActivityInfo); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]worker.RegisteredActivityInfo)
+		}
+	}
+
+	return r0
+}
+
+// GetRegisteredWorkflows provides a mock function with given fields:
+func (_m *Worker) GetRegisteredWorkflows() []worker.RegisteredWorkflowInfo {
+	ret := _m.Called()
+
+	var r0 []worker.RegisteredWorkflowInfo
+	if rf, ok := ret.Get(0).(func() []worker.RegisteredWorkflowInfo); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]worker.RegisteredWorkflowInfo)
+		}
+	}
+
+	return r0
+}
+
+// RegisterActivity provides a mock function with given fields: a
+func (_m *Worker) RegisterActivity(a interface{}) {
+	_m.Called(a)
+}
+
+// RegisterActivityFactory provides a mock function with given fields: factory, options
+func (_m *Worker) RegisterActivityFactory(factory interface{}, options activity.RegisterOptions) {
+	_m.Called(factory, options)
+}
+
+// RegisterActivityStruct provides a mock function with given fields: a
+func (_m *Worker) RegisterActivityStruct(a interface{}) {
+	_m.Called(a)
+}
+
+// RegisterActivityWithOptions provides a mock function with given fields: a, options
+func (_m *Worker) RegisterActivityWithOptions(a interface{}, options activity.RegisterOptions) {
+	_m.Called(a, options)
+}
+
+// RegisterWorkflow provides a mock function with given fields: w
+func (_m *Worker) RegisterWorkflow(w interface{}) {
+	_m.Called(w)
+}
+
+// RegisterWorkflowStruct provides a mock function with given fields: w
+func (_m *Worker) RegisterWorkflowStruct(w interface{}) {
+	_m.Called(w)
+}
+
+// RegisterWorkflowWithOptions provides a mock function with given fields: w, options
+func (_m *Worker) RegisterWorkflowWithOptions(w interface{}, options workflow.RegisterOptions) {
+	_m.Called(w, options)
+}
+
+// Run provides a mock function with given fields:
+func (_m *Worker) Run() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Start provides a mock function with given fields:
+func (_m *Worker) Start() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Status provides a mock function with given fields:
+func (_m *Worker) Status() worker.Status {
+	ret := _m.Called()
+
+	var r0 worker.Status
+	if rf, ok := ret.Get(0).(func() worker.Status); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(worker.Status)
+	}
+
+	return r0
+}
+
+// Stop provides a mock function with given fields:
+func (_m *Worker) Stop() {
+	_m.Called()
+}
+
+// Unhealthy provides a mock function with given fields:
+func (_m *Worker) Unhealthy() <-chan worker.Status {
+	ret := _m.Called()
+
+	var r0 <-chan worker.Status
+	if rf, ok := ret.Get(0).(func() <-chan worker.Status); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan worker.Status)
+		}
+	}
+
+	return r0
+}
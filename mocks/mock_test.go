@@ -22,12 +22,14 @@ package mocks
 
 import (
 	"context"
+	"errors"
 	"go.uber.org/cadence/.gen/go/shared"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
 	"go.uber.org/cadence/workflow"
 )
 
@@ -95,3 +97,24 @@ func Test_MockClient(t *testing.T) {
 	require.NotNil(t, next)
 	require.NoError(t, err)
 }
+
+func Test_MockWorker(t *testing.T) {
+	testWorkflow := func(ctx workflow.Context) error { return nil }
+
+	mockWorker := &Worker{}
+	mockWorker.On("RegisterWorkflowWithOptions", mock.Anything, workflow.RegisterOptions{Name: "workflow"}).Once()
+	mockWorker.On("Start").Return(nil).Once()
+	mockWorker.On("Status").Return(worker.Status{StickyCacheSize: 1}).Once()
+	mockWorker.On("Stop").Once()
+
+	var w worker.Worker = mockWorker
+	w.RegisterWorkflowWithOptions(testWorkflow, workflow.RegisterOptions{Name: "workflow"})
+	require.NoError(t, w.Start())
+	require.Equal(t, 1, w.Status().StickyCacheSize)
+	w.Stop()
+	mockWorker.AssertExpectations(t)
+
+	mockWorker.On("Run").Return(errors.New("failed to start")).Once()
+	require.Error(t, mockWorker.Run())
+	mockWorker.AssertExpectations(t)
+}
@@ -22,8 +22,10 @@ package mocks
 
 import (
 	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/worker"
 )
 
 // make sure mocks are in sync with interfaces
 var _ client.Client = (*Client)(nil)
 var _ client.DomainClient = (*DomainClient)(nil)
+var _ worker.Worker = (*Worker)(nil)
@@ -387,7 +387,8 @@ func (ts *IntegrationTestSuite) TestWorkflowIDReuseErrorViaStartWorkflow() {
 	exec, err := ts.libClient.StartWorkflow(ctx, opts, ts.workflows.SimplestWorkflow)
 	ts.Nil(exec)
 	ts.Error(err)
-	ts.IsType(&shared.WorkflowExecutionAlreadyStartedError{}, err, "should be the known already-started error type")
+	ts.IsType(&client.WorkflowAlreadyStartedError{}, err, "should be the known already-started error type")
+	ts.True(cadence.IsWorkflowExecutionAlreadyStartedError(err), "should wrap the already-started error of the server")
 	ts.False(client.IsWorkflowError(err), "start-workflow rejected errors should not be workflow errors")
 }
 
@@ -0,0 +1,547 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+// activity is an activity scheduled by a workflow and not closed yet.
+type activity struct {
+	execution   *execution
+	scheduled   *shared.ActivityTaskScheduledEventAttributes
+	scheduledID int64
+	domain      string
+
+	attempt              int32
+	scheduledTime        time.Time
+	attemptScheduledTime time.Time
+	// expiration is when the retries of the activity stop, zero if the retries don't expire.
+	expiration time.Time
+
+	queued    bool
+	started   bool
+	startedAt time.Time
+	identity  string
+	token     string
+	// startedEvent is the started event of the current attempt. Without a retry policy it is recorded when the
+	// activity is started, with one it is recorded with the event closing the activity so that the history only
+	// shows the last attempt.
+	startedEvent    *shared.HistoryEvent
+	startedRecorded bool
+
+	heartbeatDetails  []byte
+	lastHeartbeatTime time.Time
+	cancelRequested   bool
+	cancelRequestedID *int64
+
+	lastFailureReason  *string
+	lastFailureDetails []byte
+
+	scheduleToStartTimer *timer
+	scheduleToCloseTimer *timer
+	startToCloseTimer    *timer
+	heartbeatTimer       *timer
+	retryTimer           *timer
+}
+
+func (act *activity) valid() bool {
+	return act.queued && act.current()
+}
+
+func (act *activity) inFlight() {}
+
+// current tells whether the activity is still pending in its workflow.
+func (act *activity) current() bool {
+	return !act.execution.closed() && act.execution.activities[act.scheduledID] == act
+}
+
+func (act *activity) taskListKey() taskListKey {
+	return taskListKey{domain: act.domain, name: act.scheduled.TaskList.GetName(), kind: activityTaskList}
+}
+
+func (act *activity) startedEventID() *int64 {
+	if act.startedEvent == nil {
+		return nil
+	}
+	return act.startedEvent.EventId
+}
+
+func (act *activity) describe() *shared.PendingActivityInfo {
+	info := &shared.PendingActivityInfo{
+		ActivityID:         act.scheduled.ActivityId,
+		ActivityType:       act.scheduled.ActivityType,
+		State:              shared.PendingActivityStateScheduled.Ptr(),
+		HeartbeatDetails:   act.heartbeatDetails,
+		Attempt:            common.Int32Ptr(act.attempt),
+		ScheduledTimestamp: common.Int64Ptr(act.attemptScheduledTime.UnixNano()),
+		LastFailureReason:  act.lastFailureReason,
+		LastFailureDetails: act.lastFailureDetails,
+	}
+	switch {
+	case act.cancelRequested:
+		info.State = shared.PendingActivityStateCancelRequested.Ptr()
+	case act.started:
+		info.State = shared.PendingActivityStateStarted.Ptr()
+	}
+	if act.started {
+		info.LastStartedTimestamp = common.Int64Ptr(act.startedAt.UnixNano())
+		info.LastWorkerIdentity = common.StringPtr(act.identity)
+	}
+	if !act.lastHeartbeatTime.IsZero() {
+		info.LastHeartbeatTimestamp = common.Int64Ptr(act.lastHeartbeatTime.UnixNano())
+	}
+	if act.scheduled.RetryPolicy != nil {
+		info.MaximumAttempts = act.scheduled.RetryPolicy.MaximumAttempts
+	}
+	if !act.expiration.IsZero() {
+		info.ExpirationTimestamp = common.Int64Ptr(act.expiration.UnixNano())
+	}
+	return info
+}
+
+// scheduleActivity adds an activity scheduled by a decision to the history of the workflow and to its task list.
+func (s *Server) scheduleActivity(e *execution, a *shared.ScheduleActivityTaskDecisionAttributes, completedEventID *int64) {
+	scheduleToClose := a.GetScheduleToCloseTimeoutSeconds()
+	scheduleToStart := a.GetScheduleToStartTimeoutSeconds()
+	startToClose := a.GetStartToCloseTimeoutSeconds()
+	if scheduleToClose <= 0 {
+		scheduleToClose = scheduleToStart + startToClose
+	}
+	if scheduleToStart <= 0 || scheduleToStart > scheduleToClose {
+		scheduleToStart = scheduleToClose
+	}
+	if startToClose <= 0 || startToClose > scheduleToClose {
+		startToClose = scheduleToClose
+	}
+	heartbeat := a.GetHeartbeatTimeoutSeconds()
+	if heartbeat < 0 {
+		heartbeat = 0
+	}
+	domain := a.GetDomain()
+	if domain == "" {
+		domain = e.domain
+	}
+
+	event := s.newEvent(shared.EventTypeActivityTaskScheduled)
+	event.ActivityTaskScheduledEventAttributes = &shared.ActivityTaskScheduledEventAttributes{
+		ActivityId:                    a.ActivityId,
+		ActivityType:                  a.ActivityType,
+		Domain:                        common.StringPtr(domain),
+		TaskList:                      a.TaskList,
+		Input:                         a.Input,
+		ScheduleToCloseTimeoutSeconds: common.Int32Ptr(scheduleToClose),
+		ScheduleToStartTimeoutSeconds: common.Int32Ptr(scheduleToStart),
+		StartToCloseTimeoutSeconds:    common.Int32Ptr(startToClose),
+		HeartbeatTimeoutSeconds:       common.Int32Ptr(heartbeat),
+		DecisionTaskCompletedEventId:  completedEventID,
+		RetryPolicy:                   a.RetryPolicy,
+		Header:                        a.Header,
+	}
+	e.appendEvent(event)
+
+	act := &activity{
+		execution:     e,
+		scheduled:     event.ActivityTaskScheduledEventAttributes,
+		scheduledID:   event.GetEventId(),
+		domain:        domain,
+		scheduledTime: s.now(),
+	}
+	if expiration := a.RetryPolicy.GetExpirationIntervalInSeconds(); expiration > 0 {
+		act.expiration = act.scheduledTime.Add(seconds(expiration))
+	}
+	e.activities[act.scheduledID] = act
+	s.queueActivity(act)
+}
+
+// queueActivity adds an attempt of the activity to its task list.
+func (s *Server) queueActivity(act *activity) {
+	act.queued = true
+	act.attemptScheduledTime = s.now()
+	act.scheduleToStartTimer = s.addTimer(seconds(act.scheduled.GetScheduleToStartTimeoutSeconds()), func() {
+		s.timeoutActivity(act, shared.TimeoutTypeScheduleToStart)
+	})
+	act.scheduleToCloseTimer = s.addTimer(seconds(act.scheduled.GetScheduleToCloseTimeoutSeconds()), func() {
+		s.timeoutActivity(act, shared.TimeoutTypeScheduleToClose)
+	})
+	s.addTask(act.taskListKey(), act)
+}
+
+// PollForActivityTask long polls an activity task list.
+func (s *Server) PollForActivityTask(ctx context.Context, request *shared.PollForActivityTaskRequest, opts ...yarpc.CallOption) (*shared.PollForActivityTaskResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getDomain(request.GetDomain()); err != nil {
+		return nil, err
+	}
+	key := taskListKey{domain: request.GetDomain(), name: request.TaskList.GetName(), kind: activityTaskList}
+	act, ok := s.pollTask(ctx, key, request.GetIdentity()).(*activity)
+	if !ok {
+		return &shared.PollForActivityTaskResponse{}, nil
+	}
+	return s.startActivity(act, request.GetIdentity()), nil
+}
+
+func (s *Server) startActivity(act *activity, identity string) *shared.PollForActivityTaskResponse {
+	e := act.execution
+	act.queued = false
+	act.started = true
+	act.startedAt = s.now()
+	act.identity = identity
+	act.token = s.newTaskToken()
+	s.inFlightTasks[act.token] = act
+	act.scheduleToStartTimer.cancel()
+	act.startToCloseTimer = s.addTimer(seconds(act.scheduled.GetStartToCloseTimeoutSeconds()), func() {
+		s.timeoutActivity(act, shared.TimeoutTypeStartToClose)
+	})
+	s.resetHeartbeatTimer(act)
+
+	act.startedEvent = s.newEvent(shared.EventTypeActivityTaskStarted)
+	act.startedEvent.ActivityTaskStartedEventAttributes = &shared.ActivityTaskStartedEventAttributes{
+		ScheduledEventId:   common.Int64Ptr(act.scheduledID),
+		Identity:           common.StringPtr(identity),
+		RequestId:          common.StringPtr(uuid.New()),
+		Attempt:            common.Int32Ptr(act.attempt),
+		LastFailureReason:  act.lastFailureReason,
+		LastFailureDetails: act.lastFailureDetails,
+	}
+	act.startedRecorded = false
+	if act.scheduled.RetryPolicy == nil {
+		s.recordStarted(act)
+	}
+
+	return &shared.PollForActivityTaskResponse{
+		TaskToken:                       []byte(act.token),
+		WorkflowExecution:               e.workflowExecution(),
+		ActivityId:                      act.scheduled.ActivityId,
+		ActivityType:                    act.scheduled.ActivityType,
+		Input:                           act.scheduled.Input,
+		ScheduledTimestamp:              common.Int64Ptr(act.attemptScheduledTime.UnixNano()),
+		ScheduleToCloseTimeoutSeconds:   act.scheduled.ScheduleToCloseTimeoutSeconds,
+		StartedTimestamp:                common.Int64Ptr(act.startedAt.UnixNano()),
+		StartToCloseTimeoutSeconds:      act.scheduled.StartToCloseTimeoutSeconds,
+		HeartbeatTimeoutSeconds:         act.scheduled.HeartbeatTimeoutSeconds,
+		Attempt:                         common.Int32Ptr(act.attempt),
+		ScheduledTimestampOfThisAttempt: common.Int64Ptr(act.attemptScheduledTime.UnixNano()),
+		HeartbeatDetails:                act.heartbeatDetails,
+		WorkflowType:                    e.started.WorkflowType,
+		WorkflowDomain:                  common.StringPtr(e.domain),
+		Header:                          act.scheduled.Header,
+	}
+}
+
+func (s *Server) resetHeartbeatTimer(act *activity) {
+	act.heartbeatTimer.cancel()
+	if heartbeat := act.scheduled.GetHeartbeatTimeoutSeconds(); heartbeat > 0 {
+		act.heartbeatTimer = s.addTimer(seconds(heartbeat), func() {
+			s.timeoutActivity(act, shared.TimeoutTypeHeartbeat)
+		})
+	}
+}
+
+func (s *Server) recordStarted(act *activity) {
+	if act.startedEvent != nil && !act.startedRecorded {
+		act.startedRecorded = true
+		s.recordEvent(act.execution, act.startedEvent, false)
+	}
+}
+
+// stopAttempt stops the timers and the task of the current attempt of the activity.
+func (s *Server) stopAttempt(act *activity) {
+	act.scheduleToStartTimer.cancel()
+	act.scheduleToCloseTimer.cancel()
+	act.startToCloseTimer.cancel()
+	act.heartbeatTimer.cancel()
+	act.retryTimer.cancel()
+	if act.token != "" {
+		s.finishTask(act.token)
+		act.token = ""
+	}
+	act.queued = false
+	act.started = false
+}
+
+// closeActivity removes the activity from its workflow.
+func (s *Server) closeActivity(act *activity) {
+	s.stopAttempt(act)
+	delete(act.execution.activities, act.scheduledID)
+}
+
+// closeActivityWith records the event closing the activity after its started event.
+func (s *Server) closeActivityWith(act *activity, event *shared.HistoryEvent) {
+	s.recordStarted(act)
+	s.closeActivity(act)
+	s.recordEvent(act.execution, event, true)
+}
+
+// retryActivity schedules the next attempt of the activity if its retry policy allows it.
+func (s *Server) retryActivity(act *activity, reason string, details []byte) bool {
+	if act.cancelRequested {
+		return false
+	}
+	backoff, ok := retryBackoff(act.scheduled.RetryPolicy, act.attempt, reason, s.now(), act.expiration)
+	if !ok {
+		return false
+	}
+	s.stopAttempt(act)
+	act.attempt++
+	act.startedEvent = nil
+	act.lastFailureReason = common.StringPtr(reason)
+	act.lastFailureDetails = details
+	act.retryTimer = s.addTimer(backoff, func() {
+		s.queueActivity(act)
+	})
+	return true
+}
+
+func (s *Server) completeActivity(act *activity, result []byte, identity string) {
+	event := s.newEvent(shared.EventTypeActivityTaskCompleted)
+	event.ActivityTaskCompletedEventAttributes = &shared.ActivityTaskCompletedEventAttributes{
+		Result:           result,
+		ScheduledEventId: common.Int64Ptr(act.scheduledID),
+		StartedEventId:   act.startedEventID(),
+		Identity:         common.StringPtr(identity),
+	}
+	s.closeActivityWith(act, event)
+}
+
+func (s *Server) failActivity(act *activity, reason string, details []byte, identity string) {
+	if s.retryActivity(act, reason, details) {
+		return
+	}
+	event := s.newEvent(shared.EventTypeActivityTaskFailed)
+	event.ActivityTaskFailedEventAttributes = &shared.ActivityTaskFailedEventAttributes{
+		Reason:           common.StringPtr(reason),
+		Details:          details,
+		ScheduledEventId: common.Int64Ptr(act.scheduledID),
+		StartedEventId:   act.startedEventID(),
+		Identity:         common.StringPtr(identity),
+	}
+	s.closeActivityWith(act, event)
+}
+
+func (s *Server) cancelActivity(act *activity, details []byte, identity string) {
+	event := s.newEvent(shared.EventTypeActivityTaskCanceled)
+	event.ActivityTaskCanceledEventAttributes = &shared.ActivityTaskCanceledEventAttributes{
+		Details:                      details,
+		LatestCancelRequestedEventId: act.cancelRequestedID,
+		ScheduledEventId:             common.Int64Ptr(act.scheduledID),
+		StartedEventId:               act.startedEventID(),
+		Identity:                     common.StringPtr(identity),
+	}
+	s.closeActivityWith(act, event)
+}
+
+// timeoutActivity times out the activity. The attempts timing out while started are retried by the retry policy of
+// the activity.
+func (s *Server) timeoutActivity(act *activity, timeoutType shared.TimeoutType) {
+	if !act.current() {
+		return
+	}
+	if timeoutType == shared.TimeoutTypeStartToClose || timeoutType == shared.TimeoutTypeHeartbeat {
+		if s.retryActivity(act, timeoutReasonPrefix+timeoutType.String(), act.heartbeatDetails) {
+			return
+		}
+	}
+	event := s.newEvent(shared.EventTypeActivityTaskTimedOut)
+	event.ActivityTaskTimedOutEventAttributes = &shared.ActivityTaskTimedOutEventAttributes{
+		Details:            act.heartbeatDetails,
+		ScheduledEventId:   common.Int64Ptr(act.scheduledID),
+		StartedEventId:     act.startedEventID(),
+		TimeoutType:        timeoutType.Ptr(),
+		LastFailureReason:  act.lastFailureReason,
+		LastFailureDetails: act.lastFailureDetails,
+	}
+	s.closeActivityWith(act, event)
+}
+
+// requestCancelActivity requests the cancellation of an activity by a decision and tells whether it requires a new
+// decision task. An activity not started yet is canceled right away.
+func (s *Server) requestCancelActivity(e *execution, activityID string, completedEventID *int64, identity string) bool {
+	var act *activity
+	for _, a := range e.activities {
+		if a.scheduled.GetActivityId() == activityID {
+			act = a
+			break
+		}
+	}
+	if act == nil {
+		event := s.newEvent(shared.EventTypeRequestCancelActivityTaskFailed)
+		event.RequestCancelActivityTaskFailedEventAttributes = &shared.RequestCancelActivityTaskFailedEventAttributes{
+			ActivityId:                   common.StringPtr(activityID),
+			Cause:                        common.StringPtr("ACTIVITY_ID_UNKNOWN"),
+			DecisionTaskCompletedEventId: completedEventID,
+		}
+		e.appendEvent(event)
+		return true
+	}
+
+	event := s.newEvent(shared.EventTypeActivityTaskCancelRequested)
+	event.ActivityTaskCancelRequestedEventAttributes = &shared.ActivityTaskCancelRequestedEventAttributes{
+		ActivityId:                   common.StringPtr(activityID),
+		DecisionTaskCompletedEventId: completedEventID,
+	}
+	e.appendEvent(event)
+	act.cancelRequested = true
+	act.cancelRequestedID = event.EventId
+	if act.started {
+		return false
+	}
+	s.closeActivity(act)
+	canceled := s.newEvent(shared.EventTypeActivityTaskCanceled)
+	canceled.ActivityTaskCanceledEventAttributes = &shared.ActivityTaskCanceledEventAttributes{
+		LatestCancelRequestedEventId: act.cancelRequestedID,
+		ScheduledEventId:             common.Int64Ptr(act.scheduledID),
+		Identity:                     common.StringPtr(identity),
+	}
+	e.appendEvent(canceled)
+	return true
+}
+
+func (s *Server) getActivity(token []byte) (*activity, error) {
+	act, ok := s.inFlightTasks[string(token)].(*activity)
+	if !ok || !act.current() || !act.started {
+		return nil, &shared.EntityNotExistsError{Message: "Activity task not found."}
+	}
+	return act, nil
+}
+
+func (s *Server) getActivityByID(domain, workflowID, runID, activityID string) (*activity, error) {
+	e, err := s.getOpenExecution(domain, &shared.WorkflowExecution{WorkflowId: common.StringPtr(workflowID), RunId: common.StringPtr(runID)})
+	if err != nil {
+		return nil, err
+	}
+	for _, act := range e.activities {
+		if act.scheduled.GetActivityId() == activityID && act.started {
+			return act, nil
+		}
+	}
+	return nil, &shared.EntityNotExistsError{Message: "Activity task not found."}
+}
+
+func (s *Server) heartbeat(act *activity, details []byte) *shared.RecordActivityTaskHeartbeatResponse {
+	act.heartbeatDetails = details
+	act.lastHeartbeatTime = s.now()
+	s.resetHeartbeatTimer(act)
+	return &shared.RecordActivityTaskHeartbeatResponse{CancelRequested: common.BoolPtr(act.cancelRequested)}
+}
+
+// RecordActivityTaskHeartbeat records the heartbeat of an activity and tells whether its cancellation was requested.
+func (s *Server) RecordActivityTaskHeartbeat(ctx context.Context, request *shared.RecordActivityTaskHeartbeatRequest, opts ...yarpc.CallOption) (*shared.RecordActivityTaskHeartbeatResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	act, err := s.getActivity(request.TaskToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.heartbeat(act, request.Details), nil
+}
+
+// RecordActivityTaskHeartbeatByID records the heartbeat of an activity identified by its workflow and its ID.
+func (s *Server) RecordActivityTaskHeartbeatByID(ctx context.Context, request *shared.RecordActivityTaskHeartbeatByIDRequest, opts ...yarpc.CallOption) (*shared.RecordActivityTaskHeartbeatResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	act, err := s.getActivityByID(request.GetDomain(), request.GetWorkflowID(), request.GetRunID(), request.GetActivityID())
+	if err != nil {
+		return nil, err
+	}
+	return s.heartbeat(act, request.Details), nil
+}
+
+// RespondActivityTaskCompleted completes an activity.
+func (s *Server) RespondActivityTaskCompleted(ctx context.Context, request *shared.RespondActivityTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	act, err := s.getActivity(request.TaskToken)
+	if err != nil {
+		return err
+	}
+	s.completeActivity(act, request.Result, request.GetIdentity())
+	return nil
+}
+
+// RespondActivityTaskCompletedByID completes an activity identified by its workflow and its ID.
+func (s *Server) RespondActivityTaskCompletedByID(ctx context.Context, request *shared.RespondActivityTaskCompletedByIDRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	act, err := s.getActivityByID(request.GetDomain(), request.GetWorkflowID(), request.GetRunID(), request.GetActivityID())
+	if err != nil {
+		return err
+	}
+	s.completeActivity(act, request.Result, request.GetIdentity())
+	return nil
+}
+
+// RespondActivityTaskFailed fails an attempt of an activity, which is retried if the retry policy of the activity
+// allows it.
+func (s *Server) RespondActivityTaskFailed(ctx context.Context, request *shared.RespondActivityTaskFailedRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	act, err := s.getActivity(request.TaskToken)
+	if err != nil {
+		return err
+	}
+	s.failActivity(act, request.GetReason(), request.Details, request.GetIdentity())
+	return nil
+}
+
+// RespondActivityTaskFailedByID fails an attempt of an activity identified by its workflow and its ID.
+func (s *Server) RespondActivityTaskFailedByID(ctx context.Context, request *shared.RespondActivityTaskFailedByIDRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	act, err := s.getActivityByID(request.GetDomain(), request.GetWorkflowID(), request.GetRunID(), request.GetActivityID())
+	if err != nil {
+		return err
+	}
+	s.failActivity(act, request.GetReason(), request.Details, request.GetIdentity())
+	return nil
+}
+
+// RespondActivityTaskCanceled cancels an activity.
+func (s *Server) RespondActivityTaskCanceled(ctx context.Context, request *shared.RespondActivityTaskCanceledRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	act, err := s.getActivity(request.TaskToken)
+	if err != nil {
+		return err
+	}
+	s.cancelActivity(act, request.Details, request.GetIdentity())
+	return nil
+}
+
+// RespondActivityTaskCanceledByID cancels an activity identified by its workflow and its ID.
+func (s *Server) RespondActivityTaskCanceledByID(ctx context.Context, request *shared.RespondActivityTaskCanceledByIDRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	act, err := s.getActivityByID(request.GetDomain(), request.GetWorkflowID(), request.GetRunID(), request.GetActivityID())
+	if err != nil {
+		return err
+	}
+	s.cancelActivity(act, request.Details, request.GetIdentity())
+	return nil
+}
@@ -0,0 +1,816 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+// defaultChildDecisionTimeout is the decision task timeout of the child workflows started without one.
+const defaultChildDecisionTimeout = 10
+
+type (
+	// decisionTask is the pending decision task of a workflow.
+	decisionTask struct {
+		execution     *execution
+		scheduledID   int64
+		startedID     int64
+		attempt       int64
+		scheduledTime time.Time
+		startedTime   time.Time
+		started       bool
+		token         string
+		// sticky tells whether the task is dispatched to the sticky task list of the workflow.
+		sticky bool
+		// timer is the schedule to start timeout of a sticky task until it is started, then its start to close
+		// timeout.
+		timer *timer
+	}
+
+	// queryTask is a query of a workflow dispatched to a poller of its decision task list.
+	queryTask struct {
+		execution  *execution
+		query      *shared.WorkflowQuery
+		token      string
+		dispatched bool
+		done       bool
+		resultC    chan *shared.RespondQueryTaskCompletedRequest
+	}
+)
+
+func (d *decisionTask) valid() bool {
+	return !d.started && d.execution.decision == d && !d.execution.closed()
+}
+
+func (d *decisionTask) inFlight() {}
+
+func (q *queryTask) valid() bool {
+	return !q.dispatched && !q.done
+}
+
+func (q *queryTask) inFlight() {}
+
+func decisionTaskListKey(e *execution) taskListKey {
+	return taskListKey{domain: e.domain, name: e.taskList(), kind: decisionTaskList}
+}
+
+func (e *execution) clearStickiness() {
+	e.stickyTaskList = ""
+	e.stickyScheduleToStartTimeout = 0
+}
+
+// scheduleDecision schedules a decision task for the workflow unless it already has one.
+func (s *Server) scheduleDecision(e *execution) {
+	if e.closed() || e.decision != nil || e.decisionBackoff {
+		return
+	}
+	d := &decisionTask{execution: e, attempt: e.decisionAttempt, scheduledTime: s.now(), sticky: e.stickyTaskList != ""}
+	taskList := &shared.TaskList{Name: common.StringPtr(e.taskList())}
+	if d.sticky {
+		taskList = &shared.TaskList{Name: common.StringPtr(e.stickyTaskList), Kind: shared.TaskListKindSticky.Ptr()}
+	}
+	event := s.newEvent(shared.EventTypeDecisionTaskScheduled)
+	event.DecisionTaskScheduledEventAttributes = &shared.DecisionTaskScheduledEventAttributes{
+		TaskList:                   taskList,
+		StartToCloseTimeoutSeconds: e.started.TaskStartToCloseTimeoutSeconds,
+		Attempt:                    common.Int64Ptr(d.attempt),
+	}
+	e.appendEvent(event)
+	d.scheduledID = event.GetEventId()
+	s.dispatchDecision(d)
+}
+
+// dispatchDecision adds a scheduled decision task to the sticky task list of the workflow, or to its task list if
+// the task isn't sticky.
+func (s *Server) dispatchDecision(d *decisionTask) {
+	e := d.execution
+	e.decision = d
+	if !d.sticky {
+		s.addTask(decisionTaskListKey(e), d)
+		return
+	}
+	d.timer = s.addTimer(e.stickyScheduleToStartTimeout, func() {
+		s.timeoutStickyDecision(d)
+	})
+	s.addTask(taskListKey{domain: e.domain, name: e.stickyTaskList, kind: decisionTaskList}, d)
+}
+
+// timeoutStickyDecision times out a sticky decision task that was not started in time, the workflow is no longer
+// sticky and the decision task is scheduled again on its task list.
+func (s *Server) timeoutStickyDecision(d *decisionTask) {
+	e := d.execution
+	if !d.valid() {
+		return
+	}
+	event := s.newEvent(shared.EventTypeDecisionTaskTimedOut)
+	event.DecisionTaskTimedOutEventAttributes = &shared.DecisionTaskTimedOutEventAttributes{
+		ScheduledEventId: common.Int64Ptr(d.scheduledID),
+		TimeoutType:      shared.TimeoutTypeScheduleToStart.Ptr(),
+		Cause:            shared.DecisionTaskTimedOutCauseTimeout.Ptr(),
+	}
+	e.appendEvent(event)
+	s.closeDecision(d)
+	e.clearStickiness()
+	s.flushBufferedEvents(e)
+	s.scheduleDecision(e)
+}
+
+// PollForDecisionTask long polls a decision task list for a decision or a query task.
+func (s *Server) PollForDecisionTask(ctx context.Context, request *shared.PollForDecisionTaskRequest, opts ...yarpc.CallOption) (*shared.PollForDecisionTaskResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getDomain(request.GetDomain()); err != nil {
+		return nil, err
+	}
+	key := taskListKey{domain: request.GetDomain(), name: request.TaskList.GetName(), kind: decisionTaskList}
+	switch task := s.pollTask(ctx, key, request.GetIdentity()).(type) {
+	case *decisionTask:
+		return s.startDecision(task, request.GetIdentity()), nil
+	case *queryTask:
+		return s.dispatchQuery(task), nil
+	default:
+		return &shared.PollForDecisionTaskResponse{}, nil
+	}
+}
+
+func (s *Server) startDecision(d *decisionTask, identity string) *shared.PollForDecisionTaskResponse {
+	e := d.execution
+	event := s.newEvent(shared.EventTypeDecisionTaskStarted)
+	event.DecisionTaskStartedEventAttributes = &shared.DecisionTaskStartedEventAttributes{
+		ScheduledEventId: common.Int64Ptr(d.scheduledID),
+		Identity:         common.StringPtr(identity),
+		RequestId:        common.StringPtr(uuid.New()),
+	}
+	e.appendEvent(event)
+	d.started = true
+	d.startedID = event.GetEventId()
+	d.startedTime = s.now()
+	d.token = s.newTaskToken()
+	s.inFlightTasks[d.token] = d
+	d.timer.cancel()
+	d.timer = s.addTimer(seconds(e.started.GetTaskStartToCloseTimeoutSeconds()), func() {
+		s.timeoutDecision(d)
+	})
+
+	// the worker caching the workflow only needs the events since its previous decision task
+	history := e.history
+	if d.sticky {
+		history = history[e.previousStartedEventID:]
+	}
+	return &shared.PollForDecisionTaskResponse{
+		TaskToken:                 []byte(d.token),
+		WorkflowExecution:         e.workflowExecution(),
+		WorkflowType:              e.started.WorkflowType,
+		PreviousStartedEventId:    common.Int64Ptr(e.previousStartedEventID),
+		StartedEventId:            common.Int64Ptr(d.startedID),
+		Attempt:                   common.Int64Ptr(d.attempt),
+		History:                   &shared.History{Events: append([]*shared.HistoryEvent(nil), history...)},
+		WorkflowExecutionTaskList: &shared.TaskList{Name: common.StringPtr(e.taskList())},
+		ScheduledTimestamp:        common.Int64Ptr(d.scheduledTime.UnixNano()),
+		StartedTimestamp:          common.Int64Ptr(d.startedTime.UnixNano()),
+		NextEventId:               common.Int64Ptr(int64(len(e.history) + 1)),
+	}
+}
+
+// closeDecision removes the decision task from the workflow.
+func (s *Server) closeDecision(d *decisionTask) {
+	d.timer.cancel()
+	if d.token != "" {
+		s.finishTask(d.token)
+	}
+	if d.execution.decision == d {
+		d.execution.decision = nil
+	}
+}
+
+// retryDecision schedules the next attempt of a failed or timed out decision task, on the task list of the workflow
+// since the worker that failed it may not have the workflow cached anymore.
+func (s *Server) retryDecision(d *decisionTask, event *shared.HistoryEvent) {
+	e := d.execution
+	e.appendEvent(event)
+	s.closeDecision(d)
+	e.clearStickiness()
+	e.decisionAttempt++
+	s.flushBufferedEvents(e)
+	s.scheduleDecision(e)
+}
+
+func (s *Server) failDecision(d *decisionTask, cause shared.DecisionTaskFailedCause, details []byte, identity string) {
+	event := s.newEvent(shared.EventTypeDecisionTaskFailed)
+	event.DecisionTaskFailedEventAttributes = &shared.DecisionTaskFailedEventAttributes{
+		ScheduledEventId: common.Int64Ptr(d.scheduledID),
+		StartedEventId:   common.Int64Ptr(d.startedID),
+		Cause:            cause.Ptr(),
+		Details:          details,
+		Identity:         common.StringPtr(identity),
+	}
+	s.retryDecision(d, event)
+}
+
+func (s *Server) timeoutDecision(d *decisionTask) {
+	if d.execution.decision != d || d.execution.closed() {
+		return
+	}
+	event := s.newEvent(shared.EventTypeDecisionTaskTimedOut)
+	event.DecisionTaskTimedOutEventAttributes = &shared.DecisionTaskTimedOutEventAttributes{
+		ScheduledEventId: common.Int64Ptr(d.scheduledID),
+		StartedEventId:   common.Int64Ptr(d.startedID),
+		TimeoutType:      shared.TimeoutTypeStartToClose.Ptr(),
+		Cause:            shared.DecisionTaskTimedOutCauseTimeout.Ptr(),
+	}
+	s.retryDecision(d, event)
+}
+
+func (s *Server) getDecisionTask(token []byte) (*decisionTask, error) {
+	d, ok := s.inFlightTasks[string(token)].(*decisionTask)
+	if !ok || d.execution.decision != d {
+		return nil, &shared.EntityNotExistsError{Message: "Decision task not found."}
+	}
+	return d, nil
+}
+
+// RespondDecisionTaskCompleted applies the decisions of a decision task. The decision task fails if a decision is
+// invalid, or if the workflow is closed by a decision while new events are waiting to be handled.
+func (s *Server) RespondDecisionTaskCompleted(ctx context.Context, request *shared.RespondDecisionTaskCompletedRequest, opts ...yarpc.CallOption) (*shared.RespondDecisionTaskCompletedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.getDecisionTask(request.TaskToken)
+	if err != nil {
+		return nil, err
+	}
+	e := d.execution
+	if cause, message := s.validateDecisions(e, request.Decisions); cause != nil {
+		s.failDecision(d, *cause, []byte(message), request.GetIdentity())
+		return &shared.RespondDecisionTaskCompletedResponse{}, nil
+	}
+	if e.bufferedNeedDecision && hasCloseDecision(request.Decisions) {
+		s.failDecision(d, shared.DecisionTaskFailedCauseUnhandledDecision, nil, request.GetIdentity())
+		return &shared.RespondDecisionTaskCompletedResponse{}, nil
+	}
+
+	event := s.newEvent(shared.EventTypeDecisionTaskCompleted)
+	event.DecisionTaskCompletedEventAttributes = &shared.DecisionTaskCompletedEventAttributes{
+		ExecutionContext: request.ExecutionContext,
+		ScheduledEventId: common.Int64Ptr(d.scheduledID),
+		StartedEventId:   common.Int64Ptr(d.startedID),
+		Identity:         request.Identity,
+		BinaryChecksum:   request.BinaryChecksum,
+	}
+	e.appendEvent(event)
+	s.closeDecision(d)
+	e.decisionAttempt = 0
+	e.previousStartedEventID = d.startedID
+	e.clearStickiness()
+	if sticky := request.StickyAttributes; sticky.WorkerTaskList.GetName() != "" {
+		e.stickyTaskList = sticky.WorkerTaskList.GetName()
+		e.stickyScheduleToStartTimeout = seconds(sticky.GetScheduleToStartTimeoutSeconds())
+	}
+
+	needDecision := request.GetForceCreateNewDecisionTask()
+	e.applyingDecisions = true
+	for _, decision := range request.Decisions {
+		if s.applyDecision(e, decision, event.EventId, request.GetIdentity()) {
+			needDecision = true
+		}
+		if e.closed() {
+			break
+		}
+	}
+	e.applyingDecisions = false
+	if e.closed() {
+		return &shared.RespondDecisionTaskCompletedResponse{}, nil
+	}
+	if s.flushBufferedEvents(e) || needDecision {
+		s.scheduleDecision(e)
+	}
+	return &shared.RespondDecisionTaskCompletedResponse{}, nil
+}
+
+// RespondDecisionTaskFailed fails a decision task, which is retried.
+func (s *Server) RespondDecisionTaskFailed(ctx context.Context, request *shared.RespondDecisionTaskFailedRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.getDecisionTask(request.TaskToken)
+	if err != nil {
+		return err
+	}
+	s.failDecision(d, request.GetCause(), request.Details, request.GetIdentity())
+	return nil
+}
+
+func hasCloseDecision(decisions []*shared.Decision) bool {
+	for _, decision := range decisions {
+		switch decision.GetDecisionType() {
+		case shared.DecisionTypeCompleteWorkflowExecution,
+			shared.DecisionTypeFailWorkflowExecution,
+			shared.DecisionTypeCancelWorkflowExecution,
+			shared.DecisionTypeContinueAsNewWorkflowExecution:
+			return true
+		}
+	}
+	return false
+}
+
+// validateDecisions returns the cause of the failure of a decision task with invalid decisions, and nil if all the
+// decisions are valid.
+func (s *Server) validateDecisions(e *execution, decisions []*shared.Decision) (*shared.DecisionTaskFailedCause, string) {
+	activityIDs := make(map[string]bool)
+	for _, act := range e.activities {
+		activityIDs[act.scheduled.GetActivityId()] = true
+	}
+	timerIDs := make(map[string]bool)
+	for timerID := range e.timers {
+		timerIDs[timerID] = true
+	}
+	fail := func(cause shared.DecisionTaskFailedCause, format string, args ...interface{}) (*shared.DecisionTaskFailedCause, string) {
+		return cause.Ptr(), fmt.Sprintf(format, args...)
+	}
+
+	for _, decision := range decisions {
+		switch decision.GetDecisionType() {
+		case shared.DecisionTypeScheduleActivityTask:
+			a := decision.ScheduleActivityTaskDecisionAttributes
+			switch {
+			case a == nil || a.GetActivityId() == "" || a.ActivityType.GetName() == "" || a.TaskList.GetName() == "":
+				return fail(shared.DecisionTaskFailedCauseBadScheduleActivityAttributes, "ActivityId, ActivityType and TaskList are required.")
+			case a.GetDomain() != "" && s.domains[a.GetDomain()] == nil:
+				return fail(shared.DecisionTaskFailedCauseBadScheduleActivityAttributes, "Domain: %v does not exist.", a.GetDomain())
+			case a.GetScheduleToCloseTimeoutSeconds() <= 0 && (a.GetScheduleToStartTimeoutSeconds() <= 0 || a.GetStartToCloseTimeoutSeconds() <= 0):
+				return fail(shared.DecisionTaskFailedCauseBadScheduleActivityAttributes, "A valid timeout is not set for activity: %v.", a.GetActivityId())
+			case activityIDs[a.GetActivityId()]:
+				return fail(shared.DecisionTaskFailedCauseScheduleActivityDuplicateID, "Duplicate ActivityId: %v.", a.GetActivityId())
+			}
+			activityIDs[a.GetActivityId()] = true
+		case shared.DecisionTypeRequestCancelActivityTask:
+			if a := decision.RequestCancelActivityTaskDecisionAttributes; a == nil || a.GetActivityId() == "" {
+				return fail(shared.DecisionTaskFailedCauseBadRequestCancelActivityAttributes, "ActivityId is required.")
+			}
+		case shared.DecisionTypeStartTimer:
+			a := decision.StartTimerDecisionAttributes
+			switch {
+			case a == nil || a.GetTimerId() == "" || a.GetStartToFireTimeoutSeconds() <= 0:
+				return fail(shared.DecisionTaskFailedCauseBadStartTimerAttributes, "TimerId and a valid StartToFireTimeoutSeconds are required.")
+			case timerIDs[a.GetTimerId()]:
+				return fail(shared.DecisionTaskFailedCauseStartTimerDuplicateID, "Duplicate TimerId: %v.", a.GetTimerId())
+			}
+			timerIDs[a.GetTimerId()] = true
+		case shared.DecisionTypeCancelTimer:
+			if a := decision.CancelTimerDecisionAttributes; a == nil || a.GetTimerId() == "" {
+				return fail(shared.DecisionTaskFailedCauseBadCancelTimerAttributes, "TimerId is required.")
+			}
+		case shared.DecisionTypeCompleteWorkflowExecution:
+			if decision.CompleteWorkflowExecutionDecisionAttributes == nil {
+				return fail(shared.DecisionTaskFailedCauseBadCompleteWorkflowExecutionAttributes, "Attributes are required.")
+			}
+		case shared.DecisionTypeFailWorkflowExecution:
+			if a := decision.FailWorkflowExecutionDecisionAttributes; a == nil || a.GetReason() == "" {
+				return fail(shared.DecisionTaskFailedCauseBadFailWorkflowExecutionAttributes, "Reason is required.")
+			}
+		case shared.DecisionTypeCancelWorkflowExecution:
+			if decision.CancelWorkflowExecutionDecisionAttributes == nil {
+				return fail(shared.DecisionTaskFailedCauseBadCancelWorkflowExecutionAttributes, "Attributes are required.")
+			}
+		case shared.DecisionTypeRequestCancelExternalWorkflowExecution:
+			if a := decision.RequestCancelExternalWorkflowExecutionDecisionAttributes; a == nil || a.GetWorkflowId() == "" {
+				return fail(shared.DecisionTaskFailedCauseBadRequestCancelExternalWorkflowExecutionAttributes, "WorkflowId is required.")
+			}
+		case shared.DecisionTypeRecordMarker:
+			if a := decision.RecordMarkerDecisionAttributes; a == nil || a.GetMarkerName() == "" {
+				return fail(shared.DecisionTaskFailedCauseBadRecordMarkerAttributes, "MarkerName is required.")
+			}
+		case shared.DecisionTypeContinueAsNewWorkflowExecution:
+			a := decision.ContinueAsNewWorkflowExecutionDecisionAttributes
+			if a == nil {
+				return fail(shared.DecisionTaskFailedCauseBadContinueAsNewAttributes, "Attributes are required.")
+			}
+			if a.GetCronSchedule() != "" && cronBackoff(a.GetCronSchedule(), s.now()) <= 0 {
+				return fail(shared.DecisionTaskFailedCauseBadContinueAsNewAttributes, "Invalid CronSchedule: %v.", a.GetCronSchedule())
+			}
+		case shared.DecisionTypeStartChildWorkflowExecution:
+			a := decision.StartChildWorkflowExecutionDecisionAttributes
+			switch {
+			case a == nil || a.GetWorkflowId() == "" || a.WorkflowType.GetName() == "" || a.TaskList.GetName() == "":
+				return fail(shared.DecisionTaskFailedCauseBadStartChildExecutionAttributes, "WorkflowId, WorkflowType and TaskList are required.")
+			case a.GetDomain() != "" && s.domains[a.GetDomain()] == nil:
+				return fail(shared.DecisionTaskFailedCauseBadStartChildExecutionAttributes, "Domain: %v does not exist.", a.GetDomain())
+			case a.GetExecutionStartToCloseTimeoutSeconds() <= 0:
+				return fail(shared.DecisionTaskFailedCauseBadStartChildExecutionAttributes, "A valid ExecutionStartToCloseTimeoutSeconds is required.")
+			}
+		case shared.DecisionTypeSignalExternalWorkflowExecution:
+			a := decision.SignalExternalWorkflowExecutionDecisionAttributes
+			if a == nil || a.Execution.GetWorkflowId() == "" || a.GetSignalName() == "" {
+				return fail(shared.DecisionTaskFailedCauseBadSignalWorkflowExecutionAttributes, "Execution and SignalName are required.")
+			}
+		case shared.DecisionTypeUpsertWorkflowSearchAttributes:
+			a := decision.UpsertWorkflowSearchAttributesDecisionAttributes
+			if a == nil || len(a.SearchAttributes.GetIndexedFields()) == 0 {
+				return fail(shared.DecisionTaskFailedCauseBadSearchAttributes, "SearchAttributes are required.")
+			}
+		default:
+			return fail(shared.DecisionTaskFailedCauseUnhandledDecision, "Unknown decision type: %v.", decision.GetDecisionType())
+		}
+	}
+	return nil, ""
+}
+
+// applyDecision applies a valid decision and tells whether it requires a new decision task.
+func (s *Server) applyDecision(e *execution, decision *shared.Decision, completedEventID *int64, identity string) bool {
+	switch decision.GetDecisionType() {
+	case shared.DecisionTypeScheduleActivityTask:
+		s.scheduleActivity(e, decision.ScheduleActivityTaskDecisionAttributes, completedEventID)
+	case shared.DecisionTypeRequestCancelActivityTask:
+		return s.requestCancelActivity(e, decision.RequestCancelActivityTaskDecisionAttributes.GetActivityId(), completedEventID, identity)
+	case shared.DecisionTypeStartTimer:
+		s.startTimer(e, decision.StartTimerDecisionAttributes, completedEventID)
+	case shared.DecisionTypeCancelTimer:
+		return s.cancelTimer(e, decision.CancelTimerDecisionAttributes.GetTimerId(), completedEventID, identity)
+	case shared.DecisionTypeCompleteWorkflowExecution:
+		s.completeExecution(e, decision.CompleteWorkflowExecutionDecisionAttributes.Result, completedEventID)
+	case shared.DecisionTypeFailWorkflowExecution:
+		a := decision.FailWorkflowExecutionDecisionAttributes
+		s.failExecution(e, a.GetReason(), a.Details, completedEventID)
+	case shared.DecisionTypeCancelWorkflowExecution:
+		event := s.newEvent(shared.EventTypeWorkflowExecutionCanceled)
+		event.WorkflowExecutionCanceledEventAttributes = &shared.WorkflowExecutionCanceledEventAttributes{
+			DecisionTaskCompletedEventId: completedEventID,
+			Details:                      decision.CancelWorkflowExecutionDecisionAttributes.Details,
+		}
+		s.closeExecution(e, shared.WorkflowExecutionCloseStatusCanceled, event)
+	case shared.DecisionTypeRequestCancelExternalWorkflowExecution:
+		s.requestCancelExternal(e, decision.RequestCancelExternalWorkflowExecutionDecisionAttributes, completedEventID)
+	case shared.DecisionTypeRecordMarker:
+		a := decision.RecordMarkerDecisionAttributes
+		event := s.newEvent(shared.EventTypeMarkerRecorded)
+		event.MarkerRecordedEventAttributes = &shared.MarkerRecordedEventAttributes{
+			MarkerName:                   a.MarkerName,
+			Details:                      a.Details,
+			DecisionTaskCompletedEventId: completedEventID,
+			Header:                       a.Header,
+		}
+		e.appendEvent(event)
+	case shared.DecisionTypeContinueAsNewWorkflowExecution:
+		s.continueAsNew(e, decision.ContinueAsNewWorkflowExecutionDecisionAttributes, 0, completedEventID)
+	case shared.DecisionTypeStartChildWorkflowExecution:
+		s.startChild(e, decision.StartChildWorkflowExecutionDecisionAttributes, completedEventID)
+	case shared.DecisionTypeSignalExternalWorkflowExecution:
+		s.signalExternal(e, decision.SignalExternalWorkflowExecutionDecisionAttributes, completedEventID)
+	case shared.DecisionTypeUpsertWorkflowSearchAttributes:
+		a := decision.UpsertWorkflowSearchAttributesDecisionAttributes
+		if e.searchAttributes == nil {
+			e.searchAttributes = &shared.SearchAttributes{}
+		}
+		fields := make(map[string][]byte, len(e.searchAttributes.IndexedFields)+len(a.SearchAttributes.IndexedFields))
+		for k, v := range e.searchAttributes.IndexedFields {
+			fields[k] = v
+		}
+		for k, v := range a.SearchAttributes.IndexedFields {
+			fields[k] = v
+		}
+		e.searchAttributes = &shared.SearchAttributes{IndexedFields: fields}
+		event := s.newEvent(shared.EventTypeUpsertWorkflowSearchAttributes)
+		event.UpsertWorkflowSearchAttributesEventAttributes = &shared.UpsertWorkflowSearchAttributesEventAttributes{
+			DecisionTaskCompletedEventId: completedEventID,
+			SearchAttributes:             a.SearchAttributes,
+		}
+		e.appendEvent(event)
+	}
+	return false
+}
+
+func (s *Server) startTimer(e *execution, a *shared.StartTimerDecisionAttributes, completedEventID *int64) {
+	event := s.newEvent(shared.EventTypeTimerStarted)
+	event.TimerStartedEventAttributes = &shared.TimerStartedEventAttributes{
+		TimerId:                      a.TimerId,
+		StartToFireTimeoutSeconds:    a.StartToFireTimeoutSeconds,
+		DecisionTaskCompletedEventId: completedEventID,
+	}
+	e.appendEvent(event)
+	timerID := a.GetTimerId()
+	t := &userTimer{startedID: event.GetEventId()}
+	t.timer = s.addTimer(time.Duration(a.GetStartToFireTimeoutSeconds())*time.Second, func() {
+		delete(e.timers, timerID)
+		fired := s.newEvent(shared.EventTypeTimerFired)
+		fired.TimerFiredEventAttributes = &shared.TimerFiredEventAttributes{
+			TimerId:        common.StringPtr(timerID),
+			StartedEventId: common.Int64Ptr(t.startedID),
+		}
+		s.recordEvent(e, fired, true)
+	})
+	e.timers[timerID] = t
+}
+
+func (s *Server) cancelTimer(e *execution, timerID string, completedEventID *int64, identity string) bool {
+	t, ok := e.timers[timerID]
+	if !ok {
+		event := s.newEvent(shared.EventTypeCancelTimerFailed)
+		event.CancelTimerFailedEventAttributes = &shared.CancelTimerFailedEventAttributes{
+			TimerId:                      common.StringPtr(timerID),
+			Cause:                        common.StringPtr("TIMER_ID_UNKNOWN"),
+			DecisionTaskCompletedEventId: completedEventID,
+			Identity:                     common.StringPtr(identity),
+		}
+		e.appendEvent(event)
+		return true
+	}
+	t.timer.cancel()
+	delete(e.timers, timerID)
+	event := s.newEvent(shared.EventTypeTimerCanceled)
+	event.TimerCanceledEventAttributes = &shared.TimerCanceledEventAttributes{
+		TimerId:                      common.StringPtr(timerID),
+		StartedEventId:               common.Int64Ptr(t.startedID),
+		DecisionTaskCompletedEventId: completedEventID,
+		Identity:                     common.StringPtr(identity),
+	}
+	e.appendEvent(event)
+	return false
+}
+
+func (s *Server) startChild(e *execution, a *shared.StartChildWorkflowExecutionDecisionAttributes, completedEventID *int64) {
+	domain := a.GetDomain()
+	if domain == "" {
+		domain = e.domain
+	}
+	decisionTimeout := a.GetTaskStartToCloseTimeoutSeconds()
+	if decisionTimeout <= 0 {
+		decisionTimeout = defaultChildDecisionTimeout
+	}
+	policy := shared.ParentClosePolicyTerminate
+	if a.ParentClosePolicy != nil {
+		policy = *a.ParentClosePolicy
+	}
+	initiated := s.newEvent(shared.EventTypeStartChildWorkflowExecutionInitiated)
+	initiated.StartChildWorkflowExecutionInitiatedEventAttributes = &shared.StartChildWorkflowExecutionInitiatedEventAttributes{
+		Domain:                              common.StringPtr(domain),
+		WorkflowId:                          a.WorkflowId,
+		WorkflowType:                        a.WorkflowType,
+		TaskList:                            a.TaskList,
+		Input:                               a.Input,
+		ExecutionStartToCloseTimeoutSeconds: a.ExecutionStartToCloseTimeoutSeconds,
+		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(decisionTimeout),
+		ParentClosePolicy:                   policy.Ptr(),
+		Control:                             a.Control,
+		DecisionTaskCompletedEventId:        completedEventID,
+		WorkflowIdReusePolicy:               a.WorkflowIdReusePolicy,
+		RetryPolicy:                         a.RetryPolicy,
+		CronSchedule:                        a.CronSchedule,
+		Header:                              a.Header,
+		Memo:                                a.Memo,
+		SearchAttributes:                    a.SearchAttributes,
+	}
+	e.appendEvent(initiated)
+	initiatedID := initiated.GetEventId()
+
+	child, _, err := s.start(&startRequest{
+		domain:            domain,
+		workflowID:        a.GetWorkflowId(),
+		workflowType:      a.WorkflowType,
+		taskList:          a.TaskList.GetName(),
+		input:             a.Input,
+		executionTimeout:  a.GetExecutionStartToCloseTimeoutSeconds(),
+		decisionTimeout:   decisionTimeout,
+		reusePolicy:       a.WorkflowIdReusePolicy,
+		retryPolicy:       a.RetryPolicy,
+		cronSchedule:      a.GetCronSchedule(),
+		memo:              a.Memo,
+		searchAttributes:  a.SearchAttributes,
+		header:            a.Header,
+		parent:            e,
+		parentInitiatedID: initiatedID,
+	})
+	if err != nil {
+		event := s.newEvent(shared.EventTypeStartChildWorkflowExecutionFailed)
+		event.StartChildWorkflowExecutionFailedEventAttributes = &shared.StartChildWorkflowExecutionFailedEventAttributes{
+			Domain:                       common.StringPtr(domain),
+			WorkflowId:                   a.WorkflowId,
+			WorkflowType:                 a.WorkflowType,
+			Cause:                        shared.ChildWorkflowExecutionFailedCauseWorkflowAlreadyRunning.Ptr(),
+			Control:                      a.Control,
+			InitiatedEventId:             common.Int64Ptr(initiatedID),
+			DecisionTaskCompletedEventId: completedEventID,
+		}
+		s.recordEvent(e, event, true)
+		return
+	}
+
+	event := s.newEvent(shared.EventTypeChildWorkflowExecutionStarted)
+	event.ChildWorkflowExecutionStartedEventAttributes = &shared.ChildWorkflowExecutionStartedEventAttributes{
+		Domain:            common.StringPtr(domain),
+		InitiatedEventId:  common.Int64Ptr(initiatedID),
+		WorkflowExecution: child.workflowExecution(),
+		WorkflowType:      a.WorkflowType,
+		Header:            a.Header,
+	}
+	e.children[initiatedID] = &childWorkflow{
+		initiatedID:  initiatedID,
+		startedEvent: event,
+		domain:       domain,
+		workflowType: a.WorkflowType,
+		policy:       policy,
+		execution:    child,
+	}
+	s.recordEvent(e, event, true)
+	s.scheduleFirstDecision(child)
+}
+
+// getExternalExecution returns the open workflow targeted by a decision, or nil if there is none.
+func (s *Server) getExternalExecution(e *execution, domain, workflowID, runID string, childOnly bool) *execution {
+	if domain == "" {
+		domain = e.domain
+	}
+	target, err := s.getOpenExecution(domain, &shared.WorkflowExecution{WorkflowId: common.StringPtr(workflowID), RunId: common.StringPtr(runID)})
+	if err != nil || (childOnly && target.parent != e) {
+		return nil
+	}
+	return target
+}
+
+func (s *Server) signalExternal(e *execution, a *shared.SignalExternalWorkflowExecutionDecisionAttributes, completedEventID *int64) {
+	domain := a.GetDomain()
+	if domain == "" {
+		domain = e.domain
+	}
+	initiated := s.newEvent(shared.EventTypeSignalExternalWorkflowExecutionInitiated)
+	initiated.SignalExternalWorkflowExecutionInitiatedEventAttributes = &shared.SignalExternalWorkflowExecutionInitiatedEventAttributes{
+		DecisionTaskCompletedEventId: completedEventID,
+		Domain:                       common.StringPtr(domain),
+		WorkflowExecution:            a.Execution,
+		SignalName:                   a.SignalName,
+		Input:                        a.Input,
+		Control:                      a.Control,
+		ChildWorkflowOnly:            a.ChildWorkflowOnly,
+	}
+	e.appendEvent(initiated)
+
+	target := s.getExternalExecution(e, domain, a.Execution.GetWorkflowId(), a.Execution.GetRunId(), a.GetChildWorkflowOnly())
+	if target == nil {
+		event := s.newEvent(shared.EventTypeSignalExternalWorkflowExecutionFailed)
+		event.SignalExternalWorkflowExecutionFailedEventAttributes = &shared.SignalExternalWorkflowExecutionFailedEventAttributes{
+			Cause:                        shared.SignalExternalWorkflowExecutionFailedCauseUnknownExternalWorkflowExecution.Ptr(),
+			DecisionTaskCompletedEventId: completedEventID,
+			Domain:                       common.StringPtr(domain),
+			WorkflowExecution:            a.Execution,
+			InitiatedEventId:             initiated.EventId,
+			Control:                      a.Control,
+		}
+		s.recordEvent(e, event, true)
+		return
+	}
+	s.signal(target, a.GetSignalName(), a.Input, "")
+	event := s.newEvent(shared.EventTypeExternalWorkflowExecutionSignaled)
+	event.ExternalWorkflowExecutionSignaledEventAttributes = &shared.ExternalWorkflowExecutionSignaledEventAttributes{
+		InitiatedEventId:  initiated.EventId,
+		Domain:            common.StringPtr(domain),
+		WorkflowExecution: target.workflowExecution(),
+		Control:           a.Control,
+	}
+	s.recordEvent(e, event, true)
+}
+
+func (s *Server) requestCancelExternal(e *execution, a *shared.RequestCancelExternalWorkflowExecutionDecisionAttributes, completedEventID *int64) {
+	domain := a.GetDomain()
+	if domain == "" {
+		domain = e.domain
+	}
+	workflowExecution := &shared.WorkflowExecution{WorkflowId: a.WorkflowId, RunId: a.RunId}
+	initiated := s.newEvent(shared.EventTypeRequestCancelExternalWorkflowExecutionInitiated)
+	initiated.RequestCancelExternalWorkflowExecutionInitiatedEventAttributes = &shared.RequestCancelExternalWorkflowExecutionInitiatedEventAttributes{
+		DecisionTaskCompletedEventId: completedEventID,
+		Domain:                       common.StringPtr(domain),
+		WorkflowExecution:            workflowExecution,
+		Control:                      a.Control,
+		ChildWorkflowOnly:            a.ChildWorkflowOnly,
+	}
+	e.appendEvent(initiated)
+
+	target := s.getExternalExecution(e, domain, a.GetWorkflowId(), a.GetRunId(), a.GetChildWorkflowOnly())
+	if target == nil {
+		event := s.newEvent(shared.EventTypeRequestCancelExternalWorkflowExecutionFailed)
+		event.RequestCancelExternalWorkflowExecutionFailedEventAttributes = &shared.RequestCancelExternalWorkflowExecutionFailedEventAttributes{
+			Cause:                        shared.CancelExternalWorkflowExecutionFailedCauseUnknownExternalWorkflowExecution.Ptr(),
+			DecisionTaskCompletedEventId: completedEventID,
+			Domain:                       common.StringPtr(domain),
+			WorkflowExecution:            workflowExecution,
+			InitiatedEventId:             initiated.EventId,
+			Control:                      a.Control,
+		}
+		s.recordEvent(e, event, true)
+		return
+	}
+	if !target.cancelRequested {
+		s.requestCancel(target, "", "", initiated.EventId, e.workflowExecution())
+	}
+	event := s.newEvent(shared.EventTypeExternalWorkflowExecutionCancelRequested)
+	event.ExternalWorkflowExecutionCancelRequestedEventAttributes = &shared.ExternalWorkflowExecutionCancelRequestedEventAttributes{
+		InitiatedEventId:  initiated.EventId,
+		Domain:            common.StringPtr(domain),
+		WorkflowExecution: target.workflowExecution(),
+	}
+	s.recordEvent(e, event, true)
+}
+
+// QueryWorkflow queries a workflow through a query task dispatched to a poller of its decision task list.
+func (s *Server) QueryWorkflow(ctx context.Context, request *shared.QueryWorkflowRequest, opts ...yarpc.CallOption) (*shared.QueryWorkflowResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.getExecution(request.GetDomain(), request.Execution)
+	if err != nil {
+		return nil, err
+	}
+	if e.closed() && request.QueryRejectCondition != nil {
+		switch *request.QueryRejectCondition {
+		case shared.QueryRejectConditionNotOpen:
+			return &shared.QueryWorkflowResponse{QueryRejected: &shared.QueryRejected{CloseStatus: e.closeStatus}}, nil
+		case shared.QueryRejectConditionNotCompletedCleanly:
+			if *e.closeStatus != shared.WorkflowExecutionCloseStatusCompleted {
+				return &shared.QueryWorkflowResponse{QueryRejected: &shared.QueryRejected{CloseStatus: e.closeStatus}}, nil
+			}
+		}
+	}
+	if e.previousStartedEventID == 0 {
+		return nil, &shared.QueryFailedError{Message: "Cannot query a workflow before its first decision task completed."}
+	}
+
+	q := &queryTask{
+		execution: e,
+		query:     request.Query,
+		resultC:   make(chan *shared.RespondQueryTaskCompletedRequest, 1),
+	}
+	s.addTask(decisionTaskListKey(e), q)
+	s.mu.Unlock()
+	var result *shared.RespondQueryTaskCompletedRequest
+	select {
+	case result = <-q.resultC:
+	case <-ctx.Done():
+	case <-s.stopC:
+	}
+	s.mu.Lock()
+	if result == nil {
+		q.done = true
+		s.finishTask(q.token)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &shared.InternalServiceError{Message: "The test server stopped."}
+	}
+	if result.GetCompletedType() == shared.QueryTaskCompletedTypeFailed {
+		return nil, &shared.QueryFailedError{Message: result.GetErrorMessage()}
+	}
+	return &shared.QueryWorkflowResponse{QueryResult: result.QueryResult}, nil
+}
+
+func (s *Server) dispatchQuery(q *queryTask) *shared.PollForDecisionTaskResponse {
+	e := q.execution
+	q.dispatched = true
+	q.token = s.newTaskToken()
+	s.inFlightTasks[q.token] = q
+	return &shared.PollForDecisionTaskResponse{
+		TaskToken:                 []byte(q.token),
+		WorkflowExecution:         e.workflowExecution(),
+		WorkflowType:              e.started.WorkflowType,
+		PreviousStartedEventId:    common.Int64Ptr(e.previousStartedEventID),
+		History:                   &shared.History{Events: append([]*shared.HistoryEvent(nil), e.history...)},
+		Query:                     q.query,
+		WorkflowExecutionTaskList: &shared.TaskList{Name: common.StringPtr(e.taskList())},
+		NextEventId:               common.Int64Ptr(int64(len(e.history) + 1)),
+	}
+}
+
+// RespondQueryTaskCompleted returns the result of a query task to the pending QueryWorkflow request.
+func (s *Server) RespondQueryTaskCompleted(ctx context.Context, request *shared.RespondQueryTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.inFlightTasks[string(request.TaskToken)].(*queryTask)
+	if !ok {
+		return &shared.EntityNotExistsError{Message: "Query task not found."}
+	}
+	s.finishTask(q.token)
+	q.done = true
+	q.resultC <- request
+	return nil
+}
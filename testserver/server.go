@@ -0,0 +1,696 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package testserver provides an in-memory Cadence service to run integration tests of workers and clients end to
+// end, in the test process and without a Cadence deployment.
+//
+// The Server implements workflowserviceclient.Interface, it is passed in place of the service client of a Cadence
+// deployment to client.NewClient, client.NewDomainClient and worker.New:
+//
+//	server := testserver.NewServer(testserver.Options{Domains: []string{"test-domain"}})
+//	defer server.Stop()
+//	w := worker.New(server, "test-domain", "test-tasklist", worker.Options{})
+//	c := client.NewClient(server, "test-domain", &client.Options{})
+//
+// The server matches the decision and activity tasks with the pollers of the workers and records the history of the
+// workflows the way the Cadence server does: activities with their timeouts and retries, timers, signals, cancellation
+// requests, child workflows, continue as new, queries, workflow retries and cron schedules.
+//
+// The decision tasks of a workflow are dispatched to the sticky task list of the worker that completed its last
+// decision task, with the events since that decision task only, as the Cadence server does for the workflows cached
+// by a worker. A sticky decision task not started within the StickyScheduleToStartTimeout of the worker times out
+// and is dispatched to the task list of the workflow with its whole history, so is the next decision task after
+// ResetStickyTaskList or a failed decision task. The queries are always dispatched to the task list of the workflow.
+//
+// The server has its own clock, which starts at the wall clock. While a client waits for the result of a workflow and
+// there is no task to be processed by the workers, the clock of the server skips ahead to the next timer: a workflow
+// sleeping for a day completes right away. Options.DisableTimeSkipping keeps the clock of the server in line with the
+// wall clock instead.
+//
+// The server does not support workflow resets, archival, search attribute queries of the visibility APIs and the
+// replication of global domains.
+package testserver
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/yarpcerrors"
+
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+const (
+	// longPollTimeout is the time the polls for tasks and for new history events wait at most, the same as the
+	// Cadence server.
+	longPollTimeout = time.Minute
+	// longPollMargin is how long before the deadline of their context the long polls return an empty result, so
+	// that the caller gets the result before it gives up on the request.
+	longPollMargin = time.Second
+
+	defaultRetentionDays = 1
+)
+
+var _ workflowserviceclient.Interface = (*Server)(nil)
+
+type (
+	// Options of the test server.
+	Options struct {
+		// Optional: the domains registered when the server is created. Other domains can be registered with
+		// RegisterDomain, e.g. through client.DomainClient.
+		Domains []string
+
+		// Optional: keeps the clock of the server in line with the wall clock, the timers of the workflows and the
+		// timeouts fire in real time.
+		// default: false, the clock of the server skips ahead to the next timer while a client waits for the result
+		// of a workflow and no task is left to be processed.
+		DisableTimeSkipping bool
+	}
+
+	// Server is an in-memory Cadence service, see the package documentation. It is safe for concurrent use.
+	Server struct {
+		options Options
+
+		mu sync.Mutex
+		// offset is how far the clock of the server was moved ahead of the wall clock by time skipping.
+		offset      time.Duration
+		timers      timerQueue
+		domains     map[string]*domain
+		executions  map[executionKey]*execution
+		currentRuns map[workflowKey]*execution
+		taskLists   map[taskListKey]*taskList
+		// inFlightTasks are the tasks dispatched to a poller and not responded yet, by task token.
+		inFlightTasks map[string]inFlightTask
+		// resultWaiters is the number of long polls waiting for new history events.
+		resultWaiters int
+		nextTaskID    int64
+
+		wakeC    chan struct{}
+		stopC    chan struct{}
+		stopOnce sync.Once
+		doneC    chan struct{}
+	}
+
+	domain struct {
+		info          *shared.DomainInfo
+		configuration *shared.DomainConfiguration
+	}
+
+	workflowKey struct {
+		domain     string
+		workflowID string
+	}
+
+	executionKey struct {
+		domain     string
+		workflowID string
+		runID      string
+	}
+
+	taskListKind int
+
+	taskListKey struct {
+		domain string
+		name   string
+		kind   taskListKind
+	}
+
+	taskList struct {
+		tasks []queuedTask
+		// tasksAddedC is closed and replaced when tasks are added, to wake up the pollers.
+		tasksAddedC chan struct{}
+		pollers     map[string]time.Time
+	}
+
+	// queuedTask is a task added to a task list. A task stays in the task list after e.g. the workflow closed, the
+	// pollers skip the tasks no longer valid.
+	queuedTask interface {
+		valid() bool
+	}
+
+	// inFlightTask is a task dispatched to a poller and not responded yet.
+	inFlightTask interface {
+		inFlight()
+	}
+
+	// timer fires a callback at a time of the clock of the server, under the lock of the server.
+	timer struct {
+		deadline time.Time
+		fire     func()
+		canceled bool
+		index    int
+	}
+
+	timerQueue []*timer
+)
+
+const (
+	decisionTaskList taskListKind = iota
+	activityTaskList
+)
+
+// NewServer creates and starts an in-memory Cadence service. Stop releases its resources.
+func NewServer(options Options) *Server {
+	server := &Server{
+		options:       options,
+		domains:       make(map[string]*domain),
+		executions:    make(map[executionKey]*execution),
+		currentRuns:   make(map[workflowKey]*execution),
+		taskLists:     make(map[taskListKey]*taskList),
+		inFlightTasks: make(map[string]inFlightTask),
+		wakeC:         make(chan struct{}, 1),
+		stopC:         make(chan struct{}),
+		doneC:         make(chan struct{}),
+	}
+	for _, name := range options.Domains {
+		server.domains[name] = newDomain(&shared.RegisterDomainRequest{Name: common.StringPtr(name)})
+	}
+	go server.runTimers()
+	return server
+}
+
+// Stop stops the server, the pending polls return an empty result.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopC)
+		<-s.doneC
+	})
+}
+
+// Now returns the current time of the clock of the server, the time of the events recorded in the history of the
+// workflows and of workflow.Now.
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now()
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// wake makes the timer loop check the timers again, after the state of the server changed.
+func (s *Server) wake() {
+	select {
+	case s.wakeC <- struct{}{}:
+	default:
+	}
+}
+
+// addTimer adds a timer firing after d on the clock of the server.
+func (s *Server) addTimer(d time.Duration, fire func()) *timer {
+	t := &timer{deadline: s.now().Add(d), fire: fire}
+	heap.Push(&s.timers, t)
+	s.wake()
+	return t
+}
+
+func (t *timer) cancel() {
+	if t != nil {
+		t.canceled = true
+	}
+}
+
+func (s *Server) runTimers() {
+	defer close(s.doneC)
+	wallTimer := time.NewTimer(time.Hour)
+	defer wallTimer.Stop()
+	for {
+		s.mu.Lock()
+		s.fireTimers()
+		wait := time.Hour
+		if len(s.timers) > 0 {
+			next := s.timers[0].deadline.Sub(s.now())
+			if s.canSkipTime() {
+				s.offset += next
+				s.mu.Unlock()
+				continue
+			}
+			if next < wait {
+				wait = next
+			}
+		}
+		s.mu.Unlock()
+
+		if !wallTimer.Stop() {
+			select {
+			case <-wallTimer.C:
+			default:
+			}
+		}
+		wallTimer.Reset(wait)
+		select {
+		case <-wallTimer.C:
+		case <-s.wakeC:
+		case <-s.stopC:
+			return
+		}
+	}
+}
+
+func (s *Server) fireTimers() {
+	for len(s.timers) > 0 {
+		next := s.timers[0]
+		if next.canceled {
+			heap.Pop(&s.timers)
+			continue
+		}
+		if next.deadline.After(s.now()) {
+			return
+		}
+		heap.Pop(&s.timers)
+		next.fire()
+	}
+}
+
+// canSkipTime tells whether the clock of the server can skip ahead to the next timer: a client waits for the result
+// of a workflow and there is no task to be processed by the workers, which would otherwise time out.
+func (s *Server) canSkipTime() bool {
+	if s.options.DisableTimeSkipping || s.resultWaiters == 0 || len(s.inFlightTasks) > 0 {
+		return false
+	}
+	for _, tl := range s.taskLists {
+		for _, task := range tl.tasks {
+			if task.valid() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (q timerQueue) Len() int           { return len(q) }
+func (q timerQueue) Less(i, j int) bool { return q[i].deadline.Before(q[j].deadline) }
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x interface{}) {
+	t := x.(*timer)
+	t.index = len(*q)
+	*q = append(*q, t)
+}
+
+func (q *timerQueue) Pop() interface{} {
+	old := *q
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return t
+}
+
+// newTaskToken returns a token identifying a task dispatched to a poller.
+func (s *Server) newTaskToken() string {
+	s.nextTaskID++
+	return fmt.Sprintf("task-%d", s.nextTaskID)
+}
+
+// finishTask removes a task from the tasks in flight. The timer loop is woken up since the clock may now skip ahead.
+func (s *Server) finishTask(token string) {
+	delete(s.inFlightTasks, token)
+	s.wake()
+}
+
+func (s *Server) getTaskList(key taskListKey) *taskList {
+	tl, ok := s.taskLists[key]
+	if !ok {
+		tl = &taskList{tasksAddedC: make(chan struct{}), pollers: make(map[string]time.Time)}
+		s.taskLists[key] = tl
+	}
+	return tl
+}
+
+func (s *Server) addTask(key taskListKey, task queuedTask) {
+	tl := s.getTaskList(key)
+	tl.tasks = append(tl.tasks, task)
+	close(tl.tasksAddedC)
+	tl.tasksAddedC = make(chan struct{})
+	s.wake()
+}
+
+// pollTask waits for a valid task in the task list. It is called and returns with the lock of the server held, the
+// task is nil if the long poll expired or the server was stopped.
+func (s *Server) pollTask(ctx context.Context, key taskListKey, identity string) queuedTask {
+	expiredC, stop := longPollExpiration(ctx)
+	defer stop()
+	for {
+		tl := s.getTaskList(key)
+		tl.pollers[identity] = s.now()
+		for len(tl.tasks) > 0 {
+			task := tl.tasks[0]
+			tl.tasks[0] = nil
+			tl.tasks = tl.tasks[1:]
+			if task.valid() {
+				return task
+			}
+		}
+
+		tasksAddedC := tl.tasksAddedC
+		s.mu.Unlock()
+		select {
+		case <-tasksAddedC:
+			s.mu.Lock()
+		case <-expiredC:
+			s.mu.Lock()
+			return nil
+		case <-ctx.Done():
+			s.mu.Lock()
+			return nil
+		case <-s.stopC:
+			s.mu.Lock()
+			return nil
+		}
+	}
+}
+
+// longPollExpiration returns a channel closed when a long poll with ctx should return an empty result.
+func longPollExpiration(ctx context.Context) (<-chan time.Time, func() bool) {
+	timeout := longPollTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout+longPollMargin {
+			timeout = remaining - longPollMargin
+			if timeout < remaining/2 {
+				timeout = remaining / 2
+			}
+		}
+	}
+	t := time.NewTimer(timeout)
+	return t.C, t.Stop
+}
+
+func newDomain(request *shared.RegisterDomainRequest) *domain {
+	retention := request.GetWorkflowExecutionRetentionPeriodInDays()
+	if retention <= 0 {
+		retention = defaultRetentionDays
+	}
+	return &domain{
+		info: &shared.DomainInfo{
+			Name:        request.Name,
+			Status:      shared.DomainStatusRegistered.Ptr(),
+			Description: request.Description,
+			OwnerEmail:  request.OwnerEmail,
+			Data:        request.Data,
+			UUID:        common.StringPtr(uuid.New()),
+		},
+		configuration: &shared.DomainConfiguration{
+			WorkflowExecutionRetentionPeriodInDays: common.Int32Ptr(retention),
+			EmitMetric:                             request.EmitMetric,
+			BadBinaries:                            &shared.BadBinaries{Binaries: map[string]*shared.BadBinaryInfo{}},
+			HistoryArchivalStatus:                  shared.ArchivalStatusDisabled.Ptr(),
+			VisibilityArchivalStatus:               shared.ArchivalStatusDisabled.Ptr(),
+		},
+	}
+}
+
+func (d *domain) describe() *shared.DescribeDomainResponse {
+	return &shared.DescribeDomainResponse{
+		DomainInfo:    d.info,
+		Configuration: d.configuration,
+		ReplicationConfiguration: &shared.DomainReplicationConfiguration{
+			ActiveClusterName: common.StringPtr("active"),
+			Clusters:          []*shared.ClusterReplicationConfiguration{{ClusterName: common.StringPtr("active")}},
+		},
+		FailoverVersion: common.Int64Ptr(0),
+		IsGlobalDomain:  common.BoolPtr(false),
+	}
+}
+
+func (s *Server) getDomain(name string) (*domain, error) {
+	d, ok := s.domains[name]
+	if !ok {
+		return nil, &shared.EntityNotExistsError{Message: fmt.Sprintf("Domain: %v does not exist.", name)}
+	}
+	return d, nil
+}
+
+// RegisterDomain registers a domain.
+func (s *Server) RegisterDomain(ctx context.Context, request *shared.RegisterDomainRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := request.GetName()
+	if name == "" {
+		return &shared.BadRequestError{Message: "Domain not set on request."}
+	}
+	if _, ok := s.domains[name]; ok {
+		return &shared.DomainAlreadyExistsError{Message: fmt.Sprintf("Domain already exists: %v.", name)}
+	}
+	s.domains[name] = newDomain(request)
+	return nil
+}
+
+// DescribeDomain returns the information and the configuration of a domain, by name or by UUID.
+func (s *Server) DescribeDomain(ctx context.Context, request *shared.DescribeDomainRequest, opts ...yarpc.CallOption) (*shared.DescribeDomainResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if request.UUID != nil {
+		for _, d := range s.domains {
+			if d.info.GetUUID() == request.GetUUID() {
+				return d.describe(), nil
+			}
+		}
+		return nil, &shared.EntityNotExistsError{Message: fmt.Sprintf("Domain: %v does not exist.", request.GetUUID())}
+	}
+	d, err := s.getDomain(request.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return d.describe(), nil
+}
+
+// UpdateDomain updates the information and the configuration of a domain.
+func (s *Server) UpdateDomain(ctx context.Context, request *shared.UpdateDomainRequest, opts ...yarpc.CallOption) (*shared.UpdateDomainResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.getDomain(request.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if info := request.UpdatedInfo; info != nil {
+		if info.Description != nil {
+			d.info.Description = info.Description
+		}
+		if info.OwnerEmail != nil {
+			d.info.OwnerEmail = info.OwnerEmail
+		}
+		if info.Data != nil {
+			if d.info.Data == nil {
+				d.info.Data = make(map[string]string)
+			}
+			for k, v := range info.Data {
+				d.info.Data[k] = v
+			}
+		}
+	}
+	if configuration := request.Configuration; configuration != nil {
+		if configuration.WorkflowExecutionRetentionPeriodInDays != nil {
+			d.configuration.WorkflowExecutionRetentionPeriodInDays = configuration.WorkflowExecutionRetentionPeriodInDays
+		}
+		if configuration.EmitMetric != nil {
+			d.configuration.EmitMetric = configuration.EmitMetric
+		}
+		if configuration.BadBinaries != nil {
+			for checksum, info := range configuration.BadBinaries.Binaries {
+				d.configuration.BadBinaries.Binaries[checksum] = info
+			}
+		}
+	}
+	if checksum := request.GetDeleteBadBinary(); checksum != "" {
+		delete(d.configuration.BadBinaries.Binaries, checksum)
+	}
+	response := d.describe()
+	return &shared.UpdateDomainResponse{
+		DomainInfo:               response.DomainInfo,
+		Configuration:            response.Configuration,
+		ReplicationConfiguration: response.ReplicationConfiguration,
+		FailoverVersion:          response.FailoverVersion,
+		IsGlobalDomain:           response.IsGlobalDomain,
+	}, nil
+}
+
+// DeprecateDomain deprecates a domain.
+func (s *Server) DeprecateDomain(ctx context.Context, request *shared.DeprecateDomainRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.getDomain(request.GetName())
+	if err != nil {
+		return err
+	}
+	d.info.Status = shared.DomainStatusDeprecated.Ptr()
+	return nil
+}
+
+// ListDomains returns all the domains, sorted by name.
+func (s *Server) ListDomains(ctx context.Context, request *shared.ListDomainsRequest, opts ...yarpc.CallOption) (*shared.ListDomainsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response := &shared.ListDomainsResponse{}
+	for _, d := range s.domains {
+		response.Domains = append(response.Domains, d.describe())
+	}
+	sort.Slice(response.Domains, func(i, j int) bool {
+		return response.Domains[i].DomainInfo.GetName() < response.Domains[j].DomainInfo.GetName()
+	})
+	return response, nil
+}
+
+// DescribeTaskList returns the pollers of a task list.
+func (s *Server) DescribeTaskList(ctx context.Context, request *shared.DescribeTaskListRequest, opts ...yarpc.CallOption) (*shared.DescribeTaskListResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getDomain(request.GetDomain()); err != nil {
+		return nil, err
+	}
+	kind := decisionTaskList
+	if request.GetTaskListType() == shared.TaskListTypeActivity {
+		kind = activityTaskList
+	}
+	return s.describeTaskList(taskListKey{domain: request.GetDomain(), name: request.TaskList.GetName(), kind: kind}), nil
+}
+
+func (s *Server) describeTaskList(key taskListKey) *shared.DescribeTaskListResponse {
+	response := &shared.DescribeTaskListResponse{}
+	tl, ok := s.taskLists[key]
+	if !ok {
+		response.TaskListStatus = &shared.TaskListStatus{BacklogCountHint: common.Int64Ptr(0)}
+		return response
+	}
+	var backlog int64
+	for _, task := range tl.tasks {
+		if task.valid() {
+			backlog++
+		}
+	}
+	response.TaskListStatus = &shared.TaskListStatus{BacklogCountHint: common.Int64Ptr(backlog)}
+	for identity, lastAccessTime := range tl.pollers {
+		response.Pollers = append(response.Pollers, &shared.PollerInfo{
+			Identity:       common.StringPtr(identity),
+			LastAccessTime: common.Int64Ptr(lastAccessTime.UnixNano()),
+		})
+	}
+	sort.Slice(response.Pollers, func(i, j int) bool {
+		return response.Pollers[i].GetIdentity() < response.Pollers[j].GetIdentity()
+	})
+	return response
+}
+
+// GetTaskListsByDomain returns the task lists of a domain that were polled or had tasks added.
+func (s *Server) GetTaskListsByDomain(ctx context.Context, request *shared.GetTaskListsByDomainRequest, opts ...yarpc.CallOption) (*shared.GetTaskListsByDomainResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getDomain(request.GetDomainName()); err != nil {
+		return nil, err
+	}
+	response := &shared.GetTaskListsByDomainResponse{
+		DecisionTaskListMap: make(map[string]*shared.DescribeTaskListResponse),
+		ActivityTaskListMap: make(map[string]*shared.DescribeTaskListResponse),
+	}
+	for key := range s.taskLists {
+		if key.domain != request.GetDomainName() {
+			continue
+		}
+		if key.kind == decisionTaskList {
+			response.DecisionTaskListMap[key.name] = s.describeTaskList(key)
+		} else {
+			response.ActivityTaskListMap[key.name] = s.describeTaskList(key)
+		}
+	}
+	return response, nil
+}
+
+// ListTaskListPartitions returns the single partition of a task list.
+func (s *Server) ListTaskListPartitions(ctx context.Context, request *shared.ListTaskListPartitionsRequest, opts ...yarpc.CallOption) (*shared.ListTaskListPartitionsResponse, error) {
+	partition := []*shared.TaskListPartitionMetadata{{
+		Key:           common.StringPtr(request.TaskList.GetName()),
+		OwnerHostName: common.StringPtr("testserver"),
+	}}
+	return &shared.ListTaskListPartitionsResponse{
+		ActivityTaskListPartitions: partition,
+		DecisionTaskListPartitions: partition,
+	}, nil
+}
+
+// GetClusterInfo returns the information of the cluster, the server supports all the client versions.
+func (s *Server) GetClusterInfo(ctx context.Context, opts ...yarpc.CallOption) (*shared.ClusterInfo, error) {
+	return &shared.ClusterInfo{SupportedClientVersions: &shared.SupportedClientVersions{}}, nil
+}
+
+// GetSearchAttributes returns the system search attributes. Workflows can upsert any other search attribute.
+func (s *Server) GetSearchAttributes(ctx context.Context, opts ...yarpc.CallOption) (*shared.GetSearchAttributesResponse, error) {
+	return &shared.GetSearchAttributesResponse{Keys: map[string]shared.IndexedValueType{
+		"WorkflowID":    shared.IndexedValueTypeKeyword,
+		"RunID":         shared.IndexedValueTypeKeyword,
+		"WorkflowType":  shared.IndexedValueTypeKeyword,
+		"StartTime":     shared.IndexedValueTypeInt,
+		"ExecutionTime": shared.IndexedValueTypeInt,
+		"CloseTime":     shared.IndexedValueTypeInt,
+		"CloseStatus":   shared.IndexedValueTypeInt,
+		"HistoryLength": shared.IndexedValueTypeInt,
+		"TaskList":      shared.IndexedValueTypeKeyword,
+	}}, nil
+}
+
+// ResetStickyTaskList makes the decision tasks of the workflow dispatched to its task list again, e.g. after a
+// worker evicted it from its cache. Unlike the Cadence server, which lets it time out, a decision task pending on
+// the sticky task list is moved right away to the task list of the workflow.
+func (s *Server) ResetStickyTaskList(ctx context.Context, request *shared.ResetStickyTaskListRequest, opts ...yarpc.CallOption) (*shared.ResetStickyTaskListResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.getOpenExecution(request.GetDomain(), request.Execution)
+	if err != nil {
+		return nil, err
+	}
+	e.clearStickiness()
+	if d := e.decision; d != nil && d.sticky && !d.started {
+		d.timer.cancel()
+		s.dispatchDecision(&decisionTask{
+			execution:     e,
+			scheduledID:   d.scheduledID,
+			attempt:       d.attempt,
+			scheduledTime: d.scheduledTime,
+		})
+	}
+	return &shared.ResetStickyTaskListResponse{}, nil
+}
+
+// RefreshWorkflowTasks is a no-op, the tasks of the workflows are never lost.
+func (s *Server) RefreshWorkflowTasks(ctx context.Context, request *shared.RefreshWorkflowTasksRequest, opts ...yarpc.CallOption) error {
+	return nil
+}
+
+// ResetWorkflowExecution is not supported.
+func (s *Server) ResetWorkflowExecution(ctx context.Context, request *shared.ResetWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.ResetWorkflowExecutionResponse, error) {
+	return nil, yarpcerrors.UnimplementedErrorf("the test server does not support workflow resets")
+}
+
+// ListArchivedWorkflowExecutions is not supported.
+func (s *Server) ListArchivedWorkflowExecutions(ctx context.Context, request *shared.ListArchivedWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListArchivedWorkflowExecutionsResponse, error) {
+	return nil, yarpcerrors.UnimplementedErrorf("the test server does not support archival")
+}
@@ -0,0 +1,388 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testserver_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/zap/zaptest"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/client"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/cadence/testserver"
+	"go.uber.org/cadence/worker"
+	"go.uber.org/cadence/workflow"
+)
+
+const (
+	testDomain   = "test-domain"
+	testTaskList = "test-task-list"
+)
+
+func newTestEnv(t *testing.T, register func(worker.Worker)) (*testserver.Server, client.Client) {
+	server := testserver.NewServer(testserver.Options{Domains: []string{testDomain}})
+	w := worker.New(server, testDomain, testTaskList, worker.Options{Logger: zaptest.NewLogger(t)})
+	register(w)
+	require.NoError(t, w.Start())
+	t.Cleanup(func() {
+		w.Stop()
+		server.Stop()
+	})
+	return server, client.NewClient(server, testDomain, nil)
+}
+
+func startOptions(id string) client.StartWorkflowOptions {
+	return client.StartWorkflowOptions{
+		ID:                              id,
+		TaskList:                        testTaskList,
+		ExecutionStartToCloseTimeout:    7 * 24 * time.Hour,
+		DecisionTaskStartToCloseTimeout: 10 * time.Second,
+	}
+}
+
+func upperActivity(ctx context.Context, name string) (string, error) {
+	return "HELLO " + name, nil
+}
+
+func sleepingWorkflow(ctx workflow.Context, name string) (string, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+	})
+	var greeting string
+	if err := workflow.ExecuteActivity(ctx, upperActivity, name).Get(ctx, &greeting); err != nil {
+		return "", err
+	}
+	if err := workflow.Sleep(ctx, 72*time.Hour); err != nil {
+		return "", err
+	}
+	return greeting, nil
+}
+
+func TestTimeSkipping(t *testing.T) {
+	server, c := newTestEnv(t, func(w worker.Worker) {
+		w.RegisterWorkflow(sleepingWorkflow)
+		w.RegisterActivity(upperActivity)
+	})
+	start := server.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	run, err := c.ExecuteWorkflow(ctx, startOptions("sleeping"), sleepingWorkflow, "WORLD")
+	require.NoError(t, err)
+	var result string
+	require.NoError(t, run.Get(ctx, &result))
+	require.Equal(t, "HELLO WORLD", result)
+	require.True(t, server.Now().Sub(start) >= 72*time.Hour)
+}
+
+func signalWorkflow(ctx workflow.Context) (string, error) {
+	state := "waiting"
+	if err := workflow.SetQueryHandler(ctx, "state", func() (string, error) { return state, nil }); err != nil {
+		return "", err
+	}
+	var value string
+	workflow.GetSignalChannel(ctx, "signal").Receive(ctx, &value)
+	state = "done"
+	return value, nil
+}
+
+func TestSignalAndQuery(t *testing.T) {
+	_, c := newTestEnv(t, func(w worker.Worker) {
+		w.RegisterWorkflow(signalWorkflow)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	run, err := c.ExecuteWorkflow(ctx, startOptions("signal"), signalWorkflow)
+	require.NoError(t, err)
+
+	var state string
+	require.Eventually(t, func() bool {
+		value, err := c.QueryWorkflow(ctx, "signal", run.GetRunID(), "state")
+		return err == nil && value.Get(&state) == nil
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, "waiting", state)
+
+	require.NoError(t, c.SignalWorkflow(ctx, "signal", run.GetRunID(), "signal", "value"))
+	var result string
+	require.NoError(t, run.Get(ctx, &result))
+	require.Equal(t, "value", result)
+
+	value, err := c.QueryWorkflow(ctx, "signal", run.GetRunID(), "state")
+	require.NoError(t, err)
+	require.NoError(t, value.Get(&state))
+	require.Equal(t, "done", state)
+}
+
+func flakyActivity(ctx context.Context) (int32, error) {
+	attempt := activity.GetInfo(ctx).Attempt
+	if attempt < 2 {
+		return 0, errors.New("flaky")
+	}
+	return attempt, nil
+}
+
+func retryWorkflow(ctx workflow.Context) (int32, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		RetryPolicy: &cadence.RetryPolicy{
+			InitialInterval:    time.Minute,
+			BackoffCoefficient: 2,
+			MaximumAttempts:    5,
+		},
+	})
+	var attempt int32
+	err := workflow.ExecuteActivity(ctx, flakyActivity).Get(ctx, &attempt)
+	return attempt, err
+}
+
+func TestActivityRetry(t *testing.T) {
+	_, c := newTestEnv(t, func(w worker.Worker) {
+		w.RegisterWorkflow(retryWorkflow)
+		w.RegisterActivity(flakyActivity)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	run, err := c.ExecuteWorkflow(ctx, startOptions("retry"), retryWorkflow)
+	require.NoError(t, err)
+	var attempt int32
+	require.NoError(t, run.Get(ctx, &attempt))
+	require.Equal(t, int32(2), attempt)
+}
+
+func childWorkflow(ctx workflow.Context, value int) (int, error) {
+	return value * 2, nil
+}
+
+func parentWorkflow(ctx workflow.Context) (int, error) {
+	ctx = workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		ExecutionStartToCloseTimeout: time.Hour,
+	})
+	var result int
+	err := workflow.ExecuteChildWorkflow(ctx, childWorkflow, 21).Get(ctx, &result)
+	return result, err
+}
+
+func TestChildWorkflow(t *testing.T) {
+	_, c := newTestEnv(t, func(w worker.Worker) {
+		w.RegisterWorkflow(parentWorkflow)
+		w.RegisterWorkflow(childWorkflow)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	run, err := c.ExecuteWorkflow(ctx, startOptions("parent"), parentWorkflow)
+	require.NoError(t, err)
+	var result int
+	require.NoError(t, run.Get(ctx, &result))
+	require.Equal(t, 42, result)
+}
+
+func TestWorkflowTimeout(t *testing.T) {
+	_, c := newTestEnv(t, func(w worker.Worker) {
+		w.RegisterWorkflow(signalWorkflow)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	run, err := c.ExecuteWorkflow(ctx, startOptions("timeout"), signalWorkflow)
+	require.NoError(t, err)
+	err = run.Get(ctx, nil)
+	var timeoutErr *workflow.TimeoutError
+	require.True(t, errors.As(err, &timeoutErr), "unexpected error: %v", err)
+	require.Equal(t, shared.TimeoutTypeStartToClose, timeoutErr.TimeoutType())
+
+	_, err = c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:                              "timeout",
+		TaskList:                        testTaskList,
+		ExecutionStartToCloseTimeout:    time.Hour,
+		DecisionTaskStartToCloseTimeout: 10 * time.Second,
+		WorkflowIDReusePolicy:           client.WorkflowIDReusePolicyAllowDuplicateFailedOnly,
+	}, signalWorkflow)
+	require.NoError(t, err)
+}
+
+// decisionRecorder records the decision tasks dispatched by the server with the kind of the task list they were
+// polled from.
+type decisionRecorder struct {
+	*testserver.Server
+
+	mu     sync.Mutex
+	tasks  []*shared.PollForDecisionTaskResponse
+	kinds  []shared.TaskListKind
+	resets int
+	// dropSticky makes the polls of the sticky task lists return no task without polling the server.
+	dropSticky bool
+}
+
+func (r *decisionRecorder) dropStickyPolls() {
+	r.mu.Lock()
+	r.dropSticky = true
+	r.mu.Unlock()
+}
+
+func (r *decisionRecorder) PollForDecisionTask(ctx context.Context, request *shared.PollForDecisionTaskRequest, opts ...yarpc.CallOption) (*shared.PollForDecisionTaskResponse, error) {
+	r.mu.Lock()
+	drop := r.dropSticky && request.TaskList.GetKind() == shared.TaskListKindSticky
+	r.mu.Unlock()
+	if drop {
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+		return &shared.PollForDecisionTaskResponse{}, nil
+	}
+	response, err := r.Server.PollForDecisionTask(ctx, request, opts...)
+	if err == nil && len(response.TaskToken) > 0 && response.Query == nil {
+		r.mu.Lock()
+		r.tasks = append(r.tasks, response)
+		r.kinds = append(r.kinds, request.TaskList.GetKind())
+		r.mu.Unlock()
+	}
+	return response, err
+}
+
+func (r *decisionRecorder) ResetStickyTaskList(ctx context.Context, request *shared.ResetStickyTaskListRequest, opts ...yarpc.CallOption) (*shared.ResetStickyTaskListResponse, error) {
+	r.mu.Lock()
+	r.resets++
+	r.mu.Unlock()
+	return r.Server.ResetStickyTaskList(ctx, request, opts...)
+}
+
+func (r *decisionRecorder) lastTask() (*shared.PollForDecisionTaskResponse, shared.TaskListKind) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tasks[len(r.tasks)-1], r.kinds[len(r.kinds)-1]
+}
+
+func newRecorderEnv(t *testing.T) (*decisionRecorder, client.Client) {
+	server := testserver.NewServer(testserver.Options{Domains: []string{testDomain}})
+	t.Cleanup(server.Stop)
+	return &decisionRecorder{Server: server}, client.NewClient(server, testDomain, nil)
+}
+
+func startWorker(t *testing.T, service *decisionRecorder, options worker.Options) worker.Worker {
+	options.Logger = zaptest.NewLogger(t)
+	w := worker.New(service, testDomain, testTaskList, options)
+	w.RegisterWorkflow(signalWorkflow)
+	require.NoError(t, w.Start())
+	t.Cleanup(w.Stop)
+	return w
+}
+
+// waitForFirstDecision waits until the first decision task of the workflow completed.
+func waitForFirstDecision(ctx context.Context, t *testing.T, c client.Client, workflowID string) {
+	require.Eventually(t, func() bool {
+		_, err := c.QueryWorkflow(ctx, workflowID, "", "state")
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestStickyExecution(t *testing.T) {
+	service, c := newRecorderEnv(t)
+	startWorker(t, service, worker.Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	run, err := c.ExecuteWorkflow(ctx, startOptions("sticky"), signalWorkflow)
+	require.NoError(t, err)
+	waitForFirstDecision(ctx, t, c, "sticky")
+
+	first, kind := service.lastTask()
+	require.Equal(t, shared.TaskListKindNormal, kind)
+	require.Equal(t, int64(1), first.History.Events[0].GetEventId())
+
+	require.NoError(t, c.SignalWorkflow(ctx, "sticky", "", "signal", "value"))
+	var result string
+	require.NoError(t, run.Get(ctx, &result))
+	require.Equal(t, "value", result)
+
+	// the worker caching the workflow got the sticky decision task with the events since the first one only
+	second, kind := service.lastTask()
+	require.Equal(t, shared.TaskListKindSticky, kind)
+	require.Equal(t, first.GetStartedEventId(), second.GetPreviousStartedEventId())
+	require.Equal(t, second.GetPreviousStartedEventId()+1, second.History.Events[0].GetEventId())
+}
+
+func TestStickyScheduleToStartTimeout(t *testing.T) {
+	service, c := newRecorderEnv(t)
+	// the sticky decision task times out as if the worker caching the workflow was gone, and is dispatched with the
+	// whole history to the task list of the workflow
+	service.dropStickyPolls()
+	startWorker(t, service, worker.Options{StickyScheduleToStartTimeout: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	run, err := c.ExecuteWorkflow(ctx, startOptions("sticky-timeout"), signalWorkflow)
+	require.NoError(t, err)
+	waitForFirstDecision(ctx, t, c, "sticky-timeout")
+
+	require.NoError(t, c.SignalWorkflow(ctx, "sticky-timeout", "", "signal", "value"))
+	var result string
+	require.NoError(t, run.Get(ctx, &result))
+	require.Equal(t, "value", result)
+
+	task, kind := service.lastTask()
+	require.Equal(t, shared.TaskListKindNormal, kind)
+	require.Equal(t, int64(1), task.History.Events[0].GetEventId())
+	var timedOut *shared.DecisionTaskTimedOutEventAttributes
+	iter := c.GetWorkflowHistory(ctx, "sticky-timeout", "", false, shared.HistoryEventFilterTypeAllEvent)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		require.NoError(t, err)
+		if event.DecisionTaskTimedOutEventAttributes != nil {
+			timedOut = event.DecisionTaskTimedOutEventAttributes
+		}
+	}
+	require.NotNil(t, timedOut)
+	require.Equal(t, shared.TimeoutTypeScheduleToStart, timedOut.GetTimeoutType())
+}
+
+func TestResetStickyTaskList(t *testing.T) {
+	service, c := newRecorderEnv(t)
+	startWorker(t, service, worker.Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	run, err := c.ExecuteWorkflow(ctx, startOptions("reset-sticky"), signalWorkflow)
+	require.NoError(t, err)
+	waitForFirstDecision(ctx, t, c, "reset-sticky")
+
+	_, err = service.ResetStickyTaskList(ctx, &shared.ResetStickyTaskListRequest{
+		Domain:    common.StringPtr(testDomain),
+		Execution: &shared.WorkflowExecution{WorkflowId: common.StringPtr("reset-sticky")},
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.SignalWorkflow(ctx, "reset-sticky", "", "signal", "value"))
+	var result string
+	require.NoError(t, run.Get(ctx, &result))
+	require.Equal(t, "value", result)
+
+	task, kind := service.lastTask()
+	require.Equal(t, shared.TaskListKindNormal, kind)
+	require.Equal(t, int64(1), task.History.Events[0].GetEventId())
+	require.Equal(t, 1, service.resets)
+}
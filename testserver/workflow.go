@@ -0,0 +1,1148 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testserver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/robfig/cron"
+	"go.uber.org/yarpc"
+
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+// timeoutReasonPrefix is the failure reason prefix of the timeouts retried by a retry policy, the same as the
+// workflows and activities of the client use for their timeout errors.
+const timeoutReasonPrefix = "cadenceInternal:Timeout "
+
+type (
+	// execution is a run of a workflow.
+	execution struct {
+		domain     string
+		workflowID string
+		runID      string
+		requestID  string
+		started    *shared.WorkflowExecutionStartedEventAttributes
+
+		startTime     time.Time
+		executionTime time.Time
+		closeTime     time.Time
+		// closeStatus is nil while the workflow is open.
+		closeStatus *shared.WorkflowExecutionCloseStatus
+
+		history []*shared.HistoryEvent
+		// historyChangedC is closed and replaced when events are added to the history.
+		historyChangedC chan struct{}
+		// buffered are the events that happened while a decision task is started or the decisions of a completed
+		// one are applied. They are added to the history after the events of the decisions.
+		buffered []*shared.HistoryEvent
+		// bufferedNeedDecision tells whether a buffered event has to be handled by a new decision task.
+		bufferedNeedDecision bool
+		applyingDecisions    bool
+
+		decision               *decisionTask
+		decisionAttempt        int64
+		previousStartedEventID int64
+		// stickyTaskList is the task list of the worker caching the workflow, set by the sticky attributes of the
+		// last completed decision task. The decision tasks are dispatched to it with the events since the previous
+		// decision task, until one is not started within stickyScheduleToStartTimeout.
+		stickyTaskList               string
+		stickyScheduleToStartTimeout time.Duration
+		// decisionBackoff is set until the first decision task of a retry, of a cron run or of a delayed start is
+		// scheduled.
+		decisionBackoff bool
+
+		activities map[int64]*activity
+		timers     map[string]*userTimer
+		children   map[int64]*childWorkflow
+
+		parent            *execution
+		parentInitiatedID int64
+
+		cancelRequested  bool
+		requestIDs       map[string]bool
+		memo             *shared.Memo
+		searchAttributes *shared.SearchAttributes
+		executionTimer   *timer
+	}
+
+	userTimer struct {
+		startedID int64
+		timer     *timer
+	}
+
+	// childWorkflow is a child workflow started by the decisions of its parent.
+	childWorkflow struct {
+		initiatedID  int64
+		startedEvent *shared.HistoryEvent
+		domain       string
+		workflowType *shared.WorkflowType
+		policy       shared.ParentClosePolicy
+		// execution is the current run of the child workflow.
+		execution *execution
+	}
+
+	// startRequest are the parameters of the requests and decisions starting a workflow.
+	startRequest struct {
+		domain            string
+		workflowID        string
+		requestID         string
+		identity          string
+		workflowType      *shared.WorkflowType
+		taskList          string
+		input             []byte
+		executionTimeout  int32
+		decisionTimeout   int32
+		reusePolicy       *shared.WorkflowIdReusePolicy
+		retryPolicy       *shared.RetryPolicy
+		cronSchedule      string
+		memo              *shared.Memo
+		searchAttributes  *shared.SearchAttributes
+		header            *shared.Header
+		delayStart        int32
+		parent            *execution
+		parentInitiatedID int64
+	}
+)
+
+func seconds(v int32) time.Duration {
+	return time.Duration(v) * time.Second
+}
+
+func (e *execution) closed() bool {
+	return e.closeStatus != nil
+}
+
+func (e *execution) taskList() string {
+	return e.started.TaskList.GetName()
+}
+
+func (e *execution) workflowExecution() *shared.WorkflowExecution {
+	return &shared.WorkflowExecution{WorkflowId: common.StringPtr(e.workflowID), RunId: common.StringPtr(e.runID)}
+}
+
+// newEvent creates an event of the history at the current time of the server. Its ID is allocated and set when the
+// event is added to the history, so that the attributes of later events can refer to a buffered event.
+func (s *Server) newEvent(eventType shared.EventType) *shared.HistoryEvent {
+	return &shared.HistoryEvent{
+		EventId:   new(int64),
+		Timestamp: common.Int64Ptr(s.now().UnixNano()),
+		EventType: eventType.Ptr(),
+	}
+}
+
+// appendEvent adds an event to the history of the workflow.
+func (e *execution) appendEvent(event *shared.HistoryEvent) {
+	*event.EventId = int64(len(e.history) + 1)
+	e.history = append(e.history, event)
+	close(e.historyChangedC)
+	e.historyChangedC = make(chan struct{})
+}
+
+// recordEvent adds an event that did not result from the decisions of the workflow, like a signal or the completion
+// of an activity, to its history. The event is buffered while a decision task is started and a decision task is
+// scheduled to handle the event if needDecision.
+func (s *Server) recordEvent(e *execution, event *shared.HistoryEvent, needDecision bool) {
+	if e.closed() {
+		return
+	}
+	if e.applyingDecisions || (e.decision != nil && e.decision.started) {
+		e.buffered = append(e.buffered, event)
+		e.bufferedNeedDecision = e.bufferedNeedDecision || needDecision
+		return
+	}
+	e.appendEvent(event)
+	if needDecision {
+		s.scheduleDecision(e)
+	}
+}
+
+// flushBufferedEvents adds the buffered events to the history and tells whether they need a new decision task.
+func (s *Server) flushBufferedEvents(e *execution) bool {
+	for _, event := range e.buffered {
+		e.appendEvent(event)
+	}
+	needDecision := e.bufferedNeedDecision
+	e.buffered = nil
+	e.bufferedNeedDecision = false
+	return needDecision
+}
+
+func (s *Server) getExecution(domain string, workflowExecution *shared.WorkflowExecution) (*execution, error) {
+	if _, err := s.getDomain(domain); err != nil {
+		return nil, err
+	}
+	var e *execution
+	if runID := workflowExecution.GetRunId(); runID != "" {
+		e = s.executions[executionKey{domain: domain, workflowID: workflowExecution.GetWorkflowId(), runID: runID}]
+	} else {
+		e = s.currentRuns[workflowKey{domain: domain, workflowID: workflowExecution.GetWorkflowId()}]
+	}
+	if e == nil {
+		return nil, &shared.EntityNotExistsError{Message: fmt.Sprintf(
+			"Workflow execution not found. WorkflowId: %v, RunId: %v.", workflowExecution.GetWorkflowId(), workflowExecution.GetRunId())}
+	}
+	return e, nil
+}
+
+func (s *Server) getOpenExecution(domain string, workflowExecution *shared.WorkflowExecution) (*execution, error) {
+	e, err := s.getExecution(domain, workflowExecution)
+	if err != nil {
+		return nil, err
+	}
+	if e.closed() {
+		return nil, &shared.WorkflowExecutionAlreadyCompletedError{Message: "Workflow execution already completed."}
+	}
+	return e, nil
+}
+
+func (r *startRequest) validate() error {
+	switch {
+	case r.workflowID == "":
+		return &shared.BadRequestError{Message: "WorkflowId is not set on request."}
+	case r.workflowType.GetName() == "":
+		return &shared.BadRequestError{Message: "WorkflowType is not set on request."}
+	case r.taskList == "":
+		return &shared.BadRequestError{Message: "TaskList is not set on request."}
+	case r.executionTimeout <= 0:
+		return &shared.BadRequestError{Message: "A valid ExecutionStartToCloseTimeoutSeconds is not set on request."}
+	case r.decisionTimeout <= 0:
+		return &shared.BadRequestError{Message: "A valid TaskStartToCloseTimeoutSeconds is not set on request."}
+	case r.delayStart < 0:
+		return &shared.BadRequestError{Message: "Invalid DelayStartSeconds."}
+	}
+	if r.cronSchedule != "" {
+		if _, err := cron.ParseStandard(r.cronSchedule); err != nil {
+			return &shared.BadRequestError{Message: fmt.Sprintf("Invalid CronSchedule: %v.", err)}
+		}
+	}
+	return nil
+}
+
+// start starts a workflow unless its ID reuse policy does not allow it. It returns the open run of the workflow
+// instead if the request is a retry of the one that started the run. The first decision task of a new run is scheduled
+// with scheduleFirstDecision.
+func (s *Server) start(r *startRequest) (e *execution, created bool, err error) {
+	current := s.currentRuns[workflowKey{domain: r.domain, workflowID: r.workflowID}]
+	if current != nil && !current.closed() {
+		if r.requestID != "" && current.requestID == r.requestID {
+			return current, false, nil
+		}
+		if r.reusePolicy == nil || *r.reusePolicy != shared.WorkflowIdReusePolicyTerminateIfRunning {
+			return nil, false, &shared.WorkflowExecutionAlreadyStartedError{
+				Message:        common.StringPtr(fmt.Sprintf("Workflow execution is already running. WorkflowId: %v, RunId: %v.", current.workflowID, current.runID)),
+				StartRequestId: common.StringPtr(current.requestID),
+				RunId:          common.StringPtr(current.runID),
+			}
+		}
+		s.terminate(current, "terminated by a new run of the workflow", nil, r.identity)
+	} else if current != nil && r.reusePolicy != nil {
+		switch *r.reusePolicy {
+		case shared.WorkflowIdReusePolicyRejectDuplicate:
+			return nil, false, &shared.WorkflowExecutionAlreadyStartedError{
+				Message: common.StringPtr(fmt.Sprintf("Workflow execution already finished. WorkflowId: %v, RunId: %v.", current.workflowID, current.runID)),
+				RunId:   common.StringPtr(current.runID),
+			}
+		case shared.WorkflowIdReusePolicyAllowDuplicateFailedOnly:
+			if *current.closeStatus == shared.WorkflowExecutionCloseStatusCompleted {
+				return nil, false, &shared.WorkflowExecutionAlreadyStartedError{
+					Message: common.StringPtr(fmt.Sprintf("Workflow execution already finished successfully. WorkflowId: %v, RunId: %v.", current.workflowID, current.runID)),
+					RunId:   common.StringPtr(current.runID),
+				}
+			}
+		}
+	}
+
+	runID := uuid.New()
+	now := s.now()
+	attributes := &shared.WorkflowExecutionStartedEventAttributes{
+		WorkflowType:                        r.workflowType,
+		TaskList:                            &shared.TaskList{Name: common.StringPtr(r.taskList)},
+		Input:                               r.input,
+		ExecutionStartToCloseTimeoutSeconds: common.Int32Ptr(r.executionTimeout),
+		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(r.decisionTimeout),
+		OriginalExecutionRunId:              common.StringPtr(runID),
+		FirstExecutionRunId:                 common.StringPtr(runID),
+		Identity:                            common.StringPtr(r.identity),
+		RetryPolicy:                         r.retryPolicy,
+		Attempt:                             common.Int32Ptr(0),
+		Memo:                                r.memo,
+		SearchAttributes:                    r.searchAttributes,
+		Header:                              r.header,
+	}
+	if expiration := r.retryPolicy.GetExpirationIntervalInSeconds(); expiration > 0 {
+		attributes.ExpirationTimestamp = common.Int64Ptr(now.Add(seconds(expiration)).UnixNano())
+	}
+	backoff := seconds(r.delayStart)
+	if r.cronSchedule != "" {
+		attributes.CronSchedule = common.StringPtr(r.cronSchedule)
+		backoff += cronBackoff(r.cronSchedule, now.Add(backoff))
+	}
+	if backoff > 0 {
+		attributes.FirstDecisionTaskBackoffSeconds = common.Int32Ptr(int32(backoff / time.Second))
+	}
+	if r.parent != nil {
+		attributes.ParentWorkflowDomain = common.StringPtr(r.parent.domain)
+		attributes.ParentWorkflowExecution = r.parent.workflowExecution()
+		attributes.ParentInitiatedEventId = common.Int64Ptr(r.parentInitiatedID)
+	}
+	e = s.startExecution(r.domain, r.workflowID, runID, r.requestID, attributes)
+	e.parent = r.parent
+	e.parentInitiatedID = r.parentInitiatedID
+	return e, true, nil
+}
+
+// startExecution starts a run of a workflow with the attributes of its started event.
+func (s *Server) startExecution(domain, workflowID, runID, requestID string, attributes *shared.WorkflowExecutionStartedEventAttributes) *execution {
+	e := &execution{
+		domain:           domain,
+		workflowID:       workflowID,
+		runID:            runID,
+		requestID:        requestID,
+		started:          attributes,
+		startTime:        s.now(),
+		historyChangedC:  make(chan struct{}),
+		activities:       make(map[int64]*activity),
+		timers:           make(map[string]*userTimer),
+		children:         make(map[int64]*childWorkflow),
+		requestIDs:       make(map[string]bool),
+		memo:             attributes.Memo,
+		searchAttributes: attributes.SearchAttributes,
+	}
+	event := s.newEvent(shared.EventTypeWorkflowExecutionStarted)
+	event.WorkflowExecutionStartedEventAttributes = attributes
+	e.appendEvent(event)
+	s.executions[executionKey{domain: domain, workflowID: workflowID, runID: runID}] = e
+	s.currentRuns[workflowKey{domain: domain, workflowID: workflowID}] = e
+
+	backoff := seconds(attributes.GetFirstDecisionTaskBackoffSeconds())
+	e.executionTime = e.startTime.Add(backoff)
+	e.decisionBackoff = backoff > 0
+	e.executionTimer = s.addTimer(backoff+seconds(attributes.GetExecutionStartToCloseTimeoutSeconds()), func() {
+		s.timeoutExecution(e)
+	})
+	return e
+}
+
+// scheduleFirstDecision schedules the first decision task of a new run, after the backoff of the run if any.
+func (s *Server) scheduleFirstDecision(e *execution) {
+	if !e.decisionBackoff {
+		s.scheduleDecision(e)
+		return
+	}
+	s.addTimer(e.executionTime.Sub(e.startTime), func() {
+		e.decisionBackoff = false
+		s.scheduleDecision(e)
+	})
+}
+
+// closeExecution adds the event closing the workflow to its history, after the buffered events, and notifies its
+// parent.
+func (s *Server) closeExecution(e *execution, status shared.WorkflowExecutionCloseStatus, event *shared.HistoryEvent) {
+	s.flushBufferedEvents(e)
+	e.appendEvent(event)
+	e.closeStatus = status.Ptr()
+	e.closeTime = s.now()
+
+	e.executionTimer.cancel()
+	if e.decision != nil {
+		s.closeDecision(e.decision)
+	}
+	for _, t := range e.timers {
+		t.timer.cancel()
+	}
+	for _, act := range e.activities {
+		s.closeActivity(act)
+	}
+
+	// the tasks of the workflow left in the task lists are no longer valid, which may let the clock skip ahead
+	s.wake()
+
+	if e.parent != nil && status != shared.WorkflowExecutionCloseStatusContinuedAsNew {
+		s.notifyParent(e, status, event)
+	}
+	for _, child := range e.children {
+		if child.execution.closed() {
+			continue
+		}
+		switch child.policy {
+		case shared.ParentClosePolicyAbandon:
+		case shared.ParentClosePolicyRequestCancel:
+			s.requestCancel(child.execution, "parent close policy", "", nil, nil)
+		default:
+			s.terminate(child.execution, "by parent close policy", nil, "")
+		}
+	}
+}
+
+// notifyParent records the closing of a child workflow in the history of its parent.
+func (s *Server) notifyParent(e *execution, status shared.WorkflowExecutionCloseStatus, closeEvent *shared.HistoryEvent) {
+	parent := e.parent
+	child, ok := parent.children[e.parentInitiatedID]
+	if !ok {
+		return
+	}
+	delete(parent.children, e.parentInitiatedID)
+
+	var event *shared.HistoryEvent
+	switch status {
+	case shared.WorkflowExecutionCloseStatusCompleted:
+		event = s.newEvent(shared.EventTypeChildWorkflowExecutionCompleted)
+		event.ChildWorkflowExecutionCompletedEventAttributes = &shared.ChildWorkflowExecutionCompletedEventAttributes{
+			Result:            closeEvent.WorkflowExecutionCompletedEventAttributes.Result,
+			Domain:            common.StringPtr(child.domain),
+			WorkflowExecution: e.workflowExecution(),
+			WorkflowType:      child.workflowType,
+			InitiatedEventId:  common.Int64Ptr(child.initiatedID),
+			StartedEventId:    child.startedEvent.EventId,
+		}
+	case shared.WorkflowExecutionCloseStatusFailed:
+		event = s.newEvent(shared.EventTypeChildWorkflowExecutionFailed)
+		event.ChildWorkflowExecutionFailedEventAttributes = &shared.ChildWorkflowExecutionFailedEventAttributes{
+			Reason:            closeEvent.WorkflowExecutionFailedEventAttributes.Reason,
+			Details:           closeEvent.WorkflowExecutionFailedEventAttributes.Details,
+			Domain:            common.StringPtr(child.domain),
+			WorkflowExecution: e.workflowExecution(),
+			WorkflowType:      child.workflowType,
+			InitiatedEventId:  common.Int64Ptr(child.initiatedID),
+			StartedEventId:    child.startedEvent.EventId,
+		}
+	case shared.WorkflowExecutionCloseStatusCanceled:
+		event = s.newEvent(shared.EventTypeChildWorkflowExecutionCanceled)
+		event.ChildWorkflowExecutionCanceledEventAttributes = &shared.ChildWorkflowExecutionCanceledEventAttributes{
+			Details:           closeEvent.WorkflowExecutionCanceledEventAttributes.Details,
+			Domain:            common.StringPtr(child.domain),
+			WorkflowExecution: e.workflowExecution(),
+			WorkflowType:      child.workflowType,
+			InitiatedEventId:  common.Int64Ptr(child.initiatedID),
+			StartedEventId:    child.startedEvent.EventId,
+		}
+	case shared.WorkflowExecutionCloseStatusTimedOut:
+		event = s.newEvent(shared.EventTypeChildWorkflowExecutionTimedOut)
+		event.ChildWorkflowExecutionTimedOutEventAttributes = &shared.ChildWorkflowExecutionTimedOutEventAttributes{
+			TimeoutType:       closeEvent.WorkflowExecutionTimedOutEventAttributes.TimeoutType,
+			Domain:            common.StringPtr(child.domain),
+			WorkflowExecution: e.workflowExecution(),
+			WorkflowType:      child.workflowType,
+			InitiatedEventId:  common.Int64Ptr(child.initiatedID),
+			StartedEventId:    child.startedEvent.EventId,
+		}
+	default:
+		event = s.newEvent(shared.EventTypeChildWorkflowExecutionTerminated)
+		event.ChildWorkflowExecutionTerminatedEventAttributes = &shared.ChildWorkflowExecutionTerminatedEventAttributes{
+			Domain:            common.StringPtr(child.domain),
+			WorkflowExecution: e.workflowExecution(),
+			WorkflowType:      child.workflowType,
+			InitiatedEventId:  common.Int64Ptr(child.initiatedID),
+			StartedEventId:    child.startedEvent.EventId,
+		}
+	}
+	s.recordEvent(parent, event, true)
+}
+
+// completeExecution completes the workflow, or starts its next run if it has a cron schedule.
+func (s *Server) completeExecution(e *execution, result []byte, completedEventID *int64) {
+	if s.continueAfterClose(e, result, nil, nil, completedEventID) {
+		return
+	}
+	event := s.newEvent(shared.EventTypeWorkflowExecutionCompleted)
+	event.WorkflowExecutionCompletedEventAttributes = &shared.WorkflowExecutionCompletedEventAttributes{
+		Result:                       result,
+		DecisionTaskCompletedEventId: completedEventID,
+	}
+	s.closeExecution(e, shared.WorkflowExecutionCloseStatusCompleted, event)
+}
+
+// failExecution fails the workflow, or starts its next run if it has a retry policy or a cron schedule.
+func (s *Server) failExecution(e *execution, reason string, details []byte, completedEventID *int64) {
+	if s.continueAfterClose(e, nil, &reason, details, completedEventID) {
+		return
+	}
+	event := s.newEvent(shared.EventTypeWorkflowExecutionFailed)
+	event.WorkflowExecutionFailedEventAttributes = &shared.WorkflowExecutionFailedEventAttributes{
+		Reason:                       common.StringPtr(reason),
+		Details:                      details,
+		DecisionTaskCompletedEventId: completedEventID,
+	}
+	s.closeExecution(e, shared.WorkflowExecutionCloseStatusFailed, event)
+}
+
+func (s *Server) timeoutExecution(e *execution) {
+	if e.closed() {
+		return
+	}
+	timeoutType := shared.TimeoutTypeStartToClose
+	if s.continueAfterClose(e, nil, common.StringPtr(timeoutReasonPrefix+timeoutType.String()), nil, nil) {
+		return
+	}
+	event := s.newEvent(shared.EventTypeWorkflowExecutionTimedOut)
+	event.WorkflowExecutionTimedOutEventAttributes = &shared.WorkflowExecutionTimedOutEventAttributes{
+		TimeoutType: timeoutType.Ptr(),
+	}
+	s.closeExecution(e, shared.WorkflowExecutionCloseStatusTimedOut, event)
+}
+
+func (s *Server) terminate(e *execution, reason string, details []byte, identity string) {
+	event := s.newEvent(shared.EventTypeWorkflowExecutionTerminated)
+	event.WorkflowExecutionTerminatedEventAttributes = &shared.WorkflowExecutionTerminatedEventAttributes{
+		Reason:   common.StringPtr(reason),
+		Details:  details,
+		Identity: common.StringPtr(identity),
+	}
+	s.closeExecution(e, shared.WorkflowExecutionCloseStatusTerminated, event)
+}
+
+// continueAfterClose starts the next run of a workflow closing with result, or with failureReason if it failed or
+// timed out, as required by its retry policy or its cron schedule. It returns false if there is no next run.
+func (s *Server) continueAfterClose(e *execution, result []byte, failureReason *string, failureDetails []byte, completedEventID *int64) bool {
+	started := e.started
+	next := &shared.ContinueAsNewWorkflowExecutionDecisionAttributes{
+		WorkflowType:                        started.WorkflowType,
+		TaskList:                            started.TaskList,
+		Input:                               started.Input,
+		ExecutionStartToCloseTimeoutSeconds: started.ExecutionStartToCloseTimeoutSeconds,
+		TaskStartToCloseTimeoutSeconds:      started.TaskStartToCloseTimeoutSeconds,
+		RetryPolicy:                         started.RetryPolicy,
+		CronSchedule:                        started.CronSchedule,
+		Header:                              started.Header,
+		Memo:                                e.memo,
+		SearchAttributes:                    e.searchAttributes,
+		FailureReason:                       failureReason,
+		FailureDetails:                      failureDetails,
+		LastCompletionResult:                started.LastCompletionResult,
+	}
+	if failureReason != nil {
+		var expiration time.Time
+		if started.ExpirationTimestamp != nil {
+			expiration = time.Unix(0, started.GetExpirationTimestamp())
+		}
+		if backoff, ok := retryBackoff(started.RetryPolicy, started.GetAttempt(), *failureReason, s.now(), expiration); ok {
+			next.Initiator = shared.ContinueAsNewInitiatorRetryPolicy.Ptr()
+			next.BackoffStartIntervalInSeconds = common.Int32Ptr(int32(math.Ceil(backoff.Seconds())))
+			s.continueAsNew(e, next, started.GetAttempt()+1, completedEventID)
+			return true
+		}
+	}
+	if started.GetCronSchedule() == "" {
+		return false
+	}
+	if failureReason == nil {
+		next.LastCompletionResult = result
+	}
+	next.Initiator = shared.ContinueAsNewInitiatorCronSchedule.Ptr()
+	next.BackoffStartIntervalInSeconds = common.Int32Ptr(int32(cronBackoff(started.GetCronSchedule(), s.now()) / time.Second))
+	s.continueAsNew(e, next, 0, completedEventID)
+	return true
+}
+
+// continueAsNew closes a run of a workflow and starts the next one.
+func (s *Server) continueAsNew(e *execution, a *shared.ContinueAsNewWorkflowExecutionDecisionAttributes, attempt int32, completedEventID *int64) {
+	started := e.started
+	workflowType := a.WorkflowType
+	if workflowType == nil {
+		workflowType = started.WorkflowType
+	}
+	taskList := started.TaskList
+	if a.TaskList.GetName() != "" {
+		taskList = &shared.TaskList{Name: a.TaskList.Name}
+	}
+	executionTimeout := a.GetExecutionStartToCloseTimeoutSeconds()
+	if executionTimeout <= 0 {
+		executionTimeout = started.GetExecutionStartToCloseTimeoutSeconds()
+	}
+	decisionTimeout := a.GetTaskStartToCloseTimeoutSeconds()
+	if decisionTimeout <= 0 {
+		decisionTimeout = started.GetTaskStartToCloseTimeoutSeconds()
+	}
+	initiator := a.Initiator
+	if initiator == nil {
+		initiator = shared.ContinueAsNewInitiatorDecider.Ptr()
+	}
+
+	runID := uuid.New()
+	event := s.newEvent(shared.EventTypeWorkflowExecutionContinuedAsNew)
+	event.WorkflowExecutionContinuedAsNewEventAttributes = &shared.WorkflowExecutionContinuedAsNewEventAttributes{
+		NewExecutionRunId:                   common.StringPtr(runID),
+		WorkflowType:                        workflowType,
+		TaskList:                            taskList,
+		Input:                               a.Input,
+		ExecutionStartToCloseTimeoutSeconds: common.Int32Ptr(executionTimeout),
+		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(decisionTimeout),
+		DecisionTaskCompletedEventId:        completedEventID,
+		BackoffStartIntervalInSeconds:       a.BackoffStartIntervalInSeconds,
+		Initiator:                           initiator,
+		FailureReason:                       a.FailureReason,
+		FailureDetails:                      a.FailureDetails,
+		LastCompletionResult:                a.LastCompletionResult,
+		Header:                              a.Header,
+		Memo:                                a.Memo,
+		SearchAttributes:                    a.SearchAttributes,
+	}
+	s.closeExecution(e, shared.WorkflowExecutionCloseStatusContinuedAsNew, event)
+
+	attributes := &shared.WorkflowExecutionStartedEventAttributes{
+		WorkflowType:                        workflowType,
+		ParentWorkflowDomain:                started.ParentWorkflowDomain,
+		ParentWorkflowExecution:             started.ParentWorkflowExecution,
+		ParentInitiatedEventId:              started.ParentInitiatedEventId,
+		TaskList:                            taskList,
+		Input:                               a.Input,
+		ExecutionStartToCloseTimeoutSeconds: common.Int32Ptr(executionTimeout),
+		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(decisionTimeout),
+		ContinuedExecutionRunId:             common.StringPtr(e.runID),
+		Initiator:                           initiator,
+		ContinuedFailureReason:              a.FailureReason,
+		ContinuedFailureDetails:             a.FailureDetails,
+		LastCompletionResult:                a.LastCompletionResult,
+		OriginalExecutionRunId:              common.StringPtr(runID),
+		Identity:                            started.Identity,
+		FirstExecutionRunId:                 started.FirstExecutionRunId,
+		RetryPolicy:                         a.RetryPolicy,
+		Attempt:                             common.Int32Ptr(attempt),
+		CronSchedule:                        a.CronSchedule,
+		FirstDecisionTaskBackoffSeconds:     a.BackoffStartIntervalInSeconds,
+		Memo:                                a.Memo,
+		SearchAttributes:                    a.SearchAttributes,
+		Header:                              a.Header,
+	}
+	if attempt > 0 {
+		attributes.ExpirationTimestamp = started.ExpirationTimestamp
+	} else if expiration := a.RetryPolicy.GetExpirationIntervalInSeconds(); expiration > 0 {
+		attributes.ExpirationTimestamp = common.Int64Ptr(s.now().Add(seconds(expiration)).UnixNano())
+	}
+	next := s.startExecution(e.domain, e.workflowID, runID, "", attributes)
+	next.parent = e.parent
+	next.parentInitiatedID = e.parentInitiatedID
+	if e.parent != nil {
+		if child, ok := e.parent.children[e.parentInitiatedID]; ok {
+			child.execution = next
+		}
+	}
+	s.scheduleFirstDecision(next)
+}
+
+// retryBackoff returns the delay before retrying an attempt failing with reason, and false if the retry policy does
+// not allow another attempt.
+func retryBackoff(policy *shared.RetryPolicy, attempt int32, reason string, now, expiration time.Time) (time.Duration, bool) {
+	if policy == nil {
+		return 0, false
+	}
+	if maxAttempts := policy.GetMaximumAttempts(); maxAttempts > 0 && attempt+1 >= maxAttempts {
+		return 0, false
+	}
+	for _, nonRetriable := range policy.NonRetriableErrorReasons {
+		if nonRetriable == reason {
+			return 0, false
+		}
+	}
+	initialInterval := float64(policy.GetInitialIntervalInSeconds())
+	if initialInterval <= 0 {
+		initialInterval = 1
+	}
+	coefficient := policy.GetBackoffCoefficient()
+	if coefficient < 1 {
+		coefficient = 2
+	}
+	interval := initialInterval * math.Pow(coefficient, float64(attempt))
+	if maxInterval := float64(policy.GetMaximumIntervalInSeconds()); maxInterval > 0 && interval > maxInterval {
+		interval = maxInterval
+	}
+	backoff := time.Duration(interval * float64(time.Second))
+	if !expiration.IsZero() && now.Add(backoff).After(expiration) {
+		return 0, false
+	}
+	return backoff, true
+}
+
+// cronBackoff returns the delay from now until the next time of the cron schedule, rounded up to a second.
+func cronBackoff(schedule string, now time.Time) time.Duration {
+	s, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(math.Ceil(s.Next(now).Sub(now).Seconds())) * time.Second
+}
+
+func (s *Server) signal(e *execution, name string, input []byte, identity string) {
+	event := s.newEvent(shared.EventTypeWorkflowExecutionSignaled)
+	event.WorkflowExecutionSignaledEventAttributes = &shared.WorkflowExecutionSignaledEventAttributes{
+		SignalName: common.StringPtr(name),
+		Input:      input,
+		Identity:   common.StringPtr(identity),
+	}
+	s.recordEvent(e, event, true)
+}
+
+func (s *Server) requestCancel(e *execution, cause, identity string, externalInitiatedID *int64, externalExecution *shared.WorkflowExecution) {
+	e.cancelRequested = true
+	event := s.newEvent(shared.EventTypeWorkflowExecutionCancelRequested)
+	event.WorkflowExecutionCancelRequestedEventAttributes = &shared.WorkflowExecutionCancelRequestedEventAttributes{
+		Cause:                     common.StringPtr(cause),
+		ExternalInitiatedEventId:  externalInitiatedID,
+		ExternalWorkflowExecution: externalExecution,
+		Identity:                  common.StringPtr(identity),
+	}
+	s.recordEvent(e, event, true)
+}
+
+// StartWorkflowExecution starts a workflow.
+func (s *Server) StartWorkflowExecution(ctx context.Context, request *shared.StartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getDomain(request.GetDomain()); err != nil {
+		return nil, err
+	}
+	r := &startRequest{
+		domain:           request.GetDomain(),
+		workflowID:       request.GetWorkflowId(),
+		requestID:        request.GetRequestId(),
+		identity:         request.GetIdentity(),
+		workflowType:     request.WorkflowType,
+		taskList:         request.TaskList.GetName(),
+		input:            request.Input,
+		executionTimeout: request.GetExecutionStartToCloseTimeoutSeconds(),
+		decisionTimeout:  request.GetTaskStartToCloseTimeoutSeconds(),
+		reusePolicy:      request.WorkflowIdReusePolicy,
+		retryPolicy:      request.RetryPolicy,
+		cronSchedule:     request.GetCronSchedule(),
+		memo:             request.Memo,
+		searchAttributes: request.SearchAttributes,
+		header:           request.Header,
+		delayStart:       request.GetDelayStartSeconds(),
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	e, created, err := s.start(r)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		s.scheduleFirstDecision(e)
+	}
+	return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(e.runID)}, nil
+}
+
+// SignalWithStartWorkflowExecution signals a workflow, starting it first if it is not running.
+func (s *Server) SignalWithStartWorkflowExecution(ctx context.Context, request *shared.SignalWithStartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.StartWorkflowExecutionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getDomain(request.GetDomain()); err != nil {
+		return nil, err
+	}
+	if request.GetSignalName() == "" {
+		return nil, &shared.BadRequestError{Message: "SignalName is not set on request."}
+	}
+	current := s.currentRuns[workflowKey{domain: request.GetDomain(), workflowID: request.GetWorkflowId()}]
+	if current != nil && !current.closed() {
+		s.signal(current, request.GetSignalName(), request.SignalInput, request.GetIdentity())
+		return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(current.runID)}, nil
+	}
+
+	r := &startRequest{
+		domain:           request.GetDomain(),
+		workflowID:       request.GetWorkflowId(),
+		requestID:        request.GetRequestId(),
+		identity:         request.GetIdentity(),
+		workflowType:     request.WorkflowType,
+		taskList:         request.TaskList.GetName(),
+		input:            request.Input,
+		executionTimeout: request.GetExecutionStartToCloseTimeoutSeconds(),
+		decisionTimeout:  request.GetTaskStartToCloseTimeoutSeconds(),
+		reusePolicy:      request.WorkflowIdReusePolicy,
+		retryPolicy:      request.RetryPolicy,
+		cronSchedule:     request.GetCronSchedule(),
+		memo:             request.Memo,
+		searchAttributes: request.SearchAttributes,
+		header:           request.Header,
+		delayStart:       request.GetDelayStartSeconds(),
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	e, _, err := s.start(r)
+	if err != nil {
+		return nil, err
+	}
+	s.signal(e, request.GetSignalName(), request.SignalInput, request.GetIdentity())
+	s.scheduleFirstDecision(e)
+	return &shared.StartWorkflowExecutionResponse{RunId: common.StringPtr(e.runID)}, nil
+}
+
+// SignalWorkflowExecution signals a workflow.
+func (s *Server) SignalWorkflowExecution(ctx context.Context, request *shared.SignalWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.getOpenExecution(request.GetDomain(), request.WorkflowExecution)
+	if err != nil {
+		return err
+	}
+	if request.GetSignalName() == "" {
+		return &shared.BadRequestError{Message: "SignalName is not set on request."}
+	}
+	if requestID := request.GetRequestId(); requestID != "" {
+		if e.requestIDs[requestID] {
+			return nil
+		}
+		e.requestIDs[requestID] = true
+	}
+	s.signal(e, request.GetSignalName(), request.Input, request.GetIdentity())
+	return nil
+}
+
+// RequestCancelWorkflowExecution requests the cancellation of a workflow.
+func (s *Server) RequestCancelWorkflowExecution(ctx context.Context, request *shared.RequestCancelWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.getOpenExecution(request.GetDomain(), request.WorkflowExecution)
+	if err != nil {
+		return err
+	}
+	if requestID := request.GetRequestId(); requestID != "" {
+		if e.requestIDs[requestID] {
+			return nil
+		}
+		e.requestIDs[requestID] = true
+	}
+	if e.cancelRequested {
+		return &shared.CancellationAlreadyRequestedError{Message: "Cancellation already requested for this workflow execution."}
+	}
+	s.requestCancel(e, "", request.GetIdentity(), nil, nil)
+	return nil
+}
+
+// TerminateWorkflowExecution terminates a workflow.
+func (s *Server) TerminateWorkflowExecution(ctx context.Context, request *shared.TerminateWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.getOpenExecution(request.GetDomain(), request.WorkflowExecution)
+	if err != nil {
+		return err
+	}
+	s.terminate(e, request.GetReason(), request.Details, request.GetIdentity())
+	return nil
+}
+
+// GetWorkflowExecutionHistory returns the history of a workflow. The long polls waiting for new events are the ones
+// letting the clock of the server skip ahead, see the package documentation.
+func (s *Server) GetWorkflowExecutionHistory(ctx context.Context, request *shared.GetWorkflowExecutionHistoryRequest, opts ...yarpc.CallOption) (*shared.GetWorkflowExecutionHistoryResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.getExecution(request.GetDomain(), request.Execution)
+	if err != nil {
+		return nil, err
+	}
+	nextEventID := int64(1)
+	if len(request.NextPageToken) > 0 {
+		if nextEventID, err = strconv.ParseInt(string(request.NextPageToken), 10, 64); err != nil || nextEventID < 1 {
+			return nil, &shared.BadRequestError{Message: "Invalid NextPageToken."}
+		}
+	}
+	closeEventOnly := request.GetHistoryEventFilterType() == shared.HistoryEventFilterTypeCloseEvent
+	wait := request.GetWaitForNewEvent()
+
+	var expiredC <-chan time.Time
+	if wait {
+		var stop func() bool
+		expiredC, stop = longPollExpiration(ctx)
+		defer stop()
+		s.resultWaiters++
+		defer func() { s.resultWaiters-- }()
+		s.wake()
+	}
+	for {
+		if closeEventOnly && e.closed() {
+			return &shared.GetWorkflowExecutionHistoryResponse{
+				History: &shared.History{Events: []*shared.HistoryEvent{e.history[len(e.history)-1]}},
+			}, nil
+		}
+		if !closeEventOnly && int64(len(e.history)) >= nextEventID {
+			end := int64(len(e.history))
+			if pageSize := int64(request.GetMaximumPageSize()); pageSize > 0 && nextEventID-1+pageSize < end {
+				end = nextEventID - 1 + pageSize
+			}
+			response := &shared.GetWorkflowExecutionHistoryResponse{
+				History: &shared.History{Events: append([]*shared.HistoryEvent(nil), e.history[nextEventID-1:end]...)},
+			}
+			if end < int64(len(e.history)) || (wait && !e.closed()) {
+				response.NextPageToken = []byte(strconv.FormatInt(end+1, 10))
+			}
+			return response, nil
+		}
+		if !wait {
+			return &shared.GetWorkflowExecutionHistoryResponse{History: &shared.History{}}, nil
+		}
+
+		historyChangedC := e.historyChangedC
+		s.mu.Unlock()
+		select {
+		case <-historyChangedC:
+			s.mu.Lock()
+		case <-expiredC:
+			s.mu.Lock()
+			return &shared.GetWorkflowExecutionHistoryResponse{
+				History:       &shared.History{},
+				NextPageToken: []byte(strconv.FormatInt(nextEventID, 10)),
+			}, nil
+		case <-ctx.Done():
+			s.mu.Lock()
+			return nil, ctx.Err()
+		case <-s.stopC:
+			s.mu.Lock()
+			return &shared.GetWorkflowExecutionHistoryResponse{
+				History:       &shared.History{},
+				NextPageToken: []byte(strconv.FormatInt(nextEventID, 10)),
+			}, nil
+		}
+	}
+}
+
+// DescribeWorkflowExecution returns the configuration and the pending tasks of a workflow.
+func (s *Server) DescribeWorkflowExecution(ctx context.Context, request *shared.DescribeWorkflowExecutionRequest, opts ...yarpc.CallOption) (*shared.DescribeWorkflowExecutionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.getExecution(request.GetDomain(), request.Execution)
+	if err != nil {
+		return nil, err
+	}
+	response := &shared.DescribeWorkflowExecutionResponse{
+		ExecutionConfiguration: &shared.WorkflowExecutionConfiguration{
+			TaskList:                            e.started.TaskList,
+			ExecutionStartToCloseTimeoutSeconds: e.started.ExecutionStartToCloseTimeoutSeconds,
+			TaskStartToCloseTimeoutSeconds:      e.started.TaskStartToCloseTimeoutSeconds,
+		},
+		WorkflowExecutionInfo: s.executionInfo(e),
+	}
+
+	scheduledIDs := make([]int64, 0, len(e.activities))
+	for scheduledID := range e.activities {
+		scheduledIDs = append(scheduledIDs, scheduledID)
+	}
+	sort.Slice(scheduledIDs, func(i, j int) bool { return scheduledIDs[i] < scheduledIDs[j] })
+	for _, scheduledID := range scheduledIDs {
+		response.PendingActivities = append(response.PendingActivities, e.activities[scheduledID].describe())
+	}
+
+	initiatedIDs := make([]int64, 0, len(e.children))
+	for initiatedID := range e.children {
+		initiatedIDs = append(initiatedIDs, initiatedID)
+	}
+	sort.Slice(initiatedIDs, func(i, j int) bool { return initiatedIDs[i] < initiatedIDs[j] })
+	for _, initiatedID := range initiatedIDs {
+		child := e.children[initiatedID]
+		response.PendingChildren = append(response.PendingChildren, &shared.PendingChildExecutionInfo{
+			Domain:            common.StringPtr(child.domain),
+			WorkflowID:        common.StringPtr(child.execution.workflowID),
+			RunID:             common.StringPtr(child.execution.runID),
+			WorkflowTypName:   child.workflowType.Name,
+			InitiatedID:       common.Int64Ptr(initiatedID),
+			ParentClosePolicy: child.policy.Ptr(),
+		})
+	}
+
+	if d := e.decision; d != nil {
+		response.PendingDecision = &shared.PendingDecisionInfo{
+			State:                      shared.PendingDecisionStateScheduled.Ptr(),
+			ScheduledTimestamp:         common.Int64Ptr(d.scheduledTime.UnixNano()),
+			Attempt:                    common.Int64Ptr(d.attempt),
+			OriginalScheduledTimestamp: common.Int64Ptr(d.scheduledTime.UnixNano()),
+		}
+		if d.started {
+			response.PendingDecision.State = shared.PendingDecisionStateStarted.Ptr()
+			response.PendingDecision.StartedTimestamp = common.Int64Ptr(d.startedTime.UnixNano())
+		}
+	}
+	return response, nil
+}
+
+func (s *Server) executionInfo(e *execution) *shared.WorkflowExecutionInfo {
+	info := &shared.WorkflowExecutionInfo{
+		Execution:        e.workflowExecution(),
+		Type:             e.started.WorkflowType,
+		StartTime:        common.Int64Ptr(e.startTime.UnixNano()),
+		CloseStatus:      e.closeStatus,
+		HistoryLength:    common.Int64Ptr(int64(len(e.history))),
+		ExecutionTime:    common.Int64Ptr(e.executionTime.UnixNano()),
+		Memo:             e.memo,
+		SearchAttributes: e.searchAttributes,
+		TaskList:         common.StringPtr(e.taskList()),
+		IsCron:           common.BoolPtr(e.started.GetCronSchedule() != ""),
+	}
+	if e.closed() {
+		info.CloseTime = common.Int64Ptr(e.closeTime.UnixNano())
+	}
+	if e.parent != nil {
+		if d, ok := s.domains[e.parent.domain]; ok {
+			info.ParentDomainId = d.info.UUID
+		}
+		info.ParentExecution = e.parent.workflowExecution()
+	}
+	return info
+}
+
+// listExecutions returns the workflows of a domain matching filter, the most recently started first.
+func (s *Server) listExecutions(domain string, filter func(*execution) bool) ([]*shared.WorkflowExecutionInfo, error) {
+	if _, err := s.getDomain(domain); err != nil {
+		return nil, err
+	}
+	var executions []*execution
+	for key, e := range s.executions {
+		if key.domain == domain && filter(e) {
+			executions = append(executions, e)
+		}
+	}
+	sort.Slice(executions, func(i, j int) bool {
+		if !executions[i].startTime.Equal(executions[j].startTime) {
+			return executions[i].startTime.After(executions[j].startTime)
+		}
+		return executions[i].runID < executions[j].runID
+	})
+	infos := make([]*shared.WorkflowExecutionInfo, len(executions))
+	for i, e := range executions {
+		infos[i] = s.executionInfo(e)
+	}
+	return infos, nil
+}
+
+// paginate returns the page of infos starting at the offset of nextPageToken.
+func paginate(infos []*shared.WorkflowExecutionInfo, pageSize int32, nextPageToken []byte) ([]*shared.WorkflowExecutionInfo, []byte, error) {
+	offset := 0
+	if len(nextPageToken) > 0 {
+		var err error
+		if offset, err = strconv.Atoi(string(nextPageToken)); err != nil || offset < 0 {
+			return nil, nil, &shared.BadRequestError{Message: "Invalid NextPageToken."}
+		}
+	}
+	if offset > len(infos) {
+		offset = len(infos)
+	}
+	end := len(infos)
+	if pageSize > 0 && offset+int(pageSize) < end {
+		end = offset + int(pageSize)
+		return infos[offset:end], []byte(strconv.Itoa(end)), nil
+	}
+	return infos[offset:end], nil, nil
+}
+
+func matchesFilters(e *execution, startTimeFilter *shared.StartTimeFilter, executionFilter *shared.WorkflowExecutionFilter, typeFilter *shared.WorkflowTypeFilter) bool {
+	if startTimeFilter != nil {
+		startTime := e.startTime.UnixNano()
+		if startTimeFilter.EarliestTime != nil && startTime < startTimeFilter.GetEarliestTime() {
+			return false
+		}
+		if startTimeFilter.LatestTime != nil && startTime > startTimeFilter.GetLatestTime() {
+			return false
+		}
+	}
+	if executionFilter != nil {
+		if executionFilter.GetWorkflowId() != "" && executionFilter.GetWorkflowId() != e.workflowID {
+			return false
+		}
+		if executionFilter.GetRunId() != "" && executionFilter.GetRunId() != e.runID {
+			return false
+		}
+	}
+	if typeFilter != nil && typeFilter.GetName() != e.started.WorkflowType.GetName() {
+		return false
+	}
+	return true
+}
+
+// ListOpenWorkflowExecutions returns the open workflows of a domain matching the filters of the request.
+func (s *Server) ListOpenWorkflowExecutions(ctx context.Context, request *shared.ListOpenWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListOpenWorkflowExecutionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos, err := s.listExecutions(request.GetDomain(), func(e *execution) bool {
+		return !e.closed() && matchesFilters(e, request.StartTimeFilter, request.ExecutionFilter, request.TypeFilter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	page, nextPageToken, err := paginate(infos, request.GetMaximumPageSize(), request.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+	return &shared.ListOpenWorkflowExecutionsResponse{Executions: page, NextPageToken: nextPageToken}, nil
+}
+
+// ListClosedWorkflowExecutions returns the closed workflows of a domain matching the filters of the request.
+func (s *Server) ListClosedWorkflowExecutions(ctx context.Context, request *shared.ListClosedWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListClosedWorkflowExecutionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos, err := s.listExecutions(request.GetDomain(), func(e *execution) bool {
+		if !e.closed() || (request.StatusFilter != nil && *request.StatusFilter != *e.closeStatus) {
+			return false
+		}
+		return matchesFilters(e, request.StartTimeFilter, request.ExecutionFilter, request.TypeFilter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	page, nextPageToken, err := paginate(infos, request.GetMaximumPageSize(), request.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+	return &shared.ListClosedWorkflowExecutionsResponse{Executions: page, NextPageToken: nextPageToken}, nil
+}
+
+// ListWorkflowExecutions returns the workflows of a domain. Only the empty query is supported.
+func (s *Server) ListWorkflowExecutions(ctx context.Context, request *shared.ListWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListWorkflowExecutionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if request.GetQuery() != "" {
+		return nil, &shared.BadRequestError{Message: "The test server does not support visibility queries."}
+	}
+	infos, err := s.listExecutions(request.GetDomain(), func(*execution) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	page, nextPageToken, err := paginate(infos, request.GetPageSize(), request.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+	return &shared.ListWorkflowExecutionsResponse{Executions: page, NextPageToken: nextPageToken}, nil
+}
+
+// ScanWorkflowExecutions returns the workflows of a domain. Only the empty query is supported.
+func (s *Server) ScanWorkflowExecutions(ctx context.Context, request *shared.ListWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.ListWorkflowExecutionsResponse, error) {
+	return s.ListWorkflowExecutions(ctx, request, opts...)
+}
+
+// CountWorkflowExecutions counts the workflows of a domain. Only the empty query is supported.
+func (s *Server) CountWorkflowExecutions(ctx context.Context, request *shared.CountWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*shared.CountWorkflowExecutionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if request.GetQuery() != "" {
+		return nil, &shared.BadRequestError{Message: "The test server does not support visibility queries."}
+	}
+	infos, err := s.listExecutions(request.GetDomain(), func(*execution) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	return &shared.CountWorkflowExecutionsResponse{Count: common.Int64Ptr(int64(len(infos)))}, nil
+}
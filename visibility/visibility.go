@@ -0,0 +1,43 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package visibility contains helpers to build the queries of client.Client ListWorkflow, ScanWorkflow and
+// CountWorkflow for advanced visibility. For example:
+//
+//	query, err := visibility.NewQueryBuilder().
+//		Eq("WorkflowType", "orderWorkflow").
+//		In("CloseStatus", client.WorkflowStatusFailed, client.WorkflowStatusTimedOut).
+//		Between("StartTime", time.Now().Add(-24*time.Hour), time.Now()).
+//		OrderByDesc("StartTime").
+//		Build()
+package visibility
+
+import "go.uber.org/cadence/internal"
+
+type (
+	// QueryBuilder builds advanced visibility queries from typed predicates. Keys are validated and values are
+	// quoted and escaped, so the built query is syntactically valid whatever the values are.
+	QueryBuilder = internal.VisibilityQueryBuilder
+)
+
+// NewQueryBuilder creates a new, empty QueryBuilder
+func NewQueryBuilder() *QueryBuilder {
+	return internal.NewVisibilityQueryBuilder()
+}
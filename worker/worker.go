@@ -47,6 +47,18 @@ type (
 		Run() error
 		// Stop cleans up any resources opened by worker
 		Stop()
+		// Status returns a point in time health report of the worker, including the pollers running,
+		// the last successful poll and the task slots in use per task list, and the sticky cache size.
+		Status() Status
+		// Unhealthy returns a channel that receives the worker status every time the worker transitions
+		// from healthy to unhealthy. It can be used to wire the worker into readiness probes.
+		Unhealthy() <-chan Status
+		// GetRegisteredWorkflows returns the workflow types the worker can execute sorted by name, including
+		// the ones registered globally, with their aliases, function signatures and registration options.
+		GetRegisteredWorkflows() []RegisteredWorkflowInfo
+		// GetRegisteredActivities returns the activity types the worker can execute sorted by name, including
+		// the ones registered globally, with their aliases, function signatures and registration options.
+		GetRegisteredActivities() []RegisteredActivityInfo
 	}
 
 	// Registry exposes registration functions to consumers.
@@ -77,6 +89,21 @@ type (
 		// This method panics if workflowFunc doesn't comply with the expected format or tries to register the same workflow
 		// type name twice. Use workflow.RegisterOptions.DisableAlreadyRegisteredCheck to allow multiple registrations.
 		RegisterWorkflowWithOptions(w interface{}, options workflow.RegisterOptions)
+
+		// RegisterWorkflowStruct registers every exported method of a pointer to a structure as a workflow.
+		// The type name of each workflow defaults to the method name, the names and options can be set through
+		// "cadence" struct tags on fields of the structure:
+		//  type OrderWorkflows struct {
+		//     _ struct{} `cadence:"prefix=Order"`
+		//     _ struct{} `cadence:"method=Process,name=ProcessOrder,decisionTaskTimeout=30s"`
+		//     _ struct{} `cadence:"method=Helper,skip"`
+		//  }
+		//  worker.RegisterWorkflowStruct(&OrderWorkflows{ ... })
+		// The struct options are prefix, that is prepended to the method names. The method options are name,
+		// that overrides the type name, skip and decisionTaskTimeout (see RegisterOptions.DecisionTaskStartToCloseTimeout).
+		// This method panics if w is not a pointer to a structure, a tag is invalid, a method doesn't comply with
+		// the expected workflow format or a workflow type name is registered twice.
+		RegisterWorkflowStruct(w interface{})
 	}
 
 	// ActivityRegistry exposes activity registration functions to consumers.
@@ -129,6 +156,46 @@ type (
 		// which might be useful for integration tests.
 		// worker.RegisterActivityWithOptions(barActivity, RegisterActivityOptions{DisableAlreadyRegisteredCheck: true})
 		RegisterActivityWithOptions(a interface{}, options activity.RegisterOptions)
+
+		// RegisterActivityFactory registers the exported methods of the structure created by the factory as activities.
+		// Unlike RegisterActivity with a structure, the factory is invoked for every activity execution with the
+		// activity context, so request scoped dependencies like DB transactions or per tenant clients can be injected
+		// into the structure:
+		//  worker.RegisterActivityFactory(func(ctx context.Context) (*Activities, func(), error) {
+		//     tx, err := db.BeginTx(ctx, nil)
+		//     if err != nil {
+		//        return nil, nil, err
+		//     }
+		//     // rolls back the transaction unless the activity method committed it
+		//     return &Activities{tx: tx}, func() { tx.Rollback() }, nil
+		//  }, activity.RegisterOptions{})
+		// The factory signature is func(context.Context) *T, func(context.Context) (*T, error) or
+		// func(context.Context) (*T, func(), error), an error fails the activity execution. The func() result, if not
+		// nil, is called once the activity method returns, also when it fails or panics, to release what the factory
+		// acquired. The name in options is used as a prefix that is prepended to the
+		// activity method names like with RegisterActivityWithOptions. Workflows schedule the activities
+		// through a method of the structure, the receiver can be nil:
+		//  var a *Activities
+		//  workflow.ExecuteActivity(ctx, a.SampleActivity1, arg1, arg2)
+		// This method panics if the factory or an activity method doesn't comply with the expected format or
+		// an activity type name is registered twice.
+		RegisterActivityFactory(factory interface{}, options activity.RegisterOptions)
+
+		// RegisterActivityStruct registers every exported method of a pointer to a structure as an activity.
+		// The type name of each activity defaults to the method name, the names and options can be set through
+		// "cadence" struct tags on fields of the structure:
+		//  type OrderActivities struct {
+		//     _ struct{} `cadence:"prefix=Order,namespace=orders"`
+		//     _ struct{} `cadence:"method=Charge,name=ChargeCard,autoHeartbeat"`
+		//     _ struct{} `cadence:"method=Close,skip"`
+		//  }
+		//  worker.RegisterActivityStruct(&OrderActivities{ ... })
+		// The struct options are prefix, that is prepended to the method names, and namespace (see
+		// RegisterOptions.Namespace). The method options are name, that overrides the type name, skip and
+		// autoHeartbeat (see RegisterOptions.EnableAutoHeartbeat).
+		// This method panics if a is not a pointer to a structure, a tag is invalid, a method doesn't comply with
+		// the expected activity format or an activity type name is registered twice.
+		RegisterActivityStruct(a interface{})
 	}
 
 	// WorkflowReplayer supports replaying a workflow from its event history.
@@ -161,6 +228,13 @@ type (
 		// The logger is an optional parameter. Defaults to the noop logger.
 		ReplayPartialWorkflowHistoryFromJSONFile(logger *zap.Logger, jsonfileName string, lastEventID int64) error
 
+		// ReplayWorkflowHistoryFromBlob executes a single decision task for the given encoded history. The history
+		// can be the json downloaded from the cli, or a thrift or proto binary encoded history as exported by archival,
+		// the format is detected from the content. The json history files can hold these binary histories too.
+		// Use for testing the backwards compatibility of code changes and troubleshooting workflows in a debugger.
+		// The logger is an optional parameter. Defaults to the noop logger.
+		ReplayWorkflowHistoryFromBlob(logger *zap.Logger, blob []byte) error
+
 		// ReplayWorkflowExecution loads a workflow execution history from the Cadence service and executes a single decision task for it.
 		// Use for testing the backwards compatibility of code changes and troubleshooting workflows in a debugger.
 		// The logger is the only optional parameter. Defaults to the noop logger.
@@ -177,6 +251,29 @@ type (
 	// Options is used to configure a worker instance.
 	Options = internal.WorkerOptions
 
+	// Status is a point in time health report of a worker, see Worker.Status().
+	Status = internal.WorkerStatus
+
+	// PollerStatus is the status of a group of pollers of the same type polling a task list.
+	PollerStatus = internal.PollerStatus
+
+	// LifecycleHooks are callbacks invoked at lifecycle points of a worker, see Options.LifecycleHooks.
+	LifecycleHooks = internal.WorkerLifecycleHooks
+
+	// LifecycleInfo identifies the worker passed to the LifecycleHooks.
+	LifecycleInfo = internal.WorkerLifecycleInfo
+
+	// PollerLifecycleInfo identifies the group of pollers passed to the LifecycleHooks.
+	PollerLifecycleInfo = internal.PollerLifecycleInfo
+
+	// RegisteredWorkflowInfo is the metadata of a workflow type registered with a worker, see
+	// Worker.GetRegisteredWorkflows.
+	RegisteredWorkflowInfo = internal.RegisteredWorkflowInfo
+
+	// RegisteredActivityInfo is the metadata of an activity type registered with a worker, see
+	// Worker.GetRegisteredActivities.
+	RegisteredActivityInfo = internal.RegisteredActivityInfo
+
 	// ShadowOptions is used to configure a WorkflowShadower.
 	ShadowOptions = internal.ShadowOptions
 	// ShadowMode is an enum for configuring if shadowing should continue after all workflows matches the WorkflowQuery have been replayed.
@@ -186,14 +283,94 @@ type (
 	// ShadowExitCondition configures when the workflow shadower should exit.
 	// If not specified shadower will exit after replaying all workflows satisfying the visibility query.
 	ShadowExitCondition = internal.ShadowExitCondition
+	// ShadowReportFormat is an enum for the format of the report written to ShadowOptions.ReportPath.
+	ShadowReportFormat = internal.ShadowReportFormat
+	// ShadowReport is the result of a WorkflowShadower run, written to ShadowOptions.ReportPath.
+	ShadowReport = internal.ShadowReport
+	// ShadowExecutionResult is the result of the replay of a workflow execution in a ShadowReport.
+	ShadowExecutionResult = internal.ShadowExecutionResult
 
 	// ReplayOptions is used to configure the replay decision task worker.
 	ReplayOptions = internal.ReplayOptions
 
+	// ReplayCoverage records the workflow code paths exercised by replayed histories, see ReplayOptions.Coverage.
+	ReplayCoverage = internal.ReplayCoverage
+
+	// ReplayHistoryCoverage is the coverage of a single replayed history.
+	ReplayHistoryCoverage = internal.ReplayHistoryCoverage
+
+	// ReplayCodeLocation is a location in the workflow code that was reached during replay.
+	ReplayCodeLocation = internal.ReplayCodeLocation
+
 	// NonDeterministicWorkflowPolicy is an enum for configuring how client's decision task handler deals with
 	// mismatched history events (presumably arising from non-deterministic workflow definitions).
 	NonDeterministicWorkflowPolicy = internal.NonDeterministicWorkflowPolicy
 
+	// WorkflowPanicAction is an enum returned by Options.WorkflowPanicClassifier deciding what a panic in
+	// workflow code does to the workflow.
+	WorkflowPanicAction = internal.WorkflowPanicAction
+
+	// DecisionTaskFailurePolicy is an enum for configuring what the worker does with a workflow whose decision task
+	// failed Options.DecisionTaskFailureThreshold times in a row.
+	DecisionTaskFailurePolicy = internal.DecisionTaskFailurePolicy
+
+	// StickyCacheEvictionPolicy is an enum for configuring which workflow execution is evicted from the sticky
+	// workflow cache, see Options.StickyCacheEvictionPolicy.
+	StickyCacheEvictionPolicy = internal.StickyCacheEvictionPolicy
+
+	// EventListener is notified by a worker about the workflow executions and activities it processes, see
+	// Options.EventListeners.
+	EventListener = internal.WorkerEventListener
+
+	// EventListenerBase implements EventListener with methods doing nothing, embed it to only implement some.
+	EventListenerBase = internal.WorkerEventListenerBase
+
+	// WorkflowEvent is the metadata of a workflow execution passed to an EventListener.
+	WorkflowEvent = internal.WorkflowEvent
+
+	// ActivityEvent is the metadata of an activity passed to an EventListener.
+	ActivityEvent = internal.ActivityEvent
+
+	// WorkflowTaskFilter decides whether a worker processes the workflow executions it polls, see
+	// Options.WorkflowTaskFilter. Returning an error rejects the workflow execution: its decision task is failed
+	// with the error and rescheduled by the server, so that it can be picked up by another worker polling the same
+	// task list.
+	WorkflowTaskFilter = internal.WorkflowTaskFilter
+
+	// WorkflowTaskFilterInfo is the metadata of a workflow execution passed to a WorkflowTaskFilter.
+	WorkflowTaskFilterInfo = internal.WorkflowTaskFilterInfo
+
+	// DecisionTaskInspector is called with the history and the decisions of every decision task completed by a
+	// worker, right before the decisions are sent to the server, see Options.DecisionTaskInspector. The history
+	// events and decisions must not be modified, and the inspector must not block.
+	DecisionTaskInspector = internal.DecisionTaskInspector
+
+	// DecisionTaskInfo is a decision task completed by a worker, passed to a DecisionTaskInspector.
+	DecisionTaskInfo = internal.DecisionTaskInfo
+
+	// SystemResourceProvider reports the resource usage of the host a worker is running on.
+	SystemResourceProvider = internal.SystemResourceProvider
+
+	// ResourceControllerOptions configures an activity worker to stop picking up new activity tasks while the
+	// resource usage of the host is above the thresholds, see Options.ActivityResourceController.
+	ResourceControllerOptions = internal.ResourceControllerOptions
+
+	// WorkflowConfigProvider provides the config snapshots read through workflow.GetConfigValue, see
+	// Options.WorkflowConfigProvider.
+	WorkflowConfigProvider = internal.WorkflowConfigProvider
+
+	// WorkflowSnapshot is the state of a workflow saved to Options.WorkflowSnapshotStore.
+	// EXPERIMENTAL: the snapshot API may change in future releases.
+	WorkflowSnapshot = internal.WorkflowSnapshot
+
+	// WorkflowSnapshotStore stores the snapshots of the workflows that call workflow.SetSnapshotter, see
+	// Options.WorkflowSnapshotStore.
+	// EXPERIMENTAL: the snapshot API may change in future releases.
+	WorkflowSnapshotStore = internal.WorkflowSnapshotStore
+
+	// RunGroupOptions configures RunGroup.
+	RunGroupOptions = internal.RunGroupOptions
+
 	// AuthorizationProvider is the interface that contains the method to get the auth token
 	AuthorizationProvider = auth.AuthorizationProvider
 )
@@ -213,6 +390,37 @@ const (
 	NonDeterministicWorkflowPolicyFailWorkflow = internal.NonDeterministicWorkflowPolicyFailWorkflow
 )
 
+const (
+	// WorkflowPanicActionBlock fails the decision task. The decision task is retried, so the workflow is blocked
+	// until the panic is fixed by a new deployment of the workflow code.
+	WorkflowPanicActionBlock = internal.WorkflowPanicActionBlock
+	// WorkflowPanicActionFail fails the workflow execution with a PanicError holding the panic value and stack trace.
+	WorkflowPanicActionFail = internal.WorkflowPanicActionFail
+)
+
+const (
+	// DecisionTaskFailurePolicyRetry is the default policy. The workflow is left alone and the server keeps
+	// retrying its decision task.
+	DecisionTaskFailurePolicyRetry = internal.DecisionTaskFailurePolicyRetry
+	// DecisionTaskFailurePolicyTerminate terminates the workflow.
+	DecisionTaskFailurePolicyTerminate = internal.DecisionTaskFailurePolicyTerminate
+	// DecisionTaskFailurePolicyReset resets the workflow to the last completed decision task, dropping the events
+	// after it. The workflow is left alone if no decision task of the run has completed yet.
+	DecisionTaskFailurePolicyReset = internal.DecisionTaskFailurePolicyReset
+)
+
+const (
+	// StickyCacheEvictionPolicyLRU is the default policy. It evicts the least recently used execution once the cache
+	// is full.
+	StickyCacheEvictionPolicyLRU = internal.StickyCacheEvictionPolicyLRU
+	// StickyCacheEvictionPolicyLFU evicts the execution with the fewest decision tasks served from the cache once the
+	// cache is full, so executions that are replayed often stay cached.
+	StickyCacheEvictionPolicyLFU = internal.StickyCacheEvictionPolicyLFU
+	// StickyCacheEvictionPolicyTTL evicts executions that were not used for Options.StickyCacheTTL with a
+	// background sweeper, in addition to evicting the least recently used execution once the cache is full.
+	StickyCacheEvictionPolicyTTL = internal.StickyCacheEvictionPolicyTTL
+)
+
 const (
 	// ShadowModeNormal is the default mode for workflow shadowing.
 	// Shadowing will complete after all workflows matches WorkflowQuery have been replayed.
@@ -226,6 +434,14 @@ const (
 	ShadowModeContinuous = internal.ShadowModeContinuous
 )
 
+const (
+	// ShadowReportFormatJUnit writes the shadowing report as a JUnit XML test suite, with a test case per replayed
+	// workflow execution.
+	ShadowReportFormatJUnit = internal.ShadowReportFormatJUnit
+	// ShadowReportFormatJSON writes the shadowing report as a JSON encoded ShadowReport.
+	ShadowReportFormatJSON = internal.ShadowReportFormatJSON
+)
+
 // New creates an instance of worker for managing workflow and activity executions.
 //    service  - thrift connection to the cadence server
 //    domain   - the name of the cadence domain
@@ -242,11 +458,70 @@ func New(
 	return internal.NewWorker(service, domain, taskList, options)
 }
 
+// NewActivityWorker creates an instance of worker that only hosts activity implementations.
+// No decision task poller is created, and Start fails if no activity is registered.
+// It returns an error if options disables the activity worker or enables the shadow worker.
+//    service  - thrift connection to the cadence server
+//    domain   - the name of the cadence domain
+//    taskList - is the task list name the worker polls activity tasks from
+//    options  - configure any worker specific options like logger, metrics, identity
+func NewActivityWorker(
+	service workflowserviceclient.Interface,
+	domain string,
+	taskList string,
+	options Options,
+) (Worker, error) {
+	w, err := internal.NewActivityWorker(service, domain, taskList, options)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewWorkflowWorker creates an instance of worker that only hosts workflow implementations.
+// No activity task poller is created, and Start fails if no workflow is registered.
+// It returns an error if options disables the workflow worker, or enables the session or shadow worker.
+//    service  - thrift connection to the cadence server
+//    domain   - the name of the cadence domain
+//    taskList - is the task list name the worker polls decision tasks from
+//    options  - configure any worker specific options like logger, metrics, identity
+func NewWorkflowWorker(
+	service workflowserviceclient.Interface,
+	domain string,
+	taskList string,
+	options Options,
+) (Worker, error) {
+	w, err := internal.NewWorkflowWorker(service, domain, taskList, options)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// RunGroup starts the workers in the given order and blocks until SIGINT or SIGTERM is received (see
+// RunGroupOptions.Signals) or RunGroupOptions.StopChannel is closed. The workers are then stopped one by one
+// in the reverse order, and an error is returned if they are not all stopped within RunGroupOptions.ShutdownTimeout.
+// When a worker fails to start, the workers already started are stopped and the start error is returned.
+//
+//	err := worker.RunGroup(worker.RunGroupOptions{ShutdownTimeout: time.Minute}, workflowWorker, activityWorker)
+func RunGroup(options RunGroupOptions, workers ...Worker) error {
+	groupWorkers := make([]internal.GroupWorker, len(workers))
+	for i, w := range workers {
+		groupWorkers[i] = w
+	}
+	return internal.RunGroup(options, groupWorkers...)
+}
+
 // NewWorkflowReplayer creates a WorkflowReplayer instance.
 func NewWorkflowReplayer() WorkflowReplayer {
 	return internal.NewWorkflowReplayer()
 }
 
+// NewReplayCoverage creates a ReplayCoverage to set on ReplayOptions.Coverage.
+func NewReplayCoverage() *ReplayCoverage {
+	return internal.NewReplayCoverage()
+}
+
 // NewWorkflowReplayerWithOptions creates an instance of the WorkflowReplayer
 // with provided replay worker options
 func NewWorkflowReplayerWithOptions(
@@ -27,12 +27,14 @@ import (
 )
 
 // ActivityOptions stores all activity-specific invocation parameters that will be stored inside of a context.
+// It can be read from config files as JSON with durations like "30s", see ParseActivityOptionsJSON.
 type ActivityOptions = internal.ActivityOptions
 
 // LocalActivityOptions doc
 type LocalActivityOptions = internal.LocalActivityOptions
 
 // RetryPolicy specify how to retry activity if error happens.
+// It can be read from config files as JSON with durations like "30s", see ParseRetryPolicyJSON.
 type RetryPolicy = internal.RetryPolicy
 
 // WithActivityOptions makes a copy of the context and adds the
@@ -58,6 +60,19 @@ func WithTaskList(ctx Context, name string) Context {
 	return internal.WithTaskList(ctx, name)
 }
 
+// ActivityTaskListResolver returns the task list an activity is scheduled on from its type name and arguments,
+// e.g. to route activities to a task list per customer region. It must be deterministic.
+type ActivityTaskListResolver = internal.ActivityTaskListResolver
+
+// WithActivityTaskListResolver makes a copy of the current context and update
+// the task list resolver field in its activity options. An empty activity
+// options will be created if it does not exist in the original context.
+// The task list returned by the resolver takes precedence over the task list
+// of the activity options, unless it is empty.
+func WithActivityTaskListResolver(ctx Context, resolver ActivityTaskListResolver) Context {
+	return internal.WithActivityTaskListResolver(ctx, resolver)
+}
+
 // WithScheduleToCloseTimeout makes a copy of the current context and update
 // the ScheduleToCloseTimeout field in its activity options. An empty activity
 // options will be created if it does not exist in the original context.
@@ -111,3 +126,45 @@ func WithWaitForCancellation(ctx Context, wait bool) Context {
 func WithRetryPolicy(ctx Context, retryPolicy RetryPolicy) Context {
 	return internal.WithRetryPolicy(ctx, retryPolicy)
 }
+
+// WithHostPinnedActivityOptions makes a copy of the current context and update
+// the TaskList field in its activity options to the task list of the worker
+// with the host ID, so the activities are only executed by that worker. The
+// worker must set worker.Options.EnableHostPinnedActivities, and hostID is the
+// activity.Info.HostID of an activity it executed. An empty activity options
+// will be created if it does not exist in the original context.
+func WithHostPinnedActivityOptions(ctx Context, hostID string) Context {
+	return internal.WithHostPinnedActivityOptions(ctx, hostID)
+}
+
+// WithNonRetriableErrors makes a copy of the current context and update
+// the NonRetriableErrors field in its activity options. An empty activity
+// options will be created if it does not exist in the original context.
+func WithNonRetriableErrors(ctx Context, errs ...error) Context {
+	return internal.WithNonRetriableErrors(ctx, errs...)
+}
+
+// ParseActivityOptionsJSON decodes options read as JSON, e.g. from a config file. The keys are the lowerCamelCase
+// names of the fields and durations are strings like "1m30s". The NonRetriableErrors are listed by the reason of a
+// *cadence.CustomError, or else by the name of the type of the error qualified by its package path, like
+// "*github.com/org/repo/pkg.NotFoundError" for a pointer type, and are resolved to the one of the knownErrors with
+// that name. Unknown keys and invalid values are rejected.
+func ParseActivityOptionsJSON(data []byte, knownErrors ...error) (ActivityOptions, error) {
+	return internal.ParseActivityOptionsJSON(data, knownErrors...)
+}
+
+// MarshalActivityOptionsJSON encodes options in the JSON format read by ParseActivityOptionsJSON.
+func MarshalActivityOptionsJSON(options ActivityOptions) ([]byte, error) {
+	return internal.MarshalActivityOptionsJSON(options)
+}
+
+// ParseRetryPolicyJSON decodes a retry policy read as JSON, in the format of the retryPolicy of
+// ParseActivityOptionsJSON. Policies which would be rejected when scheduling an activity are rejected.
+func ParseRetryPolicyJSON(data []byte) (*RetryPolicy, error) {
+	return internal.ParseRetryPolicyJSON(data)
+}
+
+// MarshalRetryPolicyJSON encodes a retry policy in the JSON format read by ParseRetryPolicyJSON.
+func MarshalRetryPolicyJSON(policy RetryPolicy) ([]byte, error) {
+	return internal.MarshalRetryPolicyJSON(policy)
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package workflow
+
+import (
+	"go.uber.org/cadence/internal"
+)
+
+type (
+	// BatchExecutorOptions configure a BatchExecutor: the number of items per batch, and how long, in workflow time,
+	// a batch which is not full waits for more items before it is dispatched.
+	BatchExecutorOptions = internal.BatchExecutorOptions
+
+	// BatchExecutor accumulates small activity invocations and dispatches them as a single activity call per batch,
+	// which reduces the history events and the scheduling overhead of fan-outs of thousands of tiny tasks.
+	// For example:
+	//
+	//	batcher, err := workflow.NewBatchExecutor(ctx, ResizeImages, workflow.BatchExecutorOptions{
+	//		BatchSize:     50,
+	//		FlushInterval: time.Minute,
+	//	})
+	//	if err != nil {
+	//		return err
+	//	}
+	//	var futures []workflow.Future
+	//	for _, image := range images {
+	//		futures = append(futures, batcher.Execute(image))
+	//	}
+	//	batcher.Flush()
+	//	for _, f := range futures {
+	//		var thumbnail string
+	//		if err := f.Get(ctx, &thumbnail); err != nil {
+	//			return err
+	//		}
+	//	}
+	BatchExecutor = internal.BatchExecutor
+)
+
+// NewBatchExecutor creates a BatchExecutor dispatching its batches to activity, a function with the signature
+// func([ctx context.Context,] items []T) ([]R, error), which returns one result per item, or
+// func([ctx context.Context,] items []T) error. The activity is executed with the activity options of ctx.
+func NewBatchExecutor(ctx Context, activity interface{}, options BatchExecutorOptions) (*BatchExecutor, error) {
+	return internal.NewBatchExecutor(ctx, activity, options)
+}
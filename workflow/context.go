@@ -21,6 +21,8 @@
 package workflow
 
 import (
+	"time"
+
 	"go.uber.org/cadence/internal"
 )
 
@@ -75,3 +77,17 @@ func WithValue(parent Context, key interface{}, val interface{}) Context {
 func NewDisconnectedContext(parent Context) (ctx Context, cancel CancelFunc) {
 	return internal.NewDisconnectedContext(parent)
 }
+
+// RunWithCleanup runs mainFn with ctx and then cleanupFn with a context that is disconnected from the cancellation of
+// ctx, which standardizes the cancel-cleanup pattern of NewDisconnectedContext. When cleanupTimeout is positive the
+// cleanup context is canceled after cleanupTimeout.
+// RunWithCleanup returns the error of mainFn, or the error of cleanupFn when mainFn succeeded.
+//
+//	err := workflow.RunWithCleanup(ctx, func(ctx workflow.Context) error {
+//		return workflow.ExecuteActivity(ctx, ReserveActivity).Get(ctx, nil)
+//	}, func(ctx workflow.Context) error {
+//		return workflow.ExecuteActivity(ctx, ReleaseActivity).Get(ctx, nil)
+//	}, time.Minute)
+func RunWithCleanup(ctx Context, mainFn func(ctx Context) error, cleanupFn func(ctx Context) error, cleanupTimeout time.Duration) error {
+	return internal.RunWithCleanup(ctx, mainFn, cleanupFn, cleanupTimeout)
+}
@@ -33,3 +33,17 @@ type (
 	// context to pass along
 	ContextPropagator = internal.ContextPropagator
 )
+
+// GetHeader returns the value of the given key in the header the current workflow was started with, and whether the
+// key was present. The header carries the fields set by the client's context propagators or by the WithHeader calls
+// of the parent workflow.
+func GetHeader(ctx Context, key string) ([]byte, bool) {
+	return internal.GetHeader(ctx, key)
+}
+
+// WithHeader returns a copy of ctx with the given header field set on the activities and child workflows started
+// with it. It is typically called by workflow interceptors before forwarding the call to the next interceptor, e.g.
+// to attach an auth token or a tenant ID to every child workflow without writing a ContextPropagator.
+func WithHeader(ctx Context, key string, value []byte) Context {
+	return internal.WithHeader(ctx, key, value)
+}
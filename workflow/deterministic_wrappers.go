@@ -47,6 +47,14 @@ type (
 	// WaitGroup is used to wait for a collection of
 	// coroutines to finish
 	WaitGroup = internal.WaitGroup
+
+	// Mutex is used to coordinate access to shared workflow
+	// state between coroutines
+	Mutex = internal.Mutex
+
+	// Semaphore is used to limit the number of coroutines
+	// accessing a shared resource
+	Semaphore = internal.Semaphore
 )
 
 // Await blocks the calling thread until condition() returns true.
@@ -100,6 +108,16 @@ func NewWaitGroup(ctx Context) WaitGroup {
 	return internal.NewWaitGroup(ctx)
 }
 
+// NewMutex creates a new Mutex instance.
+func NewMutex(ctx Context) Mutex {
+	return internal.NewMutex(ctx)
+}
+
+// NewSemaphore creates a new Semaphore instance with n permits.
+func NewSemaphore(ctx Context, n int64) Semaphore {
+	return internal.NewSemaphore(ctx, n)
+}
+
 // Go creates a new coroutine. It has similar semantic to goroutine in a context of the workflow.
 func Go(ctx Context, f func(ctx Context)) {
 	internal.Go(ctx, f)
@@ -117,6 +135,44 @@ func NewFuture(ctx Context) (Future, Settable) {
 	return internal.NewFuture(ctx)
 }
 
+// AwaitAll blocks until all of the futures are ready. It returns nil if every future succeeded,
+// otherwise an *AggregateError holding the error of each future in the order they were passed.
+// Returns CanceledError if the ctx is canceled before all the futures are ready.
+func AwaitAll(ctx Context, futures []Future) error {
+	return internal.AwaitAll(ctx, futures)
+}
+
+// AwaitAny blocks until at least one of the futures is ready. It returns the index of the ready
+// future and the error it completed with. When several futures are ready the lowest index is returned.
+// Returns -1 and CanceledError if the ctx is canceled before any of the futures is ready.
+func AwaitAny(ctx Context, futures []Future) (int, error) {
+	return internal.AwaitAny(ctx, futures)
+}
+
+// SortedMapKeys returns the keys of the map m as a slice sorted in ascending order, so a map[string]int returns a
+// []string. Ranging over a map is a common source of non-determinism, as the iteration order is random and differs
+// on replay, so workflow code should iterate over the sorted keys instead:
+//
+//	for _, key := range workflow.SortedMapKeys(m).([]string) {
+//		...
+//	}
+//
+// The keys must be of a boolean, integer, floating point or string kind, otherwise it panics. It also panics for
+// NaN keys, which have no deterministic order.
+func SortedMapKeys(m interface{}) interface{} {
+	return internal.SortedMapKeys(m)
+}
+
+// SortedMapRange calls f for each key and value of the map m in the ascending order of the keys, see SortedMapKeys.
+// f is a func(key K, value V) for a map[K]V, or a func(key K, value V) bool that stops the iteration by returning false.
+//
+//	workflow.SortedMapRange(m, func(key string, value int) {
+//		...
+//	})
+func SortedMapRange(m interface{}, f interface{}) {
+	internal.SortedMapRange(m, f)
+}
+
 // Now returns the current time when the decision is started or replayed.
 // The workflow needs to use this Now() to get the wall clock time instead of the Go lang library one.
 func Now(ctx Context) time.Time {
@@ -133,6 +189,13 @@ func NewTimer(ctx Context, d time.Duration) Future {
 	return internal.NewTimer(ctx, d)
 }
 
+// NewTimerWithOptions works like NewTimer, with the options of the timer. The summary of the options is recorded in
+// the workflow history next to the started timer. The returned Future.Get() returns nil when the timer fires, or a
+// *CanceledError with the summary as details when the timer is canceled.
+func NewTimerWithOptions(ctx Context, d time.Duration, options TimerOptions) Future {
+	return internal.NewTimerWithOptions(ctx, d, options)
+}
+
 // Sleep pauses the current workflow for at least the duration d. A negative or zero duration causes Sleep to return
 // immediately. Workflow code needs to use this Sleep() to sleep instead of the Go lang library one(timer.Sleep()).
 // You can cancel the pending sleep by cancel the Context (using context from workflow.WithCancel(ctx)).
@@ -144,3 +207,11 @@ func NewTimer(ctx Context, d time.Duration) Future {
 func Sleep(ctx Context, d time.Duration) (err error) {
 	return internal.Sleep(ctx, d)
 }
+
+// SleepUntil pauses the current workflow until at least the time t. Workflow code needs to use this SleepUntil()
+// instead of computing the duration from time.Now(), as the duration is computed from workflow.Now() which is the
+// same when the workflow is replayed. A time t which is not after workflow.Now() causes SleepUntil to return
+// immediately. SleepUntil() returns nil when t is reached, or *CanceledError if the ctx is canceled.
+func SleepUntil(ctx Context, t time.Time) (err error) {
+	return internal.SleepUntil(ctx, t)
+}
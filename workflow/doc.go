@@ -400,12 +400,12 @@ So the error handling code would look like:
 		}
 	case *workflow.TimeoutError:
 		switch err.TimeoutType() {
-		case shared.TimeoutTypeScheduleToStart:
+		case workflow.TimeoutTypeScheduleToStart:
 			// handle ScheduleToStart timeout
-		case shared.TimeoutTypeStartToClose:
+		case workflow.TimeoutTypeStartToClose:
 			// handle StartToClose timeout
-		case shared.TimeoutTypeHeartbeat:
-			// handle heartbeat timeout
+		case workflow.TimeoutTypeHeartbeat:
+			// handle heartbeat timeout, the progress of the activity can be extracted by err.LastHeartbeatDetails()
 		default:
 		}
 	case *workflow.PanicError:
@@ -43,7 +43,9 @@ Below are the possible errors that activity or child workflow could return:
     NewCancelError() and could supply optional details which could be extracted by workflow code.
 4) *workflow.TimeoutError:
 	If activity or child workflow was timed out (several timeout types), workflow code will receive instance of
-    *TimeoutError. The err contains details about what type of timeout it was.
+    *TimeoutError. The err contains details about what type of timeout it was, err.TimeoutType() is one of the
+    TimeoutType constants. When an activity timed out, err.LastHeartbeatDetails() extracts the details of the last
+    heartbeat it recorded.
 5) *workflow.PanicError:
 	If activity code panics while executing, cadence activity worker will report it as activity failure to cadence server.
 	The cadence client library will present that failure as *PanicError to workflow code. The err contains a string
@@ -91,18 +93,41 @@ type (
 	// TimeoutError returned when activity or child workflow timed out.
 	TimeoutError = internal.TimeoutError
 
+	// TimeoutType is the type of the timeout a TimeoutError was raised for.
+	TimeoutType = internal.TimeoutType
+
 	// TerminatedError returned when workflow was terminated.
 	TerminatedError = internal.TerminatedError
 
 	// PanicError contains information about panicked workflow/activity.
 	PanicError = internal.PanicError
 
+	// PanicDetails are the details of a PanicError: the panic value, the stack trace of the panicking goroutine and
+	// the file:line the panic was raised at.
+	PanicDetails = internal.PanicDetails
+
 	// ContinueAsNewError can be returned by a workflow implementation function and indicates that
 	// the workflow should continue as new with the same WorkflowID, but new RunID and new history.
 	ContinueAsNewError = internal.ContinueAsNewError
 
-	// UnknownExternalWorkflowExecutionError can be returned when external workflow doesn't exist
+	// UnknownExternalWorkflowExecutionError can be returned when external workflow doesn't exist, or has already
+	// completed, when it is signaled or its cancellation is requested.
 	UnknownExternalWorkflowExecutionError = internal.UnknownExternalWorkflowExecutionError
+
+	// AggregateError is returned by AwaitAll when one or more of the awaited futures failed.
+	AggregateError = internal.AggregateError
+)
+
+// The types of timeout returned by TimeoutError.TimeoutType.
+const (
+	// TimeoutTypeStartToClose is the timeout of an activity or child workflow execution that did not complete in time.
+	TimeoutTypeStartToClose = shared.TimeoutTypeStartToClose
+	// TimeoutTypeScheduleToStart is the timeout of an activity no worker picked up in time.
+	TimeoutTypeScheduleToStart = shared.TimeoutTypeScheduleToStart
+	// TimeoutTypeScheduleToClose is the timeout of an activity that did not complete in time, retries included.
+	TimeoutTypeScheduleToClose = shared.TimeoutTypeScheduleToClose
+	// TimeoutTypeHeartbeat is the timeout of an activity that did not heartbeat in time.
+	TimeoutTypeHeartbeat = shared.TimeoutTypeHeartbeat
 )
 
 // NewContinueAsNewError creates ContinueAsNewError instance
@@ -53,6 +53,19 @@ type (
 
 	// Info information about currently executing workflow
 	Info = internal.WorkflowInfo
+
+	// QueryHandlerOptions configure a query handler. See SetQueryHandlerWithOptions.
+	QueryHandlerOptions = internal.QueryHandlerOptions
+
+	// SignalChannelOptions configure the buffering of a signal channel. See GetSignalChannelWithOptions.
+	SignalChannelOptions = internal.SignalChannelOptions
+
+	// TimerOptions configure a timer. See NewTimerWithOptions.
+	TimerOptions = internal.TimerOptions
+
+	// Snapshotter saves and restores the state of a workflow, see SetSnapshotter.
+	// EXPERIMENTAL: the snapshot API may change in future releases.
+	Snapshotter = internal.WorkflowSnapshotter
 )
 
 // Register - registers a workflow function with the framework.
@@ -187,7 +200,10 @@ func GetLogger(ctx Context) *zap.Logger {
 	return internal.GetLogger(ctx)
 }
 
-// GetMetricsScope returns a metrics scope to be used in workflow's context
+// GetMetricsScope returns a metrics scope to be used in workflow's context.
+// The scope does not emit metrics while the workflow code is replaying history. Metrics are emitted as soon as they
+// are recorded, unless worker.Options.EnableBufferedWorkflowMetrics is set, in which case they are emitted once the
+// decision task completes so a failed and retried decision task does not emit them twice.
 func GetMetricsScope(ctx Context) tally.Scope {
 	return internal.GetMetricsScope(ctx)
 }
@@ -204,6 +220,12 @@ func RequestCancelExternalWorkflow(ctx Context, workflowID, runID string) Future
 	return internal.RequestCancelExternalWorkflow(ctx, workflowID, runID)
 }
 
+// RequestCancelExternalWorkflowInDomain requests cancellation of an external workflow running in the given domain.
+// It is equivalent to calling RequestCancelExternalWorkflow with a context created by WithWorkflowDomain.
+func RequestCancelExternalWorkflowInDomain(ctx Context, domain, workflowID, runID string) Future {
+	return internal.RequestCancelExternalWorkflowInDomain(ctx, domain, workflowID, runID)
+}
+
 // SignalExternalWorkflow can be used to send signal info to an external workflow.
 // Input workflowID is the workflow ID of target workflow.
 // Input runID indicates the instance of a workflow. Input runID is optional (default is ""). When runID is not specified,
@@ -216,11 +238,35 @@ func SignalExternalWorkflow(ctx Context, workflowID, runID, signalName string, a
 	return internal.SignalExternalWorkflow(ctx, workflowID, runID, signalName, arg)
 }
 
+// SignalExternalWorkflowInDomain sends a signal to an external workflow running in the given domain.
+// It is equivalent to calling SignalExternalWorkflow with a context created by WithWorkflowDomain.
+func SignalExternalWorkflowInDomain(ctx Context, domain, workflowID, runID, signalName string, arg interface{}) Future {
+	return internal.SignalExternalWorkflowInDomain(ctx, domain, workflowID, runID, signalName, arg)
+}
+
 // GetSignalChannel returns channel corresponding to the signal name.
 func GetSignalChannel(ctx Context, signalName string) Channel {
 	return internal.GetSignalChannel(ctx, signalName)
 }
 
+// SetSignalHandler sets the handler invoked for every signal with the given name, as an alternative to receiving
+// from GetSignalChannel in a loop. The handler must accept workflow.Context as the first parameter and optionally the
+// signal argument, and must not return any value. Signals are handled one at a time in the order they were received,
+// by a coroutine started by SetSignalHandler, so the handler may call blocking workflow APIs.
+// Only one handler can be set for a signal name.
+func SetSignalHandler(ctx Context, signalName string, handler interface{}) error {
+	return internal.SetSignalHandler(ctx, signalName, handler)
+}
+
+// GetSignalChannelWithOptions returns channel corresponding to the signal name with a bounded buffer.
+// Signals delivered while the channel holds options.MaxBufferedSignals signals are passed to options.OnOverflow
+// instead of being buffered, or dropped if no callback is set. This allows workflows that receive signals at a high
+// rate to drop or aggregate them instead of growing the workflow state without bounds.
+// The options apply to the signal channel for the rest of the workflow execution.
+func GetSignalChannelWithOptions(ctx Context, signalName string, options SignalChannelOptions) Channel {
+	return internal.GetSignalChannelWithOptions(ctx, signalName, options)
+}
+
 // SideEffect executes the provided function once, records its result into the workflow history. The recorded result on
 // history will be returned without executing the provided function during replay. This guarantees the deterministic
 // requirement for workflow as the exact same result will be returned in replay.
@@ -280,6 +326,51 @@ func MutableSideEffect(ctx Context, id string, f func(ctx Context) interface{},
 	return internal.MutableSideEffect(ctx, id, f, equals)
 }
 
+// GetConfigValue returns the value of key from the config snapshot provided by worker.Options.WorkflowConfigProvider.
+// The worker takes the snapshot once per decision task and records it in the workflow history as a single marker
+// when it differs from the last recorded one. Replays read the recorded snapshots, so workflow behavior can depend
+// on dynamic config without a SideEffect or MutableSideEffect call per key.
+// The returned value has no value (HasValue returns false) when key is not in the snapshot. Without a provider an
+// empty snapshot is recorded, so that the provider can be added to the worker of running workflows.
+//
+//	var limit int
+//	if value := workflow.GetConfigValue(ctx, "batch.limit"); value.HasValue() {
+//		if err := value.Get(&limit); err != nil {
+//			return err
+//		}
+//	}
+func GetConfigValue(ctx Context, key string) encoded.Value {
+	return internal.GetConfigValue(ctx, key)
+}
+
+// SetSnapshotter lets the worker save snapshots of the workflow state to worker.Options.WorkflowSnapshotStore, so
+// that a worker that does not have the workflow in its sticky cache resumes it from the last snapshot instead of
+// replaying the whole history. It returns true if the state was just restored from a snapshot. The workflow function
+// is still called from its start when it is resumed, so SetSnapshotter must be called before any other workflow API,
+// and the workflow must continue from the restored state when it returns true.
+// A snapshot is only taken while the workflow waits for a signal with nothing else pending: no activities, timers,
+// child workflows, local activities, sessions, cancellation request or unreceived signals.
+//
+//	// state is a *counterState, which implements Snapshotter by encoding its Count field
+//	restored, err := workflow.SetSnapshotter(ctx, state)
+//	if err != nil {
+//		return err
+//	}
+//	if !restored {
+//		state.Count = 0
+//	}
+//	signalCh := workflow.GetSignalChannel(ctx, "add")
+//	for state.Count < 1000 {
+//		var value int
+//		signalCh.Receive(ctx, &value)
+//		state.Count += value
+//	}
+//
+// EXPERIMENTAL: the snapshot API may change in future releases.
+func SetSnapshotter(ctx Context, snapshotter Snapshotter) (bool, error) {
+	return internal.SetWorkflowSnapshotter(ctx, snapshotter)
+}
+
 // DefaultVersion is a version returned by GetVersion for code that wasn't versioned before
 const DefaultVersion Version = internal.DefaultVersion
 
@@ -386,6 +477,18 @@ func SetQueryHandler(ctx Context, queryType string, handler interface{}) error {
 	return internal.SetQueryHandler(ctx, queryType, handler)
 }
 
+// SetQueryHandlerWithOptions sets the query handler like SetQueryHandler, with additional options.
+// The handler may also accept workflow.Context as its first parameter, which can be used for read only access like
+// GetInfo or GetLogger. If options.Timeout is set and the handler takes longer, the query fails and a warning is logged.
+// Example:
+//  err := workflow.SetQueryHandlerWithOptions(ctx, "current_state", func(ctx workflow.Context) (string, error) {
+//    workflow.GetLogger(ctx).Info("Querying current state.")
+//    return currentState, nil
+//  }, workflow.QueryHandlerOptions{Timeout: time.Second})
+func SetQueryHandlerWithOptions(ctx Context, queryType string, handler interface{}, options QueryHandlerOptions) error {
+	return internal.SetQueryHandlerWithOptions(ctx, queryType, handler, options)
+}
+
 // IsReplaying returns whether the current workflow code is replaying.
 //
 // Warning! Never make decisions, like schedule activity/childWorkflow/timer or send/wait on future/channel, based on
@@ -0,0 +1,92 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package a
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"example.com/b"
+	"go.uber.org/cadence/workflow"
+)
+
+func init() {
+	workflow.Register(RegisteredWorkflow)
+	workflow.Register(b.Workflow) // want `registered workflow function Workflow starts a goroutine`
+	workflow.Register(func(i int) {
+		time.Sleep(time.Second) // want `workflow function literal calls time.Sleep, use workflow.Sleep instead`
+	})
+}
+
+func register(r workflow.Registry) {
+	r.RegisterWorkflow(RegisteredWorkflow)
+}
+
+func RegisteredWorkflow(i int) int {
+	return rand.Intn(i) // want `workflow function RegisteredWorkflow calls rand.Intn, use workflow.SideEffect instead`
+}
+
+func Workflow(ctx workflow.Context, m map[string]int, ch chan int) error {
+	_ = time.Now() // want `workflow function Workflow calls time.Now, use workflow.Now instead`
+	_ = workflow.Now(ctx)
+	go func() {}() // want `workflow function Workflow starts a goroutine, use workflow.Go instead`
+	workflow.Go(ctx, func(ctx workflow.Context) {})
+	for k := range m { // want `workflow function Workflow iterates over a map, the iteration order is random, sort the keys first`
+		_ = k
+	}
+	ch <- 1  // want `workflow function Workflow sends on a native channel, use workflow.Channel instead`
+	<-ch     // want `workflow function Workflow receives from a native channel, use workflow.Channel instead`
+	select { // want `workflow function Workflow uses a select statement, use workflow.NewSelector instead`
+	case <-ch:
+	default:
+	}
+	workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} { return time.Now() })
+	workflow.ExecuteActivity(ctx, activity)
+	_ = b.Deterministic()
+	_ = b.CallsNotDeterministic() // want `workflow function Workflow calls CallsNotDeterministic which calls NotDeterministic which calls time.Now`
+	_ = helper()                  // want `workflow function Workflow calls helper which iterates over a map`
+	_ = sortedKeys(m)
+	return ChildWorkflow(ctx)
+}
+
+func ChildWorkflow(ctx workflow.Context) error {
+	time.Sleep(time.Second) // want `workflow function ChildWorkflow calls time.Sleep, use workflow.Sleep instead`
+	return nil
+}
+
+func helper() int {
+	sum := 0
+	for _, v := range map[string]int{} {
+		sum += v
+	}
+	return sum
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	sort.Strings(keys)
+	return keys
+}
+
+func activity() time.Time {
+	return time.Now()
+}
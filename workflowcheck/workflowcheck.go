@@ -0,0 +1,377 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package workflowcheck provides a static analyzer that reports code breaking the determinism of Cadence workflows.
+//
+// Workflow code is replayed from the workflow history, so it must produce the same decisions every time it runs. The
+// analyzer reports the following in workflow functions, and in the functions they call, also across packages:
+//   - calls to time.Now, time.Sleep and the timers of the time package, use workflow.Now, workflow.Sleep and
+//     workflow.NewTimer instead
+//   - calls to the functions of math/rand and crypto/rand, use workflow.SideEffect instead
+//   - go statements, use workflow.Go instead
+//   - select statements, sends and receives on native channels, use workflow.NewSelector and workflow.Channel instead
+//   - iteration over maps, the iteration order is random, sort the keys first
+//
+// Workflow functions are the functions taking a workflow.Context as their first parameter and the functions registered
+// with workflow.Register or the RegisterWorkflow methods of worker.Worker and worker.WorkflowReplayer. The functions
+// passed to workflow.SideEffect, workflow.MutableSideEffect and the activities executed by the workflow are not
+// workflow code and are not checked.
+//
+// The analyzer can be run with go vet through the workflowcheck command:
+//
+//	go install go.uber.org/cadence/workflowcheck/cmd/workflowcheck
+//	go vet -vettool=$(which workflowcheck) ./...
+package workflowcheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const cadencePackagePrefix = "go.uber.org/cadence"
+
+// Analyzer reports code breaking the determinism of Cadence workflows, see the package documentation.
+var Analyzer = &analysis.Analyzer{
+	Name:      "workflowcheck",
+	Doc:       "reports code breaking the determinism of Cadence workflows",
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(nonDeterministicFact)},
+}
+
+type (
+	// nonDeterministicFact is exported for the functions that are not deterministic, so that the workflow functions
+	// calling them from other packages are reported.
+	nonDeterministicFact struct {
+		Reason string
+	}
+
+	violation struct {
+		pos    token.Pos
+		reason string
+		hint   string
+	}
+
+	call struct {
+		pos    token.Pos
+		callee *types.Func
+	}
+
+	// function is a function or function literal declared in the analyzed package.
+	function struct {
+		name       string
+		violations []violation
+		calls      []call
+	}
+)
+
+// timeFunctions are the functions of the time package that depend on the wall clock, with their workflow replacement.
+var timeFunctions = map[string]string{
+	"Now":       "workflow.Now",
+	"Since":     "workflow.Now",
+	"Until":     "workflow.Now",
+	"Sleep":     "workflow.Sleep",
+	"After":     "workflow.NewTimer",
+	"AfterFunc": "workflow.NewTimer",
+	"NewTimer":  "workflow.NewTimer",
+	"NewTicker": "workflow.NewTimer",
+	"Tick":      "workflow.NewTimer",
+}
+
+// sideEffectFunctions take functions that are not executed as workflow code.
+var sideEffectFunctions = map[string]bool{
+	"SideEffect":           true,
+	"MutableSideEffect":    true,
+	"ExecuteActivity":      true,
+	"ExecuteLocalActivity": true,
+}
+
+func (*nonDeterministicFact) AFact() {}
+
+func (f *nonDeterministicFact) String() string {
+	return fmt.Sprintf("nonDeterministic(%s)", f.Reason)
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if isCadencePackage(pass.Pkg.Path()) || isStandardPackage(pass.Pkg.Path()) {
+		// the workflow APIs are deterministic by design, and the standard library is checked through its calls.
+		return nil, nil
+	}
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	functions := make(map[*types.Func]*function)
+	var roots []*function
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(n ast.Node) {
+		decl := n.(*ast.FuncDecl)
+		fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func)
+		if !ok || decl.Body == nil {
+			return
+		}
+		f := newFunction(pass, fn.Name(), decl.Body)
+		functions[fn] = f
+		if isWorkflowFunction(fn.Type().(*types.Signature)) {
+			roots = append(roots, f)
+		}
+	})
+
+	// the functions registered as workflows are roots as well, even if they are declared in another package.
+	registered := make(map[*types.Func]token.Pos)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		expr := n.(*ast.CallExpr)
+		if !isRegisterWorkflow(calledFunction(pass.TypesInfo, expr)) || len(expr.Args) == 0 {
+			return
+		}
+		switch arg := astutil.Unparen(expr.Args[0]).(type) {
+		case *ast.FuncLit:
+			roots = append(roots, newFunction(pass, "literal", arg.Body))
+		case *ast.Ident, *ast.SelectorExpr:
+			if fn := referencedFunction(pass.TypesInfo, arg); fn != nil {
+				registered[fn] = arg.Pos()
+			}
+		}
+	})
+
+	reasons := nonDeterministicReasons(pass, functions)
+	for fn, reason := range reasons {
+		pass.ExportObjectFact(fn, &nonDeterministicFact{Reason: reason})
+	}
+
+	for fn, pos := range registered {
+		if f, ok := functions[fn]; ok {
+			roots = append(roots, f)
+		} else if reason, ok := calleeReason(pass, reasons, fn); ok {
+			pass.Reportf(pos, "registered workflow function %s %s", fn.Name(), reason)
+		}
+	}
+	isRoot := make(map[*function]bool)
+	for _, f := range roots {
+		isRoot[f] = true
+	}
+	reported := make(map[*function]bool)
+	for _, f := range roots {
+		if reported[f] {
+			continue
+		}
+		reported[f] = true
+		for _, v := range f.violations {
+			pass.Reportf(v.pos, "workflow function %s %s, %s", f.name, v.reason, v.hint)
+		}
+		for _, c := range f.calls {
+			if callee, ok := functions[c.callee]; ok && isRoot[callee] {
+				// reported in the callee itself
+				continue
+			}
+			if reason, ok := calleeReason(pass, reasons, c.callee); ok {
+				pass.Reportf(c.pos, "workflow function %s calls %s which %s", f.name, c.callee.Name(), reason)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// newFunction collects the violations and the calls of a function body.
+func newFunction(pass *analysis.Pass, name string, body *ast.BlockStmt) *function {
+	f := &function{name: name}
+	f.inspect(pass, body)
+	return f
+}
+
+func (f *function) inspect(pass *analysis.Pass, node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool { return f.visit(pass, n) })
+}
+
+func (f *function) report(pos token.Pos, reason, hint string) {
+	f.violations = append(f.violations, violation{pos: pos, reason: reason, hint: hint})
+}
+
+func (f *function) visit(pass *analysis.Pass, n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.CallExpr:
+		fn := calledFunction(pass.TypesInfo, n)
+		if fn == nil {
+			return true
+		}
+		if isCadenceFunction(fn) && sideEffectFunctions[fn.Name()] {
+			// the function literals passed in are not workflow code, only check the other arguments.
+			for _, arg := range n.Args {
+				if _, ok := astutil.Unparen(arg).(*ast.FuncLit); !ok {
+					f.inspect(pass, arg)
+				}
+			}
+			return false
+		}
+		if reason, hint, ok := forbiddenCall(fn); ok {
+			f.report(n.Pos(), reason, hint)
+		} else if !isCadenceFunction(fn) {
+			f.calls = append(f.calls, call{pos: n.Pos(), callee: fn})
+		}
+	case *ast.GoStmt:
+		f.report(n.Pos(), "starts a goroutine", "use workflow.Go instead")
+	case *ast.SelectStmt:
+		f.report(n.Pos(), "uses a select statement", "use workflow.NewSelector instead")
+		// the channel operations of the cases are part of the select statement, only check the bodies.
+		for _, clause := range n.Body.List {
+			for _, stmt := range clause.(*ast.CommClause).Body {
+				f.inspect(pass, stmt)
+			}
+		}
+		return false
+	case *ast.SendStmt:
+		f.report(n.Pos(), "sends on a native channel", "use workflow.Channel instead")
+	case *ast.UnaryExpr:
+		if n.Op == token.ARROW {
+			f.report(n.Pos(), "receives from a native channel", "use workflow.Channel instead")
+		}
+	case *ast.RangeStmt:
+		switch pass.TypesInfo.TypeOf(n.X).Underlying().(type) {
+		case *types.Map:
+			f.report(n.Pos(), "iterates over a map", "the iteration order is random, sort the keys first")
+		case *types.Chan:
+			f.report(n.Pos(), "receives from a native channel", "use workflow.Channel instead")
+		}
+	}
+	return true
+}
+
+// nonDeterministicReasons returns the reason of every function of the package that is not deterministic, either
+// because of its own code or because of the functions it calls.
+func nonDeterministicReasons(pass *analysis.Pass, functions map[*types.Func]*function) map[*types.Func]string {
+	reasons := make(map[*types.Func]string)
+	for fn, f := range functions {
+		if len(f.violations) > 0 {
+			reasons[fn] = f.violations[0].reason
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for fn, f := range functions {
+			if _, ok := reasons[fn]; ok {
+				continue
+			}
+			for _, c := range f.calls {
+				if reason, ok := calleeReason(pass, reasons, c.callee); ok {
+					reasons[fn] = fmt.Sprintf("calls %s which %s", c.callee.Name(), reason)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return reasons
+}
+
+func calleeReason(pass *analysis.Pass, reasons map[*types.Func]string, fn *types.Func) (string, bool) {
+	if reason, ok := reasons[fn]; ok {
+		return reason, true
+	}
+	var fact nonDeterministicFact
+	if fn.Pkg() != pass.Pkg && pass.ImportObjectFact(fn, &fact) {
+		return fact.Reason, true
+	}
+	return "", false
+}
+
+// forbiddenCall returns why a call to a function of the standard library is not deterministic.
+func forbiddenCall(fn *types.Func) (reason, hint string, forbidden bool) {
+	if fn.Pkg() == nil || fn.Type().(*types.Signature).Recv() != nil {
+		return "", "", false
+	}
+	switch fn.Pkg().Path() {
+	case "time":
+		if replacement, ok := timeFunctions[fn.Name()]; ok {
+			return "calls time." + fn.Name(), "use " + replacement + " instead", true
+		}
+	case "math/rand", "crypto/rand":
+		if !strings.HasPrefix(fn.Name(), "New") {
+			return "calls " + fn.Pkg().Name() + "." + fn.Name(), "use workflow.SideEffect instead", true
+		}
+	}
+	return "", "", false
+}
+
+func calledFunction(info *types.Info, expr *ast.CallExpr) *types.Func {
+	return referencedFunction(info, astutil.Unparen(expr.Fun))
+}
+
+func referencedFunction(info *types.Info, expr ast.Expr) *types.Func {
+	var fn types.Object
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		fn = info.Uses[expr]
+	case *ast.SelectorExpr:
+		fn = info.Uses[expr.Sel]
+	}
+	f, _ := fn.(*types.Func)
+	return f
+}
+
+// isStandardPackage checks if the package is part of the standard library, the first element of their paths has no dot.
+func isStandardPackage(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+// isCadencePackage checks if the package is part of the client, and not of another module sharing its path prefix.
+func isCadencePackage(path string) bool {
+	return path == cadencePackagePrefix || strings.HasPrefix(path, cadencePackagePrefix+"/")
+}
+
+func isCadenceFunction(fn *types.Func) bool {
+	return fn.Pkg() != nil && isCadencePackage(fn.Pkg().Path())
+}
+
+// isRegisterWorkflow checks if the function registers a workflow, e.g. workflow.Register or worker.RegisterWorkflow.
+func isRegisterWorkflow(fn *types.Func) bool {
+	if fn == nil || !isCadenceFunction(fn) {
+		return false
+	}
+	switch fn.Name() {
+	case "RegisterWorkflow", "RegisterWorkflowWithOptions":
+		return true
+	case "Register", "RegisterWithOptions":
+		return strings.HasSuffix(fn.Pkg().Path(), "/workflow")
+	}
+	return false
+}
+
+// isWorkflowFunction checks if the first parameter of the function is a workflow.Context.
+func isWorkflowFunction(sig *types.Signature) bool {
+	if sig.Params().Len() == 0 {
+		return false
+	}
+	// workflow.Context is an alias of internal.Context, both named and alias types have an Obj method.
+	named, ok := sig.Params().At(0).Type().(interface{ Obj() *types.TypeName })
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	if obj.Name() != "Context" || obj.Pkg() == nil {
+		return false
+	}
+	path := obj.Pkg().Path()
+	return path == cadencePackagePrefix+"/workflow" || path == cadencePackagePrefix+"/internal"
+}
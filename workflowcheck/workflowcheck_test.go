@@ -0,0 +1,163 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package workflowcheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var wantPattern = regexp.MustCompile("// want `([^`]*)`")
+
+// testChecker runs the analyzer on the packages of testdata/src one by one, as go vet does. The packages are type
+// checked here instead of being loaded through the go command, so that the test doesn't depend on its version.
+type testChecker struct {
+	t        *testing.T
+	fset     *token.FileSet
+	std      types.Importer
+	packages map[string]*types.Package
+	facts    map[types.Object]analysis.Fact
+	// diagnostics and the patterns of the expected diagnostics by file:line
+	diagnostics map[string]string
+	want        map[string]*regexp.Regexp
+}
+
+func TestAnalyzer(t *testing.T) {
+	c := newTestChecker(t)
+	c.check("example.com/a")
+
+	for position, pattern := range c.want {
+		message, ok := c.diagnostics[position]
+		if !ok {
+			t.Errorf("%s: no diagnostic was reported, want %q", position, pattern)
+		} else if !pattern.MatchString(message) {
+			t.Errorf("%s: diagnostic %q does not match %q", position, message, pattern)
+		}
+	}
+	for position, message := range c.diagnostics {
+		if _, ok := c.want[position]; !ok {
+			t.Errorf("%s: unexpected diagnostic %q", position, message)
+		}
+	}
+}
+
+func TestIsCadencePackage(t *testing.T) {
+	require.True(t, isCadencePackage("go.uber.org/cadence"))
+	require.True(t, isCadencePackage("go.uber.org/cadence/workflow"))
+	require.False(t, isCadencePackage("go.uber.org/cadence-samples/workflow"))
+	require.False(t, isCadencePackage("go.uber.org/cadencefx"))
+}
+
+func newTestChecker(t *testing.T) *testChecker {
+	fset := token.NewFileSet()
+	return &testChecker{
+		t:           t,
+		fset:        fset,
+		std:         importer.ForCompiler(fset, "source", nil),
+		packages:    make(map[string]*types.Package),
+		facts:       make(map[types.Object]analysis.Fact),
+		diagnostics: make(map[string]string),
+		want:        make(map[string]*regexp.Regexp),
+	}
+}
+
+func (c *testChecker) Import(path string) (*types.Package, error) {
+	if isStandardPackage(path) {
+		return c.std.Import(path)
+	}
+	return c.check(path), nil
+}
+
+func (c *testChecker) position(pos token.Pos) string {
+	position := c.fset.Position(pos)
+	return fmt.Sprintf("%s:%d", filepath.Base(position.Filename), position.Line)
+}
+
+func (c *testChecker) check(path string) *types.Package {
+	if pkg, ok := c.packages[path]; ok {
+		return pkg
+	}
+	dir := filepath.Join("testdata", "src", filepath.FromSlash(path))
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(c.t, err)
+	var files []*ast.File
+	for _, info := range infos {
+		file, err := parser.ParseFile(c.fset, filepath.Join(dir, info.Name()), nil, parser.ParseComments)
+		require.NoError(c.t, err)
+		files = append(files, file)
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				if match := wantPattern.FindStringSubmatch(comment.Text); match != nil {
+					c.want[c.position(comment.Pos())] = regexp.MustCompile(match[1])
+				}
+			}
+		}
+	}
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	pkg, err := (&types.Config{Importer: c}).Check(path, c.fset, files, info)
+	require.NoError(c.t, err)
+	c.packages[path] = pkg
+
+	pass := &analysis.Pass{
+		Analyzer:  Analyzer,
+		Fset:      c.fset,
+		Files:     files,
+		Pkg:       pkg,
+		TypesInfo: info,
+		ResultOf:  map[*analysis.Analyzer]interface{}{inspect.Analyzer: inspector.New(files)},
+		Report: func(d analysis.Diagnostic) {
+			c.diagnostics[c.position(d.Pos)] = d.Message
+		},
+		ImportObjectFact: func(obj types.Object, fact analysis.Fact) bool {
+			exported, ok := c.facts[obj]
+			if ok {
+				reflect.ValueOf(fact).Elem().Set(reflect.ValueOf(exported).Elem())
+			}
+			return ok
+		},
+		ExportObjectFact: func(obj types.Object, fact analysis.Fact) {
+			c.facts[obj] = fact
+		},
+	}
+	_, err = Analyzer.Run(pass)
+	require.NoError(c.t, err)
+	return pkg
+}